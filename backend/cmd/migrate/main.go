@@ -0,0 +1,170 @@
+// Command migrate inspects and changes the database schema outside of server
+// startup.
+//
+//	go run ./cmd/migrate [-dry-run] up
+//	go run ./cmd/migrate [-dry-run] down [N]
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate [-dry-run] docs-to-flows
+//
+// docs-to-flows converts legacy documents into flows (see
+// service.DocConversionService) and prints a verification report for the
+// documents it converted; it exits non-zero when verification finds a
+// mismatch. Re-running it only converts documents that have not been
+// converted yet.
+//
+// It reads DB_DRIVER, DB_DSN and MIGRATE_LOCK_TIMEOUT like the server does.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"docmv/internal/config"
+	"docmv/internal/repository"
+	"docmv/internal/service"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing anything")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [-dry-run] up | down [N] | status | docs-to-flows")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	db, err := repository.NewDB(cfg.DBDriver, cfg.DBDSN)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := repository.NewMigrator(db, cfg.DBDriver)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator.DryRun = *dryRun
+	migrator.LockTimeout = cfg.MigrateLockTimeout
+
+	ctx := context.Background()
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		done, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		log.Printf("%d migration(s) %s", len(done), verb(*dryRun, "applied"))
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps <= 0 {
+				log.Fatalf("invalid step count %q", flag.Arg(1))
+			}
+		}
+		done, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		log.Printf("%d migration(s) %s", len(done), verb(*dryRun, "rolled back"))
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		for _, st := range statuses {
+			state := "pending"
+			switch {
+			case st.Missing:
+				state = "applied, file missing"
+			case st.Modified:
+				state = "applied, MODIFIED since"
+			case st.Applied:
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d  %-32s %s\n", st.Version, st.Name, state)
+		}
+
+	case "docs-to-flows":
+		// The conversion bookkeeping table is created by a schema migration.
+		if !*dryRun {
+			if _, err := migrator.Up(ctx); err != nil {
+				log.Fatalf("migrate up: %v", err)
+			}
+		}
+		conversionSvc := service.NewDocConversionService(repository.NewTxManager(db),
+			repository.NewDocumentConversionRepo(db),
+			repository.NewVersionRepo(db),
+			repository.NewWorkflowNodeRepo(db),
+			repository.NewDocumentShareRepo(db),
+			repository.NewFlowRepo(db),
+			repository.NewFlowNodeRepo(db),
+			repository.NewFlowVersionRepo(db),
+			repository.NewFlowShareRepo(db),
+			repository.NewDocumentGroupShareRepo(db),
+			repository.NewFlowGroupShareRepo(db),
+		)
+		report, err := conversionSvc.Convert(ctx, *dryRun)
+		if err != nil {
+			log.Fatalf("docs-to-flows: %v", err)
+		}
+		printConversionReport(report)
+		if len(report.Problems) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		log.Printf("unknown command %q", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func verb(dryRun bool, done string) string {
+	if dryRun {
+		return "would be " + done
+	}
+	return done
+}
+
+func printConversionReport(r *service.DocConversionReport) {
+	for _, it := range r.Items {
+		switch it.Outcome {
+		case service.ConversionConverted:
+			fmt.Printf("%s  %-18s %s  %-9s nodes=%d shares=%d versions=%d  %s\n",
+				it.DocumentID, it.Outcome, it.FlowNo, it.Status, it.Nodes, it.Shares, it.Versions, it.Title)
+		case service.ConversionExisting:
+			fmt.Printf("%s  %-18s flow=%s  %s\n", it.DocumentID, it.Outcome, it.FlowID, it.Title)
+		default:
+			fmt.Printf("%s  %-18s %s\n", it.DocumentID, it.Outcome, it.Title)
+		}
+	}
+
+	fmt.Printf("\ndocuments: %d  %s: %d  already converted: %d  skipped (trashed): %d\n",
+		r.Documents, verb(r.DryRun, "converted"), r.Converted, r.Existing, r.Skipped)
+	if r.DryRun {
+		fmt.Println("dry run: no changes were written, verification skipped")
+		return
+	}
+	if len(r.Problems) == 0 {
+		fmt.Println("verification: OK")
+		return
+	}
+	fmt.Printf("verification: %d problem(s)\n", len(r.Problems))
+	for _, p := range r.Problems {
+		fmt.Println("  - " + p)
+	}
+}
@@ -0,0 +1,52 @@
+// Command mockidp runs a mock OpenID Connect provider for trying single
+// sign-on locally. It logs in everyone as the user given by its flags,
+// without asking.
+//
+//	go run ./cmd/mockidp -addr :9000 -email alice@example.com -groups docmv-admins
+//
+// Point the server at it with OIDC_ISSUER=http://localhost:9000,
+// OIDC_CLIENT_ID=docmv and OIDC_CLIENT_SECRET=secret (the defaults of
+// -client-id and -client-secret).
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"docmv/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, as the server reaches it")
+	clientID := flag.String("client-id", "docmv", "accepted client id")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	sub := flag.String("sub", "mock-user-1", "subject of the user who logs in")
+	email := flag.String("email", "sso.user@example.com", "email of the user who logs in")
+	verified := flag.Bool("email-verified", true, "whether the email is verified")
+	name := flag.String("name", "SSO User", "display name of the user who logs in")
+	groups := flag.String("groups", "", "comma-separated groups claim")
+	flag.Parse()
+
+	idp, err := oidctest.New(strings.TrimSuffix(*issuer, "/"), *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("starting mock idp: %v", err)
+	}
+	user := map[string]interface{}{
+		"sub":            *sub,
+		"email":          *email,
+		"email_verified": *verified,
+		"name":           *name,
+	}
+	if *groups != "" {
+		user["groups"] = strings.Split(*groups, ",")
+	}
+	idp.SetUser(user)
+
+	log.Printf("mock idp for client %q serving %s on %s, logging in %s", *clientID, idp.Issuer, *addr, *email)
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		log.Fatalf("mock idp failed: %v", err)
+	}
+}
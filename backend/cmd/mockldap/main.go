@@ -0,0 +1,75 @@
+// Command mockldap runs a mock LDAP directory for trying directory logins
+// locally. It holds a service account, a docmv-admins group and the users
+// given by its flags, all in dc=example,dc=com.
+//
+//	go run ./cmd/mockldap -addr :3389 -users alice,bob -admins alice
+//
+// Point the server at it with LDAP_URL=ldap://localhost:3389,
+// LDAP_BASE_DN=dc=example,dc=com, LDAP_BIND_DN=cn=docmv,dc=example,dc=com,
+// LDAP_BIND_PASSWORD=secret and
+// LDAP_ADMIN_GROUPS=cn=docmv-admins,ou=groups,dc=example,dc=com. Users log
+// in as alice or alice@example.com with the password of -password.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"docmv/internal/ldap/ldaptest"
+)
+
+const (
+	base      = "dc=example,dc=com"
+	serviceDN = "cn=docmv," + base
+	adminsDN  = "cn=docmv-admins,ou=groups," + base
+)
+
+func main() {
+	addr := flag.String("addr", ":3389", "listen address")
+	ldaps := flag.Bool("ldaps", false, "speak LDAP over TLS with a self-signed certificate")
+	servicePassword := flag.String("bind-password", "secret", "password of "+serviceDN)
+	users := flag.String("users", "alice,bob", "comma-separated uids of the users")
+	admins := flag.String("admins", "alice", "comma-separated uids of the members of docmv-admins")
+	password := flag.String("password", "password", "password of every user")
+	flag.Parse()
+
+	srv, err := ldaptest.Listen(*addr, *ldaps)
+	if err != nil {
+		log.Fatalf("starting mock ldap: %v", err)
+	}
+	srv.Add(base, "", map[string][]string{"objectClass": {"domain"}, "dc": {"example"}})
+	srv.Add(serviceDN, *servicePassword, map[string][]string{"objectClass": {"applicationProcess"}, "cn": {"docmv"}})
+
+	adminUIDs := strings.Split(*admins, ",")
+	var members []string
+	for _, uid := range strings.Split(*users, ",") {
+		if uid = strings.TrimSpace(uid); uid == "" {
+			continue
+		}
+		dn := "uid=" + uid + ",ou=people," + base
+		attrs := map[string][]string{
+			"objectClass": {"person", "inetOrgPerson"},
+			"uid":         {uid},
+			"cn":          {uid},
+			"displayName": {strings.ToUpper(uid[:1]) + uid[1:]},
+			"mail":        {uid + "@example.com"},
+			"entryUUID":   {"mock-" + uid},
+		}
+		if slices.Contains(adminUIDs, uid) {
+			attrs["memberOf"] = []string{adminsDN}
+			members = append(members, dn)
+		}
+		srv.Add(dn, *password, attrs)
+	}
+	srv.Add(adminsDN, "", map[string][]string{"objectClass": {"groupOfNames"}, "cn": {"docmv-admins"}, "member": members})
+
+	log.Printf("mock ldap serving %s on %s, users %s", base, srv.URL, *users)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	srv.Close()
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"docmv/internal/config"
 	"docmv/internal/handler"
+	"docmv/internal/ldap"
+	"docmv/internal/oidc"
 	"docmv/internal/repository"
 	"docmv/internal/service"
 )
@@ -23,29 +26,154 @@ func main() {
 	}
 	defer db.Close()
 
-	// Auto-migrate: create tables/columns if they don't exist
-	if err := repository.AutoMigrate(db, cfg.DBDriver); err != nil {
-		log.Fatalf("auto-migration failed: %v", err)
+	// Apply pending schema migrations (serialised across replicas by a DB lock)
+	migrator, err := repository.NewMigrator(db, cfg.DBDriver)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator.LockTimeout = cfg.MigrateLockTimeout
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("migration failed: %v", err)
 	}
 
 	// Repositories
+	txm := repository.NewTxManager(db)
 	userRepo := repository.NewUserRepo(db)
 	docRepo := repository.NewDocumentRepo(db)
 	versionRepo := repository.NewVersionRepo(db)
+	nodeRepo := repository.NewWorkflowNodeRepo(db)
 	flowRepo := repository.NewFlowRepo(db)
+	flowNodeRepo := repository.NewFlowNodeRepo(db)
+	flowVersionRepo := repository.NewFlowVersionRepo(db)
+	docShareRepo := repository.NewDocumentShareRepo(db)
+	flowShareRepo := repository.NewFlowShareRepo(db)
+	docGroupShareRepo := repository.NewDocumentGroupShareRepo(db)
+	flowGroupShareRepo := repository.NewFlowGroupShareRepo(db)
+	transferRepo := repository.NewOwnershipTransferRepo(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepo(db)
+	apiTokenRepo := repository.NewAPITokenRepo(db)
+	throttleRepo := repository.NewLoginThrottleRepo(db)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepo(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepo(db)
+	settingRepo := repository.NewSettingRepo(db)
+	identityRepo := repository.NewUserIdentityRepo(db)
+	oidcLoginRepo := repository.NewOIDCLoginRepo(db)
+	groupRepo := repository.NewGroupRepo(db)
+	signingKeyRepo := repository.NewSigningKeyRepo(db)
+
+	// Login protection and password rules
+	loginGuard := service.NewLoginGuard(throttleRepo, service.LockoutPolicy{
+		MaxAccountFailures: cfg.LoginMaxFailures,
+		MaxIPFailures:      cfg.LoginIPMaxFailures,
+		Lockout:            cfg.LoginLockout,
+		Delay:              cfg.LoginDelay,
+	})
+	passwordPolicy := &service.PasswordPolicy{
+		MinLength:  cfg.PasswordMinLength,
+		MinClasses: cfg.PasswordMinClasses,
+		History:    cfg.PasswordHistory,
+	}
+	if cfg.PasswordBreachedFile != "" {
+		n, err := passwordPolicy.LoadBreachedList(cfg.PasswordBreachedFile)
+		if err != nil {
+			log.Fatalf("failed to load password policy: %v", err)
+		}
+		log.Printf("loaded %d breached passwords from %s", n, cfg.PasswordBreachedFile)
+	}
+
+	// Passwords that are not local are checked with the directory
+	var authenticators []service.Authenticator
+	if cfg.LDAPURL != "" {
+		ldapCfg := ldap.Config{
+			URL:          cfg.LDAPURL,
+			StartTLS:     cfg.LDAPStartTLS,
+			BindDN:       cfg.LDAPBindDN,
+			BindPassword: cfg.LDAPBindPassword,
+			BaseDN:       cfg.LDAPBaseDN,
+			UserFilter:   cfg.LDAPUserFilter,
+			IDAttr:       cfg.LDAPIDAttr,
+			EmailAttr:    cfg.LDAPEmailAttr,
+			NameAttr:     cfg.LDAPNameAttr,
+			GroupAttr:    cfg.LDAPGroupAttr,
+			GroupBaseDN:  cfg.LDAPGroupBaseDN,
+			GroupFilter:  cfg.LDAPGroupFilter,
+		}
+		if cfg.LDAPCAFile != "" {
+			if ldapCfg.TLS, err = ldap.LoadCA(cfg.LDAPCAFile); err != nil {
+				log.Fatalf("failed to load LDAP CA: %v", err)
+			}
+		}
+		authenticators = append(authenticators, service.NewLDAPAuthenticator(ldap.New(ldapCfg), identityRepo, userRepo, service.LDAPOptions{
+			AdminGroups: cfg.LDAPAdminGroups,
+		}))
+		log.Printf("directory logins through %s enabled", cfg.LDAPURL)
+	}
+
+	// Access token keys; the first asymmetric key is created here
+	signer := service.NewTokenSigner(signingKeyRepo, service.TokenSignerOptions{
+		Algorithm:   cfg.JWTAlgorithm,
+		Secret:      cfg.JWTSecret,
+		Rotation:    cfg.JWTKeyRotation,
+		MaxTokenAge: cfg.AccessTokenTTL,
+	})
+	if err := signer.Rotate(context.Background(), time.Now()); err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	if cfg.JWTAlgorithm == service.AlgHS256 && cfg.JWTSecret == config.DevJWTSecret {
+		log.Printf("WARNING: access tokens are signed with the development JWT secret")
+	}
 
 	// Services
-	authSvc := service.NewAuthService(userRepo, cfg.JWTSecret)
-	docSvc := service.NewDocumentService(db, docRepo, versionRepo)
-	flowSvc := service.NewFlowService(db, flowRepo, docRepo)
+	twoFactorSvc := service.NewTwoFactorService(userRepo, recoveryCodeRepo, settingRepo, loginGuard, cfg.TOTPIssuer)
+	authSvc := service.NewAuthService(userRepo, refreshTokenRepo, passwordHistoryRepo, loginGuard, passwordPolicy, twoFactorSvc, signer, cfg.AccessTokenTTL, cfg.RefreshTokenTTL, authenticators...)
+	var oidcProvider *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		oidcProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil)
+		log.Printf("single sign-on through %s enabled", cfg.OIDCIssuer)
+	}
+	oidcSvc := service.NewOIDCService(oidcProvider, oidcLoginRepo, identityRepo, userRepo, authSvc, service.OIDCOptions{
+		DisplayName: cfg.OIDCDisplayName,
+		RoleClaim:   cfg.OIDCRoleClaim,
+		AdminValues: cfg.OIDCAdminValues,
+		AutoCreate:  cfg.OIDCAutoCreate,
+	})
+	scimSvc := service.NewSCIMService(cfg.SCIMToken, userRepo, groupRepo, identityRepo, authSvc)
+	if cfg.SCIMToken != "" {
+		log.Printf("SCIM provisioning enabled at /scim/v2")
+	}
+	apiTokenSvc := service.NewAPITokenService(apiTokenRepo, userRepo, twoFactorSvc)
+	docSvc := service.NewDocumentService(txm, docRepo, versionRepo)
+	nodeSvc := service.NewWorkflowNodeService(txm, nodeRepo, docRepo)
+	flowSvc := service.NewFlowService(txm, flowRepo, flowNodeRepo, flowVersionRepo)
+	shareSvc := service.NewShareService(docRepo, flowRepo, userRepo, groupRepo, docShareRepo, flowShareRepo, docGroupShareRepo, flowGroupShareRepo)
+	groupSvc := service.NewGroupService(txm, groupRepo, userRepo)
+	ownershipSvc := service.NewOwnershipService(txm, docRepo, flowRepo, userRepo, docShareRepo, flowShareRepo, transferRepo)
+	trashSvc := service.NewTrashService(txm, docRepo, flowRepo, nodeRepo, transferRepo)
 
 	// Seed default admin account
 	if err := authSvc.SeedAdmin(context.Background(), cfg.AdminEmail, cfg.AdminPassword); err != nil {
 		log.Fatalf("failed to seed admin: %v", err)
 	}
 
+	// Purge trashed items past the retention period
+	if cfg.TrashRetention > 0 {
+		go trashSvc.RunPurgeJob(context.Background(), cfg.TrashRetention, cfg.TrashPurgeInterval)
+	}
+
+	// Drop expired refresh tokens and forgotten login failures
+	go authSvc.RunPurgeJob(context.Background(), time.Hour)
+
+	// Rotate signing keys and pick up keys other instances created
+	go signer.RunRotationJob(context.Background(), time.Minute)
+
 	// Router
-	r := handler.NewRouter(cfg, authSvc, docSvc, flowSvc)
+	r := handler.NewRouter(cfg, authSvc, twoFactorSvc, oidcSvc, scimSvc, apiTokenSvc, docSvc, nodeSvc, flowSvc, shareSvc, groupSvc, ownershipSvc, trashSvc)
 
 	log.Printf("=== DocMV server starting on :%s [%s] ===", cfg.ServerPort, cfg.DBDriver)
 	if err := http.ListenAndServe(":"+cfg.ServerPort, r); err != nil {
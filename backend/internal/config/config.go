@@ -1,19 +1,83 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DevJWTSecret is the HS256 secret used in development when JWT_SECRET is
+// not set. It is refused outside development.
+const DevJWTSecret = "dev-secret-change-me"
+
+// placeholderJWTSecrets are secrets from the documentation and examples,
+// refused like DevJWTSecret.
+var placeholderJWTSecrets = []string{DevJWTSecret, "change-me-in-production-use-a-long-random-string", "your-production-secret"}
+
 // Config holds all configuration for the application.
 type Config struct {
-	DBDriver      string // "mysql" or "postgres"
+	DBDriver      string // "mysql", "postgres" or "sqlite"
 	DBDSN         string // driver-specific DSN
-	JWTSecret     string
 	ServerPort    string
 	AdminEmail    string // default admin account email (seed)
-	AdminPassword string // default admin account password (seed)
+	AdminPassword string // default admin account password (seed); empty generates one
+	DevMode       bool   // APP_ENV=development: allows the built-in JWT secret
+
+	JWTAlgorithm   string        // access token signature: EdDSA, RS256 or HS256
+	JWTSecret      string        // the HS256 key; asymmetric keys are generated and stored in the database
+	JWTKeyRotation time.Duration // how long a signing key signs before the next takes over; 0 never rotates
+
+	AccessTokenTTL  time.Duration // lifetime of a signed access token
+	RefreshTokenTTL time.Duration // lifetime of a refresh token; each refresh issues a new one
+
+	TrashRetention     time.Duration // how long soft-deleted items stay restorable; 0 disables purging
+	TrashPurgeInterval time.Duration // how often the purge job runs
+
+	MigrateLockTimeout time.Duration // how long startup waits for another instance's migration lock
+
+	LoginMaxFailures   int           // failed logins that lock an account; 0 disables
+	LoginIPMaxFailures int           // failed logins that lock a client address; 0 disables
+	LoginLockout       time.Duration // how long a lockout lasts and failures are remembered
+	LoginDelay         time.Duration // wait after the second failure in a row, doubling up to 30s
+
+	PasswordMinLength    int    // minimum password length in characters
+	PasswordMinClasses   int    // of lower case, upper case, digits and other characters
+	PasswordBreachedFile string // local list of breached passwords, one per line; empty disables
+	PasswordHistory      int    // previous passwords that may not be reused; 0 disables
+
+	TOTPIssuer string // name authenticator apps show for two-factor codes
+
+	OIDCIssuer       string // OpenID Connect provider; empty disables single sign-on
+	OIDCClientID     string
+	OIDCClientSecret string   // empty for a public client
+	OIDCRedirectURL  string   // the frontend's /login/sso page, as registered at the provider
+	OIDCScopes       []string // requested scopes; openid is always included
+	OIDCDisplayName  string   // provider name on the login button
+	OIDCRoleClaim    string   // ID token claim with roles or groups; empty leaves roles to admins
+	OIDCAdminValues  []string // values of the role claim that make a user ADMIN
+	OIDCAutoCreate   bool     // create users on their first single sign-on
+
+	LDAPURL          string // ldap:// or ldaps:// directory; empty disables directory logins
+	LDAPStartTLS     bool   // upgrade ldap:// connections to TLS
+	LDAPCAFile       string // PEM certificates to trust instead of the system roots
+	LDAPBindDN       string // service account that finds users; empty searches anonymously
+	LDAPBindPassword string
+	LDAPBaseDN       string
+	LDAPUserFilter   string // "{login}" stands for what the user typed
+	LDAPIDAttr       string // stable identifier of an entry; empty uses the DN
+	LDAPEmailAttr    string
+	LDAPNameAttr     string
+	LDAPGroupAttr    string   // attribute listing a user's groups
+	LDAPGroupBaseDN  string   // where LDAPGroupFilter searches; empty uses LDAPBaseDN
+	LDAPGroupFilter  string   // "{dn}" stands for the user's DN; replaces LDAPGroupAttr
+	LDAPAdminGroups  []string // group DNs whose members are ADMIN; empty leaves roles to admins
+
+	SCIMToken string // bearer token of the SCIM provisioning client; empty disables SCIM
 }
 
 // Load reads configuration from environment variables (with .env fallback).
@@ -23,14 +87,174 @@ func Load() (*Config, error) {
 	cfg := &Config{
 		DBDriver:      getEnv("DB_DRIVER", "mysql"),
 		DBDSN:         getEnv("DB_DSN", "docmv:docmv@tcp(127.0.0.1:3306)/docdb?parseTime=true&charset=utf8mb4&loc=Local"),
-		JWTSecret:     getEnv("JWT_SECRET", "dev-secret-change-me"),
+		JWTSecret:     os.Getenv("JWT_SECRET"),
 		ServerPort:    getEnv("SERVER_PORT", "8080"),
 		AdminEmail:    getEnv("ADMIN_EMAIL", "admin@docmv.local"),
-		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
+		AdminPassword: os.Getenv("ADMIN_PASSWORD"),
+		DevMode:       strings.EqualFold(os.Getenv("APP_ENV"), "development"),
+
+		PasswordBreachedFile: os.Getenv("PASSWORD_BREACHED_FILE"),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "DocMV"),
+
+		OIDCIssuer:       os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/login/sso"),
+		OIDCScopes:       strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		OIDCDisplayName:  getEnv("OIDC_DISPLAY_NAME", "SSO"),
+		OIDCRoleClaim:    os.Getenv("OIDC_ROLE_CLAIM"),
+		OIDCAdminValues:  splitList(os.Getenv("OIDC_ADMIN_VALUES"), ","),
+
+		LDAPURL:          os.Getenv("LDAP_URL"),
+		LDAPCAFile:       os.Getenv("LDAP_CA_FILE"),
+		LDAPBindDN:       os.Getenv("LDAP_BIND_DN"),
+		LDAPBindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		LDAPBaseDN:       os.Getenv("LDAP_BASE_DN"),
+		LDAPUserFilter:   getEnv("LDAP_USER_FILTER", "(&(objectClass=person)(|(uid={login})(mail={login})))"),
+		LDAPIDAttr:       getEnv("LDAP_ID_ATTR", "entryUUID"),
+		LDAPEmailAttr:    getEnv("LDAP_EMAIL_ATTR", "mail"),
+		LDAPNameAttr:     getEnv("LDAP_NAME_ATTR", "displayName"),
+		LDAPGroupAttr:    getEnv("LDAP_GROUP_ATTR", "memberOf"),
+		LDAPGroupBaseDN:  os.Getenv("LDAP_GROUP_BASE_DN"),
+		LDAPGroupFilter:  os.Getenv("LDAP_GROUP_FILTER"),
+		// DNs contain commas
+		LDAPAdminGroups: splitList(os.Getenv("LDAP_ADMIN_GROUPS"), ";"),
+
+		SCIMToken: os.Getenv("SCIM_TOKEN"),
+	}
+
+	var err error
+	cfg.AccessTokenTTL, err = time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil || cfg.AccessTokenTTL <= 0 {
+		return nil, fmt.Errorf("invalid ACCESS_TOKEN_TTL: %q", os.Getenv("ACCESS_TOKEN_TTL"))
+	}
+
+	cfg.RefreshTokenTTL, err = time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil || cfg.RefreshTokenTTL <= 0 {
+		return nil, fmt.Errorf("invalid REFRESH_TOKEN_TTL: %q", os.Getenv("REFRESH_TOKEN_TTL"))
+	}
+
+	days, err := strconv.Atoi(getEnv("TRASH_RETENTION_DAYS", "30"))
+	if err != nil || days < 0 {
+		return nil, fmt.Errorf("invalid TRASH_RETENTION_DAYS: %q", os.Getenv("TRASH_RETENTION_DAYS"))
+	}
+	cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+
+	switch alg := getEnv("JWT_ALG", "EdDSA"); strings.ToUpper(alg) {
+	case "EDDSA":
+		cfg.JWTAlgorithm = "EdDSA"
+	case "RS256", "HS256":
+		cfg.JWTAlgorithm = strings.ToUpper(alg)
+	default:
+		return nil, fmt.Errorf("invalid JWT_ALG: %q (EdDSA, RS256 or HS256)", alg)
+	}
+
+	cfg.JWTKeyRotation, err = time.ParseDuration(getEnv("JWT_KEY_ROTATION", "720h"))
+	if err != nil || (cfg.JWTKeyRotation != 0 && cfg.JWTKeyRotation < time.Hour) {
+		return nil, fmt.Errorf("invalid JWT_KEY_ROTATION: %q (0 or at least 1h)", os.Getenv("JWT_KEY_ROTATION"))
+	}
+
+	if cfg.JWTAlgorithm == "HS256" {
+		if cfg.JWTSecret == "" && cfg.DevMode {
+			cfg.JWTSecret = DevJWTSecret
+		}
+		if !cfg.DevMode && (len(cfg.JWTSecret) < 32 || slices.Contains(placeholderJWTSecrets, cfg.JWTSecret)) {
+			return nil, fmt.Errorf("JWT_SECRET must be a random string of at least 32 characters; the default is only allowed with APP_ENV=development")
+		}
+	}
+
+	cfg.TrashPurgeInterval, err = time.ParseDuration(getEnv("TRASH_PURGE_INTERVAL", "1h"))
+	if err != nil || cfg.TrashPurgeInterval <= 0 {
+		return nil, fmt.Errorf("invalid TRASH_PURGE_INTERVAL: %q", os.Getenv("TRASH_PURGE_INTERVAL"))
 	}
+
+	cfg.MigrateLockTimeout, err = time.ParseDuration(getEnv("MIGRATE_LOCK_TIMEOUT", "1m"))
+	if err != nil || cfg.MigrateLockTimeout <= 0 {
+		return nil, fmt.Errorf("invalid MIGRATE_LOCK_TIMEOUT: %q", os.Getenv("MIGRATE_LOCK_TIMEOUT"))
+	}
+
+	cfg.LoginMaxFailures, err = strconv.Atoi(getEnv("LOGIN_MAX_FAILURES", "5"))
+	if err != nil || cfg.LoginMaxFailures < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_MAX_FAILURES: %q", os.Getenv("LOGIN_MAX_FAILURES"))
+	}
+
+	cfg.LoginIPMaxFailures, err = strconv.Atoi(getEnv("LOGIN_IP_MAX_FAILURES", "20"))
+	if err != nil || cfg.LoginIPMaxFailures < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_IP_MAX_FAILURES: %q", os.Getenv("LOGIN_IP_MAX_FAILURES"))
+	}
+
+	cfg.LoginLockout, err = time.ParseDuration(getEnv("LOGIN_LOCKOUT", "15m"))
+	if err != nil || cfg.LoginLockout <= 0 {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT: %q", os.Getenv("LOGIN_LOCKOUT"))
+	}
+
+	cfg.LoginDelay, err = time.ParseDuration(getEnv("LOGIN_DELAY", "1s"))
+	if err != nil || cfg.LoginDelay < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_DELAY: %q", os.Getenv("LOGIN_DELAY"))
+	}
+
+	// bcrypt ignores everything past 72 bytes
+	cfg.PasswordMinLength, err = strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "10"))
+	if err != nil || cfg.PasswordMinLength < 1 || cfg.PasswordMinLength > 72 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH: %q", os.Getenv("PASSWORD_MIN_LENGTH"))
+	}
+
+	cfg.PasswordMinClasses, err = strconv.Atoi(getEnv("PASSWORD_MIN_CLASSES", "3"))
+	if err != nil || cfg.PasswordMinClasses < 1 || cfg.PasswordMinClasses > 4 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_CLASSES: %q", os.Getenv("PASSWORD_MIN_CLASSES"))
+	}
+
+	cfg.PasswordHistory, err = strconv.Atoi(getEnv("PASSWORD_HISTORY", "5"))
+	if err != nil || cfg.PasswordHistory < 0 {
+		return nil, fmt.Errorf("invalid PASSWORD_HISTORY: %q", os.Getenv("PASSWORD_HISTORY"))
+	}
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID must be set when OIDC_ISSUER is")
+	}
+
+	cfg.OIDCAutoCreate, err = strconv.ParseBool(getEnv("OIDC_AUTO_CREATE", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_AUTO_CREATE: %q", os.Getenv("OIDC_AUTO_CREATE"))
+	}
+
+	cfg.LDAPStartTLS, err = strconv.ParseBool(getEnv("LDAP_START_TLS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP_START_TLS: %q", os.Getenv("LDAP_START_TLS"))
+	}
+
+	if cfg.LDAPURL != "" {
+		switch {
+		case !strings.HasPrefix(cfg.LDAPURL, "ldap://") && !strings.HasPrefix(cfg.LDAPURL, "ldaps://"):
+			return nil, fmt.Errorf("invalid LDAP_URL: %q", cfg.LDAPURL)
+		case cfg.LDAPStartTLS && strings.HasPrefix(cfg.LDAPURL, "ldaps://"):
+			return nil, fmt.Errorf("LDAP_START_TLS is for ldap:// URLs; ldaps:// is encrypted already")
+		case cfg.LDAPBaseDN == "":
+			return nil, fmt.Errorf("LDAP_BASE_DN must be set when LDAP_URL is")
+		case !strings.Contains(cfg.LDAPUserFilter, "{login}"):
+			return nil, fmt.Errorf("LDAP_USER_FILTER must contain {login}")
+		}
+	}
+
+	if cfg.SCIMToken != "" && len(cfg.SCIMToken) < 32 {
+		return nil, fmt.Errorf("SCIM_TOKEN must be at least 32 characters")
+	}
+
 	return cfg, nil
 }
 
+// splitList splits a list separated by sep, dropping empty items.
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,204 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is one link in a chain of rotating refresh tokens. Each refresh
+// marks the presented token used and issues a successor with the same
+// FamilyID; a used or revoked token coming back means the chain leaked.
+type RefreshToken struct {
+	ID           uuid.UUID  `db:"id"`
+	UserID       uuid.UUID  `db:"user_id"`
+	FamilyID     uuid.UUID  `db:"family_id"`
+	TokenHash    string     `db:"token_hash"`    // hex SHA-256 of the token; the token itself is never stored
+	TokenVersion int        `db:"token_version"` // User.TokenVersion when the family was started
+	ExpiresAt    time.Time  `db:"expires_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UsedAt       *time.Time `db:"used_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID uuid.UUID
+	Role   Role
+	// Scopes limits what a personal access token may do; nil for sessions.
+	Scopes []TokenScope
+	// TwoFactorSetupRequired is set for sessions and tokens of users whose
+	// role requires two-factor authentication but who have not enrolled yet.
+	// They may only enroll, with a session, until they do.
+	TwoFactorSetupRequired bool
+}
+
+// HasScope reports whether the principal may act with the given scope.
+// Session logins carry no scope list and may do anything their role allows.
+func (p *Principal) HasScope(scope TokenScope) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------- Personal access tokens ----------
+
+// APITokenPrefix starts every personal access token, which tells them apart
+// from session JWTs and makes leaked tokens easy to search for.
+const APITokenPrefix = "dmv_pat_"
+
+type TokenScope string
+
+const (
+	ScopeRead  TokenScope = "read"  // GET requests outside /api/admin
+	ScopeWrite TokenScope = "write" // every other request outside /api/admin
+	ScopeAdmin TokenScope = "admin" // /api/admin, for ADMIN users only
+)
+
+func (s TokenScope) Valid() bool {
+	switch s {
+	case ScopeRead, ScopeWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// TokenScopes is stored as a comma-separated list and serialized to JSON as
+// an array.
+type TokenScopes []TokenScope
+
+func (s TokenScopes) Value() (driver.Value, error) {
+	parts := make([]string, len(s))
+	for i, scope := range s {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func (s *TokenScopes) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into TokenScopes", src)
+	}
+	*s = TokenScopes{}
+	for _, part := range strings.Split(raw, ",") {
+		if part != "" {
+			*s = append(*s, TokenScope(part))
+		}
+	}
+	return nil
+}
+
+// APIToken is a personal access token a user created for scripts and CI.
+// It acts as its owner, limited to its scopes.
+type APIToken struct {
+	ID          uuid.UUID   `db:"id" json:"id"`
+	UserID      uuid.UUID   `db:"user_id" json:"user_id"`
+	Name        string      `db:"name" json:"name"`
+	TokenHash   string      `db:"token_hash" json:"-"`              // hex SHA-256 of the token
+	TokenPrefix string      `db:"token_prefix" json:"token_prefix"` // first characters, to recognise the token by
+	Scopes      TokenScopes `db:"scopes" json:"scopes"`
+	ExpiresAt   time.Time   `db:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time   `db:"created_at" json:"created_at"`
+	LastUsedAt  *time.Time  `db:"last_used_at" json:"last_used_at"`
+	RevokedAt   *time.Time  `db:"revoked_at" json:"-"`
+}
+
+// ---------- Login throttling ----------
+
+// LoginThrottle counts the recent failed logins for one throttle key: an
+// account ("account:" + lower-cased email) or a client address ("ip:" +
+// address). Failures older than the lockout period are forgotten.
+type LoginThrottle struct {
+	Key           string    `db:"throttle_key" json:"key"`
+	Failures      int       `db:"failures" json:"failures"`
+	LastFailureAt time.Time `db:"last_failure_at" json:"last_failure_at"`
+}
+
+// ---------- Single sign-on ----------
+
+// UserIdentity links a user to their account at an external identity
+// provider. A user has at most one identity per provider.
+type UserIdentity struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	Provider  string    `db:"provider" json:"provider"` // OIDC issuer URL, "ldap" for the directory or "scim" for SCIM externalIds
+	Subject   string    `db:"subject" json:"subject"`   // the provider's stable id for the user
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// OIDCLogin is an OpenID Connect login between the redirect to the identity
+// provider and its callback, found again by its state.
+type OIDCLogin struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`         // must come back in the ID token
+	CodeVerifier string    `db:"code_verifier"` // PKCE secret for exchanging the code
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// ---------- Signing keys ----------
+
+// SigningKey is a key access tokens are signed with. It signs from
+// ActivatesAt until the next key activates, and verifies until the tokens
+// it signed have expired.
+type SigningKey struct {
+	ID          string    `db:"id"`          // kid header of the tokens it signs
+	Algorithm   string    `db:"algorithm"`   // JWS alg: EdDSA or RS256
+	PrivateKey  string    `db:"private_key"` // PKCS #8, PEM encoded
+	ActivatesAt time.Time `db:"activates_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// ---------- Groups ----------
+
+// Group is a named set of users, created in the app or provisioned through
+// SCIM. Documents and flows can be shared with a group as a whole.
+type Group struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	Name       string    `db:"name" json:"name"`
+	ExternalID string    `db:"external_id" json:"external_id,omitempty"` // the provisioning client's id for the group
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// GroupRole is a member's role within a group. Owners and admins manage
+// the group's members; only owners rename or delete it and manage owners.
+type GroupRole string
+
+const (
+	GroupRoleMember GroupRole = "MEMBER"
+	GroupRoleAdmin  GroupRole = "ADMIN"
+	GroupRoleOwner  GroupRole = "OWNER"
+)
+
+func (r GroupRole) Valid() bool {
+	switch r {
+	case GroupRoleMember, GroupRoleAdmin, GroupRoleOwner:
+		return true
+	}
+	return false
+}
+
+// GroupMember makes a user a member of a group.
+type GroupMember struct {
+	GroupID   uuid.UUID `db:"group_id" json:"group_id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	Role      GroupRole `db:"role" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
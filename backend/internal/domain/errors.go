@@ -3,6 +3,7 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Sentinel errors for business-level failures.
@@ -12,6 +13,14 @@ var (
 	ErrUnauthorized  = errors.New("unauthorized")
 	ErrAlreadyExists = errors.New("resource already exists")
 	ErrInvalidInput  = errors.New("invalid input")
+	ErrInvalidState  = errors.New("invalid state")
+
+	// ErrPreconditionRequired is returned when an update carries no revision token.
+	ErrPreconditionRequired = errors.New("precondition required")
+	// ErrStaleRevision is returned when an update was based on an outdated revision.
+	ErrStaleRevision = errors.New("stale revision")
+	// ErrRateLimited is returned when a caller must wait before trying again.
+	ErrRateLimited = errors.New("too many requests")
 )
 
 // ValidationError carries per-field error details while still wrapping ErrInvalidInput.
@@ -31,3 +40,48 @@ func (e *ValidationError) Unwrap() error {
 func NewValidationError(fields map[string]string) *ValidationError {
 	return &ValidationError{Fields: fields}
 }
+
+// TransitionError reports an action that is not allowed from the current
+// lifecycle state. It wraps ErrInvalidState.
+type TransitionError struct {
+	From   string // current state, e.g. "EFFECTIVE"
+	Action string // attempted action, e.g. "submit_review"
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: cannot %s from %s", ErrInvalidState, e.Action, e.From)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidState
+}
+
+// StaleRevisionError reports an update based on an outdated revision. Current
+// holds the present server state so the client can merge and retry. It wraps
+// ErrStaleRevision.
+type StaleRevisionError struct {
+	Revision int         // current revision on the server
+	Current  interface{} // current server state, returned to the client
+}
+
+func (e *StaleRevisionError) Error() string {
+	return fmt.Sprintf("%v: resource is now at revision %d", ErrStaleRevision, e.Revision)
+}
+
+func (e *StaleRevisionError) Unwrap() error {
+	return ErrStaleRevision
+}
+
+// RateLimitError reports a request refused because of too many recent
+// failures, such as logins against a locked account. It wraps ErrRateLimited.
+type RateLimitError struct {
+	RetryAfter time.Duration // how long the caller must wait
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: retry in %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -43,6 +44,79 @@ func (d DurationUnit) Valid() bool {
 	return false
 }
 
+// ToDays converts a duration in this unit into working days
+// (8-hour working day, 5-day working week).
+func (d DurationUnit) ToDays(v float64) float64 {
+	switch d {
+	case DurationUnitMinute:
+		return v / (8 * 60)
+	case DurationUnitHour:
+		return v / 8
+	case DurationUnitWeek:
+		return v * 5
+	default:
+		return v
+	}
+}
+
+type FlowStatus string
+
+const (
+	FlowStatusDraft     FlowStatus = "DRAFT"
+	FlowStatusInReview  FlowStatus = "IN_REVIEW"
+	FlowStatusEffective FlowStatus = "EFFECTIVE"
+)
+
+func (s FlowStatus) Valid() bool {
+	switch s {
+	case FlowStatusDraft, FlowStatusInReview, FlowStatusEffective:
+		return true
+	}
+	return false
+}
+
+// Editable reports whether flow content may be changed in this status.
+func (s FlowStatus) Editable() bool {
+	return s == FlowStatusDraft
+}
+
+// FlowAction is a lifecycle operation on a flow.
+type FlowAction string
+
+const (
+	FlowActionSubmitReview FlowAction = "submit_review"
+	FlowActionPublish      FlowAction = "publish"
+	FlowActionReject       FlowAction = "reject"
+	FlowActionNewDraft     FlowAction = "new_draft"
+	FlowActionRestore      FlowAction = "restore"
+)
+
+// flowTransitions is the lifecycle state machine:
+//
+//	DRAFT --submit_review--> IN_REVIEW --publish--> EFFECTIVE
+//	  ^                          |                      |
+//	  +--------reject------------+                      |
+//	  +--------------------new_draft--------------------+
+//
+// restore re-publishes an older snapshot and keeps the flow EFFECTIVE.
+var flowTransitions = map[FlowAction]struct{ from, to FlowStatus }{
+	FlowActionSubmitReview: {FlowStatusDraft, FlowStatusInReview},
+	FlowActionPublish:      {FlowStatusInReview, FlowStatusEffective},
+	FlowActionReject:       {FlowStatusInReview, FlowStatusDraft},
+	FlowActionNewDraft:     {FlowStatusEffective, FlowStatusDraft},
+	FlowActionRestore:      {FlowStatusEffective, FlowStatusEffective},
+}
+
+// Transition returns the status reached by applying action, or a
+// *TransitionError if the action is not allowed from s.
+func (s FlowStatus) Transition(action FlowAction) (FlowStatus, error) {
+	t, ok := flowTransitions[action]
+	if !ok || t.from != s {
+		return s, &TransitionError{From: string(s), Action: string(action)}
+	}
+	return t.to, nil
+}
+
 // ---------- RACI ----------
 
 type RACI struct {
@@ -105,8 +179,11 @@ type WorkflowNode struct {
 	RaciJSON      string       `db:"raci_json"      json:"-"`
 	SubtasksJSON  string       `db:"subtasks_json"  json:"-"`
 	DiagramRaw    string       `db:"diagram_json"   json:"-"`
+	SortOrder     int          `db:"sort_order"     json:"sort_order"`
+	Revision      int          `db:"revision"       json:"revision"`
 	CreatedAt     time.Time    `db:"created_at"     json:"created_at"`
 	UpdatedAt     time.Time    `db:"updated_at"     json:"updated_at"`
+	DeletedAt     *time.Time   `db:"deleted_at"     json:"deleted_at,omitempty"`
 
 	// Computed fields (populated after DB read)
 	Raci        RACI        `db:"-" json:"raci"`
@@ -125,3 +202,87 @@ func (n *WorkflowNode) HydrateJSON() {
 	_ = json.Unmarshal([]byte(n.DiagramRaw), &n.DiagramJSON)
 	n.DiagramJSON.Normalize()
 }
+
+// ---------- Flow entities ----------
+
+type Flow struct {
+	ID              uuid.UUID  `db:"id"                json:"id"`
+	FlowNo          string     `db:"flow_no"           json:"flow_no"`
+	Title           string     `db:"title"             json:"title"`
+	OwnerID         uuid.UUID  `db:"owner_id"          json:"owner_id"`
+	OwnerDeptID     string     `db:"owner_dept_id"     json:"owner_dept_id"`
+	Overview        string     `db:"overview"          json:"overview"`
+	Status          FlowStatus `db:"status"            json:"status"`
+	DiagramJSON     string     `db:"diagram_json"      json:"diagram_json"`
+	LatestVersionID *uuid.UUID `db:"latest_version_id" json:"latest_version_id,omitempty"`
+	Revision        int        `db:"revision"          json:"revision"`
+	CreatedAt       time.Time  `db:"created_at"        json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"        json:"updated_at"`
+	DeletedAt       *time.Time `db:"deleted_at"        json:"deleted_at,omitempty"`
+}
+
+type FlowNode struct {
+	ID           uuid.UUID    `db:"id"            json:"id"`
+	FlowID       uuid.UUID    `db:"flow_id"       json:"flow_id"`
+	NodeNo       string       `db:"node_no"       json:"node_no"`
+	Name         string       `db:"name"          json:"name"`
+	Intro        string       `db:"intro"         json:"intro"`
+	RaciJSON     string       `db:"raci_json"     json:"raci_json"`
+	ExecForm     ExecForm     `db:"exec_form"     json:"exec_form"`
+	DurationMin  *float64     `db:"duration_min"  json:"duration_min"`
+	DurationMax  *float64     `db:"duration_max"  json:"duration_max"`
+	DurationUnit DurationUnit `db:"duration_unit" json:"duration_unit"`
+	PrereqText   string       `db:"prereq_text"   json:"prereq_text"`
+	OutputsText  string       `db:"outputs_text"  json:"outputs_text"`
+	SubtasksJSON string       `db:"subtasks_json" json:"subtasks_json"`
+	SortOrder    int          `db:"sort_order"    json:"sort_order"`
+	CreatedAt    time.Time    `db:"created_at"    json:"created_at"`
+	UpdatedAt    time.Time    `db:"updated_at"    json:"updated_at"`
+}
+
+type FlowVersion struct {
+	ID                    uuid.UUID  `db:"id"                       json:"id"`
+	FlowID                uuid.UUID  `db:"flow_id"                  json:"flow_id"`
+	SnapshotJSON          string     `db:"snapshot_json"            json:"-"`
+	CreatedBy             uuid.UUID  `db:"created_by"               json:"created_by"`
+	CreatedAt             time.Time  `db:"created_at"               json:"created_at"`
+	RestoredFromVersionID *uuid.UUID `db:"restored_from_version_id" json:"restored_from_version_id,omitempty"`
+
+	// Computed fields (populated by HydrateJSON, detail reads only)
+	Snapshot *FlowSnapshot `db:"-" json:"snapshot,omitempty"`
+}
+
+// HydrateJSON parses the stored snapshot into Snapshot.
+func (v *FlowVersion) HydrateJSON() error {
+	var snap FlowSnapshot
+	if err := json.Unmarshal([]byte(v.SnapshotJSON), &snap); err != nil {
+		return fmt.Errorf("decoding snapshot of version %s: %w", v.ID, err)
+	}
+	if snap.Nodes == nil {
+		snap.Nodes = []FlowNode{}
+	}
+	v.Snapshot = &snap
+	return nil
+}
+
+// FlowSnapshotSchema is the layout version written into new snapshots.
+// Snapshots without schema_version predate it and are read as version 0.
+const FlowSnapshotSchema = 1
+
+// FlowSnapshot is the immutable content of a published flow: the header
+// (including diagram_json), its nodes in display order and the computed totals.
+type FlowSnapshot struct {
+	SchemaVersion        int        `json:"schema_version"`
+	Flow                 Flow       `json:"flow"`
+	Nodes                []FlowNode `json:"nodes"`
+	TotalDurationMinDays float64    `json:"total_duration_min_days"`
+	TotalDurationMaxDays float64    `json:"total_duration_max_days"`
+}
+
+type FlowShare struct {
+	ID        uuid.UUID `db:"id"         json:"id"`
+	FlowID    uuid.UUID `db:"flow_id"    json:"flow_id"`
+	UserID    uuid.UUID `db:"user_id"    json:"user_id"`
+	Role      ShareRole `db:"role"       json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
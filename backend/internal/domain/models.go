@@ -31,6 +31,22 @@ const (
 	ShareRoleEdit ShareRole = "EDIT"
 )
 
+func (r ShareRole) Valid() bool {
+	switch r {
+	case ShareRoleView, ShareRoleEdit:
+		return true
+	}
+	return false
+}
+
+// ShareResource identifies which kind of object a share grants access to.
+type ShareResource string
+
+const (
+	ShareResourceDocument ShareResource = "document"
+	ShareResourceFlow     ShareResource = "flow"
+)
+
 type Role string
 
 const (
@@ -38,6 +54,30 @@ const (
 	RoleUser  Role = "USER"
 )
 
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleUser:
+		return true
+	}
+	return false
+}
+
+// Locale is the user's preferred UI language.
+type Locale string
+
+const (
+	LocaleZhCN Locale = "zh-CN"
+	LocaleEnUS Locale = "en-US"
+)
+
+func (l Locale) Valid() bool {
+	switch l {
+	case LocaleZhCN, LocaleEnUS:
+		return true
+	}
+	return false
+}
+
 // ---------- Entities ----------
 
 type User struct {
@@ -45,7 +85,18 @@ type User struct {
 	Email        string    `db:"email" json:"email"`
 	PasswordHash string    `db:"password_hash" json:"-"`
 	Role         Role      `db:"role" json:"role"`
+	DisplayName  string    `db:"display_name" json:"display_name"`
+	Department   string    `db:"department" json:"department"`
+	Locale       Locale    `db:"locale" json:"locale"`
+	Active       bool      `db:"is_active" json:"active"` // false once an admin deactivates the account
+	TokenVersion int       `db:"token_version" json:"-"`  // bumped to invalidate every token issued so far
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+
+	// TOTP two-factor authentication. The secret is set at enrollment and
+	// only counts once the user confirmed it with a code.
+	TOTPSecret       string `db:"totp_secret" json:"-"` // base32
+	TwoFactorEnabled bool   `db:"two_factor_enabled" json:"two_factor_enabled"`
+	TOTPLastStep     int64  `db:"totp_last_step" json:"-"` // time step of the last accepted code
 }
 
 type Document struct {
@@ -54,16 +105,19 @@ type Document struct {
 	Title           string     `db:"title" json:"title"`
 	Visibility      Visibility `db:"visibility" json:"visibility"`
 	LatestVersionID *uuid.UUID `db:"latest_version_id" json:"latest_version_id,omitempty"`
+	Revision        int        `db:"revision" json:"revision"`
 	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt       *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 type DocumentVersion struct {
-	ID         uuid.UUID `db:"id" json:"id"`
-	DocumentID uuid.UUID `db:"document_id" json:"document_id"`
-	Content    string    `db:"content" json:"content"`
-	CreatedBy  uuid.UUID `db:"created_by" json:"created_by"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	ID                    uuid.UUID  `db:"id" json:"id"`
+	DocumentID            uuid.UUID  `db:"document_id" json:"document_id"`
+	Content               string     `db:"content" json:"content"`
+	CreatedBy             uuid.UUID  `db:"created_by" json:"created_by"`
+	CreatedAt             time.Time  `db:"created_at" json:"created_at"`
+	RestoredFromVersionID *uuid.UUID `db:"restored_from_version_id" json:"restored_from_version_id,omitempty"`
 }
 
 type DocumentShare struct {
@@ -73,3 +127,48 @@ type DocumentShare struct {
 	Role       ShareRole `db:"role" json:"role"`
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 }
+
+// Share is a grant on a document or flow, read from document_shares or flow_shares.
+type Share struct {
+	ID         uuid.UUID     `db:"id" json:"id"`
+	Resource   ShareResource `db:"-" json:"resource"`
+	ResourceID uuid.UUID     `db:"resource_id" json:"resource_id"`
+	UserID     uuid.UUID     `db:"user_id" json:"user_id"`
+	UserEmail  string        `db:"user_email" json:"user_email"`
+	Role       ShareRole     `db:"role" json:"role"`
+	CreatedAt  time.Time     `db:"created_at" json:"created_at"`
+}
+
+// GroupShare is a grant on a document or flow to every member of a group,
+// read from document_group_shares or flow_group_shares. A member's access is
+// the strongest of their own share and the shares of their groups.
+type GroupShare struct {
+	ID         uuid.UUID     `db:"id" json:"id"`
+	Resource   ShareResource `db:"-" json:"resource"`
+	ResourceID uuid.UUID     `db:"resource_id" json:"resource_id"`
+	GroupID    uuid.UUID     `db:"group_id" json:"group_id"`
+	GroupName  string        `db:"group_name" json:"group_name"`
+	Role       ShareRole     `db:"role" json:"role"`
+	CreatedAt  time.Time     `db:"created_at" json:"created_at"`
+}
+
+// OwnershipTransfer records one change of owner on a document or flow.
+type OwnershipTransfer struct {
+	ID            uuid.UUID     `db:"id" json:"id"`
+	ResourceType  ShareResource `db:"resource_type" json:"resource_type"`
+	ResourceID    uuid.UUID     `db:"resource_id" json:"resource_id"`
+	FromUserID    uuid.UUID     `db:"from_user_id" json:"from_user_id"`
+	ToUserID      uuid.UUID     `db:"to_user_id" json:"to_user_id"`
+	TransferredBy uuid.UUID     `db:"transferred_by" json:"transferred_by"`
+	KeptEditShare bool          `db:"kept_edit_share" json:"kept_edit_share"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+}
+
+// DocumentConversion links a document to the flow created from it by the
+// docs-to-flows data migration.
+type DocumentConversion struct {
+	DocumentID  uuid.UUID `db:"document_id" json:"document_id"`
+	FlowID      uuid.UUID `db:"flow_id" json:"flow_id"`
+	VersionID   uuid.UUID `db:"version_id" json:"version_id"`
+	ConvertedAt time.Time `db:"converted_at" json:"converted_at"`
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// AccountHandler serves the caller's own account under /api/me.
+type AccountHandler struct {
+	authSvc      *service.AuthService
+	twoFactorSvc *service.TwoFactorService
+}
+
+func NewAccountHandler(authSvc *service.AuthService, twoFactorSvc *service.TwoFactorService) *AccountHandler {
+	return &AccountHandler{authSvc: authSvc, twoFactorSvc: twoFactorSvc}
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"` // TOTP code, or a recovery code where one is accepted
+}
+
+// Get handles GET /api/me
+func (h *AccountHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.authSvc.GetProfile(r.Context(), userID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, user)
+}
+
+// Update handles PUT /api/me
+func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var in service.UpdateProfileInput
+	if err := decodeJSON(r, &in); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	user, err := h.authSvc.UpdateProfile(r.Context(), userID, in)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, user)
+}
+
+// ChangePassword handles POST /api/me/password. Every other session of the
+// user ends; the response carries a new token pair for this one.
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req changePasswordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	result, err := h.authSvc.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, result)
+}
+
+// TwoFactorStatus handles GET /api/me/2fa
+func (h *AccountHandler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	status, err := h.twoFactorSvc.Status(r.Context(), userID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, status)
+}
+
+// SetupTwoFactor handles POST /api/me/2fa/setup
+func (h *AccountHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	setup, err := h.twoFactorSvc.Setup(r.Context(), userID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, setup)
+}
+
+// ConfirmTwoFactor handles POST /api/me/2fa/confirm. The response carries
+// the recovery codes, which are not shown again.
+func (h *AccountHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.withCode(w, r, h.twoFactorSvc.Confirm)
+}
+
+// RegenerateRecoveryCodes handles POST /api/me/2fa/recovery_codes
+func (h *AccountHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.withCode(w, r, h.twoFactorSvc.RegenerateRecoveryCodes)
+}
+
+// DisableTwoFactor handles POST /api/me/2fa/disable
+func (h *AccountHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.withCode(w, r, func(ctx context.Context, userID uuid.UUID, code string) (*service.RecoveryCodes, error) {
+		return nil, h.twoFactorSvc.Disable(ctx, userID, code)
+	})
+}
+
+// withCode runs a two-factor change that the caller confirms with a code.
+// A nil result is answered with a plain status.
+func (h *AccountHandler) withCode(w http.ResponseWriter, r *http.Request, fn func(context.Context, uuid.UUID, string) (*service.RecoveryCodes, error)) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req twoFactorCodeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	codes, err := fn(r.Context(), userID, req.Code)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	if codes == nil {
+		respondOK(w, map[string]string{"status": "ok"})
+		return
+	}
+	respondOK(w, codes)
+}
@@ -3,18 +3,22 @@ package handler
 import (
 	"net/http"
 
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
 	"docmv/internal/service"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 // AdminHandler handles user-management endpoints (ADMIN only).
 type AdminHandler struct {
-	authSvc *service.AuthService
+	authSvc      *service.AuthService
+	twoFactorSvc *service.TwoFactorService
 }
 
-func NewAdminHandler(authSvc *service.AuthService) *AdminHandler {
-	return &AdminHandler{authSvc: authSvc}
+func NewAdminHandler(authSvc *service.AuthService, twoFactorSvc *service.TwoFactorService) *AdminHandler {
+	return &AdminHandler{authSvc: authSvc, twoFactorSvc: twoFactorSvc}
 }
 
 // ---------- Request types ----------
@@ -29,6 +33,14 @@ type resetPasswordRequest struct {
 	Password string `json:"password"`
 }
 
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type clearLockoutRequest struct {
+	Key string `json:"key"` // as listed, e.g. "account:alice@example.com" or "ip:192.0.2.1"
+}
+
 // ---------- Handlers ----------
 
 // ListUsers handles GET /api/admin/users
@@ -79,3 +91,143 @@ func (h *AdminHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	respondOK(w, map[string]string{"status": "ok"})
 }
+
+// UpdateRole handles PUT /api/admin/users/{id}/role
+func (h *AdminHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := h.actorAndTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateRoleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	user, err := h.authSvc.UpdateRole(r.Context(), actorID, userID, req.Role)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, user)
+}
+
+// Deactivate handles POST /api/admin/users/{id}/deactivate
+func (h *AdminHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, false)
+}
+
+// Reactivate handles POST /api/admin/users/{id}/reactivate
+func (h *AdminHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, true)
+}
+
+func (h *AdminHandler) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+	actorID, userID, ok := h.actorAndTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authSvc.SetActive(r.Context(), actorID, userID, active)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, user)
+}
+
+// DeleteUser handles DELETE /api/admin/users/{id}
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := h.actorAndTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authSvc.DeleteUser(r.Context(), actorID, userID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// ListLockouts handles GET /api/admin/lockouts
+func (h *AdminHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.authSvc.ListLockouts(r.Context())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, lockouts)
+}
+
+// ClearLockout handles POST /api/admin/lockouts/clear
+func (h *AdminHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	var req clearLockoutRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.authSvc.ClearLockout(r.Context(), req.Key); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// ResetTwoFactor handles POST /api/admin/users/{id}/reset_2fa
+func (h *AdminHandler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := h.actorAndTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactorSvc.Reset(r.Context(), actorID, userID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// GetSecurity handles GET /api/admin/security
+func (h *AdminHandler) GetSecurity(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.twoFactorSvc.Settings(r.Context())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, settings)
+}
+
+// UpdateSecurity handles PUT /api/admin/security
+func (h *AdminHandler) UpdateSecurity(w http.ResponseWriter, r *http.Request) {
+	var in service.SecuritySettings
+	if err := decodeJSON(r, &in); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	settings, err := h.twoFactorSvc.UpdateSettings(r.Context(), in)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, settings)
+}
+
+// actorAndTarget returns the calling admin and the user named in the path.
+// On failure it has already written the error response.
+func (h *AdminHandler) actorAndTarget(w http.ResponseWriter, r *http.Request) (actorID, userID uuid.UUID, ok bool) {
+	actorID, ok = middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return actorID, userID, false
+	}
+	userID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return actorID, userID, false
+	}
+	return actorID, userID, true
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APITokenHandler lets users manage their personal access tokens.
+type APITokenHandler struct {
+	tokenSvc *service.APITokenService
+}
+
+func NewAPITokenHandler(tokenSvc *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{tokenSvc: tokenSvc}
+}
+
+// List handles GET /api/tokens
+func (h *APITokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	tokens, err := h.tokenSvc.List(r.Context(), userID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, tokens)
+}
+
+// Create handles POST /api/tokens. The response is the only time the token
+// itself is returned.
+func (h *APITokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var in service.CreateAPITokenInput
+	if err := decodeJSON(r, &in); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	role := domain.Role(middleware.RoleFromCtx(r.Context()))
+	token, err := h.tokenSvc.Create(r.Context(), userID, role, in)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, token)
+}
+
+// Revoke handles DELETE /api/tokens/{id}
+func (h *APITokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	tokenID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.tokenSvc.Revoke(r.Context(), userID, tokenID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"net"
 	"net/http"
 
 	"docmv/internal/domain"
@@ -20,6 +21,15 @@ type authRequest struct {
 	Password string `json:"password"`
 }
 
+type completeLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // TOTP code or recovery code
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // Register is disabled — self-registration is not allowed.
 // Kept as a handler to return a clear 403 if someone hits the old endpoint.
 func (h *AuthHandler) Register(w http.ResponseWriter, _ *http.Request) {
@@ -34,7 +44,41 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.authSvc.Login(r.Context(), req.Email, req.Password)
+	result, err := h.authSvc.Login(r.Context(), req.Email, req.Password, clientIP(r))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w, result)
+}
+
+// CompleteLogin handles POST /api/auth/login/2fa
+func (h *AuthHandler) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	var req completeLoginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	result, err := h.authSvc.CompleteLogin(r.Context(), req.ChallengeToken, req.Code, clientIP(r))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w, result)
+}
+
+// Refresh handles POST /api/auth/refresh
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	result, err := h.authSvc.Refresh(r.Context(), req.RefreshToken)
 	if err != nil {
 		respondError(w, err)
 		return
@@ -42,3 +86,42 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	respondOK(w, result)
 }
+
+// Logout handles POST /api/auth/logout
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.authSvc.Logout(r.Context(), req.RefreshToken); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// PasswordPolicy handles GET /api/auth/password_policy
+func (h *AuthHandler) PasswordPolicy(w http.ResponseWriter, _ *http.Request) {
+	respondOK(w, h.authSvc.PasswordPolicy())
+}
+
+// JWKS handles GET /.well-known/jwks.json: the public keys access tokens
+// are signed with, as a bare JWK set rather than the API envelope.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, _ *http.Request) {
+	// New keys are published well ahead of signing, so a short cache is safe.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.authSvc.JWKS())
+}
+
+// clientIP returns the address failed logins are counted against. Behind a
+// proxy, the RealIP middleware has already replaced RemoteAddr with the
+// address from X-Real-IP or X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -75,6 +75,7 @@ func (h *DocumentHandler) GetDetail(w http.ResponseWriter, r *http.Request) {
 		respondError(w, err)
 		return
 	}
+	setETag(w, detail.Document.Revision)
 	respondOK(w, detail)
 }
 
@@ -97,15 +98,41 @@ func (h *DocumentHandler) Update(w http.ResponseWriter, r *http.Request) {
 		respondError(w, err)
 		return
 	}
+	if err := applyIfMatch(r, &req.Revision); err != nil {
+		respondError(w, err)
+		return
+	}
 
 	doc, err := h.docSvc.Update(r.Context(), userID, docID, req)
 	if err != nil {
 		respondError(w, err)
 		return
 	}
+	setETag(w, doc.Revision)
 	respondOK(w, doc)
 }
 
+// Delete handles DELETE /api/docs/{id}
+func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.docSvc.Delete(r.Context(), userID, docID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
 // ListVersions handles GET /api/docs/{id}/versions
 func (h *DocumentHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromCtx(r.Context())
@@ -128,4 +155,63 @@ func (h *DocumentHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, versions)
 }
 
+// DiffVersions handles GET /api/docs/{id}/versions/{versionId}/diff/{otherVersionId}
+func (h *DocumentHandler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	fromID, err := parseUUID(chi.URLParam(r, "versionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	toID, err := parseUUID(chi.URLParam(r, "otherVersionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	diff, err := h.docSvc.DiffVersions(r.Context(), userID, docID, fromID, toID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, diff)
+}
+
+// RestoreVersion handles POST /api/docs/{id}/versions/{versionId}/restore
+func (h *DocumentHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	versionID, err := parseUUID(chi.URLParam(r, "versionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	version, err := h.docSvc.RestoreVersion(r.Context(), userID, docID, versionID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, version)
+}
+
 // parseUUID is defined in response.go
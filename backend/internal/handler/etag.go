@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"docmv/internal/domain"
+)
+
+// setETag exposes a resource revision as a strong ETag. It must be called
+// before the response body is written.
+func setETag(w http.ResponseWriter, revision int) {
+	w.Header().Set("ETag", `"`+strconv.Itoa(revision)+`"`)
+}
+
+// applyIfMatch overrides *revision with the If-Match header, if one is sent.
+// Accepted forms are "3", W/"3" and a bare 3.
+func applyIfMatch(r *http.Request, revision **int) error {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	n, err := strconv.Atoi(tag)
+	if err != nil {
+		return domain.NewValidationError(map[string]string{"If-Match": "invalid_revision"})
+	}
+	*revision = &n
+	return nil
+}
@@ -18,106 +18,313 @@ func NewFlowHandler(flowSvc *service.FlowService) *FlowHandler {
 	return &FlowHandler{flowSvc: flowSvc}
 }
 
-// ListNodes handles GET /api/docs/{id}/nodes
-func (h *FlowHandler) ListNodes(w http.ResponseWriter, r *http.Request) {
+// List handles GET /api/flows
+func (h *FlowHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromCtx(r.Context())
 	if !ok {
 		respondError(w, domain.ErrUnauthorized)
 		return
 	}
 
-	docID, err := parseUUID(chi.URLParam(r, "id"))
+	flows, err := h.flowSvc.List(r.Context(), userID)
 	if err != nil {
 		respondError(w, err)
 		return
 	}
+	respondOK(w, flows)
+}
+
+// Create handles POST /api/flows
+func (h *FlowHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req service.CreateFlowInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	flow, err := h.flowSvc.Create(r.Context(), userID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, flow)
+}
+
+// GetDetail handles GET /api/flows/{id}
+func (h *FlowHandler) GetDetail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
 
-	nodes, err := h.flowSvc.ListNodes(r.Context(), userID, docID)
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	respondOK(w, nodes)
+	detail, err := h.flowSvc.GetDetail(r.Context(), userID, flowID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	setETag(w, detail.Flow.Revision)
+	respondOK(w, detail)
 }
 
-// CreateNode handles POST /api/docs/{id}/nodes
-func (h *FlowHandler) CreateNode(w http.ResponseWriter, r *http.Request) {
+// Update handles PUT /api/flows/{id}
+func (h *FlowHandler) Update(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromCtx(r.Context())
 	if !ok {
 		respondError(w, domain.ErrUnauthorized)
 		return
 	}
 
-	docID, err := parseUUID(chi.URLParam(r, "id"))
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	var req service.NodeInput
+	var req service.UpdateFlowInput
 	if err := decodeJSON(r, &req); err != nil {
 		respondError(w, err)
 		return
 	}
+	if err := applyIfMatch(r, &req.Revision); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	flow, err := h.flowSvc.Update(r.Context(), userID, flowID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	setETag(w, flow.Revision)
+	respondOK(w, flow)
+}
+
+// Delete handles DELETE /api/flows/{id}
+func (h *FlowHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
 
-	node, err := h.flowSvc.CreateNode(r.Context(), userID, docID, req)
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	respondCreated(w, node)
+	if err := h.flowSvc.Delete(r.Context(), userID, flowID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
 }
 
-// GetNode handles GET /api/nodes/{nodeId}
-func (h *FlowHandler) GetNode(w http.ResponseWriter, r *http.Request) {
+// SubmitReview handles POST /api/flows/{id}/submit_review
+func (h *FlowHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromCtx(r.Context())
 	if !ok {
 		respondError(w, domain.ErrUnauthorized)
 		return
 	}
 
-	nodeID, err := parseUUID(chi.URLParam(r, "nodeId"))
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	flow, err := h.flowSvc.SubmitReview(r.Context(), userID, flowID)
 	if err != nil {
 		respondError(w, err)
 		return
 	}
+	respondOK(w, flow)
+}
+
+// Publish handles POST /api/flows/{id}/publish
+func (h *FlowHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
 
-	node, err := h.flowSvc.GetNode(r.Context(), userID, nodeID)
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	respondOK(w, node)
+	flow, err := h.flowSvc.Publish(r.Context(), userID, flowID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, flow)
 }
 
-// UpdateNode handles PUT /api/nodes/{nodeId}
-func (h *FlowHandler) UpdateNode(w http.ResponseWriter, r *http.Request) {
+// Reject handles POST /api/flows/{id}/reject
+func (h *FlowHandler) Reject(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromCtx(r.Context())
 	if !ok {
 		respondError(w, domain.ErrUnauthorized)
 		return
 	}
 
-	nodeID, err := parseUUID(chi.URLParam(r, "nodeId"))
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	var req service.NodeInput
-	if err := decodeJSON(r, &req); err != nil {
+	flow, err := h.flowSvc.Reject(r.Context(), userID, flowID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, flow)
+}
+
+// NewDraft handles POST /api/flows/{id}/new_draft
+func (h *FlowHandler) NewDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	node, err := h.flowSvc.UpdateNode(r.Context(), userID, nodeID, req)
+	flow, err := h.flowSvc.NewDraft(r.Context(), userID, flowID)
 	if err != nil {
 		respondError(w, err)
 		return
 	}
+	respondOK(w, flow)
+}
 
-	respondOK(w, node)
+// ListVersions handles GET /api/flows/{id}/versions
+func (h *FlowHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	versions, err := h.flowSvc.ListVersions(r.Context(), userID, flowID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, versions)
+}
+
+// GetVersion handles GET /api/flows/{id}/versions/{versionId}
+func (h *FlowHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	versionID, err := parseUUID(chi.URLParam(r, "versionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	version, err := h.flowSvc.GetVersion(r.Context(), userID, flowID, versionID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, version)
+}
+
+// DiffVersions handles GET /api/flows/{id}/versions/{versionId}/diff/{otherVersionId}
+func (h *FlowHandler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	fromID, err := parseUUID(chi.URLParam(r, "versionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	toID, err := parseUUID(chi.URLParam(r, "otherVersionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	diff, err := h.flowSvc.DiffVersions(r.Context(), userID, flowID, fromID, toID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, diff)
+}
+
+// RestoreVersion handles POST /api/flows/{id}/versions/{versionId}/restore
+func (h *FlowHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	versionID, err := parseUUID(chi.URLParam(r, "versionId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	flow, err := h.flowSvc.RestoreVersion(r.Context(), userID, flowID, versionID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, flow)
 }
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GroupHandler serves groups and their members. ADMINs manage every group;
+// other users manage the groups they own or administer.
+type GroupHandler struct {
+	groupSvc *service.GroupService
+}
+
+func NewGroupHandler(groupSvc *service.GroupService) *GroupHandler {
+	return &GroupHandler{groupSvc: groupSvc}
+}
+
+// List handles GET /api/groups
+func (h *GroupHandler) List(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.groupSvc.List(r.Context())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, groups)
+}
+
+// Get handles GET /api/groups/{id}
+func (h *GroupHandler) Get(w http.ResponseWriter, r *http.Request) {
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	detail, err := h.groupSvc.Get(r.Context(), groupID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, detail)
+}
+
+// Create handles POST /api/groups
+func (h *GroupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req service.GroupInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	group, err := h.groupSvc.Create(r.Context(), userID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, group)
+}
+
+// Update handles PUT /api/groups/{id}
+func (h *GroupHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.GroupInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	group, err := h.groupSvc.Update(r.Context(), userID, isAdmin, groupID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, group)
+}
+
+// Delete handles DELETE /api/groups/{id}
+func (h *GroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.groupSvc.Delete(r.Context(), userID, isAdmin, groupID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// AddMember handles POST /api/groups/{id}/members
+func (h *GroupHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.AddGroupMemberInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	member, err := h.groupSvc.AddMember(r.Context(), userID, isAdmin, groupID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, member)
+}
+
+// UpdateMember handles PUT /api/groups/{id}/members/{userId}
+func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	memberID, err := parseUUID(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.UpdateGroupMemberInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	member, err := h.groupSvc.UpdateMember(r.Context(), userID, isAdmin, groupID, memberID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, member)
+}
+
+// RemoveMember handles DELETE /api/groups/{id}/members/{userId}
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	groupID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	memberID, err := parseUUID(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.groupSvc.RemoveMember(r.Context(), userID, isAdmin, groupID, memberID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
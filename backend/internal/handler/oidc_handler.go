@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+// oidcStateCookie carries the state of a single sign-on login from its start
+// to its callback, so a callback only completes the login of the browser
+// that started it.
+const oidcStateCookie = "docmv_oidc_state"
+
+type OIDCHandler struct {
+	oidcSvc *service.OIDCService
+}
+
+func NewOIDCHandler(oidcSvc *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{oidcSvc: oidcSvc}
+}
+
+type oidcCallbackRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+// Info handles GET /api/auth/oidc
+func (h *OIDCHandler) Info(w http.ResponseWriter, _ *http.Request) {
+	respondOK(w, h.oidcSvc.Info())
+}
+
+// Login handles GET /api/auth/oidc/login. The browser navigates here and is
+// redirected to the identity provider.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	start, err := h.oidcSvc.Start(r.Context())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	http.SetCookie(w, stateCookie(r, start.State, 600)) // as long as the login may take
+	http.Redirect(w, r, start.URL, http.StatusFound)
+}
+
+// Callback handles POST /api/auth/oidc/callback. The login page posts the
+// code and state the identity provider redirected back with, and gets what
+// POST /api/auth/login returns.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	var req oidcCallbackRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.State)) != 1 {
+		respondError(w, fmt.Errorf("%w: the login was started in another browser, please start again", domain.ErrUnauthorized))
+		return
+	}
+	http.SetCookie(w, stateCookie(r, "", -1))
+
+	result, err := h.oidcSvc.Finish(r.Context(), req.State, req.Code)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w, result)
+}
+
+func stateCookie(r *http.Request, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type OwnershipHandler struct {
+	ownershipSvc *service.OwnershipService
+	resource     domain.ShareResource
+}
+
+// NewOwnershipHandler returns a handler for one resource kind. BulkTransfer
+// does not depend on the resource kind.
+func NewOwnershipHandler(ownershipSvc *service.OwnershipService, resource domain.ShareResource) *OwnershipHandler {
+	return &OwnershipHandler{ownershipSvc: ownershipSvc, resource: resource}
+}
+
+// Transfer handles POST /api/{docs|flows}/{id}/transfer
+func (h *OwnershipHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+	isAdmin := middleware.RoleFromCtx(r.Context()) == string(domain.RoleAdmin)
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.TransferInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	transfer, err := h.ownershipSvc.Transfer(r.Context(), userID, isAdmin, h.resource, resourceID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, transfer)
+}
+
+// ListTransfers handles GET /api/{docs|flows}/{id}/transfers
+func (h *OwnershipHandler) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	transfers, err := h.ownershipSvc.ListTransfers(r.Context(), userID, h.resource, resourceID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, transfers)
+}
+
+// BulkTransfer handles POST /api/admin/users/{id}/transfer_ownership
+func (h *OwnershipHandler) BulkTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	fromUserID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.BulkTransferInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	result, err := h.ownershipSvc.BulkTransfer(r.Context(), userID, fromUserID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, result)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"docmv/internal/domain"
 
@@ -59,7 +61,22 @@ func respondError(w http.ResponseWriter, err error) {
 		log.Printf("[%s] validation failed: %v", reqID, ve.Fields)
 	}
 
+	// A stale update returns the current server state alongside the error
+	var data interface{}
+	var se *domain.StaleRevisionError
+	if errors.As(err, &se) {
+		data = se.Current
+		setETag(w, se.Revision)
+	}
+
+	// A refused login tells the client when to try again
+	var rl *domain.RateLimitError
+	if errors.As(err, &rl) {
+		w.Header().Set("Retry-After", strconv.Itoa(int((rl.RetryAfter+time.Second-1)/time.Second)))
+	}
+
 	writeJSON(w, status, APIResponse{
+		Data:      data,
 		Error:     apiErr,
 		RequestID: reqID,
 	})
@@ -75,8 +92,16 @@ func mapError(err error) (code string, status int) {
 		return "UNAUTHORIZED", http.StatusUnauthorized
 	case errors.Is(err, domain.ErrAlreadyExists):
 		return "CONFLICT", http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidState):
+		return "INVALID_STATE", http.StatusConflict
+	case errors.Is(err, domain.ErrStaleRevision):
+		return "PRECONDITION_FAILED", http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrPreconditionRequired):
+		return "PRECONDITION_REQUIRED", http.StatusPreconditionRequired
 	case errors.Is(err, domain.ErrInvalidInput):
 		return "BAD_REQUEST", http.StatusBadRequest
+	case errors.Is(err, domain.ErrRateLimited):
+		return "TOO_MANY_REQUESTS", http.StatusTooManyRequests
 	default:
 		return "INTERNAL_ERROR", http.StatusInternalServerError
 	}
@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 
 	"docmv/internal/config"
+	"docmv/internal/domain"
 	mw "docmv/internal/middleware"
 	"docmv/internal/service"
 
@@ -17,7 +18,7 @@ import (
 )
 
 // NewRouter builds the HTTP router with all routes and middleware.
-func NewRouter(cfg *config.Config, authSvc *service.AuthService, docSvc *service.DocumentService, flowSvc *service.FlowService) http.Handler {
+func NewRouter(cfg *config.Config, authSvc *service.AuthService, twoFactorSvc *service.TwoFactorService, oidcSvc *service.OIDCService, scimSvc *service.SCIMService, apiTokenSvc *service.APITokenService, docSvc *service.DocumentService, nodeSvc *service.WorkflowNodeService, flowSvc *service.FlowService, shareSvc *service.ShareService, groupSvc *service.GroupService, ownershipSvc *service.OwnershipService, trashSvc *service.TrashService) http.Handler {
 	r := chi.NewRouter()
 
 	// ---------- Global middleware ----------
@@ -27,53 +28,207 @@ func NewRouter(cfg *config.Config, authSvc *service.AuthService, docSvc *service
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "http://127.0.0.1:3000"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match"},
+		ExposedHeaders:   []string{"X-Request-ID", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	authH := NewAuthHandler(authSvc)
+	oidcH := NewOIDCHandler(oidcSvc)
+	scimH := NewSCIMHandler(scimSvc)
 	docH := NewDocumentHandler(docSvc)
-	adminH := NewAdminHandler(authSvc)
+	adminH := NewAdminHandler(authSvc, twoFactorSvc)
+	nodeH := NewWorkflowNodeHandler(nodeSvc)
 	flowH := NewFlowHandler(flowSvc)
+	docShareH := NewShareHandler(shareSvc, domain.ShareResourceDocument)
+	flowShareH := NewShareHandler(shareSvc, domain.ShareResourceFlow)
+	groupH := NewGroupHandler(groupSvc)
+	docOwnerH := NewOwnershipHandler(ownershipSvc, domain.ShareResourceDocument)
+	flowOwnerH := NewOwnershipHandler(ownershipSvc, domain.ShareResourceFlow)
+	trashH := NewTrashHandler(trashSvc)
+	apiTokenH := NewAPITokenHandler(apiTokenSvc)
+	accountH := NewAccountHandler(authSvc, twoFactorSvc)
 
 	// ---------- Public routes ----------
+	r.Get("/.well-known/jwks.json", authH.JWKS)
+
 	r.Route("/api/auth", func(r chi.Router) {
 		r.Post("/login", authH.Login)
+		r.Post("/login/2fa", authH.CompleteLogin)
+		r.Post("/refresh", authH.Refresh)
+		r.Post("/logout", authH.Logout)
+		r.Get("/password_policy", authH.PasswordPolicy)
+		// OpenID Connect single sign-on
+		r.Get("/oidc", oidcH.Info)
+		r.Get("/oidc/login", oidcH.Login)
+		r.Post("/oidc/callback", oidcH.Callback)
 		// Self-registration disabled: return 403 if hit
 		r.Post("/register", authH.Register)
 	})
 
+	// ---------- SCIM provisioning ----------
+	// Authenticated by the provisioning client's own bearer token
+	r.Route("/scim/v2", func(r chi.Router) {
+		r.Use(scimH.Authenticate)
+		r.Get("/ServiceProviderConfig", scimH.ServiceProviderConfig)
+		r.Get("/ResourceTypes", scimH.ResourceTypes)
+		r.Get("/Users", scimH.ListUsers)
+		r.Post("/Users", scimH.CreateUser)
+		r.Get("/Users/{id}", scimH.GetUser)
+		r.Put("/Users/{id}", scimH.ReplaceUser)
+		r.Patch("/Users/{id}", scimH.PatchUser)
+		r.Delete("/Users/{id}", scimH.DeleteUser)
+		r.Get("/Groups", scimH.ListGroups)
+		r.Post("/Groups", scimH.CreateGroup)
+		r.Get("/Groups/{id}", scimH.GetGroup)
+		r.Put("/Groups/{id}", scimH.ReplaceGroup)
+		r.Patch("/Groups/{id}", scimH.PatchGroup)
+		r.Delete("/Groups/{id}", scimH.DeleteGroup)
+	})
+
 	// ---------- Protected routes ----------
 	r.Group(func(r chi.Router) {
-		r.Use(mw.Auth(cfg.JWTSecret))
-
-		// Document routes
-		r.Route("/api/docs", func(r chi.Router) {
-			r.Get("/", docH.List)
-			r.Post("/", docH.Create)
-			r.Get("/{id}", docH.GetDetail)
-			r.Put("/{id}", docH.Update)
-			r.Get("/{id}/versions", docH.ListVersions)
-
-			// Workflow node routes (nested under document)
-			r.Get("/{id}/nodes", flowH.ListNodes)
-			r.Post("/{id}/nodes", flowH.CreateNode)
-		})
+		// Sessions and personal access tokens are both accepted. A token needs
+		// the read scope for GET and the write scope for anything else, and
+		// the admin scope under /api/admin; /api/tokens is for sessions only,
+		// so a leaked token cannot mint more tokens.
+		r.Use(mw.Auth(authSvc, apiTokenSvc))
 
-		// Workflow node routes (by node ID)
-		r.Route("/api/nodes", func(r chi.Router) {
-			r.Get("/{nodeId}", flowH.GetNode)
-			r.Put("/{nodeId}", flowH.UpdateNode)
+		// The caller's own account; changing the password and two-factor
+		// authentication need a session
+		r.Route("/api/me", func(r chi.Router) {
+			r.Use(mw.RequireMethodScope)
+			r.Get("/", accountH.Get)
+			r.Put("/", accountH.Update)
+			r.With(mw.RequireSession).Post("/password", accountH.ChangePassword)
+			r.Route("/2fa", func(r chi.Router) {
+				r.Use(mw.RequireSession)
+				r.Get("/", accountH.TwoFactorStatus)
+				r.Post("/setup", accountH.SetupTwoFactor)
+				r.Post("/confirm", accountH.ConfirmTwoFactor)
+				r.Post("/disable", accountH.DisableTwoFactor)
+				r.Post("/recovery_codes", accountH.RegenerateRecoveryCodes)
+			})
 		})
 
-		// Admin routes (ADMIN role required)
-		r.Route("/api/admin", func(r chi.Router) {
-			r.Use(mw.RequireAdmin)
-			r.Get("/users", adminH.ListUsers)
-			r.Post("/users", adminH.CreateUser)
-			r.Post("/users/{id}/reset_password", adminH.ResetPassword)
+		// Everything else waits until users who must enroll in two-factor
+		// authentication have done so
+		r.Group(func(r chi.Router) {
+			r.Use(mw.RequireTwoFactorEnrollment)
+
+			// Document routes
+			r.Route("/api/docs", func(r chi.Router) {
+				r.Use(mw.RequireMethodScope)
+				r.Get("/", docH.List)
+				r.Post("/", docH.Create)
+				r.Get("/{id}", docH.GetDetail)
+				r.Put("/{id}", docH.Update)
+				r.Delete("/{id}", docH.Delete)
+				r.Get("/{id}/versions", docH.ListVersions)
+				r.Get("/{id}/versions/{versionId}/diff/{otherVersionId}", docH.DiffVersions)
+				r.Post("/{id}/versions/{versionId}/restore", docH.RestoreVersion)
+				r.Get("/{id}/shares", docShareH.List)
+				r.Post("/{id}/shares", docShareH.Create)
+				r.Put("/{id}/shares/{shareId}", docShareH.Update)
+				r.Delete("/{id}/shares/{shareId}", docShareH.Delete)
+				r.Get("/{id}/group_shares", docShareH.ListGroupShares)
+				r.Post("/{id}/group_shares", docShareH.CreateGroupShare)
+				r.Put("/{id}/group_shares/{shareId}", docShareH.UpdateGroupShare)
+				r.Delete("/{id}/group_shares/{shareId}", docShareH.DeleteGroupShare)
+				r.Post("/{id}/transfer", docOwnerH.Transfer)
+				r.Get("/{id}/transfers", docOwnerH.ListTransfers)
+
+				// Workflow node routes (nested under document)
+				r.Get("/{id}/nodes", nodeH.ListNodes)
+				r.Post("/{id}/nodes", nodeH.CreateNode)
+				r.Put("/{id}/nodes/order", nodeH.ReorderNodes)
+			})
+
+			// Workflow node routes (by node ID)
+			r.Route("/api/nodes", func(r chi.Router) {
+				r.Use(mw.RequireMethodScope)
+				r.Get("/{nodeId}", nodeH.GetNode)
+				r.Put("/{nodeId}", nodeH.UpdateNode)
+				r.Delete("/{nodeId}", nodeH.DeleteNode)
+			})
+
+			// Flow routes
+			r.Route("/api/flows", func(r chi.Router) {
+				r.Use(mw.RequireMethodScope)
+				r.Get("/", flowH.List)
+				r.Post("/", flowH.Create)
+				r.Get("/{id}", flowH.GetDetail)
+				r.Put("/{id}", flowH.Update)
+				r.Delete("/{id}", flowH.Delete)
+				r.Post("/{id}/submit_review", flowH.SubmitReview)
+				r.Post("/{id}/publish", flowH.Publish)
+				r.Post("/{id}/reject", flowH.Reject)
+				r.Post("/{id}/new_draft", flowH.NewDraft)
+				r.Get("/{id}/versions", flowH.ListVersions)
+				r.Get("/{id}/versions/{versionId}", flowH.GetVersion)
+				r.Get("/{id}/versions/{versionId}/diff/{otherVersionId}", flowH.DiffVersions)
+				r.Post("/{id}/versions/{versionId}/restore", flowH.RestoreVersion)
+				r.Get("/{id}/shares", flowShareH.List)
+				r.Post("/{id}/shares", flowShareH.Create)
+				r.Put("/{id}/shares/{shareId}", flowShareH.Update)
+				r.Delete("/{id}/shares/{shareId}", flowShareH.Delete)
+				r.Get("/{id}/group_shares", flowShareH.ListGroupShares)
+				r.Post("/{id}/group_shares", flowShareH.CreateGroupShare)
+				r.Put("/{id}/group_shares/{shareId}", flowShareH.UpdateGroupShare)
+				r.Delete("/{id}/group_shares/{shareId}", flowShareH.DeleteGroupShare)
+				r.Post("/{id}/transfer", flowOwnerH.Transfer)
+				r.Get("/{id}/transfers", flowOwnerH.ListTransfers)
+			})
+
+			// Group routes; any user may create a group and share with one
+			r.Route("/api/groups", func(r chi.Router) {
+				r.Use(mw.RequireMethodScope)
+				r.Get("/", groupH.List)
+				r.Post("/", groupH.Create)
+				r.Get("/{id}", groupH.Get)
+				r.Put("/{id}", groupH.Update)
+				r.Delete("/{id}", groupH.Delete)
+				r.Post("/{id}/members", groupH.AddMember)
+				r.Put("/{id}/members/{userId}", groupH.UpdateMember)
+				r.Delete("/{id}/members/{userId}", groupH.RemoveMember)
+			})
+
+			// Trash routes (soft-deleted items of the current user)
+			r.Route("/api/trash", func(r chi.Router) {
+				r.Use(mw.RequireMethodScope)
+				r.Get("/", trashH.List)
+				r.Post("/documents/{id}/restore", trashH.RestoreDocument)
+				r.Post("/flows/{id}/restore", trashH.RestoreFlow)
+				r.Post("/nodes/{id}/restore", trashH.RestoreNode)
+			})
+
+			// Personal access token management (session only)
+			r.Route("/api/tokens", func(r chi.Router) {
+				r.Use(mw.RequireSession)
+				r.Get("/", apiTokenH.List)
+				r.Post("/", apiTokenH.Create)
+				r.Delete("/{id}", apiTokenH.Revoke)
+			})
+
+			// Admin routes (ADMIN role required)
+			r.Route("/api/admin", func(r chi.Router) {
+				r.Use(mw.RequireAdmin)
+				r.Use(mw.RequireScope(domain.ScopeAdmin))
+				r.Get("/users", adminH.ListUsers)
+				r.Post("/users", adminH.CreateUser)
+				r.Delete("/users/{id}", adminH.DeleteUser)
+				r.Put("/users/{id}/role", adminH.UpdateRole)
+				r.Post("/users/{id}/deactivate", adminH.Deactivate)
+				r.Post("/users/{id}/reactivate", adminH.Reactivate)
+				r.Post("/users/{id}/reset_password", adminH.ResetPassword)
+				r.Post("/users/{id}/transfer_ownership", docOwnerH.BulkTransfer)
+				r.Post("/users/{id}/reset_2fa", adminH.ResetTwoFactor)
+				r.Get("/lockouts", adminH.ListLockouts)
+				r.Post("/lockouts/clear", adminH.ClearLockout)
+				r.Get("/security", adminH.GetSecurity)
+				r.Put("/security", adminH.UpdateSecurity)
+			})
 		})
 	})
 
@@ -0,0 +1,331 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docmv/internal/domain"
+	"docmv/internal/scim"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// scimContentType is the media type of SCIM requests and responses.
+const scimContentType = "application/scim+json"
+
+// SCIMHandler serves the SCIM 2.0 API under /scim/v2. It speaks SCIM rather
+// than the /api envelope: resources are returned as they are, and errors in
+// the SCIM error format.
+type SCIMHandler struct {
+	scimSvc *service.SCIMService
+}
+
+func NewSCIMHandler(scimSvc *service.SCIMService) *SCIMHandler {
+	return &SCIMHandler{scimSvc: scimSvc}
+}
+
+// Authenticate requires the provisioning client's bearer token. Without a
+// token configured, every SCIM route is 404.
+func (h *SCIMHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "bearer") {
+			token = ""
+		}
+		if err := h.scimSvc.Authorize(token); err != nil {
+			respondSCIMError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListUsers handles GET /scim/v2/Users
+func (h *SCIMHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q, err := scimQuery(r)
+	if err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	list, err := h.scimSvc.ListUsers(r.Context(), q)
+	h.respondList(w, r, list, err)
+}
+
+// GetUser handles GET /scim/v2/Users/{id}
+func (h *SCIMHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.scimSvc.GetUser(r.Context(), chi.URLParam(r, "id"))
+	h.respond(w, r, http.StatusOK, user, err)
+}
+
+// CreateUser handles POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var in scim.User
+	if err := decodeSCIM(r, &in); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	user, err := h.scimSvc.CreateUser(r.Context(), &in)
+	h.respond(w, r, http.StatusCreated, user, err)
+}
+
+// ReplaceUser handles PUT /scim/v2/Users/{id}
+func (h *SCIMHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var in scim.User
+	if err := decodeSCIM(r, &in); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	user, err := h.scimSvc.ReplaceUser(r.Context(), chi.URLParam(r, "id"), &in)
+	h.respond(w, r, http.StatusOK, user, err)
+}
+
+// PatchUser handles PATCH /scim/v2/Users/{id}
+func (h *SCIMHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	var req scim.PatchRequest
+	if err := decodeSCIM(r, &req); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	user, err := h.scimSvc.PatchUser(r.Context(), chi.URLParam(r, "id"), req.Operations)
+	h.respond(w, r, http.StatusOK, user, err)
+}
+
+// DeleteUser handles DELETE /scim/v2/Users/{id}, which deactivates the user.
+func (h *SCIMHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.scimSvc.DeleteUser(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListGroups handles GET /scim/v2/Groups
+func (h *SCIMHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	q, err := scimQuery(r)
+	if err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	list, err := h.scimSvc.ListGroups(r.Context(), q)
+	h.respondList(w, r, list, err)
+}
+
+// GetGroup handles GET /scim/v2/Groups/{id}
+func (h *SCIMHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := h.scimSvc.GetGroup(r.Context(), chi.URLParam(r, "id"))
+	h.respond(w, r, http.StatusOK, group, err)
+}
+
+// CreateGroup handles POST /scim/v2/Groups
+func (h *SCIMHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var in scim.Group
+	if err := decodeSCIM(r, &in); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	group, err := h.scimSvc.CreateGroup(r.Context(), &in)
+	h.respond(w, r, http.StatusCreated, group, err)
+}
+
+// ReplaceGroup handles PUT /scim/v2/Groups/{id}
+func (h *SCIMHandler) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	var in scim.Group
+	if err := decodeSCIM(r, &in); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	group, err := h.scimSvc.ReplaceGroup(r.Context(), chi.URLParam(r, "id"), &in)
+	h.respond(w, r, http.StatusOK, group, err)
+}
+
+// PatchGroup handles PATCH /scim/v2/Groups/{id}
+func (h *SCIMHandler) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	var req scim.PatchRequest
+	if err := decodeSCIM(r, &req); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	group, err := h.scimSvc.PatchGroup(r.Context(), chi.URLParam(r, "id"), req.Operations)
+	h.respond(w, r, http.StatusOK, group, err)
+}
+
+// DeleteGroup handles DELETE /scim/v2/Groups/{id}
+func (h *SCIMHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.scimSvc.DeleteGroup(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServiceProviderConfig handles GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(ok bool) map[string]any { return map[string]any{"supported": ok} }
+	writeSCIM(w, http.StatusOK, map[string]any{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          supported(true),
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": service.SCIMMaxCount},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "The token configured in SCIM_TOKEN",
+			"primary":     true,
+		}},
+		"meta": map[string]any{"resourceType": "ServiceProviderConfig", "location": scimBaseURL(r) + "/ServiceProviderConfig"},
+	})
+}
+
+// ResourceTypes handles GET /scim/v2/ResourceTypes
+func (h *SCIMHandler) ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	base := scimBaseURL(r)
+	resourceType := func(name, endpoint, schema string, extensions ...string) map[string]any {
+		t := map[string]any{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       name,
+			"name":     name,
+			"endpoint": endpoint,
+			"schema":   schema,
+			"meta":     map[string]any{"resourceType": "ResourceType", "location": base + "/ResourceTypes/" + name},
+		}
+		if len(extensions) > 0 {
+			var ext []map[string]any
+			for _, e := range extensions {
+				ext = append(ext, map[string]any{"schema": e, "required": false})
+			}
+			t["schemaExtensions"] = ext
+		}
+		return t
+	}
+	types := []map[string]any{
+		resourceType("User", "/Users", scim.UserSchema, scim.EnterpriseUserSchema),
+		resourceType("Group", "/Groups", scim.GroupSchema),
+	}
+	writeSCIM(w, http.StatusOK, map[string]any{
+		"schemas":      []string{scim.ListResponseSchema},
+		"totalResults": len(types),
+		"startIndex":   1,
+		"itemsPerPage": len(types),
+		"Resources":    types,
+	})
+}
+
+// ---------- Internal ----------
+
+func (h *SCIMHandler) respond(w http.ResponseWriter, r *http.Request, status int, res scim.Resource, err error) {
+	if err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	setLocation(r, res)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", res.ResourceMeta().Location)
+	}
+	writeSCIM(w, status, res)
+}
+
+func (h *SCIMHandler) respondList(w http.ResponseWriter, r *http.Request, list *scim.ListResponse, err error) {
+	if err != nil {
+		respondSCIMError(w, err)
+		return
+	}
+	for _, res := range list.Resources {
+		setLocation(r, res)
+	}
+	writeSCIM(w, http.StatusOK, list)
+}
+
+// setLocation makes a resource's location absolute.
+func setLocation(r *http.Request, res scim.Resource) {
+	if meta := res.ResourceMeta(); meta != nil {
+		meta.Location = scimBaseURL(r) + meta.Location
+	}
+}
+
+// scimBaseURL returns the URL of /scim/v2 as the client sees it.
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+func scimQuery(r *http.Request) (service.SCIMQuery, error) {
+	q := service.SCIMQuery{Filter: r.URL.Query().Get("filter"), StartIndex: 1, Count: service.SCIMDefaultCount}
+	for name, dst := range map[string]*int{"startIndex": &q.StartIndex, "count": &q.Count} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return q, &scim.Error{Status: http.StatusBadRequest, ScimType: scim.ErrInvalidValue, Detail: name + " must be an integer"}
+			}
+			*dst = n
+		}
+	}
+	return q, nil
+}
+
+func decodeSCIM(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var serr *scim.Error
+		if errors.As(err, &serr) {
+			return serr
+		}
+		return &scim.Error{Status: http.StatusBadRequest, ScimType: scim.ErrInvalidSyntax, Detail: "request body is not valid JSON"}
+	}
+	return nil
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+// respondSCIMError writes err in the SCIM error format.
+func respondSCIMError(w http.ResponseWriter, err error) {
+	var serr *scim.Error
+	if !errors.As(err, &serr) {
+		serr = &scim.Error{Detail: err.Error()}
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			serr.Status = http.StatusNotFound
+		case errors.Is(err, domain.ErrUnauthorized):
+			serr.Status = http.StatusUnauthorized
+		case errors.Is(err, domain.ErrForbidden):
+			serr.Status = http.StatusForbidden
+		case errors.Is(err, domain.ErrAlreadyExists):
+			serr.Status, serr.ScimType = http.StatusConflict, scim.ErrUniqueness
+		case errors.Is(err, domain.ErrInvalidInput):
+			serr.Status, serr.ScimType = http.StatusBadRequest, scim.ErrInvalidValue
+			var ve *domain.ValidationError
+			if errors.As(err, &ve) {
+				serr.Detail = validationDetail(ve)
+			}
+		default:
+			log.Printf("[scim] %v", err)
+			serr.Status, serr.Detail = http.StatusInternalServerError, "internal server error"
+		}
+	}
+	writeSCIM(w, serr.Status, serr)
+}
+
+// validationDetail lists the fields of a validation error, such as
+// "password: too_short".
+func validationDetail(ve *domain.ValidationError) string {
+	var parts []string
+	for field, code := range ve.Fields {
+		parts = append(parts, field+": "+code)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
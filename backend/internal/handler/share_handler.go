@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ShareHandler serves share CRUD for one resource kind. The router mounts one
+// instance under /api/docs and another under /api/flows.
+type ShareHandler struct {
+	shareSvc *service.ShareService
+	resource domain.ShareResource
+}
+
+func NewShareHandler(shareSvc *service.ShareService, resource domain.ShareResource) *ShareHandler {
+	return &ShareHandler{shareSvc: shareSvc, resource: resource}
+}
+
+// List handles GET /api/{docs|flows}/{id}/shares
+func (h *ShareHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	shares, err := h.shareSvc.List(r.Context(), userID, h.resource, resourceID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, shares)
+}
+
+// Create handles POST /api/{docs|flows}/{id}/shares
+func (h *ShareHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.CreateShareInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	share, err := h.shareSvc.Create(r.Context(), userID, h.resource, resourceID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, share)
+}
+
+// Update handles PUT /api/{docs|flows}/{id}/shares/{shareId}
+func (h *ShareHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	shareID, err := parseUUID(chi.URLParam(r, "shareId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.UpdateShareInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	share, err := h.shareSvc.Update(r.Context(), userID, h.resource, resourceID, shareID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, share)
+}
+
+// Delete handles DELETE /api/{docs|flows}/{id}/shares/{shareId}
+func (h *ShareHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	shareID, err := parseUUID(chi.URLParam(r, "shareId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.shareSvc.Delete(r.Context(), userID, h.resource, resourceID, shareID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
+
+// ListGroupShares handles GET /api/{docs|flows}/{id}/group_shares
+func (h *ShareHandler) ListGroupShares(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	shares, err := h.shareSvc.ListGroupShares(r.Context(), userID, h.resource, resourceID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, shares)
+}
+
+// CreateGroupShare handles POST /api/{docs|flows}/{id}/group_shares
+func (h *ShareHandler) CreateGroupShare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.CreateGroupShareInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	share, err := h.shareSvc.CreateGroupShare(r.Context(), userID, h.resource, resourceID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondCreated(w, share)
+}
+
+// UpdateGroupShare handles PUT /api/{docs|flows}/{id}/group_shares/{shareId}
+func (h *ShareHandler) UpdateGroupShare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	shareID, err := parseUUID(chi.URLParam(r, "shareId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.UpdateShareInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	share, err := h.shareSvc.UpdateGroupShare(r.Context(), userID, h.resource, resourceID, shareID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, share)
+}
+
+// DeleteGroupShare handles DELETE /api/{docs|flows}/{id}/group_shares/{shareId}
+func (h *ShareHandler) DeleteGroupShare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	resourceID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	shareID, err := parseUUID(chi.URLParam(r, "shareId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.shareSvc.DeleteGroupShare(r.Context(), userID, h.resource, resourceID, shareID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TrashHandler struct {
+	trashSvc *service.TrashService
+}
+
+func NewTrashHandler(trashSvc *service.TrashService) *TrashHandler {
+	return &TrashHandler{trashSvc: trashSvc}
+}
+
+// List handles GET /api/trash
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	trash, err := h.trashSvc.List(r.Context(), userID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, trash)
+}
+
+// RestoreDocument handles POST /api/trash/documents/{id}/restore
+func (h *TrashHandler) RestoreDocument(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	doc, err := h.trashSvc.RestoreDocument(r.Context(), userID, docID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, doc)
+}
+
+// RestoreFlow handles POST /api/trash/flows/{id}/restore
+func (h *TrashHandler) RestoreFlow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	flowID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	flow, err := h.trashSvc.RestoreFlow(r.Context(), userID, flowID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, flow)
+}
+
+// RestoreNode handles POST /api/trash/nodes/{id}/restore
+func (h *TrashHandler) RestoreNode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	nodeID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	node, err := h.trashSvc.RestoreNode(r.Context(), userID, nodeID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, node)
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net/http"
+
+	"docmv/internal/domain"
+	"docmv/internal/middleware"
+	"docmv/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WorkflowNodeHandler struct {
+	nodeSvc *service.WorkflowNodeService
+}
+
+func NewWorkflowNodeHandler(nodeSvc *service.WorkflowNodeService) *WorkflowNodeHandler {
+	return &WorkflowNodeHandler{nodeSvc: nodeSvc}
+}
+
+// ListNodes handles GET /api/docs/{id}/nodes
+func (h *WorkflowNodeHandler) ListNodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	nodes, err := h.nodeSvc.ListNodes(r.Context(), userID, docID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w, nodes)
+}
+
+// CreateNode handles POST /api/docs/{id}/nodes
+func (h *WorkflowNodeHandler) CreateNode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.NodeInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	node, err := h.nodeSvc.CreateNode(r.Context(), userID, docID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondCreated(w, node)
+}
+
+// ReorderNodes handles PUT /api/docs/{id}/nodes/order
+func (h *WorkflowNodeHandler) ReorderNodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	docID, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.ReorderNodesInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	nodes, err := h.nodeSvc.ReorderNodes(r.Context(), userID, docID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, nodes)
+}
+
+// GetNode handles GET /api/nodes/{nodeId}
+func (h *WorkflowNodeHandler) GetNode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	nodeID, err := parseUUID(chi.URLParam(r, "nodeId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	node, err := h.nodeSvc.GetNode(r.Context(), userID, nodeID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	setETag(w, node.Revision)
+	respondOK(w, node)
+}
+
+// UpdateNode handles PUT /api/nodes/{nodeId}
+func (h *WorkflowNodeHandler) UpdateNode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	nodeID, err := parseUUID(chi.URLParam(r, "nodeId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var req service.NodeInput
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, err)
+		return
+	}
+	if err := applyIfMatch(r, &req.Revision); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	node, err := h.nodeSvc.UpdateNode(r.Context(), userID, nodeID, req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	setETag(w, node.Revision)
+	respondOK(w, node)
+}
+
+// DeleteNode handles DELETE /api/nodes/{nodeId}
+func (h *WorkflowNodeHandler) DeleteNode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromCtx(r.Context())
+	if !ok {
+		respondError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	nodeID, err := parseUUID(chi.URLParam(r, "nodeId"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := h.nodeSvc.DeleteNode(r.Context(), userID, nodeID); err != nil {
+		respondError(w, err)
+		return
+	}
+	respondOK(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,273 @@
+// Package ldap checks passwords against an LDAP directory such as OpenLDAP
+// or Active Directory: it finds the user's entry with a service account,
+// reads the attributes a login needs, and binds as the entry to check the
+// password.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+var (
+	// ErrNoEntry is returned when no entry matches the login.
+	ErrNoEntry = errors.New("ldap: no such user")
+	// ErrInvalidCredentials is returned when the entry's password is wrong.
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+)
+
+// Active Directory's userAccountControl flag for disabled accounts.
+const adAccountDisable = 0x2
+
+type Config struct {
+	URL          string      // ldap://host:389 or ldaps://host:636
+	StartTLS     bool        // upgrade an ldap:// connection before binding
+	TLS          *tls.Config // nil verifies against the system roots
+	BindDN       string      // service account that searches; empty searches anonymously
+	BindPassword string
+	Timeout      time.Duration // per connection and operation; zero means ten seconds
+
+	BaseDN     string
+	UserFilter string // "{login}" is replaced with the escaped login
+
+	IDAttr    string // stable identifier, such as entryUUID or objectGUID; empty uses the DN
+	EmailAttr string
+	NameAttr  string
+
+	GroupAttr   string // attribute listing the entry's groups, such as memberOf
+	GroupBaseDN string // where to search groups; empty uses BaseDN
+	GroupFilter string // "{dn}" is replaced with the escaped user DN; finds groups instead of GroupAttr
+}
+
+// Entry is a user as the directory describes them.
+type Entry struct {
+	DN          string
+	ID          string // value of IDAttr, hex encoded if it is binary
+	Email       string
+	DisplayName string
+	Groups      []string // DNs of the groups the user belongs to
+	Disabled    bool     // the directory marks the account as disabled
+}
+
+// MemberOf reports whether the entry belongs to the group with the given DN,
+// comparing DNs the way LDAP does.
+func (e *Entry) MemberOf(group string) bool {
+	want, err := goldap.ParseDN(group)
+	if err != nil {
+		return false
+	}
+	for _, g := range e.Groups {
+		if dn, err := goldap.ParseDN(g); err == nil && dn.EqualFold(want) {
+			return true
+		}
+	}
+	return false
+}
+
+type Directory struct {
+	cfg Config
+}
+
+func New(cfg Config) *Directory {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Directory{cfg: cfg}
+}
+
+// Authenticate finds the entry login names and checks password by binding
+// as it. It returns ErrNoEntry when no single entry matches, and
+// ErrInvalidCredentials along with the entry when the bind fails, so the
+// caller may still sync what the directory says about the account.
+func (d *Directory) Authenticate(ctx context.Context, login, password string) (*Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// An empty password would make an unauthenticated bind, which succeeds.
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if d.cfg.BindDN != "" {
+		if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: binding as %s: %w", d.cfg.BindDN, err)
+		}
+	}
+	entry, err := d.find(conn, login)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.GroupFilter != "" {
+		if entry.Groups, err = d.groups(conn, entry.DN); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return entry, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: binding as %s: %w", entry.DN, err)
+	}
+	return entry, nil
+}
+
+// LoadCA returns TLS settings trusting the PEM certificates in file, for
+// directories with a certificate of a private CA.
+func LoadCA(file string) (*tls.Config, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: reading CA file: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ldap: no certificates in %s", file)
+	}
+	return &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}, nil
+}
+
+func (d *Directory) connect() (*goldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.cfg.Timeout}
+	conn, err := goldap.DialURL(d.cfg.URL, goldap.DialWithDialer(dialer), goldap.DialWithTLSConfig(d.tlsConfig()))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: connecting to %s: %w", d.cfg.URL, err)
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(d.tlsConfig()); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: starting TLS with %s: %w", d.cfg.URL, err)
+		}
+	}
+	return conn, nil
+}
+
+// tlsConfig returns the configured TLS settings with the server name set,
+// which tls.Client needs to verify the certificate on StartTLS.
+func (d *Directory) tlsConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if d.cfg.TLS != nil {
+		cfg = d.cfg.TLS.Clone()
+	}
+	if cfg.ServerName == "" {
+		if u, err := url.Parse(d.cfg.URL); err == nil {
+			cfg.ServerName = u.Hostname()
+		}
+	}
+	return cfg
+}
+
+func (d *Directory) find(conn *goldap.Conn, login string) (*Entry, error) {
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{login}", goldap.EscapeFilter(login))
+	attrs := []string{d.cfg.EmailAttr, d.cfg.NameAttr, "cn", "userAccountControl", "nsAccountLock"}
+	if d.cfg.IDAttr != "" {
+		attrs = append(attrs, d.cfg.IDAttr)
+	}
+	if d.cfg.GroupAttr != "" {
+		attrs = append(attrs, d.cfg.GroupAttr)
+	}
+	res, err := conn.Search(goldap.NewSearchRequest(d.cfg.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		2, d.timeLimit(), false, filter, attrs, nil))
+	if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap: searching %s: %w", filter, err)
+	}
+	// A login matching several entries is ambiguous, not a choice.
+	if len(res.Entries) != 1 {
+		return nil, ErrNoEntry
+	}
+
+	e := res.Entries[0]
+	entry := &Entry{
+		DN:          e.DN,
+		ID:          e.DN,
+		Email:       strings.TrimSpace(value(e, d.cfg.EmailAttr)),
+		DisplayName: strings.TrimSpace(value(e, d.cfg.NameAttr)),
+		Disabled:    disabled(e),
+	}
+	if entry.DisplayName == "" {
+		entry.DisplayName = strings.TrimSpace(value(e, "cn"))
+	}
+	if d.cfg.IDAttr != "" {
+		// AD's objectGUID is binary.
+		if raw := rawValue(e, d.cfg.IDAttr); len(raw) > 0 {
+			if utf8.Valid(raw) {
+				entry.ID = string(raw)
+			} else {
+				entry.ID = hex.EncodeToString(raw)
+			}
+		}
+	}
+	if d.cfg.GroupAttr != "" {
+		entry.Groups = e.GetEqualFoldAttributeValues(d.cfg.GroupAttr)
+	}
+	return entry, nil
+}
+
+// groups returns the DNs of the groups the filter finds for the user.
+func (d *Directory) groups(conn *goldap.Conn, userDN string) ([]string, error) {
+	base := d.cfg.GroupBaseDN
+	if base == "" {
+		base = d.cfg.BaseDN
+	}
+	filter := strings.ReplaceAll(d.cfg.GroupFilter, "{dn}", goldap.EscapeFilter(userDN))
+	res, err := conn.Search(goldap.NewSearchRequest(base, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, d.timeLimit(), false, filter, []string{"1.1"}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: searching groups %s: %w", filter, err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		groups = append(groups, e.DN)
+	}
+	return groups, nil
+}
+
+func (d *Directory) timeLimit() int {
+	return int(d.cfg.Timeout / time.Second)
+}
+
+// disabled reads the account flags of Active Directory and of 389 Directory
+// Server / FreeIPA.
+func disabled(e *goldap.Entry) bool {
+	if uac, err := strconv.ParseInt(value(e, "userAccountControl"), 10, 64); err == nil && uac&adAccountDisable != 0 {
+		return true
+	}
+	return strings.EqualFold(value(e, "nsAccountLock"), "true")
+}
+
+// value returns the first value of an attribute. Servers answer with the
+// attribute's own spelling, which need not be the configured one.
+func value(e *goldap.Entry, attr string) string {
+	if values := e.GetEqualFoldAttributeValues(attr); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func rawValue(e *goldap.Entry, attr string) []byte {
+	for _, a := range e.Attributes {
+		if strings.EqualFold(a.Name, attr) && len(a.ByteValues) > 0 {
+			return a.ByteValues[0]
+		}
+	}
+	return nil
+}
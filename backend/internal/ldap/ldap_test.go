@@ -0,0 +1,152 @@
+package ldap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"docmv/internal/ldap"
+	"docmv/internal/ldap/ldaptest"
+)
+
+const (
+	base    = "dc=example,dc=com"
+	service = "cn=docmv,ou=services,dc=example,dc=com"
+	alice   = "uid=alice,ou=people,dc=example,dc=com"
+	admins  = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+func setup(t *testing.T, srv *ldaptest.Server) ldap.Config {
+	t.Helper()
+	t.Cleanup(srv.Close)
+	srv.Add(base, "", map[string][]string{"objectClass": {"domain"}})
+	srv.Add(service, "service-secret", map[string][]string{"objectClass": {"applicationProcess"}})
+	srv.Add(alice, "alice-secret", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {"alice"},
+		"mail":        {"alice@example.com"},
+		"cn":          {"Alice Liddell"},
+		"entryUUID":   {"0c3c5e2e-8d8a-4d9e-9a57-2b1f3c4d5e6f"},
+		"memberOf":    {admins},
+	})
+	srv.Add(admins, "", map[string][]string{"objectClass": {"groupOfNames"}, "member": {alice}})
+	return ldap.Config{
+		URL:          srv.URL,
+		TLS:          srv.TLSConfig(),
+		BindDN:       service,
+		BindPassword: "service-secret",
+		BaseDN:       base,
+		UserFilter:   "(&(objectClass=inetOrgPerson)(|(uid={login})(mail={login})))",
+		IDAttr:       "entryUUID",
+		EmailAttr:    "mail",
+		NameAttr:     "displayName",
+		GroupAttr:    "memberOf",
+	}
+}
+
+func start(t *testing.T) (*ldaptest.Server, ldap.Config) {
+	t.Helper()
+	srv, err := ldaptest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, setup(t, srv)
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	_, cfg := start(t)
+	dir := ldap.New(cfg)
+
+	for _, login := range []string{"alice", "ALICE@example.com"} {
+		entry, err := dir.Authenticate(ctx, login, "alice-secret")
+		if err != nil {
+			t.Fatalf("Authenticate(%s): %v", login, err)
+		}
+		if entry.DN != alice || entry.ID != "0c3c5e2e-8d8a-4d9e-9a57-2b1f3c4d5e6f" || entry.Email != "alice@example.com" ||
+			entry.DisplayName != "Alice Liddell" || entry.Disabled {
+			t.Fatalf("entry = %+v", entry)
+		}
+		if !entry.MemberOf("CN=Admins, OU=Groups, DC=example, DC=com") || entry.MemberOf("cn=others,dc=example,dc=com") {
+			t.Fatalf("groups = %v", entry.Groups)
+		}
+	}
+
+	entry, err := dir.Authenticate(ctx, "alice", "wrong")
+	if !errors.Is(err, ldap.ErrInvalidCredentials) || entry == nil || entry.DN != alice {
+		t.Fatalf("wrong password: %+v, %v", entry, err)
+	}
+	if _, err := dir.Authenticate(ctx, "alice", ""); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("empty password: %v", err)
+	}
+	if _, err := dir.Authenticate(ctx, "bob", "alice-secret"); !errors.Is(err, ldap.ErrNoEntry) {
+		t.Fatalf("unknown login: %v", err)
+	}
+	// The login is escaped, not a filter of its own.
+	if _, err := dir.Authenticate(ctx, "*", "alice-secret"); !errors.Is(err, ldap.ErrNoEntry) {
+		t.Fatalf("wildcard login: %v", err)
+	}
+
+	bad := cfg
+	bad.BindPassword = "wrong"
+	if _, err := ldap.New(bad).Authenticate(ctx, "alice", "alice-secret"); err == nil || errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("wrong service password: %v, want a configuration error", err)
+	}
+}
+
+func TestAuthenticateAmbiguous(t *testing.T) {
+	srv, cfg := start(t)
+	srv.Add("uid=alice2,ou=people,dc=example,dc=com", "x", map[string][]string{
+		"objectClass": {"inetOrgPerson"}, "uid": {"alice2"}, "mail": {"alice@example.com"},
+	})
+	if _, err := ldap.New(cfg).Authenticate(context.Background(), "alice@example.com", "alice-secret"); !errors.Is(err, ldap.ErrNoEntry) {
+		t.Fatalf("got %v, want ErrNoEntry", err)
+	}
+}
+
+func TestAuthenticateGroupSearchAndFlags(t *testing.T) {
+	srv, cfg := start(t)
+	srv.Set(alice, "memberOf")
+	srv.Set(alice, "userAccountControl", "514") // NORMAL_ACCOUNT | ACCOUNTDISABLE
+	cfg.GroupAttr = ""
+	cfg.GroupFilter = "(&(objectClass=groupOfNames)(member={dn}))"
+
+	entry, err := ldap.New(cfg).Authenticate(context.Background(), "alice", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entry.MemberOf(admins) || len(entry.Groups) != 1 {
+		t.Fatalf("groups = %v", entry.Groups)
+	}
+	if !entry.Disabled {
+		t.Fatal("disabled account not reported")
+	}
+}
+
+func TestAuthenticateTLS(t *testing.T) {
+	ctx := context.Background()
+
+	srv, cfg := start(t)
+	srv.RequireTLS = true
+	if _, err := ldap.New(cfg).Authenticate(ctx, "alice", "alice-secret"); err == nil {
+		t.Fatal("bind without TLS succeeded")
+	}
+	cfg.StartTLS = true
+	if _, err := ldap.New(cfg).Authenticate(ctx, "alice", "alice-secret"); err != nil {
+		t.Fatalf("StartTLS: %v", err)
+	}
+
+	ldaps, err := ldaptest.StartLDAPS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = setup(t, ldaps)
+	if _, err := ldap.New(cfg).Authenticate(ctx, "alice", "alice-secret"); err != nil {
+		t.Fatalf("LDAPS: %v", err)
+	}
+	// Without the server's certificate among the roots.
+	cfg.TLS = nil
+	if _, err := ldap.New(cfg).Authenticate(ctx, "alice", "alice-secret"); err == nil {
+		t.Fatal("untrusted certificate accepted")
+	}
+}
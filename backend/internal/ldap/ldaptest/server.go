@@ -0,0 +1,544 @@
+// Package ldaptest is a minimal LDAP server for tests and local development.
+// It keeps its entries in memory and understands what a login needs: simple
+// binds, searches with the common filters, StartTLS and LDAPS.
+package ldaptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Server is the mock directory. Searches require a bind; binds check the
+// passwords set with Add.
+type Server struct {
+	URL string // ldap:// or ldaps:// address to connect to
+
+	// RequireTLS refuses binds on connections that are not encrypted.
+	RequireTLS bool
+
+	mu      sync.Mutex
+	entries []*entry
+	cert    tls.Certificate
+	roots   *x509.CertPool
+	ln      net.Listener
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type entry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// Start runs a server on a local port, speaking plain LDAP with StartTLS
+// available. Close stops it.
+func Start() (*Server, error) {
+	return Listen("127.0.0.1:0", false)
+}
+
+// StartLDAPS runs a server that speaks LDAP over TLS (ldaps://).
+func StartLDAPS() (*Server, error) {
+	return Listen("127.0.0.1:0", true)
+}
+
+// Listen runs a server at addr, with LDAP over TLS if ldaps is set.
+func Listen(addr string, ldaps bool) (*Server, error) {
+	s := &Server{conns: make(map[net.Conn]struct{})}
+	if err := s.generateCert(); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "ldap"
+	if ldaps {
+		ln = tls.NewListener(ln, s.serverTLS())
+		scheme = "ldaps"
+	}
+	s.ln = ln
+	s.URL = fmt.Sprintf("%s://%s", scheme, ln.Addr())
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// TLSConfig returns client settings that trust the server's certificate.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.roots, MinVersion: tls.VersionTLS12}
+}
+
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.ln.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Add stores an entry; a non-empty password lets it bind. Attribute names
+// are matched case-insensitively, values of equality and substring filters
+// too.
+func (s *Server) Add(dn, password string, attrs map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(dn)
+	copied := make(map[string][]string, len(attrs))
+	for k, v := range attrs {
+		copied[k] = append([]string(nil), v...)
+	}
+	s.entries = append(s.entries, &entry{dn: dn, password: password, attrs: copied})
+}
+
+// Set replaces the values of one attribute of an entry.
+func (s *Server) Set(dn, attr string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.find(dn); e != nil {
+		for k := range e.attrs {
+			if strings.EqualFold(k, attr) {
+				delete(e.attrs, k)
+			}
+		}
+		if len(values) > 0 {
+			e.attrs[attr] = values
+		}
+	}
+}
+
+// Remove deletes an entry.
+func (s *Server) Remove(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(dn)
+}
+
+func (s *Server) remove(dn string) {
+	for i, e := range s.entries {
+		if sameDN(e.dn, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Server) find(dn string) *entry {
+	for _, e := range s.entries {
+		if sameDN(e.dn, dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// session is the state of one connection.
+type session struct {
+	conn  net.Conn
+	tls   bool
+	bound bool
+}
+
+func (s *Server) handle(conn net.Conn) {
+	_, isTLS := conn.(*tls.Conn)
+	sess := &session{conn: conn, tls: isTLS}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, sess.conn)
+		s.mu.Unlock()
+		sess.conn.Close()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(sess.conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			s.bind(sess, id, op)
+		case goldap.ApplicationSearchRequest:
+			s.search(sess, id, op)
+		case goldap.ApplicationExtendedRequest:
+			if !s.extended(sess, id, op) {
+				return
+			}
+		case goldap.ApplicationUnbindRequest:
+			return
+		default:
+			log.Printf("[ldaptest] unsupported operation %d", op.Tag)
+			return
+		}
+	}
+}
+
+func (s *Server) bind(sess *session, id int64, op *ber.Packet) {
+	if len(op.Children) < 3 {
+		respond(sess.conn, id, goldap.ApplicationBindResponse, goldap.LDAPResultProtocolError, "malformed bind")
+		return
+	}
+	name, password := str(op.Children[1]), str(op.Children[2])
+	sess.bound = false
+	if name == "" && password == "" {
+		respond(sess.conn, id, goldap.ApplicationBindResponse, goldap.LDAPResultSuccess, "")
+		return
+	}
+	if s.RequireTLS && !sess.tls {
+		respond(sess.conn, id, goldap.ApplicationBindResponse, goldap.LDAPResultConfidentialityRequired, "TLS required")
+		return
+	}
+
+	s.mu.Lock()
+	e := s.find(name)
+	ok := e != nil && e.password != "" && e.password == password
+	s.mu.Unlock()
+	if !ok {
+		respond(sess.conn, id, goldap.ApplicationBindResponse, goldap.LDAPResultInvalidCredentials, "invalid credentials")
+		return
+	}
+	sess.bound = true
+	respond(sess.conn, id, goldap.ApplicationBindResponse, goldap.LDAPResultSuccess, "")
+}
+
+func (s *Server) search(sess *session, id int64, op *ber.Packet) {
+	if !sess.bound {
+		respond(sess.conn, id, goldap.ApplicationSearchResultDone, goldap.LDAPResultInsufficientAccessRights, "bind required")
+		return
+	}
+	if len(op.Children) < 8 {
+		respond(sess.conn, id, goldap.ApplicationSearchResultDone, goldap.LDAPResultProtocolError, "malformed search")
+		return
+	}
+	base := str(op.Children[0])
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var wanted []string
+	for _, a := range op.Children[7].Children {
+		wanted = append(wanted, str(a))
+	}
+
+	s.mu.Lock()
+	var found []*entry
+	baseExists := s.find(base) != nil
+	for _, e := range s.entries {
+		if inScope(e.dn, base, scope) && matches(filter, e) {
+			found = append(found, e)
+		}
+	}
+	var out [][]byte
+	code := uint16(goldap.LDAPResultSuccess)
+	for i, e := range found {
+		if sizeLimit > 0 && int64(i) >= sizeLimit {
+			code = goldap.LDAPResultSizeLimitExceeded
+			break
+		}
+		out = append(out, entryPacket(id, e, wanted).Bytes())
+	}
+	s.mu.Unlock()
+
+	if !baseExists {
+		respond(sess.conn, id, goldap.ApplicationSearchResultDone, goldap.LDAPResultNoSuchObject, "no such base")
+		return
+	}
+	for _, b := range out {
+		if _, err := sess.conn.Write(b); err != nil {
+			return
+		}
+	}
+	respond(sess.conn, id, goldap.ApplicationSearchResultDone, code, "")
+}
+
+// extended handles StartTLS and refuses other extended operations. It
+// reports whether the connection is still usable.
+func (s *Server) extended(sess *session, id int64, op *ber.Packet) bool {
+	if len(op.Children) == 0 || str(op.Children[0]) != startTLSOID {
+		respond(sess.conn, id, goldap.ApplicationExtendedResponse, goldap.LDAPResultProtocolError, "unsupported extended operation")
+		return true
+	}
+	if sess.tls {
+		respond(sess.conn, id, goldap.ApplicationExtendedResponse, goldap.LDAPResultOperationsError, "already encrypted")
+		return true
+	}
+	respond(sess.conn, id, goldap.ApplicationExtendedResponse, goldap.LDAPResultSuccess, "")
+	tlsConn := tls.Server(sess.conn, s.serverTLS())
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+	s.mu.Lock()
+	delete(s.conns, sess.conn)
+	s.conns[tlsConn] = struct{}{}
+	s.mu.Unlock()
+	sess.conn, sess.tls = tlsConn, true
+	return true
+}
+
+// ---------- Encoding ----------
+
+func respond(conn net.Conn, id int64, tag ber.Tag, code uint16, message string) {
+	packet := envelope(id)
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	packet.AppendChild(res)
+	conn.Write(packet.Bytes()) //nolint:errcheck
+}
+
+func entryPacket(id int64, e *entry, wanted []string) *ber.Packet {
+	packet := envelope(id)
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Entry")
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+	attrs := ber.NewSequence("attributes")
+	for name, values := range e.attrs {
+		returned, ok := selected(name, wanted)
+		if !ok {
+			continue
+		}
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, returned, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	res.AppendChild(attrs)
+	packet.AppendChild(res)
+	return packet
+}
+
+// selected reports whether an attribute was asked for, and under which name
+// to return it: the one the client used, as its lookups may be case-sensitive.
+func selected(name string, wanted []string) (string, bool) {
+	if len(wanted) == 0 {
+		return name, true
+	}
+	for _, w := range wanted {
+		if w == "*" {
+			return name, true
+		}
+		if strings.EqualFold(w, name) {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func envelope(id int64) *ber.Packet {
+	packet := ber.NewSequence("LDAPMessage")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "messageID"))
+	return packet
+}
+
+func str(p *ber.Packet) string {
+	if p.Data == nil {
+		return ""
+	}
+	return p.Data.String()
+}
+
+// ---------- Matching ----------
+
+func inScope(dn, base string, scope int64) bool {
+	d, b := normalizeDN(dn), normalizeDN(base)
+	switch scope {
+	case goldap.ScopeBaseObject:
+		return d == b
+	case goldap.ScopeSingleLevel:
+		i := strings.IndexByte(d, ',')
+		return i >= 0 && d[i+1:] == b
+	default:
+		return d == b || b == "" || strings.HasSuffix(d, ","+b)
+	}
+}
+
+func sameDN(a, b string) bool {
+	return normalizeDN(a) == normalizeDN(b)
+}
+
+// normalizeDN lower-cases a DN and drops the spaces around its separators,
+// which is enough for the plain DNs tests use.
+func normalizeDN(dn string) string {
+	parts := strings.Split(strings.ToLower(dn), ",")
+	for i, p := range parts {
+		kv := strings.SplitN(p, "=", 2)
+		for j := range kv {
+			kv[j] = strings.TrimSpace(kv[j])
+		}
+		parts[i] = strings.Join(kv, "=")
+	}
+	return strings.Join(parts, ",")
+}
+
+// matches evaluates the and, or, not, equality, substring and presence
+// filters; any other filter matches nothing.
+func matches(f *ber.Packet, e *entry) bool {
+	switch f.Tag {
+	case goldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matches(c, e) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, c := range f.Children {
+			if matches(c, e) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterNot:
+		return len(f.Children) == 1 && !matches(f.Children[0], e)
+	case goldap.FilterEqualityMatch:
+		if len(f.Children) != 2 {
+			return false
+		}
+		want := str(f.Children[1])
+		for _, v := range e.values(str(f.Children[0])) {
+			if strings.EqualFold(v, want) || (isDN(v) && sameDN(v, want)) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterSubstrings:
+		if len(f.Children) != 2 {
+			return false
+		}
+		for _, v := range e.values(str(f.Children[0])) {
+			if substringsMatch(strings.ToLower(v), f.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterPresent:
+		return len(e.values(str(f))) > 0
+	default:
+		return false
+	}
+}
+
+func substringsMatch(v string, parts []*ber.Packet) bool {
+	for _, p := range parts {
+		sub := strings.ToLower(str(p))
+		switch p.Tag {
+		case goldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(v, sub) {
+				return false
+			}
+			v = v[len(sub):]
+		case goldap.FilterSubstringsAny:
+			i := strings.Index(v, sub)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(sub):]
+		case goldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(v, sub) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDN(v string) bool {
+	return strings.Contains(v, "=")
+}
+
+func (e *entry) values(attr string) []string {
+	for k, v := range e.attrs {
+		if strings.EqualFold(k, attr) {
+			return v
+		}
+	}
+	return nil
+}
+
+// ---------- TLS ----------
+
+// generateCert makes a self-signed certificate for the local addresses.
+func (s *Server) generateCert() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	s.cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+	s.roots = x509.NewCertPool()
+	s.roots.AddCert(cert)
+	return nil
+}
+
+func (s *Server) serverTLS() *tls.Config {
+	return &tls.Config{Certificates: []tls.Certificate{s.cert}, MinVersion: tls.VersionTLS12}
+}
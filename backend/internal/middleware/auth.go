@@ -2,19 +2,22 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"docmv/internal/domain"
+
 	"github.com/google/uuid"
 )
 
 type contextKey string
 
 const (
-	UserIDKey contextKey = "userID"
-	RoleKey   contextKey = "userRole"
+	UserIDKey    contextKey = "userID"
+	RoleKey      contextKey = "userRole"
+	PrincipalKey contextKey = "principal"
 )
 
 // UserIDFromCtx extracts the authenticated user ID from the request context.
@@ -29,9 +32,24 @@ func RoleFromCtx(ctx context.Context) string {
 	return v
 }
 
-// Auth returns middleware that validates a Bearer JWT and sets user ID + role in context.
-func Auth(secret string) func(http.Handler) http.Handler {
-	secretBytes := []byte(secret)
+// PrincipalFromCtx returns the authenticated caller, including the scopes of
+// the personal access token the request carries, if any.
+func PrincipalFromCtx(ctx context.Context) (*domain.Principal, bool) {
+	v, ok := ctx.Value(PrincipalKey).(*domain.Principal)
+	return v, ok
+}
+
+// TokenVerifier resolves a bearer token to the caller it was issued to. It
+// returns an error wrapping domain.ErrUnauthorized for tokens that are
+// malformed, expired or revoked.
+type TokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (*domain.Principal, error)
+}
+
+// Auth returns middleware that validates a Bearer token and sets user ID + role in context.
+// Tokens starting with domain.APITokenPrefix are personal access tokens and
+// go to apiTokens; everything else is a session JWT and goes to sessions.
+func Auth(sessions, apiTokens TokenVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
@@ -46,34 +64,24 @@ func Auth(secret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-				}
-				return secretBytes, nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, `{"error":{"code":"UNAUTHORIZED","message":"invalid or expired token"}}`, http.StatusUnauthorized)
-				return
+			verifier := sessions
+			if strings.HasPrefix(parts[1], domain.APITokenPrefix) {
+				verifier = apiTokens
 			}
-
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, `{"error":{"code":"UNAUTHORIZED","message":"invalid claims"}}`, http.StatusUnauthorized)
+			principal, err := verifier.VerifyAccessToken(r.Context(), parts[1])
+			if errors.Is(err, domain.ErrUnauthorized) {
+				http.Error(w, `{"error":{"code":"UNAUTHORIZED","message":"invalid or expired token"}}`, http.StatusUnauthorized)
 				return
 			}
-
-			sub, _ := claims["sub"].(string)
-			userID, err := uuid.Parse(sub)
 			if err != nil {
-				http.Error(w, `{"error":{"code":"UNAUTHORIZED","message":"invalid user id in token"}}`, http.StatusUnauthorized)
+				log.Printf("[auth] verifying token: %v", err)
+				http.Error(w, `{"error":{"code":"INTERNAL_SERVER_ERROR","message":"internal server error"}}`, http.StatusInternalServerError)
 				return
 			}
 
-			role, _ := claims["role"].(string)
-
-			ctx := context.WithValue(r.Context(), UserIDKey, userID)
-			ctx = context.WithValue(ctx, RoleKey, role)
+			ctx := context.WithValue(r.Context(), UserIDKey, principal.UserID)
+			ctx = context.WithValue(ctx, RoleKey, string(principal.Role))
+			ctx = context.WithValue(ctx, PrincipalKey, principal)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -89,3 +97,62 @@ func RequireAdmin(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireScope rejects requests whose personal access token lacks scope.
+// Session logins pass.
+func RequireScope(scope domain.TokenScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasScope(r, scope) {
+				http.Error(w, `{"error":{"code":"FORBIDDEN","message":"token lacks the `+string(scope)+` scope"}}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireMethodScope requires the read scope for GET, HEAD and OPTIONS
+// requests and the write scope for everything else.
+func RequireMethodScope(next http.Handler) http.Handler {
+	read, write := RequireScope(domain.ScopeRead)(next), RequireScope(domain.ScopeWrite)(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			read.ServeHTTP(w, r)
+		default:
+			write.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RequireSession rejects requests authenticated with a personal access
+// token, for routes such as token management that a token must not reach.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := PrincipalFromCtx(r.Context()); !ok || p.Scopes != nil {
+			http.Error(w, `{"error":{"code":"FORBIDDEN","message":"not available to api tokens"}}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasScope(r *http.Request, scope domain.TokenScope) bool {
+	p, ok := PrincipalFromCtx(r.Context())
+	return ok && p.HasScope(scope)
+}
+
+// RequireTwoFactorEnrollment rejects requests of users who must enroll in
+// two-factor authentication and have not yet, so that the only thing such
+// a session can do is enroll. Their personal access tokens are held back
+// the same way.
+func RequireTwoFactorEnrollment(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := PrincipalFromCtx(r.Context()); ok && p.TwoFactorSetupRequired {
+			http.Error(w, `{"error":{"code":"TWO_FACTOR_REQUIRED","message":"set up two-factor authentication first"}}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,186 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"docmv/internal/oidc"
+	"docmv/internal/oidc/oidctest"
+)
+
+func setup(t *testing.T) (*oidc.Provider, *oidctest.IdP) {
+	t.Helper()
+	idp, err := oidctest.Start("docmv", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "a@example.com"})
+	p := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer + "/",
+		ClientID:     "docmv",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app/callback",
+		Scopes:       []string{"email"},
+	}, nil)
+	return p, idp
+}
+
+func wantRejected(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("got error %v, want ErrRejected", err)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, idp := setup(t)
+	raw, err := p.AuthCodeURL(context.Background(), "st", "no", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	checks := map[string]string{
+		"response_type":         "code",
+		"client_id":             "docmv",
+		"redirect_uri":          "http://app/callback",
+		"scope":                 "openid email",
+		"state":                 "st",
+		"nonce":                 "no",
+		"code_challenge":        oidc.ChallengeS256("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for k, want := range checks {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if !strings.HasPrefix(raw, idp.Issuer+"/authorize?") {
+		t.Errorf("URL %s is not the authorization endpoint", raw)
+	}
+	// RFC 7636 appendix B
+	if got := oidc.ChallengeS256("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("ChallengeS256 = %s", got)
+	}
+}
+
+func TestExchangeAndVerify(t *testing.T) {
+	ctx := context.Background()
+	p, idp := setup(t)
+
+	authURL, err := p.AuthCodeURL(ctx, "st", "no", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := idp.Approve(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Exchange(ctx, code, "wrong verifier")
+	wantRejected(t, err)
+
+	// The failed attempt used up the code; start over.
+	code, _, _ = idp.Approve(authURL)
+	idToken, err := p.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := p.Verify(ctx, idToken, "no")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.String("sub") != "u1" || claims.String("email") != "a@example.com" {
+		t.Fatalf("claims = %v", claims)
+	}
+	_, err = p.Verify(ctx, idToken, "other nonce")
+	wantRejected(t, err)
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+	ctx := context.Background()
+	p, idp := setup(t)
+
+	cases := map[string]map[string]interface{}{
+		"audience": {"aud": "someone-else"},
+		"issuer":   {"iss": "https://evil.example.com"},
+		"expired":  {"exp": 1000},
+		"azp":      {"aud": []string{"docmv", "other"}, "azp": "other"},
+		"no azp":   {"aud": []string{"docmv", "other"}},
+	}
+	for name, extra := range cases {
+		token, err := idp.Token("no", extra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Verify(ctx, token, "no"); !errors.Is(err, oidc.ErrRejected) {
+			t.Errorf("%s: got error %v, want ErrRejected", name, err)
+		}
+	}
+
+	// A token of another provider does not verify, even under our issuer.
+	other, err := oidctest.New(idp.Issuer, "docmv", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetUser(map[string]interface{}{"sub": "u1"})
+	forged, err := other.Token("no", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Verify(ctx, forged, "no")
+	wantRejected(t, err)
+}
+
+func TestVerifyFollowsKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	p, idp := setup(t)
+
+	token, err := idp.Token("no", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(ctx, token, "no"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := idp.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	token, err = idp.Token("no", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(ctx, token, "no"); err != nil {
+		t.Fatalf("Verify after rotation: %v", err)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	c := oidc.Claims{
+		"email_verified":            "true",
+		"groups":                    []interface{}{"a", "b", 3},
+		"realm_access":              map[string]interface{}{"roles": []interface{}{"admin"}},
+		"https://example.com/roles": "x",
+	}
+	if !c.Bool("email_verified") || c.Bool("missing") {
+		t.Error("Bool")
+	}
+	if got, ok := c.Strings("groups"); !ok || strings.Join(got, ",") != "a,b" {
+		t.Errorf("Strings(groups) = %v, %v", got, ok)
+	}
+	if got, ok := c.Strings("realm_access.roles"); !ok || strings.Join(got, ",") != "admin" {
+		t.Errorf("Strings(realm_access.roles) = %v, %v", got, ok)
+	}
+	if got, ok := c.Strings("https://example.com/roles"); !ok || strings.Join(got, ",") != "x" {
+		t.Errorf("Strings(namespaced) = %v, %v", got, ok)
+	}
+	if _, ok := c.Strings("realm_access.missing"); ok {
+		t.Error("Strings of a missing claim reports ok")
+	}
+}
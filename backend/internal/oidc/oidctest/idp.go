@@ -0,0 +1,299 @@
+// Package oidctest is a minimal OpenID Connect provider for tests and local
+// development. It approves every authorization request as the user set with
+// SetUser, checks client credentials and PKCE at the token endpoint, and
+// signs ID tokens with an RSA key it generates.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"docmv/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IdP is the mock provider. Its zero value is not usable; call New or Start.
+type IdP struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty accepts public clients
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	keyNo  int
+	user   map[string]interface{}
+	grants map[string]grant
+	server *httptest.Server
+}
+
+// grant is an issued authorization code.
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]interface{}
+	expiresAt   time.Time
+}
+
+// New returns a provider serving at issuer, for use as an http.Handler.
+func New(issuer, clientID, clientSecret string) (*IdP, error) {
+	p := &IdP{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		grants:       make(map[string]grant),
+	}
+	if err := p.RotateKey(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start runs a provider on a local test server. Close stops it.
+func Start(clientID, clientSecret string) (*IdP, error) {
+	p, err := New("", clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	p.server = httptest.NewServer(p)
+	p.Issuer = p.server.URL
+	return p, nil
+}
+
+func (p *IdP) Close() {
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// SetUser sets the claims of the user who logs in next, "sub" included.
+func (p *IdP) SetUser(claims map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = claims
+}
+
+// RotateKey replaces the signing key; the old one disappears from the key
+// set at once.
+func (p *IdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("generating signing key: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keyNo++
+	p.key, p.kid = key, fmt.Sprintf("mock-%d", p.keyNo)
+	return nil
+}
+
+// Approve plays the browser: it follows authURL to the authorization
+// endpoint and returns the code and state the provider redirects back with.
+func (p *IdP) Approve(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("authorization endpoint returned status %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state"), nil
+}
+
+// Token signs an ID token for the current user as the token endpoint would,
+// for tests of verification itself. Claims in override replace the standard
+// ones, such as iss, aud or exp.
+func (p *IdP) Token(nonce string, override map[string]interface{}) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sign(p.user, nonce, override)
+}
+
+func (p *IdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.discovery(w)
+	case "/jwks":
+		p.jwks(w)
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *IdP) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+func (p *IdP) jwks(w http.ResponseWriter) {
+	p.mu.Lock()
+	pub := p.key.PublicKey
+	kid := p.kid
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	switch {
+	case q.Get("client_id") != p.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case redirectURI == "":
+		http.Error(w, "redirect_uri required", http.StatusBadRequest)
+		return
+	case q.Get("response_type") != "code":
+		http.Error(w, "only response_type=code is supported", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		http.Error(w, "PKCE with S256 required", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	if p.user == nil {
+		p.mu.Unlock()
+		http.Error(w, "no user set", http.StatusServiceUnavailable)
+		return
+	}
+	code := randomString()
+	p.grants[code] = grant{
+		redirectURI: redirectURI,
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		claims:      p.user,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	back := url.Values{}
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	http.Redirect(w, r, redirectURI+"?"+back.Encode(), http.StatusFound)
+}
+
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || secret != p.ClientSecret {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := r.PostForm.Get("code")
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	if !ok || time.Now().After(g.expiresAt) || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		oidc.ChallengeS256(r.PostForm.Get("code_verifier")) != g.challenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	idToken, err := p.sign(g.claims, g.nonce, nil)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// sign issues an ID token with the user's claims; the caller holds p.mu.
+func (p *IdP) sign(user map[string]interface{}, nonce string, override map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for k, v := range user {
+		claims[k] = v
+	}
+	claims["iss"] = p.Issuer
+	claims["aud"] = p.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for k, v := range override {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	return token.SignedString(p.key)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+func randomString() string {
+	raw := make([]byte, 24)
+	rand.Read(raw) //nolint:errcheck
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type APITokenRepo struct {
+	db *sqlx.DB
+}
+
+func NewAPITokenRepo(db *sqlx.DB) *APITokenRepo {
+	return &APITokenRepo{db: db}
+}
+
+// Create stores a personal access token. ID and CreatedAt are set here.
+func (r *APITokenRepo) Create(ctx context.Context, t *domain.APIToken) error {
+	query := r.db.Rebind(`INSERT INTO api_tokens
+		(id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, t.ID, t.UserID, t.Name, t.TokenHash, t.TokenPrefix, t.Scopes, t.ExpiresAt, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("creating api token: %w", err)
+	}
+	return nil
+}
+
+func (r *APITokenRepo) GetByHash(ctx context.Context, hash string) (*domain.APIToken, error) {
+	var t domain.APIToken
+	err := r.db.GetContext(ctx, &t, r.db.Rebind(`SELECT * FROM api_tokens WHERE token_hash = ?`), hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting api token: %w", err)
+	}
+	return &t, nil
+}
+
+// ListByUser returns the user's tokens that are not revoked, newest first.
+// Expired tokens are included so their owner can see why a script stopped
+// working.
+func (r *APITokenRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.APIToken, error) {
+	tokens := make([]domain.APIToken, 0)
+	query := r.db.Rebind(`SELECT * FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC`)
+	if err := r.db.SelectContext(ctx, &tokens, query, userID); err != nil {
+		return nil, fmt.Errorf("listing api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Revoke revokes one of the user's tokens. It returns ErrNotFound when the
+// user has no such token or it is already revoked.
+func (r *APITokenRepo) Revoke(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	query := r.db.Rebind(`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`)
+	result, err := r.db.ExecContext(ctx, query, at, id, userID)
+	if err != nil {
+		return fmt.Errorf("revoking api token: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *APITokenRepo) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := r.db.Rebind(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`)
+	if _, err := r.db.ExecContext(ctx, query, at, id); err != nil {
+		return fmt.Errorf("updating api token last use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,1937 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// contractCases is the behavior every backend must share. Each case gets an
+// empty backend.
+var contractCases = []contractCase{
+	{"users/create_and_get", testUserCreateAndGet},
+	{"users/duplicate_email", testUserDuplicateEmail},
+	{"users/list", testUserList},
+	{"users/update_password", testUserUpdatePassword},
+	{"users/profile_role_active", testUserProfileRoleActive},
+	{"users/update_email", testUserUpdateEmail},
+	{"users/two_factor", testUserTwoFactor},
+	{"users/delete", testUserDelete},
+	{"tx/rollback_discards", testTxRollback},
+	{"tx/uncommitted_writes_hidden", testTxIsolation},
+	{"documents/get_and_update", testDocumentGetAndUpdate},
+	{"documents/list_visible", testDocumentListVisible},
+	{"documents/access_checks", testDocumentAccess},
+	{"documents/soft_delete", testDocumentSoftDelete},
+	{"versions/order_and_get", testVersionOrder},
+	{"flows/next_flow_no", testFlowNextFlowNo},
+	{"flows/duplicate_flow_no", testFlowDuplicateFlowNo},
+	{"flows/get_and_update", testFlowGetAndUpdate},
+	{"flows/update_status", testFlowUpdateStatus},
+	{"flows/list_visible_and_access", testFlowVisibility},
+	{"flow_nodes/replace_and_order", testFlowNodeReplace},
+	{"flow_nodes/optional_fields", testFlowNodeOptionalFields},
+	{"flow_versions/list_and_hydrate", testFlowVersions},
+	{"shares/crud", testShareCRUD},
+	{"shares/duplicate", testShareDuplicate},
+	{"group_shares/crud", testGroupShareCRUD},
+	{"group_shares/duplicate", testGroupShareDuplicate},
+	{"group_shares/access", testGroupShareAccess},
+	{"workflow_nodes/crud_and_order", testWorkflowNodeCRUD},
+	{"workflow_nodes/trash", testWorkflowNodeTrash},
+	{"trash/restore_and_purge", testDocumentTrashAndPurge},
+	{"ownership/transfer", testOwnershipTransfer},
+	{"document_conversions/record", testDocumentConversions},
+	{"refresh_tokens/lifecycle", testRefreshTokenLifecycle},
+	{"refresh_tokens/delete_expired", testRefreshTokenDeleteExpired},
+	{"api_tokens/lifecycle", testAPITokenLifecycle},
+	{"login_throttles/lifecycle", testLoginThrottleLifecycle},
+	{"password_history/trim", testPasswordHistoryTrim},
+	{"recovery_codes/replace_and_use", testRecoveryCodes},
+	{"settings/get_and_set", testSettings},
+	{"user_identities/link", testUserIdentities},
+	{"user_identities/by_user_and_provider", testUserIdentitiesByProvider},
+	{"oidc_logins/take_once", testOIDCLogins},
+	{"groups/crud", testGroupCRUD},
+	{"groups/members", testGroupMembers},
+	{"signing_keys/list_and_delete", testSigningKeys},
+	{"timestamps/round_trip", testTimestampRoundTrip},
+}
+
+// ── Assertions ─────────────────────────────────────────────────────────────
+
+func mustNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+// sameTime compares timestamps to the precision every driver keeps: MySQL
+// DATETIME(6) and PostgreSQL TIMESTAMPTZ store microseconds.
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d < time.Microsecond && d > -time.Microsecond
+}
+
+// tick makes the next timestamp strictly later than the previous one on
+// every driver, for cases that depend on created_at ordering.
+func tick() { time.Sleep(2 * time.Millisecond) }
+
+func ids[T any](items []T, id func(T) uuid.UUID) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = id(item).String()[:8]
+	}
+	return strings.Join(parts, ",")
+}
+
+func docIDs(docs []domain.Document) string {
+	return ids(docs, func(d domain.Document) uuid.UUID { return d.ID })
+}
+
+func flowIDs(flows []domain.Flow) string {
+	return ids(flows, func(f domain.Flow) uuid.UUID { return f.ID })
+}
+
+// ── Users ──────────────────────────────────────────────────────────────────
+
+func testUserCreateAndGet(t *testing.T, ctx context.Context, b *backend) {
+	u := &domain.User{Email: "a@example.com", PasswordHash: "h", Role: domain.RoleAdmin}
+	mustNoErr(t, b.users.Create(ctx, u))
+	if u.ID == uuid.Nil || u.CreatedAt.IsZero() {
+		t.Fatalf("Create left id=%s created_at=%s unset", u.ID, u.CreatedAt)
+	}
+
+	byEmail, err := b.users.GetByEmail(ctx, "a@example.com")
+	mustNoErr(t, err)
+	byID, err := b.users.GetByID(ctx, u.ID)
+	mustNoErr(t, err)
+	for _, got := range []*domain.User{byEmail, byID} {
+		if got.ID != u.ID || got.PasswordHash != "h" || got.Role != domain.RoleAdmin {
+			t.Fatalf("got user %+v, want %+v", got, u)
+		}
+	}
+
+	defaulted := &domain.User{Email: "b@example.com", PasswordHash: "h"}
+	mustNoErr(t, b.users.Create(ctx, defaulted))
+	got, err := b.users.GetByID(ctx, defaulted.ID)
+	mustNoErr(t, err)
+	if got.Role != domain.RoleUser || got.Locale != domain.LocaleZhCN || !got.Active {
+		t.Fatalf("got role %q locale %q active %t, want an active USER in zh-CN by default", got.Role, got.Locale, got.Active)
+	}
+
+	_, err = b.users.GetByEmail(ctx, "nobody@example.com")
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.users.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+func testUserDuplicateEmail(t *testing.T, ctx context.Context, b *backend) {
+	b.user(t, ctx, "a@example.com")
+	err := b.users.Create(ctx, &domain.User{Email: "a@example.com", PasswordHash: "h"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+}
+
+func testUserList(t *testing.T, ctx context.Context, b *backend) {
+	first := b.user(t, ctx, "first@example.com")
+	tick()
+	second := b.user(t, ctx, "second@example.com")
+
+	users, err := b.users.List(ctx)
+	mustNoErr(t, err)
+	if len(users) != 2 || users[0].ID != second || users[1].ID != first {
+		t.Fatalf("got %d users, want second then first", len(users))
+	}
+	for _, u := range users {
+		if u.PasswordHash != "" {
+			t.Fatalf("List returned password hash for %s", u.Email)
+		}
+	}
+}
+
+func testUserUpdatePassword(t *testing.T, ctx context.Context, b *backend) {
+	id := b.user(t, ctx, "a@example.com")
+	mustNoErr(t, b.users.UpdatePassword(ctx, id, "new-hash"))
+	got, err := b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if got.PasswordHash != "new-hash" || got.TokenVersion != 2 {
+		t.Fatalf("got hash %q token_version %d, want new-hash at version 2", got.PasswordHash, got.TokenVersion)
+	}
+	wantErr(t, b.users.UpdatePassword(ctx, uuid.New(), "x"), domain.ErrNotFound)
+}
+
+func testUserProfileRoleActive(t *testing.T, ctx context.Context, b *backend) {
+	id := b.user(t, ctx, "a@example.com")
+
+	mustNoErr(t, b.users.UpdateProfile(ctx, id, "Alice", "Ops", domain.LocaleEnUS))
+	// Writing unchanged values succeeds too (MySQL reports no affected rows).
+	mustNoErr(t, b.users.UpdateProfile(ctx, id, "Alice", "Ops", domain.LocaleEnUS))
+	mustNoErr(t, b.users.UpdateRole(ctx, id, domain.RoleAdmin))
+	got, err := b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if got.DisplayName != "Alice" || got.Department != "Ops" || got.Locale != domain.LocaleEnUS || got.Role != domain.RoleAdmin {
+		t.Fatalf("got user %+v", got)
+	}
+
+	mustNoErr(t, b.users.SetActive(ctx, id, false))
+	got, err = b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if got.Active || got.TokenVersion != 2 {
+		t.Fatalf("after deactivating: active %t token_version %d, want false at version 2", got.Active, got.TokenVersion)
+	}
+	mustNoErr(t, b.users.SetActive(ctx, id, true))
+	got, err = b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if !got.Active || got.TokenVersion != 2 {
+		t.Fatalf("after reactivating: active %t token_version %d, want true at version 2", got.Active, got.TokenVersion)
+	}
+
+	users, err := b.users.List(ctx)
+	mustNoErr(t, err)
+	if len(users) != 1 || users[0].DisplayName != "Alice" || !users[0].Active {
+		t.Fatalf("List returned %+v", users)
+	}
+}
+
+func testUserUpdateEmail(t *testing.T, ctx context.Context, b *backend) {
+	id := b.user(t, ctx, "a@example.com")
+	b.user(t, ctx, "b@example.com")
+
+	mustNoErr(t, b.users.UpdateEmail(ctx, id, "a2@example.com"))
+	got, err := b.users.GetByEmail(ctx, "a2@example.com")
+	mustNoErr(t, err)
+	if got.ID != id {
+		t.Fatalf("a2@example.com belongs to %s, want %s", got.ID, id)
+	}
+	_, err = b.users.GetByEmail(ctx, "a@example.com")
+	wantErr(t, err, domain.ErrNotFound)
+	wantErr(t, b.users.UpdateEmail(ctx, id, "b@example.com"), domain.ErrAlreadyExists)
+	// Keeping the current address is not a clash.
+	mustNoErr(t, b.users.UpdateEmail(ctx, id, "a2@example.com"))
+}
+
+func testUserTwoFactor(t *testing.T, ctx context.Context, b *backend) {
+	id := b.user(t, ctx, "a@example.com")
+	mustNoErr(t, b.users.SetTwoFactor(ctx, id, "SECRET", false))
+	got, err := b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if got.TOTPSecret != "SECRET" || got.TwoFactorEnabled {
+		t.Fatalf("pending enrollment: got secret %q enabled %t", got.TOTPSecret, got.TwoFactorEnabled)
+	}
+
+	// Each time step is accepted once, and never one older than the last.
+	for _, c := range []struct {
+		step int64
+		want bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		ok, err := b.users.UseTOTPStep(ctx, id, c.step)
+		mustNoErr(t, err)
+		if ok != c.want {
+			t.Fatalf("UseTOTPStep(%d) = %t, want %t", c.step, ok, c.want)
+		}
+	}
+
+	mustNoErr(t, b.users.SetTwoFactor(ctx, id, "SECRET", true))
+	got, err = b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if !got.TwoFactorEnabled || got.TOTPLastStep != 0 {
+		t.Fatalf("after enabling: enabled %t last step %d", got.TwoFactorEnabled, got.TOTPLastStep)
+	}
+	users, err := b.users.List(ctx)
+	mustNoErr(t, err)
+	if len(users) != 1 || !users[0].TwoFactorEnabled || users[0].TOTPSecret != "" {
+		t.Fatalf("List returned %+v", users)
+	}
+
+	mustNoErr(t, b.users.SetTwoFactor(ctx, id, "", false))
+	got, err = b.users.GetByID(ctx, id)
+	mustNoErr(t, err)
+	if got.TOTPSecret != "" || got.TwoFactorEnabled {
+		t.Fatalf("after reset: got secret %q enabled %t", got.TOTPSecret, got.TwoFactorEnabled)
+	}
+}
+
+func testUserDelete(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	guest := b.user(t, ctx, "guest@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityShared)
+	share := b.share(t, ctx, b.docShares, doc.ID, guest, domain.ShareRoleView)
+	b.refreshToken(t, ctx, guest, uuid.New(), strings.Repeat("a", 64), time.Now().Add(time.Hour))
+	b.apiToken(t, ctx, guest, "ci", strings.Repeat("b", 64), domain.ScopeRead)
+	mustNoErr(t, b.pwHistory.Add(ctx, guest, "h1", 5))
+	mustNoErr(t, b.recovery.Replace(ctx, guest, []string{"r1"}))
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: guest, Provider: "https://idp", Subject: "guest"}))
+	group := &domain.Group{Name: "guests"}
+	mustNoErr(t, b.groups.Create(ctx, group))
+	mustNoErr(t, b.groups.AddMember(ctx, group.ID, guest, domain.GroupRoleMember))
+
+	// Owners keep their account until their documents move elsewhere.
+	wantErr(t, b.users.Delete(ctx, owner), domain.ErrInvalidState)
+	_, err := b.users.GetByID(ctx, owner)
+	mustNoErr(t, err)
+
+	// Shares and tokens go with the user.
+	mustNoErr(t, b.users.Delete(ctx, guest))
+	_, err = b.users.GetByID(ctx, guest)
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.docShares.GetByID(ctx, share.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.tokens.GetByHash(ctx, strings.Repeat("a", 64))
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.apiTokens.GetByHash(ctx, strings.Repeat("b", 64))
+	wantErr(t, err, domain.ErrNotFound)
+	history, err := b.pwHistory.ListRecent(ctx, guest, 5)
+	mustNoErr(t, err)
+	if len(history) != 0 {
+		t.Fatalf("password history of deleted user: %v", history)
+	}
+	codes, err := b.recovery.CountUnused(ctx, guest)
+	mustNoErr(t, err)
+	if codes != 0 {
+		t.Fatalf("deleted user still has %d recovery codes", codes)
+	}
+	_, err = b.identities.Get(ctx, "https://idp", "guest")
+	wantErr(t, err, domain.ErrNotFound)
+	members, err := b.groups.ListMembers(ctx, group.ID)
+	mustNoErr(t, err)
+	if len(members) != 0 {
+		t.Fatalf("deleted user is still a member: %+v", members)
+	}
+
+	wantErr(t, b.users.Delete(ctx, guest), domain.ErrNotFound)
+}
+
+// ── Transactions ───────────────────────────────────────────────────────────
+
+func testTxRollback(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	doc := &domain.Document{OwnerID: owner, Title: "T", Visibility: domain.VisibilityPrivate}
+	mustNoErr(t, b.docs.CreateTx(ctx, tx, doc))
+	mustNoErr(t, tx.Rollback())
+
+	_, err = b.docs.GetByID(ctx, doc.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	if err := tx.Commit(); err == nil {
+		t.Fatalf("Commit after Rollback succeeded")
+	}
+}
+
+func testTxIsolation(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	defer tx.Rollback() //nolint:errcheck
+
+	flow := &domain.Flow{OwnerID: owner, FlowNo: "FLOW-TX-0001", Title: "T", Status: domain.FlowStatusDraft}
+	mustNoErr(t, b.flows.CreateTx(ctx, tx, flow))
+
+	// The transaction sees its own write; nobody else does until commit.
+	inTx, err := b.flows.GetByIDTx(ctx, tx, flow.ID)
+	mustNoErr(t, err)
+	if inTx.FlowNo != flow.FlowNo {
+		t.Fatalf("got flow_no %q inside tx, want %q", inTx.FlowNo, flow.FlowNo)
+	}
+	_, err = b.flows.GetByID(ctx, flow.ID)
+	wantErr(t, err, domain.ErrNotFound)
+
+	mustNoErr(t, tx.Commit())
+	_, err = b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+}
+
+// ── Documents ──────────────────────────────────────────────────────────────
+
+func testDocumentGetAndUpdate(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	doc := b.doc(t, ctx, owner, "Draft", domain.VisibilityPrivate)
+	if doc.Revision != 1 {
+		t.Fatalf("got revision %d after create, want 1", doc.Revision)
+	}
+
+	var version domain.DocumentVersion
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		version = domain.DocumentVersion{DocumentID: doc.ID, Content: "v1", CreatedBy: owner}
+		mustNoErr(t, b.versions.CreateTx(ctx, tx, &version))
+		doc.Title = "Final"
+		doc.Visibility = domain.VisibilityPublic
+		doc.LatestVersionID = &version.ID
+		mustNoErr(t, b.docs.UpdateTx(ctx, tx, doc))
+	})
+	if doc.Revision != 2 {
+		t.Fatalf("got revision %d after update, want 2", doc.Revision)
+	}
+
+	got, err := b.docs.GetByID(ctx, doc.ID)
+	mustNoErr(t, err)
+	if got.Title != "Final" || got.Visibility != domain.VisibilityPublic || got.Revision != 2 ||
+		got.LatestVersionID == nil || *got.LatestVersionID != version.ID || got.DeletedAt != nil {
+		t.Fatalf("got document %+v", got)
+	}
+
+	// A writer holding the old revision is turned away and changes nothing.
+	stale := *got
+	stale.Revision = 1
+	stale.Title = "Lost update"
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	wantErr(t, b.docs.UpdateTx(ctx, tx, &stale), domain.ErrStaleRevision)
+	mustNoErr(t, tx.Rollback())
+	got, err = b.docs.GetByID(ctx, doc.ID)
+	mustNoErr(t, err)
+	if got.Title != "Final" {
+		t.Fatalf("stale update wrote title %q", got.Title)
+	}
+
+	_, err = b.docs.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+func testDocumentListVisible(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	viewer := b.user(t, ctx, "viewer@example.com")
+	stranger := b.user(t, ctx, "stranger@example.com")
+
+	private := b.doc(t, ctx, owner, "Private", domain.VisibilityPrivate)
+	tick()
+	shared := b.doc(t, ctx, owner, "Shared", domain.VisibilityShared)
+	tick()
+	public := b.doc(t, ctx, owner, "Public", domain.VisibilityPublic)
+	// Two shares on the same document must not duplicate it in the list.
+	b.share(t, ctx, b.docShares, shared.ID, viewer, domain.ShareRoleView)
+	b.share(t, ctx, b.docShares, shared.ID, stranger, domain.ShareRoleEdit)
+	deleted := b.doc(t, ctx, viewer, "Deleted", domain.VisibilityPublic)
+	mustNoErr(t, b.docs.SoftDelete(ctx, deleted.ID))
+
+	tests := []struct {
+		user uuid.UUID
+		want []*domain.Document
+	}{
+		{owner, []*domain.Document{public, shared, private}},
+		{viewer, []*domain.Document{public, shared}},
+		{uuid.New(), []*domain.Document{public}},
+	}
+	for _, tt := range tests {
+		got, err := b.docs.ListVisible(ctx, tt.user)
+		mustNoErr(t, err)
+		want := make([]domain.Document, len(tt.want))
+		for i, d := range tt.want {
+			want[i] = *d
+		}
+		if docIDs(got) != docIDs(want) {
+			t.Errorf("user %s: got [%s], want [%s] (updated_at desc)", tt.user.String()[:8], docIDs(got), docIDs(want))
+		}
+	}
+}
+
+func testDocumentAccess(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	viewer := b.user(t, ctx, "viewer@example.com")
+	editor := b.user(t, ctx, "editor@example.com")
+	stranger := b.user(t, ctx, "stranger@example.com")
+
+	private := b.doc(t, ctx, owner, "Private", domain.VisibilityPrivate)
+	public := b.doc(t, ctx, owner, "Public", domain.VisibilityPublic)
+	b.share(t, ctx, b.docShares, private.ID, viewer, domain.ShareRoleView)
+	b.share(t, ctx, b.docShares, private.ID, editor, domain.ShareRoleEdit)
+
+	tests := []struct {
+		name           string
+		doc            uuid.UUID
+		user           uuid.UUID
+		canRead, canEd bool
+	}{
+		{"owner", private.ID, owner, true, true},
+		{"view share", private.ID, viewer, true, false},
+		{"edit share", private.ID, editor, true, true},
+		{"stranger on private", private.ID, stranger, false, false},
+		{"stranger on public", public.ID, stranger, true, false},
+		{"missing document", uuid.New(), owner, false, false},
+	}
+	for _, tt := range tests {
+		read, err := b.docs.HasReadAccess(ctx, tt.doc, tt.user)
+		mustNoErr(t, err)
+		edit, err := b.docs.HasEditAccess(ctx, tt.doc, tt.user)
+		mustNoErr(t, err)
+		if read != tt.canRead || edit != tt.canEd {
+			t.Errorf("%s: got read=%t edit=%t, want read=%t edit=%t", tt.name, read, edit, tt.canRead, tt.canEd)
+		}
+	}
+}
+
+func testDocumentSoftDelete(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPublic)
+
+	mustNoErr(t, b.docs.SoftDelete(ctx, doc.ID))
+	_, err := b.docs.GetByID(ctx, doc.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	for _, check := range []func(context.Context, uuid.UUID, uuid.UUID) (bool, error){b.docs.HasReadAccess, b.docs.HasEditAccess} {
+		ok, err := check(ctx, doc.ID, owner)
+		mustNoErr(t, err)
+		if ok {
+			t.Fatalf("owner keeps access to a deleted document")
+		}
+	}
+	// Deleting again is a no-op, not an error.
+	mustNoErr(t, b.docs.SoftDelete(ctx, doc.ID))
+}
+
+// ── Document versions ──────────────────────────────────────────────────────
+
+func testVersionOrder(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	other := b.doc(t, ctx, owner, "Other", domain.VisibilityPrivate)
+
+	var created []domain.DocumentVersion
+	for i, content := range []string{"v1", "v2", "v3"} {
+		v := domain.DocumentVersion{DocumentID: doc.ID, Content: content, CreatedBy: owner}
+		if i == 2 {
+			v.RestoredFromVersionID = &created[0].ID
+		}
+		b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.versions.CreateTx(ctx, tx, &v)) })
+		created = append(created, v)
+		tick()
+	}
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		mustNoErr(t, b.versions.CreateTx(ctx, tx, &domain.DocumentVersion{DocumentID: other.ID, Content: "x", CreatedBy: owner}))
+	})
+
+	got, err := b.versions.ListByDocument(ctx, doc.ID)
+	mustNoErr(t, err)
+	var contents []string
+	for _, v := range got {
+		contents = append(contents, v.Content)
+	}
+	if strings.Join(contents, ",") != "v3,v2,v1" {
+		t.Fatalf("got versions [%s], want newest first [v3,v2,v1]", strings.Join(contents, ","))
+	}
+
+	restored, err := b.versions.GetByID(ctx, created[2].ID)
+	mustNoErr(t, err)
+	if restored.RestoredFromVersionID == nil || *restored.RestoredFromVersionID != created[0].ID || restored.CreatedBy != owner {
+		t.Fatalf("got version %+v, want restored from %s", restored, created[0].ID)
+	}
+	plain, err := b.versions.GetByID(ctx, created[0].ID)
+	mustNoErr(t, err)
+	if plain.RestoredFromVersionID != nil {
+		t.Fatalf("got restored_from %s on an original version", plain.RestoredFromVersionID)
+	}
+
+	empty, err := b.versions.ListByDocument(ctx, uuid.New())
+	mustNoErr(t, err)
+	if empty == nil || len(empty) != 0 {
+		t.Fatalf("got %v for a document without versions, want an empty slice", empty)
+	}
+	_, err = b.versions.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+// ── Flows ──────────────────────────────────────────────────────────────────
+
+func testFlowNextFlowNo(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	year := time.Now().Year()
+
+	first := b.flow(t, ctx, owner, "A", domain.FlowStatusDraft)
+	second := b.flow(t, ctx, owner, "B", domain.FlowStatusDraft)
+	if first.FlowNo != fmt.Sprintf("FLOW-%d-0001", year) || second.FlowNo != fmt.Sprintf("FLOW-%d-0002", year) {
+		t.Fatalf("got flow numbers %s, %s", first.FlowNo, second.FlowNo)
+	}
+
+	// Numbers from other years do not advance this year's sequence; deleted
+	// flows keep theirs.
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		old := &domain.Flow{OwnerID: owner, FlowNo: fmt.Sprintf("FLOW-%d-0042", year-1), Title: "Old", Status: domain.FlowStatusDraft}
+		mustNoErr(t, b.flows.CreateTx(ctx, tx, old))
+	})
+	mustNoErr(t, b.flows.SoftDelete(ctx, second.ID))
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		no, err := b.flows.NextFlowNoTx(ctx, tx)
+		mustNoErr(t, err)
+		if want := fmt.Sprintf("FLOW-%d-0003", year); no != want {
+			t.Fatalf("got next flow number %s, want %s", no, want)
+		}
+	})
+}
+
+func testFlowDuplicateFlowNo(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	existing := b.flow(t, ctx, owner, "A", domain.FlowStatusDraft)
+
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	defer tx.Rollback() //nolint:errcheck
+	dup := &domain.Flow{OwnerID: owner, FlowNo: existing.FlowNo, Title: "B", Status: domain.FlowStatusDraft}
+	wantErr(t, b.flows.CreateTx(ctx, tx, dup), domain.ErrAlreadyExists)
+}
+
+func testFlowGetAndUpdate(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	createdAt := time.Date(2023, 5, 1, 8, 30, 0, 0, time.UTC)
+	flow := &domain.Flow{OwnerID: owner, FlowNo: "FLOW-2023-0001", Title: "Onboarding", Status: domain.FlowStatusDraft, CreatedAt: createdAt}
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.flows.CreateTx(ctx, tx, flow)) })
+
+	// Empty optional text reads back as "" rather than failing on NULL.
+	got, err := b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+	if got.Revision != 1 || got.Overview != "" || got.DiagramJSON != "" || got.OwnerDeptID != "" ||
+		!got.CreatedAt.Equal(createdAt) || got.LatestVersionID != nil {
+		t.Fatalf("got flow %+v", got)
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		current, err := b.flows.GetByIDTx(ctx, tx, flow.ID)
+		mustNoErr(t, err)
+		current.Title = "Onboarding v2"
+		current.OwnerDeptID = "HR"
+		current.Overview = "How new hires start"
+		current.DiagramJSON = `{"nodes":[]}`
+		mustNoErr(t, b.flows.UpdateTx(ctx, tx, current))
+		if current.Revision != 2 {
+			t.Fatalf("got revision %d after update, want 2", current.Revision)
+		}
+	})
+	got, err = b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+	if got.Title != "Onboarding v2" || got.OwnerDeptID != "HR" || got.Overview != "How new hires start" ||
+		got.DiagramJSON != `{"nodes":[]}` || got.Revision != 2 {
+		t.Fatalf("got flow %+v after update", got)
+	}
+
+	stale := *got
+	stale.Revision = 1
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	wantErr(t, b.flows.UpdateTx(ctx, tx, &stale), domain.ErrStaleRevision)
+	mustNoErr(t, tx.Rollback())
+
+	mustNoErr(t, b.flows.SoftDelete(ctx, flow.ID))
+	_, err = b.flows.GetByID(ctx, flow.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	mustNoErr(t, b.flows.SoftDelete(ctx, flow.ID))
+}
+
+func testFlowUpdateStatus(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusInReview)
+
+	var version domain.FlowVersion
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		version = domain.FlowVersion{FlowID: flow.ID, SnapshotJSON: `{"nodes":[]}`, CreatedBy: owner}
+		mustNoErr(t, b.flowVersions.CreateTx(ctx, tx, &version))
+
+		publish := *flow
+		publish.Status = domain.FlowStatusEffective
+		publish.LatestVersionID = &version.ID
+		publish.Title = "ignored"
+		ok, err := b.flows.UpdateStatusTx(ctx, tx, &publish, domain.FlowStatusInReview)
+		mustNoErr(t, err)
+		if !ok {
+			t.Fatalf("UpdateStatusTx from IN_REVIEW reported no change")
+		}
+
+		// The flow is no longer IN_REVIEW, so a second transition loses.
+		again := publish
+		again.Status = domain.FlowStatusDraft
+		ok, err = b.flows.UpdateStatusTx(ctx, tx, &again, domain.FlowStatusInReview)
+		mustNoErr(t, err)
+		if ok {
+			t.Fatalf("UpdateStatusTx applied from a status the flow had left")
+		}
+	})
+
+	got, err := b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+	if got.Status != domain.FlowStatusEffective || got.LatestVersionID == nil || *got.LatestVersionID != version.ID ||
+		got.Title != "T" || got.Revision != flow.Revision+1 {
+		t.Fatalf("got flow %+v, want EFFECTIVE at revision %d with only status and version changed", got, flow.Revision+1)
+	}
+}
+
+func testFlowVisibility(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	viewer := b.user(t, ctx, "viewer@example.com")
+	editor := b.user(t, ctx, "editor@example.com")
+	stranger := b.user(t, ctx, "stranger@example.com")
+
+	draft := b.flow(t, ctx, owner, "Draft", domain.FlowStatusDraft)
+	tick()
+	effective := b.flow(t, ctx, owner, "Effective", domain.FlowStatusEffective)
+	b.share(t, ctx, b.flowShares, draft.ID, viewer, domain.ShareRoleView)
+	b.share(t, ctx, b.flowShares, draft.ID, editor, domain.ShareRoleEdit)
+	deleted := b.flow(t, ctx, stranger, "Deleted", domain.FlowStatusEffective)
+	mustNoErr(t, b.flows.SoftDelete(ctx, deleted.ID))
+	// A published flow reopened as a draft keeps its latest version.
+	tick()
+	redrafted := b.flow(t, ctx, owner, "Redrafted", domain.FlowStatusEffective)
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		v := &domain.FlowVersion{FlowID: redrafted.ID, SnapshotJSON: `{}`, CreatedBy: owner}
+		mustNoErr(t, b.flowVersions.CreateTx(ctx, tx, v))
+		redrafted.LatestVersionID = &v.ID
+		redrafted.Status = domain.FlowStatusDraft
+		ok, err := b.flows.UpdateStatusTx(ctx, tx, redrafted, domain.FlowStatusEffective)
+		mustNoErr(t, err)
+		if !ok {
+			t.Fatalf("redrafting did not switch the status")
+		}
+	})
+
+	lists := []struct {
+		user uuid.UUID
+		want []domain.Flow
+	}{
+		{owner, []domain.Flow{*redrafted, *effective, *draft}},
+		{viewer, []domain.Flow{*redrafted, *effective, *draft}},
+		{stranger, []domain.Flow{*redrafted, *effective}},
+	}
+	for _, tt := range lists {
+		got, err := b.flows.ListVisible(ctx, tt.user)
+		mustNoErr(t, err)
+		if flowIDs(got) != flowIDs(tt.want) {
+			t.Errorf("user %s: got [%s], want [%s] (updated_at desc)", tt.user.String()[:8], flowIDs(got), flowIDs(tt.want))
+		}
+	}
+
+	access := []struct {
+		name                     string
+		flow                     uuid.UUID
+		user                     uuid.UUID
+		canRead, canDraft, canEd bool
+	}{
+		{"owner", draft.ID, owner, true, true, true},
+		{"view share", draft.ID, viewer, true, true, false},
+		{"edit share", draft.ID, editor, true, true, true},
+		{"stranger on draft", draft.ID, stranger, false, false, false},
+		{"stranger on effective", effective.ID, stranger, true, false, false},
+		{"stranger on redrafted", redrafted.ID, stranger, true, false, false},
+		{"owner of redrafted", redrafted.ID, owner, true, true, true},
+		{"owner of deleted", deleted.ID, stranger, false, false, false},
+	}
+	for _, tt := range access {
+		read, err := b.flows.HasReadAccess(ctx, tt.flow, tt.user)
+		mustNoErr(t, err)
+		inDraft, err := b.flows.HasDraftAccess(ctx, tt.flow, tt.user)
+		mustNoErr(t, err)
+		edit, err := b.flows.HasEditAccess(ctx, tt.flow, tt.user)
+		mustNoErr(t, err)
+		if read != tt.canRead || inDraft != tt.canDraft || edit != tt.canEd {
+			t.Errorf("%s: got read=%t draft=%t edit=%t, want read=%t draft=%t edit=%t",
+				tt.name, read, inDraft, edit, tt.canRead, tt.canDraft, tt.canEd)
+		}
+	}
+}
+
+// ── Flow nodes ─────────────────────────────────────────────────────────────
+
+func testFlowNodeReplace(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+	other := b.flow(t, ctx, owner, "Other", domain.FlowStatusDraft)
+
+	nodes := []domain.FlowNode{{NodeNo: "1", Name: "Apply"}, {NodeNo: "2", Name: "Review"}, {NodeNo: "3", Name: "Approve"}}
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		mustNoErr(t, b.flowNodes.ReplaceTx(ctx, tx, flow.ID, nodes))
+		mustNoErr(t, b.flowNodes.ReplaceTx(ctx, tx, other.ID, []domain.FlowNode{{NodeNo: "1", Name: "Elsewhere"}}))
+	})
+
+	// Reorder, drop one, add one: kept nodes keep their IDs, and sort_order
+	// follows the slice.
+	reordered := []domain.FlowNode{nodes[2], nodes[0], {NodeNo: "4", Name: "Archive"}}
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		mustNoErr(t, b.flowNodes.ReplaceTx(ctx, tx, flow.ID, reordered))
+		inTx, err := b.flowNodes.ListByFlowTx(ctx, tx, flow.ID)
+		mustNoErr(t, err)
+		if len(inTx) != 3 {
+			t.Fatalf("got %d nodes inside tx, want 3", len(inTx))
+		}
+	})
+
+	got, err := b.flowNodes.ListByFlow(ctx, flow.ID)
+	mustNoErr(t, err)
+	var names []string
+	for i, n := range got {
+		names = append(names, n.Name)
+		if n.SortOrder != i || n.FlowID != flow.ID || n.ID != reordered[i].ID {
+			t.Errorf("node %d: got sort_order=%d flow=%s id=%s, want %d %s %s",
+				i, n.SortOrder, n.FlowID, n.ID, i, flow.ID, reordered[i].ID)
+		}
+	}
+	if strings.Join(names, ",") != "Approve,Apply,Archive" {
+		t.Fatalf("got nodes [%s], want [Approve,Apply,Archive]", strings.Join(names, ","))
+	}
+
+	otherNodes, err := b.flowNodes.ListByFlow(ctx, other.ID)
+	mustNoErr(t, err)
+	if len(otherNodes) != 1 {
+		t.Fatalf("replacing one flow's nodes touched another: got %d nodes", len(otherNodes))
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.flowNodes.ReplaceTx(ctx, tx, flow.ID, nil)) })
+	got, err = b.flowNodes.ListByFlow(ctx, flow.ID)
+	mustNoErr(t, err)
+	if got == nil || len(got) != 0 {
+		t.Fatalf("got %v after clearing, want an empty slice", got)
+	}
+}
+
+func testFlowNodeOptionalFields(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	min, max := 0.5, 2.25
+	nodes := []domain.FlowNode{
+		{
+			NodeNo: "1", Name: "Full", Intro: "intro", RaciJSON: `{"r":["a"]}`, ExecForm: domain.ExecFormReview,
+			DurationMin: &min, DurationMax: &max, DurationUnit: domain.DurationUnitDay,
+			PrereqText: "pre", OutputsText: "out", SubtasksJSON: `["s"]`,
+		},
+		{NodeNo: "2", Name: "Bare", DurationUnit: domain.DurationUnitHour},
+	}
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.flowNodes.ReplaceTx(ctx, tx, flow.ID, nodes)) })
+
+	got, err := b.flowNodes.ListByFlow(ctx, flow.ID)
+	mustNoErr(t, err)
+	if len(got) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(got))
+	}
+	full, bare := got[0], got[1]
+	if full.Intro != "intro" || full.RaciJSON != `{"r":["a"]}` || full.ExecForm != domain.ExecFormReview ||
+		full.DurationMin == nil || *full.DurationMin != min || full.DurationMax == nil || *full.DurationMax != max ||
+		full.DurationUnit != domain.DurationUnitDay || full.PrereqText != "pre" || full.OutputsText != "out" ||
+		full.SubtasksJSON != `["s"]` {
+		t.Errorf("got full node %+v", full)
+	}
+	if bare.Intro != "" || bare.RaciJSON != "" || bare.ExecForm != "" || bare.DurationMin != nil ||
+		bare.DurationMax != nil || bare.PrereqText != "" || bare.SubtasksJSON != "" {
+		t.Errorf("got bare node %+v, want empty strings and nil durations", bare)
+	}
+}
+
+// ── Flow versions ──────────────────────────────────────────────────────────
+
+func testFlowVersions(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	min := 1.0
+	snapshot := domain.FlowSnapshot{
+		SchemaVersion: domain.FlowSnapshotSchema,
+		Flow:          *flow,
+		Nodes:         []domain.FlowNode{{NodeNo: "1", Name: "Apply", DurationMin: &min, DurationUnit: domain.DurationUnitDay}},
+	}
+	raw, err := json.Marshal(snapshot)
+	mustNoErr(t, err)
+
+	var created []domain.FlowVersion
+	for i := 0; i < 2; i++ {
+		v := domain.FlowVersion{FlowID: flow.ID, SnapshotJSON: string(raw), CreatedBy: owner}
+		if i == 1 {
+			v.RestoredFromVersionID = &created[0].ID
+		}
+		b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.flowVersions.CreateTx(ctx, tx, &v)) })
+		created = append(created, v)
+		tick()
+	}
+
+	list, err := b.flowVersions.ListByFlow(ctx, flow.ID)
+	mustNoErr(t, err)
+	if len(list) != 2 || list[0].ID != created[1].ID || list[1].ID != created[0].ID {
+		t.Fatalf("got %d versions, want 2 newest first", len(list))
+	}
+	for _, v := range list {
+		if v.SnapshotJSON != "" || v.Snapshot != nil {
+			t.Fatalf("ListByFlow returned snapshot content for %s", v.ID)
+		}
+	}
+	if list[0].RestoredFromVersionID == nil || *list[0].RestoredFromVersionID != created[0].ID {
+		t.Fatalf("got restored_from %v, want %s", list[0].RestoredFromVersionID, created[0].ID)
+	}
+
+	got, err := b.flowVersions.GetByID(ctx, created[0].ID)
+	mustNoErr(t, err)
+	if got.Snapshot == nil || got.Snapshot.Flow.FlowNo != flow.FlowNo || len(got.Snapshot.Nodes) != 1 ||
+		got.Snapshot.Nodes[0].DurationMin == nil || *got.Snapshot.Nodes[0].DurationMin != min {
+		t.Fatalf("got snapshot %+v, want it hydrated from the stored JSON", got.Snapshot)
+	}
+	_, err = b.flowVersions.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+// ── Shares ─────────────────────────────────────────────────────────────────
+
+func testShareCRUD(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	for _, repo := range []struct {
+		name     string
+		shares   repository.ShareRepository
+		resource uuid.UUID
+		kind     domain.ShareResource
+	}{
+		{"document", b.docShares, doc.ID, domain.ShareResourceDocument},
+		{"flow", b.flowShares, flow.ID, domain.ShareResourceFlow},
+	} {
+		first := b.share(t, ctx, repo.shares, repo.resource, alice, domain.ShareRoleView)
+		tick()
+		second := b.share(t, ctx, repo.shares, repo.resource, bob, domain.ShareRoleEdit)
+
+		list, err := repo.shares.ListByResource(ctx, repo.resource)
+		mustNoErr(t, err)
+		if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+			t.Fatalf("%s: got %d shares, want alice then bob", repo.name, len(list))
+		}
+		if list[0].UserEmail != "alice@example.com" || list[0].Resource != repo.kind || list[0].ResourceID != repo.resource {
+			t.Fatalf("%s: got share %+v", repo.name, list[0])
+		}
+
+		mustNoErr(t, repo.shares.UpdateRole(ctx, first.ID, domain.ShareRoleEdit))
+		got, err := repo.shares.GetByID(ctx, first.ID)
+		mustNoErr(t, err)
+		if got.Role != domain.ShareRoleEdit || got.UserEmail != "alice@example.com" || got.Resource != repo.kind {
+			t.Fatalf("%s: got share %+v after UpdateRole", repo.name, got)
+		}
+
+		mustNoErr(t, repo.shares.Delete(ctx, first.ID))
+		_, err = repo.shares.GetByID(ctx, first.ID)
+		wantErr(t, err, domain.ErrNotFound)
+		// Writes to a missing share are no-ops; callers check existence first.
+		mustNoErr(t, repo.shares.Delete(ctx, first.ID))
+		mustNoErr(t, repo.shares.UpdateRole(ctx, first.ID, domain.ShareRoleView))
+	}
+
+	// Document and flow shares live apart even when the IDs would collide.
+	list, err := b.docShares.ListByResource(ctx, flow.ID)
+	mustNoErr(t, err)
+	if len(list) != 0 {
+		t.Fatalf("document shares list returned %d flow shares", len(list))
+	}
+}
+
+func testShareDuplicate(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	alice := b.user(t, ctx, "alice@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	for _, tt := range []struct {
+		shares   repository.ShareRepository
+		resource uuid.UUID
+	}{{b.docShares, doc.ID}, {b.flowShares, flow.ID}} {
+		b.share(t, ctx, tt.shares, tt.resource, alice, domain.ShareRoleView)
+		err := tt.shares.Create(ctx, &domain.Share{ResourceID: tt.resource, UserID: alice, Role: domain.ShareRoleEdit})
+		wantErr(t, err, domain.ErrAlreadyExists)
+	}
+}
+
+func testGroupShareCRUD(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	eng := b.group(t, ctx, "eng")
+	ops := b.group(t, ctx, "ops")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	for _, repo := range []struct {
+		name     string
+		shares   repository.GroupShareRepository
+		resource uuid.UUID
+		kind     domain.ShareResource
+	}{
+		{"document", b.docGroupShares, doc.ID, domain.ShareResourceDocument},
+		{"flow", b.flowGroupShares, flow.ID, domain.ShareResourceFlow},
+	} {
+		first := b.groupShare(t, ctx, repo.shares, repo.resource, eng.ID, domain.ShareRoleView)
+		tick()
+		second := b.groupShare(t, ctx, repo.shares, repo.resource, ops.ID, domain.ShareRoleEdit)
+
+		list, err := repo.shares.ListByResource(ctx, repo.resource)
+		mustNoErr(t, err)
+		if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+			t.Fatalf("%s: got %d group shares, want eng then ops", repo.name, len(list))
+		}
+		if list[0].GroupName != "eng" || list[0].Resource != repo.kind || list[0].ResourceID != repo.resource {
+			t.Fatalf("%s: got group share %+v", repo.name, list[0])
+		}
+
+		mustNoErr(t, repo.shares.UpdateRole(ctx, first.ID, domain.ShareRoleEdit))
+		got, err := repo.shares.GetByID(ctx, first.ID)
+		mustNoErr(t, err)
+		if got.Role != domain.ShareRoleEdit || got.GroupName != "eng" || got.Resource != repo.kind {
+			t.Fatalf("%s: got group share %+v after UpdateRole", repo.name, got)
+		}
+
+		mustNoErr(t, repo.shares.Delete(ctx, first.ID))
+		_, err = repo.shares.GetByID(ctx, first.ID)
+		wantErr(t, err, domain.ErrNotFound)
+	}
+
+	// Shares go with their group.
+	mustNoErr(t, b.groups.Delete(ctx, ops.ID))
+	for _, repo := range []repository.GroupShareRepository{b.docGroupShares, b.flowGroupShares} {
+		list, err := repo.ListByResource(ctx, doc.ID)
+		mustNoErr(t, err)
+		more, err := repo.ListByResource(ctx, flow.ID)
+		mustNoErr(t, err)
+		if len(list)+len(more) != 0 {
+			t.Fatalf("group shares left after deleting their group: %+v %+v", list, more)
+		}
+	}
+}
+
+func testGroupShareDuplicate(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	eng := b.group(t, ctx, "eng")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	flow := b.flow(t, ctx, owner, "T", domain.FlowStatusDraft)
+
+	for _, tt := range []struct {
+		shares   repository.GroupShareRepository
+		resource uuid.UUID
+	}{{b.docGroupShares, doc.ID}, {b.flowGroupShares, flow.ID}} {
+		b.groupShare(t, ctx, tt.shares, tt.resource, eng.ID, domain.ShareRoleView)
+		err := tt.shares.Create(ctx, &domain.GroupShare{ResourceID: tt.resource, GroupID: eng.ID, Role: domain.ShareRoleEdit})
+		wantErr(t, err, domain.ErrAlreadyExists)
+	}
+}
+
+// testGroupShareAccess checks that members get the strongest of their own
+// share and their groups' shares, and lose it when they leave.
+func testGroupShareAccess(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	viewer := b.user(t, ctx, "viewer@example.com")
+	editor := b.user(t, ctx, "editor@example.com")
+	both := b.user(t, ctx, "both@example.com")
+	stranger := b.user(t, ctx, "stranger@example.com")
+	readers := b.group(t, ctx, "readers", viewer, both)
+	writers := b.group(t, ctx, "writers", editor, both)
+	b.group(t, ctx, "others", stranger)
+
+	doc := b.doc(t, ctx, owner, "Doc", domain.VisibilityPrivate)
+	flow := b.flow(t, ctx, owner, "Flow", domain.FlowStatusDraft)
+	b.groupShare(t, ctx, b.docGroupShares, doc.ID, readers.ID, domain.ShareRoleView)
+	b.groupShare(t, ctx, b.docGroupShares, doc.ID, writers.ID, domain.ShareRoleEdit)
+	b.groupShare(t, ctx, b.flowGroupShares, flow.ID, readers.ID, domain.ShareRoleView)
+	b.groupShare(t, ctx, b.flowGroupShares, flow.ID, writers.ID, domain.ShareRoleEdit)
+	// A direct VIEW share does not hide the EDIT the user has through a group.
+	b.share(t, ctx, b.docShares, doc.ID, editor, domain.ShareRoleView)
+	b.share(t, ctx, b.flowShares, flow.ID, editor, domain.ShareRoleView)
+
+	type access func(context.Context, uuid.UUID, uuid.UUID) (bool, error)
+	for _, res := range []struct {
+		name       string
+		id         uuid.UUID
+		read, edit access
+		visible    func(uuid.UUID) string
+	}{
+		{"document", doc.ID, b.docs.HasReadAccess, b.docs.HasEditAccess, func(user uuid.UUID) string {
+			docs, err := b.docs.ListVisible(ctx, user)
+			mustNoErr(t, err)
+			return docIDs(docs)
+		}},
+		{"flow", flow.ID, b.flows.HasReadAccess, b.flows.HasEditAccess, func(user uuid.UUID) string {
+			flows, err := b.flows.ListVisible(ctx, user)
+			mustNoErr(t, err)
+			return flowIDs(flows)
+		}},
+	} {
+		want := ids([]uuid.UUID{res.id}, func(id uuid.UUID) uuid.UUID { return id })
+		for _, tt := range []struct {
+			name           string
+			user           uuid.UUID
+			canRead, canEd bool
+		}{
+			{"view group", viewer, true, false},
+			{"edit group over view share", editor, true, true},
+			{"member of both groups", both, true, true},
+			{"member of another group", stranger, false, false},
+		} {
+			read, err := res.read(ctx, res.id, tt.user)
+			mustNoErr(t, err)
+			edit, err := res.edit(ctx, res.id, tt.user)
+			mustNoErr(t, err)
+			if read != tt.canRead || edit != tt.canEd {
+				t.Errorf("%s, %s: got read=%t edit=%t, want read=%t edit=%t", res.name, tt.name, read, edit, tt.canRead, tt.canEd)
+			}
+			// Two grants through groups must not duplicate the row.
+			if got := res.visible(tt.user); tt.canRead && got != want || !tt.canRead && got != "" {
+				t.Errorf("%s, %s: listed [%s]", res.name, tt.name, got)
+			}
+		}
+	}
+
+	// Leaving the group ends the access.
+	mustNoErr(t, b.groups.RemoveMember(ctx, readers.ID, viewer))
+	for id, check := range map[uuid.UUID]access{doc.ID: b.docs.HasReadAccess, flow.ID: b.flows.HasReadAccess} {
+		ok, err := check(ctx, id, viewer)
+		mustNoErr(t, err)
+		if ok {
+			t.Fatal("former member keeps access")
+		}
+	}
+}
+
+// ── Workflow nodes ─────────────────────────────────────────────────────────
+
+func (b *backend) node(t *testing.T, ctx context.Context, docID uuid.UUID, name string) *domain.WorkflowNode {
+	t.Helper()
+	node := &domain.WorkflowNode{
+		DocumentID: docID, Name: name, ExecForm: domain.ExecFormManual, DurationUnit: domain.DurationUnitDay,
+		RaciJSON: `{"R":["ann"],"A":[],"S":[],"C":[],"I":[]}`, SubtasksJSON: `["a","b"]`, DiagramRaw: `{"nodes":[],"edges":[]}`,
+	}
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.workflowNodes.CreateTx(ctx, tx, node)) })
+	tick()
+	return node
+}
+
+func testWorkflowNodeCRUD(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	first := b.node(t, ctx, doc.ID, "first")
+	second := b.node(t, ctx, doc.ID, "second")
+	if first.SortOrder != 0 || second.SortOrder != 1 || first.Revision != 1 {
+		t.Fatalf("created with sort orders %d, %d and revision %d", first.SortOrder, second.SortOrder, first.Revision)
+	}
+
+	got, err := b.workflowNodes.GetByID(ctx, first.ID)
+	mustNoErr(t, err)
+	if got.Name != "first" || len(got.Raci.R) != 1 || len(got.Subtasks) != 2 || !sameTime(got.CreatedAt, first.CreatedAt) {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = b.workflowNodes.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+
+	got.Name = "renamed"
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.workflowNodes.UpdateTx(ctx, tx, got)) })
+	if got.Revision != 2 {
+		t.Fatalf("revision after update = %d, want 2", got.Revision)
+	}
+	stale := *first
+	stale.Name = "stale"
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		wantErr(t, b.workflowNodes.UpdateTx(ctx, tx, &stale), domain.ErrStaleRevision)
+	})
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		ids, err := b.workflowNodes.ListIDsByDocumentTx(ctx, tx, doc.ID)
+		mustNoErr(t, err)
+		if len(ids) != 2 {
+			t.Fatalf("ListIDsByDocumentTx = %v", ids)
+		}
+		mustNoErr(t, b.workflowNodes.UpdateSortOrderTx(ctx, tx, doc.ID, []uuid.UUID{second.ID, first.ID}))
+	})
+	nodes, err := b.workflowNodes.ListByDocument(ctx, doc.ID)
+	mustNoErr(t, err)
+	if len(nodes) != 2 || nodes[0].ID != second.ID || nodes[1].Name != "renamed" || nodes[1].SortOrder != 1 {
+		t.Fatalf("ListByDocument = %s", ids(nodes, func(n domain.WorkflowNode) uuid.UUID { return n.ID }))
+	}
+}
+
+func testWorkflowNodeTrash(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	editor := b.user(t, ctx, "editor@example.com")
+	viewer := b.user(t, ctx, "viewer@example.com")
+	member := b.user(t, ctx, "member@example.com")
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPublic)
+	b.share(t, ctx, b.docShares, doc.ID, editor, domain.ShareRoleEdit)
+	b.share(t, ctx, b.docShares, doc.ID, viewer, domain.ShareRoleView)
+	b.groupShare(t, ctx, b.docGroupShares, doc.ID, b.group(t, ctx, "Editors", member).ID, domain.ShareRoleEdit)
+	kept := b.node(t, ctx, doc.ID, "kept")
+	older := b.node(t, ctx, doc.ID, "older")
+	newer := b.node(t, ctx, doc.ID, "newer")
+
+	mustNoErr(t, b.workflowNodes.SoftDelete(ctx, older.ID))
+	tick()
+	mustNoErr(t, b.workflowNodes.SoftDelete(ctx, newer.ID))
+	_, err := b.workflowNodes.GetByID(ctx, older.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.workflowNodes.GetDeletedByID(ctx, kept.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	got, err := b.workflowNodes.GetDeletedByID(ctx, older.ID)
+	mustNoErr(t, err)
+	if got.DeletedAt == nil || got.Name != "older" {
+		t.Fatalf("GetDeletedByID = %+v", got)
+	}
+
+	for _, tc := range []struct {
+		user uuid.UUID
+		want int
+	}{{owner, 2}, {editor, 2}, {member, 2}, {viewer, 0}} {
+		nodes, err := b.workflowNodes.ListDeletedEditable(ctx, tc.user)
+		mustNoErr(t, err)
+		if len(nodes) != tc.want || (tc.want > 0 && nodes[0].ID != newer.ID) {
+			t.Fatalf("ListDeletedEditable = %s, want %d newest first", ids(nodes, func(n domain.WorkflowNode) uuid.UUID { return n.ID }), tc.want)
+		}
+	}
+
+	mustNoErr(t, b.workflowNodes.Restore(ctx, newer.ID))
+	if _, err := b.workflowNodes.GetByID(ctx, newer.ID); err != nil {
+		t.Fatalf("restored node: %v", err)
+	}
+
+	// Nodes of a trashed document are not listed.
+	mustNoErr(t, b.docs.SoftDelete(ctx, doc.ID))
+	nodes, err := b.workflowNodes.ListDeletedEditable(ctx, owner)
+	mustNoErr(t, err)
+	if len(nodes) != 0 {
+		t.Fatalf("ListDeletedEditable lists %d nodes of a trashed document", len(nodes))
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		purged, err := b.workflowNodes.PurgeDeletedTx(ctx, tx, time.Now().Add(time.Minute))
+		mustNoErr(t, err)
+		if purged != 1 {
+			t.Fatalf("purged %d nodes, want 1", purged)
+		}
+	})
+	_, err = b.workflowNodes.GetDeletedByID(ctx, older.ID)
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+// ── Trash and ownership ────────────────────────────────────────────────────
+
+func testDocumentTrashAndPurge(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	other := b.user(t, ctx, "other@example.com")
+	older := b.doc(t, ctx, owner, "older", domain.VisibilityPrivate)
+	newer := b.doc(t, ctx, owner, "newer", domain.VisibilityPrivate)
+	live := b.doc(t, ctx, owner, "live", domain.VisibilityPrivate)
+	b.share(t, ctx, b.docShares, older.ID, other, domain.ShareRoleView)
+	b.node(t, ctx, older.ID, "child")
+	flow := b.flow(t, ctx, owner, "F", domain.FlowStatusDraft)
+
+	mustNoErr(t, b.docs.SoftDelete(ctx, older.ID))
+	tick()
+	mustNoErr(t, b.docs.SoftDelete(ctx, newer.ID))
+	mustNoErr(t, b.flows.SoftDelete(ctx, flow.ID))
+
+	docs, err := b.docs.ListDeletedByOwner(ctx, owner)
+	mustNoErr(t, err)
+	if len(docs) != 2 || docs[0].ID != newer.ID || docs[1].ID != older.ID {
+		t.Fatalf("ListDeletedByOwner = %s, want newest first", ids(docs, func(d domain.Document) uuid.UUID { return d.ID }))
+	}
+	if docs, err := b.docs.ListDeletedByOwner(ctx, other); err != nil || len(docs) != 0 {
+		t.Fatalf("other user's trash = %d documents (err %v)", len(docs), err)
+	}
+	flows, err := b.flows.ListDeletedByOwner(ctx, owner)
+	mustNoErr(t, err)
+	if len(flows) != 1 || flows[0].ID != flow.ID {
+		t.Fatalf("flows.ListDeletedByOwner = %d flows", len(flows))
+	}
+
+	for _, tc := range []struct {
+		name string
+		id   uuid.UUID
+		user uuid.UUID
+		want bool
+	}{{"not the owner", newer.ID, other, false}, {"not trashed", live.ID, owner, false}, {"owner", newer.ID, owner, true}} {
+		ok, err := b.docs.Restore(ctx, tc.id, tc.user)
+		mustNoErr(t, err)
+		if ok != tc.want {
+			t.Fatalf("Restore %s = %v, want %v", tc.name, ok, tc.want)
+		}
+	}
+	ok, err := b.flows.Restore(ctx, flow.ID, other)
+	mustNoErr(t, err)
+	if ok {
+		t.Fatalf("flows.Restore by another user succeeded")
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		purged, err := b.docs.PurgeDeletedTx(ctx, tx, time.Now().Add(time.Minute))
+		mustNoErr(t, err)
+		if purged != 1 {
+			t.Fatalf("purged %d documents, want 1", purged)
+		}
+		purged, err = b.flows.PurgeDeletedTx(ctx, tx, time.Now().Add(time.Minute))
+		mustNoErr(t, err)
+		if purged != 1 {
+			t.Fatalf("purged %d flows, want 1", purged)
+		}
+	})
+	if _, err := b.docs.GetByID(ctx, newer.ID); err != nil {
+		t.Fatalf("restored document: %v", err)
+	}
+	// Purged documents take their shares and nodes with them.
+	shares, err := b.docShares.ListByResource(ctx, older.ID)
+	mustNoErr(t, err)
+	nodes, err := b.workflowNodes.ListByDocument(ctx, older.ID)
+	mustNoErr(t, err)
+	if len(shares) != 0 || len(nodes) != 0 {
+		t.Fatalf("purged document left %d shares and %d nodes", len(shares), len(nodes))
+	}
+}
+
+func testOwnershipTransfer(t *testing.T, ctx context.Context, b *backend) {
+	ann := b.user(t, ctx, "ann@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	doc := b.doc(t, ctx, ann, "T", domain.VisibilityPrivate)
+	trashed := b.doc(t, ctx, ann, "trashed", domain.VisibilityPrivate)
+	mustNoErr(t, b.docs.SoftDelete(ctx, trashed.ID))
+	flow := b.flow(t, ctx, ann, "F", domain.FlowStatusDraft)
+	b.share(t, ctx, b.docShares, doc.ID, bob, domain.ShareRoleView)
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		docIDs, err := b.docs.ListIDsByOwnerTx(ctx, tx, ann)
+		mustNoErr(t, err)
+		flowIDs, err := b.flows.ListIDsByOwnerTx(ctx, tx, ann)
+		mustNoErr(t, err)
+		if len(docIDs) != 2 || len(flowIDs) != 1 {
+			t.Fatalf("ListIDsByOwnerTx = %d documents, %d flows; want trashed ones included", len(docIDs), len(flowIDs))
+		}
+
+		ok, err := b.docs.UpdateOwnerTx(ctx, tx, doc.ID, bob, ann)
+		mustNoErr(t, err)
+		if ok {
+			t.Fatalf("UpdateOwnerTx moved a document from a user who does not own it")
+		}
+		ok, err = b.docs.UpdateOwnerTx(ctx, tx, doc.ID, ann, bob)
+		mustNoErr(t, err)
+		ok2, err := b.flows.UpdateOwnerTx(ctx, tx, flow.ID, ann, bob)
+		mustNoErr(t, err)
+		if !ok || !ok2 {
+			t.Fatalf("UpdateOwnerTx = %v, %v", ok, ok2)
+		}
+		mustNoErr(t, b.docShares.DeleteByUserTx(ctx, tx, doc.ID, bob))
+		mustNoErr(t, b.docShares.CreateTx(ctx, tx, &domain.Share{ResourceID: doc.ID, UserID: ann, Role: domain.ShareRoleEdit}))
+		for _, res := range []struct {
+			typ domain.ShareResource
+			id  uuid.UUID
+		}{{domain.ShareResourceDocument, doc.ID}, {domain.ShareResourceDocument, trashed.ID}, {domain.ShareResourceFlow, flow.ID}} {
+			mustNoErr(t, b.transfers.CreateTx(ctx, tx, &domain.OwnershipTransfer{
+				ResourceType: res.typ, ResourceID: res.id, FromUserID: ann, ToUserID: bob, TransferredBy: ann, KeptEditShare: true,
+			}))
+			tick()
+		}
+	})
+
+	got, err := b.docs.GetByID(ctx, doc.ID)
+	mustNoErr(t, err)
+	if got.OwnerID != bob || got.Revision != doc.Revision+1 {
+		t.Fatalf("owner = %s at revision %d, want %s at %d", got.OwnerID, got.Revision, bob, doc.Revision+1)
+	}
+	gotFlow, err := b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+	if gotFlow.OwnerID != bob || gotFlow.Revision != flow.Revision+1 {
+		t.Fatalf("flow owner = %s at revision %d, want %s at %d", gotFlow.OwnerID, gotFlow.Revision, bob, flow.Revision+1)
+	}
+	shares, err := b.docShares.ListByResource(ctx, doc.ID)
+	mustNoErr(t, err)
+	if len(shares) != 1 || shares[0].UserID != ann || shares[0].Role != domain.ShareRoleEdit {
+		t.Fatalf("shares after transfer = %+v", shares)
+	}
+	history, err := b.transfers.ListByResource(ctx, domain.ShareResourceDocument, doc.ID)
+	mustNoErr(t, err)
+	if len(history) != 1 || history[0].ToUserID != bob || !history[0].KeptEditShare || history[0].TransferredBy != ann {
+		t.Fatalf("ListByResource = %+v", history)
+	}
+
+	// Once the trashed document is purged its history is an orphan.
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		_, err := b.docs.PurgeDeletedTx(ctx, tx, time.Now().Add(time.Minute))
+		mustNoErr(t, err)
+		mustNoErr(t, b.transfers.DeleteOrphansTx(ctx, tx))
+	})
+	for _, res := range []struct {
+		typ  domain.ShareResource
+		id   uuid.UUID
+		want int
+	}{{domain.ShareResourceDocument, doc.ID, 1}, {domain.ShareResourceDocument, trashed.ID, 0}, {domain.ShareResourceFlow, flow.ID, 1}} {
+		history, err := b.transfers.ListByResource(ctx, res.typ, res.id)
+		mustNoErr(t, err)
+		if len(history) != res.want {
+			t.Fatalf("%s %s has %d transfers after DeleteOrphansTx, want %d", res.typ, res.id, len(history), res.want)
+		}
+	}
+}
+
+func testDocumentConversions(t *testing.T, ctx context.Context, b *backend) {
+	owner := b.user(t, ctx, "owner@example.com")
+	first := b.doc(t, ctx, owner, "first", domain.VisibilityPrivate)
+	tick()
+	second := b.doc(t, ctx, owner, "second", domain.VisibilityPrivate)
+	mustNoErr(t, b.docs.SoftDelete(ctx, second.ID))
+	flow := b.flow(t, ctx, owner, "F", domain.FlowStatusDraft)
+
+	docs, err := b.conversions.ListDocuments(ctx)
+	mustNoErr(t, err)
+	if len(docs) != 2 || docs[0].ID != first.ID || docs[1].ID != second.ID {
+		t.Fatalf("ListDocuments = %s, want both oldest first", ids(docs, func(d domain.Document) uuid.UUID { return d.ID }))
+	}
+
+	versionID := uuid.New()
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		_, err := b.conversions.GetTx(ctx, tx, first.ID)
+		wantErr(t, err, domain.ErrNotFound)
+		mustNoErr(t, b.conversions.CreateTx(ctx, tx, &domain.DocumentConversion{DocumentID: first.ID, FlowID: flow.ID, VersionID: versionID}))
+		got, err := b.conversions.GetTx(ctx, tx, first.ID)
+		mustNoErr(t, err)
+		if got.FlowID != flow.ID || got.VersionID != versionID {
+			t.Fatalf("GetTx = %+v", got)
+		}
+	})
+	list, err := b.conversions.List(ctx)
+	mustNoErr(t, err)
+	if len(list) != 1 || list[0].DocumentID != first.ID || list[0].ConvertedAt.IsZero() {
+		t.Fatalf("List = %+v", list)
+	}
+
+	tx, err := b.txm.Begin(ctx)
+	mustNoErr(t, err)
+	defer tx.Rollback() //nolint:errcheck
+	dup := &domain.DocumentConversion{DocumentID: first.ID, FlowID: flow.ID, VersionID: versionID}
+	wantErr(t, b.conversions.CreateTx(ctx, tx, dup), domain.ErrAlreadyExists)
+}
+
+// ── Refresh tokens ─────────────────────────────────────────────────────────
+
+func (b *backend) refreshToken(t *testing.T, ctx context.Context, userID, family uuid.UUID, hash string, expires time.Time) *domain.RefreshToken {
+	t.Helper()
+	rt := &domain.RefreshToken{UserID: userID, FamilyID: family, TokenHash: hash, TokenVersion: 1, ExpiresAt: expires}
+	if err := b.tokens.Create(ctx, rt); err != nil {
+		t.Fatalf("creating refresh token: %v", err)
+	}
+	return rt
+}
+
+func testRefreshTokenLifecycle(t *testing.T, ctx context.Context, b *backend) {
+	user := b.user(t, ctx, "u@example.com")
+	family, other := uuid.New(), uuid.New()
+	expires := time.Now().Add(time.Hour)
+	first := b.refreshToken(t, ctx, user, family, strings.Repeat("a", 64), expires)
+	second := b.refreshToken(t, ctx, user, family, strings.Repeat("b", 64), expires)
+	elsewhere := b.refreshToken(t, ctx, user, other, strings.Repeat("c", 64), expires)
+
+	got, err := b.tokens.GetByHash(ctx, first.TokenHash)
+	mustNoErr(t, err)
+	if got.ID != first.ID || got.FamilyID != family || got.TokenVersion != 1 || !sameTime(got.ExpiresAt, expires) ||
+		got.UsedAt != nil || got.RevokedAt != nil {
+		t.Fatalf("got token %+v", got)
+	}
+	_, err = b.tokens.GetByHash(ctx, strings.Repeat("f", 64))
+	wantErr(t, err, domain.ErrNotFound)
+
+	// A token is consumed once.
+	ok, err := b.tokens.MarkUsed(ctx, first.ID, time.Now())
+	mustNoErr(t, err)
+	if !ok {
+		t.Fatalf("MarkUsed on a fresh token reported no change")
+	}
+	ok, err = b.tokens.MarkUsed(ctx, first.ID, time.Now())
+	mustNoErr(t, err)
+	if ok {
+		t.Fatalf("MarkUsed consumed a token twice")
+	}
+
+	mustNoErr(t, b.tokens.RevokeFamily(ctx, family, time.Now()))
+	for _, rt := range []*domain.RefreshToken{first, second} {
+		got, err := b.tokens.GetByHash(ctx, rt.TokenHash)
+		mustNoErr(t, err)
+		if got.RevokedAt == nil {
+			t.Fatalf("token %s survived revoking its family", rt.ID)
+		}
+	}
+	ok, err = b.tokens.MarkUsed(ctx, second.ID, time.Now())
+	mustNoErr(t, err)
+	if ok {
+		t.Fatalf("MarkUsed consumed a revoked token")
+	}
+	got, err = b.tokens.GetByHash(ctx, elsewhere.TokenHash)
+	mustNoErr(t, err)
+	if got.RevokedAt != nil {
+		t.Fatalf("revoking one family revoked another")
+	}
+}
+
+func testRefreshTokenDeleteExpired(t *testing.T, ctx context.Context, b *backend) {
+	user := b.user(t, ctx, "u@example.com")
+	now := time.Now()
+	expired := b.refreshToken(t, ctx, user, uuid.New(), strings.Repeat("a", 64), now.Add(-time.Minute))
+	live := b.refreshToken(t, ctx, user, uuid.New(), strings.Repeat("b", 64), now.Add(time.Hour))
+
+	n, err := b.tokens.DeleteExpired(ctx, now)
+	mustNoErr(t, err)
+	if n != 1 {
+		t.Fatalf("deleted %d tokens, want 1", n)
+	}
+	_, err = b.tokens.GetByHash(ctx, expired.TokenHash)
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.tokens.GetByHash(ctx, live.TokenHash)
+	mustNoErr(t, err)
+}
+
+// ── API tokens ─────────────────────────────────────────────────────────────
+
+func (b *backend) apiToken(t *testing.T, ctx context.Context, userID uuid.UUID, name, hash string, scopes ...domain.TokenScope) *domain.APIToken {
+	t.Helper()
+	at := &domain.APIToken{
+		UserID: userID, Name: name, TokenHash: hash, TokenPrefix: hash[:8],
+		Scopes: scopes, ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := b.apiTokens.Create(ctx, at); err != nil {
+		t.Fatalf("creating api token: %v", err)
+	}
+	return at
+}
+
+func testAPITokenLifecycle(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	ci := b.apiToken(t, ctx, alice, "ci", strings.Repeat("a", 64), domain.ScopeRead, domain.ScopeWrite)
+	tick()
+	backup := b.apiToken(t, ctx, alice, "backup", strings.Repeat("b", 64), domain.ScopeRead)
+	b.apiToken(t, ctx, bob, "bob", strings.Repeat("c", 64), domain.ScopeRead)
+
+	got, err := b.apiTokens.GetByHash(ctx, ci.TokenHash)
+	mustNoErr(t, err)
+	if got.ID != ci.ID || got.Name != "ci" || got.TokenPrefix != "aaaaaaaa" || !sameTime(got.ExpiresAt, ci.ExpiresAt) ||
+		got.LastUsedAt != nil || got.RevokedAt != nil {
+		t.Fatalf("got token %+v", got)
+	}
+	if len(got.Scopes) != 2 || got.Scopes[0] != domain.ScopeRead || got.Scopes[1] != domain.ScopeWrite {
+		t.Fatalf("scopes = %v, want [read write]", got.Scopes)
+	}
+	_, err = b.apiTokens.GetByHash(ctx, strings.Repeat("f", 64))
+	wantErr(t, err, domain.ErrNotFound)
+
+	used := time.Now()
+	mustNoErr(t, b.apiTokens.TouchLastUsed(ctx, ci.ID, used))
+	got, err = b.apiTokens.GetByHash(ctx, ci.TokenHash)
+	mustNoErr(t, err)
+	if got.LastUsedAt == nil || !sameTime(*got.LastUsedAt, used) {
+		t.Fatalf("last_used_at = %v, want %s", got.LastUsedAt, used)
+	}
+
+	list, err := b.apiTokens.ListByUser(ctx, alice)
+	mustNoErr(t, err)
+	if len(list) != 2 || list[0].ID != backup.ID || list[1].ID != ci.ID {
+		t.Fatalf("listed %d tokens, want backup then ci", len(list))
+	}
+
+	// Only the owner can revoke, and only once.
+	wantErr(t, b.apiTokens.Revoke(ctx, ci.ID, bob, time.Now()), domain.ErrNotFound)
+	mustNoErr(t, b.apiTokens.Revoke(ctx, ci.ID, alice, time.Now()))
+	wantErr(t, b.apiTokens.Revoke(ctx, ci.ID, alice, time.Now()), domain.ErrNotFound)
+	got, err = b.apiTokens.GetByHash(ctx, ci.TokenHash)
+	mustNoErr(t, err)
+	if got.RevokedAt == nil {
+		t.Fatalf("revoked token has no revoked_at")
+	}
+	list, err = b.apiTokens.ListByUser(ctx, alice)
+	mustNoErr(t, err)
+	if len(list) != 1 || list[0].ID != backup.ID {
+		t.Fatalf("revoked token still listed")
+	}
+}
+
+// ── Login security ─────────────────────────────────────────────────────────
+
+func testLoginThrottleLifecycle(t *testing.T, ctx context.Context, b *backend) {
+	start := time.Now().Add(-time.Hour)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	_, err := b.throttles.Get(ctx, "account:a@example.com")
+	wantErr(t, err, domain.ErrNotFound)
+
+	// Failures within the window add up; one after it starts over.
+	for i, want := range []int{1, 2, 3} {
+		n, err := b.throttles.RecordFailure(ctx, "account:a@example.com", at(i), at(i-15))
+		mustNoErr(t, err)
+		if n != want {
+			t.Fatalf("failure %d: count %d, want %d", i+1, n, want)
+		}
+	}
+	got, err := b.throttles.Get(ctx, "account:a@example.com")
+	mustNoErr(t, err)
+	if got.Failures != 3 || !sameTime(got.LastFailureAt, at(2)) {
+		t.Fatalf("got throttle %+v", got)
+	}
+	n, err := b.throttles.RecordFailure(ctx, "account:a@example.com", at(30), at(15))
+	mustNoErr(t, err)
+	if n != 1 {
+		t.Fatalf("failure after window: count %d, want 1", n)
+	}
+
+	_, err = b.throttles.RecordFailure(ctx, "ip:192.0.2.1", at(10), at(-5))
+	mustNoErr(t, err)
+	_, err = b.throttles.RecordFailure(ctx, "ip:192.0.2.2", at(20), at(5))
+	mustNoErr(t, err)
+
+	list, err := b.throttles.List(ctx, at(10))
+	mustNoErr(t, err)
+	var keys []string
+	for _, lt := range list {
+		keys = append(keys, lt.Key)
+	}
+	if strings.Join(keys, ",") != "account:a@example.com,ip:192.0.2.2,ip:192.0.2.1" {
+		t.Fatalf("List = %v, want newest first from minute 10", keys)
+	}
+
+	mustNoErr(t, b.throttles.Delete(ctx, "ip:192.0.2.2"))
+	wantErr(t, b.throttles.Delete(ctx, "ip:192.0.2.2"), domain.ErrNotFound)
+
+	removed, err := b.throttles.DeleteStale(ctx, at(15))
+	mustNoErr(t, err)
+	if removed != 1 {
+		t.Fatalf("DeleteStale removed %d, want 1", removed)
+	}
+	_, err = b.throttles.Get(ctx, "ip:192.0.2.1")
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = b.throttles.Get(ctx, "account:a@example.com")
+	mustNoErr(t, err)
+}
+
+func testPasswordHistoryTrim(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	mustNoErr(t, b.pwHistory.Add(ctx, bob, "b1", 3))
+	for _, hash := range []string{"a1", "a2", "a3", "a4"} {
+		tick()
+		mustNoErr(t, b.pwHistory.Add(ctx, alice, hash, 3))
+	}
+
+	got, err := b.pwHistory.ListRecent(ctx, alice, 10)
+	mustNoErr(t, err)
+	if strings.Join(got, ",") != "a4,a3,a2" {
+		t.Fatalf("history = %v, want the newest three", got)
+	}
+	got, err = b.pwHistory.ListRecent(ctx, alice, 2)
+	mustNoErr(t, err)
+	if strings.Join(got, ",") != "a4,a3" {
+		t.Fatalf("ListRecent(2) = %v", got)
+	}
+	got, err = b.pwHistory.ListRecent(ctx, bob, 10)
+	mustNoErr(t, err)
+	if strings.Join(got, ",") != "b1" {
+		t.Fatalf("other user's history = %v", got)
+	}
+}
+
+func testRecoveryCodes(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	mustNoErr(t, b.recovery.Replace(ctx, alice, []string{"a1", "a2", "a3"}))
+	mustNoErr(t, b.recovery.Replace(ctx, bob, []string{"b1"}))
+
+	count := func(user uuid.UUID, want int) {
+		t.Helper()
+		n, err := b.recovery.CountUnused(ctx, user)
+		mustNoErr(t, err)
+		if n != want {
+			t.Fatalf("CountUnused = %d, want %d", n, want)
+		}
+	}
+
+	for _, c := range []struct {
+		user uuid.UUID
+		hash string
+		want bool
+	}{{alice, "a1", true}, {alice, "a1", false}, {alice, "b1", false}, {bob, "b1", true}} {
+		ok, err := b.recovery.Use(ctx, c.user, c.hash, time.Now())
+		mustNoErr(t, err)
+		if ok != c.want {
+			t.Fatalf("Use(%s) = %t, want %t", c.hash, ok, c.want)
+		}
+	}
+	count(alice, 2)
+	count(bob, 0)
+
+	// A new set replaces the old one, used codes included.
+	mustNoErr(t, b.recovery.Replace(ctx, alice, []string{"n1"}))
+	count(alice, 1)
+	ok, err := b.recovery.Use(ctx, alice, "a2", time.Now())
+	mustNoErr(t, err)
+	if ok {
+		t.Fatalf("replaced code still usable")
+	}
+	mustNoErr(t, b.recovery.Replace(ctx, alice, nil))
+	count(alice, 0)
+}
+
+func testSettings(t *testing.T, ctx context.Context, b *backend) {
+	_, err := b.settings.Get(ctx, "k")
+	wantErr(t, err, domain.ErrNotFound)
+	mustNoErr(t, b.settings.Set(ctx, "k", "one"))
+	mustNoErr(t, b.settings.Set(ctx, "k", "two"))
+	// Writing the same value again succeeds too (MySQL reports no affected rows).
+	mustNoErr(t, b.settings.Set(ctx, "k", "two"))
+	got, err := b.settings.Get(ctx, "k")
+	mustNoErr(t, err)
+	if got != "two" {
+		t.Fatalf("Get = %q, want two", got)
+	}
+}
+
+// ── Single sign-on ─────────────────────────────────────────────────────────
+
+func testUserIdentities(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	_, err := b.identities.Get(ctx, "https://idp", "a")
+	wantErr(t, err, domain.ErrNotFound)
+
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: alice, Provider: "https://idp", Subject: "a"}))
+	got, err := b.identities.Get(ctx, "https://idp", "a")
+	mustNoErr(t, err)
+	if got.UserID != alice {
+		t.Fatalf("identity belongs to %s, want alice", got.UserID)
+	}
+
+	// A subject links one user, and a user has one identity per provider.
+	wantErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: bob, Provider: "https://idp", Subject: "a"}), domain.ErrAlreadyExists)
+	wantErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: alice, Provider: "https://idp", Subject: "b"}), domain.ErrAlreadyExists)
+	// The same subject at another provider is someone else.
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: bob, Provider: "https://other", Subject: "a"}))
+	got, err = b.identities.Get(ctx, "https://other", "a")
+	mustNoErr(t, err)
+	if got.UserID != bob {
+		t.Fatalf("identity belongs to %s, want bob", got.UserID)
+	}
+}
+
+func testUserIdentitiesByProvider(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: alice, Provider: "scim", Subject: "ext-a"}))
+	tick()
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: bob, Provider: "scim", Subject: "ext-b"}))
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: alice, Provider: "ldap", Subject: "uuid-a"}))
+
+	got, err := b.identities.GetByUser(ctx, alice, "scim")
+	mustNoErr(t, err)
+	if got.Subject != "ext-a" {
+		t.Fatalf("GetByUser returned %+v, want ext-a", got)
+	}
+	_, err = b.identities.GetByUser(ctx, bob, "ldap")
+	wantErr(t, err, domain.ErrNotFound)
+
+	list, err := b.identities.ListByProvider(ctx, "scim")
+	mustNoErr(t, err)
+	if len(list) != 2 || list[0].UserID != alice || list[1].UserID != bob {
+		t.Fatalf("ListByProvider returned %+v, want alice then bob", list)
+	}
+
+	mustNoErr(t, b.identities.Delete(ctx, got.ID))
+	mustNoErr(t, b.identities.Delete(ctx, got.ID))
+	_, err = b.identities.Get(ctx, "scim", "ext-a")
+	wantErr(t, err, domain.ErrNotFound)
+	// The subject is free for another user once unlinked.
+	mustNoErr(t, b.identities.Create(ctx, &domain.UserIdentity{UserID: bob, Provider: "https://idp", Subject: "ext-a"}))
+}
+
+func testOIDCLogins(t *testing.T, ctx context.Context, b *backend) {
+	now := time.Now()
+	mustNoErr(t, b.oidcLogins.Create(ctx, &domain.OIDCLogin{State: "s1", Nonce: "n1", CodeVerifier: "v1", ExpiresAt: now.Add(time.Minute)}))
+	mustNoErr(t, b.oidcLogins.Create(ctx, &domain.OIDCLogin{State: "s2", Nonce: "n2", CodeVerifier: "v2", ExpiresAt: now.Add(-time.Minute)}))
+
+	got, err := b.oidcLogins.Take(ctx, "s1")
+	mustNoErr(t, err)
+	if got.Nonce != "n1" || got.CodeVerifier != "v1" || !sameTime(got.ExpiresAt, now.Add(time.Minute)) {
+		t.Fatalf("Take = %+v", got)
+	}
+	_, err = b.oidcLogins.Take(ctx, "s1")
+	wantErr(t, err, domain.ErrNotFound)
+
+	n, err := b.oidcLogins.DeleteExpired(ctx, now)
+	mustNoErr(t, err)
+	if n != 1 {
+		t.Fatalf("DeleteExpired = %d, want 1", n)
+	}
+	_, err = b.oidcLogins.Take(ctx, "s2")
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+// ── Groups ─────────────────────────────────────────────────────────────────
+
+func testGroupCRUD(t *testing.T, ctx context.Context, b *backend) {
+	eng := &domain.Group{Name: "Engineering", ExternalID: "ext-1"}
+	mustNoErr(t, b.groups.Create(ctx, eng))
+	ops := &domain.Group{Name: "Ops"}
+	mustNoErr(t, b.groups.Create(ctx, ops))
+	wantErr(t, b.groups.Create(ctx, &domain.Group{Name: "Engineering"}), domain.ErrAlreadyExists)
+
+	got, err := b.groups.GetByID(ctx, eng.ID)
+	mustNoErr(t, err)
+	if got.Name != "Engineering" || got.ExternalID != "ext-1" || !sameTime(got.CreatedAt, eng.CreatedAt) {
+		t.Fatalf("got group %+v", got)
+	}
+	_, err = b.groups.GetByID(ctx, uuid.New())
+	wantErr(t, err, domain.ErrNotFound)
+
+	tick()
+	eng.Name, eng.ExternalID = "R&D", ""
+	mustNoErr(t, b.groups.Update(ctx, eng))
+	got, err = b.groups.GetByID(ctx, eng.ID)
+	mustNoErr(t, err)
+	if got.Name != "R&D" || got.ExternalID != "" || !got.UpdatedAt.After(got.CreatedAt) {
+		t.Fatalf("after update: %+v", got)
+	}
+	ops.Name = "R&D"
+	wantErr(t, b.groups.Update(ctx, ops), domain.ErrAlreadyExists)
+
+	groups, err := b.groups.List(ctx)
+	mustNoErr(t, err)
+	if len(groups) != 2 || groups[0].Name != "Ops" || groups[1].Name != "R&D" {
+		t.Fatalf("List returned %+v, want Ops then R&D", groups)
+	}
+
+	mustNoErr(t, b.groups.Delete(ctx, eng.ID))
+	_, err = b.groups.GetByID(ctx, eng.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	wantErr(t, b.groups.Delete(ctx, eng.ID), domain.ErrNotFound)
+}
+
+func testGroupMembers(t *testing.T, ctx context.Context, b *backend) {
+	alice := b.user(t, ctx, "alice@example.com")
+	bob := b.user(t, ctx, "bob@example.com")
+	eng := &domain.Group{Name: "eng"}
+	mustNoErr(t, b.groups.Create(ctx, eng))
+	ops := &domain.Group{Name: "ops"}
+	mustNoErr(t, b.groups.Create(ctx, ops))
+
+	mustNoErr(t, b.groups.AddMember(ctx, eng.ID, alice, domain.GroupRoleOwner))
+	tick()
+	mustNoErr(t, b.groups.AddMember(ctx, eng.ID, bob, domain.GroupRoleMember))
+	// Adding a member twice is not an error and keeps their role.
+	mustNoErr(t, b.groups.AddMember(ctx, eng.ID, alice, domain.GroupRoleMember))
+	tick()
+	mustNoErr(t, b.groups.AddMember(ctx, ops.ID, bob, domain.GroupRoleMember))
+
+	members, err := b.groups.ListMembers(ctx, eng.ID)
+	mustNoErr(t, err)
+	if len(members) != 2 || members[0].UserID != alice || members[1].UserID != bob {
+		t.Fatalf("eng members %+v, want alice then bob", members)
+	}
+	if members[0].Role != domain.GroupRoleOwner || members[1].Role != domain.GroupRoleMember {
+		t.Fatalf("eng roles %+v, want OWNER then MEMBER", members)
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		mustNoErr(t, b.groups.UpdateMemberRoleTx(ctx, tx, eng.ID, bob, domain.GroupRoleAdmin))
+		owners, err := b.groups.ListOwnersTx(ctx, tx, eng.ID)
+		mustNoErr(t, err)
+		if len(owners) != 1 || owners[0] != alice {
+			t.Fatalf("ListOwnersTx = %v, want alice", owners)
+		}
+	})
+	got, err := b.groups.GetMember(ctx, eng.ID, bob)
+	mustNoErr(t, err)
+	if got.Role != domain.GroupRoleAdmin || got.GroupID != eng.ID || got.UserID != bob {
+		t.Fatalf("got member %+v after UpdateMemberRoleTx", got)
+	}
+	_, err = b.groups.GetMember(ctx, ops.ID, alice)
+	wantErr(t, err, domain.ErrNotFound)
+	all, err := b.groups.ListMemberships(ctx)
+	mustNoErr(t, err)
+	if len(all) != 3 {
+		t.Fatalf("ListMemberships returned %d rows, want 3", len(all))
+	}
+
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		mustNoErr(t, b.groups.RemoveMemberTx(ctx, tx, eng.ID, alice))
+	})
+	mustNoErr(t, b.groups.RemoveMember(ctx, eng.ID, alice))
+	members, err = b.groups.ListMembers(ctx, eng.ID)
+	mustNoErr(t, err)
+	if len(members) != 1 || members[0].UserID != bob {
+		t.Fatalf("eng members after removal %+v, want bob", members)
+	}
+
+	// Memberships go with their group.
+	mustNoErr(t, b.groups.Delete(ctx, eng.ID))
+	all, err = b.groups.ListMemberships(ctx)
+	mustNoErr(t, err)
+	if len(all) != 1 || all[0].GroupID != ops.ID {
+		t.Fatalf("memberships after deleting eng: %+v", all)
+	}
+}
+
+// ── Signing keys ───────────────────────────────────────────────────────────
+
+func testSigningKeys(t *testing.T, ctx context.Context, b *backend) {
+	now := time.Now()
+	next := &domain.SigningKey{ID: "k2", Algorithm: "EdDSA", PrivateKey: "pem-2", ActivatesAt: now.Add(time.Hour)}
+	mustNoErr(t, b.signingKeys.Create(ctx, next))
+	mustNoErr(t, b.signingKeys.Create(ctx, &domain.SigningKey{ID: "k1", Algorithm: "RS256", PrivateKey: "pem-1", ActivatesAt: now}))
+	wantErr(t, b.signingKeys.Create(ctx, &domain.SigningKey{ID: "k1", Algorithm: "EdDSA", PrivateKey: "x", ActivatesAt: now}), domain.ErrAlreadyExists)
+
+	keys, err := b.signingKeys.List(ctx)
+	mustNoErr(t, err)
+	if len(keys) != 2 || keys[0].ID != "k1" || keys[1].ID != "k2" {
+		t.Fatalf("List = %+v", keys)
+	}
+	if k := keys[1]; k.Algorithm != "EdDSA" || k.PrivateKey != "pem-2" || !sameTime(k.ActivatesAt, next.ActivatesAt) || !sameTime(k.CreatedAt, next.CreatedAt) {
+		t.Fatalf("got key %+v", k)
+	}
+
+	mustNoErr(t, b.signingKeys.Delete(ctx, "k1"))
+	mustNoErr(t, b.signingKeys.Delete(ctx, "k1"))
+	keys, err = b.signingKeys.List(ctx)
+	mustNoErr(t, err)
+	if len(keys) != 1 || keys[0].ID != "k2" {
+		t.Fatalf("List after delete = %+v", keys)
+	}
+}
+
+// ── Timestamps ─────────────────────────────────────────────────────────────
+
+// testTimestampRoundTrip checks that times written by the repositories read
+// back as the same instant, whatever column type and zone the driver uses.
+func testTimestampRoundTrip(t *testing.T, ctx context.Context, b *backend) {
+	user := &domain.User{Email: "owner@example.com", PasswordHash: "h"}
+	mustNoErr(t, b.users.Create(ctx, user))
+	owner := user.ID
+	gotUser, err := b.users.GetByID(ctx, owner)
+	mustNoErr(t, err)
+
+	doc := b.doc(t, ctx, owner, "T", domain.VisibilityPrivate)
+	gotDoc, err := b.docs.GetByID(ctx, doc.ID)
+	mustNoErr(t, err)
+
+	// A preset created_at far from now, in a non-UTC zone, survives as the
+	// same instant.
+	zone := time.FixedZone("UTC+8", 8*60*60)
+	createdAt := time.Date(2021, 12, 31, 23, 59, 58, 123456000, zone)
+	flow := &domain.Flow{OwnerID: owner, FlowNo: "FLOW-2021-0001", Title: "T", Status: domain.FlowStatusDraft, CreatedAt: createdAt}
+	b.inTx(t, ctx, func(tx repository.Tx) { mustNoErr(t, b.flows.CreateTx(ctx, tx, flow)) })
+	gotFlow, err := b.flows.GetByID(ctx, flow.ID)
+	mustNoErr(t, err)
+
+	checks := []struct {
+		name      string
+		got, want time.Time
+	}{
+		{"users.created_at", gotUser.CreatedAt, user.CreatedAt},
+		{"documents.created_at", gotDoc.CreatedAt, doc.CreatedAt},
+		{"documents.updated_at", gotDoc.UpdatedAt, doc.UpdatedAt},
+		{"flows.created_at (preset, UTC+8)", gotFlow.CreatedAt, createdAt},
+		{"flows.updated_at", gotFlow.UpdatedAt, flow.UpdatedAt},
+	}
+	for _, c := range checks {
+		if !sameTime(c.got, c.want) {
+			t.Errorf("%s: got %s, want %s", c.name, c.got.Format(time.RFC3339Nano), c.want.Format(time.RFC3339Nano))
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+	"docmv/internal/repository/memory"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// The contract suite runs every case in contractCases against each backend:
+// the in-memory store, SQLite (always available, pure Go) and PostgreSQL and
+// MySQL when their DSN variables are set. scripts/test_drivers.sh starts
+// throwaway containers for the latter two and sets the variables.
+//
+// When a case passes on some backends and fails on others, the suite ends
+// with a matrix naming the case and where it diverges, so driver-specific
+// behavior shows up as such rather than as an isolated failure.
+
+// backend is one implementation of the repository interfaces.
+type backend struct {
+	name            string
+	txm             repository.TxManager
+	users           repository.UserRepository
+	docs            repository.DocumentRepository
+	versions        repository.VersionRepository
+	flows           repository.FlowRepository
+	flowNodes       repository.FlowNodeRepository
+	flowVersions    repository.FlowVersionRepository
+	docShares       repository.ShareRepository
+	flowShares      repository.ShareRepository
+	docGroupShares  repository.GroupShareRepository
+	flowGroupShares repository.GroupShareRepository
+	tokens          repository.RefreshTokenRepository
+	apiTokens       repository.APITokenRepository
+	throttles       repository.LoginThrottleRepository
+	pwHistory       repository.PasswordHistoryRepository
+	recovery        repository.RecoveryCodeRepository
+	settings        repository.SettingRepository
+	identities      repository.UserIdentityRepository
+	oidcLogins      repository.OIDCLoginRepository
+	groups          repository.GroupRepository
+	signingKeys     repository.SigningKeyRepository
+	workflowNodes   repository.WorkflowNodeRepository
+	transfers       repository.OwnershipTransferRepository
+	conversions     repository.DocumentConversionRepository
+}
+
+type driver struct {
+	name string
+	env  string // DSN variable; empty for backends that need no server
+	// open prepares the driver once and returns a function giving each case
+	// an empty backend.
+	open func(t *testing.T) func(t *testing.T) *backend
+}
+
+var drivers = []driver{
+	{name: "memory", open: openMemory},
+	{name: "sqlite", open: func(t *testing.T) func(t *testing.T) *backend {
+		return openSQL(t, "sqlite", "file:"+filepath.Join(t.TempDir(), "contract.db"))
+	}},
+	{name: "postgres", env: "DOCMV_TEST_POSTGRES_DSN"},
+	{name: "mysql", env: "DOCMV_TEST_MYSQL_DSN"},
+}
+
+type contractCase struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, b *backend)
+}
+
+func TestRepositoryContract(t *testing.T) {
+	ctx := context.Background()
+	results := make(map[string]map[string]bool) // case → driver → passed
+	var ran []string
+
+	for _, d := range drivers {
+		d := d
+		t.Run(d.name, func(t *testing.T) {
+			open := d.open
+			if d.env != "" {
+				dsn := os.Getenv(d.env)
+				if dsn == "" {
+					t.Skipf("%s not set; run scripts/test_drivers.sh to test against a %s container", d.env, d.name)
+				}
+				open = func(t *testing.T) func(t *testing.T) *backend { return openSQL(t, d.name, dsn) }
+			}
+			fresh := open(t)
+			ran = append(ran, d.name)
+			for _, c := range contractCases {
+				c := c
+				passed := t.Run(c.name, func(t *testing.T) { c.run(t, ctx, fresh(t)) })
+				if results[c.name] == nil {
+					results[c.name] = make(map[string]bool)
+				}
+				results[c.name][d.name] = passed
+			}
+		})
+	}
+
+	reportDivergence(t, ran, results)
+}
+
+// reportDivergence lists the cases whose outcome depends on the backend.
+func reportDivergence(t *testing.T, ran []string, results map[string]map[string]bool) {
+	var lines []string
+	for name, byDriver := range results {
+		var pass, fail []string
+		for _, d := range ran {
+			if byDriver[d] {
+				pass = append(pass, d)
+			} else {
+				fail = append(fail, d)
+			}
+		}
+		if len(pass) > 0 && len(fail) > 0 {
+			lines = append(lines, fmt.Sprintf("  %-40s passes on %-24s fails on %s",
+				name, strings.Join(pass, ","), strings.Join(fail, ",")))
+		}
+	}
+	if len(lines) == 0 {
+		t.Logf("contract ran on %s; no driver-specific differences", strings.Join(ran, ", "))
+		return
+	}
+	sort.Strings(lines)
+	t.Errorf("driver-specific behavior:\n%s", strings.Join(lines, "\n"))
+}
+
+func openMemory(*testing.T) func(t *testing.T) *backend {
+	return func(*testing.T) *backend {
+		s := memory.NewStore()
+		return &backend{
+			name:            "memory",
+			txm:             s,
+			users:           memory.NewUserRepo(s),
+			docs:            memory.NewDocumentRepo(s),
+			versions:        memory.NewVersionRepo(s),
+			flows:           memory.NewFlowRepo(s),
+			flowNodes:       memory.NewFlowNodeRepo(s),
+			flowVersions:    memory.NewFlowVersionRepo(s),
+			docShares:       memory.NewDocumentShareRepo(s),
+			flowShares:      memory.NewFlowShareRepo(s),
+			docGroupShares:  memory.NewDocumentGroupShareRepo(s),
+			flowGroupShares: memory.NewFlowGroupShareRepo(s),
+			tokens:          memory.NewRefreshTokenRepo(s),
+			apiTokens:       memory.NewAPITokenRepo(s),
+			throttles:       memory.NewLoginThrottleRepo(s),
+			pwHistory:       memory.NewPasswordHistoryRepo(s),
+			recovery:        memory.NewRecoveryCodeRepo(s),
+			settings:        memory.NewSettingRepo(s),
+			identities:      memory.NewUserIdentityRepo(s),
+			oidcLogins:      memory.NewOIDCLoginRepo(s),
+			groups:          memory.NewGroupRepo(s),
+			signingKeys:     memory.NewSigningKeyRepo(s),
+			workflowNodes:   memory.NewWorkflowNodeRepo(s),
+			transfers:       memory.NewOwnershipTransferRepo(s),
+			conversions:     memory.NewDocumentConversionRepo(s),
+		}
+	}
+}
+
+// contractTables lists every table, children before parents, so that
+// deleting in this order empties the schema without tripping foreign keys.
+var contractTables = []string{
+	"signing_keys", "flow_group_shares", "document_group_shares", "user_group_members", "user_groups", "oidc_logins", "user_identities", "app_settings", "recovery_codes", "login_throttles", "password_history", "api_tokens", "refresh_tokens", "document_conversions", "ownership_transfers",
+	"flow_shares", "flow_versions", "flow_nodes", "flows",
+	"workflow_nodes", "document_shares", "document_versions", "documents",
+	"users",
+}
+
+// openSQL connects and migrates once; each case then starts from empty tables.
+func openSQL(t *testing.T, driverName, dsn string) func(t *testing.T) *backend {
+	db, err := repository.NewDB(driverName, dsn)
+	if err != nil {
+		t.Fatalf("opening %s: %v", driverName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := repository.NewMigrator(db, driverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating %s: %v", driverName, err)
+	}
+
+	return func(t *testing.T) *backend {
+		for _, table := range contractTables {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("emptying %s: %v", table, err)
+			}
+		}
+		return sqlBackend(driverName, db)
+	}
+}
+
+func sqlBackend(name string, db *sqlx.DB) *backend {
+	return &backend{
+		name:            name,
+		txm:             repository.NewTxManager(db),
+		users:           repository.NewUserRepo(db),
+		docs:            repository.NewDocumentRepo(db),
+		versions:        repository.NewVersionRepo(db),
+		flows:           repository.NewFlowRepo(db),
+		flowNodes:       repository.NewFlowNodeRepo(db),
+		flowVersions:    repository.NewFlowVersionRepo(db),
+		docShares:       repository.NewDocumentShareRepo(db),
+		flowShares:      repository.NewFlowShareRepo(db),
+		docGroupShares:  repository.NewDocumentGroupShareRepo(db),
+		flowGroupShares: repository.NewFlowGroupShareRepo(db),
+		tokens:          repository.NewRefreshTokenRepo(db),
+		apiTokens:       repository.NewAPITokenRepo(db),
+		throttles:       repository.NewLoginThrottleRepo(db),
+		pwHistory:       repository.NewPasswordHistoryRepo(db),
+		recovery:        repository.NewRecoveryCodeRepo(db),
+		settings:        repository.NewSettingRepo(db),
+		identities:      repository.NewUserIdentityRepo(db),
+		oidcLogins:      repository.NewOIDCLoginRepo(db),
+		groups:          repository.NewGroupRepo(db),
+		signingKeys:     repository.NewSigningKeyRepo(db),
+		workflowNodes:   repository.NewWorkflowNodeRepo(db),
+		transfers:       repository.NewOwnershipTransferRepo(db),
+		conversions:     repository.NewDocumentConversionRepo(db),
+	}
+}
+
+// ── Fixtures ───────────────────────────────────────────────────────────────
+
+// inTx runs fn in a unit of work and commits it.
+func (b *backend) inTx(t *testing.T, ctx context.Context, fn func(tx repository.Tx)) {
+	t.Helper()
+	tx, err := b.txm.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+	fn(tx)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+}
+
+func (b *backend) user(t *testing.T, ctx context.Context, email string) uuid.UUID {
+	t.Helper()
+	u := &domain.User{Email: email, PasswordHash: "hash-" + email}
+	if err := b.users.Create(ctx, u); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return u.ID
+}
+
+func (b *backend) doc(t *testing.T, ctx context.Context, owner uuid.UUID, title string, vis domain.Visibility) *domain.Document {
+	t.Helper()
+	doc := &domain.Document{OwnerID: owner, Title: title, Visibility: vis}
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		if err := b.docs.CreateTx(ctx, tx, doc); err != nil {
+			t.Fatalf("creating document: %v", err)
+		}
+	})
+	return doc
+}
+
+func (b *backend) flow(t *testing.T, ctx context.Context, owner uuid.UUID, title string, status domain.FlowStatus) *domain.Flow {
+	t.Helper()
+	flow := &domain.Flow{OwnerID: owner, Title: title, Status: status}
+	b.inTx(t, ctx, func(tx repository.Tx) {
+		no, err := b.flows.NextFlowNoTx(ctx, tx)
+		if err != nil {
+			t.Fatalf("next flow number: %v", err)
+		}
+		flow.FlowNo = no
+		if err := b.flows.CreateTx(ctx, tx, flow); err != nil {
+			t.Fatalf("creating flow: %v", err)
+		}
+	})
+	return flow
+}
+
+func (b *backend) share(t *testing.T, ctx context.Context, repo repository.ShareRepository, resourceID, userID uuid.UUID, role domain.ShareRole) *domain.Share {
+	t.Helper()
+	sh := &domain.Share{ResourceID: resourceID, UserID: userID, Role: role}
+	if err := repo.Create(ctx, sh); err != nil {
+		t.Fatalf("creating share: %v", err)
+	}
+	return sh
+}
+
+// group creates a group with the given members.
+func (b *backend) group(t *testing.T, ctx context.Context, name string, members ...uuid.UUID) *domain.Group {
+	t.Helper()
+	g := &domain.Group{Name: name}
+	if err := b.groups.Create(ctx, g); err != nil {
+		t.Fatalf("creating group %s: %v", name, err)
+	}
+	for _, userID := range members {
+		if err := b.groups.AddMember(ctx, g.ID, userID, domain.GroupRoleMember); err != nil {
+			t.Fatalf("adding member to %s: %v", name, err)
+		}
+	}
+	return g
+}
+
+func (b *backend) groupShare(t *testing.T, ctx context.Context, repo repository.GroupShareRepository, resourceID, groupID uuid.UUID, role domain.ShareRole) *domain.GroupShare {
+	t.Helper()
+	sh := &domain.GroupShare{ResourceID: resourceID, GroupID: groupID, Role: role}
+	if err := repo.Create(ctx, sh); err != nil {
+		t.Fatalf("creating group share: %v", err)
+	}
+	return sh
+}
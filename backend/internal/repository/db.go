@@ -1,21 +1,33 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
+func init() {
+	// sqlx only knows the bindvar style of drivers registered as "sqlite3".
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
 // NewDB opens a database connection pool.
-// driver must be "mysql" or "postgres".
+// driver must be "mysql", "postgres" or "sqlite".
 func NewDB(driver, dsn string) (*sqlx.DB, error) {
 	switch driver {
 	case "mysql", "postgres":
 		// ok
+	case "sqlite":
+		dsn = sqliteDSN(dsn)
 	default:
-		return nil, fmt.Errorf("unsupported DB_DRIVER %q (want mysql or postgres)", driver)
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (want mysql, postgres or sqlite)", driver)
 	}
 
 	db, err := sqlx.Connect(driver, dsn)
@@ -26,3 +38,74 @@ func NewDB(driver, dsn string) (*sqlx.DB, error) {
 	db.SetMaxIdleConns(5)
 	return db, nil
 }
+
+// sqliteDefaults are applied to a SQLite DSN unless it sets them itself:
+// enforce foreign keys, wait for locks instead of failing with SQLITE_BUSY,
+// let readers run alongside the writer, take the write lock when a
+// transaction begins (so two transactions never deadlock upgrading from a
+// read lock), and store times in a lexically ordered format.
+var sqliteDefaults = []struct{ key, value string }{
+	{"_pragma", "foreign_keys(1)"},
+	{"_pragma", "busy_timeout(5000)"},
+	{"_pragma", "journal_mode(WAL)"},
+	{"_txlock", "immediate"},
+	{"_time_format", "sqlite"},
+}
+
+// sqliteDSN adds sqliteDefaults to dsn, which is a file name or file: URI.
+func sqliteDSN(dsn string) string {
+	base, rawQuery, _ := strings.Cut(dsn, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return dsn // let the driver report it
+	}
+	for _, d := range sqliteDefaults {
+		if d.key == "_pragma" {
+			name, _, _ := strings.Cut(d.value, "(")
+			if hasPragma(query["_pragma"], name) {
+				continue
+			}
+		} else if query.Has(d.key) {
+			continue
+		}
+		query.Add(d.key, d.value)
+	}
+	return base + "?" + query.Encode()
+}
+
+func hasPragma(pragmas []string, name string) bool {
+	for _, p := range pragmas {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(p)), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUniqueViolation reports whether err is a unique-constraint violation.
+func isUniqueViolation(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		code := liteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+// forUpdate turns a SELECT run in tx into a locking read. SQLite has no
+// FOR UPDATE; there a transaction takes the write lock when it begins (see
+// _txlock in sqliteDefaults), which already keeps writers apart.
+func forUpdate(tx *sqlx.Tx, query string) string {
+	if tx.DriverName() == "sqlite" {
+		return query
+	}
+	return query + ` FOR UPDATE`
+}
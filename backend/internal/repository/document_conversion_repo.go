@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// DocumentConversionRepo records which flow each document was converted into.
+type DocumentConversionRepo struct {
+	db *sqlx.DB
+}
+
+func NewDocumentConversionRepo(db *sqlx.DB) *DocumentConversionRepo {
+	return &DocumentConversionRepo{db: db}
+}
+
+// ListDocuments returns every document, including trashed ones, oldest first.
+func (r *DocumentConversionRepo) ListDocuments(ctx context.Context) ([]domain.Document, error) {
+	docs := make([]domain.Document, 0)
+	if err := r.db.SelectContext(ctx, &docs, `SELECT * FROM documents ORDER BY created_at ASC`); err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+	return docs, nil
+}
+
+// GetTx returns the conversion record of a document, or domain.ErrNotFound.
+func (r *DocumentConversionRepo) GetTx(ctx context.Context, tx Tx, documentID uuid.UUID) (*domain.DocumentConversion, error) {
+	stx := sqlxTx(tx)
+	var c domain.DocumentConversion
+	err := stx.GetContext(ctx, &c, stx.Rebind(`SELECT * FROM document_conversions WHERE document_id = ?`), documentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting document conversion: %w", err)
+	}
+	return &c, nil
+}
+
+// CreateTx records a conversion. A second record for the same document
+// returns domain.ErrAlreadyExists.
+func (r *DocumentConversionRepo) CreateTx(ctx context.Context, tx Tx, c *domain.DocumentConversion) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`INSERT INTO document_conversions (document_id, flow_id, version_id, converted_at) VALUES (?, ?, ?, ?)`)
+	c.ConvertedAt = time.Now()
+	if _, err := stx.ExecContext(ctx, query, c.DocumentID, c.FlowID, c.VersionID, c.ConvertedAt); err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrAlreadyExists
+		}
+		return fmt.Errorf("recording document conversion: %w", err)
+	}
+	return nil
+}
+
+// List returns all conversion records, oldest first.
+func (r *DocumentConversionRepo) List(ctx context.Context) ([]domain.DocumentConversion, error) {
+	conversions := make([]domain.DocumentConversion, 0)
+	if err := r.db.SelectContext(ctx, &conversions, `SELECT * FROM document_conversions ORDER BY converted_at ASC`); err != nil {
+		return nil, fmt.Errorf("listing document conversions: %w", err)
+	}
+	return conversions, nil
+}
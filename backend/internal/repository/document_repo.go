@@ -21,14 +21,16 @@ func NewDocumentRepo(db *sqlx.DB) *DocumentRepo {
 	return &DocumentRepo{db: db}
 }
 
-func (r *DocumentRepo) CreateTx(ctx context.Context, tx *sqlx.Tx, doc *domain.Document) error {
-	query := tx.Rebind(`INSERT INTO documents (id, owner_id, title, visibility, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)`)
+func (r *DocumentRepo) CreateTx(ctx context.Context, tx Tx, doc *domain.Document) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`INSERT INTO documents (id, owner_id, title, visibility, revision, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
 	doc.ID = uuid.New()
+	doc.Revision = 1
 	now := time.Now()
 	doc.CreatedAt = now
 	doc.UpdatedAt = now
-	_, err := tx.ExecContext(ctx, query, doc.ID, doc.OwnerID, doc.Title, doc.Visibility, doc.CreatedAt, doc.UpdatedAt)
+	_, err := stx.ExecContext(ctx, query, doc.ID, doc.OwnerID, doc.Title, doc.Visibility, doc.Revision, doc.CreatedAt, doc.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("creating document: %w", err)
 	}
@@ -37,7 +39,7 @@ func (r *DocumentRepo) CreateTx(ctx context.Context, tx *sqlx.Tx, doc *domain.Do
 
 func (r *DocumentRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
 	var doc domain.Document
-	err := r.db.GetContext(ctx, &doc, r.db.Rebind(`SELECT * FROM documents WHERE id = ?`), id)
+	err := r.db.GetContext(ctx, &doc, r.db.Rebind(`SELECT * FROM documents WHERE id = ? AND deleted_at IS NULL`), id)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
@@ -47,54 +49,136 @@ func (r *DocumentRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Docum
 	return &doc, nil
 }
 
-func (r *DocumentRepo) UpdateTx(ctx context.Context, tx *sqlx.Tx, doc *domain.Document) error {
-	query := tx.Rebind(`UPDATE documents SET title = ?, visibility = ?, latest_version_id = ?, updated_at = ? WHERE id = ?`)
+// UpdateTx saves the document if it is still at doc.Revision, and bumps the
+// revision. It returns domain.ErrStaleRevision if another write got there first.
+func (r *DocumentRepo) UpdateTx(ctx context.Context, tx Tx, doc *domain.Document) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE documents SET title = ?, visibility = ?, latest_version_id = ?, revision = revision + 1, updated_at = ?
+		WHERE id = ? AND revision = ?`)
 	doc.UpdatedAt = time.Now()
-	_, err := tx.ExecContext(ctx, query, doc.Title, doc.Visibility, doc.LatestVersionID, doc.UpdatedAt, doc.ID)
+	result, err := stx.ExecContext(ctx, query, doc.Title, doc.Visibility, doc.LatestVersionID, doc.UpdatedAt, doc.ID, doc.Revision)
 	if err != nil {
 		return fmt.Errorf("updating document: %w", err)
 	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrStaleRevision
+	}
+	doc.Revision++
 	return nil
 }
 
-// ListVisible returns documents visible to the given user (owner, public, or shared).
+// UpdateOwnerTx moves a document from owner from to owner to and bumps its
+// revision. It returns false if the document is no longer owned by from.
+func (r *DocumentRepo) UpdateOwnerTx(ctx context.Context, tx Tx, id, from, to uuid.UUID) (bool, error) {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE documents SET owner_id = ?, revision = revision + 1, updated_at = ? WHERE id = ? AND owner_id = ?`)
+	result, err := stx.ExecContext(ctx, query, to, time.Now(), id, from)
+	if err != nil {
+		return false, fmt.Errorf("updating document owner: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ListIDsByOwnerTx returns the IDs of all documents owned by a user.
+func (r *DocumentRepo) ListIDsByOwnerTx(ctx context.Context, tx Tx, ownerID uuid.UUID) ([]uuid.UUID, error) {
+	stx := sqlxTx(tx)
+	ids := make([]uuid.UUID, 0)
+	if err := stx.SelectContext(ctx, &ids, stx.Rebind(`SELECT id FROM documents WHERE owner_id = ?`), ownerID); err != nil {
+		return nil, fmt.Errorf("listing owned documents: %w", err)
+	}
+	return ids, nil
+}
+
+// ListVisible returns documents visible to the given user (owner, public, or
+// shared with the user or one of their groups).
 func (r *DocumentRepo) ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Document, error) {
 	query := r.db.Rebind(`
 		SELECT DISTINCT d.* FROM documents d
 		LEFT JOIN document_shares ds ON d.id = ds.document_id AND ds.user_id = ?
-		WHERE d.owner_id = ? OR d.visibility = 'PUBLIC' OR ds.id IS NOT NULL
+		WHERE d.deleted_at IS NULL AND (d.owner_id = ? OR d.visibility = 'PUBLIC' OR ds.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM document_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.document_id = d.id AND gm.user_id = ?))
 		ORDER BY d.updated_at DESC`)
 	docs := make([]domain.Document, 0)
-	if err := r.db.SelectContext(ctx, &docs, query, userID, userID); err != nil {
+	if err := r.db.SelectContext(ctx, &docs, query, userID, userID, userID); err != nil {
 		return nil, fmt.Errorf("listing documents: %w", err)
 	}
 	return docs, nil
 }
 
-// HasEditAccess checks if a user can edit a document (owner or share role=EDIT).
+// HasEditAccess checks if a user can edit a document (owner, or share
+// role=EDIT with the user or one of their groups).
 func (r *DocumentRepo) HasEditAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error) {
 	var count int
 	query := r.db.Rebind(`
 		SELECT COUNT(*) FROM documents d
 		LEFT JOIN document_shares ds ON d.id = ds.document_id AND ds.user_id = ? AND ds.role = 'EDIT'
-		WHERE d.id = ? AND (d.owner_id = ? OR ds.id IS NOT NULL)`)
-	err := r.db.GetContext(ctx, &count, query, userID, docID, userID)
+		WHERE d.id = ? AND d.deleted_at IS NULL AND (d.owner_id = ? OR ds.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM document_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.document_id = d.id AND gm.user_id = ? AND gs.role = 'EDIT'))`)
+	err := r.db.GetContext(ctx, &count, query, userID, docID, userID, userID)
 	if err != nil {
 		return false, fmt.Errorf("checking edit access: %w", err)
 	}
 	return count > 0, nil
 }
 
-// HasReadAccess checks if a user can read a document (owner, public, or any share).
+// HasReadAccess checks if a user can read a document (owner, public, or any
+// share with the user or one of their groups).
 func (r *DocumentRepo) HasReadAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error) {
 	var count int
 	query := r.db.Rebind(`
 		SELECT COUNT(*) FROM documents d
 		LEFT JOIN document_shares ds ON d.id = ds.document_id AND ds.user_id = ?
-		WHERE d.id = ? AND (d.owner_id = ? OR d.visibility = 'PUBLIC' OR ds.id IS NOT NULL)`)
-	err := r.db.GetContext(ctx, &count, query, userID, docID, userID)
+		WHERE d.id = ? AND d.deleted_at IS NULL AND (d.owner_id = ? OR d.visibility = 'PUBLIC' OR ds.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM document_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.document_id = d.id AND gm.user_id = ?))`)
+	err := r.db.GetContext(ctx, &count, query, userID, docID, userID, userID)
 	if err != nil {
 		return false, fmt.Errorf("checking read access: %w", err)
 	}
 	return count > 0, nil
 }
+
+// SoftDelete moves a live document to the trash.
+func (r *DocumentRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(`UPDATE documents SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`)
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("deleting document: %w", err)
+	}
+	return nil
+}
+
+// ListDeletedByOwner returns the owner's documents in the trash, most recently deleted first.
+func (r *DocumentRepo) ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Document, error) {
+	query := r.db.Rebind(`SELECT * FROM documents WHERE owner_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`)
+	docs := make([]domain.Document, 0)
+	if err := r.db.SelectContext(ctx, &docs, query, ownerID); err != nil {
+		return nil, fmt.Errorf("listing deleted documents: %w", err)
+	}
+	return docs, nil
+}
+
+// Restore takes a document owned by ownerID out of the trash. It returns
+// false if no such document is in the trash.
+func (r *DocumentRepo) Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	query := r.db.Rebind(`UPDATE documents SET deleted_at = NULL WHERE id = ? AND owner_id = ? AND deleted_at IS NOT NULL`)
+	result, err := r.db.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return false, fmt.Errorf("restoring document: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// PurgeDeletedTx hard-deletes documents trashed before the cutoff. Versions,
+// shares and nodes go with them via ON DELETE CASCADE.
+func (r *DocumentRepo) PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error) {
+	stx := sqlxTx(tx)
+	result, err := stx.ExecContext(ctx, stx.Rebind(`DELETE FROM documents WHERE deleted_at IS NOT NULL AND deleted_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("purging documents: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// flowNodeColumns lists the flow_nodes columns with NULL-able text columns coalesced.
+const flowNodeColumns = `id, flow_id, node_no, name, COALESCE(intro, '') AS intro,
+	COALESCE(raci_json, '') AS raci_json, COALESCE(exec_form, '') AS exec_form,
+	duration_min, duration_max, duration_unit,
+	COALESCE(prereq_text, '') AS prereq_text, COALESCE(outputs_text, '') AS outputs_text,
+	COALESCE(subtasks_json, '') AS subtasks_json, sort_order, created_at, updated_at`
+
+type FlowNodeRepo struct {
+	db *sqlx.DB
+}
+
+func NewFlowNodeRepo(db *sqlx.DB) *FlowNodeRepo {
+	return &FlowNodeRepo{db: db}
+}
+
+// ListByFlow returns all nodes of a flow in display order.
+func (r *FlowNodeRepo) ListByFlow(ctx context.Context, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	return listFlowNodes(ctx, r.db, flowID)
+}
+
+// ListByFlowTx returns all nodes of a flow in display order, within the given transaction.
+func (r *FlowNodeRepo) ListByFlowTx(ctx context.Context, tx Tx, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	return listFlowNodes(ctx, sqlxTx(tx), flowID)
+}
+
+func listFlowNodes(ctx context.Context, q sqlx.ExtContext, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	query := q.Rebind(`SELECT ` + flowNodeColumns + ` FROM flow_nodes
+		WHERE flow_id = ? ORDER BY sort_order ASC, created_at ASC`)
+	nodes := make([]domain.FlowNode, 0)
+	if err := sqlx.SelectContext(ctx, q, &nodes, query, flowID); err != nil {
+		return nil, fmt.Errorf("listing flow nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+// ReplaceTx replaces the full node list of a flow within the given transaction.
+// Nodes keep their IDs; sort_order is taken from the slice position.
+func (r *FlowNodeRepo) ReplaceTx(ctx context.Context, tx Tx, flowID uuid.UUID, nodes []domain.FlowNode) error {
+	stx := sqlxTx(tx)
+	if _, err := stx.ExecContext(ctx, stx.Rebind(`DELETE FROM flow_nodes WHERE flow_id = ?`), flowID); err != nil {
+		return fmt.Errorf("clearing flow nodes: %w", err)
+	}
+
+	query := stx.Rebind(`INSERT INTO flow_nodes
+		(id, flow_id, node_no, name, intro, raci_json, exec_form,
+		 duration_min, duration_max, duration_unit, prereq_text, outputs_text, subtasks_json,
+		 sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	now := time.Now()
+	for i := range nodes {
+		n := &nodes[i]
+		if n.ID == uuid.Nil {
+			n.ID = uuid.New()
+		}
+		n.FlowID = flowID
+		n.SortOrder = i
+		if n.CreatedAt.IsZero() {
+			n.CreatedAt = now
+		}
+		n.UpdatedAt = now
+		_, err := stx.ExecContext(ctx, query,
+			n.ID, n.FlowID, n.NodeNo, n.Name, n.Intro, n.RaciJSON, n.ExecForm,
+			n.DurationMin, n.DurationMax, n.DurationUnit, n.PrereqText, n.OutputsText, n.SubtasksJSON,
+			n.SortOrder, n.CreatedAt, n.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("inserting flow node: %w", err)
+		}
+	}
+	return nil
+}
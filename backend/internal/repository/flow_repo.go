@@ -13,6 +13,12 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// flowColumns lists the flows columns with NULL-able text columns coalesced,
+// since migration 003 declares overview/diagram_json without a default.
+const flowColumns = `f.id, f.flow_no, f.title, f.owner_id, f.owner_dept_id,
+	COALESCE(f.overview, '') AS overview, f.status, COALESCE(f.diagram_json, '') AS diagram_json,
+	f.latest_version_id, f.revision, f.created_at, f.updated_at, f.deleted_at`
+
 type FlowRepo struct {
 	db *sqlx.DB
 }
@@ -21,83 +27,245 @@ func NewFlowRepo(db *sqlx.DB) *FlowRepo {
 	return &FlowRepo{db: db}
 }
 
-// CreateTx inserts a new workflow node within the given transaction.
-func (r *FlowRepo) CreateTx(ctx context.Context, tx *sqlx.Tx, node *domain.WorkflowNode) error {
-	query := tx.Rebind(`INSERT INTO workflow_nodes
-		(id, document_id, name, exec_form, description, preconditions, outputs,
-		 duration_min, duration_max, duration_unit, raci_json, subtasks_json, diagram_json,
-		 created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
-	node.ID = uuid.New()
+// NextFlowNoTx returns the next flow number for the current year (FLOW-YYYY-NNNN).
+func (r *FlowRepo) NextFlowNoTx(ctx context.Context, tx Tx) (string, error) {
+	stx := sqlxTx(tx)
+	prefix := fmt.Sprintf("FLOW-%d-", time.Now().Year())
+	var last string
+	err := stx.GetContext(ctx, &last,
+		stx.Rebind(`SELECT flow_no FROM flows WHERE flow_no LIKE ? ORDER BY flow_no DESC LIMIT 1`),
+		prefix+"%")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("finding last flow number: %w", err)
+	}
+
+	seq := 0
+	if last != "" {
+		fmt.Sscanf(last[len(prefix):], "%d", &seq) //nolint:errcheck
+	}
+	return fmt.Sprintf("%s%04d", prefix, seq+1), nil
+}
+
+func (r *FlowRepo) CreateTx(ctx context.Context, tx Tx, flow *domain.Flow) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`INSERT INTO flows
+		(id, flow_no, title, owner_id, owner_dept_id, overview, status, diagram_json, revision, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	flow.ID = uuid.New()
+	flow.Revision = 1
 	now := time.Now()
-	node.CreatedAt = now
-	node.UpdatedAt = now
-	_, err := tx.ExecContext(ctx, query,
-		node.ID, node.DocumentID, node.Name, node.ExecForm,
-		node.Description, node.Preconditions, node.Outputs,
-		node.DurationMin, node.DurationMax, node.DurationUnit,
-		node.RaciJSON, node.SubtasksJSON, node.DiagramRaw,
-		node.CreatedAt, node.UpdatedAt,
+	if flow.CreatedAt.IsZero() {
+		flow.CreatedAt = now
+	}
+	flow.UpdatedAt = now
+	_, err := stx.ExecContext(ctx, query,
+		flow.ID, flow.FlowNo, flow.Title, flow.OwnerID, flow.OwnerDeptID,
+		flow.Overview, flow.Status, flow.DiagramJSON, flow.Revision, flow.CreatedAt, flow.UpdatedAt,
 	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("creating flow: %w: flow_no %s", domain.ErrAlreadyExists, flow.FlowNo)
+	}
 	if err != nil {
-		return fmt.Errorf("creating workflow node: %w", err)
+		return fmt.Errorf("creating flow: %w", err)
 	}
 	return nil
 }
 
-// UpdateTx updates an existing workflow node within the given transaction.
-func (r *FlowRepo) UpdateTx(ctx context.Context, tx *sqlx.Tx, node *domain.WorkflowNode) error {
-	query := tx.Rebind(`UPDATE workflow_nodes SET
-		name = ?, exec_form = ?, description = ?, preconditions = ?, outputs = ?,
-		duration_min = ?, duration_max = ?, duration_unit = ?,
-		raci_json = ?, subtasks_json = ?, diagram_json = ?, updated_at = ?
-		WHERE id = ?`)
-	node.UpdatedAt = time.Now()
-	_, err := tx.ExecContext(ctx, query,
-		node.Name, node.ExecForm, node.Description, node.Preconditions, node.Outputs,
-		node.DurationMin, node.DurationMax, node.DurationUnit,
-		node.RaciJSON, node.SubtasksJSON, node.DiagramRaw, node.UpdatedAt,
-		node.ID,
+func (r *FlowRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Flow, error) {
+	return getFlow(ctx, r.db, id)
+}
+
+// GetByIDTx reads a flow within the given transaction.
+func (r *FlowRepo) GetByIDTx(ctx context.Context, tx Tx, id uuid.UUID) (*domain.Flow, error) {
+	return getFlow(ctx, sqlxTx(tx), id)
+}
+
+func getFlow(ctx context.Context, q sqlx.ExtContext, id uuid.UUID) (*domain.Flow, error) {
+	var flow domain.Flow
+	err := sqlx.GetContext(ctx, q, &flow, q.Rebind(`SELECT `+flowColumns+` FROM flows f WHERE f.id = ? AND f.deleted_at IS NULL`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting flow: %w", err)
+	}
+	return &flow, nil
+}
+
+// UpdateTx saves the flow header if it is still at flow.Revision, and bumps
+// the revision. It returns domain.ErrStaleRevision if another write got there first.
+func (r *FlowRepo) UpdateTx(ctx context.Context, tx Tx, flow *domain.Flow) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE flows SET
+		title = ?, owner_dept_id = ?, overview = ?, status = ?, diagram_json = ?,
+		latest_version_id = ?, revision = revision + 1, updated_at = ?
+		WHERE id = ? AND revision = ?`)
+	flow.UpdatedAt = time.Now()
+	result, err := stx.ExecContext(ctx, query,
+		flow.Title, flow.OwnerDeptID, flow.Overview, flow.Status, flow.DiagramJSON,
+		flow.LatestVersionID, flow.UpdatedAt,
+		flow.ID, flow.Revision,
 	)
 	if err != nil {
-		return fmt.Errorf("updating workflow node: %w", err)
+		return fmt.Errorf("updating flow: %w", err)
 	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrStaleRevision
+	}
+	flow.Revision++
 	return nil
 }
 
-// GetByID returns a single workflow node.
-func (r *FlowRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error) {
-	var node domain.WorkflowNode
-	err := r.db.GetContext(ctx, &node, r.db.Rebind(`SELECT * FROM workflow_nodes WHERE id = ?`), id)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, domain.ErrNotFound
+// UpdateStatusTx moves a flow out of status from into flow.Status, also saving
+// latest_version_id. It returns false if the flow was no longer in status from,
+// i.e. a concurrent request already transitioned it.
+func (r *FlowRepo) UpdateStatusTx(ctx context.Context, tx Tx, flow *domain.Flow, from domain.FlowStatus) (bool, error) {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE flows SET status = ?, latest_version_id = ?, revision = revision + 1, updated_at = ?
+		WHERE id = ? AND status = ?`)
+	flow.UpdatedAt = time.Now()
+	result, err := stx.ExecContext(ctx, query, flow.Status, flow.LatestVersionID, flow.UpdatedAt, flow.ID, from)
+	if err != nil {
+		return false, fmt.Errorf("updating flow status: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows > 0 {
+		flow.Revision++
 	}
+	return rows > 0, nil
+}
+
+// UpdateOwnerTx moves a flow from owner from to owner to and bumps its
+// revision. It returns false if the flow is no longer owned by from.
+func (r *FlowRepo) UpdateOwnerTx(ctx context.Context, tx Tx, id, from, to uuid.UUID) (bool, error) {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE flows SET owner_id = ?, revision = revision + 1, updated_at = ? WHERE id = ? AND owner_id = ?`)
+	result, err := stx.ExecContext(ctx, query, to, time.Now(), id, from)
 	if err != nil {
-		return nil, fmt.Errorf("getting workflow node: %w", err)
+		return false, fmt.Errorf("updating flow owner: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ListIDsByOwnerTx returns the IDs of all flows owned by a user.
+func (r *FlowRepo) ListIDsByOwnerTx(ctx context.Context, tx Tx, ownerID uuid.UUID) ([]uuid.UUID, error) {
+	stx := sqlxTx(tx)
+	ids := make([]uuid.UUID, 0)
+	if err := stx.SelectContext(ctx, &ids, stx.Rebind(`SELECT id FROM flows WHERE owner_id = ?`), ownerID); err != nil {
+		return nil, fmt.Errorf("listing owned flows: %w", err)
 	}
-	node.HydrateJSON()
-	return &node, nil
+	return ids, nil
 }
 
-// ListByDocument returns all workflow nodes for a document, ordered by creation time.
-func (r *FlowRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.WorkflowNode, error) {
-	query := r.db.Rebind(`SELECT * FROM workflow_nodes WHERE document_id = ? ORDER BY created_at ASC`)
-	nodes := make([]domain.WorkflowNode, 0)
-	if err := r.db.SelectContext(ctx, &nodes, query, docID); err != nil {
-		return nil, fmt.Errorf("listing workflow nodes: %w", err)
+// ListVisible returns flows visible to the given user (owner, effective or
+// published before, or shared with the user or one of their groups).
+func (r *FlowRepo) ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Flow, error) {
+	query := r.db.Rebind(`
+		SELECT DISTINCT ` + flowColumns + ` FROM flows f
+		LEFT JOIN flow_shares fs ON f.id = fs.flow_id AND fs.user_id = ?
+		WHERE f.deleted_at IS NULL AND (f.owner_id = ? OR f.status = 'EFFECTIVE' OR f.latest_version_id IS NOT NULL OR fs.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM flow_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.flow_id = f.id AND gm.user_id = ?))
+		ORDER BY f.updated_at DESC`)
+	flows := make([]domain.Flow, 0)
+	if err := r.db.SelectContext(ctx, &flows, query, userID, userID, userID); err != nil {
+		return nil, fmt.Errorf("listing flows: %w", err)
 	}
-	for i := range nodes {
-		nodes[i].HydrateJSON()
+	return flows, nil
+}
+
+// HasEditAccess checks if a user can edit a flow (owner, or share role=EDIT
+// with the user or one of their groups).
+func (r *FlowRepo) HasEditAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var count int
+	query := r.db.Rebind(`
+		SELECT COUNT(*) FROM flows f
+		LEFT JOIN flow_shares fs ON f.id = fs.flow_id AND fs.user_id = ? AND fs.role = 'EDIT'
+		WHERE f.id = ? AND f.deleted_at IS NULL AND (f.owner_id = ? OR fs.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM flow_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.flow_id = f.id AND gm.user_id = ? AND gs.role = 'EDIT'))`)
+	err := r.db.GetContext(ctx, &count, query, userID, flowID, userID, userID)
+	if err != nil {
+		return false, fmt.Errorf("checking flow edit access: %w", err)
 	}
-	return nodes, nil
+	return count > 0, nil
 }
 
-// Delete removes a workflow node by ID.
-func (r *FlowRepo) Delete(ctx context.Context, id uuid.UUID) error {
-	query := r.db.Rebind(`DELETE FROM workflow_nodes WHERE id = ?`)
-	_, err := r.db.ExecContext(ctx, query, id)
+// HasReadAccess checks if a user can read a flow (owner, effective or
+// published before, or any share with the user or one of their groups).
+func (r *FlowRepo) HasReadAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var count int
+	query := r.db.Rebind(`
+		SELECT COUNT(*) FROM flows f
+		LEFT JOIN flow_shares fs ON f.id = fs.flow_id AND fs.user_id = ?
+		WHERE f.id = ? AND f.deleted_at IS NULL AND (f.owner_id = ? OR f.status = 'EFFECTIVE' OR f.latest_version_id IS NOT NULL OR fs.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM flow_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.flow_id = f.id AND gm.user_id = ?))`)
+	err := r.db.GetContext(ctx, &count, query, userID, flowID, userID, userID)
 	if err != nil {
-		return fmt.Errorf("deleting workflow node: %w", err)
+		return false, fmt.Errorf("checking flow read access: %w", err)
+	}
+	return count > 0, nil
+}
+
+// HasDraftAccess checks if a user can read a flow's working copy while it is
+// not effective (owner, or any share with the user or one of their groups).
+// Other readers only see its latest published version.
+func (r *FlowRepo) HasDraftAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var count int
+	query := r.db.Rebind(`
+		SELECT COUNT(*) FROM flows f
+		LEFT JOIN flow_shares fs ON f.id = fs.flow_id AND fs.user_id = ?
+		WHERE f.id = ? AND f.deleted_at IS NULL AND (f.owner_id = ? OR fs.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM flow_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.flow_id = f.id AND gm.user_id = ?))`)
+	err := r.db.GetContext(ctx, &count, query, userID, flowID, userID, userID)
+	if err != nil {
+		return false, fmt.Errorf("checking flow draft access: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SoftDelete moves a live flow to the trash.
+func (r *FlowRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(`UPDATE flows SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`)
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("deleting flow: %w", err)
 	}
 	return nil
 }
+
+// ListDeletedByOwner returns the owner's flows in the trash, most recently deleted first.
+func (r *FlowRepo) ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Flow, error) {
+	query := r.db.Rebind(`SELECT ` + flowColumns + ` FROM flows f
+		WHERE f.owner_id = ? AND f.deleted_at IS NOT NULL ORDER BY f.deleted_at DESC`)
+	flows := make([]domain.Flow, 0)
+	if err := r.db.SelectContext(ctx, &flows, query, ownerID); err != nil {
+		return nil, fmt.Errorf("listing deleted flows: %w", err)
+	}
+	return flows, nil
+}
+
+// Restore takes a flow owned by ownerID out of the trash. It returns false
+// if no such flow is in the trash.
+func (r *FlowRepo) Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	query := r.db.Rebind(`UPDATE flows SET deleted_at = NULL WHERE id = ? AND owner_id = ? AND deleted_at IS NOT NULL`)
+	result, err := r.db.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return false, fmt.Errorf("restoring flow: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// PurgeDeletedTx hard-deletes flows trashed before the cutoff. Nodes,
+// versions and shares go with them via ON DELETE CASCADE.
+func (r *FlowRepo) PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error) {
+	stx := sqlxTx(tx)
+	result, err := stx.ExecContext(ctx, stx.Rebind(`DELETE FROM flows WHERE deleted_at IS NOT NULL AND deleted_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("purging flows: %w", err)
+	}
+	return result.RowsAffected()
+}
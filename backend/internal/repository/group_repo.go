@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type GroupRepo struct {
+	db *sqlx.DB
+}
+
+func NewGroupRepo(db *sqlx.DB) *GroupRepo {
+	return &GroupRepo{db: db}
+}
+
+// Create inserts a group. A second group with the same name returns
+// domain.ErrAlreadyExists.
+func (r *GroupRepo) Create(ctx context.Context, group *domain.Group) error {
+	query := r.db.Rebind(`INSERT INTO user_groups (id, name, external_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`)
+	group.ID = uuid.New()
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	_, err := r.db.ExecContext(ctx, query, group.ID, group.Name, group.ExternalID, group.CreatedAt, group.UpdatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: group name already taken", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("creating group: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Group, error) {
+	var group domain.Group
+	err := r.db.GetContext(ctx, &group, r.db.Rebind(`SELECT * FROM user_groups WHERE id = ?`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting group: %w", err)
+	}
+	return &group, nil
+}
+
+// List returns all groups by name.
+func (r *GroupRepo) List(ctx context.Context) ([]domain.Group, error) {
+	groups := make([]domain.Group, 0)
+	if err := r.db.SelectContext(ctx, &groups, `SELECT * FROM user_groups ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("listing groups: %w", err)
+	}
+	return groups, nil
+}
+
+// Update saves a group's name and external id and sets UpdatedAt. A name
+// taken by another group returns domain.ErrAlreadyExists.
+func (r *GroupRepo) Update(ctx context.Context, group *domain.Group) error {
+	query := r.db.Rebind(`UPDATE user_groups SET name = ?, external_id = ?, updated_at = ? WHERE id = ?`)
+	group.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, group.Name, group.ExternalID, group.UpdatedAt, group.ID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: group name already taken", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("updating group: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a group; its memberships go with it through ON DELETE
+// CASCADE.
+func (r *GroupRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM user_groups WHERE id = ?`), id)
+	if err != nil {
+		return fmt.Errorf("deleting group: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepo) ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error) {
+	members := make([]domain.GroupMember, 0)
+	query := r.db.Rebind(`SELECT * FROM user_group_members WHERE group_id = ? ORDER BY created_at`)
+	if err := r.db.SelectContext(ctx, &members, query, groupID); err != nil {
+		return nil, fmt.Errorf("listing group members: %w", err)
+	}
+	return members, nil
+}
+
+// ListMemberships returns the members of every group, for listing groups or
+// users without a query per row.
+func (r *GroupRepo) ListMemberships(ctx context.Context) ([]domain.GroupMember, error) {
+	members := make([]domain.GroupMember, 0)
+	if err := r.db.SelectContext(ctx, &members, `SELECT * FROM user_group_members ORDER BY created_at`); err != nil {
+		return nil, fmt.Errorf("listing group memberships: %w", err)
+	}
+	return members, nil
+}
+
+func (r *GroupRepo) GetMember(ctx context.Context, groupID, userID uuid.UUID) (*domain.GroupMember, error) {
+	var member domain.GroupMember
+	query := r.db.Rebind(`SELECT * FROM user_group_members WHERE group_id = ? AND user_id = ?`)
+	err := r.db.GetContext(ctx, &member, query, groupID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting group member: %w", err)
+	}
+	return &member, nil
+}
+
+// AddMember adds a user to a group with the given role. Adding a member
+// again is not an error and keeps the role they have.
+func (r *GroupRepo) AddMember(ctx context.Context, groupID, userID uuid.UUID, role domain.GroupRole) error {
+	query := r.db.Rebind(`INSERT INTO user_group_members (group_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`)
+	_, err := r.db.ExecContext(ctx, query, groupID, userID, role, time.Now())
+	if err != nil && !isUniqueViolation(err) {
+		return fmt.Errorf("adding group member: %w", err)
+	}
+	return nil
+}
+
+// ListOwnersTx returns the user IDs of a group's owners and locks their
+// memberships until tx ends, so that transactions demoting or removing
+// owners of the same group take turns and each sees the others' result.
+func (r *GroupRepo) ListOwnersTx(ctx context.Context, tx Tx, groupID uuid.UUID) ([]uuid.UUID, error) {
+	stx := sqlxTx(tx)
+	owners := make([]uuid.UUID, 0)
+	query := stx.Rebind(forUpdate(stx, `SELECT user_id FROM user_group_members WHERE group_id = ? AND role = ?`))
+	if err := stx.SelectContext(ctx, &owners, query, groupID, domain.GroupRoleOwner); err != nil {
+		return nil, fmt.Errorf("listing group owners: %w", err)
+	}
+	return owners, nil
+}
+
+// UpdateMemberRoleTx changes a member's role within the given transaction.
+func (r *GroupRepo) UpdateMemberRoleTx(ctx context.Context, tx Tx, groupID, userID uuid.UUID, role domain.GroupRole) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE user_group_members SET role = ? WHERE group_id = ? AND user_id = ?`)
+	if _, err := stx.ExecContext(ctx, query, role, groupID, userID); err != nil {
+		return fmt.Errorf("updating group member: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember removes a user from a group. Removing a user who is not a
+// member is not an error.
+func (r *GroupRepo) RemoveMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	return removeGroupMember(ctx, r.db, groupID, userID)
+}
+
+// RemoveMemberTx removes a user from a group within the given transaction.
+func (r *GroupRepo) RemoveMemberTx(ctx context.Context, tx Tx, groupID, userID uuid.UUID) error {
+	return removeGroupMember(ctx, sqlxTx(tx), groupID, userID)
+}
+
+func removeGroupMember(ctx context.Context, q sqlx.ExtContext, groupID, userID uuid.UUID) error {
+	query := q.Rebind(`DELETE FROM user_group_members WHERE group_id = ? AND user_id = ?`)
+	if _, err := q.ExecContext(ctx, query, groupID, userID); err != nil {
+		return fmt.Errorf("removing group member: %w", err)
+	}
+	return nil
+}
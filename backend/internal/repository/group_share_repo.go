@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// GroupShareRepo manages group grants in document_group_shares or
+// flow_group_shares, the group counterparts of ShareRepo's tables.
+type GroupShareRepo struct {
+	db       *sqlx.DB
+	resource domain.ShareResource
+	table    string // "document_group_shares" or "flow_group_shares"
+	fk       string // "document_id" or "flow_id"
+}
+
+func NewDocumentGroupShareRepo(db *sqlx.DB) *GroupShareRepo {
+	return &GroupShareRepo{db: db, resource: domain.ShareResourceDocument, table: "document_group_shares", fk: "document_id"}
+}
+
+func NewFlowGroupShareRepo(db *sqlx.DB) *GroupShareRepo {
+	return &GroupShareRepo{db: db, resource: domain.ShareResourceFlow, table: "flow_group_shares", fk: "flow_id"}
+}
+
+func (r *GroupShareRepo) selectShares() string {
+	return fmt.Sprintf(`SELECT s.id, s.%s AS resource_id, s.group_id, g.name AS group_name, s.role, s.created_at
+		FROM %s s JOIN user_groups g ON g.id = s.group_id`, r.fk, r.table)
+}
+
+// Create inserts a group share. A second share for the same (resource,
+// group) pair returns domain.ErrAlreadyExists.
+func (r *GroupShareRepo) Create(ctx context.Context, share *domain.GroupShare) error {
+	return r.insert(ctx, r.db, share)
+}
+
+// CreateTx inserts a group share within the given transaction.
+func (r *GroupShareRepo) CreateTx(ctx context.Context, tx Tx, share *domain.GroupShare) error {
+	return r.insert(ctx, sqlxTx(tx), share)
+}
+
+func (r *GroupShareRepo) insert(ctx context.Context, q sqlx.ExtContext, share *domain.GroupShare) error {
+	query := q.Rebind(fmt.Sprintf(`INSERT INTO %s (id, %s, group_id, role, created_at) VALUES (?, ?, ?, ?, ?)`, r.table, r.fk))
+	share.ID = uuid.New()
+	share.Resource = r.resource
+	share.CreatedAt = time.Now()
+	_, err := q.ExecContext(ctx, query, share.ID, share.ResourceID, share.GroupID, share.Role, share.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: group already has a share", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("creating group share: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupShareRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.GroupShare, error) {
+	var share domain.GroupShare
+	err := r.db.GetContext(ctx, &share, r.db.Rebind(r.selectShares()+` WHERE s.id = ?`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting group share: %w", err)
+	}
+	share.Resource = r.resource
+	return &share, nil
+}
+
+// ListByResource returns all group shares of a document or flow, oldest
+// first.
+func (r *GroupShareRepo) ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.GroupShare, error) {
+	query := r.db.Rebind(r.selectShares() + fmt.Sprintf(` WHERE s.%s = ? ORDER BY s.created_at ASC`, r.fk))
+	shares := make([]domain.GroupShare, 0)
+	if err := r.db.SelectContext(ctx, &shares, query, resourceID); err != nil {
+		return nil, fmt.Errorf("listing group shares: %w", err)
+	}
+	for i := range shares {
+		shares[i].Resource = r.resource
+	}
+	return shares, nil
+}
+
+func (r *GroupShareRepo) UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error {
+	query := r.db.Rebind(fmt.Sprintf(`UPDATE %s SET role = ? WHERE id = ?`, r.table))
+	if _, err := r.db.ExecContext(ctx, query, role, id); err != nil {
+		return fmt.Errorf("updating group share: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupShareRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, r.table))
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("deleting group share: %w", err)
+	}
+	return nil
+}
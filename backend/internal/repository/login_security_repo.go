@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type LoginThrottleRepo struct {
+	db *sqlx.DB
+}
+
+func NewLoginThrottleRepo(db *sqlx.DB) *LoginThrottleRepo {
+	return &LoginThrottleRepo{db: db}
+}
+
+func (r *LoginThrottleRepo) Get(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	var lt domain.LoginThrottle
+	err := r.db.GetContext(ctx, &lt, r.db.Rebind(`SELECT * FROM login_throttles WHERE throttle_key = ?`), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting login throttle: %w", err)
+	}
+	return &lt, nil
+}
+
+// RecordFailure counts a failed login against key and returns the number of
+// failures now on record. A count whose last failure lies before resetBefore
+// starts over at one.
+func (r *LoginThrottleRepo) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error) {
+	update := r.db.Rebind(`UPDATE login_throttles
+		SET failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+		    last_failure_at = ?
+		WHERE throttle_key = ?`)
+	insert := r.db.Rebind(`INSERT INTO login_throttles (throttle_key, failures, last_failure_at) VALUES (?, 1, ?)`)
+
+	// Two passes at most: when a concurrent failure inserts the row between
+	// our update and insert, the second update finds it.
+	for pass := 0; pass < 2; pass++ {
+		result, err := r.db.ExecContext(ctx, update, resetBefore, at, key)
+		if err != nil {
+			return 0, fmt.Errorf("recording login failure: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			var failures int
+			err := r.db.GetContext(ctx, &failures, r.db.Rebind(`SELECT failures FROM login_throttles WHERE throttle_key = ?`), key)
+			if err != nil {
+				return 0, fmt.Errorf("reading login failures: %w", err)
+			}
+			return failures, nil
+		}
+		_, err = r.db.ExecContext(ctx, insert, key, at)
+		if err == nil {
+			return 1, nil
+		}
+		if !isUniqueViolation(err) {
+			return 0, fmt.Errorf("recording login failure: %w", err)
+		}
+	}
+	return 0, fmt.Errorf("recording login failure: concurrent updates of %q", key)
+}
+
+// List returns the throttles with a failure at or after since, most recent
+// first.
+func (r *LoginThrottleRepo) List(ctx context.Context, since time.Time) ([]domain.LoginThrottle, error) {
+	throttles := make([]domain.LoginThrottle, 0)
+	query := r.db.Rebind(`SELECT * FROM login_throttles WHERE last_failure_at >= ? ORDER BY last_failure_at DESC`)
+	if err := r.db.SelectContext(ctx, &throttles, query, since); err != nil {
+		return nil, fmt.Errorf("listing login throttles: %w", err)
+	}
+	return throttles, nil
+}
+
+// Delete forgets the failures recorded for key. It returns ErrNotFound when
+// there are none.
+func (r *LoginThrottleRepo) Delete(ctx context.Context, key string) error {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM login_throttles WHERE throttle_key = ?`), key)
+	if err != nil {
+		return fmt.Errorf("deleting login throttle: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteStale removes throttles whose last failure lies before the given
+// time and returns how many were removed.
+func (r *LoginThrottleRepo) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM login_throttles WHERE last_failure_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("deleting stale login throttles: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+type PasswordHistoryRepo struct {
+	db *sqlx.DB
+}
+
+func NewPasswordHistoryRepo(db *sqlx.DB) *PasswordHistoryRepo {
+	return &PasswordHistoryRepo{db: db}
+}
+
+// Add records a password hash the user has just been given and drops all
+// but the newest keep entries of their history.
+func (r *PasswordHistoryRepo) Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error {
+	query := r.db.Rebind(`INSERT INTO password_history (id, user_id, password_hash, created_at) VALUES (?, ?, ?, ?)`)
+	if _, err := r.db.ExecContext(ctx, query, uuid.New(), userID, hash, time.Now()); err != nil {
+		return fmt.Errorf("recording password history: %w", err)
+	}
+
+	// Selected first and deleted one by one: MySQL does not allow LIMIT in a
+	// subquery of DELETE. Usually there is a single entry to drop.
+	var ids []uuid.UUID
+	query = r.db.Rebind(`SELECT id FROM password_history WHERE user_id = ? ORDER BY created_at DESC, id`)
+	if err := r.db.SelectContext(ctx, &ids, query, userID); err != nil {
+		return fmt.Errorf("listing password history: %w", err)
+	}
+	if len(ids) <= keep {
+		return nil
+	}
+	for _, id := range ids[keep:] {
+		if _, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM password_history WHERE id = ?`), id); err != nil {
+			return fmt.Errorf("trimming password history: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListRecent returns the hashes of the user's newest passwords, newest first.
+func (r *PasswordHistoryRepo) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	hashes := make([]string, 0)
+	query := r.db.Rebind(`SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY created_at DESC, id LIMIT ?`)
+	if err := r.db.SelectContext(ctx, &hashes, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("listing password history: %w", err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type APITokenRepo struct {
+	s *Store
+}
+
+func NewAPITokenRepo(s *Store) *APITokenRepo {
+	return &APITokenRepo{s: s}
+}
+
+func (r *APITokenRepo) Create(ctx context.Context, at *domain.APIToken) error {
+	return r.s.write(nil, func(t *tables) error {
+		at.ID = uuid.New()
+		at.CreatedAt = time.Now()
+		row := *at
+		row.Scopes = slices.Clone(at.Scopes)
+		t.apiTokens[at.ID] = row
+		return nil
+	})
+}
+
+func (r *APITokenRepo) GetByHash(ctx context.Context, hash string) (*domain.APIToken, error) {
+	var found *domain.APIToken
+	err := r.s.read(nil, func(t *tables) error {
+		for _, at := range t.apiTokens {
+			if at.TokenHash == hash {
+				found = &at
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return found, err
+}
+
+func (r *APITokenRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.APIToken, error) {
+	tokens := make([]domain.APIToken, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, at := range t.apiTokens {
+			if at.UserID == userID && at.RevokedAt == nil {
+				tokens = append(tokens, at)
+			}
+		}
+		return nil
+	})
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, err
+}
+
+func (r *APITokenRepo) Revoke(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	return r.s.write(nil, func(t *tables) error {
+		row, ok := t.apiTokens[id]
+		if !ok || row.UserID != userID || row.RevokedAt != nil {
+			return domain.ErrNotFound
+		}
+		row.RevokedAt = &at
+		t.apiTokens[id] = row
+		return nil
+	})
+}
+
+func (r *APITokenRepo) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.s.write(nil, func(t *tables) error {
+		if row, ok := t.apiTokens[id]; ok {
+			row.LastUsedAt = &at
+			t.apiTokens[id] = row
+		}
+		return nil
+	})
+}
+
+var _ repository.APITokenRepository = (*APITokenRepo)(nil)
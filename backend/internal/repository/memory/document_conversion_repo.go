@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// DocumentConversionRepo records which flow each document was converted into.
+type DocumentConversionRepo struct {
+	s *Store
+}
+
+func NewDocumentConversionRepo(s *Store) *DocumentConversionRepo {
+	return &DocumentConversionRepo{s: s}
+}
+
+// ListDocuments returns every document, including trashed ones, oldest first.
+func (r *DocumentConversionRepo) ListDocuments(ctx context.Context) ([]domain.Document, error) {
+	docs := make([]domain.Document, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, d := range t.documents {
+			docs = append(docs, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].CreatedAt.Before(docs[j].CreatedAt) })
+	return docs, nil
+}
+
+// GetTx returns the conversion record of a document, or domain.ErrNotFound.
+func (r *DocumentConversionRepo) GetTx(ctx context.Context, tx repository.Tx, documentID uuid.UUID) (*domain.DocumentConversion, error) {
+	var found *domain.DocumentConversion
+	err := r.s.read(tx, func(t *tables) error {
+		c, ok := t.conversions[documentID]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &c
+		return nil
+	})
+	return found, err
+}
+
+// CreateTx records a conversion. A second record for the same document
+// returns domain.ErrAlreadyExists.
+func (r *DocumentConversionRepo) CreateTx(ctx context.Context, tx repository.Tx, c *domain.DocumentConversion) error {
+	return r.s.write(tx, func(t *tables) error {
+		if _, ok := t.conversions[c.DocumentID]; ok {
+			return domain.ErrAlreadyExists
+		}
+		c.ConvertedAt = time.Now()
+		t.conversions[c.DocumentID] = *c
+		return nil
+	})
+}
+
+// List returns all conversion records, oldest first.
+func (r *DocumentConversionRepo) List(ctx context.Context) ([]domain.DocumentConversion, error) {
+	conversions := make([]domain.DocumentConversion, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, c := range t.conversions {
+			conversions = append(conversions, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(conversions, func(i, j int) bool { return conversions[i].ConvertedAt.Before(conversions[j].ConvertedAt) })
+	return conversions, nil
+}
+
+var _ repository.DocumentConversionRepository = (*DocumentConversionRepo)(nil)
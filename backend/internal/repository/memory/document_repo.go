@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type DocumentRepo struct {
+	s *Store
+}
+
+func NewDocumentRepo(s *Store) *DocumentRepo {
+	return &DocumentRepo{s: s}
+}
+
+func (r *DocumentRepo) CreateTx(ctx context.Context, tx repository.Tx, doc *domain.Document) error {
+	return r.s.write(tx, func(t *tables) error {
+		doc.ID = uuid.New()
+		doc.Revision = 1
+		now := time.Now()
+		doc.CreatedAt = now
+		doc.UpdatedAt = now
+		t.documents[doc.ID] = *doc
+		return nil
+	})
+}
+
+func (r *DocumentRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	var found *domain.Document
+	err := r.s.read(nil, func(t *tables) error {
+		d, ok := t.documents[id]
+		if !ok || d.DeletedAt != nil {
+			return domain.ErrNotFound
+		}
+		found = &d
+		return nil
+	})
+	return found, err
+}
+
+// UpdateTx saves the document if it is still at doc.Revision, and bumps the
+// revision. It returns domain.ErrStaleRevision if another write got there first.
+func (r *DocumentRepo) UpdateTx(ctx context.Context, tx repository.Tx, doc *domain.Document) error {
+	return r.s.write(tx, func(t *tables) error {
+		stored, ok := t.documents[doc.ID]
+		if !ok || stored.Revision != doc.Revision {
+			return domain.ErrStaleRevision
+		}
+		doc.UpdatedAt = time.Now()
+		stored.Title = doc.Title
+		stored.Visibility = doc.Visibility
+		stored.LatestVersionID = doc.LatestVersionID
+		stored.Revision++
+		stored.UpdatedAt = doc.UpdatedAt
+		t.documents[doc.ID] = stored
+		doc.Revision++
+		return nil
+	})
+}
+
+// ListVisible returns documents visible to the given user (owner, public, or
+// shared with the user or one of their groups).
+func (r *DocumentRepo) ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Document, error) {
+	docs := make([]domain.Document, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, d := range t.documents {
+			if canReadDocument(t, d, userID) {
+				docs = append(docs, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].UpdatedAt.After(docs[j].UpdatedAt) })
+	return docs, nil
+}
+
+// HasEditAccess checks if a user can edit a document (owner, or share
+// role=EDIT with the user or one of their groups).
+func (r *DocumentRepo) HasEditAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.s.read(nil, func(t *tables) error {
+		d, found := t.documents[docID]
+		if !found || d.DeletedAt != nil {
+			return nil
+		}
+		role, shared := accessRole(t, domain.ShareResourceDocument, docID, userID)
+		ok = d.OwnerID == userID || (shared && role == domain.ShareRoleEdit)
+		return nil
+	})
+	return ok, err
+}
+
+// HasReadAccess checks if a user can read a document (owner, public, or any
+// share with the user or one of their groups).
+func (r *DocumentRepo) HasReadAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.s.read(nil, func(t *tables) error {
+		d, found := t.documents[docID]
+		ok = found && canReadDocument(t, d, userID)
+		return nil
+	})
+	return ok, err
+}
+
+func canReadDocument(t *tables, d domain.Document, userID uuid.UUID) bool {
+	if d.DeletedAt != nil {
+		return false
+	}
+	if d.OwnerID == userID || d.Visibility == domain.VisibilityPublic {
+		return true
+	}
+	_, shared := accessRole(t, domain.ShareResourceDocument, d.ID, userID)
+	return shared
+}
+
+// SoftDelete moves a live document to the trash.
+func (r *DocumentRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		d, ok := t.documents[id]
+		if ok && d.DeletedAt == nil {
+			now := time.Now()
+			d.DeletedAt = &now
+			t.documents[id] = d
+		}
+		return nil
+	})
+}
+
+// UpdateOwnerTx moves a document from owner from to owner to and bumps its
+// revision. It returns false if the document is no longer owned by from.
+func (r *DocumentRepo) UpdateOwnerTx(ctx context.Context, tx repository.Tx, id, from, to uuid.UUID) (bool, error) {
+	var moved bool
+	err := r.s.write(tx, func(t *tables) error {
+		d, ok := t.documents[id]
+		if !ok || d.OwnerID != from {
+			return nil
+		}
+		d.OwnerID = to
+		d.Revision++
+		d.UpdatedAt = time.Now()
+		t.documents[id] = d
+		moved = true
+		return nil
+	})
+	return moved, err
+}
+
+// ListIDsByOwnerTx returns the IDs of all documents owned by a user.
+func (r *DocumentRepo) ListIDsByOwnerTx(ctx context.Context, tx repository.Tx, ownerID uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	err := r.s.read(tx, func(t *tables) error {
+		for _, d := range t.documents {
+			if d.OwnerID == ownerID {
+				ids = append(ids, d.ID)
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// ListDeletedByOwner returns the owner's documents in the trash, most recently deleted first.
+func (r *DocumentRepo) ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Document, error) {
+	docs := make([]domain.Document, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, d := range t.documents {
+			if d.OwnerID == ownerID && d.DeletedAt != nil {
+				docs = append(docs, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].DeletedAt.After(*docs[j].DeletedAt) })
+	return docs, nil
+}
+
+// Restore takes a document owned by ownerID out of the trash. It returns
+// false if no such document is in the trash.
+func (r *DocumentRepo) Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	var restored bool
+	err := r.s.write(nil, func(t *tables) error {
+		d, ok := t.documents[id]
+		if !ok || d.OwnerID != ownerID || d.DeletedAt == nil {
+			return nil
+		}
+		d.DeletedAt = nil
+		t.documents[id] = d
+		restored = true
+		return nil
+	})
+	return restored, err
+}
+
+// PurgeDeletedTx hard-deletes documents trashed before the cutoff, together
+// with their versions, shares and nodes.
+func (r *DocumentRepo) PurgeDeletedTx(ctx context.Context, tx repository.Tx, before time.Time) (int64, error) {
+	var purged int64
+	err := r.s.write(tx, func(t *tables) error {
+		for id, d := range t.documents {
+			if d.DeletedAt != nil && d.DeletedAt.Before(before) {
+				deleteDocument(t, id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+var _ repository.DocumentRepository = (*DocumentRepo)(nil)
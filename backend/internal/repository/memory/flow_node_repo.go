@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type FlowNodeRepo struct {
+	s *Store
+}
+
+func NewFlowNodeRepo(s *Store) *FlowNodeRepo {
+	return &FlowNodeRepo{s: s}
+}
+
+// ListByFlow returns all nodes of a flow in display order.
+func (r *FlowNodeRepo) ListByFlow(ctx context.Context, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	return r.list(nil, flowID)
+}
+
+// ListByFlowTx returns all nodes of a flow in display order, within the given transaction.
+func (r *FlowNodeRepo) ListByFlowTx(ctx context.Context, tx repository.Tx, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	return r.list(tx, flowID)
+}
+
+func (r *FlowNodeRepo) list(tx repository.Tx, flowID uuid.UUID) ([]domain.FlowNode, error) {
+	nodes := make([]domain.FlowNode, 0)
+	err := r.s.read(tx, func(t *tables) error {
+		for _, n := range t.flowNodes {
+			if n.FlowID == flowID {
+				nodes = append(nodes, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].SortOrder != nodes[j].SortOrder {
+			return nodes[i].SortOrder < nodes[j].SortOrder
+		}
+		return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+	})
+	return nodes, nil
+}
+
+// ReplaceTx replaces the full node list of a flow within the given transaction.
+// Nodes keep their IDs; sort_order is taken from the slice position.
+func (r *FlowNodeRepo) ReplaceTx(ctx context.Context, tx repository.Tx, flowID uuid.UUID, nodes []domain.FlowNode) error {
+	return r.s.write(tx, func(t *tables) error {
+		for id, n := range t.flowNodes {
+			if n.FlowID == flowID {
+				delete(t.flowNodes, id)
+			}
+		}
+
+		now := time.Now()
+		for i := range nodes {
+			n := &nodes[i]
+			if n.ID == uuid.Nil {
+				n.ID = uuid.New()
+			}
+			if _, taken := t.flowNodes[n.ID]; taken {
+				return domain.ErrAlreadyExists
+			}
+			n.FlowID = flowID
+			n.SortOrder = i
+			if n.CreatedAt.IsZero() {
+				n.CreatedAt = now
+			}
+			n.UpdatedAt = now
+			t.flowNodes[n.ID] = *n
+		}
+		return nil
+	})
+}
+
+var _ repository.FlowNodeRepository = (*FlowNodeRepo)(nil)
@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type FlowRepo struct {
+	s *Store
+}
+
+func NewFlowRepo(s *Store) *FlowRepo {
+	return &FlowRepo{s: s}
+}
+
+// NextFlowNoTx returns the next flow number for the current year (FLOW-YYYY-NNNN).
+func (r *FlowRepo) NextFlowNoTx(ctx context.Context, tx repository.Tx) (string, error) {
+	prefix := fmt.Sprintf("FLOW-%d-", time.Now().Year())
+	var last string
+	err := r.s.read(tx, func(t *tables) error {
+		for _, f := range t.flows {
+			if strings.HasPrefix(f.FlowNo, prefix) && f.FlowNo > last {
+				last = f.FlowNo
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	seq := 0
+	if last != "" {
+		fmt.Sscanf(last[len(prefix):], "%d", &seq) //nolint:errcheck
+	}
+	return fmt.Sprintf("%s%04d", prefix, seq+1), nil
+}
+
+func (r *FlowRepo) CreateTx(ctx context.Context, tx repository.Tx, flow *domain.Flow) error {
+	return r.s.write(tx, func(t *tables) error {
+		for _, f := range t.flows {
+			if f.FlowNo == flow.FlowNo {
+				return fmt.Errorf("creating flow: %w: flow_no %s", domain.ErrAlreadyExists, flow.FlowNo)
+			}
+		}
+		flow.ID = uuid.New()
+		flow.Revision = 1
+		now := time.Now()
+		if flow.CreatedAt.IsZero() {
+			flow.CreatedAt = now
+		}
+		flow.UpdatedAt = now
+		t.flows[flow.ID] = *flow
+		return nil
+	})
+}
+
+func (r *FlowRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Flow, error) {
+	return r.get(nil, id)
+}
+
+// GetByIDTx reads a flow within the given transaction.
+func (r *FlowRepo) GetByIDTx(ctx context.Context, tx repository.Tx, id uuid.UUID) (*domain.Flow, error) {
+	return r.get(tx, id)
+}
+
+func (r *FlowRepo) get(tx repository.Tx, id uuid.UUID) (*domain.Flow, error) {
+	var found *domain.Flow
+	err := r.s.read(tx, func(t *tables) error {
+		f, ok := t.flows[id]
+		if !ok || f.DeletedAt != nil {
+			return domain.ErrNotFound
+		}
+		found = &f
+		return nil
+	})
+	return found, err
+}
+
+// UpdateTx saves the flow header if it is still at flow.Revision, and bumps
+// the revision. It returns domain.ErrStaleRevision if another write got there first.
+func (r *FlowRepo) UpdateTx(ctx context.Context, tx repository.Tx, flow *domain.Flow) error {
+	return r.s.write(tx, func(t *tables) error {
+		stored, ok := t.flows[flow.ID]
+		if !ok || stored.Revision != flow.Revision {
+			return domain.ErrStaleRevision
+		}
+		flow.UpdatedAt = time.Now()
+		stored.Title = flow.Title
+		stored.OwnerDeptID = flow.OwnerDeptID
+		stored.Overview = flow.Overview
+		stored.Status = flow.Status
+		stored.DiagramJSON = flow.DiagramJSON
+		stored.LatestVersionID = flow.LatestVersionID
+		stored.Revision++
+		stored.UpdatedAt = flow.UpdatedAt
+		t.flows[flow.ID] = stored
+		flow.Revision++
+		return nil
+	})
+}
+
+// UpdateStatusTx moves a flow out of status from into flow.Status, also saving
+// latest_version_id. It returns false if the flow was no longer in status from.
+func (r *FlowRepo) UpdateStatusTx(ctx context.Context, tx repository.Tx, flow *domain.Flow, from domain.FlowStatus) (bool, error) {
+	var switched bool
+	err := r.s.write(tx, func(t *tables) error {
+		stored, ok := t.flows[flow.ID]
+		if !ok || stored.Status != from {
+			return nil
+		}
+		flow.UpdatedAt = time.Now()
+		stored.Status = flow.Status
+		stored.LatestVersionID = flow.LatestVersionID
+		stored.Revision++
+		stored.UpdatedAt = flow.UpdatedAt
+		t.flows[flow.ID] = stored
+		flow.Revision++
+		switched = true
+		return nil
+	})
+	return switched, err
+}
+
+// ListVisible returns flows visible to the given user (owner, effective or
+// published before, or shared with the user or one of their groups).
+func (r *FlowRepo) ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Flow, error) {
+	flows := make([]domain.Flow, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, f := range t.flows {
+			if canReadFlow(t, f, userID) {
+				flows = append(flows, f)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].UpdatedAt.After(flows[j].UpdatedAt) })
+	return flows, nil
+}
+
+// HasEditAccess checks if a user can edit a flow (owner, or share role=EDIT
+// with the user or one of their groups).
+func (r *FlowRepo) HasEditAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.s.read(nil, func(t *tables) error {
+		f, found := t.flows[flowID]
+		if !found || f.DeletedAt != nil {
+			return nil
+		}
+		role, shared := accessRole(t, domain.ShareResourceFlow, flowID, userID)
+		ok = f.OwnerID == userID || (shared && role == domain.ShareRoleEdit)
+		return nil
+	})
+	return ok, err
+}
+
+// HasReadAccess checks if a user can read a flow (owner, effective or
+// published before, or any share with the user or one of their groups).
+func (r *FlowRepo) HasReadAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.s.read(nil, func(t *tables) error {
+		f, found := t.flows[flowID]
+		ok = found && canReadFlow(t, f, userID)
+		return nil
+	})
+	return ok, err
+}
+
+// HasDraftAccess checks if a user can read a flow's working copy while it is
+// not effective (owner, or any share with the user or one of their groups).
+// Other readers only see its latest published version.
+func (r *FlowRepo) HasDraftAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.s.read(nil, func(t *tables) error {
+		f, found := t.flows[flowID]
+		ok = found && f.DeletedAt == nil && canReadDraft(t, f, userID)
+		return nil
+	})
+	return ok, err
+}
+
+func canReadFlow(t *tables, f domain.Flow, userID uuid.UUID) bool {
+	if f.DeletedAt != nil {
+		return false
+	}
+	if f.Status == domain.FlowStatusEffective || f.LatestVersionID != nil {
+		return true
+	}
+	return canReadDraft(t, f, userID)
+}
+
+func canReadDraft(t *tables, f domain.Flow, userID uuid.UUID) bool {
+	if f.OwnerID == userID {
+		return true
+	}
+	_, shared := accessRole(t, domain.ShareResourceFlow, f.ID, userID)
+	return shared
+}
+
+// SoftDelete moves a live flow to the trash.
+func (r *FlowRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		f, ok := t.flows[id]
+		if ok && f.DeletedAt == nil {
+			now := time.Now()
+			f.DeletedAt = &now
+			t.flows[id] = f
+		}
+		return nil
+	})
+}
+
+// UpdateOwnerTx moves a flow from owner from to owner to and bumps its
+// revision. It returns false if the flow is no longer owned by from.
+func (r *FlowRepo) UpdateOwnerTx(ctx context.Context, tx repository.Tx, id, from, to uuid.UUID) (bool, error) {
+	var moved bool
+	err := r.s.write(tx, func(t *tables) error {
+		f, ok := t.flows[id]
+		if !ok || f.OwnerID != from {
+			return nil
+		}
+		f.OwnerID = to
+		f.Revision++
+		f.UpdatedAt = time.Now()
+		t.flows[id] = f
+		moved = true
+		return nil
+	})
+	return moved, err
+}
+
+// ListIDsByOwnerTx returns the IDs of all flows owned by a user.
+func (r *FlowRepo) ListIDsByOwnerTx(ctx context.Context, tx repository.Tx, ownerID uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	err := r.s.read(tx, func(t *tables) error {
+		for _, f := range t.flows {
+			if f.OwnerID == ownerID {
+				ids = append(ids, f.ID)
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// ListDeletedByOwner returns the owner's flows in the trash, most recently deleted first.
+func (r *FlowRepo) ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Flow, error) {
+	flows := make([]domain.Flow, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, f := range t.flows {
+			if f.OwnerID == ownerID && f.DeletedAt != nil {
+				flows = append(flows, f)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].DeletedAt.After(*flows[j].DeletedAt) })
+	return flows, nil
+}
+
+// Restore takes a flow owned by ownerID out of the trash. It returns false
+// if no such flow is in the trash.
+func (r *FlowRepo) Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	var restored bool
+	err := r.s.write(nil, func(t *tables) error {
+		f, ok := t.flows[id]
+		if !ok || f.OwnerID != ownerID || f.DeletedAt == nil {
+			return nil
+		}
+		f.DeletedAt = nil
+		t.flows[id] = f
+		restored = true
+		return nil
+	})
+	return restored, err
+}
+
+// PurgeDeletedTx hard-deletes flows trashed before the cutoff, together
+// with their nodes, versions and shares.
+func (r *FlowRepo) PurgeDeletedTx(ctx context.Context, tx repository.Tx, before time.Time) (int64, error) {
+	var purged int64
+	err := r.s.write(tx, func(t *tables) error {
+		for id, f := range t.flows {
+			if f.DeletedAt != nil && f.DeletedAt.Before(before) {
+				deleteFlow(t, id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+var _ repository.FlowRepository = (*FlowRepo)(nil)
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type FlowVersionRepo struct {
+	s *Store
+}
+
+func NewFlowVersionRepo(s *Store) *FlowVersionRepo {
+	return &FlowVersionRepo{s: s}
+}
+
+func (r *FlowVersionRepo) CreateTx(ctx context.Context, tx repository.Tx, v *domain.FlowVersion) error {
+	return r.s.write(tx, func(t *tables) error {
+		v.ID = uuid.New()
+		if v.CreatedAt.IsZero() {
+			v.CreatedAt = time.Now()
+		}
+		stored := *v
+		stored.Snapshot = nil
+		t.flowVersions[v.ID] = stored
+		return nil
+	})
+}
+
+// ListByFlow returns version metadata (without snapshot content), newest first.
+func (r *FlowVersionRepo) ListByFlow(ctx context.Context, flowID uuid.UUID) ([]domain.FlowVersion, error) {
+	versions := make([]domain.FlowVersion, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, v := range t.flowVersions {
+			if v.FlowID == flowID {
+				v.SnapshotJSON = ""
+				versions = append(versions, v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].CreatedAt.After(versions[j].CreatedAt) })
+	return versions, nil
+}
+
+// GetByID returns a version including its parsed snapshot.
+func (r *FlowVersionRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.FlowVersion, error) {
+	var found *domain.FlowVersion
+	err := r.s.read(nil, func(t *tables) error {
+		v, ok := t.flowVersions[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := found.HydrateJSON(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+var _ repository.FlowVersionRepository = (*FlowVersionRepo)(nil)
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type groupMemberKey struct {
+	groupID, userID uuid.UUID
+}
+
+type GroupRepo struct {
+	s *Store
+}
+
+func NewGroupRepo(s *Store) *GroupRepo {
+	return &GroupRepo{s: s}
+}
+
+func (r *GroupRepo) Create(ctx context.Context, group *domain.Group) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, g := range t.groups {
+			if g.Name == group.Name {
+				return fmt.Errorf("%w: group name already taken", domain.ErrAlreadyExists)
+			}
+		}
+		group.ID = uuid.New()
+		group.CreatedAt = time.Now()
+		group.UpdatedAt = group.CreatedAt
+		t.groups[group.ID] = *group
+		return nil
+	})
+}
+
+func (r *GroupRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Group, error) {
+	var found *domain.Group
+	err := r.s.read(nil, func(t *tables) error {
+		g, ok := t.groups[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &g
+		return nil
+	})
+	return found, err
+}
+
+func (r *GroupRepo) List(ctx context.Context) ([]domain.Group, error) {
+	groups := make([]domain.Group, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, g := range t.groups {
+			groups = append(groups, g)
+		}
+		return nil
+	})
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, err
+}
+
+func (r *GroupRepo) Update(ctx context.Context, group *domain.Group) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, g := range t.groups {
+			if g.Name == group.Name && g.ID != group.ID {
+				return fmt.Errorf("%w: group name already taken", domain.ErrAlreadyExists)
+			}
+		}
+		g, ok := t.groups[group.ID]
+		if !ok {
+			return nil
+		}
+		group.UpdatedAt = time.Now()
+		g.Name, g.ExternalID, g.UpdatedAt = group.Name, group.ExternalID, group.UpdatedAt
+		t.groups[group.ID] = g
+		return nil
+	})
+}
+
+func (r *GroupRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		if _, ok := t.groups[id]; !ok {
+			return domain.ErrNotFound
+		}
+		for key := range t.groupMembers {
+			if key.groupID == id {
+				delete(t.groupMembers, key)
+			}
+		}
+		for _, shares := range []map[uuid.UUID]domain.GroupShare{t.docGroupShares, t.flowGroupShares} {
+			for shareID, sh := range shares {
+				if sh.GroupID == id {
+					delete(shares, shareID)
+				}
+			}
+		}
+		delete(t.groups, id)
+		return nil
+	})
+}
+
+func (r *GroupRepo) ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error) {
+	return r.members(func(m domain.GroupMember) bool { return m.GroupID == groupID })
+}
+
+func (r *GroupRepo) ListMemberships(ctx context.Context) ([]domain.GroupMember, error) {
+	return r.members(func(domain.GroupMember) bool { return true })
+}
+
+func (r *GroupRepo) members(keep func(domain.GroupMember) bool) ([]domain.GroupMember, error) {
+	members := make([]domain.GroupMember, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, m := range t.groupMembers {
+			if keep(m) {
+				members = append(members, m)
+			}
+		}
+		return nil
+	})
+	sort.Slice(members, func(i, j int) bool { return members[i].CreatedAt.Before(members[j].CreatedAt) })
+	return members, err
+}
+
+func (r *GroupRepo) GetMember(ctx context.Context, groupID, userID uuid.UUID) (*domain.GroupMember, error) {
+	var found *domain.GroupMember
+	err := r.s.read(nil, func(t *tables) error {
+		m, ok := t.groupMembers[groupMemberKey{groupID, userID}]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &m
+		return nil
+	})
+	return found, err
+}
+
+func (r *GroupRepo) AddMember(ctx context.Context, groupID, userID uuid.UUID, role domain.GroupRole) error {
+	return r.s.write(nil, func(t *tables) error {
+		key := groupMemberKey{groupID, userID}
+		if _, ok := t.groupMembers[key]; ok {
+			return nil
+		}
+		t.groupMembers[key] = domain.GroupMember{GroupID: groupID, UserID: userID, Role: role, CreatedAt: time.Now()}
+		return nil
+	})
+}
+
+// ListOwnersTx returns the user IDs of a group's owners. Transactions on the
+// store run one at a time, so nothing needs locking.
+func (r *GroupRepo) ListOwnersTx(ctx context.Context, tx repository.Tx, groupID uuid.UUID) ([]uuid.UUID, error) {
+	owners := make([]uuid.UUID, 0)
+	err := r.s.read(tx, func(t *tables) error {
+		for _, m := range t.groupMembers {
+			if m.GroupID == groupID && m.Role == domain.GroupRoleOwner {
+				owners = append(owners, m.UserID)
+			}
+		}
+		return nil
+	})
+	return owners, err
+}
+
+func (r *GroupRepo) UpdateMemberRoleTx(ctx context.Context, tx repository.Tx, groupID, userID uuid.UUID, role domain.GroupRole) error {
+	return r.s.write(tx, func(t *tables) error {
+		key := groupMemberKey{groupID, userID}
+		if m, ok := t.groupMembers[key]; ok {
+			m.Role = role
+			t.groupMembers[key] = m
+		}
+		return nil
+	})
+}
+
+func (r *GroupRepo) RemoveMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	return r.RemoveMemberTx(ctx, nil, groupID, userID)
+}
+
+func (r *GroupRepo) RemoveMemberTx(ctx context.Context, tx repository.Tx, groupID, userID uuid.UUID) error {
+	return r.s.write(tx, func(t *tables) error {
+		delete(t.groupMembers, groupMemberKey{groupID, userID})
+		return nil
+	})
+}
+
+var _ repository.GroupRepository = (*GroupRepo)(nil)
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// GroupShareRepo manages group grants on documents or flows, depending on
+// the constructor.
+type GroupShareRepo struct {
+	s        *Store
+	resource domain.ShareResource
+}
+
+func NewDocumentGroupShareRepo(s *Store) *GroupShareRepo {
+	return &GroupShareRepo{s: s, resource: domain.ShareResourceDocument}
+}
+
+func NewFlowGroupShareRepo(s *Store) *GroupShareRepo {
+	return &GroupShareRepo{s: s, resource: domain.ShareResourceFlow}
+}
+
+func (r *GroupShareRepo) table(t *tables) map[uuid.UUID]domain.GroupShare {
+	if r.resource == domain.ShareResourceFlow {
+		return t.flowGroupShares
+	}
+	return t.docGroupShares
+}
+
+// Create inserts a group share. A second share for the same (resource,
+// group) pair returns domain.ErrAlreadyExists.
+func (r *GroupShareRepo) Create(ctx context.Context, share *domain.GroupShare) error {
+	return r.insert(nil, share)
+}
+
+// CreateTx inserts a group share within the given transaction.
+func (r *GroupShareRepo) CreateTx(ctx context.Context, tx repository.Tx, share *domain.GroupShare) error {
+	return r.insert(tx, share)
+}
+
+func (r *GroupShareRepo) insert(tx repository.Tx, share *domain.GroupShare) error {
+	return r.s.write(tx, func(t *tables) error {
+		for _, sh := range r.table(t) {
+			if sh.ResourceID == share.ResourceID && sh.GroupID == share.GroupID {
+				return fmt.Errorf("%w: group already has a share", domain.ErrAlreadyExists)
+			}
+		}
+		share.ID = uuid.New()
+		share.Resource = r.resource
+		share.CreatedAt = time.Now()
+		stored := *share
+		stored.GroupName = "" // joined from user_groups on read
+		r.table(t)[share.ID] = stored
+		return nil
+	})
+}
+
+func (r *GroupShareRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.GroupShare, error) {
+	var found *domain.GroupShare
+	err := r.s.read(nil, func(t *tables) error {
+		sh, ok := r.withName(t, r.table(t)[id])
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &sh
+		return nil
+	})
+	return found, err
+}
+
+// ListByResource returns all group shares of a document or flow, oldest
+// first.
+func (r *GroupShareRepo) ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.GroupShare, error) {
+	shares := make([]domain.GroupShare, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, sh := range r.table(t) {
+			if sh.ResourceID != resourceID {
+				continue
+			}
+			if sh, ok := r.withName(t, sh); ok {
+				shares = append(shares, sh)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.Before(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+// withName fills in the group's name, as the SQL join on user_groups does.
+func (r *GroupShareRepo) withName(t *tables, sh domain.GroupShare) (domain.GroupShare, bool) {
+	g, ok := t.groups[sh.GroupID]
+	if !ok || sh.ID == uuid.Nil {
+		return domain.GroupShare{}, false
+	}
+	sh.GroupName = g.Name
+	return sh, true
+}
+
+func (r *GroupShareRepo) UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error {
+	return r.s.write(nil, func(t *tables) error {
+		if sh, ok := r.table(t)[id]; ok {
+			sh.Role = role
+			r.table(t)[id] = sh
+		}
+		return nil
+	})
+}
+
+func (r *GroupShareRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		delete(r.table(t), id)
+		return nil
+	})
+}
+
+var _ repository.GroupShareRepository = (*GroupShareRepo)(nil)
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type LoginThrottleRepo struct {
+	s *Store
+}
+
+func NewLoginThrottleRepo(s *Store) *LoginThrottleRepo {
+	return &LoginThrottleRepo{s: s}
+}
+
+func (r *LoginThrottleRepo) Get(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	var found *domain.LoginThrottle
+	err := r.s.read(nil, func(t *tables) error {
+		lt, ok := t.loginThrottles[key]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &lt
+		return nil
+	})
+	return found, err
+}
+
+func (r *LoginThrottleRepo) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error) {
+	var failures int
+	err := r.s.write(nil, func(t *tables) error {
+		lt, ok := t.loginThrottles[key]
+		if !ok || lt.LastFailureAt.Before(resetBefore) {
+			lt = domain.LoginThrottle{Key: key}
+		}
+		lt.Failures++
+		lt.LastFailureAt = at
+		t.loginThrottles[key] = lt
+		failures = lt.Failures
+		return nil
+	})
+	return failures, err
+}
+
+func (r *LoginThrottleRepo) List(ctx context.Context, since time.Time) ([]domain.LoginThrottle, error) {
+	throttles := make([]domain.LoginThrottle, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, lt := range t.loginThrottles {
+			if !lt.LastFailureAt.Before(since) {
+				throttles = append(throttles, lt)
+			}
+		}
+		return nil
+	})
+	sort.Slice(throttles, func(i, j int) bool { return throttles[i].LastFailureAt.After(throttles[j].LastFailureAt) })
+	return throttles, err
+}
+
+func (r *LoginThrottleRepo) Delete(ctx context.Context, key string) error {
+	return r.s.write(nil, func(t *tables) error {
+		if _, ok := t.loginThrottles[key]; !ok {
+			return domain.ErrNotFound
+		}
+		delete(t.loginThrottles, key)
+		return nil
+	})
+}
+
+func (r *LoginThrottleRepo) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := r.s.write(nil, func(t *tables) error {
+		for key, lt := range t.loginThrottles {
+			if lt.LastFailureAt.Before(before) {
+				delete(t.loginThrottles, key)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// passwordEntry is a row of the password history.
+type passwordEntry struct {
+	UserID    uuid.UUID
+	Hash      string
+	CreatedAt time.Time
+}
+
+type PasswordHistoryRepo struct {
+	s *Store
+}
+
+func NewPasswordHistoryRepo(s *Store) *PasswordHistoryRepo {
+	return &PasswordHistoryRepo{s: s}
+}
+
+func (r *PasswordHistoryRepo) Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error {
+	return r.s.write(nil, func(t *tables) error {
+		t.passwordHistory[uuid.New()] = passwordEntry{UserID: userID, Hash: hash, CreatedAt: time.Now()}
+		ids := userPasswordHistory(t, userID)
+		for _, id := range ids[min(keep, len(ids)):] {
+			delete(t.passwordHistory, id)
+		}
+		return nil
+	})
+}
+
+func (r *PasswordHistoryRepo) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	hashes := make([]string, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		ids := userPasswordHistory(t, userID)
+		for _, id := range ids[:min(limit, len(ids))] {
+			hashes = append(hashes, t.passwordHistory[id].Hash)
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// userPasswordHistory returns the ids of the user's history entries, newest
+// first.
+func userPasswordHistory(t *tables, userID uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for id, e := range t.passwordHistory {
+		if e.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return t.passwordHistory[ids[i]].CreatedAt.After(t.passwordHistory[ids[j]].CreatedAt)
+	})
+	return ids
+}
+
+var (
+	_ repository.LoginThrottleRepository   = (*LoginThrottleRepo)(nil)
+	_ repository.PasswordHistoryRepository = (*PasswordHistoryRepo)(nil)
+)
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// OwnershipTransferRepo stores the ownership history of documents and flows.
+type OwnershipTransferRepo struct {
+	s *Store
+}
+
+func NewOwnershipTransferRepo(s *Store) *OwnershipTransferRepo {
+	return &OwnershipTransferRepo{s: s}
+}
+
+func (r *OwnershipTransferRepo) CreateTx(ctx context.Context, tx repository.Tx, transfer *domain.OwnershipTransfer) error {
+	return r.s.write(tx, func(t *tables) error {
+		transfer.ID = uuid.New()
+		transfer.CreatedAt = time.Now()
+		t.transfers[transfer.ID] = *transfer
+		return nil
+	})
+}
+
+// ListByResource returns the transfers of one document or flow, newest first.
+func (r *OwnershipTransferRepo) ListByResource(ctx context.Context, resourceType domain.ShareResource, resourceID uuid.UUID) ([]domain.OwnershipTransfer, error) {
+	transfers := make([]domain.OwnershipTransfer, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, tr := range t.transfers {
+			if tr.ResourceType == resourceType && tr.ResourceID == resourceID {
+				transfers = append(transfers, tr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].CreatedAt.After(transfers[j].CreatedAt) })
+	return transfers, nil
+}
+
+// DeleteOrphansTx removes history rows whose document or flow no longer exists.
+func (r *OwnershipTransferRepo) DeleteOrphansTx(ctx context.Context, tx repository.Tx) error {
+	return r.s.write(tx, func(t *tables) error {
+		maps.DeleteFunc(t.transfers, func(_ uuid.UUID, tr domain.OwnershipTransfer) bool {
+			switch tr.ResourceType {
+			case domain.ShareResourceDocument:
+				_, ok := t.documents[tr.ResourceID]
+				return !ok
+			case domain.ShareResourceFlow:
+				_, ok := t.flows[tr.ResourceID]
+				return !ok
+			}
+			return false
+		})
+		return nil
+	})
+}
+
+var _ repository.OwnershipTransferRepository = (*OwnershipTransferRepo)(nil)
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepo struct {
+	s *Store
+}
+
+func NewRefreshTokenRepo(s *Store) *RefreshTokenRepo {
+	return &RefreshTokenRepo{s: s}
+}
+
+func (r *RefreshTokenRepo) Create(ctx context.Context, rt *domain.RefreshToken) error {
+	return r.s.write(nil, func(t *tables) error {
+		rt.ID = uuid.New()
+		rt.CreatedAt = time.Now()
+		t.refreshTokens[rt.ID] = *rt
+		return nil
+	})
+}
+
+func (r *RefreshTokenRepo) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	var found *domain.RefreshToken
+	err := r.s.read(nil, func(t *tables) error {
+		for _, rt := range t.refreshTokens {
+			if rt.TokenHash == hash {
+				found = &rt
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return found, err
+}
+
+// MarkUsed consumes a token; it returns false if the token was already used
+// or revoked.
+func (r *RefreshTokenRepo) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	var ok bool
+	err := r.s.write(nil, func(t *tables) error {
+		rt, exists := t.refreshTokens[id]
+		if !exists || rt.UsedAt != nil || rt.RevokedAt != nil {
+			return nil
+		}
+		rt.UsedAt = &at
+		t.refreshTokens[id] = rt
+		ok = true
+		return nil
+	})
+	return ok, err
+}
+
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	return r.s.write(nil, func(t *tables) error {
+		for id, rt := range t.refreshTokens {
+			if rt.FamilyID == familyID && rt.RevokedAt == nil {
+				rt.RevokedAt = &at
+				t.refreshTokens[id] = rt
+			}
+		}
+		return nil
+	})
+}
+
+func (r *RefreshTokenRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := r.s.write(nil, func(t *tables) error {
+		for id, rt := range t.refreshTokens {
+			if rt.ExpiresAt.Before(before) {
+				delete(t.refreshTokens, id)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+var _ repository.RefreshTokenRepository = (*RefreshTokenRepo)(nil)
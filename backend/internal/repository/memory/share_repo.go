@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ShareRepo manages grants on documents or flows, depending on the constructor.
+type ShareRepo struct {
+	s        *Store
+	resource domain.ShareResource
+}
+
+func NewDocumentShareRepo(s *Store) *ShareRepo {
+	return &ShareRepo{s: s, resource: domain.ShareResourceDocument}
+}
+
+func NewFlowShareRepo(s *Store) *ShareRepo {
+	return &ShareRepo{s: s, resource: domain.ShareResourceFlow}
+}
+
+func (r *ShareRepo) table(t *tables) map[uuid.UUID]domain.Share {
+	if r.resource == domain.ShareResourceFlow {
+		return t.flowShares
+	}
+	return t.docShares
+}
+
+// Create inserts a share. A second share for the same (resource, user) pair
+// returns domain.ErrAlreadyExists.
+func (r *ShareRepo) Create(ctx context.Context, share *domain.Share) error {
+	return r.insert(nil, share)
+}
+
+// CreateTx inserts a share within the given transaction.
+func (r *ShareRepo) CreateTx(ctx context.Context, tx repository.Tx, share *domain.Share) error {
+	return r.insert(tx, share)
+}
+
+func (r *ShareRepo) insert(tx repository.Tx, share *domain.Share) error {
+	return r.s.write(tx, func(t *tables) error {
+		if _, taken := shareRole(r.table(t), share.ResourceID, share.UserID); taken {
+			return fmt.Errorf("%w: user already has a share", domain.ErrAlreadyExists)
+		}
+		share.ID = uuid.New()
+		share.Resource = r.resource
+		share.CreatedAt = time.Now()
+		stored := *share
+		stored.UserEmail = "" // joined from users on read
+		r.table(t)[share.ID] = stored
+		return nil
+	})
+}
+
+func (r *ShareRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Share, error) {
+	var found *domain.Share
+	err := r.s.read(nil, func(t *tables) error {
+		sh, ok := r.withEmail(t, r.table(t)[id])
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &sh
+		return nil
+	})
+	return found, err
+}
+
+// ListByResource returns all shares of a document or flow, oldest first.
+func (r *ShareRepo) ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.Share, error) {
+	shares := make([]domain.Share, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, sh := range r.table(t) {
+			if sh.ResourceID != resourceID {
+				continue
+			}
+			if sh, ok := r.withEmail(t, sh); ok {
+				shares = append(shares, sh)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.Before(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+// withEmail fills in the grantee's email, as the SQL join on users does. A
+// share whose user is gone (or a zero share) is reported as missing.
+func (r *ShareRepo) withEmail(t *tables, sh domain.Share) (domain.Share, bool) {
+	u, ok := t.users[sh.UserID]
+	if !ok || sh.ID == uuid.Nil {
+		return domain.Share{}, false
+	}
+	sh.UserEmail = u.Email
+	return sh, true
+}
+
+func (r *ShareRepo) UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error {
+	return r.s.write(nil, func(t *tables) error {
+		if sh, ok := r.table(t)[id]; ok {
+			sh.Role = role
+			r.table(t)[id] = sh
+		}
+		return nil
+	})
+}
+
+func (r *ShareRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		delete(r.table(t), id)
+		return nil
+	})
+}
+
+// DeleteByUserTx removes the share a user holds on a resource, if any.
+func (r *ShareRepo) DeleteByUserTx(ctx context.Context, tx repository.Tx, resourceID, userID uuid.UUID) error {
+	return r.s.write(tx, func(t *tables) error {
+		maps.DeleteFunc(r.table(t), func(_ uuid.UUID, sh domain.Share) bool {
+			return sh.ResourceID == resourceID && sh.UserID == userID
+		})
+		return nil
+	})
+}
+
+var _ repository.ShareRepository = (*ShareRepo)(nil)
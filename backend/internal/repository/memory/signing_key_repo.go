@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+)
+
+type SigningKeyRepo struct {
+	s *Store
+}
+
+func NewSigningKeyRepo(s *Store) *SigningKeyRepo {
+	return &SigningKeyRepo{s: s}
+}
+
+func (r *SigningKeyRepo) Create(ctx context.Context, key *domain.SigningKey) error {
+	return r.s.write(nil, func(t *tables) error {
+		if _, ok := t.signingKeys[key.ID]; ok {
+			return fmt.Errorf("%w: signing key %s", domain.ErrAlreadyExists, key.ID)
+		}
+		key.CreatedAt = time.Now()
+		t.signingKeys[key.ID] = *key
+		return nil
+	})
+}
+
+func (r *SigningKeyRepo) List(ctx context.Context) ([]domain.SigningKey, error) {
+	keys := []domain.SigningKey{}
+	err := r.s.read(nil, func(t *tables) error {
+		for _, k := range t.signingKeys {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, err
+}
+
+func (r *SigningKeyRepo) Delete(ctx context.Context, id string) error {
+	return r.s.write(nil, func(t *tables) error {
+		delete(t.signingKeys, id)
+		return nil
+	})
+}
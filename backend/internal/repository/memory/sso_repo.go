@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type UserIdentityRepo struct {
+	s *Store
+}
+
+func NewUserIdentityRepo(s *Store) *UserIdentityRepo {
+	return &UserIdentityRepo{s: s}
+}
+
+func (r *UserIdentityRepo) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, i := range t.identities {
+			if i.Provider == identity.Provider && (i.Subject == identity.Subject || i.UserID == identity.UserID) {
+				return fmt.Errorf("%w: identity already linked", domain.ErrAlreadyExists)
+			}
+		}
+		identity.ID = uuid.New()
+		identity.CreatedAt = time.Now()
+		t.identities[identity.ID] = *identity
+		return nil
+	})
+}
+
+func (r *UserIdentityRepo) Get(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	var found *domain.UserIdentity
+	err := r.s.read(nil, func(t *tables) error {
+		for _, i := range t.identities {
+			if i.Provider == provider && i.Subject == subject {
+				found = &i
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return found, err
+}
+
+func (r *UserIdentityRepo) GetByUser(ctx context.Context, userID uuid.UUID, provider string) (*domain.UserIdentity, error) {
+	var found *domain.UserIdentity
+	err := r.s.read(nil, func(t *tables) error {
+		for _, i := range t.identities {
+			if i.UserID == userID && i.Provider == provider {
+				found = &i
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return found, err
+}
+
+func (r *UserIdentityRepo) ListByProvider(ctx context.Context, provider string) ([]domain.UserIdentity, error) {
+	identities := make([]domain.UserIdentity, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, i := range t.identities {
+			if i.Provider == provider {
+				identities = append(identities, i)
+			}
+		}
+		return nil
+	})
+	sort.Slice(identities, func(i, j int) bool { return identities[i].CreatedAt.Before(identities[j].CreatedAt) })
+	return identities, err
+}
+
+func (r *UserIdentityRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		delete(t.identities, id)
+		return nil
+	})
+}
+
+type OIDCLoginRepo struct {
+	s *Store
+}
+
+func NewOIDCLoginRepo(s *Store) *OIDCLoginRepo {
+	return &OIDCLoginRepo{s: s}
+}
+
+func (r *OIDCLoginRepo) Create(ctx context.Context, login *domain.OIDCLogin) error {
+	return r.s.write(nil, func(t *tables) error {
+		login.CreatedAt = time.Now()
+		t.oidcLogins[login.State] = *login
+		return nil
+	})
+}
+
+func (r *OIDCLoginRepo) Take(ctx context.Context, state string) (*domain.OIDCLogin, error) {
+	var found *domain.OIDCLogin
+	err := r.s.write(nil, func(t *tables) error {
+		login, ok := t.oidcLogins[state]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		delete(t.oidcLogins, state)
+		found = &login
+		return nil
+	})
+	return found, err
+}
+
+func (r *OIDCLoginRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := r.s.write(nil, func(t *tables) error {
+		for state, login := range t.oidcLogins {
+			if login.ExpiresAt.Before(before) {
+				delete(t.oidcLogins, state)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+var (
+	_ repository.UserIdentityRepository = (*UserIdentityRepo)(nil)
+	_ repository.OIDCLoginRepository    = (*OIDCLoginRepo)(nil)
+)
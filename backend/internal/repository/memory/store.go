@@ -0,0 +1,259 @@
+// Package memory implements the repository interfaces on in-memory tables,
+// for service tests that should not need a database. Access rules, revision
+// checks and error values follow the SQL repositories in package repository.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"sync"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the tables shared by the repositories built on it. It is also
+// their repository.TxManager.
+//
+// Transactions are serialized, like writers on a SQLite file: Begin waits
+// until no other transaction is open, and the transaction then works on a
+// private copy of the tables that replaces the committed ones on Commit.
+// Reads outside a transaction see committed data only. Writes outside a
+// transaction commit immediately and therefore also wait for an open
+// transaction to finish; making one while holding a transaction deadlocks.
+type Store struct {
+	txMu   sync.Mutex   // held by the open transaction, if any
+	mu     sync.RWMutex // guards tables
+	tables *tables
+}
+
+type tables struct {
+	users         map[uuid.UUID]domain.User
+	documents     map[uuid.UUID]domain.Document
+	versions      map[uuid.UUID]domain.DocumentVersion
+	flows         map[uuid.UUID]domain.Flow
+	flowNodes     map[uuid.UUID]domain.FlowNode
+	flowVersions  map[uuid.UUID]domain.FlowVersion
+	docShares     map[uuid.UUID]domain.Share
+	flowShares    map[uuid.UUID]domain.Share
+	refreshTokens map[uuid.UUID]domain.RefreshToken
+	apiTokens     map[uuid.UUID]domain.APIToken
+	workflowNodes map[uuid.UUID]domain.WorkflowNode
+	transfers     map[uuid.UUID]domain.OwnershipTransfer
+	conversions   map[uuid.UUID]domain.DocumentConversion // by document ID
+
+	loginThrottles  map[string]domain.LoginThrottle
+	passwordHistory map[uuid.UUID]passwordEntry
+	recoveryCodes   map[uuid.UUID]recoveryCode
+	settings        map[string]string
+	identities      map[uuid.UUID]domain.UserIdentity
+	oidcLogins      map[string]domain.OIDCLogin
+	groups          map[uuid.UUID]domain.Group
+	groupMembers    map[groupMemberKey]domain.GroupMember
+	docGroupShares  map[uuid.UUID]domain.GroupShare
+	flowGroupShares map[uuid.UUID]domain.GroupShare
+	signingKeys     map[string]domain.SigningKey
+}
+
+func NewStore() *Store {
+	return &Store{tables: &tables{
+		users:         make(map[uuid.UUID]domain.User),
+		documents:     make(map[uuid.UUID]domain.Document),
+		versions:      make(map[uuid.UUID]domain.DocumentVersion),
+		flows:         make(map[uuid.UUID]domain.Flow),
+		flowNodes:     make(map[uuid.UUID]domain.FlowNode),
+		flowVersions:  make(map[uuid.UUID]domain.FlowVersion),
+		docShares:     make(map[uuid.UUID]domain.Share),
+		flowShares:    make(map[uuid.UUID]domain.Share),
+		refreshTokens: make(map[uuid.UUID]domain.RefreshToken),
+		apiTokens:     make(map[uuid.UUID]domain.APIToken),
+		workflowNodes: make(map[uuid.UUID]domain.WorkflowNode),
+		transfers:     make(map[uuid.UUID]domain.OwnershipTransfer),
+		conversions:   make(map[uuid.UUID]domain.DocumentConversion),
+
+		loginThrottles:  make(map[string]domain.LoginThrottle),
+		passwordHistory: make(map[uuid.UUID]passwordEntry),
+		recoveryCodes:   make(map[uuid.UUID]recoveryCode),
+		settings:        make(map[string]string),
+		identities:      make(map[uuid.UUID]domain.UserIdentity),
+		oidcLogins:      make(map[string]domain.OIDCLogin),
+		groups:          make(map[uuid.UUID]domain.Group),
+		groupMembers:    make(map[groupMemberKey]domain.GroupMember),
+		docGroupShares:  make(map[uuid.UUID]domain.GroupShare),
+		flowGroupShares: make(map[uuid.UUID]domain.GroupShare),
+		signingKeys:     make(map[string]domain.SigningKey),
+	}}
+}
+
+// clone copies every table. Rows are plain values, so copying the maps is
+// enough to isolate a transaction.
+func (t *tables) clone() *tables {
+	return &tables{
+		users:         maps.Clone(t.users),
+		documents:     maps.Clone(t.documents),
+		versions:      maps.Clone(t.versions),
+		flows:         maps.Clone(t.flows),
+		flowNodes:     maps.Clone(t.flowNodes),
+		flowVersions:  maps.Clone(t.flowVersions),
+		docShares:     maps.Clone(t.docShares),
+		flowShares:    maps.Clone(t.flowShares),
+		refreshTokens: maps.Clone(t.refreshTokens),
+		apiTokens:     maps.Clone(t.apiTokens),
+		workflowNodes: maps.Clone(t.workflowNodes),
+		transfers:     maps.Clone(t.transfers),
+		conversions:   maps.Clone(t.conversions),
+
+		loginThrottles:  maps.Clone(t.loginThrottles),
+		passwordHistory: maps.Clone(t.passwordHistory),
+		recoveryCodes:   maps.Clone(t.recoveryCodes),
+		settings:        maps.Clone(t.settings),
+		identities:      maps.Clone(t.identities),
+		oidcLogins:      maps.Clone(t.oidcLogins),
+		groups:          maps.Clone(t.groups),
+		groupMembers:    maps.Clone(t.groupMembers),
+		docGroupShares:  maps.Clone(t.docGroupShares),
+		flowGroupShares: maps.Clone(t.flowGroupShares),
+		signingKeys:     maps.Clone(t.signingKeys),
+	}
+}
+
+// Begin waits for any open transaction to finish and starts a new one.
+func (s *Store) Begin(ctx context.Context) (repository.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.txMu.Lock()
+	s.mu.RLock()
+	working := s.tables.clone()
+	s.mu.RUnlock()
+	return &tx{store: s, tables: working}, nil
+}
+
+type tx struct {
+	store  *Store
+	tables *tables
+	done   bool
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.store.mu.Lock()
+	t.store.tables = t.tables
+	t.store.mu.Unlock()
+	t.store.txMu.Unlock()
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.store.txMu.Unlock()
+	return nil
+}
+
+// working returns the tables a transaction operates on. Like the SQL
+// repositories, it treats a foreign transaction as a programming error.
+func (s *Store) working(rtx repository.Tx) *tables {
+	t, ok := rtx.(*tx)
+	if !ok || t.store != s {
+		panic(fmt.Sprintf("memory: %T is not a transaction of this store", rtx))
+	}
+	if t.done {
+		panic("memory: transaction already committed or rolled back")
+	}
+	return t.tables
+}
+
+// read runs fn on the committed tables, or on rtx's copy when rtx is not nil.
+func (s *Store) read(rtx repository.Tx, fn func(*tables) error) error {
+	if rtx != nil {
+		return fn(s.working(rtx))
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.tables)
+}
+
+// write runs fn on rtx's copy of the tables, or, when rtx is nil, on the
+// committed tables as a transaction of its own.
+func (s *Store) write(rtx repository.Tx, fn func(*tables) error) error {
+	if rtx != nil {
+		return fn(s.working(rtx))
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.tables)
+}
+
+// ── Access rules shared by documents and flows ─────────────────────────────
+
+// shareRole returns the role userID holds on a resource, if any.
+func shareRole(shares map[uuid.UUID]domain.Share, resourceID, userID uuid.UUID) (domain.ShareRole, bool) {
+	for _, sh := range shares {
+		if sh.ResourceID == resourceID && sh.UserID == userID {
+			return sh.Role, true
+		}
+	}
+	return "", false
+}
+
+// accessRole returns the strongest role userID holds on a resource through
+// their own share or a share with one of their groups, if any.
+func accessRole(t *tables, res domain.ShareResource, resourceID, userID uuid.UUID) (domain.ShareRole, bool) {
+	shares, groupShares := t.docShares, t.docGroupShares
+	if res == domain.ShareResourceFlow {
+		shares, groupShares = t.flowShares, t.flowGroupShares
+	}
+	role, shared := shareRole(shares, resourceID, userID)
+	for _, sh := range groupShares {
+		if sh.ResourceID != resourceID {
+			continue
+		}
+		if _, member := t.groupMembers[groupMemberKey{sh.GroupID, userID}]; member {
+			role, shared = maxShareRole(role, sh.Role), true
+		}
+	}
+	return role, shared
+}
+
+func maxShareRole(a, b domain.ShareRole) domain.ShareRole {
+	if a == domain.ShareRoleEdit || b == domain.ShareRoleEdit {
+		return domain.ShareRoleEdit
+	}
+	return domain.ShareRoleView
+}
+
+// ── Cascades ──────────────────────────────────────────────────────────────
+
+// deleteDocument removes a document and the rows that reference it with ON
+// DELETE CASCADE in the SQL schema.
+func deleteDocument(t *tables, id uuid.UUID) {
+	delete(t.documents, id)
+	maps.DeleteFunc(t.versions, func(_ uuid.UUID, v domain.DocumentVersion) bool { return v.DocumentID == id })
+	maps.DeleteFunc(t.docShares, func(_ uuid.UUID, sh domain.Share) bool { return sh.ResourceID == id })
+	maps.DeleteFunc(t.docGroupShares, func(_ uuid.UUID, sh domain.GroupShare) bool { return sh.ResourceID == id })
+	maps.DeleteFunc(t.workflowNodes, func(_ uuid.UUID, n domain.WorkflowNode) bool { return n.DocumentID == id })
+}
+
+// deleteFlow removes a flow and the rows that reference it with ON DELETE
+// CASCADE in the SQL schema.
+func deleteFlow(t *tables, id uuid.UUID) {
+	delete(t.flows, id)
+	maps.DeleteFunc(t.flowNodes, func(_ uuid.UUID, n domain.FlowNode) bool { return n.FlowID == id })
+	maps.DeleteFunc(t.flowVersions, func(_ uuid.UUID, v domain.FlowVersion) bool { return v.FlowID == id })
+	maps.DeleteFunc(t.flowShares, func(_ uuid.UUID, sh domain.Share) bool { return sh.ResourceID == id })
+	maps.DeleteFunc(t.flowGroupShares, func(_ uuid.UUID, sh domain.GroupShare) bool { return sh.ResourceID == id })
+}
+
+var _ repository.TxManager = (*Store)(nil)
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// recoveryCode is a row of the recovery code table.
+type recoveryCode struct {
+	UserID uuid.UUID
+	Hash   string
+	UsedAt *time.Time
+}
+
+type RecoveryCodeRepo struct {
+	s *Store
+}
+
+func NewRecoveryCodeRepo(s *Store) *RecoveryCodeRepo {
+	return &RecoveryCodeRepo{s: s}
+}
+
+func (r *RecoveryCodeRepo) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	return r.s.write(nil, func(t *tables) error {
+		for id, c := range t.recoveryCodes {
+			if c.UserID == userID {
+				delete(t.recoveryCodes, id)
+			}
+		}
+		for _, hash := range hashes {
+			t.recoveryCodes[uuid.New()] = recoveryCode{UserID: userID, Hash: hash}
+		}
+		return nil
+	})
+}
+
+func (r *RecoveryCodeRepo) Use(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+	used := false
+	err := r.s.write(nil, func(t *tables) error {
+		for id, c := range t.recoveryCodes {
+			if c.UserID == userID && c.Hash == hash && c.UsedAt == nil {
+				c.UsedAt = &at
+				t.recoveryCodes[id] = c
+				used = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return used, err
+}
+
+func (r *RecoveryCodeRepo) CountUnused(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	err := r.s.read(nil, func(t *tables) error {
+		for _, c := range t.recoveryCodes {
+			if c.UserID == userID && c.UsedAt == nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+type SettingRepo struct {
+	s *Store
+}
+
+func NewSettingRepo(s *Store) *SettingRepo {
+	return &SettingRepo{s: s}
+}
+
+func (r *SettingRepo) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.s.read(nil, func(t *tables) error {
+		v, ok := t.settings[key]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		value = v
+		return nil
+	})
+	return value, err
+}
+
+func (r *SettingRepo) Set(ctx context.Context, key, value string) error {
+	return r.s.write(nil, func(t *tables) error {
+		t.settings[key] = value
+		return nil
+	})
+}
+
+var (
+	_ repository.RecoveryCodeRepository = (*RecoveryCodeRepo)(nil)
+	_ repository.SettingRepository      = (*SettingRepo)(nil)
+)
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type UserRepo struct {
+	s *Store
+}
+
+func NewUserRepo(s *Store) *UserRepo {
+	return &UserRepo{s: s}
+}
+
+func (r *UserRepo) Create(ctx context.Context, user *domain.User) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, u := range t.users {
+			if u.Email == user.Email {
+				return fmt.Errorf("%w: email already registered", domain.ErrAlreadyExists)
+			}
+		}
+		user.ID = uuid.New()
+		if user.Role == "" {
+			user.Role = domain.RoleUser
+		}
+		if user.Locale == "" {
+			user.Locale = domain.LocaleZhCN
+		}
+		user.Active = true
+		user.TokenVersion = 1
+		user.CreatedAt = time.Now()
+		t.users[user.ID] = *user
+		return nil
+	})
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var found *domain.User
+	err := r.s.read(nil, func(t *tables) error {
+		for _, u := range t.users {
+			if u.Email == email {
+				found = &u
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return found, err
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	var found *domain.User
+	err := r.s.read(nil, func(t *tables) error {
+		u, ok := t.users[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &u
+		return nil
+	})
+	return found, err
+}
+
+// List returns all users, newest first, without password hashes, token
+// versions or two-factor secrets.
+func (r *UserRepo) List(ctx context.Context) ([]domain.User, error) {
+	users := make([]domain.User, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, u := range t.users {
+			u.PasswordHash = ""
+			u.TokenVersion = 0
+			u.TOTPSecret, u.TOTPLastStep = "", 0
+			users = append(users, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.After(users[j].CreatedAt) })
+	return users, nil
+}
+
+// UpdatePassword changes a user's password hash and bumps the token version.
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, hash string) error {
+	return r.s.write(nil, func(t *tables) error {
+		u, ok := t.users[userID]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		u.PasswordHash = hash
+		u.TokenVersion++
+		t.users[userID] = u
+		return nil
+	})
+}
+
+func (r *UserRepo) UpdateProfile(ctx context.Context, userID uuid.UUID, displayName, department string, locale domain.Locale) error {
+	return r.update(userID, func(u *domain.User) {
+		u.DisplayName, u.Department, u.Locale = displayName, department, locale
+	})
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error {
+	return r.update(userID, func(u *domain.User) { u.Role = role })
+}
+
+func (r *UserRepo) UpdateEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, u := range t.users {
+			if u.Email == email && u.ID != userID {
+				return fmt.Errorf("%w: email already registered", domain.ErrAlreadyExists)
+			}
+		}
+		if u, ok := t.users[userID]; ok {
+			u.Email = email
+			t.users[userID] = u
+		}
+		return nil
+	})
+}
+
+// SetActive deactivates or reactivates a user; deactivating bumps the token
+// version.
+func (r *UserRepo) SetActive(ctx context.Context, userID uuid.UUID, active bool) error {
+	return r.update(userID, func(u *domain.User) {
+		if !active {
+			u.TokenVersion++
+		}
+		u.Active = active
+	})
+}
+
+func (r *UserRepo) SetTwoFactor(ctx context.Context, userID uuid.UUID, secret string, enabled bool) error {
+	return r.update(userID, func(u *domain.User) {
+		u.TOTPSecret, u.TwoFactorEnabled, u.TOTPLastStep = secret, enabled, 0
+	})
+}
+
+func (r *UserRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	used := false
+	err := r.update(userID, func(u *domain.User) {
+		if u.TOTPLastStep < step {
+			u.TOTPLastStep = step
+			used = true
+		}
+	})
+	return used, err
+}
+
+// update applies fn to a user. Like the SQL repository, a missing user is
+// not an error.
+func (r *UserRepo) update(userID uuid.UUID, fn func(*domain.User)) error {
+	return r.s.write(nil, func(t *tables) error {
+		if u, ok := t.users[userID]; ok {
+			fn(&u)
+			t.users[userID] = u
+		}
+		return nil
+	})
+}
+
+// Delete removes a user with their shares and tokens, unless they own
+// documents or flows or authored versions.
+func (r *UserRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		for _, d := range t.documents {
+			if d.OwnerID == userID {
+				return fmt.Errorf("%w: user is referenced by documents", domain.ErrInvalidState)
+			}
+		}
+		for _, f := range t.flows {
+			if f.OwnerID == userID {
+				return fmt.Errorf("%w: user is referenced by flows", domain.ErrInvalidState)
+			}
+		}
+		for _, v := range t.versions {
+			if v.CreatedBy == userID {
+				return fmt.Errorf("%w: user is referenced by document_versions", domain.ErrInvalidState)
+			}
+		}
+		for _, v := range t.flowVersions {
+			if v.CreatedBy == userID {
+				return fmt.Errorf("%w: user is referenced by flow_versions", domain.ErrInvalidState)
+			}
+		}
+		if _, ok := t.users[userID]; !ok {
+			return domain.ErrNotFound
+		}
+		for _, shares := range []map[uuid.UUID]domain.Share{t.docShares, t.flowShares} {
+			for id, sh := range shares {
+				if sh.UserID == userID {
+					delete(shares, id)
+				}
+			}
+		}
+		for id, rt := range t.refreshTokens {
+			if rt.UserID == userID {
+				delete(t.refreshTokens, id)
+			}
+		}
+		for id, at := range t.apiTokens {
+			if at.UserID == userID {
+				delete(t.apiTokens, id)
+			}
+		}
+		for id, e := range t.passwordHistory {
+			if e.UserID == userID {
+				delete(t.passwordHistory, id)
+			}
+		}
+		for id, c := range t.recoveryCodes {
+			if c.UserID == userID {
+				delete(t.recoveryCodes, id)
+			}
+		}
+		for id, i := range t.identities {
+			if i.UserID == userID {
+				delete(t.identities, id)
+			}
+		}
+		for key := range t.groupMembers {
+			if key.userID == userID {
+				delete(t.groupMembers, key)
+			}
+		}
+		delete(t.users, userID)
+		return nil
+	})
+}
+
+var _ repository.UserRepository = (*UserRepo)(nil)
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type VersionRepo struct {
+	s *Store
+}
+
+func NewVersionRepo(s *Store) *VersionRepo {
+	return &VersionRepo{s: s}
+}
+
+func (r *VersionRepo) CreateTx(ctx context.Context, tx repository.Tx, v *domain.DocumentVersion) error {
+	return r.s.write(tx, func(t *tables) error {
+		v.ID = uuid.New()
+		v.CreatedAt = time.Now()
+		t.versions[v.ID] = *v
+		return nil
+	})
+}
+
+func (r *VersionRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.DocumentVersion, error) {
+	versions := make([]domain.DocumentVersion, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, v := range t.versions {
+			if v.DocumentID == docID {
+				versions = append(versions, v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].CreatedAt.After(versions[j].CreatedAt) })
+	return versions, nil
+}
+
+func (r *VersionRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.DocumentVersion, error) {
+	var found *domain.DocumentVersion
+	err := r.s.read(nil, func(t *tables) error {
+		v, ok := t.versions[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		found = &v
+		return nil
+	})
+	return found, err
+}
+
+var _ repository.VersionRepository = (*VersionRepo)(nil)
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type WorkflowNodeRepo struct {
+	s *Store
+}
+
+func NewWorkflowNodeRepo(s *Store) *WorkflowNodeRepo {
+	return &WorkflowNodeRepo{s: s}
+}
+
+// CreateTx inserts a new workflow node at the end of its document's node list,
+// within the given transaction.
+func (r *WorkflowNodeRepo) CreateTx(ctx context.Context, tx repository.Tx, node *domain.WorkflowNode) error {
+	return r.s.write(tx, func(t *tables) error {
+		node.SortOrder = 0
+		for _, n := range t.workflowNodes {
+			if n.DocumentID == node.DocumentID && n.SortOrder >= node.SortOrder {
+				node.SortOrder = n.SortOrder + 1
+			}
+		}
+		node.ID = uuid.New()
+		node.Revision = 1
+		now := time.Now()
+		node.CreatedAt = now
+		node.UpdatedAt = now
+		t.workflowNodes[node.ID] = *node
+		return nil
+	})
+}
+
+// UpdateTx updates an existing workflow node within the given transaction if
+// it is still at node.Revision, and bumps the revision. It returns
+// domain.ErrStaleRevision if another write got there first.
+func (r *WorkflowNodeRepo) UpdateTx(ctx context.Context, tx repository.Tx, node *domain.WorkflowNode) error {
+	return r.s.write(tx, func(t *tables) error {
+		stored, ok := t.workflowNodes[node.ID]
+		if !ok || stored.Revision != node.Revision {
+			return domain.ErrStaleRevision
+		}
+		node.UpdatedAt = time.Now()
+		stored.Name = node.Name
+		stored.ExecForm = node.ExecForm
+		stored.Description = node.Description
+		stored.Preconditions = node.Preconditions
+		stored.Outputs = node.Outputs
+		stored.DurationMin = node.DurationMin
+		stored.DurationMax = node.DurationMax
+		stored.DurationUnit = node.DurationUnit
+		stored.RaciJSON = node.RaciJSON
+		stored.SubtasksJSON = node.SubtasksJSON
+		stored.DiagramRaw = node.DiagramRaw
+		stored.Revision++
+		stored.UpdatedAt = node.UpdatedAt
+		t.workflowNodes[node.ID] = stored
+		node.Revision++
+		return nil
+	})
+}
+
+// GetByID returns a single workflow node.
+func (r *WorkflowNodeRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error) {
+	return r.get(id, false)
+}
+
+// ListByDocument returns all workflow nodes for a document in display order.
+func (r *WorkflowNodeRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.WorkflowNode, error) {
+	nodes := make([]domain.WorkflowNode, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, n := range t.workflowNodes {
+			if n.DocumentID == docID && n.DeletedAt == nil {
+				n.HydrateJSON()
+				nodes = append(nodes, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].SortOrder != nodes[j].SortOrder {
+			return nodes[i].SortOrder < nodes[j].SortOrder
+		}
+		return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+	})
+	return nodes, nil
+}
+
+// ListIDsByDocumentTx returns the IDs of a document's live nodes, within the given transaction.
+func (r *WorkflowNodeRepo) ListIDsByDocumentTx(ctx context.Context, tx repository.Tx, docID uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	err := r.s.read(tx, func(t *tables) error {
+		for _, n := range t.workflowNodes {
+			if n.DocumentID == docID && n.DeletedAt == nil {
+				ids = append(ids, n.ID)
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// UpdateSortOrderTx sets each node's sort_order to its position in ids.
+func (r *WorkflowNodeRepo) UpdateSortOrderTx(ctx context.Context, tx repository.Tx, docID uuid.UUID, ids []uuid.UUID) error {
+	return r.s.write(tx, func(t *tables) error {
+		for i, id := range ids {
+			if n, ok := t.workflowNodes[id]; ok && n.DocumentID == docID {
+				n.SortOrder = i
+				t.workflowNodes[id] = n
+			}
+		}
+		return nil
+	})
+}
+
+// SoftDelete moves a live workflow node to the trash.
+func (r *WorkflowNodeRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		if n, ok := t.workflowNodes[id]; ok && n.DeletedAt == nil {
+			now := time.Now()
+			n.DeletedAt = &now
+			t.workflowNodes[id] = n
+		}
+		return nil
+	})
+}
+
+// GetDeletedByID returns a workflow node that is in the trash.
+func (r *WorkflowNodeRepo) GetDeletedByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error) {
+	return r.get(id, true)
+}
+
+func (r *WorkflowNodeRepo) get(id uuid.UUID, deleted bool) (*domain.WorkflowNode, error) {
+	var found *domain.WorkflowNode
+	err := r.s.read(nil, func(t *tables) error {
+		n, ok := t.workflowNodes[id]
+		if !ok || (n.DeletedAt != nil) != deleted {
+			return domain.ErrNotFound
+		}
+		n.HydrateJSON()
+		found = &n
+		return nil
+	})
+	return found, err
+}
+
+// ListDeletedEditable returns trashed nodes of live documents the user can
+// edit (owner, or EDIT share with the user or one of their groups), most
+// recently deleted first.
+func (r *WorkflowNodeRepo) ListDeletedEditable(ctx context.Context, userID uuid.UUID) ([]domain.WorkflowNode, error) {
+	nodes := make([]domain.WorkflowNode, 0)
+	err := r.s.read(nil, func(t *tables) error {
+		for _, n := range t.workflowNodes {
+			if n.DeletedAt == nil {
+				continue
+			}
+			d, ok := t.documents[n.DocumentID]
+			if !ok || d.DeletedAt != nil {
+				continue
+			}
+			if role, _ := accessRole(t, domain.ShareResourceDocument, d.ID, userID); d.OwnerID == userID || role == domain.ShareRoleEdit {
+				n.HydrateJSON()
+				nodes = append(nodes, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].DeletedAt.After(*nodes[j].DeletedAt) })
+	return nodes, nil
+}
+
+// Restore takes a workflow node out of the trash.
+func (r *WorkflowNodeRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.s.write(nil, func(t *tables) error {
+		if n, ok := t.workflowNodes[id]; ok {
+			n.DeletedAt = nil
+			t.workflowNodes[id] = n
+		}
+		return nil
+	})
+}
+
+// PurgeDeletedTx hard-deletes workflow nodes trashed before the cutoff.
+func (r *WorkflowNodeRepo) PurgeDeletedTx(ctx context.Context, tx repository.Tx, before time.Time) (int64, error) {
+	var purged int64
+	err := r.s.write(tx, func(t *tables) error {
+		for id, n := range t.workflowNodes {
+			if n.DeletedAt != nil && n.DeletedAt.Before(before) {
+				delete(t.workflowNodes, id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+var _ repository.WorkflowNodeRepository = (*WorkflowNodeRepo)(nil)
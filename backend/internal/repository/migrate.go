@@ -1,242 +1,734 @@
 package repository
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"docmv/migrations"
+
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
 )
 
-// AutoMigrate creates all required tables and columns if they do not exist.
-// It is safe to call on every startup — all statements use IF NOT EXISTS or
-// equivalent guards so they are no-ops when the schema is already current.
-func AutoMigrate(db *sqlx.DB, driver string) error {
-	log.Println("[migrate] running auto-migration …")
-
-	switch driver {
-	case "postgres":
-		return migratePostgres(db)
-	case "mysql":
-		return migrateMySQL(db)
-	default:
-		return fmt.Errorf("unsupported driver for auto-migrate: %s", driver)
-	}
-}
-
-// ── PostgreSQL ──────────────────────────────────────────────────────────────
-
-func migratePostgres(db *sqlx.DB) error {
-	stmts := []string{
-		// Users
-		`CREATE TABLE IF NOT EXISTS users (
-			id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			email         VARCHAR(255) UNIQUE NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
-			role          VARCHAR(20)  NOT NULL DEFAULT 'USER',
-			created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
-		)`,
+// legacySchema lists, for each migration whose schema the old AutoMigrate
+// routine used to create on startup, the tables and columns it adds. A
+// database that has application tables but no schema_migrations table was
+// set up by some release of AutoMigrate; it is stamped with the longest run
+// of these versions whose schema is fully present, and the remaining
+// migrations are applied to it. Those may meet tables, columns and indexes
+// AutoMigrate already created, so while applying them Up skips statements
+// that fail only because the object exists.
+var legacySchema = []struct {
+	version int64
+	tables  []string
+	columns [][2]string // table, column
+}{
+	{1, []string{"users", "documents", "document_versions", "document_shares", "workflow_nodes", "flows", "flow_nodes", "flow_versions", "flow_shares"}, nil},
+	{2, nil, [][2]string{{"document_versions", "restored_from_version_id"}, {"flow_versions", "restored_from_version_id"}}},
+	{3, []string{"ownership_transfers"}, nil},
+	{4, nil, [][2]string{{"documents", "deleted_at"}, {"flows", "deleted_at"}, {"workflow_nodes", "deleted_at"}}},
+	{5, nil, [][2]string{{"workflow_nodes", "sort_order"}}},
+	{6, nil, [][2]string{{"documents", "revision"}, {"flows", "revision"}, {"workflow_nodes", "revision"}}},
+}
 
-		// role column (may be missing if old init migration was used)
-		`DO $$ BEGIN
-			IF NOT EXISTS (
-				SELECT 1 FROM information_schema.columns
-				WHERE table_name = 'users' AND column_name = 'role'
-			) THEN
-				ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'USER';
-			END IF;
-		END $$`,
-
-		// Visibility enum
-		`DO $$ BEGIN
-			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'doc_visibility') THEN
-				CREATE TYPE doc_visibility AS ENUM ('PRIVATE', 'PUBLIC', 'SHARED');
-			END IF;
-		END $$`,
-
-		// Share-role enum
-		`DO $$ BEGIN
-			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'share_role') THEN
-				CREATE TYPE share_role AS ENUM ('VIEW', 'EDIT');
-			END IF;
-		END $$`,
-
-		// Documents
-		`CREATE TABLE IF NOT EXISTS documents (
-			id                UUID           PRIMARY KEY DEFAULT gen_random_uuid(),
-			owner_id          UUID           NOT NULL REFERENCES users(id),
-			title             VARCHAR(500)   NOT NULL,
-			visibility        VARCHAR(20)    NOT NULL DEFAULT 'PRIVATE',
-			latest_version_id UUID,
-			created_at        TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
-			updated_at        TIMESTAMPTZ    NOT NULL DEFAULT NOW()
-		)`,
+const (
+	migrationLockName = "docmv.schema_migrations"
+	migrationLockKey  = 0x646f636d76 // "docmv", the pg advisory lock key
+)
 
-		// Document versions
-		`CREATE TABLE IF NOT EXISTS document_versions (
-			id          UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
-			document_id UUID        NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
-			content     TEXT        NOT NULL DEFAULT '',
-			created_by  UUID        NOT NULL REFERENCES users(id),
-			created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		)`,
+// Migration is one versioned schema change loaded from the embedded
+// migrations directory of the active driver.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string // empty when no down file exists
+	Checksum string // hex SHA-256 of Up
+}
 
-		// Document shares
-		`CREATE TABLE IF NOT EXISTS document_shares (
-			id          UUID       PRIMARY KEY DEFAULT gen_random_uuid(),
-			document_id UUID       NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
-			user_id     UUID       NOT NULL REFERENCES users(id),
-			role        VARCHAR(20) NOT NULL DEFAULT 'VIEW',
-			created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			UNIQUE(document_id, user_id)
-		)`,
+// MigrationStatus describes one migration as seen by Status.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"` // up file changed after it was applied
+	Missing   bool       `json:"missing"`  // recorded as applied but no longer embedded
+}
 
-		// Workflow nodes
-		`CREATE TABLE IF NOT EXISTS workflow_nodes (
-			id             UUID           PRIMARY KEY DEFAULT gen_random_uuid(),
-			document_id    UUID           NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
-			name           VARCHAR(500)   NOT NULL,
-			exec_form      VARCHAR(50)    NOT NULL,
-			description    TEXT           NOT NULL DEFAULT '',
-			preconditions  TEXT           NOT NULL DEFAULT '',
-			outputs        TEXT           NOT NULL DEFAULT '',
-			duration_min   DOUBLE PRECISION,
-			duration_max   DOUBLE PRECISION,
-			duration_unit  VARCHAR(20)    NOT NULL DEFAULT 'DAY',
-			raci_json      TEXT           NOT NULL DEFAULT '{"R":[],"A":[],"S":[],"C":[],"I":[]}',
-			subtasks_json  TEXT           NOT NULL DEFAULT '[]',
-			diagram_json   TEXT           NOT NULL DEFAULT '{"nodes":[],"edges":[]}',
-			created_at     TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
-			updated_at     TIMESTAMPTZ    NOT NULL DEFAULT NOW()
-		)`,
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
 
-		// Indexes (IF NOT EXISTS supported since PG 9.5)
-		`CREATE INDEX IF NOT EXISTS idx_documents_owner          ON documents(owner_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_documents_visibility     ON documents(visibility)`,
-		`CREATE INDEX IF NOT EXISTS idx_doc_versions_document    ON document_versions(document_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_doc_versions_created_at  ON document_versions(document_id, created_at DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_doc_shares_document      ON document_shares(document_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_doc_shares_user          ON document_shares(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_workflow_nodes_document  ON workflow_nodes(document_id)`,
+// Migrator applies and rolls back the embedded migrations for one driver.
+// Applied versions are tracked in schema_migrations together with the
+// checksum of the up file. Up and Down hold a database-level lock for their
+// whole run so that replicas starting at the same time migrate one by one.
+type Migrator struct {
+	db         *sqlx.DB
+	driver     string
+	dialect    migrationDialect
+	migrations []Migration
+
+	// DryRun logs the statements Up or Down would execute without running
+	// them or touching schema_migrations.
+	DryRun bool
+	// LockTimeout bounds how long Up and Down wait for another process
+	// holding the migration lock.
+	LockTimeout time.Duration
+}
+
+// NewMigrator loads the embedded migrations for driver.
+func NewMigrator(db *sqlx.DB, driver string) (*Migrator, error) {
+	dialect, ok := migrationDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported driver for migrations: %s", driver)
 	}
+	migs, err := loadMigrations(migrations.FS, driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          db,
+		driver:      driver,
+		dialect:     dialect,
+		migrations:  migs,
+		LockTimeout: time.Minute,
+	}, nil
+}
 
-	for _, s := range stmts {
-		if _, err := db.Exec(s); err != nil {
-			return fmt.Errorf("postgres migration failed: %w\nSQL: %s", err, s)
+// Migrations returns the embedded migrations in version order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in version order and returns the ones
+// applied (or, in dry-run mode, the ones that would be).
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.prepare(ctx, conn)
+		if err != nil {
+			return err
+		}
+		pending, err := m.pending(applied)
+		if err != nil {
+			return err
 		}
+		if len(pending) == 0 {
+			log.Printf("[migrate] %s schema up-to-date", m.driver)
+			return nil
+		}
+
+		for _, mig := range pending {
+			if m.DryRun {
+				logDryRun("apply", mig, mig.Up)
+				done = append(done, mig)
+				continue
+			}
+			log.Printf("[migrate] applying %06d_%s", mig.Version, mig.Name)
+			var skip func(error) bool
+			if mig.Version <= legacySchema[len(legacySchema)-1].version {
+				skip = m.dialect.objectExists
+			}
+			insert := conn.Rebind(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`)
+			if err := m.run(ctx, conn, mig, mig.Up, skip, insert, mig.Version, mig.Name, mig.Checksum); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("down: steps must be positive, got %d", steps)
 	}
-	log.Println("[migrate] postgres schema up-to-date")
-	return nil
+
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.prepare(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if steps > len(versions) {
+			steps = len(versions)
+		}
+
+		for _, v := range versions[:steps] {
+			mig, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("cannot roll back version %d: migration file no longer exists", v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("cannot roll back %06d_%s: no down migration", mig.Version, mig.Name)
+			}
+			if m.DryRun {
+				logDryRun("roll back", mig, mig.Down)
+				done = append(done, mig)
+				continue
+			}
+			log.Printf("[migrate] rolling back %06d_%s", mig.Version, mig.Name)
+			del := conn.Rebind(`DELETE FROM schema_migrations WHERE version = ?`)
+			if err := m.run(ctx, conn, mig, mig.Down, nil, del, mig.Version); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
 }
 
-// ── MySQL ───────────────────────────────────────────────────────────────────
+// Status lists every embedded migration with its applied state, followed by
+// any applied version that no longer has a file.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	exists, err := m.tableExists(ctx, m.db, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int64]appliedMigration{}
+	if exists {
+		if applied, err = m.loadApplied(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
 
-func migrateMySQL(db *sqlx.DB) error {
-	stmts := []string{
-		// Users
-		`CREATE TABLE IF NOT EXISTS users (
-			id            CHAR(36)     NOT NULL PRIMARY KEY,
-			email         VARCHAR(255) NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
-			role          VARCHAR(20)  NOT NULL DEFAULT 'USER',
-			created_at    DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			UNIQUE KEY uk_users_email (email)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			at := a.AppliedAt
+			st.Applied = true
+			st.AppliedAt = &at
+			st.Modified = a.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, st)
+	}
+	for _, a := range applied {
+		at := a.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: &at, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
 
-		// Documents
-		`CREATE TABLE IF NOT EXISTS documents (
-			id                CHAR(36)                            NOT NULL PRIMARY KEY,
-			owner_id          CHAR(36)                            NOT NULL,
-			title             VARCHAR(500)                        NOT NULL,
-			visibility        ENUM('PRIVATE','PUBLIC','SHARED')   NOT NULL DEFAULT 'PRIVATE',
-			latest_version_id CHAR(36)                            DEFAULT NULL,
-			created_at        DATETIME(6)                         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			updated_at        DATETIME(6)                         NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
-			CONSTRAINT fk_documents_owner FOREIGN KEY (owner_id) REFERENCES users(id)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+// ---------- Internal ----------
 
-		// Document versions
-		`CREATE TABLE IF NOT EXISTS document_versions (
-			id          CHAR(36)    NOT NULL PRIMARY KEY,
-			document_id CHAR(36)    NOT NULL,
-			content     LONGTEXT    NOT NULL,
-			created_by  CHAR(36)    NOT NULL,
-			created_at  DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			CONSTRAINT fk_versions_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
-			CONSTRAINT fk_versions_creator  FOREIGN KEY (created_by)  REFERENCES users(id)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+// prepare makes sure schema_migrations exists, stamps a pre-migrator
+// database with the versions its schema already has and returns the applied versions after
+// checking them against the embedded files. In dry-run mode nothing is
+// written; the result is what the tables would contain.
+func (m *Migrator) prepare(ctx context.Context, conn *sqlx.Conn) (map[int64]appliedMigration, error) {
+	exists, err := m.tableExists(ctx, conn, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		applied, err := m.loadApplied(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		return applied, m.verify(applied)
+	}
 
-		// Document shares
-		`CREATE TABLE IF NOT EXISTS document_shares (
-			id          CHAR(36)          NOT NULL PRIMARY KEY,
-			document_id CHAR(36)          NOT NULL,
-			user_id     CHAR(36)          NOT NULL,
-			role        ENUM('VIEW','EDIT') NOT NULL DEFAULT 'VIEW',
-			created_at  DATETIME(6)       NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			UNIQUE KEY uk_shares_doc_user (document_id, user_id),
-			CONSTRAINT fk_shares_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
-			CONSTRAINT fk_shares_user     FOREIGN KEY (user_id)     REFERENCES users(id)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	legacy, err := m.tableExists(ctx, conn, "users")
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline int64
+	if legacy {
+		if baseline, err = m.legacyVersion(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	applied := map[int64]appliedMigration{}
+	for _, mig := range m.migrations {
+		if mig.Version <= baseline {
+			applied[mig.Version] = appliedMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum}
+		}
+	}
+	if m.DryRun {
+		log.Println("[migrate] dry-run: would create schema_migrations")
+		if legacy {
+			log.Printf("[migrate] dry-run: would record existing schema as version %d", baseline)
+		}
+		return applied, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	if baseline == 0 {
+		if legacy {
+			log.Println("[migrate] existing schema predates version 1, applying every migration to it")
+		}
+		return applied, nil
+	}
+
+	log.Printf("[migrate] existing schema found, recording it as version %d", baseline)
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	insert := tx.Rebind(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`)
+	for _, a := range applied {
+		if _, err := tx.ExecContext(ctx, insert, a.Version, a.Name, a.Checksum); err != nil {
+			return nil, fmt.Errorf("recording baseline migration %d: %w", a.Version, err)
+		}
+	}
+	return applied, tx.Commit()
+}
+
+// legacyVersion returns the latest version up to which the schema created
+// by AutoMigrate is complete, or 0 when even the first migration's tables
+// are missing.
+func (m *Migrator) legacyVersion(ctx context.Context, q migrationQueryer) (int64, error) {
+	var version int64
+	for _, step := range legacySchema {
+		for _, table := range step.tables {
+			ok, err := m.tableExists(ctx, q, table)
+			if err != nil || !ok {
+				return version, err
+			}
+		}
+		for _, col := range step.columns {
+			ok, err := m.columnExists(ctx, q, col[0], col[1])
+			if err != nil || !ok {
+				return version, err
+			}
+		}
+		version = step.version
+	}
+	return version, nil
+}
 
-		// Workflow nodes
-		`CREATE TABLE IF NOT EXISTS workflow_nodes (
-			id             CHAR(36)       NOT NULL PRIMARY KEY,
-			document_id    CHAR(36)       NOT NULL,
-			name           VARCHAR(500)   NOT NULL,
-			exec_form      VARCHAR(50)    NOT NULL,
-			description    TEXT           NOT NULL,
-			preconditions  TEXT           NOT NULL,
-			outputs        TEXT           NOT NULL,
-			duration_min   DOUBLE,
-			duration_max   DOUBLE,
-			duration_unit  VARCHAR(20)    NOT NULL DEFAULT 'DAY',
-			raci_json      TEXT           NOT NULL,
-			subtasks_json  TEXT           NOT NULL,
-			diagram_json   TEXT           NOT NULL,
-			created_at     TIMESTAMP(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			updated_at     TIMESTAMP(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
-			FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
-	}
-
-	for _, s := range stmts {
-		if _, err := db.Exec(s); err != nil {
-			return fmt.Errorf("mysql migration failed: %w\nSQL: %s", err, s)
-		}
-	}
-
-	// Add role column if missing (MySQL has no ADD COLUMN IF NOT EXISTS)
-	mysqlAddColumnIfMissing(db, "users", "role", "VARCHAR(20) NOT NULL DEFAULT 'USER' AFTER password_hash")
-
-	// Indexes (MySQL ignores duplicate index names gracefully via error check)
-	indexes := []string{
-		`CREATE INDEX idx_documents_owner          ON documents(owner_id)`,
-		`CREATE INDEX idx_documents_visibility     ON documents(visibility)`,
-		`CREATE INDEX idx_doc_versions_document    ON document_versions(document_id)`,
-		`CREATE INDEX idx_doc_versions_created_at  ON document_versions(document_id, created_at DESC)`,
-		`CREATE INDEX idx_doc_shares_document      ON document_shares(document_id)`,
-		`CREATE INDEX idx_doc_shares_user          ON document_shares(user_id)`,
-		`CREATE INDEX idx_workflow_nodes_document  ON workflow_nodes(document_id)`,
-	}
-	for _, idx := range indexes {
-		// Ignore "Duplicate key name" errors
-		db.Exec(idx) //nolint:errcheck
-	}
-
-	log.Println("[migrate] mysql schema up-to-date")
+// verify rejects applied migrations whose up file has been edited since.
+func (m *Migrator) verify(applied map[int64]appliedMigration) error {
+	for _, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		if ok && a.Checksum != mig.Checksum {
+			return fmt.Errorf("migration %06d_%s was modified after it was applied (recorded checksum %s, file checksum %s)",
+				mig.Version, mig.Name, a.Checksum, mig.Checksum)
+		}
+	}
 	return nil
 }
 
-// mysqlAddColumnIfMissing adds a column to a table only if it does not already exist.
-func mysqlAddColumnIfMissing(db *sqlx.DB, table, column, definition string) {
+// pending returns the unapplied migrations. A gap below the latest applied
+// version means a migration was added out of order, which is refused.
+func (m *Migrator) pending(applied map[int64]appliedMigration) ([]Migration, error) {
+	var latest int64
+	for v := range applied {
+		if v > latest {
+			latest = v
+		}
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if mig.Version < latest {
+			return nil, fmt.Errorf("migration %06d_%s is older than the latest applied version %d", mig.Version, mig.Name, latest)
+		}
+		pending = append(pending, mig)
+	}
+	return pending, nil
+}
+
+// run executes the statements of body and then the bookkeeping statement
+// record. On drivers with transactional DDL both happen in one transaction;
+// elsewhere a failure part-way leaves the earlier statements committed.
+// Statement errors for which skip (if set) reports true are logged and
+// ignored.
+func (m *Migrator) run(ctx context.Context, conn *sqlx.Conn, mig Migration, body string, skip func(error) bool, record string, args ...interface{}) error {
+	stmts := splitStatements(body)
+	skipped := func(err error) bool {
+		if skip == nil || !skip(err) {
+			return false
+		}
+		log.Printf("[migrate] %06d_%s: skipping statement for an existing object (%v)", mig.Version, mig.Name, err)
+		return true
+	}
+
+	if !m.dialect.transactionalDDL {
+		for i, stmt := range stmts {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil && !skipped(err) {
+				return fmt.Errorf("migration %06d_%s failed at statement %d (earlier statements are already committed): %w\nSQL: %s",
+					mig.Version, mig.Name, i+1, err, stmt)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("recording migration %d: %w", mig.Version, err)
+		}
+		return nil
+	}
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for i, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil && !skipped(err) {
+			return fmt.Errorf("migration %06d_%s failed at statement %d: %w\nSQL: %s", mig.Version, mig.Name, i+1, err, stmt)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("recording migration %d: %w", mig.Version, err)
+	}
+	return tx.Commit()
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock.
+// Session-level locks belong to one connection, so everything fn does must
+// go through conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn, m.LockTimeout); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.dialect.unlock(context.Background(), conn); err != nil {
+			log.Printf("[migrate] warning: releasing migration lock: %v", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+// migrationQueryer is satisfied by both *sqlx.DB and *sqlx.Conn.
+type migrationQueryer interface {
+	sqlx.QueryerContext
+	Rebind(query string) string
+}
+
+func (m *Migrator) tableExists(ctx context.Context, q migrationQueryer, table string) (bool, error) {
+	var count int
+	if err := sqlx.GetContext(ctx, q, &count, q.Rebind(m.dialect.tableExists), table); err != nil {
+		return false, fmt.Errorf("checking table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) columnExists(ctx context.Context, q migrationQueryer, table, column string) (bool, error) {
 	var count int
-	err := db.Get(&count,
-		`SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`,
-		table, column)
-	if err != nil || count > 0 {
-		return
+	if err := sqlx.GetContext(ctx, q, &count, q.Rebind(m.dialect.columnExists), table, column); err != nil {
+		return false, fmt.Errorf("checking column %s.%s: %w", table, column, err)
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) loadApplied(ctx context.Context, q sqlx.QueryerContext) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := sqlx.SelectContext(ctx, q, &rows, `SELECT version, name, checksum, applied_at FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("loading applied migrations: %w", err)
+	}
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, a := range rows {
+		applied[a.Version] = a
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func logDryRun(action string, mig Migration, body string) {
+	log.Printf("[migrate] dry-run: would %s %06d_%s", action, mig.Version, mig.Name)
+	for _, stmt := range splitStatements(body) {
+		log.Printf("[migrate]   %s;", stmt)
+	}
+}
+
+// ── Loading ────────────────────────────────────────────────────────────────
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// loadMigrations reads <dir>/NNNNNN_name.{up,down}.sql from fsys. Every
+// version needs an up file; down files are optional.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations for %s: %w", dir, err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file in migrations/%s: %s", dir, e.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, dir+"/"+e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(body)
+			mig.Up = string(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migs := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %06d_%s has no up file", mig.Version, mig.Name)
+		}
+		migs = append(migs, *mig)
+	}
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	return migs, nil
+}
+
+// splitStatements splits a migration file into individual statements on
+// top-level semicolons, skipping comments and respecting quoted strings and
+// PostgreSQL dollar-quoted bodies.
+func splitStatements(body string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '-' && i+1 < len(body) && body[i+1] == '-':
+			for i < len(body) && body[i] != '\n' {
+				i++
+			}
+			cur.WriteByte('\n')
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(body) && body[end] != c {
+				if body[end] == '\\' && c != '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(body) {
+				end = len(body) - 1
+			}
+			cur.WriteString(body[i : end+1])
+			i = end
+		case c == '$':
+			tag := dollarTag(body[i:])
+			if tag == "" {
+				cur.WriteByte(c)
+				continue
+			}
+			end := strings.Index(body[i+len(tag):], tag)
+			if end < 0 {
+				cur.WriteString(body[i:])
+				i = len(body)
+				continue
+			}
+			stop := i + len(tag) + end + len(tag)
+			cur.WriteString(body[i:stop])
+			i = stop - 1
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// dollarTag returns the opening $tag$ at the start of s, or "".
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		switch c := s[j]; {
+		case c == '$':
+			return s[:j+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
 	}
-	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
-	if _, err := db.Exec(sql); err != nil {
-		log.Printf("[migrate] warning: failed to add column %s.%s: %v", table, column, err)
+	return ""
+}
+
+// ── Dialects ───────────────────────────────────────────────────────────────
+
+type migrationDialect struct {
+	createTable      string
+	tableExists      string // counts tables named by the single bind parameter
+	columnExists     string // counts columns named by the table and column parameters
+	transactionalDDL bool
+	lock             func(ctx context.Context, conn *sqlx.Conn, timeout time.Duration) error
+	unlock           func(ctx context.Context, conn *sqlx.Conn) error
+
+	// objectExists reports whether a DDL error means the table, column or
+	// index being created is already there. Nil when the migrations guard
+	// every such statement with IF NOT EXISTS.
+	objectExists func(err error) bool
+}
+
+var migrationDialects = map[string]migrationDialect{
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT       PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			checksum   CHAR(64)     NOT NULL,
+			applied_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+		)`,
+		tableExists:      `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`,
+		columnExists:     `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`,
+		transactionalDDL: true,
+		lock: func(ctx context.Context, conn *sqlx.Conn, timeout time.Duration) error {
+			return pollLock(ctx, timeout, func() (bool, error) {
+				var ok bool
+				err := conn.GetContext(ctx, &ok, `SELECT pg_try_advisory_lock($1)`, migrationLockKey)
+				return ok, err
+			})
+		},
+		unlock: func(ctx context.Context, conn *sqlx.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+			return err
+		},
+	},
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT       NOT NULL PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			checksum   CHAR(64)     NOT NULL,
+			applied_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		tableExists:  `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`,
+		columnExists: `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`,
+		objectExists: func(err error) bool {
+			var myErr *mysql.MySQLError
+			if !errors.As(err, &myErr) {
+				return false
+			}
+			// ER_TABLE_EXISTS_ERROR, ER_DUP_FIELDNAME, ER_DUP_KEYNAME
+			return myErr.Number == 1050 || myErr.Number == 1060 || myErr.Number == 1061
+		},
+		lock: func(ctx context.Context, conn *sqlx.Conn, timeout time.Duration) error {
+			var got sql.NullInt64
+			if err := conn.GetContext(ctx, &got, `SELECT GET_LOCK(?, ?)`, migrationLockName, int(timeout.Seconds())); err != nil {
+				return fmt.Errorf("acquiring migration lock: %w", err)
+			}
+			if !got.Valid || got.Int64 != 1 {
+				return errMigrationLocked(timeout)
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sqlx.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, migrationLockName)
+			return err
+		},
+	},
+	"sqlite": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER  NOT NULL PRIMARY KEY,
+			name       TEXT     NOT NULL,
+			checksum   TEXT     NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		tableExists:      `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		columnExists:     `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+		transactionalDDL: true,
+		// SQLite has no ADD COLUMN IF NOT EXISTS and reports a duplicate
+		// column only in the message. A failed statement does not abort the
+		// surrounding transaction.
+		objectExists: func(err error) bool {
+			var liteErr *sqlite.Error
+			return errors.As(err, &liteErr) && strings.Contains(liteErr.Error(), "duplicate column name")
+		},
+		// A SQLite file has a single writer at a time, and each migration
+		// runs in its own write transaction, so there is no separate lock.
+		lock:   func(context.Context, *sqlx.Conn, time.Duration) error { return nil },
+		unlock: func(context.Context, *sqlx.Conn) error { return nil },
+	},
+}
+
+// pollLock retries try until it reports success or timeout elapses.
+func pollLock(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := try()
+		if err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errMigrationLocked(timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
 }
+
+func errMigrationLocked(timeout time.Duration) error {
+	return fmt.Errorf("timed out after %s waiting for the migration lock; another instance is migrating", timeout)
+}
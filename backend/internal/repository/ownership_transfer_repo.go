@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OwnershipTransferRepo stores the ownership history of documents and flows.
+type OwnershipTransferRepo struct {
+	db *sqlx.DB
+}
+
+func NewOwnershipTransferRepo(db *sqlx.DB) *OwnershipTransferRepo {
+	return &OwnershipTransferRepo{db: db}
+}
+
+func (r *OwnershipTransferRepo) CreateTx(ctx context.Context, tx Tx, t *domain.OwnershipTransfer) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`INSERT INTO ownership_transfers
+		(id, resource_type, resource_id, from_user_id, to_user_id, transferred_by, kept_edit_share, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	_, err := stx.ExecContext(ctx, query,
+		t.ID, t.ResourceType, t.ResourceID, t.FromUserID, t.ToUserID, t.TransferredBy, t.KeptEditShare, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("recording ownership transfer: %w", err)
+	}
+	return nil
+}
+
+// ListByResource returns the transfers of one document or flow, newest first.
+func (r *OwnershipTransferRepo) ListByResource(ctx context.Context, resourceType domain.ShareResource, resourceID uuid.UUID) ([]domain.OwnershipTransfer, error) {
+	query := r.db.Rebind(`SELECT * FROM ownership_transfers
+		WHERE resource_type = ? AND resource_id = ? ORDER BY created_at DESC`)
+	transfers := make([]domain.OwnershipTransfer, 0)
+	if err := r.db.SelectContext(ctx, &transfers, query, resourceType, resourceID); err != nil {
+		return nil, fmt.Errorf("listing ownership transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+// DeleteOrphansTx removes history rows whose document or flow no longer exists.
+func (r *OwnershipTransferRepo) DeleteOrphansTx(ctx context.Context, tx Tx) error {
+	stx := sqlxTx(tx)
+	_, err := stx.ExecContext(ctx, `DELETE FROM ownership_transfers
+		WHERE (resource_type = 'document' AND resource_id NOT IN (SELECT id FROM documents))
+		   OR (resource_type = 'flow' AND resource_id NOT IN (SELECT id FROM flows))`)
+	if err != nil {
+		return fmt.Errorf("deleting orphaned ownership transfers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RefreshTokenRepo struct {
+	db *sqlx.DB
+}
+
+func NewRefreshTokenRepo(db *sqlx.DB) *RefreshTokenRepo {
+	return &RefreshTokenRepo{db: db}
+}
+
+// Create stores a refresh token. ID and CreatedAt are set here; the caller
+// supplies everything else.
+func (r *RefreshTokenRepo) Create(ctx context.Context, t *domain.RefreshToken) error {
+	query := r.db.Rebind(`INSERT INTO refresh_tokens
+		(id, user_id, family_id, token_hash, token_version, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, t.ID, t.UserID, t.FamilyID, t.TokenHash, t.TokenVersion, t.ExpiresAt, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("creating refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	var t domain.RefreshToken
+	err := r.db.GetContext(ctx, &t, r.db.Rebind(`SELECT * FROM refresh_tokens WHERE token_hash = ?`), hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting refresh token: %w", err)
+	}
+	return &t, nil
+}
+
+// MarkUsed consumes a token. It returns false if the token was already used
+// or revoked, so of two concurrent refreshes with the same token only one wins.
+func (r *RefreshTokenRepo) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	query := r.db.Rebind(`UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL`)
+	result, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		return false, fmt.Errorf("marking refresh token used: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// RevokeFamily revokes every token of a family that is not revoked yet.
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	query := r.db.Rebind(`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`)
+	if _, err := r.db.ExecContext(ctx, query, at, familyID); err != nil {
+		return fmt.Errorf("revoking refresh token family: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that expired before the given time and returns
+// how many were removed.
+func (r *RefreshTokenRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM refresh_tokens WHERE expires_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired refresh tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
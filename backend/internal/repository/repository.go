@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tx is a unit of work spanning several repository calls. Repository methods
+// whose name ends in Tx run inside one; the work becomes visible to other
+// callers only after Commit. A Tx must be passed back to the implementation
+// that began it. *sqlx.Tx satisfies Tx.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// TxManager begins units of work.
+type TxManager interface {
+	Begin(ctx context.Context) (Tx, error)
+}
+
+type sqlTxManager struct {
+	db *sqlx.DB
+}
+
+// NewTxManager returns a TxManager whose units of work are database
+// transactions on db.
+func NewTxManager(db *sqlx.DB) TxManager {
+	return sqlTxManager{db: db}
+}
+
+func (m sqlTxManager) Begin(ctx context.Context) (Tx, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// sqlxTx unwraps a Tx begun by a SQL TxManager. Handing a SQL repository a
+// transaction from another implementation is a programming error.
+func sqlxTx(tx Tx) *sqlx.Tx {
+	stx, ok := tx.(*sqlx.Tx)
+	if !ok {
+		panic(fmt.Sprintf("repository: %T is not a SQL transaction", tx))
+	}
+	return stx
+}
+
+// The interfaces below describe what the services need from storage. The
+// SQL repositories in this package implement them, and so does the
+// in-memory store in package memory.
+
+type UserRepository interface {
+	Create(ctx context.Context, user *domain.User) error
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+	List(ctx context.Context) ([]domain.User, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, hash string) error
+	UpdateProfile(ctx context.Context, userID uuid.UUID, displayName, department string, locale domain.Locale) error
+	UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error
+	UpdateEmail(ctx context.Context, userID uuid.UUID, email string) error
+	SetActive(ctx context.Context, userID uuid.UUID, active bool) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+	SetTwoFactor(ctx context.Context, userID uuid.UUID, secret string, enabled bool) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+}
+
+type DocumentRepository interface {
+	CreateTx(ctx context.Context, tx Tx, doc *domain.Document) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error)
+	UpdateTx(ctx context.Context, tx Tx, doc *domain.Document) error
+	ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Document, error)
+	HasReadAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error)
+	HasEditAccess(ctx context.Context, docID, userID uuid.UUID) (bool, error)
+	UpdateOwnerTx(ctx context.Context, tx Tx, id, from, to uuid.UUID) (bool, error)
+	ListIDsByOwnerTx(ctx context.Context, tx Tx, ownerID uuid.UUID) ([]uuid.UUID, error)
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Document, error)
+	Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error)
+	PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error)
+}
+
+type VersionRepository interface {
+	CreateTx(ctx context.Context, tx Tx, v *domain.DocumentVersion) error
+	ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.DocumentVersion, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.DocumentVersion, error)
+}
+
+type FlowRepository interface {
+	NextFlowNoTx(ctx context.Context, tx Tx) (string, error)
+	CreateTx(ctx context.Context, tx Tx, flow *domain.Flow) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Flow, error)
+	GetByIDTx(ctx context.Context, tx Tx, id uuid.UUID) (*domain.Flow, error)
+	UpdateTx(ctx context.Context, tx Tx, flow *domain.Flow) error
+	UpdateStatusTx(ctx context.Context, tx Tx, flow *domain.Flow, from domain.FlowStatus) (bool, error)
+	ListVisible(ctx context.Context, userID uuid.UUID) ([]domain.Flow, error)
+	HasReadAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error)
+	HasDraftAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error)
+	HasEditAccess(ctx context.Context, flowID, userID uuid.UUID) (bool, error)
+	UpdateOwnerTx(ctx context.Context, tx Tx, id, from, to uuid.UUID) (bool, error)
+	ListIDsByOwnerTx(ctx context.Context, tx Tx, ownerID uuid.UUID) ([]uuid.UUID, error)
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	ListDeletedByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.Flow, error)
+	Restore(ctx context.Context, id, ownerID uuid.UUID) (bool, error)
+	PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error)
+}
+
+type WorkflowNodeRepository interface {
+	CreateTx(ctx context.Context, tx Tx, node *domain.WorkflowNode) error
+	UpdateTx(ctx context.Context, tx Tx, node *domain.WorkflowNode) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error)
+	ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.WorkflowNode, error)
+	ListIDsByDocumentTx(ctx context.Context, tx Tx, docID uuid.UUID) ([]uuid.UUID, error)
+	UpdateSortOrderTx(ctx context.Context, tx Tx, docID uuid.UUID, ids []uuid.UUID) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	GetDeletedByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error)
+	ListDeletedEditable(ctx context.Context, userID uuid.UUID) ([]domain.WorkflowNode, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error)
+}
+
+type FlowNodeRepository interface {
+	ListByFlow(ctx context.Context, flowID uuid.UUID) ([]domain.FlowNode, error)
+	ListByFlowTx(ctx context.Context, tx Tx, flowID uuid.UUID) ([]domain.FlowNode, error)
+	ReplaceTx(ctx context.Context, tx Tx, flowID uuid.UUID, nodes []domain.FlowNode) error
+}
+
+type FlowVersionRepository interface {
+	CreateTx(ctx context.Context, tx Tx, v *domain.FlowVersion) error
+	ListByFlow(ctx context.Context, flowID uuid.UUID) ([]domain.FlowVersion, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.FlowVersion, error)
+}
+
+type ShareRepository interface {
+	Create(ctx context.Context, share *domain.Share) error
+	CreateTx(ctx context.Context, tx Tx, share *domain.Share) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Share, error)
+	ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.Share, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteByUserTx(ctx context.Context, tx Tx, resourceID, userID uuid.UUID) error
+}
+
+type GroupShareRepository interface {
+	Create(ctx context.Context, share *domain.GroupShare) error
+	CreateTx(ctx context.Context, tx Tx, share *domain.GroupShare) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.GroupShare, error)
+	ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.GroupShare, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type OwnershipTransferRepository interface {
+	CreateTx(ctx context.Context, tx Tx, t *domain.OwnershipTransfer) error
+	ListByResource(ctx context.Context, resourceType domain.ShareResource, resourceID uuid.UUID) ([]domain.OwnershipTransfer, error)
+	DeleteOrphansTx(ctx context.Context, tx Tx) error
+}
+
+type DocumentConversionRepository interface {
+	ListDocuments(ctx context.Context) ([]domain.Document, error)
+	GetTx(ctx context.Context, tx Tx, documentID uuid.UUID) (*domain.DocumentConversion, error)
+	CreateTx(ctx context.Context, tx Tx, c *domain.DocumentConversion) error
+	List(ctx context.Context) ([]domain.DocumentConversion, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, t *domain.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type APITokenRepository interface {
+	Create(ctx context.Context, t *domain.APIToken) error
+	GetByHash(ctx context.Context, hash string) (*domain.APIToken, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.APIToken, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, key string) (*domain.LoginThrottle, error)
+	RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error)
+	List(ctx context.Context, since time.Time) ([]domain.LoginThrottle, error)
+	Delete(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
+
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, userID uuid.UUID, hashes []string) error
+	Use(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error)
+	CountUnused(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+type SettingRepository interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+}
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *domain.UserIdentity) error
+	Get(ctx context.Context, provider, subject string) (*domain.UserIdentity, error)
+	GetByUser(ctx context.Context, userID uuid.UUID, provider string) (*domain.UserIdentity, error)
+	ListByProvider(ctx context.Context, provider string) ([]domain.UserIdentity, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type GroupRepository interface {
+	Create(ctx context.Context, group *domain.Group) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Group, error)
+	List(ctx context.Context) ([]domain.Group, error)
+	Update(ctx context.Context, group *domain.Group) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error)
+	ListMemberships(ctx context.Context) ([]domain.GroupMember, error)
+	GetMember(ctx context.Context, groupID, userID uuid.UUID) (*domain.GroupMember, error)
+	AddMember(ctx context.Context, groupID, userID uuid.UUID, role domain.GroupRole) error
+	ListOwnersTx(ctx context.Context, tx Tx, groupID uuid.UUID) ([]uuid.UUID, error)
+	UpdateMemberRoleTx(ctx context.Context, tx Tx, groupID, userID uuid.UUID, role domain.GroupRole) error
+	RemoveMember(ctx context.Context, groupID, userID uuid.UUID) error
+	RemoveMemberTx(ctx context.Context, tx Tx, groupID, userID uuid.UUID) error
+}
+
+type OIDCLoginRepository interface {
+	Create(ctx context.Context, login *domain.OIDCLogin) error
+	Take(ctx context.Context, state string) (*domain.OIDCLogin, error)
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *domain.SigningKey) error
+	List(ctx context.Context) ([]domain.SigningKey, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+}
+
+var (
+	_ UserRepository         = (*UserRepo)(nil)
+	_ DocumentRepository     = (*DocumentRepo)(nil)
+	_ VersionRepository      = (*VersionRepo)(nil)
+	_ FlowRepository         = (*FlowRepo)(nil)
+	_ FlowNodeRepository     = (*FlowNodeRepo)(nil)
+	_ FlowVersionRepository  = (*FlowVersionRepo)(nil)
+	_ WorkflowNodeRepository = (*WorkflowNodeRepo)(nil)
+	_ ShareRepository        = (*ShareRepo)(nil)
+	_ GroupShareRepository   = (*GroupShareRepo)(nil)
+	_ RefreshTokenRepository = (*RefreshTokenRepo)(nil)
+	_ APITokenRepository     = (*APITokenRepo)(nil)
+
+	_ OwnershipTransferRepository  = (*OwnershipTransferRepo)(nil)
+	_ DocumentConversionRepository = (*DocumentConversionRepo)(nil)
+
+	_ LoginThrottleRepository   = (*LoginThrottleRepo)(nil)
+	_ PasswordHistoryRepository = (*PasswordHistoryRepo)(nil)
+	_ RecoveryCodeRepository    = (*RecoveryCodeRepo)(nil)
+	_ SettingRepository         = (*SettingRepo)(nil)
+	_ UserIdentityRepository    = (*UserIdentityRepo)(nil)
+	_ OIDCLoginRepository       = (*OIDCLoginRepo)(nil)
+	_ GroupRepository           = (*GroupRepo)(nil)
+	_ SigningKeyRepository      = (*SigningKeyRepo)(nil)
+)
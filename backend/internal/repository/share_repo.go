@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ShareRepo manages grants in document_shares or flow_shares. Both tables
+// have the same shape apart from the foreign-key column name.
+type ShareRepo struct {
+	db       *sqlx.DB
+	resource domain.ShareResource
+	table    string // "document_shares" or "flow_shares"
+	fk       string // "document_id" or "flow_id"
+}
+
+func NewDocumentShareRepo(db *sqlx.DB) *ShareRepo {
+	return &ShareRepo{db: db, resource: domain.ShareResourceDocument, table: "document_shares", fk: "document_id"}
+}
+
+func NewFlowShareRepo(db *sqlx.DB) *ShareRepo {
+	return &ShareRepo{db: db, resource: domain.ShareResourceFlow, table: "flow_shares", fk: "flow_id"}
+}
+
+func (r *ShareRepo) selectShares() string {
+	return fmt.Sprintf(`SELECT s.id, s.%s AS resource_id, s.user_id, u.email AS user_email, s.role, s.created_at
+		FROM %s s JOIN users u ON u.id = s.user_id`, r.fk, r.table)
+}
+
+// Create inserts a share. A second share for the same (resource, user) pair
+// returns domain.ErrAlreadyExists.
+func (r *ShareRepo) Create(ctx context.Context, share *domain.Share) error {
+	return r.insert(ctx, r.db, share)
+}
+
+// CreateTx inserts a share within the given transaction.
+func (r *ShareRepo) CreateTx(ctx context.Context, tx Tx, share *domain.Share) error {
+	return r.insert(ctx, sqlxTx(tx), share)
+}
+
+func (r *ShareRepo) insert(ctx context.Context, q sqlx.ExtContext, share *domain.Share) error {
+	query := q.Rebind(fmt.Sprintf(`INSERT INTO %s (id, %s, user_id, role, created_at) VALUES (?, ?, ?, ?, ?)`, r.table, r.fk))
+	share.ID = uuid.New()
+	share.Resource = r.resource
+	share.CreatedAt = time.Now()
+	_, err := q.ExecContext(ctx, query, share.ID, share.ResourceID, share.UserID, share.Role, share.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: user already has a share", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("creating share: %w", err)
+	}
+	return nil
+}
+
+func (r *ShareRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Share, error) {
+	var share domain.Share
+	err := r.db.GetContext(ctx, &share, r.db.Rebind(r.selectShares()+` WHERE s.id = ?`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting share: %w", err)
+	}
+	share.Resource = r.resource
+	return &share, nil
+}
+
+// ListByResource returns all shares of a document or flow, oldest first.
+func (r *ShareRepo) ListByResource(ctx context.Context, resourceID uuid.UUID) ([]domain.Share, error) {
+	query := r.db.Rebind(r.selectShares() + fmt.Sprintf(` WHERE s.%s = ? ORDER BY s.created_at ASC`, r.fk))
+	shares := make([]domain.Share, 0)
+	if err := r.db.SelectContext(ctx, &shares, query, resourceID); err != nil {
+		return nil, fmt.Errorf("listing shares: %w", err)
+	}
+	for i := range shares {
+		shares[i].Resource = r.resource
+	}
+	return shares, nil
+}
+
+func (r *ShareRepo) UpdateRole(ctx context.Context, id uuid.UUID, role domain.ShareRole) error {
+	query := r.db.Rebind(fmt.Sprintf(`UPDATE %s SET role = ? WHERE id = ?`, r.table))
+	if _, err := r.db.ExecContext(ctx, query, role, id); err != nil {
+		return fmt.Errorf("updating share: %w", err)
+	}
+	return nil
+}
+
+func (r *ShareRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, r.table))
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("deleting share: %w", err)
+	}
+	return nil
+}
+
+// DeleteByUserTx removes the share a user holds on a resource, if any.
+func (r *ShareRepo) DeleteByUserTx(ctx context.Context, tx Tx, resourceID, userID uuid.UUID) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE %s = ? AND user_id = ?`, r.table, r.fk))
+	if _, err := stx.ExecContext(ctx, query, resourceID, userID); err != nil {
+		return fmt.Errorf("deleting share: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SigningKeyRepo struct {
+	db *sqlx.DB
+}
+
+func NewSigningKeyRepo(db *sqlx.DB) *SigningKeyRepo {
+	return &SigningKeyRepo{db: db}
+}
+
+// Create stores a new key. CreatedAt is set here; a key with the same id
+// returns domain.ErrAlreadyExists.
+func (r *SigningKeyRepo) Create(ctx context.Context, key *domain.SigningKey) error {
+	query := r.db.Rebind(`INSERT INTO signing_keys (id, algorithm, private_key, activates_at, created_at) VALUES (?, ?, ?, ?, ?)`)
+	key.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, key.ID, key.Algorithm, key.PrivateKey, key.ActivatesAt, key.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: signing key %s", domain.ErrAlreadyExists, key.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("creating signing key: %w", err)
+	}
+	return nil
+}
+
+// List returns every key in the order they activate.
+func (r *SigningKeyRepo) List(ctx context.Context) ([]domain.SigningKey, error) {
+	keys := []domain.SigningKey{}
+	err := r.db.SelectContext(ctx, &keys, `SELECT * FROM signing_keys ORDER BY activates_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("listing signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Delete removes a key. Deleting a key that does not exist is not an error.
+func (r *SigningKeyRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM signing_keys WHERE id = ?`), id)
+	if err != nil {
+		return fmt.Errorf("deleting signing key: %w", err)
+	}
+	return nil
+}
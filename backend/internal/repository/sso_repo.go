@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type UserIdentityRepo struct {
+	db *sqlx.DB
+}
+
+func NewUserIdentityRepo(db *sqlx.DB) *UserIdentityRepo {
+	return &UserIdentityRepo{db: db}
+}
+
+// Create links a user to an external identity. An identity that is already
+// linked, or a user already linked at the provider, returns
+// domain.ErrAlreadyExists.
+func (r *UserIdentityRepo) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	query := r.db.Rebind(`INSERT INTO user_identities (id, user_id, provider, subject, created_at) VALUES (?, ?, ?, ?, ?)`)
+	identity.ID = uuid.New()
+	identity.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: identity already linked", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("creating user identity: %w", err)
+	}
+	return nil
+}
+
+func (r *UserIdentityRepo) Get(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	query := r.db.Rebind(`SELECT * FROM user_identities WHERE provider = ? AND subject = ?`)
+	err := r.db.GetContext(ctx, &identity, query, provider, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *UserIdentityRepo) GetByUser(ctx context.Context, userID uuid.UUID, provider string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	query := r.db.Rebind(`SELECT * FROM user_identities WHERE user_id = ? AND provider = ?`)
+	err := r.db.GetContext(ctx, &identity, query, userID, provider)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *UserIdentityRepo) ListByProvider(ctx context.Context, provider string) ([]domain.UserIdentity, error) {
+	identities := make([]domain.UserIdentity, 0)
+	query := r.db.Rebind(`SELECT * FROM user_identities WHERE provider = ? ORDER BY created_at`)
+	if err := r.db.SelectContext(ctx, &identities, query, provider); err != nil {
+		return nil, fmt.Errorf("listing user identities: %w", err)
+	}
+	return identities, nil
+}
+
+// Delete unlinks an identity. Deleting one that is not linked is not an
+// error.
+func (r *UserIdentityRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM user_identities WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("deleting user identity: %w", err)
+	}
+	return nil
+}
+
+type OIDCLoginRepo struct {
+	db *sqlx.DB
+}
+
+func NewOIDCLoginRepo(db *sqlx.DB) *OIDCLoginRepo {
+	return &OIDCLoginRepo{db: db}
+}
+
+// Create stores a login in progress. CreatedAt is set here.
+func (r *OIDCLoginRepo) Create(ctx context.Context, login *domain.OIDCLogin) error {
+	query := r.db.Rebind(`INSERT INTO oidc_logins (state, nonce, code_verifier, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`)
+	login.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, login.State, login.Nonce, login.CodeVerifier, login.ExpiresAt, login.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("creating oidc login: %w", err)
+	}
+	return nil
+}
+
+// Take returns the login with the given state and deletes it, so each
+// callback is accepted once. Of two concurrent calls, one gets
+// domain.ErrNotFound.
+func (r *OIDCLoginRepo) Take(ctx context.Context, state string) (*domain.OIDCLogin, error) {
+	var login domain.OIDCLogin
+	err := r.db.GetContext(ctx, &login, r.db.Rebind(`SELECT * FROM oidc_logins WHERE state = ?`), state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting oidc login: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM oidc_logins WHERE state = ?`), state)
+	if err != nil {
+		return nil, fmt.Errorf("deleting oidc login: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return &login, nil
+}
+
+// DeleteExpired removes logins that expired before the given time and
+// returns how many were removed.
+func (r *OIDCLoginRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM oidc_logins WHERE expires_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired oidc logins: %w", err)
+	}
+	return result.RowsAffected()
+}
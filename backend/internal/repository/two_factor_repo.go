@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RecoveryCodeRepo struct {
+	db *sqlx.DB
+}
+
+func NewRecoveryCodeRepo(db *sqlx.DB) *RecoveryCodeRepo {
+	return &RecoveryCodeRepo{db: db}
+}
+
+// Replace swaps the user's recovery codes for the given hashes. No hashes
+// removes them all.
+func (r *RecoveryCodeRepo) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("replacing recovery codes: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM recovery_codes WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("deleting recovery codes: %w", err)
+	}
+	insert := tx.Rebind(`INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`)
+	now := time.Now()
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, insert, uuid.New(), userID, hash, now); err != nil {
+			return fmt.Errorf("creating recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Use marks one of the user's unused codes as used. It returns false when
+// the user has no unused code with that hash.
+func (r *RecoveryCodeRepo) Use(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+	query := r.db.Rebind(`UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`)
+	result, err := r.db.ExecContext(ctx, query, at, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("using recovery code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *RecoveryCodeRepo) CountUnused(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	query := r.db.Rebind(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`)
+	if err := r.db.GetContext(ctx, &n, query, userID); err != nil {
+		return 0, fmt.Errorf("counting recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+type SettingRepo struct {
+	db *sqlx.DB
+}
+
+func NewSettingRepo(db *sqlx.DB) *SettingRepo {
+	return &SettingRepo{db: db}
+}
+
+// Get returns a setting's value, or domain.ErrNotFound when it was never set.
+func (r *SettingRepo) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.db.GetContext(ctx, &value, r.db.Rebind(`SELECT value FROM app_settings WHERE setting_key = ?`), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (r *SettingRepo) Set(ctx context.Context, key, value string) error {
+	now := time.Now()
+	update := r.db.Rebind(`UPDATE app_settings SET value = ?, updated_at = ? WHERE setting_key = ?`)
+	result, err := r.db.ExecContext(ctx, update, value, now, key)
+	if err != nil {
+		return fmt.Errorf("updating setting %s: %w", key, err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return nil
+	}
+	insert := r.db.Rebind(`INSERT INTO app_settings (setting_key, value, updated_at) VALUES (?, ?, ?)`)
+	_, err = r.db.ExecContext(ctx, insert, key, value, now)
+	if isUniqueViolation(err) {
+		// Set concurrently; one of the two values wins, as with any update.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("creating setting %s: %w", key, err)
+	}
+	return nil
+}
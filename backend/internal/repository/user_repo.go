@@ -21,15 +21,27 @@ func NewUserRepo(db *sqlx.DB) *UserRepo {
 	return &UserRepo{db: db}
 }
 
+// Create inserts an active user. A second user with the same email returns
+// domain.ErrAlreadyExists.
 func (r *UserRepo) Create(ctx context.Context, user *domain.User) error {
-	query := r.db.Rebind(`INSERT INTO users (id, email, password_hash, role, created_at)
-	           VALUES (?, ?, ?, ?, ?)`)
+	query := r.db.Rebind(`INSERT INTO users
+		(id, email, password_hash, role, display_name, department, locale, is_active, token_version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	user.ID = uuid.New()
 	if user.Role == "" {
 		user.Role = domain.RoleUser
 	}
+	if user.Locale == "" {
+		user.Locale = domain.LocaleZhCN
+	}
+	user.Active = true
+	user.TokenVersion = 1
 	user.CreatedAt = time.Now()
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.PasswordHash, user.Role, user.CreatedAt)
+	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.PasswordHash, user.Role,
+		user.DisplayName, user.Department, user.Locale, user.Active, user.TokenVersion, user.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: email already registered", domain.ErrAlreadyExists)
+	}
 	if err != nil {
 		return fmt.Errorf("creating user: %w", err)
 	}
@@ -63,16 +75,18 @@ func (r *UserRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, err
 // List returns all users (admin operation). Passwords are excluded by json:"-" tag.
 func (r *UserRepo) List(ctx context.Context) ([]domain.User, error) {
 	users := make([]domain.User, 0)
-	err := r.db.SelectContext(ctx, &users, `SELECT id, email, role, created_at FROM users ORDER BY created_at DESC`)
+	err := r.db.SelectContext(ctx, &users, `SELECT id, email, role, display_name, department, locale, is_active, two_factor_enabled, created_at
+		FROM users ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("listing users: %w", err)
 	}
 	return users, nil
 }
 
-// UpdatePassword changes a user's password hash.
+// UpdatePassword changes a user's password hash and bumps the token version,
+// invalidating every token issued before the change.
 func (r *UserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, hash string) error {
-	query := r.db.Rebind(`UPDATE users SET password_hash = ? WHERE id = ?`)
+	query := r.db.Rebind(`UPDATE users SET password_hash = ?, token_version = token_version + 1 WHERE id = ?`)
 	result, err := r.db.ExecContext(ctx, query, hash, userID)
 	if err != nil {
 		return fmt.Errorf("updating password: %w", err)
@@ -83,3 +97,122 @@ func (r *UserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, hash st
 	}
 	return nil
 }
+
+// UpdateProfile changes the fields a user maintains themselves. Like the
+// other user updates below, it does not report a missing user: MySQL counts
+// only rows whose values change, so callers check existence beforehand.
+func (r *UserRepo) UpdateProfile(ctx context.Context, userID uuid.UUID, displayName, department string, locale domain.Locale) error {
+	query := r.db.Rebind(`UPDATE users SET display_name = ?, department = ?, locale = ? WHERE id = ?`)
+	if _, err := r.db.ExecContext(ctx, query, displayName, department, locale, userID); err != nil {
+		return fmt.Errorf("updating profile: %w", err)
+	}
+	return nil
+}
+
+// UpdateRole changes a user's role. Tokens already issued pick up the new
+// role on their next request, since the role is read from this table.
+func (r *UserRepo) UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error {
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE users SET role = ? WHERE id = ?`), role, userID); err != nil {
+		return fmt.Errorf("updating role: %w", err)
+	}
+	return nil
+}
+
+// UpdateEmail changes a user's email, which is also their login. An email
+// registered to another user returns domain.ErrAlreadyExists.
+func (r *UserRepo) UpdateEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE users SET email = ? WHERE id = ?`), email, userID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: email already registered", domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("updating email: %w", err)
+	}
+	return nil
+}
+
+// SetActive deactivates or reactivates a user. Deactivating also bumps the
+// token version, so the user's sessions end even where the active flag is
+// not checked.
+func (r *UserRepo) SetActive(ctx context.Context, userID uuid.UUID, active bool) error {
+	query := `UPDATE users SET is_active = ? WHERE id = ?`
+	if !active {
+		query = `UPDATE users SET is_active = ?, token_version = token_version + 1 WHERE id = ?`
+	}
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), active, userID); err != nil {
+		return fmt.Errorf("updating active flag: %w", err)
+	}
+	return nil
+}
+
+// SetTwoFactor stores a user's TOTP secret and whether it is confirmed, and
+// forgets the last accepted time step. An empty secret removes enrollment.
+func (r *UserRepo) SetTwoFactor(ctx context.Context, userID uuid.UUID, secret string, enabled bool) error {
+	query := r.db.Rebind(`UPDATE users SET totp_secret = ?, two_factor_enabled = ?, totp_last_step = 0 WHERE id = ?`)
+	if _, err := r.db.ExecContext(ctx, query, secret, enabled, userID); err != nil {
+		return fmt.Errorf("updating two-factor settings: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code for the given time step was accepted. It
+// returns false when a code of this or a later step was accepted before, so
+// each code works once even under concurrent requests.
+func (r *UserRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := r.db.Rebind(`UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`)
+	result, err := r.db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("recording totp step: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// userReferences lists the columns that keep a user's work attributable. A
+// user referenced by any of them cannot be deleted.
+var userReferences = []struct{ table, column string }{
+	{"documents", "owner_id"},
+	{"flows", "owner_id"},
+	{"document_versions", "created_by"},
+	{"flow_versions", "created_by"},
+	{"ownership_transfers", "from_user_id"},
+	{"ownership_transfers", "to_user_id"},
+	{"ownership_transfers", "transferred_by"},
+}
+
+// Delete removes a user together with the shares granted to them; tokens go
+// with the user through ON DELETE CASCADE. A user who owns documents or
+// flows, trashed ones included, or appears in version or transfer history
+// is kept and ErrInvalidState returned: transfer ownership first, or
+// deactivate the account instead.
+func (r *UserRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, ref := range userReferences {
+		var n int
+		query := tx.Rebind(`SELECT COUNT(*) FROM ` + ref.table + ` WHERE ` + ref.column + ` = ?`)
+		if err := tx.GetContext(ctx, &n, query, userID); err != nil {
+			return fmt.Errorf("checking %s.%s: %w", ref.table, ref.column, err)
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: user is referenced by %s", domain.ErrInvalidState, ref.table)
+		}
+	}
+	for _, table := range []string{"document_shares", "flow_shares"} {
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM `+table+` WHERE user_id = ?`), userID); err != nil {
+			return fmt.Errorf("deleting %s: %w", table, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM users WHERE id = ?`), userID)
+	if err != nil {
+		return fmt.Errorf("deleting user: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrNotFound
+	}
+	return tx.Commit()
+}
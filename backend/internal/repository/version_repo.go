@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -19,12 +21,13 @@ func NewVersionRepo(db *sqlx.DB) *VersionRepo {
 	return &VersionRepo{db: db}
 }
 
-func (r *VersionRepo) CreateTx(ctx context.Context, tx *sqlx.Tx, v *domain.DocumentVersion) error {
-	query := tx.Rebind(`INSERT INTO document_versions (id, document_id, content, created_by, created_at)
-		VALUES (?, ?, ?, ?, ?)`)
+func (r *VersionRepo) CreateTx(ctx context.Context, tx Tx, v *domain.DocumentVersion) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`INSERT INTO document_versions (id, document_id, content, created_by, created_at, restored_from_version_id)
+		VALUES (?, ?, ?, ?, ?, ?)`)
 	v.ID = uuid.New()
 	v.CreatedAt = time.Now()
-	_, err := tx.ExecContext(ctx, query, v.ID, v.DocumentID, v.Content, v.CreatedBy, v.CreatedAt)
+	_, err := stx.ExecContext(ctx, query, v.ID, v.DocumentID, v.Content, v.CreatedBy, v.CreatedAt, v.RestoredFromVersionID)
 	if err != nil {
 		return fmt.Errorf("creating version: %w", err)
 	}
@@ -39,3 +42,15 @@ func (r *VersionRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]do
 	}
 	return versions, nil
 }
+
+func (r *VersionRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.DocumentVersion, error) {
+	var v domain.DocumentVersion
+	err := r.db.GetContext(ctx, &v, r.db.Rebind(`SELECT * FROM document_versions WHERE id = ?`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting version: %w", err)
+	}
+	return &v, nil
+}
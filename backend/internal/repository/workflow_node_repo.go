@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type WorkflowNodeRepo struct {
+	db *sqlx.DB
+}
+
+func NewWorkflowNodeRepo(db *sqlx.DB) *WorkflowNodeRepo {
+	return &WorkflowNodeRepo{db: db}
+}
+
+// CreateTx inserts a new workflow node at the end of its document's node list,
+// within the given transaction.
+func (r *WorkflowNodeRepo) CreateTx(ctx context.Context, tx Tx, node *domain.WorkflowNode) error {
+	stx := sqlxTx(tx)
+	err := stx.GetContext(ctx, &node.SortOrder,
+		stx.Rebind(`SELECT COALESCE(MAX(sort_order) + 1, 0) FROM workflow_nodes WHERE document_id = ?`), node.DocumentID)
+	if err != nil {
+		return fmt.Errorf("finding next node position: %w", err)
+	}
+
+	query := stx.Rebind(`INSERT INTO workflow_nodes
+		(id, document_id, name, exec_form, description, preconditions, outputs,
+		 duration_min, duration_max, duration_unit, raci_json, subtasks_json, diagram_json,
+		 sort_order, revision, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	node.ID = uuid.New()
+	node.Revision = 1
+	now := time.Now()
+	node.CreatedAt = now
+	node.UpdatedAt = now
+	_, err = stx.ExecContext(ctx, query,
+		node.ID, node.DocumentID, node.Name, node.ExecForm,
+		node.Description, node.Preconditions, node.Outputs,
+		node.DurationMin, node.DurationMax, node.DurationUnit,
+		node.RaciJSON, node.SubtasksJSON, node.DiagramRaw,
+		node.SortOrder, node.Revision, node.CreatedAt, node.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("creating workflow node: %w", err)
+	}
+	return nil
+}
+
+// UpdateTx updates an existing workflow node within the given transaction if
+// it is still at node.Revision, and bumps the revision. It returns
+// domain.ErrStaleRevision if another write got there first.
+func (r *WorkflowNodeRepo) UpdateTx(ctx context.Context, tx Tx, node *domain.WorkflowNode) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE workflow_nodes SET
+		name = ?, exec_form = ?, description = ?, preconditions = ?, outputs = ?,
+		duration_min = ?, duration_max = ?, duration_unit = ?,
+		raci_json = ?, subtasks_json = ?, diagram_json = ?, revision = revision + 1, updated_at = ?
+		WHERE id = ? AND revision = ?`)
+	node.UpdatedAt = time.Now()
+	result, err := stx.ExecContext(ctx, query,
+		node.Name, node.ExecForm, node.Description, node.Preconditions, node.Outputs,
+		node.DurationMin, node.DurationMax, node.DurationUnit,
+		node.RaciJSON, node.SubtasksJSON, node.DiagramRaw, node.UpdatedAt,
+		node.ID, node.Revision,
+	)
+	if err != nil {
+		return fmt.Errorf("updating workflow node: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return domain.ErrStaleRevision
+	}
+	node.Revision++
+	return nil
+}
+
+// GetByID returns a single workflow node.
+func (r *WorkflowNodeRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error) {
+	var node domain.WorkflowNode
+	err := r.db.GetContext(ctx, &node, r.db.Rebind(`SELECT * FROM workflow_nodes WHERE id = ? AND deleted_at IS NULL`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow node: %w", err)
+	}
+	node.HydrateJSON()
+	return &node, nil
+}
+
+// ListByDocument returns all workflow nodes for a document in display order.
+func (r *WorkflowNodeRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.WorkflowNode, error) {
+	query := r.db.Rebind(`SELECT * FROM workflow_nodes WHERE document_id = ? AND deleted_at IS NULL
+		ORDER BY sort_order ASC, created_at ASC`)
+	nodes := make([]domain.WorkflowNode, 0)
+	if err := r.db.SelectContext(ctx, &nodes, query, docID); err != nil {
+		return nil, fmt.Errorf("listing workflow nodes: %w", err)
+	}
+	for i := range nodes {
+		nodes[i].HydrateJSON()
+	}
+	return nodes, nil
+}
+
+// ListIDsByDocumentTx returns the IDs of a document's live nodes, within the given transaction.
+func (r *WorkflowNodeRepo) ListIDsByDocumentTx(ctx context.Context, tx Tx, docID uuid.UUID) ([]uuid.UUID, error) {
+	stx := sqlxTx(tx)
+	ids := make([]uuid.UUID, 0)
+	query := stx.Rebind(`SELECT id FROM workflow_nodes WHERE document_id = ? AND deleted_at IS NULL`)
+	if err := stx.SelectContext(ctx, &ids, query, docID); err != nil {
+		return nil, fmt.Errorf("listing workflow node ids: %w", err)
+	}
+	return ids, nil
+}
+
+// UpdateSortOrderTx sets each node's sort_order to its position in ids.
+func (r *WorkflowNodeRepo) UpdateSortOrderTx(ctx context.Context, tx Tx, docID uuid.UUID, ids []uuid.UUID) error {
+	stx := sqlxTx(tx)
+	query := stx.Rebind(`UPDATE workflow_nodes SET sort_order = ? WHERE id = ? AND document_id = ?`)
+	for i, id := range ids {
+		if _, err := stx.ExecContext(ctx, query, i, id, docID); err != nil {
+			return fmt.Errorf("updating workflow node order: %w", err)
+		}
+	}
+	return nil
+}
+
+// SoftDelete moves a live workflow node to the trash.
+func (r *WorkflowNodeRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(`UPDATE workflow_nodes SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`)
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("deleting workflow node: %w", err)
+	}
+	return nil
+}
+
+// GetDeletedByID returns a workflow node that is in the trash.
+func (r *WorkflowNodeRepo) GetDeletedByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowNode, error) {
+	var node domain.WorkflowNode
+	err := r.db.GetContext(ctx, &node, r.db.Rebind(`SELECT * FROM workflow_nodes WHERE id = ? AND deleted_at IS NOT NULL`), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting deleted workflow node: %w", err)
+	}
+	node.HydrateJSON()
+	return &node, nil
+}
+
+// ListDeletedEditable returns trashed nodes of live documents the user can
+// edit (owner, or EDIT share with the user or one of their groups), most
+// recently deleted first.
+func (r *WorkflowNodeRepo) ListDeletedEditable(ctx context.Context, userID uuid.UUID) ([]domain.WorkflowNode, error) {
+	query := r.db.Rebind(`
+		SELECT n.* FROM workflow_nodes n
+		JOIN documents d ON d.id = n.document_id
+		LEFT JOIN document_shares ds ON d.id = ds.document_id AND ds.user_id = ? AND ds.role = 'EDIT'
+		WHERE n.deleted_at IS NOT NULL AND d.deleted_at IS NULL AND (d.owner_id = ? OR ds.id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM document_group_shares gs JOIN user_group_members gm ON gm.group_id = gs.group_id
+				WHERE gs.document_id = d.id AND gm.user_id = ? AND gs.role = 'EDIT'))
+		ORDER BY n.deleted_at DESC`)
+	nodes := make([]domain.WorkflowNode, 0)
+	if err := r.db.SelectContext(ctx, &nodes, query, userID, userID, userID); err != nil {
+		return nil, fmt.Errorf("listing deleted workflow nodes: %w", err)
+	}
+	for i := range nodes {
+		nodes[i].HydrateJSON()
+	}
+	return nodes, nil
+}
+
+// Restore takes a workflow node out of the trash.
+func (r *WorkflowNodeRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	query := r.db.Rebind(`UPDATE workflow_nodes SET deleted_at = NULL WHERE id = ?`)
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("restoring workflow node: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedTx hard-deletes workflow nodes trashed before the cutoff.
+func (r *WorkflowNodeRepo) PurgeDeletedTx(ctx context.Context, tx Tx, before time.Time) (int64, error) {
+	stx := sqlxTx(tx)
+	result, err := stx.ExecContext(ctx, stx.Rebind(`DELETE FROM workflow_nodes WHERE deleted_at IS NOT NULL AND deleted_at < ?`), before)
+	if err != nil {
+		return 0, fmt.Errorf("purging workflow nodes: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,511 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2), such as
+//
+//	userName eq "alice@example.com"
+//	emails[type eq "work" and value ew "@example.com"] or not (active pr)
+//
+// Attribute names and string comparisons are case-insensitive.
+type Filter struct {
+	root expr
+}
+
+// ParseFilter parses a filter expression. Errors are *Error with scimType
+// invalidFilter.
+func ParseFilter(s string) (*Filter, error) {
+	p := &parser{lex: lexer{s: s}}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.kind != tokEOF {
+		return nil, badRequest(ErrInvalidFilter, "unexpected %q in filter", tok.text)
+	}
+	return &Filter{root: e}, nil
+}
+
+// Match reports whether a resource in its JSON form matches the filter.
+func (f *Filter) Match(resource map[string]any) bool {
+	return f.root.eval(resource)
+}
+
+// attrPath names an attribute: its schema, if an extension's, the attribute
+// and a sub-attribute of a complex attribute.
+type attrPath struct {
+	urn  string
+	attr string
+	sub  string
+}
+
+// knownSchemas lets paths start with a schema URN, which itself contains
+// colons and dots.
+var knownSchemas = []string{UserSchema, GroupSchema, EnterpriseUserSchema}
+
+func parseAttrPath(s string) (attrPath, bool) {
+	var p attrPath
+	for _, schema := range knownSchemas {
+		if len(s) < len(schema) || !strings.EqualFold(s[:len(schema)], schema) {
+			continue
+		}
+		rest := s[len(schema):]
+		switch {
+		case rest == "":
+			// The extension's attributes as a whole.
+			p.attr = schema
+			return p, true
+		case rest[0] == ':':
+			if schema != UserSchema && schema != GroupSchema {
+				p.urn = schema
+			}
+			s = rest[1:]
+		default:
+			continue
+		}
+		break
+	}
+	p.attr, p.sub, _ = strings.Cut(s, ".")
+	if !validName(p.attr) || (p.sub != "" && !validName(p.sub)) {
+		return attrPath{}, false
+	}
+	return p, true
+}
+
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '$':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// values returns the values a path refers to in a resource. A multi-valued
+// attribute gives each of its values; of complex ones, their sub-attribute,
+// or their "value" when the path has none.
+func (p attrPath) values(resource map[string]any) []any {
+	container := resource
+	if p.urn != "" {
+		container, _ = lookup(resource, p.urn).(map[string]any)
+	}
+	v := lookup(container, p.attr)
+	items, multi := v.([]any)
+	if !multi {
+		items = []any{v}
+	}
+	var out []any
+	for _, item := range items {
+		m, complex := item.(map[string]any)
+		switch {
+		case p.sub != "":
+			if complex {
+				out = append(out, lookup(m, p.sub))
+			}
+		case complex && multi:
+			out = append(out, lookup(m, "value"))
+		default:
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// lookup returns the attribute of m with the given name, ignoring case.
+func lookup(m map[string]any, name string) any {
+	if k, ok := findKey(m, name); ok {
+		return m[k]
+	}
+	return nil
+}
+
+// findKey returns the key m holds name under.
+func findKey(m map[string]any, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return name, false
+}
+
+// ---------- Expressions ----------
+
+type expr interface {
+	eval(resource map[string]any) bool
+}
+
+type andExpr struct{ left, right expr }
+
+func (e andExpr) eval(r map[string]any) bool { return e.left.eval(r) && e.right.eval(r) }
+
+type orExpr struct{ left, right expr }
+
+func (e orExpr) eval(r map[string]any) bool { return e.left.eval(r) || e.right.eval(r) }
+
+type notExpr struct{ inner expr }
+
+func (e notExpr) eval(r map[string]any) bool { return !e.inner.eval(r) }
+
+// valuePathExpr matches when a value of a multi-valued complex attribute
+// matches the inner filter, as in emails[type eq "work"].
+type valuePathExpr struct {
+	path  attrPath
+	inner expr
+}
+
+func (e valuePathExpr) eval(r map[string]any) bool {
+	container := r
+	if e.path.urn != "" {
+		container, _ = lookup(r, e.path.urn).(map[string]any)
+	}
+	v := lookup(container, e.path.attr)
+	items, ok := v.([]any)
+	if !ok {
+		items = []any{v}
+	}
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok && e.inner.eval(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type compareExpr struct {
+	path  attrPath
+	op    string // lower-case operator
+	value any    // string, float64, bool or nil
+}
+
+func (e compareExpr) eval(r map[string]any) bool {
+	values := e.path.values(r)
+	switch e.op {
+	case "pr":
+		for _, v := range values {
+			if present(v) {
+				return true
+			}
+		}
+		return false
+	case "ne":
+		for _, v := range values {
+			if compare(v, "eq", e.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if compare(v, e.op, e.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func present(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
+
+func compare(a any, op string, b any) bool {
+	if b == nil {
+		return op == "eq" && a == nil
+	}
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return false
+		}
+		a, b = strings.ToLower(a), strings.ToLower(b)
+		switch op {
+		case "eq":
+			return a == b
+		case "co":
+			return strings.Contains(a, b)
+		case "sw":
+			return strings.HasPrefix(a, b)
+		case "ew":
+			return strings.HasSuffix(a, b)
+		case "gt":
+			return a > b
+		case "ge":
+			return a >= b
+		case "lt":
+			return a < b
+		case "le":
+			return a <= b
+		}
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == b
+		case "gt":
+			return a > b
+		case "ge":
+			return a >= b
+		case "lt":
+			return a < b
+		case "le":
+			return a <= b
+		}
+	case bool:
+		return op == "eq" && a == b
+	}
+	return false
+}
+
+// ---------- Parser ----------
+
+var compareOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+type parser struct {
+	lex    lexer
+	peeked *token
+}
+
+func (p *parser) next() token {
+	if p.peeked != nil {
+		tok := *p.peeked
+		p.peeked = nil
+		return tok
+	}
+	return p.lex.next()
+}
+
+func (p *parser) peek() token {
+	if p.peeked == nil {
+		tok := p.lex.next()
+		p.peeked = &tok
+	}
+	return *p.peeked
+}
+
+// keyword consumes the next token if it is the word kw, in any case.
+func (p *parser) keyword(kw string) bool {
+	if tok := p.peek(); tok.kind == tokWord && strings.EqualFold(tok.text, kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if tok := p.next(); tok.kind != kind {
+		return badRequest(ErrInvalidFilter, "expected %s, found %q", what, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.keyword("not") {
+		if err := p.expect(tokLParen, `"(" after not`); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		return p.parseGroup()
+	}
+	return p.parseAttrExpr()
+}
+
+// parseGroup parses the rest of a parenthesized filter.
+func (p *parser) parseGroup() (expr, error) {
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokRParen, `")"`); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+func (p *parser) parseAttrExpr() (expr, error) {
+	tok := p.next()
+	if tok.kind != tokWord {
+		return nil, badRequest(ErrInvalidFilter, "expected an attribute, found %q", tok.text)
+	}
+	path, ok := parseAttrPath(tok.text)
+	if !ok {
+		return nil, badRequest(ErrInvalidFilter, "invalid attribute %q", tok.text)
+	}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		if path.sub != "" {
+			return nil, badRequest(ErrInvalidFilter, "invalid attribute %q", tok.text)
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRBracket, `"]"`); err != nil {
+			return nil, err
+		}
+		return valuePathExpr{path, inner}, nil
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind != tokWord || (op != "pr" && !compareOps[op]) {
+		return nil, badRequest(ErrInvalidFilter, "expected an operator after %s, found %q", tok.text, opTok.text)
+	}
+	if op == "pr" {
+		return compareExpr{path: path, op: op}, nil
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{path: path, op: op, value: value}, nil
+}
+
+func (p *parser) parseValue() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return tok.text, nil
+	case tokWord:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if n, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return n, nil
+		}
+	}
+	return nil, badRequest(ErrInvalidFilter, "invalid value %q", tok.text)
+}
+
+// ---------- Lexer ----------
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.s) && l.s[l.pos] == ' ' {
+		l.pos++
+	}
+	if l.pos == len(l.s) {
+		return token{kind: tokEOF, text: "end of filter"}
+	}
+	start := l.pos
+	switch c := l.s[l.pos]; c {
+	case '(', ')', '[', ']':
+		l.pos++
+		return token{kind: map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket}[c], text: string(c)}
+	case '"':
+		l.pos++
+		for l.pos < len(l.s) && l.s[l.pos] != '"' {
+			if l.s[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.s) {
+			return token{kind: tokInvalid, text: l.s[start:]}
+		}
+		l.pos++
+		var s string
+		if err := json.Unmarshal([]byte(l.s[start:l.pos]), &s); err != nil {
+			return token{kind: tokInvalid, text: l.s[start:l.pos]}
+		}
+		return token{kind: tokString, text: s}
+	}
+	for l.pos < len(l.s) && !strings.ContainsRune(` ()[]"`, rune(l.s[l.pos])) {
+		l.pos++
+	}
+	return token{kind: tokWord, text: l.s[start:l.pos]}
+}
@@ -0,0 +1,307 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// patchPath is the target of a PATCH operation: an attribute path, with a
+// value filter for multi-valued attributes, as in
+// members[value eq "2819c223"] or emails[type eq "work"].value.
+type patchPath struct {
+	attrPath
+	filter expr
+}
+
+func parsePatchPath(s string) (patchPath, error) {
+	var p patchPath
+	attr, rest, filtered := strings.Cut(s, "[")
+	if filtered {
+		end := strings.LastIndex(rest, "]")
+		if end < 0 {
+			return p, badRequest(ErrInvalidPath, "unterminated filter in path %q", s)
+		}
+		f, err := ParseFilter(rest[:end])
+		if err != nil {
+			return p, badRequest(ErrInvalidPath, "invalid filter in path %q: %s", s, err.(*Error).Detail)
+		}
+		p.filter = f.root
+		rest = rest[end+1:]
+		if rest != "" {
+			sub, ok := strings.CutPrefix(rest, ".")
+			if !ok || !validName(sub) {
+				return p, badRequest(ErrInvalidPath, "invalid path %q", s)
+			}
+			p.sub = sub
+		}
+	}
+	ap, ok := parseAttrPath(attr)
+	if !ok || (filtered && ap.sub != "") {
+		return p, badRequest(ErrInvalidPath, "invalid path %q", s)
+	}
+	p.urn, p.attr = ap.urn, ap.attr
+	if !filtered {
+		p.sub = ap.sub
+	}
+	return p, nil
+}
+
+// Patch applies PATCH operations (RFC 7644 section 3.5.2) to a resource in
+// its JSON form. Operation names and attribute names are case-insensitive.
+// Errors are *Error.
+//
+// Adding to a multi-valued attribute appends the values it does not have
+// yet. A filtered path that matches no value adds one made from the
+// filter's equality tests, the way clients set emails[type eq "work"].value.
+// Removing from a multi-valued attribute with a value removes those values,
+// matched by their "value".
+func Patch(resource map[string]any, ops []PatchOp) error {
+	for _, op := range ops {
+		var value any
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return badRequest(ErrInvalidSyntax, "invalid value of %s operation", op.Op)
+			}
+		}
+		name := strings.ToLower(op.Op)
+		if name != "add" && name != "replace" && name != "remove" {
+			return badRequest(ErrInvalidSyntax, "unknown operation %q", op.Op)
+		}
+
+		if op.Path == "" {
+			if name == "remove" {
+				return badRequest(ErrNoTarget, "remove needs a path")
+			}
+			// Without a path, each attribute of the value is its own target.
+			attrs, ok := value.(map[string]any)
+			if !ok {
+				return badRequest(ErrInvalidValue, "%s without a path needs an object value", op.Op)
+			}
+			for attr, v := range attrs {
+				path, err := parsePatchPath(attr)
+				if err != nil {
+					return err
+				}
+				if err := apply(resource, name, path, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := parsePatchPath(op.Path)
+		if err != nil {
+			return err
+		}
+		if name != "remove" && len(op.Value) == 0 {
+			return badRequest(ErrInvalidValue, "%s of %s needs a value", op.Op, op.Path)
+		}
+		if err := apply(resource, name, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(resource map[string]any, op string, path patchPath, value any) error {
+	container := resource
+	if path.urn != "" {
+		key, _ := findKey(resource, path.urn)
+		ext, ok := resource[key].(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			ext = make(map[string]any)
+			resource[key] = ext
+		}
+		container = ext
+	}
+	key, _ := findKey(container, path.attr)
+
+	if path.filter != nil {
+		return applyFiltered(container, key, op, path, value)
+	}
+
+	current := container[key]
+	if path.sub != "" {
+		// A sub-attribute of a complex attribute, or of every value of a
+		// multi-valued one.
+		if items, ok := current.([]any); ok {
+			for _, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					setSub(m, op, path.sub, value)
+				}
+			}
+			return nil
+		}
+		m, ok := current.(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			m = make(map[string]any)
+			container[key] = m
+		}
+		setSub(m, op, path.sub, value)
+		return nil
+	}
+
+	switch op {
+	case "remove":
+		items, multi := current.([]any)
+		if !multi || value == nil {
+			delete(container, key)
+			return nil
+		}
+		remove, ok := value.([]any)
+		if !ok {
+			remove = []any{value}
+		}
+		kept := make([]any, 0, len(items))
+		for _, item := range items {
+			if !containsValue(remove, item) {
+				kept = append(kept, item)
+			}
+		}
+		container[key] = kept
+	case "add":
+		if items, ok := current.([]any); ok {
+			add, ok := value.([]any)
+			if !ok {
+				add = []any{value}
+			}
+			for _, v := range add {
+				if !containsValue(items, v) {
+					items = append(items, v)
+				}
+			}
+			container[key] = items
+			return nil
+		}
+		fallthrough
+	case "replace":
+		cur, curComplex := current.(map[string]any)
+		val, valComplex := value.(map[string]any)
+		if curComplex && valComplex {
+			for k, v := range val {
+				setSub(cur, "replace", k, v)
+			}
+			return nil
+		}
+		container[key] = value
+	}
+	return nil
+}
+
+// applyFiltered applies an operation to the values of a multi-valued
+// attribute that match the path's filter.
+func applyFiltered(container map[string]any, key, op string, path patchPath, value any) error {
+	items, _ := container[key].([]any)
+	var matched []int
+	for i, item := range items {
+		if m, ok := item.(map[string]any); ok && path.filter.eval(m) {
+			matched = append(matched, i)
+		}
+	}
+
+	if op == "remove" {
+		kept := make([]any, 0, len(items))
+		for i, item := range items {
+			switch {
+			case !slices.Contains(matched, i):
+				kept = append(kept, item)
+			case path.sub != "":
+				delete(item.(map[string]any), keyOf(item.(map[string]any), path.sub))
+				kept = append(kept, item)
+			}
+		}
+		container[key] = kept
+		return nil
+	}
+
+	if len(matched) == 0 {
+		item, ok := itemFromFilter(path.filter)
+		if !ok {
+			return badRequest(ErrNoTarget, "no value of %s matches the filter", path.attr)
+		}
+		items = append(items, item)
+		matched = []int{len(items) - 1}
+	}
+	for _, i := range matched {
+		m := items[i].(map[string]any)
+		switch val, complex := value.(map[string]any); {
+		case path.sub != "":
+			setSub(m, op, path.sub, value)
+		case complex && op == "add":
+			for k, v := range val {
+				setSub(m, "replace", k, v)
+			}
+		case complex:
+			items[i] = val
+		default:
+			return badRequest(ErrInvalidValue, "a value of %s must be an object", path.attr)
+		}
+	}
+	container[key] = items
+	return nil
+}
+
+// itemFromFilter builds the value a filter of equality tests describes.
+func itemFromFilter(e expr) (map[string]any, bool) {
+	item := make(map[string]any)
+	var collect func(e expr) bool
+	collect = func(e expr) bool {
+		switch e := e.(type) {
+		case andExpr:
+			return collect(e.left) && collect(e.right)
+		case compareExpr:
+			if e.op != "eq" || e.path.urn != "" || e.path.sub != "" {
+				return false
+			}
+			item[e.path.attr] = e.value
+			return true
+		}
+		return false
+	}
+	return item, collect(e)
+}
+
+func setSub(m map[string]any, op, sub string, value any) {
+	key := keyOf(m, sub)
+	if op == "remove" {
+		delete(m, key)
+		return
+	}
+	m[key] = value
+}
+
+func keyOf(m map[string]any, name string) string {
+	key, _ := findKey(m, name)
+	return key
+}
+
+// containsValue reports whether values holds v, comparing the "value" of
+// complex values.
+func containsValue(values []any, v any) bool {
+	for _, candidate := range values {
+		if sameValue(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameValue(a, b any) bool {
+	am, aComplex := a.(map[string]any)
+	bm, bComplex := b.(map[string]any)
+	if aComplex && bComplex {
+		av, bv := lookup(am, "value"), lookup(bm, "value")
+		if av != nil || bv != nil {
+			return compare(av, "eq", bv)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,197 @@
+// Package scim implements the protocol parts of SCIM 2.0 (RFC 7643, RFC
+// 7644) that provisioning needs: the User and Group resources, filters and
+// PATCH operations. Filters and patches work on resources in their JSON
+// form, map[string]any, so they need no knowledge of a resource's fields.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema URNs.
+const (
+	UserSchema           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	EnterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	ListResponseSchema   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema          = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Values of Error.ScimType.
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidValue  = "invalidValue"
+	ErrNoTarget      = "noTarget"
+	ErrUniqueness    = "uniqueness"
+)
+
+// Error is a SCIM error response.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("scim %s: %s", e.ScimType, e.Detail)
+}
+
+// MarshalJSON writes the error in the form of RFC 7644 section 3.12, which
+// has the status as a string.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{[]string{ErrorSchema}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+// badRequest returns a 400 error of the given type.
+func badRequest(scimType, format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Bool is a boolean that also accepts the strings "true" and "false" in any
+// case, which some clients send in PATCH values.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var v bool
+		if err := json.Unmarshal(data, &v); err != nil {
+			return badRequest(ErrInvalidValue, "%s is not a boolean", data)
+		}
+		*b = Bool(v)
+		return nil
+	}
+	switch strings.ToLower(s) {
+	case "true":
+		*b = true
+	case "false":
+		*b = false
+	default:
+		return badRequest(ErrInvalidValue, "%q is not a boolean", s)
+	}
+	return nil
+}
+
+// Meta is the metadata of a resource. Location is relative to the SCIM base
+// URL until the handler makes it absolute.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// Resource is a User or a Group.
+type Resource interface {
+	ResourceMeta() *Meta
+}
+
+// MultiValue is one value of a multi-valued attribute such as emails or
+// members.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary Bool   `json:"primary,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+type EnterpriseUser struct {
+	Department string `json:"department,omitempty"`
+}
+
+type User struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	UserName    string          `json:"userName"`
+	Name        *Name           `json:"name,omitempty"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Active      *Bool           `json:"active,omitempty"`
+	Password    string          `json:"password,omitempty"` // write-only
+	Emails      []MultiValue    `json:"emails,omitempty"`
+	Roles       []MultiValue    `json:"roles,omitempty"`
+	Groups      []MultiValue    `json:"groups,omitempty"` // read-only
+	Enterprise  *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        *Meta           `json:"meta,omitempty"`
+}
+
+func (u *User) ResourceMeta() *Meta { return u.Meta }
+
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+func (g *Group) ResourceMeta() *Meta { return g.Meta }
+
+// ListResponse is a page of query results. StartIndex is 1-based.
+type ListResponse struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int        `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []Resource `json:"Resources"`
+}
+
+// PatchOp is one operation of a PATCH request.
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// ToMap returns v in its JSON form, for filtering and patching.
+func ToMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FromMap decodes a resource in its JSON form into v.
+func FromMap(m map[string]any, v any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		return badRequest(ErrInvalidValue, "%v", err)
+	}
+	return nil
+}
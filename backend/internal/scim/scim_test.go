@@ -0,0 +1,265 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"docmv/internal/scim"
+)
+
+func resource(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+const alice = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223",
+	"userName": "Alice@Example.com",
+	"name": {"givenName": "Alice", "familyName": "Liddell"},
+	"active": true,
+	"emails": [
+		{"value": "alice@example.com", "type": "work", "primary": true},
+		{"value": "alice@home.example", "type": "home"}
+	],
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "R&D"},
+	"meta": {"created": "2024-05-01T10:00:00Z"}
+}`
+
+func TestFilter(t *testing.T) {
+	r := resource(t, alice)
+	for _, tc := range []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "alice@example.com"`, true},
+		{`USERNAME Eq "ALICE@EXAMPLE.COM"`, true},
+		{`userName eq "bob@example.com"`, false},
+		{`userName ne "bob@example.com"`, true},
+		{`userName sw "alice"`, true},
+		{`userName ew "@example.com"`, true},
+		{`userName co "ce@ex"`, true},
+		{`name.givenName eq "Alice"`, true},
+		{`name.familyName pr`, true},
+		{`name.formatted pr`, false},
+		{`externalId pr`, false},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`emails eq "alice@home.example"`, true},
+		{`emails.type eq "home"`, true},
+		{`emails[type eq "work" and value ew "example.com"]`, true},
+		{`emails[type eq "home" and primary eq true]`, false},
+		{`meta.created gt "2024-01-01T00:00:00Z"`, true},
+		{`meta.created lt "2024-01-01T00:00:00Z"`, false},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "r&d"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "alice"`, true},
+		{`userName eq "bob" or active eq true`, true},
+		{`userName eq "bob" or active eq true and name.givenName eq "Bob"`, false},
+		{`(userName eq "bob" or active eq true) and not (name.givenName eq "Bob")`, true},
+		{`not(active eq true)`, false},
+		{`title eq null`, true},
+		{`userName eq "quote \" and \\ escaped"`, false},
+	} {
+		f, err := scim.ParseFilter(tc.filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%s): %v", tc.filter, err)
+			continue
+		}
+		if got := f.Match(r); got != tc.want {
+			t.Errorf("%s matched %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName like "a"`,
+		`userName eq "a" and`,
+		`(userName eq "a"`,
+		`userName eq "a")`,
+		`emails[type eq "work"`,
+		`userName eq "unterminated`,
+		`userName eq alice`,
+		`not userName eq "a"`,
+		`user name eq "a"`,
+	} {
+		_, err := scim.ParseFilter(filter)
+		var e *scim.Error
+		if !errors.As(err, &e) || e.Status != 400 || e.ScimType != scim.ErrInvalidFilter {
+			t.Errorf("ParseFilter(%s) = %v, want an invalidFilter error", filter, err)
+		}
+	}
+}
+
+func patch(t *testing.T, r map[string]any, ops string) error {
+	t.Helper()
+	var req scim.PatchRequest
+	if err := json.Unmarshal([]byte(ops), &req); err != nil {
+		t.Fatal(err)
+	}
+	return scim.Patch(r, req.Operations)
+}
+
+func TestPatch(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ops  string
+		want string
+	}{
+		{
+			"replace attribute, operation name in any case",
+			`{"Operations": [{"op": "Replace", "path": "active", "value": false}]}`,
+			`{"active": false, "name": {"givenName": "Alice"}}`,
+		},
+		{
+			"replace without path, merging complex attributes",
+			`{"Operations": [{"op": "replace", "value": {"ACTIVE": false, "name": {"familyName": "L"}}}]}`,
+			`{"active": false, "name": {"givenName": "Alice", "familyName": "L"}}`,
+		},
+		{
+			"add sub-attribute",
+			`{"Operations": [{"op": "add", "path": "name.formatted", "value": "Alice L"}]}`,
+			`{"active": true, "name": {"givenName": "Alice", "formatted": "Alice L"}}`,
+		},
+		{
+			"remove attribute",
+			`{"Operations": [{"op": "remove", "path": "name"}]}`,
+			`{"active": true}`,
+		},
+		{
+			"extension attribute by urn",
+			`{"Operations": [{"op": "replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "Ops"}]}`,
+			`{"active": true, "name": {"givenName": "Alice"}, "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Ops"}}`,
+		},
+		{
+			"extension object without path",
+			`{"Operations": [{"op": "add", "value": {"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Ops"}}}]}`,
+			`{"active": true, "name": {"givenName": "Alice"}, "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Ops"}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := resource(t, `{"active": true, "name": {"givenName": "Alice"}}`)
+			if err := patch(t, r, tc.ops); err != nil {
+				t.Fatal(err)
+			}
+			if want := resource(t, tc.want); !reflect.DeepEqual(r, want) {
+				t.Fatalf("got %v, want %v", r, want)
+			}
+		})
+	}
+}
+
+func TestPatchMultiValued(t *testing.T) {
+	group := func() map[string]any {
+		return resource(t, `{"displayName": "eng", "members": [{"value": "a"}, {"value": "b"}]}`)
+	}
+	members := func(r map[string]any) []string {
+		var out []string
+		for _, m := range r["members"].([]any) {
+			out = append(out, m.(map[string]any)["value"].(string))
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name string
+		ops  string
+		want []string
+	}{
+		{"add appends new values", `{"Operations": [{"op": "add", "path": "members", "value": [{"value": "b"}, {"value": "c"}]}]}`, []string{"a", "b", "c"}},
+		{"remove by filter", `{"Operations": [{"op": "remove", "path": "members[value eq \"a\"]"}]}`, []string{"b"}},
+		{"remove by value", `{"Operations": [{"op": "remove", "path": "members", "value": [{"value": "b"}, {"value": "x"}]}]}`, []string{"a"}},
+		{"replace all", `{"Operations": [{"op": "replace", "path": "members", "value": [{"value": "z"}]}]}`, []string{"z"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := group()
+			if err := patch(t, r, tc.ops); err != nil {
+				t.Fatal(err)
+			}
+			if got := members(r); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("members %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	r := group()
+	if err := patch(t, r, `{"Operations": [{"op": "remove", "path": "members"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r["members"]; ok {
+		t.Fatalf("members not removed: %v", r)
+	}
+}
+
+func TestPatchFilteredPath(t *testing.T) {
+	r := resource(t, alice)
+	err := patch(t, r, `{"Operations": [
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "a.l@example.com"},
+		{"op": "add", "path": "emails[type eq \"other\"].value", "value": "al@other.example"},
+		{"op": "remove", "path": "emails[type eq \"home\"]"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"value": "a.l@example.com", "type": "work", "primary": true},
+		map[string]any{"value": "al@other.example", "type": "other"},
+	}
+	if !reflect.DeepEqual(r["emails"], want) {
+		t.Fatalf("emails %v, want %v", r["emails"], want)
+	}
+}
+
+func TestPatchInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		ops      string
+		scimType string
+	}{
+		{`{"Operations": [{"op": "move", "path": "active", "value": true}]}`, scim.ErrInvalidSyntax},
+		{`{"Operations": [{"op": "remove"}]}`, scim.ErrNoTarget},
+		{`{"Operations": [{"op": "replace", "value": "x"}]}`, scim.ErrInvalidValue},
+		{`{"Operations": [{"op": "replace", "path": "active"}]}`, scim.ErrInvalidValue},
+		{`{"Operations": [{"op": "replace", "path": "emails[type eq", "value": "x"}]}`, scim.ErrInvalidPath},
+		{`{"Operations": [{"op": "replace", "path": "emails[type eq \"work\"]x", "value": "x"}]}`, scim.ErrInvalidPath},
+		{`{"Operations": [{"op": "replace", "path": "bad attr", "value": "x"}]}`, scim.ErrInvalidPath},
+		{`{"Operations": [{"op": "replace", "path": "emails[type ne \"work\"].value", "value": "x"}]}`, scim.ErrNoTarget},
+	} {
+		r := resource(t, `{"active": true}`)
+		err := patch(t, r, tc.ops)
+		var e *scim.Error
+		if !errors.As(err, &e) || e.ScimType != tc.scimType {
+			t.Errorf("%s: got %v, want %s", tc.ops, err, tc.scimType)
+		}
+	}
+}
+
+func TestBoolAndRoundTrip(t *testing.T) {
+	m := resource(t, `{"userName": "a@example.com", "active": "False", "emails": [{"value": "a@example.com", "primary": "True"}]}`)
+	var u scim.User
+	if err := scim.FromMap(m, &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Active == nil || bool(*u.Active) || !bool(u.Emails[0].Primary) {
+		t.Fatalf("got %+v", u)
+	}
+	m["active"] = "maybe"
+	if err := scim.FromMap(m, &u); err == nil {
+		t.Fatal("active \"maybe\" accepted")
+	}
+
+	data, err := json.Marshal(&scim.Error{Status: 409, ScimType: scim.ErrUniqueness, Detail: "taken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"409","scimType":"uniqueness","detail":"taken"}`; string(data) != want {
+		t.Fatalf("error JSON %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAPITokenDays = 90
+	maxAPITokenDays     = 365
+	// lastUsedGranularity bounds how often verifying a token writes its
+	// last_used_at, so a busy CI job does not turn every request into a write.
+	lastUsedGranularity = time.Minute
+)
+
+// APITokenService manages personal access tokens: long-lived opaque tokens a
+// user creates for scripts and CI. A token acts as its owner, limited to the
+// scopes it was created with.
+type APITokenService struct {
+	tokenRepo repository.APITokenRepository
+	userRepo  repository.UserRepository
+	twoFactor *TwoFactorService
+}
+
+func NewAPITokenService(tokenRepo repository.APITokenRepository, userRepo repository.UserRepository, twoFactor *TwoFactorService) *APITokenService {
+	return &APITokenService{tokenRepo: tokenRepo, userRepo: userRepo, twoFactor: twoFactor}
+}
+
+type CreateAPITokenInput struct {
+	Name          string              `json:"name"`
+	Scopes        []domain.TokenScope `json:"scopes"`
+	ExpiresInDays int                 `json:"expires_in_days"` // 0 means the default of 90 days
+}
+
+// CreatedAPIToken carries the token itself, which is shown only once.
+type CreatedAPIToken struct {
+	domain.APIToken
+	Token string `json:"token"`
+}
+
+// Create issues a new token for the caller. Only ADMIN users may request the
+// admin scope.
+func (s *APITokenService) Create(ctx context.Context, userID uuid.UUID, role domain.Role, in CreateAPITokenInput) (*CreatedAPIToken, error) {
+	fields := map[string]string{}
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		fields["name"] = "required"
+	} else if utf8.RuneCountInString(name) > 100 {
+		fields["name"] = "too_long"
+	}
+
+	var scopes domain.TokenScopes
+	seen := map[domain.TokenScope]bool{}
+	for _, scope := range in.Scopes {
+		if !scope.Valid() {
+			fields["scopes"] = "invalid_enum"
+			break
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(in.Scopes) == 0 {
+		fields["scopes"] = "required"
+	}
+
+	days := in.ExpiresInDays
+	if days == 0 {
+		days = defaultAPITokenDays
+	}
+	if days < 1 || days > maxAPITokenDays {
+		fields["expires_in_days"] = "out_of_range"
+	}
+	if len(fields) > 0 {
+		return nil, domain.NewValidationError(fields)
+	}
+	if seen[domain.ScopeAdmin] && role != domain.RoleAdmin {
+		return nil, fmt.Errorf("%w: only admins may create tokens with the admin scope", domain.ErrForbidden)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generating api token: %w", err)
+	}
+	token := domain.APITokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	at := domain.APIToken{
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   hashToken(token),
+		TokenPrefix: token[:len(domain.APITokenPrefix)+4],
+		Scopes:      scopes,
+		ExpiresAt:   time.Now().AddDate(0, 0, days),
+	}
+	if err := s.tokenRepo.Create(ctx, &at); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIToken{APIToken: at, Token: token}, nil
+}
+
+// List returns the caller's tokens that are not revoked.
+func (s *APITokenService) List(ctx context.Context, userID uuid.UUID) ([]domain.APIToken, error) {
+	return s.tokenRepo.ListByUser(ctx, userID)
+}
+
+// Revoke revokes one of the caller's tokens. Other users' tokens are
+// reported as not found.
+func (s *APITokenService) Revoke(ctx context.Context, userID, tokenID uuid.UUID) error {
+	return s.tokenRepo.Revoke(ctx, tokenID, userID, time.Now())
+}
+
+// VerifyAccessToken resolves a personal access token to its owner. Like
+// session tokens, the role is read from the user record on every request,
+// and tokens of users who must still enroll in two-factor authentication
+// are flagged with Principal.TwoFactorSetupRequired.
+func (s *APITokenService) VerifyAccessToken(ctx context.Context, token string) (*domain.Principal, error) {
+	at, err := s.tokenRepo.GetByHash(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid api token", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if at.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: api token revoked", domain.ErrUnauthorized)
+	}
+	if !now.Before(at.ExpiresAt) {
+		return nil, fmt.Errorf("%w: api token expired", domain.ErrUnauthorized)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, at.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid api token", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding user: %w", err)
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("%w: account deactivated", domain.ErrUnauthorized)
+	}
+
+	setup, err := s.twoFactor.setupRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if at.LastUsedAt == nil || now.Sub(*at.LastUsedAt) >= lastUsedGranularity {
+		// Failing to record the use must not fail the request.
+		if err := s.tokenRepo.TouchLastUsed(ctx, at.ID, now); err != nil {
+			log.Printf("[auth] recording use of api token %s: %v", at.ID, err)
+		}
+	}
+	return &domain.Principal{UserID: user.ID, Role: user.Role, Scopes: at.Scopes, TwoFactorSetupRequired: setup}, nil
+}
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+func TestAPITokenCreateValidation(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.user(t, "alice@example.com")
+	read := []domain.TokenScope{domain.ScopeRead}
+
+	tests := []struct {
+		name string
+		in   service.CreateAPITokenInput
+		want map[string]string
+	}{
+		{"missing name", service.CreateAPITokenInput{Name: "  ", Scopes: read}, map[string]string{"name": "required"}},
+		{"long name", service.CreateAPITokenInput{Name: strings.Repeat("x", 101), Scopes: read}, map[string]string{"name": "too_long"}},
+		{"no scopes", service.CreateAPITokenInput{Name: "ci"}, map[string]string{"scopes": "required"}},
+		{"unknown scope", service.CreateAPITokenInput{Name: "ci", Scopes: []domain.TokenScope{"delete"}}, map[string]string{"scopes": "invalid_enum"}},
+		{"expiry too long", service.CreateAPITokenInput{Name: "ci", Scopes: read, ExpiresInDays: 366}, map[string]string{"expires_in_days": "out_of_range"}},
+		{"negative expiry", service.CreateAPITokenInput{Name: "ci", Scopes: read, ExpiresInDays: -1}, map[string]string{"expires_in_days": "out_of_range"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.apiTokens.Create(e.ctx, alice, domain.RoleUser, tt.in)
+			wantFields(t, err, tt.want)
+		})
+	}
+
+	// The admin scope is for admins only.
+	in := service.CreateAPITokenInput{Name: "ops", Scopes: []domain.TokenScope{domain.ScopeAdmin}}
+	_, err := e.apiTokens.Create(e.ctx, alice, domain.RoleUser, in)
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.apiTokens.Create(e.ctx, alice, domain.RoleAdmin, in)
+	mustNoErr(t, err)
+}
+
+func TestAPITokenVerify(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.user(t, "alice@example.com")
+
+	created, err := e.apiTokens.Create(e.ctx, alice, domain.RoleUser, service.CreateAPITokenInput{
+		Name: "ci", Scopes: []domain.TokenScope{domain.ScopeRead, domain.ScopeRead},
+	})
+	mustNoErr(t, err)
+	if !strings.HasPrefix(created.Token, domain.APITokenPrefix) || !strings.HasPrefix(created.Token, created.TokenPrefix) {
+		t.Fatalf("token %q does not start with %q", created.Token, created.TokenPrefix)
+	}
+	if days := time.Until(created.ExpiresAt).Hours() / 24; days < 89.9 || days > 90 {
+		t.Fatalf("token expires in %.1f days, want 90", days)
+	}
+
+	p, err := e.apiTokens.VerifyAccessToken(e.ctx, created.Token)
+	mustNoErr(t, err)
+	if p.UserID != alice || p.Role != domain.RoleUser || len(p.Scopes) != 1 {
+		t.Fatalf("got principal %+v", p)
+	}
+	if !p.HasScope(domain.ScopeRead) || p.HasScope(domain.ScopeWrite) {
+		t.Fatalf("principal scopes %v, want [read]", p.Scopes)
+	}
+
+	list, err := e.apiTokens.List(e.ctx, alice)
+	mustNoErr(t, err)
+	if len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Fatalf("verifying did not record last use: %+v", list)
+	}
+
+	_, err = e.apiTokens.VerifyAccessToken(e.ctx, domain.APITokenPrefix+"unknown")
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestAPITokenRevoke(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.user(t, "alice@example.com")
+	bob := e.user(t, "bob@example.com")
+	created, err := e.apiTokens.Create(e.ctx, alice, domain.RoleUser, service.CreateAPITokenInput{
+		Name: "ci", Scopes: []domain.TokenScope{domain.ScopeWrite},
+	})
+	mustNoErr(t, err)
+
+	wantErr(t, e.apiTokens.Revoke(e.ctx, bob, created.ID), domain.ErrNotFound)
+	mustNoErr(t, e.apiTokens.Revoke(e.ctx, alice, created.ID))
+	_, err = e.apiTokens.VerifyAccessToken(e.ctx, created.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	list, err := e.apiTokens.List(e.ctx, alice)
+	mustNoErr(t, err)
+	if len(list) != 0 {
+		t.Fatalf("revoked token still listed")
+	}
+}
+
+func TestSessionPrincipalHasEveryScope(t *testing.T) {
+	e := newTestEnv(t)
+	_, err := e.auth.CreateUser(e.ctx, "alice@example.com", "secret1", "")
+	mustNoErr(t, err)
+	res, err := e.auth.Login(e.ctx, "alice@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	p, err := e.auth.VerifyAccessToken(e.ctx, res.Token)
+	mustNoErr(t, err)
+	for _, scope := range []domain.TokenScope{domain.ScopeRead, domain.ScopeWrite, domain.ScopeAdmin} {
+		if !p.HasScope(scope) {
+			t.Fatalf("session principal lacks %s", scope)
+		}
+	}
+}
@@ -2,10 +2,17 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"docmv/internal/domain"
 	"docmv/internal/repository"
@@ -15,54 +22,278 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// twoFactorChallengeTTL is how long the second step of a login may take.
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// formerDefaultAdminPassword was the seeded admin's password before the
+// seed started generating one. Accounts still using it are reported on
+// startup.
+const formerDefaultAdminPassword = "admin123"
+
 type AuthService struct {
-	userRepo  *repository.UserRepo
-	jwtSecret []byte
+	userRepo    repository.UserRepository
+	tokenRepo   repository.RefreshTokenRepository
+	historyRepo repository.PasswordHistoryRepository
+	guard       *LoginGuard
+	policy      *PasswordPolicy
+	twoFactor   *TwoFactorService
+	signer      *TokenSigner
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	// authenticators check login passwords, local accounts first.
+	authenticators []Authenticator
 }
 
-func NewAuthService(userRepo *repository.UserRepo, jwtSecret string) *AuthService {
+// NewAuthService returns the service. Logins whose password is not that of
+// a local account are checked with the given authenticators, in order.
+func NewAuthService(userRepo repository.UserRepository, tokenRepo repository.RefreshTokenRepository, historyRepo repository.PasswordHistoryRepository, guard *LoginGuard, policy *PasswordPolicy, twoFactor *TwoFactorService, signer *TokenSigner, accessTTL, refreshTTL time.Duration, authenticators ...Authenticator) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: []byte(jwtSecret),
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		historyRepo:    historyRepo,
+		guard:          guard,
+		policy:         policy,
+		twoFactor:      twoFactor,
+		signer:         signer,
+		accessTTL:      accessTTL,
+		refreshTTL:     refreshTTL,
+		authenticators: append([]Authenticator{passwordAuthenticator{userRepo}}, authenticators...),
 	}
 }
 
+// AuthResult is a fresh access/refresh token pair.
 type AuthResult struct {
-	Token string       `json:"token"`
-	User  *domain.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"` // access token lifetime in seconds
+	User         *domain.User `json:"user"`
+	// TwoFactorSetupRequired tells the client that the user must enroll in
+	// two-factor authentication before doing anything else.
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
+}
+
+// LoginResult is the outcome of the first login step: a token pair, or for
+// users with two-factor authentication a challenge to pass to CompleteLogin
+// along with a code.
+type LoginResult struct {
+	*AuthResult
+	TwoFactor *TwoFactorChallenge `json:"two_factor,omitempty"`
 }
 
-// Login authenticates a user and returns a JWT (with role claim).
-func (s *AuthService) Login(ctx context.Context, email, password string) (*AuthResult, error) {
+type TwoFactorChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"` // seconds
+}
+
+// Login authenticates a user and starts a new refresh token family. The
+// password is checked against the local account and then the configured
+// authenticators, such as a directory; email is whatever login those
+// accept. Failed attempts are counted against the account and clientIP; once either has
+// failed too often, attempts are refused with a *domain.RateLimitError until
+// the delay or lockout has passed.
+//
+// For users with two-factor authentication, the result is a short-lived
+// challenge instead, and the account's failures are only forgotten once
+// CompleteLogin accepts a code.
+func (s *AuthService) Login(ctx context.Context, email, password, clientIP string) (*LoginResult, error) {
 	if email == "" || password == "" {
 		return nil, fmt.Errorf("%w: email and password required", domain.ErrInvalidInput)
 	}
+	if err := s.guard.Check(ctx, email, clientIP); err != nil {
+		return nil, err
+	}
+
+	user, err := s.authenticate(ctx, email, password)
+	if err != nil {
+		// Forbidden comes after a correct password. Anything else counts,
+		// so an unreachable directory opens no way around the throttle.
+		if !errors.Is(err, domain.ErrForbidden) {
+			s.guard.Fail(ctx, email, clientIP)
+		}
+		return nil, err
+	}
+	// Checked after the password so the answer does not reveal whether an
+	// account exists.
+	if !user.Active {
+		return nil, fmt.Errorf("%w: account deactivated", domain.ErrForbidden)
+	}
+
+	if !user.TwoFactorEnabled {
+		s.guard.Succeed(ctx, email)
+	}
+	return s.startSession(ctx, user)
+}
+
+// CompleteLogin is the second login step: it accepts the challenge from
+// Login with a TOTP code or a recovery code and returns a token pair. Wrong
+// codes count as failed logins. A challenge stops working when it expires
+// or the user's sessions are revoked.
+func (s *AuthService) CompleteLogin(ctx context.Context, challenge, code, clientIP string) (*AuthResult, error) {
+	if challenge == "" || code == "" {
+		return nil, fmt.Errorf("%w: challenge_token and code required", domain.ErrInvalidInput)
+	}
+	claims, err := s.parseToken(ctx, challenge)
+	if err != nil || claims["typ"] != "2fa" {
+		return nil, fmt.Errorf("%w: invalid or expired challenge", domain.ErrUnauthorized)
+	}
+	user, err := s.claimedUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, fmt.Errorf("%w: invalid or expired challenge", domain.ErrUnauthorized)
+	}
+
+	if err := s.guard.Check(ctx, user.Email, clientIP); err != nil {
+		return nil, err
+	}
+	ok, err := s.twoFactor.verify(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.guard.Fail(ctx, user.Email, clientIP)
+		return nil, fmt.Errorf("%w: invalid code", domain.ErrUnauthorized)
+	}
+	s.guard.Succeed(ctx, user.Email)
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is consumed; presenting it again revokes every token of its family, since
+// one of the two holders must have stolen it.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*AuthResult, error) {
+	if refreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh_token required", domain.ErrInvalidInput)
+	}
+
+	rt, err := s.tokenRepo.GetByHash(ctx, hashToken(refreshToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid refresh token", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rt.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: refresh token revoked", domain.ErrUnauthorized)
+	}
+
+	now := time.Now()
+	if !now.Before(rt.ExpiresAt) {
+		return nil, fmt.Errorf("%w: refresh token expired", domain.ErrUnauthorized)
+	}
+	// MarkUsed also catches the race of two requests presenting the same
+	// token: only one of them consumes it.
+	ok, err := s.tokenRepo.MarkUsed(ctx, rt.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		log.Printf("[auth] refresh token reuse for user %s, revoking family %s", rt.UserID, rt.FamilyID)
+		if err := s.tokenRepo.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: refresh token already used", domain.ErrUnauthorized)
+	}
 
-	user, err := s.userRepo.GetByEmail(ctx, email)
+	user, err := s.userRepo.GetByID(ctx, rt.UserID)
 	if errors.Is(err, domain.ErrNotFound) {
-		return nil, fmt.Errorf("%w: invalid credentials", domain.ErrUnauthorized)
+		return nil, fmt.Errorf("%w: invalid refresh token", domain.ErrUnauthorized)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("finding user: %w", err)
 	}
+	if user.TokenVersion != rt.TokenVersion || !user.Active {
+		if err := s.tokenRepo.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: session ended, please log in again", domain.ErrUnauthorized)
+	}
+
+	return s.issueTokens(ctx, user, rt.FamilyID)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, fmt.Errorf("%w: invalid credentials", domain.ErrUnauthorized)
+// Logout revokes the refresh token family the given token belongs to. Unknown
+// tokens are ignored, so logging out twice succeeds. The access token stays
+// valid until it expires.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return fmt.Errorf("%w: refresh_token required", domain.ErrInvalidInput)
 	}
+	rt, err := s.tokenRepo.GetByHash(ctx, hashToken(refreshToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeFamily(ctx, rt.FamilyID, time.Now())
+}
 
-	token, err := s.generateToken(user)
+// VerifyAccessToken checks an access token's signature and expiry, that it
+// was issued at the user's current token version and that the user is still
+// active. The role is read from the user record, so role changes apply to
+// tokens already issued.
+//
+// Users whose role requires two-factor authentication and who have not
+// enrolled yet are flagged with Principal.TwoFactorSetupRequired.
+func (s *AuthService) VerifyAccessToken(ctx context.Context, tokenString string) (*domain.Principal, error) {
+	claims, err := s.parseToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	// Login challenges are signed with the same keys but are not sessions.
+	if _, ok := claims["typ"]; ok {
+		return nil, fmt.Errorf("%w: invalid or expired token", domain.ErrUnauthorized)
+	}
+	user, err := s.claimedUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	setup, err := s.twoFactor.setupRequired(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	return &domain.Principal{UserID: user.ID, Role: user.Role, TwoFactorSetupRequired: setup}, nil
+}
+
+// JWKS returns the public keys other services can verify access tokens with.
+func (s *AuthService) JWKS() JWKSet {
+	return s.signer.JWKS()
+}
+
+// RunPurgeJob deletes expired refresh tokens and forgotten login failures
+// once immediately and then every interval until ctx is cancelled.
+func (s *AuthService) RunPurgeJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.tokenRepo.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			log.Printf("[auth] purging expired refresh tokens failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[auth] purged %d expired refresh tokens", n)
+		}
+		if _, err := s.guard.DeleteStale(ctx); err != nil {
+			log.Printf("[auth] purging stale login failures failed: %v", err)
+		}
 
-	return &AuthResult{Token: token, User: user}, nil
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // SeedAdmin ensures the default admin account exists on startup.
-// If the email already exists, it is a no-op.
+// If the email already exists, it is a no-op. Without a password, a random
+// one is generated and logged once; a given password must satisfy the
+// password policy.
 func (s *AuthService) SeedAdmin(ctx context.Context, email, password string) error {
-	if email == "" || password == "" {
-		return fmt.Errorf("ADMIN_EMAIL and ADMIN_PASSWORD must be set")
+	if email == "" {
+		return fmt.Errorf("ADMIN_EMAIL must be set")
 	}
 
 	existing, err := s.userRepo.GetByEmail(ctx, email)
@@ -71,9 +302,21 @@ func (s *AuthService) SeedAdmin(ctx context.Context, email, password string) err
 	}
 	if existing != nil {
 		log.Printf("[seed] admin account %s already exists, skipping", email)
+		if bcrypt.CompareHashAndPassword([]byte(existing.PasswordHash), []byte(formerDefaultAdminPassword)) == nil {
+			log.Printf("[seed] WARNING: admin account %s still uses the former default password %q; change it now", email, formerDefaultAdminPassword)
+		}
 		return nil
 	}
 
+	generated := password == ""
+	if generated {
+		if password, err = s.policy.generate(); err != nil {
+			return err
+		}
+	} else if code := s.policy.check(password); code != "" {
+		return fmt.Errorf("ADMIN_PASSWORD does not satisfy the password policy: %s", code)
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("hashing admin password: %w", err)
@@ -87,10 +330,99 @@ func (s *AuthService) SeedAdmin(ctx context.Context, email, password string) err
 	if err := s.userRepo.Create(ctx, admin); err != nil {
 		return fmt.Errorf("creating admin user: %w", err)
 	}
-	log.Printf("[seed] admin account %s created successfully", email)
+	s.rememberPassword(ctx, admin.ID, admin.PasswordHash)
+	if generated {
+		log.Printf("[seed] admin account %s created with generated password %s (shown only once; change it after logging in)", email, password)
+	} else {
+		log.Printf("[seed] admin account %s created successfully", email)
+	}
 	return nil
 }
 
+// ---------- Self-service ----------
+
+// UpdateProfileInput replaces the caller's profile. An empty locale keeps
+// the current one.
+type UpdateProfileInput struct {
+	DisplayName string `json:"display_name"`
+	Department  string `json:"department"`
+	Locale      string `json:"locale"`
+}
+
+// GetProfile returns the caller's own account.
+func (s *AuthService) GetProfile(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// UpdateProfile changes the caller's display name, department and locale.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, in UpdateProfileInput) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	displayName := strings.TrimSpace(in.DisplayName)
+	if utf8.RuneCountInString(displayName) > 100 {
+		fields["display_name"] = "too_long"
+	}
+	department := strings.TrimSpace(in.Department)
+	if utf8.RuneCountInString(department) > 100 {
+		fields["department"] = "too_long"
+	}
+	locale := user.Locale
+	if in.Locale != "" {
+		locale = domain.Locale(in.Locale)
+		if !locale.Valid() {
+			fields["locale"] = "invalid_enum"
+		}
+	}
+	if len(fields) > 0 {
+		return nil, domain.NewValidationError(fields)
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, userID, displayName, department, locale); err != nil {
+		return nil, err
+	}
+	user.DisplayName, user.Department, user.Locale = displayName, department, locale
+	return user, nil
+}
+
+// ChangePassword sets a new password for the caller after checking the
+// current one. Every session of the user ends, including the one making the
+// request, which gets a fresh token pair in return.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, current, next string) (*AuthResult, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// A wrong current password is a validation error rather than 401, which
+	// clients would take for an expired session.
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return nil, domain.NewValidationError(map[string]string{"current_password": "incorrect"})
+	}
+	if next == current {
+		return nil, domain.NewValidationError(map[string]string{"new_password": "same_as_current"})
+	}
+	code, err := s.checkNewPassword(ctx, user, next)
+	if err != nil {
+		return nil, err
+	}
+	if code != "" {
+		return nil, domain.NewValidationError(map[string]string{"new_password": code})
+	}
+
+	if err := s.setPassword(ctx, userID, next); err != nil {
+		return nil, err
+	}
+
+	user, err = s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
 // ---------- Admin operations ----------
 
 // CreateUser creates a new user account (admin-only).
@@ -98,19 +430,17 @@ func (s *AuthService) CreateUser(ctx context.Context, email, password, role stri
 	if email == "" || password == "" {
 		return nil, fmt.Errorf("%w: email and password required", domain.ErrInvalidInput)
 	}
-	if len(password) < 6 {
-		return nil, fmt.Errorf("%w: password must be at least 6 characters", domain.ErrInvalidInput)
+	if code := s.policy.check(password); code != "" {
+		return nil, domain.NewValidationError(map[string]string{"password": code})
 	}
 
 	// Validate role
 	userRole := domain.RoleUser
 	if role != "" {
-		switch domain.Role(role) {
-		case domain.RoleAdmin, domain.RoleUser:
-			userRole = domain.Role(role)
-		default:
+		if !domain.Role(role).Valid() {
 			return nil, fmt.Errorf("%w: role must be ADMIN or USER", domain.ErrInvalidInput)
 		}
+		userRole = domain.Role(role)
 	}
 
 	// Check if email already taken
@@ -135,6 +465,7 @@ func (s *AuthService) CreateUser(ctx context.Context, email, password, role stri
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
+	s.rememberPassword(ctx, user.ID, user.PasswordHash)
 
 	return user, nil
 }
@@ -144,34 +475,291 @@ func (s *AuthService) ListUsers(ctx context.Context) ([]domain.User, error) {
 	return s.userRepo.List(ctx)
 }
 
-// ResetPassword changes a user's password (admin-only).
+// ResetPassword changes a user's password (admin-only) and lifts a lockout
+// of their account. Tokens issued before the reset stop working: access
+// tokens on their next request, refresh tokens on their next refresh.
 func (s *AuthService) ResetPassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
-	if newPassword == "" || len(newPassword) < 6 {
-		return fmt.Errorf("%w: password must be at least 6 characters", domain.ErrInvalidInput)
+	if newPassword == "" {
+		return fmt.Errorf("%w: password required", domain.ErrInvalidInput)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	code, err := s.checkNewPassword(ctx, user, newPassword)
+	if err != nil {
+		return err
+	}
+	if code != "" {
+		return domain.NewValidationError(map[string]string{"password": code})
+	}
+
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return err
 	}
+	s.guard.Succeed(ctx, user.Email)
+	return nil
+}
+
+// ListLockouts returns the accounts and client addresses with recent failed
+// logins (admin-only).
+func (s *AuthService) ListLockouts(ctx context.Context) ([]LoginLockout, error) {
+	return s.guard.List(ctx)
+}
+
+// ClearLockout forgets the failed logins recorded under key, as listed by
+// ListLockouts (admin-only).
+func (s *AuthService) ClearLockout(ctx context.Context, key string) error {
+	if key == "" {
+		return domain.NewValidationError(map[string]string{"key": "required"})
+	}
+	return s.guard.Clear(ctx, key)
+}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+// PasswordPolicy returns the rules new passwords must follow.
+func (s *AuthService) PasswordPolicy() *PasswordPolicy {
+	return s.policy
+}
+
+// UpdateRole changes another user's role (admin-only). Admins cannot change
+// their own role, so there is always at least one admin left.
+func (s *AuthService) UpdateRole(ctx context.Context, actorID, userID uuid.UUID, role string) (*domain.User, error) {
+	r := domain.Role(role)
+	if !r.Valid() {
+		return nil, domain.NewValidationError(map[string]string{"role": "invalid_enum"})
+	}
+	user, err := s.otherUser(ctx, actorID, userID, "change their own role")
 	if err != nil {
-		return fmt.Errorf("hashing password: %w", err)
+		return nil, err
+	}
+	if err := s.userRepo.UpdateRole(ctx, userID, r); err != nil {
+		return nil, err
 	}
+	user.Role = r
+	return user, nil
+}
+
+// SetActive deactivates or reactivates another user (admin-only). A
+// deactivated user cannot log in, and tokens already issued to them are
+// rejected from the next request on.
+func (s *AuthService) SetActive(ctx context.Context, actorID, userID uuid.UUID, active bool) (*domain.User, error) {
+	user, err := s.otherUser(ctx, actorID, userID, "deactivate themselves")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetActive(ctx, userID, active); err != nil {
+		return nil, err
+	}
+	user.Active = active
+	return user, nil
+}
 
-	return s.userRepo.UpdatePassword(ctx, userID, string(hash))
+// DeleteUser removes another user's account (admin-only). Users who own
+// documents or flows or appear in their history cannot be deleted; transfer
+// their ownership first or deactivate them instead.
+func (s *AuthService) DeleteUser(ctx context.Context, actorID, userID uuid.UUID) error {
+	if _, err := s.otherUser(ctx, actorID, userID, "delete themselves"); err != nil {
+		return err
+	}
+	return s.userRepo.Delete(ctx, userID)
 }
 
 // ---------- Internal ----------
 
+// checkNewPassword applies the password policy to a password user is about
+// to get, including that it is none of their last policy.History passwords.
+// It returns the validation code, or "" when the password is acceptable.
+func (s *AuthService) checkNewPassword(ctx context.Context, user *domain.User, password string) (string, error) {
+	if code := s.policy.check(password); code != "" {
+		return code, nil
+	}
+	if s.policy.History == 0 {
+		return "", nil
+	}
+	hashes, err := s.historyRepo.ListRecent(ctx, user.ID, s.policy.History)
+	if err != nil {
+		return "", err
+	}
+	// The current password is normally the newest entry, but accounts
+	// created before the history was kept have none.
+	if !slices.Contains(hashes, user.PasswordHash) {
+		hashes = append(hashes, user.PasswordHash)
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return "reused", nil
+		}
+	}
+	return "", nil
+}
+
+// setPassword stores a new password for the user and records it in their
+// history.
+func (s *AuthService) setPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+	s.rememberPassword(ctx, userID, string(hash))
+	return nil
+}
+
+// rememberPassword adds a hash to the user's password history. The password
+// is already set, so a failure is logged rather than returned.
+func (s *AuthService) rememberPassword(ctx context.Context, userID uuid.UUID, hash string) {
+	if s.policy.History == 0 {
+		return
+	}
+	if err := s.historyRepo.Add(ctx, userID, hash, s.policy.History); err != nil {
+		log.Printf("[auth] recording password history of user %s: %v", userID, err)
+	}
+}
+
+// otherUser loads the target of an admin operation that admins may not apply
+// to themselves.
+func (s *AuthService) otherUser(ctx context.Context, actorID, userID uuid.UUID, what string) (*domain.User, error) {
+	if actorID == userID {
+		return nil, fmt.Errorf("%w: admins cannot %s", domain.ErrForbidden, what)
+	}
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// parseToken checks a token's signature and expiry and returns its claims.
+func (s *AuthService) parseToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.signer.Parse(ctx, tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid or expired token", domain.ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+// claimedUser loads the user a token was issued to, checking that it was
+// issued at their current token version and that they are still active.
+func (s *AuthService) claimedUser(ctx context.Context, claims jwt.MapClaims) (*domain.User, error) {
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user id in token", domain.ErrUnauthorized)
+	}
+	version, _ := claims["ver"].(float64)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid or expired token", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding user: %w", err)
+	}
+	if int(version) != user.TokenVersion {
+		return nil, fmt.Errorf("%w: token revoked", domain.ErrUnauthorized)
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("%w: account deactivated", domain.ErrUnauthorized)
+	}
+	return user, nil
+}
+
+// startSession finishes a login whose first factor passed: users with
+// two-factor authentication get a challenge for CompleteLogin, everyone else
+// a token pair in a new family.
+func (s *AuthService) startSession(ctx context.Context, user *domain.User) (*LoginResult, error) {
+	if user.TwoFactorEnabled {
+		challenge, err := s.generateChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{TwoFactor: &TwoFactorChallenge{
+			ChallengeToken: challenge,
+			ExpiresIn:      int(twoFactorChallengeTTL / time.Second),
+		}}, nil
+	}
+	result, err := s.issueTokens(ctx, user, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{AuthResult: result}, nil
+}
+
+// issueTokens signs an access token and stores a new refresh token in family.
+func (s *AuthService) issueTokens(ctx context.Context, user *domain.User, family uuid.UUID) (*AuthResult, error) {
+	access, err := s.generateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generating refresh token: %w", err)
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+	rt := &domain.RefreshToken{
+		UserID:       user.ID,
+		FamilyID:     family,
+		TokenHash:    hashToken(refresh),
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    time.Now().Add(s.refreshTTL),
+	}
+	if err := s.tokenRepo.Create(ctx, rt); err != nil {
+		return nil, err
+	}
+	setup, err := s.twoFactor.setupRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResult{
+		Token:                  access,
+		RefreshToken:           refresh,
+		ExpiresIn:              int(s.accessTTL / time.Second),
+		User:                   user,
+		TwoFactorSetupRequired: setup,
+	}, nil
+}
+
 func (s *AuthService) generateToken(user *domain.User) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub":   user.ID.String(),
 		"email": user.Email,
 		"role":  string(user.Role),
-		"exp":   time.Now().Add(72 * time.Hour).Unix(),
-		"iat":   time.Now().Unix(),
+		"ver":   user.TokenVersion,
+		"exp":   now.Add(s.accessTTL).Unix(),
+		"iat":   now.Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(s.jwtSecret)
+	signed, err := s.signer.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
 	}
 	return signed, nil
 }
+
+// generateChallenge signs the token that carries a login from the password
+// step to the code step. Its typ claim keeps it from passing as an access
+// token.
+func (s *AuthService) generateChallenge(user *domain.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": user.ID.String(),
+		"typ": "2fa",
+		"ver": user.TokenVersion,
+		"exp": now.Add(twoFactorChallengeTTL).Unix(),
+		"iat": now.Unix(),
+	}
+	signed, err := s.signer.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("signing challenge: %w", err)
+	}
+	return signed, nil
+}
+
+// hashToken returns the hex SHA-256 under which refresh tokens, personal
+// access tokens and recovery codes are stored. Tokens carry 256 random bits
+// and recovery codes 64, only ever guessed online, so an unsalted fast hash
+// suffices.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,523 @@
+package service_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestAuthCreateUserValidation(t *testing.T) {
+	e := newTestEnv(t)
+	_, err := e.auth.CreateUser(e.ctx, "taken@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	tests := []struct {
+		name, email, password, role string
+		want                        error
+	}{
+		{"missing email", "", "secret1", "", domain.ErrInvalidInput},
+		{"short password", "a@example.com", "12345", "", domain.ErrInvalidInput},
+		{"unknown role", "a@example.com", "secret1", "ROOT", domain.ErrInvalidInput},
+		{"duplicate email", "taken@example.com", "secret1", "", domain.ErrAlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.auth.CreateUser(e.ctx, tt.email, tt.password, tt.role)
+			wantErr(t, err, tt.want)
+		})
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "admin@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+
+	_, err = e.auth.Login(e.ctx, "admin@example.com", "wrong-password", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Login(e.ctx, "nobody@example.com", "secret1", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Login(e.ctx, "", "", "")
+	wantErr(t, err, domain.ErrInvalidInput)
+
+	res, err := e.auth.Login(e.ctx, "admin@example.com", "secret1", "")
+	mustNoErr(t, err)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(res.Token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	})
+	mustNoErr(t, err)
+	if claims["sub"] != user.ID.String() || claims["role"] != string(domain.RoleAdmin) || claims["ver"] != float64(1) {
+		t.Fatalf("got claims %v", claims)
+	}
+	if res.RefreshToken == "" || res.ExpiresIn != int((15*time.Minute)/time.Second) {
+		t.Fatalf("got refresh token %q expires_in %d", res.RefreshToken, res.ExpiresIn)
+	}
+}
+
+func TestAuthVerifyAccessToken(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	res, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	principal, err := e.auth.VerifyAccessToken(e.ctx, res.Token)
+	mustNoErr(t, err)
+	if principal.UserID != user.ID || principal.Role != domain.RoleUser {
+		t.Fatalf("got principal %+v", principal)
+	}
+
+	sign := func(secret string, claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		mustNoErr(t, err)
+		return signed
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": user.ID.String(), "ver": 1, "exp": time.Now().Add(time.Minute).Unix()}
+	}
+	expired, noVersion := valid(), valid()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	delete(noVersion, "ver")
+
+	tests := []struct {
+		name, token string
+	}{
+		{"garbage", "not-a-jwt"},
+		{"wrong secret", sign("other-secret", valid())},
+		{"expired", sign(testJWTSecret, expired)},
+		{"issued before token versions", sign(testJWTSecret, noVersion)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.auth.VerifyAccessToken(e.ctx, tt.token)
+			wantErr(t, err, domain.ErrUnauthorized)
+		})
+	}
+}
+
+func TestAuthRefreshRotates(t *testing.T) {
+	e := newTestEnv(t)
+	_, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	first, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	second, err := e.auth.Refresh(e.ctx, first.RefreshToken)
+	mustNoErr(t, err)
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh returned the same refresh token")
+	}
+	_, err = e.auth.VerifyAccessToken(e.ctx, second.Token)
+	mustNoErr(t, err)
+	third, err := e.auth.Refresh(e.ctx, second.RefreshToken)
+	mustNoErr(t, err)
+
+	// Replaying a consumed token revokes the whole family, including the
+	// newest token, which may be in an attacker's hands.
+	_, err = e.auth.Refresh(e.ctx, first.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, third.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	_, err = e.auth.Refresh(e.ctx, "unknown")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, "")
+	wantErr(t, err, domain.ErrInvalidInput)
+}
+
+func TestAuthRefreshExpired(t *testing.T) {
+	e := newTestEnv(t)
+	_, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	auth := service.NewAuthService(e.users, e.tokens, e.history, service.NewLoginGuard(e.throttles, testLockout),
+		testPasswordPolicy(), e.twoFactor, e.signer, time.Minute, -time.Minute)
+	res, err := auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = auth.Refresh(e.ctx, res.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestAuthLogout(t *testing.T) {
+	e := newTestEnv(t)
+	_, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	laptop, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	phone, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	rotated, err := e.auth.Refresh(e.ctx, laptop.RefreshToken)
+	mustNoErr(t, err)
+
+	// Logging out with any token of the session ends the session only.
+	mustNoErr(t, e.auth.Logout(e.ctx, laptop.RefreshToken))
+	_, err = e.auth.Refresh(e.ctx, rotated.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, phone.RefreshToken)
+	mustNoErr(t, err)
+
+	mustNoErr(t, e.auth.Logout(e.ctx, laptop.RefreshToken))
+	mustNoErr(t, e.auth.Logout(e.ctx, "unknown"))
+	wantErr(t, e.auth.Logout(e.ctx, ""), domain.ErrInvalidInput)
+}
+
+func TestAuthResetPassword(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	wantErr(t, e.auth.ResetPassword(e.ctx, user.ID, "short"), domain.ErrInvalidInput)
+	mustNoErr(t, e.auth.ResetPassword(e.ctx, user.ID, "secret2"))
+
+	_, err = e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Login(e.ctx, "u@example.com", "secret2", "")
+	mustNoErr(t, err)
+}
+
+func TestAuthResetPasswordRevokesTokens(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	before, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	mustNoErr(t, e.auth.ResetPassword(e.ctx, user.ID, "secret2"))
+
+	_, err = e.auth.VerifyAccessToken(e.ctx, before.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, before.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	after, err := e.auth.Login(e.ctx, "u@example.com", "secret2", "")
+	mustNoErr(t, err)
+	_, err = e.auth.VerifyAccessToken(e.ctx, after.Token)
+	mustNoErr(t, err)
+}
+
+func TestAuthUpdateProfile(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = e.auth.UpdateProfile(e.ctx, user.ID, service.UpdateProfileInput{
+		DisplayName: strings.Repeat("x", 101), Locale: "fr-FR",
+	})
+	wantFields(t, err, map[string]string{"display_name": "too_long", "locale": "invalid_enum"})
+
+	updated, err := e.auth.UpdateProfile(e.ctx, user.ID, service.UpdateProfileInput{
+		DisplayName: "  Alice  ", Department: "Ops", Locale: "en-US",
+	})
+	mustNoErr(t, err)
+	if updated.DisplayName != "Alice" || updated.Department != "Ops" || updated.Locale != domain.LocaleEnUS {
+		t.Fatalf("got profile %+v", updated)
+	}
+
+	// An empty locale keeps the current one.
+	_, err = e.auth.UpdateProfile(e.ctx, user.ID, service.UpdateProfileInput{DisplayName: "Alice"})
+	mustNoErr(t, err)
+	got, err := e.auth.GetProfile(e.ctx, user.ID)
+	mustNoErr(t, err)
+	if got.Locale != domain.LocaleEnUS || got.Department != "" {
+		t.Fatalf("got locale %q department %q, want en-US and cleared department", got.Locale, got.Department)
+	}
+}
+
+func TestAuthChangePassword(t *testing.T) {
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	other, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = e.auth.ChangePassword(e.ctx, user.ID, "wrong", "secret2")
+	wantFields(t, err, map[string]string{"current_password": "incorrect"})
+	_, err = e.auth.ChangePassword(e.ctx, user.ID, "secret1", "short")
+	wantFields(t, err, map[string]string{"new_password": "too_short"})
+	_, err = e.auth.ChangePassword(e.ctx, user.ID, "secret1", "secret1")
+	wantFields(t, err, map[string]string{"new_password": "same_as_current"})
+
+	res, err := e.auth.ChangePassword(e.ctx, user.ID, "secret1", "secret2")
+	mustNoErr(t, err)
+
+	// The caller keeps working with the returned pair; other sessions end.
+	_, err = e.auth.VerifyAccessToken(e.ctx, res.Token)
+	mustNoErr(t, err)
+	_, err = e.auth.Refresh(e.ctx, res.RefreshToken)
+	mustNoErr(t, err)
+	_, err = e.auth.VerifyAccessToken(e.ctx, other.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, other.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	_, err = e.auth.Login(e.ctx, "u@example.com", "secret2", "")
+	mustNoErr(t, err)
+}
+
+func TestAuthDeactivate(t *testing.T) {
+	e := newTestEnv(t)
+	admin, err := e.auth.CreateUser(e.ctx, "admin@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	session, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	pat, err := e.apiTokens.Create(e.ctx, user.ID, domain.RoleUser, service.CreateAPITokenInput{
+		Name: "ci", Scopes: []domain.TokenScope{domain.ScopeRead},
+	})
+	mustNoErr(t, err)
+
+	_, err = e.auth.SetActive(e.ctx, admin.ID, admin.ID, false)
+	wantErr(t, err, domain.ErrForbidden)
+
+	got, err := e.auth.SetActive(e.ctx, admin.ID, user.ID, false)
+	mustNoErr(t, err)
+	if got.Active {
+		t.Fatalf("SetActive(false) returned an active user")
+	}
+	_, err = e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.auth.VerifyAccessToken(e.ctx, session.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Refresh(e.ctx, session.RefreshToken)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.apiTokens.VerifyAccessToken(e.ctx, pat.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	// Reactivating restores login and personal access tokens, but not the
+	// sessions that deactivation ended.
+	_, err = e.auth.SetActive(e.ctx, admin.ID, user.ID, true)
+	mustNoErr(t, err)
+	_, err = e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	_, err = e.apiTokens.VerifyAccessToken(e.ctx, pat.Token)
+	mustNoErr(t, err)
+	_, err = e.auth.VerifyAccessToken(e.ctx, session.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestAuthUpdateRole(t *testing.T) {
+	e := newTestEnv(t)
+	admin, err := e.auth.CreateUser(e.ctx, "admin@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	session, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = e.auth.UpdateRole(e.ctx, admin.ID, user.ID, "ROOT")
+	wantFields(t, err, map[string]string{"role": "invalid_enum"})
+	_, err = e.auth.UpdateRole(e.ctx, admin.ID, admin.ID, "USER")
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.auth.UpdateRole(e.ctx, admin.ID, uuid.New(), "USER")
+	wantErr(t, err, domain.ErrNotFound)
+
+	_, err = e.auth.UpdateRole(e.ctx, admin.ID, user.ID, "ADMIN")
+	mustNoErr(t, err)
+	// Tokens already issued carry the new role.
+	p, err := e.auth.VerifyAccessToken(e.ctx, session.Token)
+	mustNoErr(t, err)
+	if p.Role != domain.RoleAdmin {
+		t.Fatalf("got role %s after promotion, want ADMIN", p.Role)
+	}
+}
+
+func TestAuthDeleteUser(t *testing.T) {
+	e := newTestEnv(t)
+	admin, err := e.auth.CreateUser(e.ctx, "admin@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+	owner, err := e.auth.CreateUser(e.ctx, "owner@example.com", "secret1", "")
+	mustNoErr(t, err)
+	guest, err := e.auth.CreateUser(e.ctx, "guest@example.com", "secret1", "")
+	mustNoErr(t, err)
+	doc, err := e.docs.Create(e.ctx, owner.ID, service.CreateDocInput{Title: "T", Visibility: "SHARED"})
+	mustNoErr(t, err)
+	e.share(t, owner.ID, domain.ShareResourceDocument, doc.ID, guest.ID, domain.ShareRoleView)
+
+	wantErr(t, e.auth.DeleteUser(e.ctx, admin.ID, admin.ID), domain.ErrForbidden)
+	wantErr(t, e.auth.DeleteUser(e.ctx, admin.ID, owner.ID), domain.ErrInvalidState)
+	mustNoErr(t, e.auth.DeleteUser(e.ctx, admin.ID, guest.ID))
+	wantErr(t, e.auth.DeleteUser(e.ctx, admin.ID, guest.ID), domain.ErrNotFound)
+
+	shares, err := e.shares.List(e.ctx, owner.ID, domain.ShareResourceDocument, doc.ID)
+	mustNoErr(t, err)
+	if len(shares) != 0 {
+		t.Fatalf("deleted user's share survived")
+	}
+}
+
+// authWith builds an AuthService on e's store with its own login guard and
+// password policy.
+func (e *testEnv) authWith(lockout service.LockoutPolicy, policy *service.PasswordPolicy) *service.AuthService {
+	guard := service.NewLoginGuard(e.throttles, lockout)
+	return service.NewAuthService(e.users, e.tokens, e.history, guard, policy,
+		service.NewTwoFactorService(e.users, e.recovery, e.settings, guard, "DocMV"),
+		e.signer, 15*time.Minute, time.Hour)
+}
+
+func wantRateLimited(t *testing.T, err error) *domain.RateLimitError {
+	t.Helper()
+	var rl *domain.RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter <= 0 {
+		t.Fatalf("got error %v, want a rate limit error", err)
+	}
+	return rl
+}
+
+func TestAuthLoginLockout(t *testing.T) {
+	e := newTestEnv(t)
+	auth := e.authWith(service.LockoutPolicy{MaxAccountFailures: 3, Lockout: time.Minute, Delay: 100 * time.Millisecond}, testPasswordPolicy())
+	_, err := auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	// A success forgets earlier failures.
+	_, err = auth.Login(e.ctx, "u@example.com", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	// The second failure in a row delays the next attempt; refused attempts
+	// do not count. Case variants of the email share the counter.
+	_, err = auth.Login(e.ctx, "u@example.com", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "U@Example.com", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	wantRateLimited(t, err)
+
+	// The third failure locks the account, even for the right password.
+	time.Sleep(110 * time.Millisecond)
+	_, err = auth.Login(e.ctx, "u@example.com", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	if rl := wantRateLimited(t, err); rl.RetryAfter < 30*time.Second {
+		t.Fatalf("retry after %s, want the lockout period", rl.RetryAfter)
+	}
+
+	lockouts, err := auth.ListLockouts(e.ctx)
+	mustNoErr(t, err)
+	if len(lockouts) != 1 || lockouts[0].Key != "account:u@example.com" || lockouts[0].Failures != 3 ||
+		!lockouts[0].Locked || lockouts[0].BlockedUntil == nil {
+		t.Fatalf("got lockouts %+v", lockouts)
+	}
+
+	wantFields(t, auth.ClearLockout(e.ctx, ""), map[string]string{"key": "required"})
+	mustNoErr(t, auth.ClearLockout(e.ctx, "account:u@example.com"))
+	wantErr(t, auth.ClearLockout(e.ctx, "account:u@example.com"), domain.ErrNotFound)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+}
+
+func TestAuthLoginIPLockout(t *testing.T) {
+	e := newTestEnv(t)
+	auth := e.authWith(service.LockoutPolicy{MaxAccountFailures: 5, MaxIPFailures: 3, Lockout: time.Minute}, testPasswordPolicy())
+	_, err := auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	// Guessing at several accounts, existing or not, locks the address.
+	for _, email := range []string{"a@example.com", "b@example.com", "u@example.com"} {
+		_, err = auth.Login(e.ctx, email, "wrong", "192.0.2.1")
+		wantErr(t, err, domain.ErrUnauthorized)
+	}
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "192.0.2.1")
+	wantRateLimited(t, err)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "192.0.2.2")
+	mustNoErr(t, err)
+
+	// The account's success does not unlock the address.
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "192.0.2.1")
+	wantRateLimited(t, err)
+}
+
+func TestAuthResetPasswordClearsLockout(t *testing.T) {
+	e := newTestEnv(t)
+	auth := e.authWith(service.LockoutPolicy{MaxAccountFailures: 1, Lockout: time.Minute}, testPasswordPolicy())
+	user, err := auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	_, err = auth.Login(e.ctx, "u@example.com", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	wantRateLimited(t, err)
+
+	mustNoErr(t, auth.ResetPassword(e.ctx, user.ID, "secret2"))
+	_, err = auth.Login(e.ctx, "u@example.com", "secret2", "")
+	mustNoErr(t, err)
+}
+
+func TestAuthPasswordPolicy(t *testing.T) {
+	e := newTestEnv(t)
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	mustNoErr(t, os.WriteFile(list, []byte("# top passwords\nPassword123!\n\nqwertyUIOP1\n"), 0o600))
+	policy := &service.PasswordPolicy{MinLength: 10, MinClasses: 3}
+	n, err := policy.LoadBreachedList(list)
+	mustNoErr(t, err)
+	if n != 2 {
+		t.Fatalf("loaded %d breached passwords, want 2", n)
+	}
+	auth := e.authWith(testLockout, policy)
+
+	for _, tc := range []struct{ password, code string }{
+		{"Short1!", "too_short"},
+		{strings.Repeat("Aa1", 25), "too_long"},
+		{"alllowercase1", "too_few_classes"},
+		{"PASSWORD123!", "breached"},
+	} {
+		_, err := auth.CreateUser(e.ctx, "u@example.com", tc.password, "")
+		wantFields(t, err, map[string]string{"password": tc.code})
+	}
+	user, err := auth.CreateUser(e.ctx, "u@example.com", "Correct-horse1", "")
+	mustNoErr(t, err)
+
+	_, err = auth.ChangePassword(e.ctx, user.ID, "Correct-horse1", "qwertyuiop1")
+	wantFields(t, err, map[string]string{"new_password": "too_few_classes"})
+	wantFields(t, auth.ResetPassword(e.ctx, user.ID, "Password123!"), map[string]string{"password": "breached"})
+}
+
+func TestAuthPasswordHistory(t *testing.T) {
+	e := newTestEnv(t)
+	auth := e.authWith(testLockout, &service.PasswordPolicy{MinLength: 6, MinClasses: 2, History: 2})
+	user, err := auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = auth.ChangePassword(e.ctx, user.ID, "secret1", "secret2")
+	mustNoErr(t, err)
+	_, err = auth.ChangePassword(e.ctx, user.ID, "secret2", "secret1")
+	wantFields(t, err, map[string]string{"new_password": "reused"})
+	wantFields(t, auth.ResetPassword(e.ctx, user.ID, "secret1"), map[string]string{"password": "reused"})
+
+	// Only the last two passwords are remembered.
+	mustNoErr(t, auth.ResetPassword(e.ctx, user.ID, "secret3"))
+	mustNoErr(t, auth.ResetPassword(e.ctx, user.ID, "secret1"))
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+}
+
+func TestAuthSeedAdmin(t *testing.T) {
+	e := newTestEnv(t)
+
+	// Without a password, one satisfying the policy is generated.
+	mustNoErr(t, e.auth.SeedAdmin(e.ctx, "admin@example.com", ""))
+	admin, err := e.users.GetByEmail(e.ctx, "admin@example.com")
+	mustNoErr(t, err)
+	if admin.Role != domain.RoleAdmin || admin.PasswordHash == "" {
+		t.Fatalf("got seeded admin %+v", admin)
+	}
+	mustNoErr(t, e.auth.SeedAdmin(e.ctx, "admin@example.com", ""))
+
+	if err := e.auth.SeedAdmin(e.ctx, "root@example.com", "12345"); err == nil {
+		t.Fatalf("seeding with a password that breaks the policy succeeded")
+	}
+	mustNoErr(t, e.auth.SeedAdmin(e.ctx, "root@example.com", "secret1"))
+	_, err = e.auth.Login(e.ctx, "root@example.com", "secret1", "")
+	mustNoErr(t, err)
+}
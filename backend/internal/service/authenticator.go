@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator checks a login and password against one source of accounts.
+// Authenticate returns the user they belong to, domain.ErrNotFound when the
+// source has no account for the login, and domain.ErrUnauthorized when the
+// password is wrong. Login tries the local passwords first and then each
+// configured authenticator in turn, until one accepts the password.
+type Authenticator interface {
+	Authenticate(ctx context.Context, login, password string) (*domain.User, error)
+}
+
+// passwordAuthenticator checks the bcrypt hashes of local accounts.
+type passwordAuthenticator struct {
+	userRepo repository.UserRepository
+}
+
+func (a passwordAuthenticator) Authenticate(ctx context.Context, email, password string) (*domain.User, error) {
+	user, err := a.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, fmt.Errorf("%w: invalid credentials", domain.ErrUnauthorized)
+	}
+	return user, nil
+}
+
+// authenticate runs the authenticator chain. Errors other than an unknown
+// login or a wrong password end it: a directory that is down must not turn
+// into "invalid credentials".
+func (s *AuthService) authenticate(ctx context.Context, login, password string) (*domain.User, error) {
+	var err error
+	for _, a := range s.authenticators {
+		var user *domain.User
+		user, err = a.Authenticate(ctx, login, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrUnauthorized) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: invalid credentials", domain.ErrUnauthorized)
+}
@@ -0,0 +1,448 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// DocConversionService converts legacy documents and their workflow nodes
+// into flows. Each document is converted at most once; document_conversions
+// remembers the result, so the conversion can be re-run after a failure or
+// after new documents appear.
+type DocConversionService struct {
+	txm             repository.TxManager
+	conversionRepo  repository.DocumentConversionRepository
+	versionRepo     repository.VersionRepository
+	nodeRepo        repository.WorkflowNodeRepository
+	docShares       repository.ShareRepository
+	flowRepo        repository.FlowRepository
+	flowNodeRepo    repository.FlowNodeRepository
+	flowVersionRepo repository.FlowVersionRepository
+	flowShares      repository.ShareRepository
+	docGroupShares  repository.GroupShareRepository
+	flowGroupShares repository.GroupShareRepository
+}
+
+func NewDocConversionService(txm repository.TxManager, conversionRepo repository.DocumentConversionRepository, versionRepo repository.VersionRepository,
+	nodeRepo repository.WorkflowNodeRepository, docShares repository.ShareRepository, flowRepo repository.FlowRepository,
+	flowNodeRepo repository.FlowNodeRepository, flowVersionRepo repository.FlowVersionRepository, flowShares repository.ShareRepository,
+	docGroupShares, flowGroupShares repository.GroupShareRepository) *DocConversionService {
+	return &DocConversionService{
+		txm:             txm,
+		conversionRepo:  conversionRepo,
+		versionRepo:     versionRepo,
+		nodeRepo:        nodeRepo,
+		docShares:       docShares,
+		flowRepo:        flowRepo,
+		flowNodeRepo:    flowNodeRepo,
+		flowVersionRepo: flowVersionRepo,
+		flowShares:      flowShares,
+		docGroupShares:  docGroupShares,
+		flowGroupShares: flowGroupShares,
+	}
+}
+
+// Outcomes of converting one document.
+const (
+	ConversionConverted = "converted"
+	ConversionExisting  = "already_converted"
+	ConversionSkipped   = "skipped_deleted"
+)
+
+type DocConversionItem struct {
+	DocumentID uuid.UUID         `json:"document_id"`
+	Title      string            `json:"title"`
+	Outcome    string            `json:"outcome"`
+	FlowID     uuid.UUID         `json:"flow_id,omitempty"`
+	FlowNo     string            `json:"flow_no,omitempty"`
+	Status     domain.FlowStatus `json:"status,omitempty"`
+	Nodes      int               `json:"nodes"`
+	Shares     int               `json:"shares"`
+	Versions   int               `json:"versions"` // document versions found; the latest becomes the snapshot
+}
+
+// DocConversionReport summarises a run. Problems lists every mismatch found
+// by Verify between a document converted in this run and its flow.
+type DocConversionReport struct {
+	DryRun    bool                `json:"dry_run"`
+	Documents int                 `json:"documents"`
+	Converted int                 `json:"converted"`
+	Existing  int                 `json:"already_converted"`
+	Skipped   int                 `json:"skipped_deleted"`
+	Items     []DocConversionItem `json:"items"`
+	Problems  []string            `json:"problems"`
+}
+
+// Convert turns every live, unconverted document into a flow and then
+// verifies the conversions it made. Trashed documents are skipped.
+//
+// Mapping:
+//   - title and owner are copied; the latest version's content becomes the overview
+//   - PUBLIC documents become EFFECTIVE flows (readable by everyone), the
+//     rest become DRAFT flows visible to the owner and share holders only
+//   - document shares are copied with their role
+//   - live workflow nodes become flow nodes in sort order (description→intro,
+//     preconditions→prereq_text, outputs→outputs_text) and are laid out as a
+//     linear diagram
+//   - one flow version holds the initial snapshot, attributed to the author
+//     of the latest document version; it is the published version only for
+//     EFFECTIVE flows
+//
+// With dryRun everything runs in a single transaction that is rolled back,
+// so the report shows what would happen without changing data.
+func (s *DocConversionService) Convert(ctx context.Context, dryRun bool) (*DocConversionReport, error) {
+	docs, err := s.conversionRepo.ListDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &DocConversionReport{DryRun: dryRun, Documents: len(docs), Items: make([]DocConversionItem, 0, len(docs)), Problems: []string{}}
+
+	var dryTx repository.Tx
+	if dryRun {
+		if dryTx, err = s.txm.Begin(ctx); err != nil {
+			return nil, err
+		}
+		defer dryTx.Rollback() //nolint:errcheck
+	}
+
+	var converted []uuid.UUID
+	for i := range docs {
+		doc := &docs[i]
+		item := DocConversionItem{DocumentID: doc.ID, Title: doc.Title}
+
+		if doc.DeletedAt != nil {
+			item.Outcome = ConversionSkipped
+			report.Skipped++
+			report.Items = append(report.Items, item)
+			continue
+		}
+
+		if dryRun {
+			err = s.convertTx(ctx, dryTx, doc, &item)
+		} else {
+			err = s.convertOne(ctx, doc, &item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("converting document %s: %w", doc.ID, err)
+		}
+
+		if item.Outcome == ConversionExisting {
+			report.Existing++
+		} else {
+			report.Converted++
+			converted = append(converted, doc.ID)
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	if !dryRun {
+		if report.Problems, err = s.Verify(ctx, converted); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Verify compares the recorded conversions of the given documents with
+// their source documents and returns one line per mismatch. Only freshly
+// converted documents are worth checking: once a flow is in use, edits to
+// it or to the document are expected to make the two drift apart.
+func (s *DocConversionService) Verify(ctx context.Context, documentIDs []uuid.UUID) ([]string, error) {
+	all, err := s.conversionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[uuid.UUID]bool, len(documentIDs))
+	for _, id := range documentIDs {
+		wanted[id] = true
+	}
+	conversions := make([]domain.DocumentConversion, 0, len(documentIDs))
+	for _, c := range all {
+		if wanted[c.DocumentID] {
+			conversions = append(conversions, c)
+		}
+	}
+
+	problems := []string{}
+	report := func(c domain.DocumentConversion, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("document %s → flow %s: ", c.DocumentID, c.FlowID)+fmt.Sprintf(format, args...))
+	}
+
+	for _, c := range conversions {
+		flow, err := s.flowRepo.GetByID(ctx, c.FlowID)
+		if errors.Is(err, domain.ErrNotFound) {
+			report(c, "flow is missing or in the trash")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if flow.LatestVersionID == nil || *flow.LatestVersionID != c.VersionID {
+			if _, err := s.flowVersionRepo.GetByID(ctx, c.VersionID); errors.Is(err, domain.ErrNotFound) {
+				report(c, "initial version %s is missing", c.VersionID)
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		docNodes, err := s.nodeRepo.ListByDocument(ctx, c.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+		flowNodes, err := s.flowNodeRepo.ListByFlow(ctx, c.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		if len(docNodes) != len(flowNodes) {
+			report(c, "document has %d nodes, flow has %d", len(docNodes), len(flowNodes))
+		}
+
+		docShares, err := s.docShares.ListByResource(ctx, c.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+		flowShares, err := s.flowShares.ListByResource(ctx, c.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		granted := make(map[uuid.UUID]domain.ShareRole, len(flowShares))
+		for _, sh := range flowShares {
+			granted[sh.UserID] = sh.Role
+		}
+		for _, sh := range docShares {
+			if role, ok := granted[sh.UserID]; !ok {
+				report(c, "share for user %s was not copied", sh.UserID)
+			} else if role != sh.Role {
+				report(c, "share for user %s is %s on the flow but %s on the document", sh.UserID, role, sh.Role)
+			}
+		}
+
+		docGroupShares, err := s.docGroupShares.ListByResource(ctx, c.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+		flowGroupShares, err := s.flowGroupShares.ListByResource(ctx, c.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		grantedGroups := make(map[uuid.UUID]domain.ShareRole, len(flowGroupShares))
+		for _, sh := range flowGroupShares {
+			grantedGroups[sh.GroupID] = sh.Role
+		}
+		for _, sh := range docGroupShares {
+			if role, ok := grantedGroups[sh.GroupID]; !ok {
+				report(c, "share for group %s was not copied", sh.GroupName)
+			} else if role != sh.Role {
+				report(c, "share for group %s is %s on the flow but %s on the document", sh.GroupName, role, sh.Role)
+			}
+		}
+	}
+	return problems, nil
+}
+
+// ---------- Internal ----------
+
+// convertOne converts a single document in its own transaction. Losing a
+// race against a concurrent run is reported as already converted.
+func (s *DocConversionService) convertOne(ctx context.Context, doc *domain.Document, item *DocConversionItem) error {
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	err = s.convertTx(ctx, tx, doc, item)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		*item = DocConversionItem{DocumentID: doc.ID, Title: doc.Title, Outcome: ConversionExisting}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *DocConversionService) convertTx(ctx context.Context, tx repository.Tx, doc *domain.Document, item *DocConversionItem) error {
+	existing, err := s.conversionRepo.GetTx(ctx, tx, doc.ID)
+	if err == nil {
+		item.Outcome = ConversionExisting
+		item.FlowID = existing.FlowID
+		return nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+
+	versions, err := s.versionRepo.ListByDocument(ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+	// versions are newest first; prefer the one the document points at.
+	var latest *domain.DocumentVersion
+	for i := range versions {
+		if doc.LatestVersionID != nil && versions[i].ID == *doc.LatestVersionID {
+			latest = &versions[i]
+			break
+		}
+	}
+	if latest == nil && len(versions) > 0 {
+		latest = &versions[0]
+	}
+
+	docNodes, err := s.nodeRepo.ListByDocument(ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+	nodes := make([]domain.FlowNode, 0, len(docNodes))
+	for _, n := range docNodes {
+		nodes = append(nodes, domain.FlowNode{
+			ID:           n.ID,
+			Name:         n.Name,
+			Intro:        n.Description,
+			RaciJSON:     n.RaciJSON,
+			ExecForm:     n.ExecForm,
+			DurationMin:  n.DurationMin,
+			DurationMax:  n.DurationMax,
+			DurationUnit: n.DurationUnit,
+			PrereqText:   n.Preconditions,
+			OutputsText:  n.Outputs,
+			SubtasksJSON: n.SubtasksJSON,
+			CreatedAt:    n.CreatedAt,
+		})
+	}
+
+	diagram, err := linearDiagram(nodes)
+	if err != nil {
+		return err
+	}
+
+	flowNo, err := s.flowRepo.NextFlowNoTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	status := domain.FlowStatusDraft
+	if doc.Visibility == domain.VisibilityPublic {
+		status = domain.FlowStatusEffective
+	}
+	flow := &domain.Flow{
+		FlowNo:      flowNo,
+		Title:       doc.Title,
+		OwnerID:     doc.OwnerID,
+		Status:      status,
+		DiagramJSON: diagram,
+		CreatedAt:   doc.CreatedAt,
+	}
+	if latest != nil {
+		flow.Overview = latest.Content
+	}
+	if err := s.flowRepo.CreateTx(ctx, tx, flow); err != nil {
+		return err
+	}
+	if err := s.flowNodeRepo.ReplaceTx(ctx, tx, flow.ID, nodes); err != nil {
+		return err
+	}
+
+	snapshot, err := encodeSnapshot(flow, nodes)
+	if err != nil {
+		return err
+	}
+	version := &domain.FlowVersion{FlowID: flow.ID, SnapshotJSON: snapshot, CreatedBy: doc.OwnerID}
+	if latest != nil {
+		version.CreatedBy = latest.CreatedBy
+		version.CreatedAt = latest.CreatedAt
+	}
+	if err := s.flowVersionRepo.CreateTx(ctx, tx, version); err != nil {
+		return err
+	}
+	// latest_version_id marks the version everyone may read, so only an
+	// effective flow gets one. Same status on both sides: this only attaches it.
+	if flow.Status == domain.FlowStatusEffective {
+		flow.LatestVersionID = &version.ID
+		if _, err := s.flowRepo.UpdateStatusTx(ctx, tx, flow, flow.Status); err != nil {
+			return err
+		}
+	}
+
+	shares, err := s.docShares.ListByResource(ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+	for _, sh := range shares {
+		if err := s.flowShares.CreateTx(ctx, tx, &domain.Share{ResourceID: flow.ID, UserID: sh.UserID, Role: sh.Role}); err != nil {
+			return err
+		}
+	}
+	groupShares, err := s.docGroupShares.ListByResource(ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+	for _, sh := range groupShares {
+		if err := s.flowGroupShares.CreateTx(ctx, tx, &domain.GroupShare{ResourceID: flow.ID, GroupID: sh.GroupID, Role: sh.Role}); err != nil {
+			return err
+		}
+	}
+
+	conversion := &domain.DocumentConversion{DocumentID: doc.ID, FlowID: flow.ID, VersionID: version.ID}
+	if err := s.conversionRepo.CreateTx(ctx, tx, conversion); err != nil {
+		return err
+	}
+
+	*item = DocConversionItem{
+		DocumentID: doc.ID,
+		Title:      doc.Title,
+		Outcome:    ConversionConverted,
+		FlowID:     flow.ID,
+		FlowNo:     flow.FlowNo,
+		Status:     flow.Status,
+		Nodes:      len(nodes),
+		Shares:     len(shares) + len(groupShares),
+		Versions:   len(versions),
+	}
+	return nil
+}
+
+// linearDiagram lays nodes out left to right joined by SEQ edges. Workflow
+// nodes carry no flow-level diagram, so this is the best available default.
+func linearDiagram(nodes []domain.FlowNode) (string, error) {
+	type diagramNode struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+		X     int    `json:"x"`
+		Y     int    `json:"y"`
+	}
+	type diagramEdge struct {
+		ID     string `json:"id"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+		Type   string `json:"type"`
+	}
+
+	diagram := struct {
+		Nodes []diagramNode `json:"nodes"`
+		Edges []diagramEdge `json:"edges"`
+	}{Nodes: []diagramNode{}, Edges: []diagramEdge{}}
+
+	for i, n := range nodes {
+		diagram.Nodes = append(diagram.Nodes, diagramNode{ID: n.ID.String(), Label: n.Name, X: 100 + 200*i, Y: 100})
+		if i > 0 {
+			prev := nodes[i-1].ID.String()
+			diagram.Edges = append(diagram.Edges, diagramEdge{
+				ID: fmt.Sprintf("e%d", i), Source: prev, Target: n.ID.String(), Type: "SEQ",
+			})
+		}
+	}
+
+	out, err := json.Marshal(diagram)
+	if err != nil {
+		return "", fmt.Errorf("encoding diagram: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+func TestDocConversion(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	editor := e.user(t, "editor@example.com")
+	public, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Onboarding", Content: "Welcome", Visibility: "PUBLIC"})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, public.ID, editor, domain.ShareRoleEdit)
+	createNode(t, e, owner, public.ID, "Kickoff")
+	createNode(t, e, owner, public.ID, "Review")
+	private, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Notes"})
+	mustNoErr(t, err)
+	trashed, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Old"})
+	mustNoErr(t, err)
+	mustNoErr(t, e.docs.Delete(e.ctx, owner, trashed.ID))
+
+	// A dry run reports the conversions without keeping them.
+	report, err := e.conversion.Convert(e.ctx, true)
+	mustNoErr(t, err)
+	if report.Converted != 2 || report.Skipped != 1 {
+		t.Fatalf("dry run got %+v", report)
+	}
+	if flows, err := e.flows.List(e.ctx, owner); err != nil || len(flows) != 0 {
+		t.Fatalf("dry run left %d flows (err %v)", len(flows), err)
+	}
+
+	report, err = e.conversion.Convert(e.ctx, false)
+	mustNoErr(t, err)
+	if report.Converted != 2 || report.Skipped != 1 || len(report.Problems) != 0 {
+		t.Fatalf("got %+v", report)
+	}
+	items := map[string]service.DocConversionItem{}
+	for _, item := range report.Items {
+		items[item.Title] = item
+	}
+	if it := items["Onboarding"]; it.Status != domain.FlowStatusEffective || it.Nodes != 2 || it.Shares != 1 {
+		t.Fatalf("public document converted to %+v", it)
+	}
+	if it := items["Notes"]; it.Status != domain.FlowStatusDraft || it.Nodes != 0 {
+		t.Fatalf("private document converted to %+v", it)
+	}
+
+	detail, err := e.flows.GetDetail(e.ctx, editor, items["Onboarding"].FlowID)
+	mustNoErr(t, err)
+	if detail.Flow.Overview != "Welcome" || detail.Flow.LatestVersionID == nil || len(detail.Nodes) != 2 || detail.Nodes[0].Name != "Kickoff" {
+		t.Fatalf("got flow %+v with nodes %+v", detail.Flow, detail.Nodes)
+	}
+	_, err = e.flows.GetDetail(e.ctx, editor, items["Notes"].FlowID)
+	wantErr(t, err, domain.ErrForbidden)
+
+	// Running again converts nothing new, and edits made to the converted
+	// flow since are not reported as problems.
+	notes, err := e.flows.GetDetail(e.ctx, owner, items["Notes"].FlowID)
+	mustNoErr(t, err)
+	_, err = e.flows.Update(e.ctx, owner, notes.Flow.ID, service.UpdateFlowInput{
+		Title: "Notes", Nodes: []service.FlowNodeInput{{Name: "Draft"}}, Revision: ptr(notes.Flow.Revision),
+	})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, public.ID, e.user(t, "late@example.com"), domain.ShareRoleView)
+	report, err = e.conversion.Convert(e.ctx, false)
+	mustNoErr(t, err)
+	if report.Converted != 0 || report.Existing != 2 || len(report.Problems) != 0 {
+		t.Fatalf("second run got %+v", report)
+	}
+	for _, item := range report.Items {
+		if item.DocumentID == private.ID && item.FlowID != items["Notes"].FlowID {
+			t.Fatalf("second run points %s at flow %s, want %s", item.Title, item.FlowID, items["Notes"].FlowID)
+		}
+	}
+}
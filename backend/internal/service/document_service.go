@@ -2,23 +2,23 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"docmv/internal/domain"
 	"docmv/internal/repository"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 )
 
 type DocumentService struct {
-	db          *sqlx.DB
-	docRepo     *repository.DocumentRepo
-	versionRepo *repository.VersionRepo
+	txm         repository.TxManager
+	docRepo     repository.DocumentRepository
+	versionRepo repository.VersionRepository
 }
 
-func NewDocumentService(db *sqlx.DB, docRepo *repository.DocumentRepo, versionRepo *repository.VersionRepo) *DocumentService {
-	return &DocumentService{db: db, docRepo: docRepo, versionRepo: versionRepo}
+func NewDocumentService(txm repository.TxManager, docRepo repository.DocumentRepository, versionRepo repository.VersionRepository) *DocumentService {
+	return &DocumentService{txm: txm, docRepo: docRepo, versionRepo: versionRepo}
 }
 
 type CreateDocInput struct {
@@ -31,6 +31,7 @@ type UpdateDocInput struct {
 	Title      string `json:"title"`
 	Content    string `json:"content"`
 	Visibility string `json:"visibility"`
+	Revision   *int   `json:"revision"` // required; the handler fills it from If-Match when present
 }
 
 type DocumentDetail struct {
@@ -84,7 +85,7 @@ func (s *DocumentService) Create(ctx context.Context, userID uuid.UUID, in Creat
 		return nil, fmt.Errorf("%w: invalid visibility", domain.ErrInvalidInput)
 	}
 
-	tx, err := s.db.BeginTxx(ctx, nil)
+	tx, err := s.txm.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +117,18 @@ func (s *DocumentService) Create(ctx context.Context, userID uuid.UUID, in Creat
 	return doc, tx.Commit()
 }
 
+// Delete moves a document to its owner's trash. Only the owner may delete.
+func (s *DocumentService) Delete(ctx context.Context, userID, docID uuid.UUID) error {
+	doc, err := s.docRepo.GetByID(ctx, docID)
+	if err != nil {
+		return err
+	}
+	if doc.OwnerID != userID {
+		return domain.ErrForbidden
+	}
+	return s.docRepo.SoftDelete(ctx, docID)
+}
+
 func (s *DocumentService) Update(ctx context.Context, userID, docID uuid.UUID, in UpdateDocInput) (*domain.Document, error) {
 	ok, err := s.docRepo.HasEditAccess(ctx, docID, userID)
 	if err != nil {
@@ -129,8 +142,11 @@ func (s *DocumentService) Update(ctx context.Context, userID, docID uuid.UUID, i
 	if err != nil {
 		return nil, err
 	}
+	if err := checkRevision(in.Revision, doc.Revision); err != nil {
+		return nil, s.staleError(ctx, userID, docID, err)
+	}
 
-	tx, txErr := s.db.BeginTxx(ctx, nil)
+	tx, txErr := s.txm.Begin(ctx)
 	if txErr != nil {
 		return nil, txErr
 	}
@@ -158,12 +174,25 @@ func (s *DocumentService) Update(ctx context.Context, userID, docID uuid.UUID, i
 	doc.LatestVersionID = &version.ID
 
 	if err := s.docRepo.UpdateTx(ctx, tx, doc); err != nil {
-		return nil, err
+		return nil, s.staleError(ctx, userID, docID, err)
 	}
 
 	return doc, tx.Commit()
 }
 
+// staleError upgrades ErrStaleRevision to a StaleRevisionError carrying the
+// current document detail. Other errors are returned unchanged.
+func (s *DocumentService) staleError(ctx context.Context, userID, docID uuid.UUID, err error) error {
+	if !errors.Is(err, domain.ErrStaleRevision) {
+		return err
+	}
+	current, loadErr := s.GetDetail(ctx, userID, docID)
+	if loadErr != nil {
+		return loadErr
+	}
+	return &domain.StaleRevisionError{Revision: current.Document.Revision, Current: current}
+}
+
 func (s *DocumentService) ListVersions(ctx context.Context, userID, docID uuid.UUID) ([]domain.DocumentVersion, error) {
 	ok, err := s.docRepo.HasReadAccess(ctx, docID, userID)
 	if err != nil {
@@ -175,3 +204,78 @@ func (s *DocumentService) ListVersions(ctx context.Context, userID, docID uuid.U
 
 	return s.versionRepo.ListByDocument(ctx, docID)
 }
+
+// RestoreVersion appends a new version whose content is copied from an older
+// one and makes it the latest. History is never rewritten.
+func (s *DocumentService) RestoreVersion(ctx context.Context, userID, docID, versionID uuid.UUID) (*domain.DocumentVersion, error) {
+	ok, err := s.docRepo.HasEditAccess(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	doc, err := s.docRepo.GetByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.versionRepo.GetByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if source.DocumentID != docID {
+		return nil, domain.ErrNotFound
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	version := &domain.DocumentVersion{
+		DocumentID:            docID,
+		Content:               source.Content,
+		CreatedBy:             userID,
+		RestoredFromVersionID: &source.ID,
+	}
+	if err := s.versionRepo.CreateTx(ctx, tx, version); err != nil {
+		return nil, err
+	}
+
+	doc.LatestVersionID = &version.ID
+	if err := s.docRepo.UpdateTx(ctx, tx, doc); err != nil {
+		return nil, err
+	}
+
+	return version, tx.Commit()
+}
+
+// DiffVersions compares the content of two versions of a document line by line.
+func (s *DocumentService) DiffVersions(ctx context.Context, userID, docID, fromID, toID uuid.UUID) (*DocumentVersionDiff, error) {
+	ok, err := s.docRepo.HasReadAccess(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	from, err := s.versionRepo.GetByID(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.versionRepo.GetByID(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.DocumentID != docID || to.DocumentID != docID {
+		return nil, domain.ErrNotFound
+	}
+
+	diff := diffLines(from.Content, to.Content)
+	diff.FromVersionID = fromID
+	diff.ToVersionID = toID
+	return diff, nil
+}
@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func TestDocumentCreateValidation(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+
+	tests := []struct {
+		name string
+		in   service.CreateDocInput
+	}{
+		{"missing title", service.CreateDocInput{Content: "x"}},
+		{"invalid visibility", service.CreateDocInput{Title: "T", Visibility: "SECRET"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.docs.Create(e.ctx, owner, tt.in)
+			wantErr(t, err, domain.ErrInvalidInput)
+		})
+	}
+}
+
+func TestDocumentCreateWritesFirstVersion(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Handbook", Content: "v1"})
+	mustNoErr(t, err)
+	if doc.Visibility != domain.VisibilityPrivate || doc.LatestVersionID == nil {
+		t.Fatalf("got visibility=%s latest=%v", doc.Visibility, doc.LatestVersionID)
+	}
+
+	detail, err := e.docs.GetDetail(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	if detail.Content != "v1" || detail.Document.Revision != doc.Revision {
+		t.Fatalf("got content %q revision %d, want v1 at %d", detail.Content, detail.Document.Revision, doc.Revision)
+	}
+}
+
+func TestDocumentAccess(t *testing.T) {
+	e := newTestEnv(t)
+	users := map[string]uuid.UUID{}
+	for _, name := range []string{"owner", "viewer", "editor", "stranger"} {
+		users[name] = e.user(t, name+"@example.com")
+	}
+	owner := users["owner"]
+
+	private, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Private"})
+	mustNoErr(t, err)
+	public, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Public", Visibility: "PUBLIC"})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, private.ID, users["viewer"], domain.ShareRoleView)
+	e.share(t, owner, domain.ShareResourceDocument, private.ID, users["editor"], domain.ShareRoleEdit)
+
+	tests := []struct {
+		name      string
+		doc       *domain.Document
+		user      string
+		canRead   bool
+		canUpdate bool
+	}{
+		{"owner of private", private, "owner", true, true},
+		{"view share", private, "viewer", true, false},
+		{"edit share", private, "editor", true, true},
+		{"stranger on private", private, "stranger", false, false},
+		{"stranger on public", public, "stranger", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := users[tt.user]
+
+			_, err := e.docs.GetDetail(e.ctx, userID, tt.doc.ID)
+			if tt.canRead {
+				mustNoErr(t, err)
+			} else {
+				wantErr(t, err, domain.ErrForbidden)
+			}
+
+			current, err := e.docs.GetDetail(e.ctx, owner, tt.doc.ID)
+			mustNoErr(t, err)
+			_, err = e.docs.Update(e.ctx, userID, tt.doc.ID, service.UpdateDocInput{
+				Content: "edit by " + tt.user, Revision: ptr(current.Document.Revision),
+			})
+			if tt.canUpdate {
+				mustNoErr(t, err)
+			} else {
+				wantErr(t, err, domain.ErrForbidden)
+			}
+		})
+	}
+
+	visible, err := e.docs.List(e.ctx, users["stranger"])
+	mustNoErr(t, err)
+	if len(visible) != 1 || visible[0].ID != public.ID {
+		t.Fatalf("stranger sees %d documents, want only the public one", len(visible))
+	}
+	visible, err = e.docs.List(e.ctx, users["viewer"])
+	mustNoErr(t, err)
+	if len(visible) != 2 {
+		t.Fatalf("viewer sees %d documents, want 2", len(visible))
+	}
+}
+
+func TestDocumentUpdateRevision(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "T", Content: "v1"})
+	mustNoErr(t, err)
+
+	_, err = e.docs.Update(e.ctx, owner, doc.ID, service.UpdateDocInput{Content: "v2"})
+	wantErr(t, err, domain.ErrPreconditionRequired)
+
+	updated, err := e.docs.Update(e.ctx, owner, doc.ID, service.UpdateDocInput{Content: "v2", Revision: ptr(doc.Revision)})
+	mustNoErr(t, err)
+	if updated.Revision != doc.Revision+1 {
+		t.Fatalf("got revision %d, want %d", updated.Revision, doc.Revision+1)
+	}
+
+	// A second writer still holding the old revision is turned away and
+	// handed the current state.
+	_, err = e.docs.Update(e.ctx, owner, doc.ID, service.UpdateDocInput{Content: "v2'", Revision: ptr(doc.Revision)})
+	var stale *domain.StaleRevisionError
+	if !errors.As(err, &stale) {
+		t.Fatalf("got error %v, want StaleRevisionError", err)
+	}
+	current, ok := stale.Current.(*service.DocumentDetail)
+	if stale.Revision != updated.Revision || !ok || current.Content != "v2" {
+		t.Fatalf("got stale revision %d current %+v, want %d with content v2", stale.Revision, stale.Current, updated.Revision)
+	}
+
+	versions, err := e.docs.ListVersions(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	if len(versions) != 2 || versions[0].Content != "v2" {
+		t.Fatalf("got %d versions, want 2 with v2 newest", len(versions))
+	}
+}
+
+func TestDocumentRestoreVersionAppends(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "T", Content: "v1"})
+	mustNoErr(t, err)
+	first := *doc.LatestVersionID
+	_, err = e.docs.Update(e.ctx, owner, doc.ID, service.UpdateDocInput{Content: "v2", Revision: ptr(doc.Revision)})
+	mustNoErr(t, err)
+
+	restored, err := e.docs.RestoreVersion(e.ctx, owner, doc.ID, first)
+	mustNoErr(t, err)
+	if restored.Content != "v1" || restored.RestoredFromVersionID == nil || *restored.RestoredFromVersionID != first {
+		t.Fatalf("got restored version %+v", restored)
+	}
+
+	versions, err := e.docs.ListVersions(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	if len(versions) != 3 {
+		t.Fatalf("got %d versions, want 3", len(versions))
+	}
+	detail, err := e.docs.GetDetail(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	if detail.Content != "v1" || *detail.Document.LatestVersionID != restored.ID {
+		t.Fatalf("latest content %q, want v1 from the restored version", detail.Content)
+	}
+
+	other, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Other"})
+	mustNoErr(t, err)
+	_, err = e.docs.RestoreVersion(e.ctx, owner, other.ID, first)
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+func TestDocumentDelete(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	editor := e.user(t, "editor@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "T"})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, doc.ID, editor, domain.ShareRoleEdit)
+
+	wantErr(t, e.docs.Delete(e.ctx, editor, doc.ID), domain.ErrForbidden)
+	mustNoErr(t, e.docs.Delete(e.ctx, owner, doc.ID))
+
+	_, err = e.docs.GetDetail(e.ctx, owner, doc.ID)
+	wantErr(t, err, domain.ErrForbidden)
+	visible, err := e.docs.List(e.ctx, owner)
+	mustNoErr(t, err)
+	if len(visible) != 0 {
+		t.Fatalf("deleted document still listed")
+	}
+}
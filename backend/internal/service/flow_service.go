@@ -1,125 +1,331 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
 	"docmv/internal/domain"
 	"docmv/internal/repository"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 )
 
 type FlowService struct {
-	db       *sqlx.DB
-	flowRepo *repository.FlowRepo
-	docRepo  *repository.DocumentRepo
+	txm         repository.TxManager
+	flowRepo    repository.FlowRepository
+	nodeRepo    repository.FlowNodeRepository
+	versionRepo repository.FlowVersionRepository
 }
 
-func NewFlowService(db *sqlx.DB, flowRepo *repository.FlowRepo, docRepo *repository.DocumentRepo) *FlowService {
-	return &FlowService{db: db, flowRepo: flowRepo, docRepo: docRepo}
+func NewFlowService(txm repository.TxManager, flowRepo repository.FlowRepository, nodeRepo repository.FlowNodeRepository, versionRepo repository.FlowVersionRepository) *FlowService {
+	return &FlowService{txm: txm, flowRepo: flowRepo, nodeRepo: nodeRepo, versionRepo: versionRepo}
 }
 
-// NodeInput holds parameters for creating or updating a workflow node.
-type NodeInput struct {
-	Name          string              `json:"name"`
-	ExecForm      domain.ExecForm     `json:"exec_form"`
-	Description   string              `json:"description"`
-	Preconditions string              `json:"preconditions"`
-	Outputs       string              `json:"outputs"`
-	DurationMin   *float64            `json:"duration_min"`
-	DurationMax   *float64            `json:"duration_max"`
-	DurationUnit  domain.DurationUnit `json:"duration_unit"`
-	Raci          *domain.RACI        `json:"raci"`
-	Subtasks      []string            `json:"subtasks"`
-	DiagramJSON   *domain.DiagramJSON `json:"diagram_json"`
+type CreateFlowInput struct {
+	Title       string `json:"title"`
+	OwnerDeptID string `json:"owner_dept_id"`
+	Overview    string `json:"overview"`
 }
 
-// validateNodeInput performs field-level validation and returns a ValidationError if any fields are invalid.
-func validateNodeInput(in *NodeInput) error {
+// FlowNodeInput is a node as sent by the editor. ID may be a client-side
+// placeholder (e.g. "node-1712345678"); such IDs are replaced by UUIDs on save.
+type FlowNodeInput struct {
+	ID           string              `json:"id"`
+	NodeNo       string              `json:"node_no"`
+	Name         string              `json:"name"`
+	Intro        string              `json:"intro"`
+	RaciJSON     string              `json:"raci_json"`
+	ExecForm     domain.ExecForm     `json:"exec_form"`
+	DurationMin  *float64            `json:"duration_min"`
+	DurationMax  *float64            `json:"duration_max"`
+	DurationUnit domain.DurationUnit `json:"duration_unit"`
+	PrereqText   string              `json:"prereq_text"`
+	OutputsText  string              `json:"outputs_text"`
+	SubtasksJSON string              `json:"subtasks_json"`
+}
+
+type UpdateFlowInput struct {
+	Title       string          `json:"title"`
+	OwnerDeptID string          `json:"owner_dept_id"`
+	Overview    string          `json:"overview"`
+	DiagramJSON string          `json:"diagram_json"`
+	Nodes       []FlowNodeInput `json:"nodes"`
+	Revision    *int            `json:"revision"` // required; the handler fills it from If-Match when present
+}
+
+type FlowDetail struct {
+	Flow                 domain.Flow       `json:"flow"`
+	Nodes                []domain.FlowNode `json:"nodes"`
+	TotalDurationMinDays float64           `json:"total_duration_min_days"`
+	TotalDurationMaxDays float64           `json:"total_duration_max_days"`
+}
+
+// validateFlowNodes performs field-level validation on the submitted node list.
+func validateFlowNodes(nodes []FlowNodeInput) error {
 	fields := make(map[string]string)
 
-	if in.Name == "" {
-		fields["name"] = "required"
+	for i, n := range nodes {
+		key := fmt.Sprintf("nodes[%d].", i)
+		if strings.TrimSpace(n.Name) == "" {
+			fields[key+"name"] = "required"
+		}
+		if n.ExecForm != "" && !n.ExecForm.Valid() {
+			fields[key+"exec_form"] = "invalid_enum"
+		}
+		if n.DurationUnit != "" && !n.DurationUnit.Valid() {
+			fields[key+"duration_unit"] = "invalid_enum"
+		}
+		if n.DurationMin != nil && *n.DurationMin < 0 {
+			fields[key+"duration_min"] = "must_be_non_negative"
+		}
+		if n.DurationMax != nil && *n.DurationMax < 0 {
+			fields[key+"duration_max"] = "must_be_non_negative"
+		}
+		if n.DurationMin != nil && n.DurationMax != nil && *n.DurationMin > *n.DurationMax {
+			fields[key+"duration"] = "min_gt_max"
+		}
+		if n.RaciJSON != "" && !json.Valid([]byte(n.RaciJSON)) {
+			fields[key+"raci_json"] = "invalid_json"
+		}
+		if n.SubtasksJSON != "" && !json.Valid([]byte(n.SubtasksJSON)) {
+			fields[key+"subtasks_json"] = "invalid_json"
+		}
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+	return nil
+}
+
+// toFlowNodes converts editor input into FlowNodes. Client-side placeholder IDs
+// are replaced by fresh UUIDs; the returned map records old → new IDs so that
+// diagram references can be rewritten.
+func toFlowNodes(in []FlowNodeInput) ([]domain.FlowNode, map[string]string) {
+	nodes := make([]domain.FlowNode, 0, len(in))
+	remap := make(map[string]string)
+
+	for _, n := range in {
+		id, err := uuid.Parse(n.ID)
+		if err != nil {
+			id = uuid.New()
+			if n.ID != "" {
+				remap[n.ID] = id.String()
+			}
+		}
+		unit := n.DurationUnit
+		if unit == "" {
+			unit = domain.DurationUnitDay
+		}
+		subtasks := n.SubtasksJSON
+		if subtasks == "" {
+			subtasks = "[]"
+		}
+		nodes = append(nodes, domain.FlowNode{
+			ID:           id,
+			NodeNo:       n.NodeNo,
+			Name:         strings.TrimSpace(n.Name),
+			Intro:        n.Intro,
+			RaciJSON:     n.RaciJSON,
+			ExecForm:     n.ExecForm,
+			DurationMin:  n.DurationMin,
+			DurationMax:  n.DurationMax,
+			DurationUnit: unit,
+			PrereqText:   n.PrereqText,
+			OutputsText:  n.OutputsText,
+			SubtasksJSON: subtasks,
+		})
 	}
-	if in.ExecForm == "" {
-		fields["exec_form"] = "required"
-	} else if !in.ExecForm.Valid() {
-		fields["exec_form"] = "invalid_enum"
+	return nodes, remap
+}
+
+// remapDiagramIDs rewrites diagram node IDs and edge endpoints according to remap.
+func remapDiagramIDs(raw string, remap map[string]string) (string, error) {
+	if raw == "" || len(remap) == 0 {
+		return raw, nil
 	}
 
-	if in.DurationUnit != "" && !in.DurationUnit.Valid() {
-		fields["duration_unit"] = "invalid_enum"
+	var diagram map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &diagram); err != nil {
+		return "", err
 	}
 
-	if in.DurationMin != nil && in.DurationMax != nil {
-		if *in.DurationMin < 0 {
-			fields["duration_min"] = "must_be_non_negative"
+	rewrite := func(list interface{}, keys ...string) {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, k := range keys {
+				if old, ok := obj[k].(string); ok {
+					if id, ok := remap[old]; ok {
+						obj[k] = id
+					}
+				}
+			}
 		}
-		if *in.DurationMax < 0 {
-			fields["duration_max"] = "must_be_non_negative"
+	}
+	rewrite(diagram["nodes"], "id")
+	rewrite(diagram["edges"], "source", "target")
+
+	out, err := json.Marshal(diagram)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// computeTotals sums node durations in working days. A node with only one
+// bound set uses it for both.
+func computeTotals(nodes []domain.FlowNode) (minDays, maxDays float64) {
+	for _, n := range nodes {
+		lo, hi := n.DurationMin, n.DurationMax
+		if lo == nil {
+			lo = hi
+		}
+		if hi == nil {
+			hi = lo
+		}
+		if lo != nil {
+			minDays += n.DurationUnit.ToDays(*lo)
 		}
-		if *in.DurationMin > *in.DurationMax {
-			fields["duration"] = "min_gt_max"
+		if hi != nil {
+			maxDays += n.DurationUnit.ToDays(*hi)
 		}
 	}
+	return minDays, maxDays
+}
 
-	if len(fields) > 0 {
-		return domain.NewValidationError(fields)
+// List returns the flows the user can see. Flows being reworked after
+// publication are listed as last published to users outside the draft.
+func (s *FlowService) List(ctx context.Context, userID uuid.UUID) ([]domain.Flow, error) {
+	flows, err := s.flowRepo.ListVisible(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	for i := range flows {
+		version, err := s.publishedFor(ctx, userID, &flows[i])
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			flows[i] = publishedHeader(&flows[i], version)
+		}
+	}
+	return flows, nil
 }
 
-// normalizeInput fills defaults for optional fields.
-func normalizeInput(in *NodeInput) {
-	if in.Raci == nil {
-		in.Raci = &domain.RACI{}
+func (s *FlowService) Create(ctx context.Context, userID uuid.UUID, in CreateFlowInput) (*domain.Flow, error) {
+	if strings.TrimSpace(in.Title) == "" {
+		return nil, domain.NewValidationError(map[string]string{"title": "required"})
 	}
-	in.Raci.Normalize()
 
-	if in.Subtasks == nil {
-		in.Subtasks = []string{}
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback() //nolint:errcheck
 
-	if in.DiagramJSON == nil {
-		in.DiagramJSON = &domain.DiagramJSON{}
+	flowNo, err := s.flowRepo.NextFlowNoTx(ctx, tx)
+	if err != nil {
+		return nil, err
 	}
-	in.DiagramJSON.Normalize()
 
-	if in.DurationUnit == "" {
-		in.DurationUnit = domain.DurationUnitDay
+	flow := &domain.Flow{
+		FlowNo:      flowNo,
+		Title:       strings.TrimSpace(in.Title),
+		OwnerID:     userID,
+		OwnerDeptID: in.OwnerDeptID,
+		Overview:    in.Overview,
+		Status:      domain.FlowStatusDraft,
+	}
+	if err := s.flowRepo.CreateTx(ctx, tx, flow); err != nil {
+		return nil, err
 	}
+
+	return flow, tx.Commit()
 }
 
-// toNode converts validated input to a WorkflowNode, serialising JSON fields.
-func toNode(in *NodeInput) *domain.WorkflowNode {
-	raciBytes, _ := json.Marshal(in.Raci)
-	subtasksBytes, _ := json.Marshal(in.Subtasks)
-	diagramBytes, _ := json.Marshal(in.DiagramJSON)
+func (s *FlowService) GetDetail(ctx context.Context, userID, flowID uuid.UUID) (*FlowDetail, error) {
+	ok, err := s.flowRepo.HasReadAccess(ctx, flowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
 
-	return &domain.WorkflowNode{
-		Name:          in.Name,
-		ExecForm:      in.ExecForm,
-		Description:   in.Description,
-		Preconditions: in.Preconditions,
-		Outputs:       in.Outputs,
-		DurationMin:   in.DurationMin,
-		DurationMax:   in.DurationMax,
-		DurationUnit:  in.DurationUnit,
-		RaciJSON:      string(raciBytes),
-		SubtasksJSON:  string(subtasksBytes),
-		DiagramRaw:    string(diagramBytes),
-		Raci:          *in.Raci,
-		Subtasks:      in.Subtasks,
-		DiagramJSON:   *in.DiagramJSON,
+	detail, err := s.loadDetail(ctx, flowID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.publishedFor(ctx, userID, &detail.Flow)
+	if err != nil {
+		return nil, err
 	}
+	if version != nil {
+		snap := version.Snapshot
+		detail = &FlowDetail{
+			Flow:                 publishedHeader(&detail.Flow, version),
+			Nodes:                snap.Nodes,
+			TotalDurationMinDays: snap.TotalDurationMinDays,
+			TotalDurationMaxDays: snap.TotalDurationMaxDays,
+		}
+	}
+	return detail, nil
+}
+
+// publishedFor returns the version a user should see instead of the working
+// copy: readers without draft access see a flow that is back in DRAFT or
+// IN_REVIEW as it was last published. It returns nil when the user sees the
+// flow as it is.
+func (s *FlowService) publishedFor(ctx context.Context, userID uuid.UUID, flow *domain.Flow) (*domain.FlowVersion, error) {
+	if flow.Status == domain.FlowStatusEffective || flow.LatestVersionID == nil {
+		return nil, nil
+	}
+	ok, err := s.flowRepo.HasDraftAccess(ctx, flow.ID, userID)
+	if err != nil || ok {
+		return nil, err
+	}
+	return s.versionRepo.GetByID(ctx, *flow.LatestVersionID)
+}
+
+// publishedHeader overlays the published content of a version on the flow's
+// current row, which keeps identity, owner and revision.
+func publishedHeader(flow *domain.Flow, version *domain.FlowVersion) domain.Flow {
+	out := *flow
+	out.Title = version.Snapshot.Flow.Title
+	out.OwnerDeptID = version.Snapshot.Flow.OwnerDeptID
+	out.Overview = version.Snapshot.Flow.Overview
+	out.DiagramJSON = version.Snapshot.Flow.DiagramJSON
+	out.Status = domain.FlowStatusEffective
+	out.UpdatedAt = version.CreatedAt
+	return out
+}
+
+func (s *FlowService) loadDetail(ctx context.Context, flowID uuid.UUID) (*FlowDetail, error) {
+	flow, err := s.flowRepo.GetByID(ctx, flowID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.nodeRepo.ListByFlow(ctx, flowID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &FlowDetail{Flow: *flow, Nodes: nodes}
+	detail.TotalDurationMinDays, detail.TotalDurationMaxDays = computeTotals(nodes)
+	return detail, nil
 }
 
-// CreateNode creates a new workflow node associated with a document.
-func (s *FlowService) CreateNode(ctx context.Context, userID, docID uuid.UUID, in NodeInput) (*domain.WorkflowNode, error) {
-	// Verify document edit access
-	ok, err := s.docRepo.HasEditAccess(ctx, docID, userID)
+// Update replaces the flow header, diagram and full node list.
+func (s *FlowService) Update(ctx context.Context, userID, flowID uuid.UUID, in UpdateFlowInput) (*domain.Flow, error) {
+	ok, err := s.flowRepo.HasEditAccess(ctx, flowID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,39 +333,84 @@ func (s *FlowService) CreateNode(ctx context.Context, userID, docID uuid.UUID, i
 		return nil, domain.ErrForbidden
 	}
 
-	normalizeInput(&in)
-	if err := validateNodeInput(&in); err != nil {
+	flow, err := s.flowRepo.GetByID(ctx, flowID)
+	if err != nil {
 		return nil, err
 	}
+	if !flow.Status.Editable() {
+		return nil, fmt.Errorf("%w: only DRAFT flows can be edited", domain.ErrInvalidState)
+	}
+	if err := checkRevision(in.Revision, flow.Revision); err != nil {
+		return nil, s.staleError(ctx, flowID, err)
+	}
 
-	tx, txErr := s.db.BeginTxx(ctx, nil)
-	if txErr != nil {
-		return nil, txErr
+	if strings.TrimSpace(in.Title) == "" {
+		return nil, domain.NewValidationError(map[string]string{"title": "required"})
+	}
+	if in.DiagramJSON != "" && !json.Valid([]byte(in.DiagramJSON)) {
+		return nil, domain.NewValidationError(map[string]string{"diagram_json": "invalid_json"})
+	}
+	if err := validateFlowNodes(in.Nodes); err != nil {
+		return nil, err
 	}
-	defer tx.Rollback() //nolint:errcheck
 
-	node := toNode(&in)
-	node.DocumentID = docID
+	nodes, remap := toFlowNodes(in.Nodes)
+	diagram, err := remapDiagramIDs(in.DiagramJSON, remap)
+	if err != nil {
+		return nil, domain.NewValidationError(map[string]string{"diagram_json": "invalid_json"})
+	}
+
+	flow.Title = strings.TrimSpace(in.Title)
+	flow.OwnerDeptID = in.OwnerDeptID
+	flow.Overview = in.Overview
+	flow.DiagramJSON = diagram
 
-	if err := s.flowRepo.CreateTx(ctx, tx, node); err != nil {
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback() //nolint:errcheck
 
-	if err := tx.Commit(); err != nil {
+	if err := s.flowRepo.UpdateTx(ctx, tx, flow); err != nil {
+		return nil, s.staleError(ctx, flowID, err)
+	}
+	if err := s.nodeRepo.ReplaceTx(ctx, tx, flowID, nodes); err != nil {
 		return nil, err
 	}
-	return node, nil
+
+	return flow, tx.Commit()
+}
+
+// staleError upgrades ErrStaleRevision to a StaleRevisionError carrying the
+// current flow detail. Other errors are returned unchanged.
+func (s *FlowService) staleError(ctx context.Context, flowID uuid.UUID, err error) error {
+	if !errors.Is(err, domain.ErrStaleRevision) {
+		return err
+	}
+	current, loadErr := s.loadDetail(ctx, flowID)
+	if loadErr != nil {
+		return loadErr
+	}
+	return &domain.StaleRevisionError{Revision: current.Flow.Revision, Current: current}
 }
 
-// UpdateNode updates an existing workflow node.
-func (s *FlowService) UpdateNode(ctx context.Context, userID, nodeID uuid.UUID, in NodeInput) (*domain.WorkflowNode, error) {
-	existing, err := s.flowRepo.GetByID(ctx, nodeID)
+// Delete moves a flow to its owner's trash. Only the owner may delete.
+func (s *FlowService) Delete(ctx context.Context, userID, flowID uuid.UUID) error {
+	flow, err := s.flowRepo.GetByID(ctx, flowID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if flow.OwnerID != userID {
+		return domain.ErrForbidden
 	}
+	return s.flowRepo.SoftDelete(ctx, flowID)
+}
 
-	// Verify document edit access
-	ok, err := s.docRepo.HasEditAccess(ctx, existing.DocumentID, userID)
+// transition applies a lifecycle action to a flow. If beforeCommit is set, it
+// runs inside the transaction before the status is switched.
+func (s *FlowService) transition(ctx context.Context, userID, flowID uuid.UUID, action domain.FlowAction,
+	beforeCommit func(tx repository.Tx, flow *domain.Flow) error) (*domain.Flow, error) {
+	ok, err := s.flowRepo.HasEditAccess(ctx, flowID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -167,40 +418,166 @@ func (s *FlowService) UpdateNode(ctx context.Context, userID, nodeID uuid.UUID,
 		return nil, domain.ErrForbidden
 	}
 
-	normalizeInput(&in)
-	if err := validateNodeInput(&in); err != nil {
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
 		return nil, err
 	}
-
-	tx, txErr := s.db.BeginTxx(ctx, nil)
-	if txErr != nil {
-		return nil, txErr
-	}
 	defer tx.Rollback() //nolint:errcheck
 
-	node := toNode(&in)
-	node.ID = existing.ID
-	node.DocumentID = existing.DocumentID
-	node.CreatedAt = existing.CreatedAt
+	flow, err := s.flowRepo.GetByIDTx(ctx, tx, flowID)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := s.flowRepo.UpdateTx(ctx, tx, node); err != nil {
+	from := flow.Status
+	next, err := from.Transition(action)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if beforeCommit != nil {
+		if err := beforeCommit(tx, flow); err != nil {
+			return nil, err
+		}
+	}
+
+	flow.Status = next
+	switched, err := s.flowRepo.UpdateStatusTx(ctx, tx, flow, from)
+	if err != nil {
 		return nil, err
 	}
-	return node, nil
+	if !switched {
+		return nil, &domain.TransitionError{From: string(from), Action: string(action)}
+	}
+
+	return flow, tx.Commit()
+}
+
+// SubmitReview moves a draft into review (DRAFT → IN_REVIEW).
+func (s *FlowService) SubmitReview(ctx context.Context, userID, flowID uuid.UUID) (*domain.Flow, error) {
+	return s.transition(ctx, userID, flowID, domain.FlowActionSubmitReview, nil)
+}
+
+// Reject sends a flow under review back to draft (IN_REVIEW → DRAFT).
+func (s *FlowService) Reject(ctx context.Context, userID, flowID uuid.UUID) (*domain.Flow, error) {
+	return s.transition(ctx, userID, flowID, domain.FlowActionReject, nil)
+}
+
+// NewDraft reopens an effective flow for editing (EFFECTIVE → DRAFT). The
+// published versions stay untouched; the current content becomes the draft.
+// Until the next publish, readers outside the draft keep seeing the latest
+// published version.
+func (s *FlowService) NewDraft(ctx context.Context, userID, flowID uuid.UUID) (*domain.Flow, error) {
+	return s.transition(ctx, userID, flowID, domain.FlowActionNewDraft, nil)
+}
+
+// Publish makes a flow effective (IN_REVIEW → EFFECTIVE). The header and
+// ordered nodes are read and frozen into a snapshot in the same transaction
+// that flips the status and sets latest_version_id.
+func (s *FlowService) Publish(ctx context.Context, userID, flowID uuid.UUID) (*domain.Flow, error) {
+	return s.transition(ctx, userID, flowID, domain.FlowActionPublish, func(tx repository.Tx, flow *domain.Flow) error {
+		nodes, err := s.nodeRepo.ListByFlowTx(ctx, tx, flowID)
+		if err != nil {
+			return err
+		}
+
+		snapshot, err := encodeSnapshot(flow, nodes)
+		if err != nil {
+			return err
+		}
+
+		version := &domain.FlowVersion{
+			FlowID:       flowID,
+			SnapshotJSON: snapshot,
+			CreatedBy:    userID,
+		}
+		if err := s.versionRepo.CreateTx(ctx, tx, version); err != nil {
+			return err
+		}
+		flow.LatestVersionID = &version.ID
+		return nil
+	})
+}
+
+// encodeSnapshot serialises a flow as published. The output is canonical:
+// nodes are in sort order, diagram_json is compacted, and version pointers
+// (which differ between otherwise identical publishes) are left out.
+func encodeSnapshot(flow *domain.Flow, nodes []domain.FlowNode) (string, error) {
+	header := *flow
+	header.Status = domain.FlowStatusEffective
+	header.LatestVersionID = nil
+	header.Revision = 0
+	header.DeletedAt = nil
+	if header.DiagramJSON != "" {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(header.DiagramJSON)); err == nil {
+			header.DiagramJSON = buf.String()
+		}
+	}
+
+	snap := domain.FlowSnapshot{
+		SchemaVersion: domain.FlowSnapshotSchema,
+		Flow:          header,
+		Nodes:         nodes,
+	}
+	snap.TotalDurationMinDays, snap.TotalDurationMaxDays = computeTotals(nodes)
+
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return "", fmt.Errorf("encoding snapshot: %w", err)
+	}
+	return string(out), nil
 }
 
-// GetNode returns a single workflow node with access check.
-func (s *FlowService) GetNode(ctx context.Context, userID, nodeID uuid.UUID) (*domain.WorkflowNode, error) {
-	node, err := s.flowRepo.GetByID(ctx, nodeID)
+// RestoreVersion rolls an effective flow back to an older published version.
+// The flow header and nodes are overwritten from that snapshot and a new
+// version carrying the same snapshot is appended; history is never rewritten.
+func (s *FlowService) RestoreVersion(ctx context.Context, userID, flowID, versionID uuid.UUID) (*domain.Flow, error) {
+	ok, err := s.flowRepo.HasEditAccess(ctx, flowID, userID)
 	if err != nil {
 		return nil, err
 	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
 
-	ok, err := s.docRepo.HasReadAccess(ctx, node.DocumentID, userID)
+	source, err := s.versionRepo.GetByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if source.FlowID != flowID {
+		return nil, domain.ErrNotFound
+	}
+	snap := source.Snapshot
+
+	return s.transition(ctx, userID, flowID, domain.FlowActionRestore, func(tx repository.Tx, flow *domain.Flow) error {
+		flow.Title = snap.Flow.Title
+		flow.OwnerDeptID = snap.Flow.OwnerDeptID
+		flow.Overview = snap.Flow.Overview
+		flow.DiagramJSON = snap.Flow.DiagramJSON
+		if err := s.flowRepo.UpdateTx(ctx, tx, flow); err != nil {
+			return err
+		}
+		if err := s.nodeRepo.ReplaceTx(ctx, tx, flowID, snap.Nodes); err != nil {
+			return err
+		}
+
+		version := &domain.FlowVersion{
+			FlowID:                flowID,
+			SnapshotJSON:          source.SnapshotJSON,
+			CreatedBy:             userID,
+			RestoredFromVersionID: &source.ID,
+		}
+		if err := s.versionRepo.CreateTx(ctx, tx, version); err != nil {
+			return err
+		}
+		flow.LatestVersionID = &version.ID
+		return nil
+	})
+}
+
+func (s *FlowService) ListVersions(ctx context.Context, userID, flowID uuid.UUID) ([]domain.FlowVersion, error) {
+	ok, err := s.flowRepo.HasReadAccess(ctx, flowID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -208,12 +585,11 @@ func (s *FlowService) GetNode(ctx context.Context, userID, nodeID uuid.UUID) (*d
 		return nil, domain.ErrForbidden
 	}
 
-	return node, nil
+	return s.versionRepo.ListByFlow(ctx, flowID)
 }
 
-// ListNodes returns all workflow nodes for a document.
-func (s *FlowService) ListNodes(ctx context.Context, userID, docID uuid.UUID) ([]domain.WorkflowNode, error) {
-	ok, err := s.docRepo.HasReadAccess(ctx, docID, userID)
+func (s *FlowService) GetVersion(ctx context.Context, userID, flowID, versionID uuid.UUID) (*domain.FlowVersion, error) {
+	ok, err := s.flowRepo.HasReadAccess(ctx, flowID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -221,5 +597,29 @@ func (s *FlowService) ListNodes(ctx context.Context, userID, docID uuid.UUID) ([
 		return nil, domain.ErrForbidden
 	}
 
-	return s.flowRepo.ListByDocument(ctx, docID)
+	v, err := s.versionRepo.GetByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if v.FlowID != flowID {
+		return nil, domain.ErrNotFound
+	}
+	return v, nil
+}
+
+// DiffVersions compares two published versions of a flow.
+func (s *FlowService) DiffVersions(ctx context.Context, userID, flowID, fromID, toID uuid.UUID) (*FlowVersionDiff, error) {
+	from, err := s.GetVersion(ctx, userID, flowID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.GetVersion(ctx, userID, flowID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffFlowSnapshots(from.Snapshot, to.Snapshot)
+	diff.FromVersionID = fromID
+	diff.ToVersionID = toID
+	return diff, nil
 }
@@ -0,0 +1,395 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func createFlow(t *testing.T, e *testEnv, owner uuid.UUID, title string) *domain.Flow {
+	t.Helper()
+	flow, err := e.flows.Create(e.ctx, owner, service.CreateFlowInput{Title: title})
+	mustNoErr(t, err)
+	return flow
+}
+
+// publish walks a draft through review to EFFECTIVE.
+func publish(t *testing.T, e *testEnv, owner uuid.UUID, flowID uuid.UUID) *domain.Flow {
+	t.Helper()
+	_, err := e.flows.SubmitReview(e.ctx, owner, flowID)
+	mustNoErr(t, err)
+	flow, err := e.flows.Publish(e.ctx, owner, flowID)
+	mustNoErr(t, err)
+	return flow
+}
+
+func TestFlowCreate(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+
+	_, err := e.flows.Create(e.ctx, owner, service.CreateFlowInput{Title: "  "})
+	wantFields(t, err, map[string]string{"title": "required"})
+
+	first := createFlow(t, e, owner, " Onboarding ")
+	second := createFlow(t, e, owner, "Offboarding")
+	if first.Title != "Onboarding" || first.Status != domain.FlowStatusDraft {
+		t.Fatalf("got title %q status %s", first.Title, first.Status)
+	}
+	if !strings.HasSuffix(first.FlowNo, "-0001") || !strings.HasSuffix(second.FlowNo, "-0002") {
+		t.Fatalf("got flow numbers %s, %s", first.FlowNo, second.FlowNo)
+	}
+}
+
+func TestFlowUpdateValidation(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+
+	tests := []struct {
+		name   string
+		in     service.UpdateFlowInput
+		fields map[string]string
+	}{
+		{"missing title", service.UpdateFlowInput{}, map[string]string{"title": "required"}},
+		{"bad diagram", service.UpdateFlowInput{Title: "F", DiagramJSON: "{"}, map[string]string{"diagram_json": "invalid_json"}},
+		{"bad nodes", service.UpdateFlowInput{Title: "F", Nodes: []service.FlowNodeInput{
+			{Name: "ok"},
+			{Name: " ", ExecForm: "TELEPATHY", DurationUnit: "FORTNIGHT"},
+			{Name: "n", DurationMin: ptr(3.0), DurationMax: ptr(1.0), RaciJSON: "[", SubtasksJSON: "{"},
+			{Name: "n", DurationMin: ptr(-1.0)},
+		}}, map[string]string{
+			"nodes[1].name":          "required",
+			"nodes[1].exec_form":     "invalid_enum",
+			"nodes[1].duration_unit": "invalid_enum",
+			"nodes[2].duration":      "min_gt_max",
+			"nodes[2].raci_json":     "invalid_json",
+			"nodes[2].subtasks_json": "invalid_json",
+			"nodes[3].duration_min":  "must_be_non_negative",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.in.Revision = ptr(flow.Revision)
+			_, err := e.flows.Update(e.ctx, owner, flow.ID, tt.in)
+			wantFields(t, err, tt.fields)
+		})
+	}
+}
+
+func TestFlowUpdateReplacesNodesAndRemapsDiagram(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+
+	diagram := `{"nodes":[{"id":"node-1"},{"id":"node-2"}],"edges":[{"source":"node-1","target":"node-2"}]}`
+	updated, err := e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{
+		Title:       "F",
+		DiagramJSON: diagram,
+		Revision:    ptr(flow.Revision),
+		Nodes: []service.FlowNodeInput{
+			{ID: "node-1", Name: "Draft", DurationMin: ptr(1.0), DurationMax: ptr(2.0)},
+			{ID: "node-2", Name: "Review", DurationMin: ptr(1.0), DurationUnit: domain.DurationUnitWeek},
+		},
+	})
+	mustNoErr(t, err)
+
+	detail, err := e.flows.GetDetail(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if len(detail.Nodes) != 2 || detail.Nodes[0].Name != "Draft" || detail.Nodes[1].SortOrder != 1 {
+		t.Fatalf("got nodes %+v", detail.Nodes)
+	}
+	if detail.Flow.Revision != updated.Revision {
+		t.Fatalf("got revision %d, want %d", detail.Flow.Revision, updated.Revision)
+	}
+
+	var d struct {
+		Nodes []struct{ ID string }
+		Edges []struct{ Source, Target string }
+	}
+	mustNoErr(t, json.Unmarshal([]byte(detail.Flow.DiagramJSON), &d))
+	if d.Nodes[0].ID != detail.Nodes[0].ID.String() || d.Edges[0].Target != detail.Nodes[1].ID.String() {
+		t.Fatalf("diagram %s does not reference the saved node IDs", detail.Flow.DiagramJSON)
+	}
+
+	wantMin := 1 + domain.DurationUnitWeek.ToDays(1)
+	if detail.TotalDurationMinDays != wantMin || detail.TotalDurationMaxDays != 2+domain.DurationUnitWeek.ToDays(1) {
+		t.Fatalf("got totals %v–%v", detail.TotalDurationMinDays, detail.TotalDurationMaxDays)
+	}
+}
+
+func TestFlowUpdateRevision(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+
+	_, err := e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{Title: "F2"})
+	wantErr(t, err, domain.ErrPreconditionRequired)
+
+	_, err = e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{Title: "F2", Revision: ptr(flow.Revision)})
+	mustNoErr(t, err)
+
+	_, err = e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{Title: "F3", Revision: ptr(flow.Revision)})
+	var stale *domain.StaleRevisionError
+	if !errors.As(err, &stale) {
+		t.Fatalf("got error %v, want StaleRevisionError", err)
+	}
+	if current := stale.Current.(*service.FlowDetail); current.Flow.Title != "F2" {
+		t.Fatalf("stale error carries title %q, want F2", current.Flow.Title)
+	}
+}
+
+func TestFlowLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+
+	_, err := e.flows.Publish(e.ctx, owner, flow.ID)
+	var terr *domain.TransitionError
+	if !errors.As(err, &terr) || terr.From != string(domain.FlowStatusDraft) {
+		t.Fatalf("publishing a draft: got %v, want a TransitionError from DRAFT", err)
+	}
+
+	inReview, err := e.flows.SubmitReview(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	_, err = e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{Title: "X", Revision: ptr(inReview.Revision)})
+	wantErr(t, err, domain.ErrInvalidState)
+
+	rejected, err := e.flows.Reject(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if rejected.Status != domain.FlowStatusDraft {
+		t.Fatalf("got status %s after reject", rejected.Status)
+	}
+
+	effective := publish(t, e, owner, flow.ID)
+	if effective.Status != domain.FlowStatusEffective || effective.LatestVersionID == nil {
+		t.Fatalf("got status %s latest %v after publish", effective.Status, effective.LatestVersionID)
+	}
+
+	draft, err := e.flows.NewDraft(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if draft.Status != domain.FlowStatusDraft || draft.LatestVersionID == nil {
+		t.Fatalf("new draft should keep the published version, got %+v", draft)
+	}
+}
+
+func TestFlowPublishSnapshotsNodes(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+	_, err := e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{
+		Title: "Published title", Revision: ptr(flow.Revision),
+		Nodes: []service.FlowNodeInput{{Name: "A"}, {Name: "B"}},
+	})
+	mustNoErr(t, err)
+	published := publish(t, e, owner, flow.ID)
+
+	v, err := e.flows.GetVersion(e.ctx, owner, flow.ID, *published.LatestVersionID)
+	mustNoErr(t, err)
+	snap := v.Snapshot
+	if snap == nil || snap.SchemaVersion != domain.FlowSnapshotSchema || snap.Flow.Title != "Published title" {
+		t.Fatalf("got snapshot %+v", snap)
+	}
+	if len(snap.Nodes) != 2 || snap.Nodes[0].Name != "A" || snap.Nodes[1].Name != "B" {
+		t.Fatalf("got snapshot nodes %+v", snap.Nodes)
+	}
+
+	versions, err := e.flows.ListVersions(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if len(versions) != 1 || versions[0].ID != v.ID {
+		t.Fatalf("got %d versions, want the published one", len(versions))
+	}
+}
+
+func TestFlowRestoreVersion(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	flow := createFlow(t, e, owner, "F")
+
+	save := func(title string, nodes ...string) {
+		t.Helper()
+		current, err := e.flows.GetDetail(e.ctx, owner, flow.ID)
+		mustNoErr(t, err)
+		in := service.UpdateFlowInput{Title: title, Revision: ptr(current.Flow.Revision)}
+		for _, n := range nodes {
+			in.Nodes = append(in.Nodes, service.FlowNodeInput{Name: n})
+		}
+		_, err = e.flows.Update(e.ctx, owner, flow.ID, in)
+		mustNoErr(t, err)
+	}
+
+	save("V1", "a")
+	v1 := *publish(t, e, owner, flow.ID).LatestVersionID
+	_, err := e.flows.NewDraft(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	save("V2", "b", "c")
+	publish(t, e, owner, flow.ID)
+
+	// Users who cannot edit the flow learn nothing about its versions.
+	viewer := e.user(t, "viewer@example.com")
+	e.share(t, owner, domain.ShareResourceFlow, flow.ID, viewer, domain.ShareRoleView)
+	_, err = e.flows.RestoreVersion(e.ctx, viewer, flow.ID, v1)
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.flows.RestoreVersion(e.ctx, viewer, flow.ID, uuid.New())
+	wantErr(t, err, domain.ErrForbidden)
+
+	restored, err := e.flows.RestoreVersion(e.ctx, owner, flow.ID, v1)
+	mustNoErr(t, err)
+	if restored.Status != domain.FlowStatusEffective || restored.Title != "V1" {
+		t.Fatalf("got %s %q after restore", restored.Status, restored.Title)
+	}
+	detail, err := e.flows.GetDetail(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if len(detail.Nodes) != 1 || detail.Nodes[0].Name != "a" {
+		t.Fatalf("got nodes %+v after restore", detail.Nodes)
+	}
+
+	latest, err := e.flows.GetVersion(e.ctx, owner, flow.ID, *restored.LatestVersionID)
+	mustNoErr(t, err)
+	if latest.RestoredFromVersionID == nil || *latest.RestoredFromVersionID != v1 {
+		t.Fatalf("restored version does not point at %s", v1)
+	}
+	versions, err := e.flows.ListVersions(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	if len(versions) != 3 {
+		t.Fatalf("got %d versions, want 3", len(versions))
+	}
+
+	// Restoring is only possible from EFFECTIVE.
+	_, err = e.flows.NewDraft(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	_, err = e.flows.RestoreVersion(e.ctx, owner, flow.ID, v1)
+	wantErr(t, err, domain.ErrInvalidState)
+}
+
+// TestFlowNewDraftKeepsPublishedVisible reopens a published flow: users
+// outside the draft keep reading the published version until the next publish.
+func TestFlowNewDraftKeepsPublishedVisible(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	viewer := e.user(t, "viewer@example.com")
+	stranger := e.user(t, "stranger@example.com")
+	flow := createFlow(t, e, owner, "Onboarding")
+	e.share(t, owner, domain.ShareResourceFlow, flow.ID, viewer, domain.ShareRoleView)
+	_, err := e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{
+		Title: "Onboarding", Revision: ptr(flow.Revision),
+		Nodes: []service.FlowNodeInput{{Name: "Welcome", DurationMin: ptr(1.0), DurationMax: ptr(1.0)}},
+	})
+	mustNoErr(t, err)
+	published := publish(t, e, owner, flow.ID)
+
+	draft, err := e.flows.NewDraft(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	_, err = e.flows.Update(e.ctx, owner, flow.ID, service.UpdateFlowInput{
+		Title: "Onboarding v2", Revision: ptr(draft.Revision),
+		Nodes: []service.FlowNodeInput{{Name: "Welcome"}, {Name: "Tour"}},
+	})
+	mustNoErr(t, err)
+
+	// The stranger has no share but still reads the published version.
+	detail, err := e.flows.GetDetail(e.ctx, stranger, flow.ID)
+	mustNoErr(t, err)
+	if detail.Flow.Title != "Onboarding" || detail.Flow.Status != domain.FlowStatusEffective ||
+		detail.Flow.LatestVersionID == nil || *detail.Flow.LatestVersionID != *published.LatestVersionID {
+		t.Fatalf("stranger got flow %+v, want the published version", detail.Flow)
+	}
+	if len(detail.Nodes) != 1 || detail.Nodes[0].Name != "Welcome" || detail.TotalDurationMinDays != 1 {
+		t.Fatalf("stranger got nodes %+v", detail.Nodes)
+	}
+	list, err := e.flows.List(e.ctx, stranger)
+	mustNoErr(t, err)
+	if len(list) != 1 || list[0].Title != "Onboarding" || list[0].Status != domain.FlowStatusEffective {
+		t.Fatalf("stranger lists %+v", list)
+	}
+	_, err = e.flows.ListVersions(e.ctx, stranger, flow.ID)
+	mustNoErr(t, err)
+
+	// Users in on the draft see the work in progress.
+	for _, userID := range []uuid.UUID{owner, viewer} {
+		detail, err := e.flows.GetDetail(e.ctx, userID, flow.ID)
+		mustNoErr(t, err)
+		if detail.Flow.Title != "Onboarding v2" || detail.Flow.Status != domain.FlowStatusDraft || len(detail.Nodes) != 2 {
+			t.Fatalf("got %s %s with %d nodes, want the draft", detail.Flow.Title, detail.Flow.Status, len(detail.Nodes))
+		}
+	}
+
+	// In review, still the published version; after publishing, the new one.
+	_, err = e.flows.SubmitReview(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	detail, err = e.flows.GetDetail(e.ctx, stranger, flow.ID)
+	mustNoErr(t, err)
+	if detail.Flow.Title != "Onboarding" {
+		t.Fatalf("stranger sees %q while in review", detail.Flow.Title)
+	}
+	_, err = e.flows.Publish(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	detail, err = e.flows.GetDetail(e.ctx, stranger, flow.ID)
+	mustNoErr(t, err)
+	if detail.Flow.Title != "Onboarding v2" || len(detail.Nodes) != 2 {
+		t.Fatalf("stranger sees %q with %d nodes after republishing", detail.Flow.Title, len(detail.Nodes))
+	}
+}
+
+func TestFlowAccess(t *testing.T) {
+	e := newTestEnv(t)
+	users := map[string]uuid.UUID{}
+	for _, name := range []string{"owner", "viewer", "editor", "stranger"} {
+		users[name] = e.user(t, name+"@example.com")
+	}
+	owner := users["owner"]
+	draft := createFlow(t, e, owner, "Draft")
+	effective := createFlow(t, e, owner, "Effective")
+	publish(t, e, owner, effective.ID)
+	e.share(t, owner, domain.ShareResourceFlow, draft.ID, users["viewer"], domain.ShareRoleView)
+	e.share(t, owner, domain.ShareResourceFlow, draft.ID, users["editor"], domain.ShareRoleEdit)
+
+	tests := []struct {
+		name      string
+		flow      *domain.Flow
+		user      string
+		canRead   bool
+		canSubmit bool
+	}{
+		{"owner", draft, "owner", true, true},
+		{"view share", draft, "viewer", true, false},
+		{"edit share", draft, "editor", true, true},
+		{"stranger on draft", draft, "stranger", false, false},
+		{"stranger on effective", effective, "stranger", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := users[tt.user]
+			_, err := e.flows.GetDetail(e.ctx, userID, tt.flow.ID)
+			if tt.canRead {
+				mustNoErr(t, err)
+			} else {
+				wantErr(t, err, domain.ErrForbidden)
+			}
+
+			_, err = e.flows.SubmitReview(e.ctx, userID, tt.flow.ID)
+			if !tt.canSubmit {
+				wantErr(t, err, domain.ErrForbidden)
+				return
+			}
+			mustNoErr(t, err)
+			_, err = e.flows.Reject(e.ctx, userID, tt.flow.ID)
+			mustNoErr(t, err)
+		})
+	}
+
+	visible, err := e.flows.List(e.ctx, users["stranger"])
+	mustNoErr(t, err)
+	if len(visible) != 1 || visible[0].ID != effective.ID {
+		t.Fatalf("stranger sees %d flows, want only the effective one", len(visible))
+	}
+
+	wantErr(t, e.flows.Delete(e.ctx, users["editor"], draft.ID), domain.ErrForbidden)
+	mustNoErr(t, e.flows.Delete(e.ctx, owner, draft.ID))
+	_, err = e.flows.GetDetail(e.ctx, users["viewer"], draft.ID)
+	wantErr(t, err, domain.ErrForbidden)
+}
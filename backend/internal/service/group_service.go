@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// GroupService manages groups and their members. Any user may create a
+// group and becomes its owner. Owners and group admins manage members; only
+// owners rename or delete the group and grant or revoke ownership. System
+// ADMINs may do all of this on any group, including groups provisioned
+// through SCIM, which have no owner.
+type GroupService struct {
+	txm       repository.TxManager
+	groupRepo repository.GroupRepository
+	userRepo  repository.UserRepository
+}
+
+func NewGroupService(txm repository.TxManager, groupRepo repository.GroupRepository, userRepo repository.UserRepository) *GroupService {
+	return &GroupService{txm: txm, groupRepo: groupRepo, userRepo: userRepo}
+}
+
+type GroupInput struct {
+	Name string `json:"name"`
+}
+
+// AddGroupMemberInput identifies the new member by ID or by email. Role
+// defaults to MEMBER.
+type AddGroupMemberInput struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	Role   string    `json:"role"`
+}
+
+type UpdateGroupMemberInput struct {
+	Role string `json:"role"`
+}
+
+// GroupMemberInfo is a membership with the member's name for display.
+type GroupMemberInfo struct {
+	domain.GroupMember
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+}
+
+type GroupDetail struct {
+	Group   domain.Group      `json:"group"`
+	Members []GroupMemberInfo `json:"members"`
+}
+
+// List returns every group, so that users can find the group to share with.
+func (s *GroupService) List(ctx context.Context) ([]domain.Group, error) {
+	return s.groupRepo.List(ctx)
+}
+
+func (s *GroupService) Get(ctx context.Context, groupID uuid.UUID) (*GroupDetail, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupRepo.ListMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.userRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]domain.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	detail := &GroupDetail{Group: *group, Members: make([]GroupMemberInfo, 0, len(members))}
+	for _, m := range members {
+		u := byID[m.UserID]
+		detail.Members = append(detail.Members, GroupMemberInfo{GroupMember: m, Email: u.Email, DisplayName: u.DisplayName})
+	}
+	return detail, nil
+}
+
+// Create adds a group owned by the caller.
+func (s *GroupService) Create(ctx context.Context, userID uuid.UUID, in GroupInput) (*domain.Group, error) {
+	name, err := groupName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	group := &domain.Group{Name: name}
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.AddMember(ctx, group.ID, userID, domain.GroupRoleOwner); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Update renames a group. Owners only.
+func (s *GroupService) Update(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID uuid.UUID, in GroupInput) (*domain.Group, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.require(ctx, userID, isAdmin, groupID, domain.GroupRoleOwner); err != nil {
+		return nil, err
+	}
+	name, err := groupName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	group.Name = name
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Delete removes a group together with its memberships and the shares made
+// with it. Owners only.
+func (s *GroupService) Delete(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID uuid.UUID) error {
+	if _, err := s.groupRepo.GetByID(ctx, groupID); err != nil {
+		return err
+	}
+	if err := s.require(ctx, userID, isAdmin, groupID, domain.GroupRoleOwner); err != nil {
+		return err
+	}
+	return s.groupRepo.Delete(ctx, groupID)
+}
+
+// AddMember adds a user to a group. Owners and admins may add members and
+// admins; only owners may add owners.
+func (s *GroupService) AddMember(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID uuid.UUID, in AddGroupMemberInput) (*GroupMemberInfo, error) {
+	if _, err := s.groupRepo.GetByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+	role, err := parseGroupRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.require(ctx, userID, isAdmin, groupID, grantingRole(role)); err != nil {
+		return nil, err
+	}
+
+	target, err := lookupUser(ctx, s.userRepo, "user_id", in.UserID, in.Email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.groupRepo.GetMember(ctx, groupID, target.ID); err == nil {
+		return nil, fmt.Errorf("%w: user is already a member", domain.ErrAlreadyExists)
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if err := s.groupRepo.AddMember(ctx, groupID, target.ID, role); err != nil {
+		return nil, err
+	}
+	member, err := s.groupRepo.GetMember(ctx, groupID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupMemberInfo{GroupMember: *member, Email: target.Email, DisplayName: target.DisplayName}, nil
+}
+
+// UpdateMember changes a member's role. Changing an owner's role, or making
+// someone an owner, takes an owner; the last owner cannot step down.
+func (s *GroupService) UpdateMember(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID, memberID uuid.UUID, in UpdateGroupMemberInput) (*domain.GroupMember, error) {
+	role, err := parseGroupRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.groupRepo.GetMember(ctx, groupID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	needed := grantingRole(role)
+	if member.Role == domain.GroupRoleOwner {
+		needed = domain.GroupRoleOwner
+	}
+	if err := s.require(ctx, userID, isAdmin, groupID, needed); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if role != domain.GroupRoleOwner {
+		if err := s.keepAnOwner(ctx, tx, groupID, memberID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.groupRepo.UpdateMemberRoleTx(ctx, tx, groupID, memberID, role); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	member.Role = role
+	return member, nil
+}
+
+// RemoveMember takes a user out of a group. Members may always leave;
+// removing someone else takes an admin, and removing an owner an owner. The
+// last owner cannot leave.
+func (s *GroupService) RemoveMember(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID, memberID uuid.UUID) error {
+	member, err := s.groupRepo.GetMember(ctx, groupID, memberID)
+	if err != nil {
+		return err
+	}
+	if memberID != userID {
+		needed := domain.GroupRoleAdmin
+		if member.Role == domain.GroupRoleOwner {
+			needed = domain.GroupRoleOwner
+		}
+		if err := s.require(ctx, userID, isAdmin, groupID, needed); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := s.keepAnOwner(ctx, tx, groupID, memberID); err != nil {
+		return err
+	}
+	if err := s.groupRepo.RemoveMemberTx(ctx, tx, groupID, memberID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---------- Internal ----------
+
+// require returns ErrForbidden unless the caller is a system ADMIN or holds
+// at least the given role in the group.
+func (s *GroupService) require(ctx context.Context, userID uuid.UUID, isAdmin bool, groupID uuid.UUID, role domain.GroupRole) error {
+	if isAdmin {
+		return nil
+	}
+	member, err := s.groupRepo.GetMember(ctx, groupID, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if member.Role == domain.GroupRoleOwner || (member.Role == domain.GroupRoleAdmin && role != domain.GroupRoleOwner) {
+		return nil
+	}
+	return domain.ErrForbidden
+}
+
+// keepAnOwner returns ErrInvalidState when memberID, who is about to step
+// down or leave, is the group's only owner. The owners stay locked until tx
+// ends, so concurrent changes to them cannot all pass this check.
+func (s *GroupService) keepAnOwner(ctx context.Context, tx repository.Tx, groupID, memberID uuid.UUID) error {
+	owners, err := s.groupRepo.ListOwnersTx(ctx, tx, groupID)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == memberID {
+		return fmt.Errorf("%w: a group needs at least one owner", domain.ErrInvalidState)
+	}
+	return nil
+}
+
+// grantingRole is the role needed to give a member the given role: owners
+// make owners, admins make everyone else.
+func grantingRole(role domain.GroupRole) domain.GroupRole {
+	if role == domain.GroupRoleOwner {
+		return domain.GroupRoleOwner
+	}
+	return domain.GroupRoleAdmin
+}
+
+func parseGroupRole(role string) (domain.GroupRole, error) {
+	r := domain.GroupRole(role)
+	if r == "" {
+		return domain.GroupRoleMember, nil
+	}
+	if !r.Valid() {
+		return "", domain.NewValidationError(map[string]string{"role": "invalid_enum"})
+	}
+	return r, nil
+}
+
+func groupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", domain.NewValidationError(map[string]string{"name": "required"})
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		return "", domain.NewValidationError(map[string]string{"name": "too_long"})
+	}
+	return name, nil
+}
@@ -0,0 +1,147 @@
+package service_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+func TestGroupCreateAndRename(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.user(t, "alice@example.com")
+	bob := e.user(t, "bob@example.com")
+
+	_, err := e.groupSvc.Create(e.ctx, alice, service.GroupInput{Name: "  "})
+	wantFields(t, err, map[string]string{"name": "required"})
+	group, err := e.groupSvc.Create(e.ctx, alice, service.GroupInput{Name: " Finance "})
+	mustNoErr(t, err)
+	_, err = e.groupSvc.Create(e.ctx, bob, service.GroupInput{Name: "Finance"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+
+	// The creator owns the group.
+	detail, err := e.groupSvc.Get(e.ctx, group.ID)
+	mustNoErr(t, err)
+	if detail.Group.Name != "Finance" || len(detail.Members) != 1 || detail.Members[0].UserID != alice ||
+		detail.Members[0].Role != domain.GroupRoleOwner || detail.Members[0].Email != "alice@example.com" {
+		t.Fatalf("got group %+v", detail)
+	}
+
+	_, err = e.groupSvc.Update(e.ctx, bob, false, group.ID, service.GroupInput{Name: "Money"})
+	wantErr(t, err, domain.ErrForbidden)
+	renamed, err := e.groupSvc.Update(e.ctx, alice, false, group.ID, service.GroupInput{Name: "Money"})
+	mustNoErr(t, err)
+	if renamed.Name != "Money" {
+		t.Fatalf("got group %+v after rename", renamed)
+	}
+
+	// System admins manage any group.
+	mustNoErr(t, e.groupSvc.Delete(e.ctx, bob, true, group.ID))
+	_, err = e.groupSvc.Get(e.ctx, group.ID)
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+func TestGroupMemberRoles(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	admin := e.user(t, "admin@example.com")
+	member := e.user(t, "member@example.com")
+	newcomer := e.user(t, "newcomer@example.com")
+	group, err := e.groupSvc.Create(e.ctx, owner, service.GroupInput{Name: "Ops"})
+	mustNoErr(t, err)
+
+	added, err := e.groupSvc.AddMember(e.ctx, owner, false, group.ID, service.AddGroupMemberInput{Email: "admin@example.com", Role: "ADMIN"})
+	mustNoErr(t, err)
+	if added.UserID != admin || added.Role != domain.GroupRoleAdmin || added.Email != "admin@example.com" {
+		t.Fatalf("got member %+v", added)
+	}
+	_, err = e.groupSvc.AddMember(e.ctx, admin, false, group.ID, service.AddGroupMemberInput{UserID: member})
+	mustNoErr(t, err)
+	_, err = e.groupSvc.AddMember(e.ctx, admin, false, group.ID, service.AddGroupMemberInput{UserID: member})
+	wantErr(t, err, domain.ErrAlreadyExists)
+	_, err = e.groupSvc.AddMember(e.ctx, admin, false, group.ID, service.AddGroupMemberInput{Email: "ghost@example.com"})
+	wantFields(t, err, map[string]string{"user_id": "user_not_found"})
+	_, err = e.groupSvc.AddMember(e.ctx, admin, false, group.ID, service.AddGroupMemberInput{UserID: newcomer, Role: "KING"})
+	wantFields(t, err, map[string]string{"role": "invalid_enum"})
+
+	// Plain members manage nothing; admins manage everyone but owners.
+	_, err = e.groupSvc.AddMember(e.ctx, member, false, group.ID, service.AddGroupMemberInput{UserID: newcomer})
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.groupSvc.AddMember(e.ctx, admin, false, group.ID, service.AddGroupMemberInput{UserID: newcomer, Role: "OWNER"})
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.groupSvc.UpdateMember(e.ctx, admin, false, group.ID, owner, service.UpdateGroupMemberInput{Role: "MEMBER"})
+	wantErr(t, err, domain.ErrForbidden)
+	wantErr(t, e.groupSvc.RemoveMember(e.ctx, admin, false, group.ID, owner), domain.ErrForbidden)
+	wantErr(t, e.groupSvc.RemoveMember(e.ctx, member, false, group.ID, admin), domain.ErrForbidden)
+	_, err = e.groupSvc.Update(e.ctx, admin, false, group.ID, service.GroupInput{Name: "Ops 2"})
+	wantErr(t, err, domain.ErrForbidden)
+	wantErr(t, e.groupSvc.Delete(e.ctx, admin, false, group.ID), domain.ErrForbidden)
+
+	// The last owner stays until someone else owns the group.
+	_, err = e.groupSvc.UpdateMember(e.ctx, owner, false, group.ID, owner, service.UpdateGroupMemberInput{Role: "ADMIN"})
+	wantErr(t, err, domain.ErrInvalidState)
+	wantErr(t, e.groupSvc.RemoveMember(e.ctx, owner, false, group.ID, owner), domain.ErrInvalidState)
+	updated, err := e.groupSvc.UpdateMember(e.ctx, owner, false, group.ID, admin, service.UpdateGroupMemberInput{Role: "OWNER"})
+	mustNoErr(t, err)
+	if updated.Role != domain.GroupRoleOwner {
+		t.Fatalf("got member %+v after promotion", updated)
+	}
+	mustNoErr(t, e.groupSvc.RemoveMember(e.ctx, owner, false, group.ID, owner))
+
+	// Members may leave on their own.
+	mustNoErr(t, e.groupSvc.RemoveMember(e.ctx, member, false, group.ID, member))
+	wantErr(t, e.groupSvc.RemoveMember(e.ctx, admin, false, group.ID, member), domain.ErrNotFound)
+
+	detail, err := e.groupSvc.Get(e.ctx, group.ID)
+	mustNoErr(t, err)
+	if len(detail.Members) != 1 || detail.Members[0].UserID != admin {
+		t.Fatalf("got members %+v, want only the new owner", detail.Members)
+	}
+}
+
+// Two owners stepping down at the same time must not leave the group
+// without one: the second to commit sees the first's change.
+func TestGroupConcurrentOwnerChanges(t *testing.T) {
+	e := newTestEnv(t)
+	ann := e.user(t, "ann@example.com")
+	bob := e.user(t, "bob@example.com")
+	group, err := e.groupSvc.Create(e.ctx, ann, service.GroupInput{Name: "Ops"})
+	mustNoErr(t, err)
+	_, err = e.groupSvc.AddMember(e.ctx, ann, false, group.ID, service.AddGroupMemberInput{UserID: bob, Role: "OWNER"})
+	mustNoErr(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = e.groupSvc.UpdateMember(e.ctx, ann, false, group.ID, ann, service.UpdateGroupMemberInput{Role: "MEMBER"})
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = e.groupSvc.RemoveMember(e.ctx, bob, false, group.ID, bob)
+	}()
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if errors.Is(err, domain.ErrInvalidState) {
+			failed++
+		} else {
+			mustNoErr(t, err)
+		}
+	}
+	detail, err := e.groupSvc.Get(e.ctx, group.ID)
+	mustNoErr(t, err)
+	owners := 0
+	for _, m := range detail.Members {
+		if m.Role == domain.GroupRoleOwner {
+			owners++
+		}
+	}
+	if failed != 1 || owners != 1 {
+		t.Fatalf("%d of the two changes refused, %d owners left; want 1 and 1", failed, owners)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"docmv/internal/domain"
+	"docmv/internal/ldap"
+	"docmv/internal/repository"
+)
+
+// ldapProvider names the directory in user_identities.
+const ldapProvider = "ldap"
+
+// LDAPOptions configures how directory entries map to users.
+type LDAPOptions struct {
+	AdminGroups []string // DNs of groups whose members are ADMIN; empty leaves roles to admins
+}
+
+// LDAPAuthenticator accepts the passwords of directory users. An entry is
+// linked to a user on its first login, to the user with the same email or
+// else to one created for it, and the link is followed from then on.
+//
+// The directory is the source of truth for its users: every login sets the
+// user's active flag from the entry's account flags, and with admin groups
+// configured their role from the groups they belong to.
+type LDAPAuthenticator struct {
+	dir          *ldap.Directory
+	identityRepo repository.UserIdentityRepository
+	userRepo     repository.UserRepository
+	opts         LDAPOptions
+}
+
+func NewLDAPAuthenticator(dir *ldap.Directory, identityRepo repository.UserIdentityRepository, userRepo repository.UserRepository, opts LDAPOptions) *LDAPAuthenticator {
+	return &LDAPAuthenticator{dir: dir, identityRepo: identityRepo, userRepo: userRepo, opts: opts}
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, login, password string) (*domain.User, error) {
+	entry, err := a.dir.Authenticate(ctx, login, password)
+	switch {
+	case errors.Is(err, ldap.ErrNoEntry):
+		return nil, fmt.Errorf("%w: no directory entry for %s", domain.ErrNotFound, login)
+	case errors.Is(err, ldap.ErrInvalidCredentials):
+		// Directories refuse binds of disabled accounts, so this is where
+		// most of them are noticed.
+		if entry != nil && entry.Disabled {
+			a.deactivate(ctx, entry)
+		}
+		return nil, fmt.Errorf("%w: invalid credentials", domain.ErrUnauthorized)
+	case err != nil:
+		return nil, fmt.Errorf("checking password with the directory: %w", err)
+	}
+
+	user, err := a.linkedUser(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	if active := !entry.Disabled; active != user.Active {
+		if err := a.userRepo.SetActive(ctx, user.ID, active); err != nil {
+			return nil, err
+		}
+		log.Printf("[ldap] %s set to active=%v by the directory", user.Email, active)
+		user.Active = active
+	}
+	if role, ok := a.mappedRole(entry); ok && role != user.Role {
+		if err := a.userRepo.UpdateRole(ctx, user.ID, role); err != nil {
+			return nil, err
+		}
+		log.Printf("[ldap] role of %s set to %s by directory groups", user.Email, role)
+		user.Role = role
+	}
+	return user, nil
+}
+
+// ---------- Internal ----------
+
+// linkedUser returns the user linked to entry, linking or creating one on
+// its first login.
+func (a *LDAPAuthenticator) linkedUser(ctx context.Context, entry *ldap.Entry) (*domain.User, error) {
+	identity, err := a.identityRepo.Get(ctx, ldapProvider, entry.ID)
+	if err == nil {
+		return a.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	if entry.Email == "" {
+		return nil, fmt.Errorf("%w: the directory has no email address for this account", domain.ErrForbidden)
+	}
+	// Directory addresses are set by its admins, so unlike a provider's
+	// they may take over a local account of the same address.
+	user, err := a.userRepo.GetByEmail(ctx, entry.Email)
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrNotFound):
+		if entry.Disabled {
+			return nil, fmt.Errorf("%w: account deactivated", domain.ErrForbidden)
+		}
+		user = &domain.User{
+			Email: entry.Email,
+			// No password: the directory checks it.
+			DisplayName: truncateRunes(entry.DisplayName, 100),
+			Role:        domain.RoleUser,
+		}
+		if role, ok := a.mappedRole(entry); ok {
+			user.Role = role
+		}
+		if err := a.userRepo.Create(ctx, user); err != nil {
+			return nil, err
+		}
+		log.Printf("[ldap] created user %s for %s", user.Email, entry.DN)
+	default:
+		return nil, err
+	}
+
+	err = a.identityRepo.Create(ctx, &domain.UserIdentity{UserID: user.ID, Provider: ldapProvider, Subject: entry.ID})
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return nil, fmt.Errorf("%w: %s is already linked to another directory account", domain.ErrForbidden, entry.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// deactivate marks the user linked to a disabled entry as inactive.
+func (a *LDAPAuthenticator) deactivate(ctx context.Context, entry *ldap.Entry) {
+	identity, err := a.identityRepo.Get(ctx, ldapProvider, entry.ID)
+	if err != nil {
+		return
+	}
+	user, err := a.userRepo.GetByID(ctx, identity.UserID)
+	if err != nil || !user.Active {
+		return
+	}
+	if err := a.userRepo.SetActive(ctx, user.ID, false); err != nil {
+		log.Printf("[ldap] deactivating %s failed: %v", user.Email, err)
+		return
+	}
+	log.Printf("[ldap] %s set to active=false by the directory", user.Email)
+}
+
+// mappedRole returns the role the entry's groups give, if admin groups are
+// configured.
+func (a *LDAPAuthenticator) mappedRole(entry *ldap.Entry) (domain.Role, bool) {
+	if len(a.opts.AdminGroups) == 0 {
+		return "", false
+	}
+	for _, g := range a.opts.AdminGroups {
+		if entry.MemberOf(g) {
+			return domain.RoleAdmin, true
+		}
+	}
+	return domain.RoleUser, true
+}
@@ -0,0 +1,177 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/ldap"
+	"docmv/internal/ldap/ldaptest"
+	"docmv/internal/service"
+)
+
+const (
+	ldapBase    = "dc=example,dc=com"
+	ldapService = "cn=docmv,dc=example,dc=com"
+	ldapAdmins  = "cn=docmv-admins,ou=groups,dc=example,dc=com"
+)
+
+// newLDAP starts a local directory and an AuthService checking passwords
+// against it after the local ones.
+func (e *testEnv) newLDAP(t *testing.T, opts service.LDAPOptions) (*service.AuthService, *ldaptest.Server) {
+	t.Helper()
+	srv, err := ldaptest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	srv.Add(ldapBase, "", map[string][]string{"objectClass": {"domain"}})
+	srv.Add(ldapService, "service-secret", map[string][]string{"objectClass": {"applicationProcess"}})
+
+	dir := ldap.New(ldap.Config{
+		URL:          srv.URL,
+		BindDN:       ldapService,
+		BindPassword: "service-secret",
+		BaseDN:       ldapBase,
+		UserFilter:   "(&(objectClass=inetOrgPerson)(|(uid={login})(mail={login})))",
+		IDAttr:       "entryUUID",
+		EmailAttr:    "mail",
+		NameAttr:     "displayName",
+		GroupAttr:    "memberOf",
+	})
+	guard := service.NewLoginGuard(e.throttles, testLockout)
+	auth := service.NewAuthService(e.users, e.tokens, e.history, guard, testPasswordPolicy(), e.twoFactor,
+		e.signer, 15*time.Minute, time.Hour, service.NewLDAPAuthenticator(dir, e.identities, e.users, opts))
+	return auth, srv
+}
+
+// addPerson adds a directory user uid with the given groups.
+func addPerson(srv *ldaptest.Server, uid, password string, groups ...string) string {
+	dn := "uid=" + uid + ",ou=people," + ldapBase
+	srv.Add(dn, password, map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {uid},
+		"mail":        {uid + "@example.com"},
+		"displayName": {"User " + uid},
+		"entryUUID":   {"uuid-" + uid},
+		"memberOf":    groups,
+	})
+	return dn
+}
+
+func TestLDAPProvisionsAndSyncs(t *testing.T) {
+	e := newTestEnv(t)
+	auth, srv := e.newLDAP(t, service.LDAPOptions{AdminGroups: []string{ldapAdmins}})
+	dn := addPerson(srv, "hana", "dir-secret", ldapAdmins)
+
+	res, err := auth.Login(e.ctx, "hana", "dir-secret", "")
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if res.AuthResult == nil || res.User.Email != "hana@example.com" || res.User.DisplayName != "User hana" || res.User.Role != domain.RoleAdmin {
+		t.Fatalf("first login = %+v", res.AuthResult)
+	}
+	id := res.User.ID
+	_, err = auth.Login(e.ctx, "hana", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	// The role follows the groups on every login, and the link survives a
+	// new address.
+	srv.Set(dn, "memberOf")
+	srv.Set(dn, "mail", "hana.new@example.com")
+	res, err = auth.Login(e.ctx, "hana", "dir-secret", "")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if res.User.ID != id || res.User.Role != domain.RoleUser {
+		t.Fatalf("second login = %+v, want the same user as USER", res.User)
+	}
+
+	// So does the active flag: a disabled account is deactivated and
+	// refused, and reactivated once enabled again.
+	srv.Set(dn, "nsAccountLock", "true")
+	_, err = auth.Login(e.ctx, "hana", "dir-secret", "")
+	wantErr(t, err, domain.ErrForbidden)
+	if u, _ := e.users.GetByID(e.ctx, id); u.Active {
+		t.Fatal("disabled directory account still active")
+	}
+	srv.Set(dn, "nsAccountLock")
+	if _, err := auth.Login(e.ctx, "hana.new@example.com", "dir-secret", ""); err != nil {
+		t.Fatalf("login after enabling: %v", err)
+	}
+}
+
+func TestLDAPDisabledBindRefused(t *testing.T) {
+	e := newTestEnv(t)
+	auth, srv := e.newLDAP(t, service.LDAPOptions{})
+	dn := addPerson(srv, "ivan", "dir-secret")
+	res, err := auth.Login(e.ctx, "ivan", "dir-secret", "")
+	mustNoErr(t, err)
+
+	// Active Directory refuses binds of disabled accounts; without a
+	// password the entry cannot bind here either.
+	srv.Add(dn, "", map[string][]string{
+		"objectClass": {"inetOrgPerson"}, "uid": {"ivan"}, "mail": {"ivan@example.com"},
+		"entryUUID": {"uuid-ivan"}, "userAccountControl": {"514"},
+	})
+	_, err = auth.Login(e.ctx, "ivan", "dir-secret", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	if u, _ := e.users.GetByID(e.ctx, res.User.ID); u.Active {
+		t.Fatal("disabled directory account still active")
+	}
+}
+
+func TestLDAPChain(t *testing.T) {
+	e := newTestEnv(t)
+	auth, srv := e.newLDAP(t, service.LDAPOptions{})
+	local, err := e.auth.CreateUser(e.ctx, "jane@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+
+	// Local passwords come first and keep working.
+	if _, err := auth.Login(e.ctx, "jane@example.com", "secret1", ""); err != nil {
+		t.Fatalf("local login: %v", err)
+	}
+	// A directory entry with the same address links to the local account,
+	// which keeps its role as no admin groups are configured.
+	addPerson(srv, "jane", "dir-secret")
+	res, err := auth.Login(e.ctx, "jane", "dir-secret", "")
+	if err != nil {
+		t.Fatalf("directory login: %v", err)
+	}
+	if res.User.ID != local.ID || res.User.Role != domain.RoleAdmin {
+		t.Fatalf("directory login = %+v, want the local account", res.User)
+	}
+	if _, err := auth.Login(e.ctx, "jane@example.com", "secret1", ""); err != nil {
+		t.Fatalf("local login after linking: %v", err)
+	}
+
+	// Unknown logins and wrong passwords are the same 401, and count as
+	// failures.
+	_, err = auth.Login(e.ctx, "nobody", "dir-secret", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = auth.Login(e.ctx, "jane", "wrong", "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	lockouts, err := auth.ListLockouts(e.ctx)
+	mustNoErr(t, err)
+	if len(lockouts) == 0 {
+		t.Fatal("failed directory logins were not counted")
+	}
+
+	// A directory that cannot be reached is an error, not a wrong password.
+	srv.Close()
+	_, err = auth.Login(e.ctx, "kim", "dir-secret", "")
+	if err == nil || errors.Is(err, domain.ErrUnauthorized) || errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("login with the directory down: %v, want an internal error", err)
+	}
+}
+
+func TestLDAPWithoutEmail(t *testing.T) {
+	e := newTestEnv(t)
+	auth, srv := e.newLDAP(t, service.LDAPOptions{})
+	dn := addPerson(srv, "leo", "dir-secret")
+	srv.Set(dn, "mail")
+
+	_, err := auth.Login(e.ctx, "leo", "dir-secret", "")
+	wantErr(t, err, domain.ErrForbidden)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+)
+
+// maxLoginDelay caps the progressive delay between failed logins.
+const maxLoginDelay = 30 * time.Second
+
+// LockoutPolicy configures how failed logins hold back further attempts.
+// From the second failure in a row on, the next attempt must wait Delay,
+// doubled with every further failure up to 30 seconds. Once MaxFailures is
+// reached, attempts are refused for Lockout. Failures are forgotten Lockout
+// after the most recent one.
+type LockoutPolicy struct {
+	MaxAccountFailures int // failures that lock an account; 0 disables per-account tracking
+	MaxIPFailures      int // failures that lock a client address; 0 disables per-address tracking
+	Lockout            time.Duration
+	Delay              time.Duration
+}
+
+// LoginGuard tracks failed logins per account and per client address.
+type LoginGuard struct {
+	repo   repository.LoginThrottleRepository
+	policy LockoutPolicy
+}
+
+func NewLoginGuard(repo repository.LoginThrottleRepository, policy LockoutPolicy) *LoginGuard {
+	return &LoginGuard{repo: repo, policy: policy}
+}
+
+// LoginLockout describes the failures recorded for one account or client
+// address, for admins.
+type LoginLockout struct {
+	domain.LoginThrottle
+	Locked       bool       `json:"locked"`        // the failure limit was reached
+	BlockedUntil *time.Time `json:"blocked_until"` // nil when the next attempt may be made now
+}
+
+const (
+	accountKeyPrefix = "account:"
+	ipKeyPrefix      = "ip:"
+)
+
+type throttleKey struct {
+	key         string
+	maxFailures int
+}
+
+// keys returns the throttles a login attempt counts against. Emails are
+// lower-cased so that case variants share one counter.
+func (g *LoginGuard) keys(email, ip string) []throttleKey {
+	var keys []throttleKey
+	if g.policy.MaxAccountFailures > 0 {
+		keys = append(keys, throttleKey{accountKeyPrefix + strings.ToLower(strings.TrimSpace(email)), g.policy.MaxAccountFailures})
+	}
+	if g.policy.MaxIPFailures > 0 && ip != "" {
+		keys = append(keys, throttleKey{ipKeyPrefix + ip, g.policy.MaxIPFailures})
+	}
+	return keys
+}
+
+// maxFailures returns the failure limit for a stored throttle key.
+func (g *LoginGuard) maxFailures(key string) int {
+	if strings.HasPrefix(key, ipKeyPrefix) {
+		return g.policy.MaxIPFailures
+	}
+	return g.policy.MaxAccountFailures
+}
+
+// blockedUntil returns when the next attempt against lt may be made.
+func (g *LoginGuard) blockedUntil(lt *domain.LoginThrottle, maxFailures int) time.Time {
+	if maxFailures > 0 && lt.Failures >= maxFailures {
+		return lt.LastFailureAt.Add(g.policy.Lockout)
+	}
+	if lt.Failures < 2 || g.policy.Delay <= 0 {
+		return time.Time{}
+	}
+	delay := min(g.policy.Delay<<min(lt.Failures-2, 16), maxLoginDelay, g.policy.Lockout)
+	return lt.LastFailureAt.Add(delay)
+}
+
+// Check returns a *domain.RateLimitError when the account or the client
+// address must wait before the next attempt. It is called before the
+// password is verified, so a refused attempt reveals nothing and is not
+// counted.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range g.keys(email, ip) {
+		lt, err := g.repo.Get(ctx, k.key)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		wait = max(wait, g.blockedUntil(lt, k.maxFailures).Sub(now))
+	}
+	if wait > 0 {
+		return &domain.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail counts a failed attempt against the account and the client address.
+// Errors are logged: they must not turn a wrong password into a server error.
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) {
+	now := time.Now()
+	for _, k := range g.keys(email, ip) {
+		n, err := g.repo.RecordFailure(ctx, k.key, now, now.Add(-g.policy.Lockout))
+		if err != nil {
+			log.Printf("[auth] recording failed login for %s: %v", k.key, err)
+			continue
+		}
+		if n == k.maxFailures {
+			log.Printf("[auth] %s locked for %s after %d failed logins", k.key, g.policy.Lockout, n)
+		}
+	}
+}
+
+// Succeed forgets the failures of an account after a successful login. The
+// client address keeps its count, so one good account does not unlock
+// guessing at others.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) {
+	for _, k := range g.keys(email, "") {
+		if err := g.repo.Delete(ctx, k.key); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			log.Printf("[auth] clearing failed logins for %s: %v", k.key, err)
+		}
+	}
+}
+
+// List returns every account and client address with failures that are not
+// yet forgotten, most recent first.
+func (g *LoginGuard) List(ctx context.Context) ([]LoginLockout, error) {
+	now := time.Now()
+	throttles, err := g.repo.List(ctx, now.Add(-g.policy.Lockout))
+	if err != nil {
+		return nil, err
+	}
+	lockouts := make([]LoginLockout, 0, len(throttles))
+	for _, lt := range throttles {
+		maxFailures := g.maxFailures(lt.Key)
+		l := LoginLockout{LoginThrottle: lt, Locked: maxFailures > 0 && lt.Failures >= maxFailures}
+		if until := g.blockedUntil(&lt, maxFailures); until.After(now) {
+			l.BlockedUntil = &until
+		}
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, nil
+}
+
+// Clear forgets the failures recorded under key, lifting any lockout.
+func (g *LoginGuard) Clear(ctx context.Context, key string) error {
+	return g.repo.Delete(ctx, key)
+}
+
+// DeleteStale removes failures that are already forgotten.
+func (g *LoginGuard) DeleteStale(ctx context.Context) (int64, error) {
+	return g.repo.DeleteStale(ctx, time.Now().Add(-g.policy.Lockout))
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/oidc"
+	"docmv/internal/repository"
+)
+
+// oidcLoginTTL is how long a user may take at the identity provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// OIDCOptions configures how identity provider accounts map to users.
+type OIDCOptions struct {
+	DisplayName string   // provider name shown on the login page
+	RoleClaim   string   // claim with the user's roles or groups; empty leaves roles to admins
+	AdminValues []string // values of RoleClaim that make a user ADMIN; any other value makes them USER
+	AutoCreate  bool     // create users on their first login instead of refusing them
+}
+
+// OIDCService logs users in through an OpenID Connect identity provider
+// (authorization code flow with PKCE). A provider account is linked to a
+// user on its first login: to the user with the same email if the provider
+// verified that email, or else to a user created for it. From then on the
+// link is followed, whatever the email becomes.
+//
+// With a role claim configured, every login sets the user's role from it,
+// so the provider decides who is an admin; tokens without the claim leave
+// the role alone. Two-factor authentication of linked users still applies.
+type OIDCService struct {
+	provider     *oidc.Provider // nil when single sign-on is not configured
+	loginRepo    repository.OIDCLoginRepository
+	identityRepo repository.UserIdentityRepository
+	userRepo     repository.UserRepository
+	auth         *AuthService
+	opts         OIDCOptions
+}
+
+func NewOIDCService(provider *oidc.Provider, loginRepo repository.OIDCLoginRepository, identityRepo repository.UserIdentityRepository, userRepo repository.UserRepository, auth *AuthService, opts OIDCOptions) *OIDCService {
+	return &OIDCService{provider: provider, loginRepo: loginRepo, identityRepo: identityRepo, userRepo: userRepo, auth: auth, opts: opts}
+}
+
+// OIDCInfo tells the login page whether to offer single sign-on.
+type OIDCInfo struct {
+	Enabled     bool   `json:"enabled"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// OIDCStart is where to send the browser to log in. State comes back with
+// the callback and ties it to the browser that started the login.
+type OIDCStart struct {
+	URL   string
+	State string
+}
+
+func (s *OIDCService) Info() *OIDCInfo {
+	if s.provider == nil {
+		return &OIDCInfo{}
+	}
+	return &OIDCInfo{Enabled: true, DisplayName: s.opts.DisplayName}
+}
+
+// Start begins a login at the identity provider.
+func (s *OIDCService) Start(ctx context.Context) (*OIDCStart, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("%w: single sign-on is not configured", domain.ErrNotFound)
+	}
+	now := time.Now()
+	if _, err := s.loginRepo.DeleteExpired(ctx, now); err != nil {
+		log.Printf("[oidc] purging expired logins failed: %v", err)
+	}
+
+	login := &domain.OIDCLogin{ExpiresAt: now.Add(oidcLoginTTL)}
+	for _, v := range []*string{&login.State, &login.Nonce, &login.CodeVerifier} {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("generating oidc login: %w", err)
+		}
+		*v = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	url, err := s.provider.AuthCodeURL(ctx, login.State, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loginRepo.Create(ctx, login); err != nil {
+		return nil, err
+	}
+	return &OIDCStart{URL: url, State: login.State}, nil
+}
+
+// Finish completes a login with the code and state the identity provider
+// redirected back with. The result is that of a password login: a token
+// pair, or a challenge for users with two-factor authentication.
+func (s *OIDCService) Finish(ctx context.Context, state, code string) (*LoginResult, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("%w: single sign-on is not configured", domain.ErrNotFound)
+	}
+	if state == "" || code == "" {
+		return nil, fmt.Errorf("%w: state and code required", domain.ErrInvalidInput)
+	}
+	login, err := s.loginRepo.Take(ctx, state)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown or expired login, please start again", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(login.ExpiresAt) {
+		return nil, fmt.Errorf("%w: unknown or expired login, please start again", domain.ErrUnauthorized)
+	}
+
+	idToken, err := s.provider.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		return nil, providerError(err)
+	}
+	claims, err := s.provider.Verify(ctx, idToken, login.Nonce)
+	if err != nil {
+		return nil, providerError(err)
+	}
+
+	user, err := s.linkedUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("%w: account deactivated", domain.ErrForbidden)
+	}
+	if role, ok := s.mappedRole(claims); ok && role != user.Role {
+		if err := s.userRepo.UpdateRole(ctx, user.ID, role); err != nil {
+			return nil, err
+		}
+		log.Printf("[oidc] role of %s set to %s by claim %s", user.Email, role, s.opts.RoleClaim)
+		user.Role = role
+	}
+	return s.auth.startSession(ctx, user)
+}
+
+// ---------- Internal ----------
+
+// linkedUser returns the user linked to the provider account in claims,
+// linking or creating one on its first login.
+func (s *OIDCService) linkedUser(ctx context.Context, claims oidc.Claims) (*domain.User, error) {
+	issuer, subject := s.provider.Issuer(), claims.String("sub")
+	identity, err := s.identityRepo.Get(ctx, issuer, subject)
+	if err == nil {
+		return s.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(claims.String("email"))
+	if email == "" {
+		return nil, fmt.Errorf("%w: the identity provider did not share an email address", domain.ErrForbidden)
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		// Anyone may claim any address at some providers; only one the
+		// provider checked may take over an existing account.
+		if !claims.Bool("email_verified") {
+			return nil, fmt.Errorf("%w: the identity provider has not verified %s", domain.ErrForbidden, email)
+		}
+	case errors.Is(err, domain.ErrNotFound):
+		if !s.opts.AutoCreate {
+			return nil, fmt.Errorf("%w: no account for %s, ask an admin to create one", domain.ErrForbidden, email)
+		}
+		user = &domain.User{
+			Email: email,
+			// No password: the account logs in through the provider only,
+			// until an admin resets its password.
+			DisplayName: truncateRunes(strings.TrimSpace(claims.String("name")), 100),
+			Role:        domain.RoleUser,
+		}
+		if role, ok := s.mappedRole(claims); ok {
+			user.Role = role
+		}
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return nil, err
+		}
+		log.Printf("[oidc] created user %s for %s at %s", email, subject, issuer)
+	default:
+		return nil, err
+	}
+
+	err = s.identityRepo.Create(ctx, &domain.UserIdentity{UserID: user.ID, Provider: issuer, Subject: subject})
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return nil, fmt.Errorf("%w: %s is already linked to another account at the identity provider", domain.ErrForbidden, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// mappedRole returns the role the role claim gives, if the claim is
+// configured and present.
+func (s *OIDCService) mappedRole(claims oidc.Claims) (domain.Role, bool) {
+	if s.opts.RoleClaim == "" {
+		return "", false
+	}
+	values, ok := claims.Strings(s.opts.RoleClaim)
+	if !ok {
+		return "", false
+	}
+	for _, v := range values {
+		if slices.Contains(s.opts.AdminValues, v) {
+			return domain.RoleAdmin, true
+		}
+	}
+	return domain.RoleUser, true
+}
+
+// providerError turns the provider refusing a login into 401; anything else
+// is a failure on our side or the provider's.
+func providerError(err error) error {
+	if errors.Is(err, oidc.ErrRejected) {
+		return fmt.Errorf("%w: %v", domain.ErrUnauthorized, err)
+	}
+	return fmt.Errorf("contacting identity provider: %w", err)
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
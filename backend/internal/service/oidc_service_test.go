@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/oidc"
+	"docmv/internal/oidc/oidctest"
+	"docmv/internal/service"
+)
+
+// newOIDC starts a mock identity provider and an OIDCService logging in
+// through it.
+func (e *testEnv) newOIDC(t *testing.T, opts service.OIDCOptions) (*service.OIDCService, *oidctest.IdP) {
+	t.Helper()
+	idp, err := oidctest.Start("docmv", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer,
+		ClientID:     "docmv",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/login/sso",
+		Scopes:       []string{"openid", "email", "profile"},
+	}, nil)
+	svc := service.NewOIDCService(provider, e.oidcLogins, e.identities, e.users, e.auth, opts)
+	return svc, idp
+}
+
+// ssoLogin runs a whole login as the provider's current user.
+func ssoLogin(t *testing.T, e *testEnv, svc *service.OIDCService, idp *oidctest.IdP) (*service.LoginResult, error) {
+	t.Helper()
+	start, err := svc.Start(e.ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	code, state, err := idp.Approve(start.URL)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if state != start.State {
+		t.Fatalf("state came back as %q, want %q", state, start.State)
+	}
+	return svc.Finish(e.ctx, state, code)
+}
+
+func TestOIDCProvisionsUsers(t *testing.T) {
+	e := newTestEnv(t)
+	svc, idp := e.newOIDC(t, service.OIDCOptions{RoleClaim: "groups", AdminValues: []string{"docmv-admins"}, AutoCreate: true})
+
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "carol@example.com", "name": "Carol", "groups": []string{"staff", "docmv-admins"}})
+	result, err := ssoLogin(t, e, svc, idp)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if result.AuthResult == nil || result.User.Email != "carol@example.com" || result.User.DisplayName != "Carol" || result.User.Role != domain.RoleAdmin {
+		t.Fatalf("first login = %+v", result.AuthResult)
+	}
+	if _, err := e.auth.VerifyAccessToken(e.ctx, result.Token); err != nil {
+		t.Fatalf("token of provisioned user: %v", err)
+	}
+	// Provisioned users have no password.
+	_, err = e.auth.Login(e.ctx, "carol@example.com", "", "")
+	wantErr(t, err, domain.ErrInvalidInput)
+
+	// The link is followed even after the email changes at the provider,
+	// and the role follows the groups on every login.
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "carol.new@example.com", "groups": []string{"staff"}})
+	again, err := ssoLogin(t, e, svc, idp)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.User.ID != result.User.ID || again.User.Role != domain.RoleUser {
+		t.Fatalf("second login = %+v, want the same user as USER", again.User)
+	}
+
+	// Without the claim, the role stays as it is.
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "carol@example.com"})
+	if again, err = ssoLogin(t, e, svc, idp); err != nil || again.User.Role != domain.RoleUser {
+		t.Fatalf("login without groups = %+v, %v", again, err)
+	}
+
+	// Deactivated users are refused.
+	if err := e.users.SetActive(e.ctx, result.User.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ssoLogin(t, e, svc, idp)
+	wantErr(t, err, domain.ErrForbidden)
+}
+
+func TestOIDCWithoutAutoCreate(t *testing.T) {
+	e := newTestEnv(t)
+	svc, idp := e.newOIDC(t, service.OIDCOptions{})
+
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "dave@example.com", "email_verified": true})
+	_, err := ssoLogin(t, e, svc, idp)
+	wantErr(t, err, domain.ErrForbidden)
+	if _, err := e.users.GetByEmail(e.ctx, "dave@example.com"); err == nil {
+		t.Fatal("user created although auto-create is off")
+	}
+
+	// Without an email, nobody can be found or created.
+	idp.SetUser(map[string]interface{}{"sub": "u2"})
+	_, err = ssoLogin(t, e, svc, idp)
+	wantErr(t, err, domain.ErrForbidden)
+}
+
+func TestOIDCLinksVerifiedEmail(t *testing.T) {
+	e := newTestEnv(t)
+	svc, idp := e.newOIDC(t, service.OIDCOptions{AutoCreate: true})
+	local, err := e.auth.CreateUser(e.ctx, "erin@example.com", "secret1", "USER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unverified address does not take over the local account.
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "erin@example.com", "email_verified": false})
+	_, err = ssoLogin(t, e, svc, idp)
+	wantErr(t, err, domain.ErrForbidden)
+
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "erin@example.com", "email_verified": true})
+	result, err := ssoLogin(t, e, svc, idp)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if result.User.ID != local.ID {
+		t.Fatalf("logged in as %s, want the local account %s", result.User.ID, local.ID)
+	}
+	// The local password keeps working.
+	if _, err := e.auth.Login(e.ctx, "erin@example.com", "secret1", ""); err != nil {
+		t.Fatalf("password login after linking: %v", err)
+	}
+
+	// A second provider account with the same address cannot link too.
+	idp.SetUser(map[string]interface{}{"sub": "u2", "email": "erin@example.com", "email_verified": true})
+	_, err = ssoLogin(t, e, svc, idp)
+	wantErr(t, err, domain.ErrForbidden)
+}
+
+func TestOIDCKeepsTwoFactor(t *testing.T) {
+	e := newTestEnv(t)
+	svc, idp := e.newOIDC(t, service.OIDCOptions{})
+	id := e.user(t, "frank@example.com")
+	secret, _ := e.enroll(t, id)
+
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "frank@example.com", "email_verified": true})
+	result, err := ssoLogin(t, e, svc, idp)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if result.AuthResult != nil || result.TwoFactor == nil {
+		t.Fatalf("login = %+v, want a two-factor challenge", result)
+	}
+	if _, err := e.auth.CompleteLogin(e.ctx, result.TwoFactor.ChallengeToken, totp(t, secret, 0), ""); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	e := newTestEnv(t)
+	svc, idp := e.newOIDC(t, service.OIDCOptions{AutoCreate: true})
+	idp.SetUser(map[string]interface{}{"sub": "u1", "email": "gina@example.com"})
+
+	start, err := svc.Start(e.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := idp.Approve(start.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Finish(e.ctx, "forged", code)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = svc.Finish(e.ctx, state, "")
+	wantErr(t, err, domain.ErrInvalidInput)
+
+	if _, err := svc.Finish(e.ctx, state, code); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	_, err = svc.Finish(e.ctx, state, code)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	// A code is bound to the verifier of its own login.
+	other, err := svc.Start(e.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Finish(e.ctx, other.State, code)
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	e := newTestEnv(t)
+	svc := service.NewOIDCService(nil, e.oidcLogins, e.identities, e.users, e.auth, service.OIDCOptions{})
+	if svc.Info().Enabled {
+		t.Fatal("Info reports single sign-on as enabled")
+	}
+	_, err := svc.Start(e.ctx)
+	wantErr(t, err, domain.ErrNotFound)
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// OwnershipService moves documents and flows between owners and keeps the
+// ownership history.
+type OwnershipService struct {
+	txm          repository.TxManager
+	docRepo      repository.DocumentRepository
+	flowRepo     repository.FlowRepository
+	userRepo     repository.UserRepository
+	docShares    repository.ShareRepository
+	flowShares   repository.ShareRepository
+	transferRepo repository.OwnershipTransferRepository
+}
+
+func NewOwnershipService(txm repository.TxManager, docRepo repository.DocumentRepository, flowRepo repository.FlowRepository, userRepo repository.UserRepository,
+	docShares, flowShares repository.ShareRepository, transferRepo repository.OwnershipTransferRepository) *OwnershipService {
+	return &OwnershipService{
+		txm:          txm,
+		docRepo:      docRepo,
+		flowRepo:     flowRepo,
+		userRepo:     userRepo,
+		docShares:    docShares,
+		flowShares:   flowShares,
+		transferRepo: transferRepo,
+	}
+}
+
+// TransferInput identifies the new owner by ID or by email. KeepEditShare
+// leaves the previous owner with an EDIT share.
+type TransferInput struct {
+	NewOwnerID    uuid.UUID `json:"new_owner_id"`
+	Email         string    `json:"email"`
+	KeepEditShare bool      `json:"keep_edit_share"`
+}
+
+type BulkTransferInput struct {
+	ToUserID      uuid.UUID `json:"to_user_id"`
+	KeepEditShare bool      `json:"keep_edit_share"`
+}
+
+type BulkTransferResult struct {
+	Documents int `json:"documents"`
+	Flows     int `json:"flows"`
+}
+
+// Transfer hands a document or flow to another user. Only the current owner
+// or an ADMIN may do this.
+func (s *OwnershipService) Transfer(ctx context.Context, userID uuid.UUID, isAdmin bool, res domain.ShareResource, resourceID uuid.UUID, in TransferInput) (*domain.OwnershipTransfer, error) {
+	ownerID, err := resourceOwner(ctx, s.docRepo, s.flowRepo, res, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != userID && !isAdmin {
+		return nil, domain.ErrForbidden
+	}
+
+	target, err := lookupUser(ctx, s.userRepo, "new_owner_id", in.NewOwnerID, in.Email)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == ownerID {
+		return nil, fmt.Errorf("%w: user already owns this resource", domain.ErrInvalidInput)
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	transfer, err := s.transferTx(ctx, tx, userID, res, resourceID, ownerID, target.ID, in.KeepEditShare)
+	if err != nil {
+		return nil, err
+	}
+	return transfer, tx.Commit()
+}
+
+// BulkTransfer moves every document and flow owned by fromUserID to
+// in.ToUserID in a single transaction. Admin only; enforced by the router.
+func (s *OwnershipService) BulkTransfer(ctx context.Context, userID, fromUserID uuid.UUID, in BulkTransferInput) (*BulkTransferResult, error) {
+	if _, err := s.userRepo.GetByID(ctx, fromUserID); err != nil {
+		return nil, err
+	}
+	target, err := lookupUser(ctx, s.userRepo, "to_user_id", in.ToUserID, "")
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == fromUserID {
+		return nil, domain.NewValidationError(map[string]string{"to_user_id": "same_as_source"})
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	docIDs, err := s.docRepo.ListIDsByOwnerTx(ctx, tx, fromUserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range docIDs {
+		if _, err := s.transferTx(ctx, tx, userID, domain.ShareResourceDocument, id, fromUserID, target.ID, in.KeepEditShare); err != nil {
+			return nil, err
+		}
+	}
+
+	flowIDs, err := s.flowRepo.ListIDsByOwnerTx(ctx, tx, fromUserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range flowIDs {
+		if _, err := s.transferTx(ctx, tx, userID, domain.ShareResourceFlow, id, fromUserID, target.ID, in.KeepEditShare); err != nil {
+			return nil, err
+		}
+	}
+
+	return &BulkTransferResult{Documents: len(docIDs), Flows: len(flowIDs)}, tx.Commit()
+}
+
+// ListTransfers returns the ownership history of a resource the user can read.
+func (s *OwnershipService) ListTransfers(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID) ([]domain.OwnershipTransfer, error) {
+	var (
+		ok  bool
+		err error
+	)
+	if res == domain.ShareResourceFlow {
+		ok, err = s.flowRepo.HasReadAccess(ctx, resourceID, userID)
+	} else {
+		ok, err = s.docRepo.HasReadAccess(ctx, resourceID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+	return s.transferRepo.ListByResource(ctx, res, resourceID)
+}
+
+// ---------- Internal ----------
+
+// transferTx reassigns owner_id, drops the new owner's now redundant share,
+// optionally grants the previous owner EDIT, and records the transfer.
+func (s *OwnershipService) transferTx(ctx context.Context, tx repository.Tx, userID uuid.UUID, res domain.ShareResource,
+	resourceID, from, to uuid.UUID, keepEditShare bool) (*domain.OwnershipTransfer, error) {
+	var (
+		ok     bool
+		err    error
+		shares = s.docShares
+	)
+	if res == domain.ShareResourceFlow {
+		shares = s.flowShares
+		ok, err = s.flowRepo.UpdateOwnerTx(ctx, tx, resourceID, from, to)
+	} else {
+		ok, err = s.docRepo.UpdateOwnerTx(ctx, tx, resourceID, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: owner changed concurrently", domain.ErrInvalidState)
+	}
+
+	if err := shares.DeleteByUserTx(ctx, tx, resourceID, to); err != nil {
+		return nil, err
+	}
+	if keepEditShare {
+		share := &domain.Share{ResourceID: resourceID, UserID: from, Role: domain.ShareRoleEdit}
+		if err := shares.CreateTx(ctx, tx, share); err != nil {
+			return nil, err
+		}
+	}
+
+	transfer := &domain.OwnershipTransfer{
+		ResourceType:  res,
+		ResourceID:    resourceID,
+		FromUserID:    from,
+		ToUserID:      to,
+		TransferredBy: userID,
+		KeptEditShare: keepEditShare,
+	}
+	if err := s.transferRepo.CreateTx(ctx, tx, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
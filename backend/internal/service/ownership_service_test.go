@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+func TestOwnershipTransfer(t *testing.T) {
+	e := newTestEnv(t)
+	ann := e.user(t, "ann@example.com")
+	bob := e.user(t, "bob@example.com")
+	carol := e.user(t, "carol@example.com")
+	doc, err := e.docs.Create(e.ctx, ann, service.CreateDocInput{Title: "Handbook"})
+	mustNoErr(t, err)
+	e.share(t, ann, domain.ShareResourceDocument, doc.ID, bob, domain.ShareRoleView)
+
+	_, err = e.ownership.Transfer(e.ctx, bob, false, domain.ShareResourceDocument, doc.ID, service.TransferInput{NewOwnerID: bob})
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.ownership.Transfer(e.ctx, ann, false, domain.ShareResourceDocument, doc.ID, service.TransferInput{NewOwnerID: ann})
+	wantErr(t, err, domain.ErrInvalidInput)
+
+	transfer, err := e.ownership.Transfer(e.ctx, ann, false, domain.ShareResourceDocument, doc.ID,
+		service.TransferInput{Email: "bob@example.com", KeepEditShare: true})
+	mustNoErr(t, err)
+	if transfer.FromUserID != ann || transfer.ToUserID != bob || transfer.TransferredBy != ann || !transfer.KeptEditShare {
+		t.Fatalf("got transfer %+v", transfer)
+	}
+
+	// Bob's view share is redundant now; Ann keeps editing through a share.
+	shares, err := e.shares.List(e.ctx, bob, domain.ShareResourceDocument, doc.ID)
+	mustNoErr(t, err)
+	if len(shares) != 1 || shares[0].UserID != ann || shares[0].Role != domain.ShareRoleEdit {
+		t.Fatalf("got shares %+v, want only an EDIT share for the previous owner", shares)
+	}
+	// The transfer is a new revision, so writes based on the old ETag are stale.
+	_, err = e.docs.Update(e.ctx, ann, doc.ID, service.UpdateDocInput{Title: "Handbook v2", Revision: ptr(doc.Revision)})
+	wantErr(t, err, domain.ErrStaleRevision)
+	_, err = e.docs.Update(e.ctx, ann, doc.ID, service.UpdateDocInput{Title: "Handbook v2", Revision: ptr(doc.Revision + 1)})
+	mustNoErr(t, err)
+
+	// An admin may move a resource they do not own.
+	_, err = e.ownership.Transfer(e.ctx, carol, true, domain.ShareResourceDocument, doc.ID, service.TransferInput{NewOwnerID: carol})
+	mustNoErr(t, err)
+	history, err := e.ownership.ListTransfers(e.ctx, carol, domain.ShareResourceDocument, doc.ID)
+	mustNoErr(t, err)
+	if len(history) != 2 || history[0].ToUserID != carol || history[1].ToUserID != bob {
+		t.Fatalf("got history %+v, want newest first", history)
+	}
+	_, err = e.ownership.ListTransfers(e.ctx, bob, domain.ShareResourceDocument, doc.ID)
+	wantErr(t, err, domain.ErrForbidden)
+}
+
+func TestOwnershipBulkTransfer(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.user(t, "admin@example.com")
+	leaver := e.user(t, "leaver@example.com")
+	heir := e.user(t, "heir@example.com")
+	for _, title := range []string{"One", "Two"} {
+		_, err := e.docs.Create(e.ctx, leaver, service.CreateDocInput{Title: title})
+		mustNoErr(t, err)
+	}
+	trashed, err := e.docs.Create(e.ctx, leaver, service.CreateDocInput{Title: "Old"})
+	mustNoErr(t, err)
+	mustNoErr(t, e.docs.Delete(e.ctx, leaver, trashed.ID))
+	flow := createFlow(t, e, leaver, "Onboarding")
+
+	_, err = e.ownership.BulkTransfer(e.ctx, admin, leaver, service.BulkTransferInput{ToUserID: leaver})
+	wantFields(t, err, map[string]string{"to_user_id": "same_as_source"})
+
+	result, err := e.ownership.BulkTransfer(e.ctx, admin, leaver, service.BulkTransferInput{ToUserID: heir})
+	mustNoErr(t, err)
+	if result.Documents != 3 || result.Flows != 1 {
+		t.Fatalf("got %+v, want 3 documents (one trashed) and 1 flow", result)
+	}
+
+	docs, err := e.docs.List(e.ctx, heir)
+	mustNoErr(t, err)
+	if len(docs) != 2 {
+		t.Fatalf("heir sees %d documents, want 2", len(docs))
+	}
+	if left, err := e.docs.List(e.ctx, leaver); err != nil || len(left) != 0 {
+		t.Fatalf("leaver still sees %d documents (err %v)", len(left), err)
+	}
+	detail, err := e.flows.GetDetail(e.ctx, heir, flow.ID)
+	mustNoErr(t, err)
+	if detail.Flow.OwnerID != heir {
+		t.Fatalf("flow owner is %s, want %s", detail.Flow.OwnerID, heir)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes is the longest password bcrypt accepts.
+const maxPasswordBytes = 72
+
+// PasswordPolicy decides which passwords users may set. Violations are
+// reported as validation codes: too_short, too_long, too_few_classes,
+// breached and, checked by AuthService against the user's history, reused.
+type PasswordPolicy struct {
+	MinLength  int `json:"min_length"`  // in characters
+	MinClasses int `json:"min_classes"` // of lower case, upper case, digits and other characters
+	History    int `json:"history"`     // how many previous passwords may not be reused; 0 allows reuse
+
+	breached map[string]struct{} // lower-cased
+}
+
+// LoadBreachedList reads known-breached passwords, one per line, from a
+// local file. Blank lines and lines starting with # are skipped. Matching
+// ignores case. It returns the number of passwords read.
+func (p *PasswordPolicy) LoadBreachedList(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening breached password list: %w", err)
+	}
+	defer f.Close()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading breached password list: %w", err)
+	}
+	p.breached = breached
+	return len(breached), nil
+}
+
+// check returns the code of the first rule password breaks, or "".
+func (p *PasswordPolicy) check(password string) string {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return "too_short"
+	}
+	if len(password) > maxPasswordBytes {
+		return "too_long"
+	}
+	if passwordClasses(password) < p.MinClasses {
+		return "too_few_classes"
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return "breached"
+	}
+	return ""
+}
+
+// passwordClasses counts the character classes password draws from.
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+const generatedPasswordChars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.!@#%"
+
+// generate returns a random password that satisfies the policy.
+func (p *PasswordPolicy) generate() (string, error) {
+	length := min(max(20, p.MinLength), maxPasswordBytes)
+	buf := make([]byte, length)
+	for attempt := 0; attempt < 100; attempt++ {
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(generatedPasswordChars))))
+			if err != nil {
+				return "", fmt.Errorf("generating password: %w", err)
+			}
+			buf[i] = generatedPasswordChars[n.Int64()]
+		}
+		if p.check(string(buf)) == "" {
+			return string(buf), nil
+		}
+	}
+	return "", fmt.Errorf("generating password: no candidate satisfies the password policy")
+}
@@ -0,0 +1,17 @@
+package service
+
+import "docmv/internal/domain"
+
+// checkRevision compares the revision token sent by the client with the
+// stored one. A missing token is ErrPreconditionRequired; a mismatch is
+// ErrStaleRevision, which callers upgrade to a StaleRevisionError carrying
+// the current state.
+func checkRevision(expected *int, current int) error {
+	if expected == nil {
+		return domain.ErrPreconditionRequired
+	}
+	if *expected != current {
+		return domain.ErrStaleRevision
+	}
+	return nil
+}
@@ -0,0 +1,600 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+	"docmv/internal/scim"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// scimProvider names SCIM externalIds in user_identities.
+const scimProvider = "scim"
+
+// Page sizes of SCIM queries.
+const (
+	SCIMDefaultCount = 100
+	SCIMMaxCount     = 1000
+)
+
+// SCIMService provisions users and groups for an identity provider through
+// SCIM 2.0. A SCIM User is a user: userName is their email, roles their
+// role, and active their active flag, so deprovisioning a user in the
+// identity provider deactivates the account here. Deleting a user
+// deactivates it too, as their documents stay attributable to them. A
+// SCIM Group is a group of users.
+type SCIMService struct {
+	token        string // bearer token of the provisioning client; empty disables SCIM
+	userRepo     repository.UserRepository
+	groupRepo    repository.GroupRepository
+	identityRepo repository.UserIdentityRepository
+	auth         *AuthService
+}
+
+func NewSCIMService(token string, userRepo repository.UserRepository, groupRepo repository.GroupRepository, identityRepo repository.UserIdentityRepository, auth *AuthService) *SCIMService {
+	return &SCIMService{token: token, userRepo: userRepo, groupRepo: groupRepo, identityRepo: identityRepo, auth: auth}
+}
+
+// SCIMQuery selects a page of resources. StartIndex is 1-based; a Count of
+// 0 asks for the number of matches only.
+type SCIMQuery struct {
+	Filter     string
+	StartIndex int
+	Count      int
+}
+
+// Authorize checks the bearer token of a SCIM request.
+func (s *SCIMService) Authorize(token string) error {
+	if s.token == "" {
+		return fmt.Errorf("%w: SCIM provisioning is not configured", domain.ErrNotFound)
+	}
+	// Hashing first makes the comparison constant-time for any length.
+	got, want := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(s.token))
+	if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+		return fmt.Errorf("%w: invalid SCIM token", domain.ErrUnauthorized)
+	}
+	return nil
+}
+
+// ---------- Users ----------
+
+func (s *SCIMService) ListUsers(ctx context.Context, q SCIMQuery) (*scim.ListResponse, error) {
+	users, err := s.userRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]scim.Resource, 0, len(users))
+	for i := range users {
+		resources = append(resources, ix.user(&users[i]))
+	}
+	return listResponse(resources, q)
+}
+
+func (s *SCIMService) GetUser(ctx context.Context, id string) (*scim.User, error) {
+	user, err := s.user(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ix.user(user), nil
+}
+
+// CreateUser creates a user. Without a password, the user logs in through
+// single sign-on or the directory.
+func (s *SCIMService) CreateUser(ctx context.Context, in *scim.User) (*scim.User, error) {
+	f, err := scimUserFields(in)
+	if err != nil {
+		return nil, err
+	}
+	if f.password != "" {
+		if code := s.auth.policy.check(f.password); code != "" {
+			return nil, domain.NewValidationError(map[string]string{"password": code})
+		}
+	}
+	if in.ExternalID != "" {
+		if _, err := s.identityRepo.Get(ctx, scimProvider, in.ExternalID); err == nil {
+			return nil, fmt.Errorf("%w: externalId already provisioned", domain.ErrAlreadyExists)
+		} else if !errors.Is(err, domain.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	user := &domain.User{Email: f.email, DisplayName: f.displayName, Department: f.department, Role: domain.RoleUser}
+	if f.role != "" {
+		user.Role = f.role
+	}
+	if f.password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(f.password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("hashing password: %w", err)
+		}
+		user.PasswordHash = string(hash)
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	if user.PasswordHash != "" {
+		s.auth.rememberPassword(ctx, user.ID, user.PasswordHash)
+	}
+	if f.active != nil && !*f.active {
+		if err := s.userRepo.SetActive(ctx, user.ID, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.setExternalID(ctx, user.ID, in.ExternalID); err != nil {
+		return nil, err
+	}
+	log.Printf("[scim] created user %s", user.Email)
+	return s.GetUser(ctx, user.ID.String())
+}
+
+// ReplaceUser sets a user from a full resource. Roles and active keep their
+// value when left out.
+func (s *SCIMService) ReplaceUser(ctx context.Context, id string, in *scim.User) (*scim.User, error) {
+	user, err := s.user(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateUser(ctx, user, in); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, id)
+}
+
+// PatchUser applies PATCH operations to a user.
+func (s *SCIMService) PatchUser(ctx context.Context, id string, ops []scim.PatchOp) (*scim.User, error) {
+	current, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var in scim.User
+	if err := patchResource(current, ops, &in); err != nil {
+		return nil, err
+	}
+	// Users always have roles, so none left means they were removed.
+	if in.Roles == nil {
+		in.Roles = []scim.MultiValue{}
+	}
+	user, err := s.user(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateUser(ctx, user, &in); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, id)
+}
+
+// DeleteUser deactivates a user.
+func (s *SCIMService) DeleteUser(ctx context.Context, id string) error {
+	user, err := s.user(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.Active {
+		return nil
+	}
+	if err := s.userRepo.SetActive(ctx, user.ID, false); err != nil {
+		return err
+	}
+	log.Printf("[scim] deactivated %s", user.Email)
+	return nil
+}
+
+// ---------- Groups ----------
+
+func (s *SCIMService) ListGroups(ctx context.Context, q SCIMQuery) (*scim.ListResponse, error) {
+	groups, err := s.groupRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]scim.Resource, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, ix.group(&groups[i]))
+	}
+	return listResponse(resources, q)
+}
+
+func (s *SCIMService) GetGroup(ctx context.Context, id string) (*scim.Group, error) {
+	group, err := s.group(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ix.group(group), nil
+}
+
+func (s *SCIMService) CreateGroup(ctx context.Context, in *scim.Group) (*scim.Group, error) {
+	name, members, err := s.scimGroupFields(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	group := &domain.Group{Name: name, ExternalID: in.ExternalID}
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, err
+	}
+	for userID := range members {
+		if err := s.groupRepo.AddMember(ctx, group.ID, userID, domain.GroupRoleMember); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("[scim] created group %s", group.Name)
+	return s.GetGroup(ctx, group.ID.String())
+}
+
+// ReplaceGroup sets a group's name, externalId and members.
+func (s *SCIMService) ReplaceGroup(ctx context.Context, id string, in *scim.Group) (*scim.Group, error) {
+	group, err := s.group(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	name, members, err := s.scimGroupFields(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	group.Name, group.ExternalID = name, in.ExternalID
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return nil, err
+	}
+
+	current, err := s.groupRepo.ListMembers(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range current {
+		if !members[m.UserID] {
+			if err := s.groupRepo.RemoveMember(ctx, group.ID, m.UserID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for userID := range members {
+		if err := s.groupRepo.AddMember(ctx, group.ID, userID, domain.GroupRoleMember); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetGroup(ctx, id)
+}
+
+// PatchGroup applies PATCH operations to a group, typically adding and
+// removing members.
+func (s *SCIMService) PatchGroup(ctx context.Context, id string, ops []scim.PatchOp) (*scim.Group, error) {
+	current, err := s.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var in scim.Group
+	if err := patchResource(current, ops, &in); err != nil {
+		return nil, err
+	}
+	return s.ReplaceGroup(ctx, id, &in)
+}
+
+func (s *SCIMService) DeleteGroup(ctx context.Context, id string) error {
+	group, err := s.group(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.groupRepo.Delete(ctx, group.ID); err != nil {
+		return err
+	}
+	log.Printf("[scim] deleted group %s", group.Name)
+	return nil
+}
+
+// ---------- Internal ----------
+
+// scimIndex holds what rendering users and groups needs besides their own
+// rows.
+type scimIndex struct {
+	emails      map[uuid.UUID]string
+	externalIDs map[uuid.UUID]string
+	groupsOf    map[uuid.UUID][]scim.MultiValue // by user
+	membersOf   map[uuid.UUID][]scim.MultiValue // by group
+}
+
+func (s *SCIMService) index(ctx context.Context) (*scimIndex, error) {
+	users, err := s.userRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.identityRepo.ListByProvider(ctx, scimProvider)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groupRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := s.groupRepo.ListMemberships(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &scimIndex{
+		emails:      make(map[uuid.UUID]string, len(users)),
+		externalIDs: make(map[uuid.UUID]string, len(identities)),
+		groupsOf:    make(map[uuid.UUID][]scim.MultiValue),
+		membersOf:   make(map[uuid.UUID][]scim.MultiValue),
+	}
+	for _, u := range users {
+		ix.emails[u.ID] = u.Email
+	}
+	for _, i := range identities {
+		ix.externalIDs[i.UserID] = i.Subject
+	}
+	names := make(map[uuid.UUID]string, len(groups))
+	for _, g := range groups {
+		names[g.ID] = g.Name
+	}
+	for _, m := range memberships {
+		ix.groupsOf[m.UserID] = append(ix.groupsOf[m.UserID], scim.MultiValue{Value: m.GroupID.String(), Display: names[m.GroupID]})
+		ix.membersOf[m.GroupID] = append(ix.membersOf[m.GroupID], scim.MultiValue{Value: m.UserID.String(), Display: ix.emails[m.UserID]})
+	}
+	return ix, nil
+}
+
+func (ix *scimIndex) user(u *domain.User) *scim.User {
+	active := scim.Bool(u.Active)
+	out := &scim.User{
+		Schemas:     []string{scim.UserSchema},
+		ID:          u.ID.String(),
+		ExternalID:  ix.externalIDs[u.ID],
+		UserName:    u.Email,
+		DisplayName: u.DisplayName,
+		Active:      &active,
+		Emails:      []scim.MultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Roles:       []scim.MultiValue{{Value: string(u.Role), Primary: true}},
+		Groups:      ix.groupsOf[u.ID],
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.CreatedAt,
+			Location:     "/Users/" + u.ID.String(),
+		},
+	}
+	if u.DisplayName != "" {
+		out.Name = &scim.Name{Formatted: u.DisplayName}
+	}
+	if u.Department != "" {
+		out.Schemas = append(out.Schemas, scim.EnterpriseUserSchema)
+		out.Enterprise = &scim.EnterpriseUser{Department: u.Department}
+	}
+	return out
+}
+
+func (ix *scimIndex) group(g *domain.Group) *scim.Group {
+	return &scim.Group{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          g.ID.String(),
+		ExternalID:  g.ExternalID,
+		DisplayName: g.Name,
+		Members:     ix.membersOf[g.ID],
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      g.CreatedAt,
+			LastModified: g.UpdatedAt,
+			Location:     "/Groups/" + g.ID.String(),
+		},
+	}
+}
+
+// listResponse filters resources and returns the page q asks for.
+func listResponse(resources []scim.Resource, q SCIMQuery) (*scim.ListResponse, error) {
+	if q.Filter != "" {
+		f, err := scim.ParseFilter(q.Filter)
+		if err != nil {
+			return nil, err
+		}
+		matched := resources[:0]
+		for _, r := range resources {
+			m, err := scim.ToMap(r)
+			if err != nil {
+				return nil, err
+			}
+			if f.Match(m) {
+				matched = append(matched, r)
+			}
+		}
+		resources = matched
+	}
+
+	start, count := max(q.StartIndex, 1), min(max(q.Count, 0), SCIMMaxCount)
+	page := resources[min(start-1, len(resources)):]
+	page = page[:min(count, len(page))]
+	return &scim.ListResponse{
+		Schemas:      []string{scim.ListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   start,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}, nil
+}
+
+// patchResource applies PATCH operations to current and decodes the result
+// into out.
+func patchResource(current any, ops []scim.PatchOp, out any) error {
+	m, err := scim.ToMap(current)
+	if err != nil {
+		return err
+	}
+	if err := scim.Patch(m, ops); err != nil {
+		return err
+	}
+	return scim.FromMap(m, out)
+}
+
+func (s *SCIMService) user(ctx context.Context, id string) (*domain.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, domain.ErrNotFound
+	}
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *SCIMService) group(ctx context.Context, id string) (*domain.Group, error) {
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, domain.ErrNotFound
+	}
+	return s.groupRepo.GetByID(ctx, groupID)
+}
+
+// userFields is what a SCIM User sets on a user. An empty role and a nil
+// active flag leave those alone.
+type userFields struct {
+	email       string
+	displayName string
+	department  string
+	role        domain.Role
+	active      *bool
+	password    string
+}
+
+func scimUserFields(in *scim.User) (*userFields, error) {
+	f := &userFields{email: strings.TrimSpace(in.UserName), password: in.Password}
+	if !strings.Contains(f.email, "@") {
+		return nil, fmt.Errorf("%w: userName must be an email address", domain.ErrInvalidInput)
+	}
+
+	f.displayName = in.DisplayName
+	if f.displayName == "" && in.Name != nil {
+		f.displayName = in.Name.Formatted
+		if f.displayName == "" {
+			f.displayName = strings.TrimSpace(in.Name.GivenName + " " + in.Name.FamilyName)
+		}
+	}
+	f.displayName = truncateRunes(strings.TrimSpace(f.displayName), 100)
+	if in.Enterprise != nil {
+		f.department = truncateRunes(strings.TrimSpace(in.Enterprise.Department), 100)
+	}
+
+	if in.Roles != nil {
+		f.role = domain.RoleUser
+		for _, r := range in.Roles {
+			if strings.EqualFold(r.Value, string(domain.RoleAdmin)) {
+				f.role = domain.RoleAdmin
+			}
+		}
+	}
+	if in.Active != nil {
+		active := bool(*in.Active)
+		f.active = &active
+	}
+	return f, nil
+}
+
+func (s *SCIMService) updateUser(ctx context.Context, user *domain.User, in *scim.User) error {
+	f, err := scimUserFields(in)
+	if err != nil {
+		return err
+	}
+	if f.password != "" {
+		code, err := s.auth.checkNewPassword(ctx, user, f.password)
+		if err != nil {
+			return err
+		}
+		if code != "" {
+			return domain.NewValidationError(map[string]string{"password": code})
+		}
+	}
+
+	if f.email != user.Email {
+		if err := s.userRepo.UpdateEmail(ctx, user.ID, f.email); err != nil {
+			return err
+		}
+	}
+	if f.displayName != user.DisplayName || f.department != user.Department {
+		if err := s.userRepo.UpdateProfile(ctx, user.ID, f.displayName, f.department, user.Locale); err != nil {
+			return err
+		}
+	}
+	if f.role != "" && f.role != user.Role {
+		if err := s.userRepo.UpdateRole(ctx, user.ID, f.role); err != nil {
+			return err
+		}
+		log.Printf("[scim] role of %s set to %s", f.email, f.role)
+	}
+	if f.active != nil && *f.active != user.Active {
+		if err := s.userRepo.SetActive(ctx, user.ID, *f.active); err != nil {
+			return err
+		}
+		log.Printf("[scim] %s set to active=%v", f.email, *f.active)
+	}
+	if f.password != "" {
+		if err := s.auth.setPassword(ctx, user.ID, f.password); err != nil {
+			return err
+		}
+	}
+	return s.setExternalID(ctx, user.ID, in.ExternalID)
+}
+
+// setExternalID links a user to the provisioning client's id for them.
+func (s *SCIMService) setExternalID(ctx context.Context, userID uuid.UUID, externalID string) error {
+	current, err := s.identityRepo.GetByUser(ctx, userID, scimProvider)
+	switch {
+	case err == nil:
+		if current.Subject == externalID {
+			return nil
+		}
+		if err := s.identityRepo.Delete(ctx, current.ID); err != nil {
+			return err
+		}
+	case !errors.Is(err, domain.ErrNotFound):
+		return err
+	}
+	if externalID == "" {
+		return nil
+	}
+	err = s.identityRepo.Create(ctx, &domain.UserIdentity{UserID: userID, Provider: scimProvider, Subject: externalID})
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return fmt.Errorf("%w: externalId already provisioned", domain.ErrAlreadyExists)
+	}
+	return err
+}
+
+// scimGroupFields validates a SCIM Group and returns its name and the users
+// it lists as members, by id.
+func (s *SCIMService) scimGroupFields(ctx context.Context, in *scim.Group) (string, map[uuid.UUID]bool, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" || len(name) > 255 {
+		return "", nil, fmt.Errorf("%w: displayName must be 1 to 255 bytes", domain.ErrInvalidInput)
+	}
+	members := make(map[uuid.UUID]bool, len(in.Members))
+	for _, m := range in.Members {
+		userID, err := uuid.Parse(m.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: member %q is not a user", domain.ErrInvalidInput, m.Value)
+		}
+		if _, err := s.userRepo.GetByID(ctx, userID); errors.Is(err, domain.ErrNotFound) {
+			return "", nil, fmt.Errorf("%w: member %q is not a user", domain.ErrInvalidInput, m.Value)
+		} else if err != nil {
+			return "", nil, err
+		}
+		members[userID] = true
+	}
+	return name, members, nil
+}
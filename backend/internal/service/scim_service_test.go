@@ -0,0 +1,209 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/scim"
+	"docmv/internal/service"
+)
+
+func (e *testEnv) newSCIM() *service.SCIMService {
+	return service.NewSCIMService("scim-token", e.users, e.groups, e.identities, e.auth)
+}
+
+// scimOps decodes the Operations of a PATCH request body.
+func scimOps(t *testing.T, body string) []scim.PatchOp {
+	t.Helper()
+	var req scim.PatchRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Operations
+}
+
+func TestSCIMAuthorize(t *testing.T) {
+	e := newTestEnv(t)
+	mustNoErr(t, e.newSCIM().Authorize("scim-token"))
+	wantErr(t, e.newSCIM().Authorize("scim-token2"), domain.ErrUnauthorized)
+	wantErr(t, e.newSCIM().Authorize(""), domain.ErrUnauthorized)
+
+	disabled := service.NewSCIMService("", e.users, e.groups, e.identities, e.auth)
+	wantErr(t, disabled.Authorize(""), domain.ErrNotFound)
+}
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	s := e.newSCIM()
+
+	created, err := s.CreateUser(e.ctx, &scim.User{
+		UserName:   "mia@example.com",
+		ExternalID: "00u1",
+		Name:       &scim.Name{GivenName: "Mia", FamilyName: "Wong"},
+		Password:   "secret1",
+		Roles:      []scim.MultiValue{{Value: "admin"}},
+		Enterprise: &scim.EnterpriseUser{Department: "Ops"},
+	})
+	mustNoErr(t, err)
+	if created.UserName != "mia@example.com" || created.ExternalID != "00u1" || created.DisplayName != "Mia Wong" ||
+		created.Roles[0].Value != "ADMIN" || !bool(*created.Active) || created.Enterprise.Department != "Ops" ||
+		created.Meta.Location != "/Users/"+created.ID {
+		t.Fatalf("created %+v", created)
+	}
+	res, err := e.auth.Login(e.ctx, "mia@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	// The client finds users it provisioned by userName or externalId.
+	for _, filter := range []string{`userName eq "MIA@example.com"`, `externalId eq "00u1"`} {
+		list, err := s.ListUsers(e.ctx, service.SCIMQuery{Filter: filter, Count: 10})
+		mustNoErr(t, err)
+		if list.TotalResults != 1 || list.Resources[0].(*scim.User).ID != created.ID {
+			t.Fatalf("%s: %+v", filter, list)
+		}
+	}
+
+	// Deprovisioning deactivates the account and ends its sessions.
+	_, err = s.PatchUser(e.ctx, created.ID, scimOps(t, `{"Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`))
+	mustNoErr(t, err)
+	_, err = e.auth.VerifyAccessToken(e.ctx, res.Token)
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.Login(e.ctx, "mia@example.com", "secret1", "")
+	wantErr(t, err, domain.ErrForbidden)
+
+	patched, err := s.PatchUser(e.ctx, created.ID, scimOps(t, `{"Operations": [
+		{"op": "replace", "value": {"active": true, "displayName": "Mia W."}},
+		{"op": "remove", "path": "roles"},
+		{"op": "remove", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department"}
+	]}`))
+	mustNoErr(t, err)
+	if !bool(*patched.Active) || patched.DisplayName != "Mia W." || patched.Roles[0].Value != "USER" || patched.Enterprise != nil {
+		t.Fatalf("patched %+v", patched)
+	}
+
+	// A full replace changes the email and externalId; roles and active
+	// left out stay as they are.
+	replaced, err := s.ReplaceUser(e.ctx, created.ID, &scim.User{UserName: "mia.wong@example.com", ExternalID: "00u2"})
+	mustNoErr(t, err)
+	if replaced.UserName != "mia.wong@example.com" || replaced.ExternalID != "00u2" || replaced.Roles[0].Value != "USER" ||
+		!bool(*replaced.Active) || replaced.DisplayName != "" {
+		t.Fatalf("replaced %+v", replaced)
+	}
+
+	mustNoErr(t, s.DeleteUser(e.ctx, created.ID))
+	u, err := s.GetUser(e.ctx, created.ID)
+	mustNoErr(t, err)
+	if bool(*u.Active) {
+		t.Fatal("deleted user still active")
+	}
+	_, err = s.GetUser(e.ctx, "not-a-uuid")
+	wantErr(t, err, domain.ErrNotFound)
+}
+
+func TestSCIMUserConflictsAndValidation(t *testing.T) {
+	e := newTestEnv(t)
+	s := e.newSCIM()
+	first, err := s.CreateUser(e.ctx, &scim.User{UserName: "nils@example.com", ExternalID: "ext-1"})
+	mustNoErr(t, err)
+	second, err := s.CreateUser(e.ctx, &scim.User{UserName: "olga@example.com"})
+	mustNoErr(t, err)
+
+	_, err = s.CreateUser(e.ctx, &scim.User{UserName: "nils@example.com"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+	_, err = s.CreateUser(e.ctx, &scim.User{UserName: "nils2@example.com", ExternalID: "ext-1"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+	_, err = s.ReplaceUser(e.ctx, second.ID, &scim.User{UserName: "nils@example.com"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+	_, err = s.CreateUser(e.ctx, &scim.User{UserName: "nils"})
+	wantErr(t, err, domain.ErrInvalidInput)
+	_, err = s.CreateUser(e.ctx, &scim.User{UserName: "pia@example.com", Password: "short"})
+	wantFields(t, err, map[string]string{"password": "too_short"})
+
+	_, err = s.PatchUser(e.ctx, first.ID, scimOps(t, `{"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]}`))
+	var serr *scim.Error
+	if !errors.As(err, &serr) || serr.ScimType != scim.ErrInvalidValue {
+		t.Fatalf("got %v, want an invalidValue error", err)
+	}
+	_, err = s.ListUsers(e.ctx, service.SCIMQuery{Filter: `userName xx "a"`})
+	if !errors.As(err, &serr) || serr.ScimType != scim.ErrInvalidFilter {
+		t.Fatalf("got %v, want an invalidFilter error", err)
+	}
+}
+
+func TestSCIMPaging(t *testing.T) {
+	e := newTestEnv(t)
+	s := e.newSCIM()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		_, err := s.CreateUser(e.ctx, &scim.User{UserName: name + "@example.com"})
+		mustNoErr(t, err)
+	}
+
+	for _, tc := range []struct {
+		start, count, want int
+	}{
+		{1, 100, 5},
+		{2, 2, 2},
+		{5, 2, 1},
+		{9, 2, 0},
+		{0, 3, 3},
+		{1, 0, 0},
+	} {
+		list, err := s.ListUsers(e.ctx, service.SCIMQuery{StartIndex: tc.start, Count: tc.count})
+		mustNoErr(t, err)
+		if list.TotalResults != 5 || list.ItemsPerPage != tc.want || len(list.Resources) != tc.want || list.StartIndex != max(tc.start, 1) {
+			t.Fatalf("startIndex %d count %d: %+v", tc.start, tc.count, list)
+		}
+	}
+}
+
+func TestSCIMGroups(t *testing.T) {
+	e := newTestEnv(t)
+	s := e.newSCIM()
+	alice := e.user(t, "alice@example.com").String()
+	bob := e.user(t, "bob@example.com").String()
+
+	g, err := s.CreateGroup(e.ctx, &scim.Group{DisplayName: "Engineering", ExternalID: "grp-1", Members: []scim.MultiValue{{Value: alice}}})
+	mustNoErr(t, err)
+	if g.DisplayName != "Engineering" || len(g.Members) != 1 || g.Members[0].Display != "alice@example.com" {
+		t.Fatalf("created %+v", g)
+	}
+	_, err = s.CreateGroup(e.ctx, &scim.Group{DisplayName: "Engineering"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+	_, err = s.CreateGroup(e.ctx, &scim.Group{DisplayName: "Ghosts", Members: []scim.MultiValue{{Value: "nobody"}}})
+	wantErr(t, err, domain.ErrInvalidInput)
+
+	g, err = s.PatchGroup(e.ctx, g.ID, scimOps(t, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "`+bob+`"}]},
+		{"op": "remove", "path": "members[value eq \"`+alice+`\"]"},
+		{"op": "replace", "path": "displayName", "value": "R&D"}
+	]}`))
+	mustNoErr(t, err)
+	if g.DisplayName != "R&D" || len(g.Members) != 1 || g.Members[0].Value != bob {
+		t.Fatalf("patched %+v", g)
+	}
+
+	// Users list the groups they belong to.
+	u, err := s.GetUser(e.ctx, bob)
+	mustNoErr(t, err)
+	if len(u.Groups) != 1 || u.Groups[0].Value != g.ID || u.Groups[0].Display != "R&D" {
+		t.Fatalf("groups of bob %+v", u.Groups)
+	}
+	list, err := s.ListGroups(e.ctx, service.SCIMQuery{Filter: `members[value eq "` + bob + `"]`, Count: 10})
+	mustNoErr(t, err)
+	if list.TotalResults != 1 {
+		t.Fatalf("groups with bob: %+v", list)
+	}
+
+	// Members not listed in a full replace are removed.
+	g, err = s.ReplaceGroup(e.ctx, g.ID, &scim.Group{DisplayName: "R&D", Members: []scim.MultiValue{{Value: alice}}})
+	mustNoErr(t, err)
+	if len(g.Members) != 1 || g.Members[0].Value != alice || g.ExternalID != "" {
+		t.Fatalf("replaced %+v", g)
+	}
+
+	mustNoErr(t, s.DeleteGroup(e.ctx, g.ID))
+	_, err = s.GetGroup(e.ctx, g.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	wantErr(t, s.DeleteGroup(e.ctx, g.ID), domain.ErrNotFound)
+}
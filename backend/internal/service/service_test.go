@@ -0,0 +1,153 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository/memory"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// testEnv wires the services to a fresh in-memory store.
+type testEnv struct {
+	ctx        context.Context
+	users      *memory.UserRepo
+	tokens     *memory.RefreshTokenRepo
+	history    *memory.PasswordHistoryRepo
+	throttles  *memory.LoginThrottleRepo
+	recovery   *memory.RecoveryCodeRepo
+	settings   *memory.SettingRepo
+	identities *memory.UserIdentityRepo
+	oidcLogins *memory.OIDCLoginRepo
+	groups     *memory.GroupRepo
+	signer     *service.TokenSigner
+	auth       *service.AuthService
+	twoFactor  *service.TwoFactorService
+	apiTokens  *service.APITokenService
+	docs       *service.DocumentService
+	flows      *service.FlowService
+	shares     *service.ShareService
+	groupSvc   *service.GroupService
+	nodes      *service.WorkflowNodeService
+	ownership  *service.OwnershipService
+	trash      *service.TrashService
+	conversion *service.DocConversionService
+}
+
+const testJWTSecret = "test-secret"
+
+// testLockout never delays logins, so tests may fail a login now and then;
+// lockout tests build their own guard.
+var testLockout = service.LockoutPolicy{MaxAccountFailures: 5, MaxIPFailures: 20, Lockout: time.Minute}
+
+// testPasswordPolicy accepts the "secret1" style passwords used throughout.
+func testPasswordPolicy() *service.PasswordPolicy {
+	return &service.PasswordPolicy{MinLength: 6, MinClasses: 2, History: 3}
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	store := memory.NewStore()
+	users := memory.NewUserRepo(store)
+	docRepo := memory.NewDocumentRepo(store)
+	flowRepo := memory.NewFlowRepo(store)
+	tokens := memory.NewRefreshTokenRepo(store)
+	history := memory.NewPasswordHistoryRepo(store)
+	throttles := memory.NewLoginThrottleRepo(store)
+	recovery := memory.NewRecoveryCodeRepo(store)
+	settings := memory.NewSettingRepo(store)
+	groups := memory.NewGroupRepo(store)
+	nodeRepo := memory.NewWorkflowNodeRepo(store)
+	transfers := memory.NewOwnershipTransferRepo(store)
+	docShares, flowShares := memory.NewDocumentShareRepo(store), memory.NewFlowShareRepo(store)
+	docGroupShares, flowGroupShares := memory.NewDocumentGroupShareRepo(store), memory.NewFlowGroupShareRepo(store)
+	guard := service.NewLoginGuard(throttles, testLockout)
+	twoFactor := service.NewTwoFactorService(users, recovery, settings, guard, "DocMV")
+	signer := service.NewTokenSigner(memory.NewSigningKeyRepo(store), service.TokenSignerOptions{Algorithm: service.AlgHS256, Secret: testJWTSecret})
+	return &testEnv{
+		ctx:        context.Background(),
+		users:      users,
+		tokens:     tokens,
+		history:    history,
+		throttles:  throttles,
+		recovery:   recovery,
+		settings:   settings,
+		identities: memory.NewUserIdentityRepo(store),
+		oidcLogins: memory.NewOIDCLoginRepo(store),
+		groups:     groups,
+		signer:     signer,
+		auth: service.NewAuthService(users, tokens, history, guard, testPasswordPolicy(), twoFactor,
+			signer, 15*time.Minute, time.Hour),
+		twoFactor: twoFactor,
+		apiTokens: service.NewAPITokenService(memory.NewAPITokenRepo(store), users, twoFactor),
+		docs:      service.NewDocumentService(store, docRepo, memory.NewVersionRepo(store)),
+		flows:     service.NewFlowService(store, flowRepo, memory.NewFlowNodeRepo(store), memory.NewFlowVersionRepo(store)),
+		shares:    service.NewShareService(docRepo, flowRepo, users, groups, docShares, flowShares, docGroupShares, flowGroupShares),
+		groupSvc:  service.NewGroupService(store, groups, users),
+		nodes:     service.NewWorkflowNodeService(store, nodeRepo, docRepo),
+		ownership: service.NewOwnershipService(store, docRepo, flowRepo, users, docShares, flowShares, transfers),
+		trash:     service.NewTrashService(store, docRepo, flowRepo, nodeRepo, transfers),
+		conversion: service.NewDocConversionService(store, memory.NewDocumentConversionRepo(store), memory.NewVersionRepo(store),
+			nodeRepo, docShares, flowRepo, memory.NewFlowNodeRepo(store), memory.NewFlowVersionRepo(store), flowShares,
+			docGroupShares, flowGroupShares),
+	}
+}
+
+// user creates an account directly in the store, skipping password hashing.
+func (e *testEnv) user(t *testing.T, email string) uuid.UUID {
+	t.Helper()
+	u := &domain.User{Email: email, PasswordHash: "-"}
+	if err := e.users.Create(e.ctx, u); err != nil {
+		t.Fatalf("creating user %s: %v", email, err)
+	}
+	return u.ID
+}
+
+// share grants userID a role on a resource owned by ownerID.
+func (e *testEnv) share(t *testing.T, ownerID uuid.UUID, res domain.ShareResource, resourceID, userID uuid.UUID, role domain.ShareRole) {
+	t.Helper()
+	in := service.CreateShareInput{UserID: userID, Role: string(role)}
+	if _, err := e.shares.Create(e.ctx, ownerID, res, resourceID, in); err != nil {
+		t.Fatalf("sharing %s with %s: %v", resourceID, userID, err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// wantErr fails the test unless err matches target.
+func wantErr(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("got error %v, want %v", err, target)
+	}
+}
+
+// wantFields fails the test unless err is a ValidationError with exactly the
+// given field codes.
+func wantFields(t *testing.T, err error, want map[string]string) {
+	t.Helper()
+	var verr *domain.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+	if len(verr.Fields) != len(want) {
+		t.Fatalf("got fields %v, want %v", verr.Fields, want)
+	}
+	for k, v := range want {
+		if verr.Fields[k] != v {
+			t.Fatalf("got fields %v, want %v", verr.Fields, want)
+		}
+	}
+}
+
+func mustNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ShareService manages VIEW/EDIT grants on documents and flows, to single
+// users or to whole groups. Only the owner of the resource may list or
+// change its shares.
+type ShareService struct {
+	docRepo         repository.DocumentRepository
+	flowRepo        repository.FlowRepository
+	userRepo        repository.UserRepository
+	groupRepo       repository.GroupRepository
+	docShares       repository.ShareRepository
+	flowShares      repository.ShareRepository
+	docGroupShares  repository.GroupShareRepository
+	flowGroupShares repository.GroupShareRepository
+}
+
+func NewShareService(docRepo repository.DocumentRepository, flowRepo repository.FlowRepository, userRepo repository.UserRepository,
+	groupRepo repository.GroupRepository, docShares, flowShares repository.ShareRepository,
+	docGroupShares, flowGroupShares repository.GroupShareRepository) *ShareService {
+	return &ShareService{
+		docRepo:         docRepo,
+		flowRepo:        flowRepo,
+		userRepo:        userRepo,
+		groupRepo:       groupRepo,
+		docShares:       docShares,
+		flowShares:      flowShares,
+		docGroupShares:  docGroupShares,
+		flowGroupShares: flowGroupShares,
+	}
+}
+
+// CreateShareInput identifies the target user by ID or by email.
+type CreateShareInput struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	Role   string    `json:"role"`
+}
+
+type CreateGroupShareInput struct {
+	GroupID uuid.UUID `json:"group_id"`
+	Role    string    `json:"role"`
+}
+
+// UpdateShareInput changes the role of a user or group share.
+type UpdateShareInput struct {
+	Role string `json:"role"`
+}
+
+func (s *ShareService) List(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID) ([]domain.Share, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+	return s.repoFor(res).ListByResource(ctx, resourceID)
+}
+
+func (s *ShareService) Create(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID, in CreateShareInput) (*domain.Share, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+
+	role, err := parseShareRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := lookupUser(ctx, s.userRepo, "user_id", in.UserID, in.Email)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == userID {
+		return nil, fmt.Errorf("%w: cannot share with yourself", domain.ErrInvalidInput)
+	}
+
+	share := &domain.Share{
+		ResourceID: resourceID,
+		UserID:     target.ID,
+		UserEmail:  target.Email,
+		Role:       role,
+	}
+	if err := s.repoFor(res).Create(ctx, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *ShareService) Update(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID, shareID uuid.UUID, in UpdateShareInput) (*domain.Share, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+
+	role, err := parseShareRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := s.getShare(ctx, res, resourceID, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repoFor(res).UpdateRole(ctx, shareID, role); err != nil {
+		return nil, err
+	}
+	share.Role = role
+	return share, nil
+}
+
+func (s *ShareService) Delete(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID, shareID uuid.UUID) error {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return err
+	}
+	if _, err := s.getShare(ctx, res, resourceID, shareID); err != nil {
+		return err
+	}
+	return s.repoFor(res).Delete(ctx, shareID)
+}
+
+// ---------- Group shares ----------
+
+func (s *ShareService) ListGroupShares(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID) ([]domain.GroupShare, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+	return s.groupRepoFor(res).ListByResource(ctx, resourceID)
+}
+
+// CreateGroupShare grants every member of a group a role on the resource.
+func (s *ShareService) CreateGroupShare(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID, in CreateGroupShareInput) (*domain.GroupShare, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+
+	role, err := parseShareRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.GroupID == uuid.Nil {
+		return nil, domain.NewValidationError(map[string]string{"group_id": "required"})
+	}
+	group, err := s.groupRepo.GetByID(ctx, in.GroupID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.NewValidationError(map[string]string{"group_id": "group_not_found"})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	share := &domain.GroupShare{
+		ResourceID: resourceID,
+		GroupID:    group.ID,
+		GroupName:  group.Name,
+		Role:       role,
+	}
+	if err := s.groupRepoFor(res).Create(ctx, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *ShareService) UpdateGroupShare(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID, shareID uuid.UUID, in UpdateShareInput) (*domain.GroupShare, error) {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return nil, err
+	}
+
+	role, err := parseShareRole(in.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := s.getGroupShare(ctx, res, resourceID, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.groupRepoFor(res).UpdateRole(ctx, shareID, role); err != nil {
+		return nil, err
+	}
+	share.Role = role
+	return share, nil
+}
+
+func (s *ShareService) DeleteGroupShare(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID, shareID uuid.UUID) error {
+	if err := s.requireOwner(ctx, userID, res, resourceID); err != nil {
+		return err
+	}
+	if _, err := s.getGroupShare(ctx, res, resourceID, shareID); err != nil {
+		return err
+	}
+	return s.groupRepoFor(res).Delete(ctx, shareID)
+}
+
+// ---------- Internal ----------
+
+func (s *ShareService) repoFor(res domain.ShareResource) repository.ShareRepository {
+	if res == domain.ShareResourceFlow {
+		return s.flowShares
+	}
+	return s.docShares
+}
+
+func (s *ShareService) groupRepoFor(res domain.ShareResource) repository.GroupShareRepository {
+	if res == domain.ShareResourceFlow {
+		return s.flowGroupShares
+	}
+	return s.docGroupShares
+}
+
+// requireOwner returns ErrForbidden unless userID owns the resource.
+func (s *ShareService) requireOwner(ctx context.Context, userID uuid.UUID, res domain.ShareResource, resourceID uuid.UUID) error {
+	ownerID, err := resourceOwner(ctx, s.docRepo, s.flowRepo, res, resourceID)
+	if err != nil {
+		return err
+	}
+	if ownerID != userID {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// getShare loads a share and checks that it belongs to the given resource.
+func (s *ShareService) getShare(ctx context.Context, res domain.ShareResource, resourceID, shareID uuid.UUID) (*domain.Share, error) {
+	share, err := s.repoFor(res).GetByID(ctx, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if share.ResourceID != resourceID {
+		return nil, domain.ErrNotFound
+	}
+	return share, nil
+}
+
+// getGroupShare loads a group share and checks that it belongs to the given
+// resource.
+func (s *ShareService) getGroupShare(ctx context.Context, res domain.ShareResource, resourceID, shareID uuid.UUID) (*domain.GroupShare, error) {
+	share, err := s.groupRepoFor(res).GetByID(ctx, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if share.ResourceID != resourceID {
+		return nil, domain.ErrNotFound
+	}
+	return share, nil
+}
+
+func parseShareRole(role string) (domain.ShareRole, error) {
+	r := domain.ShareRole(role)
+	if r == "" {
+		return domain.ShareRoleView, nil
+	}
+	if !r.Valid() {
+		return "", domain.NewValidationError(map[string]string{"role": "invalid_enum"})
+	}
+	return r, nil
+}
+
+// resourceOwner returns the current owner of a document or flow.
+func resourceOwner(ctx context.Context, docRepo repository.DocumentRepository, flowRepo repository.FlowRepository,
+	res domain.ShareResource, resourceID uuid.UUID) (uuid.UUID, error) {
+	if res == domain.ShareResourceFlow {
+		flow, err := flowRepo.GetByID(ctx, resourceID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return flow.OwnerID, nil
+	}
+	doc, err := docRepo.GetByID(ctx, resourceID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return doc.OwnerID, nil
+}
+
+// lookupUser finds a user by ID, or by email when no ID is given. A missing
+// user is reported as a validation error on field.
+func lookupUser(ctx context.Context, userRepo repository.UserRepository, field string, id uuid.UUID, email string) (*domain.User, error) {
+	var (
+		user *domain.User
+		err  error
+	)
+	switch {
+	case id != uuid.Nil:
+		user, err = userRepo.GetByID(ctx, id)
+	case email != "":
+		user, err = userRepo.GetByEmail(ctx, email)
+	default:
+		return nil, domain.NewValidationError(map[string]string{field: "required"})
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.NewValidationError(map[string]string{field: "user_not_found"})
+	}
+	return user, err
+}
@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func TestShareRules(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	other := e.user(t, "other@example.com")
+	flow := createFlow(t, e, owner, "F")
+	res := domain.ShareResourceFlow
+
+	_, err := e.shares.Create(e.ctx, other, res, flow.ID, service.CreateShareInput{UserID: owner})
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.shares.Create(e.ctx, owner, res, flow.ID, service.CreateShareInput{UserID: owner})
+	wantErr(t, err, domain.ErrInvalidInput)
+	_, err = e.shares.Create(e.ctx, owner, res, flow.ID, service.CreateShareInput{Email: "ghost@example.com"})
+	wantFields(t, err, map[string]string{"user_id": "user_not_found"})
+	_, err = e.shares.Create(e.ctx, owner, res, flow.ID, service.CreateShareInput{UserID: other, Role: "ADMIN"})
+	wantFields(t, err, map[string]string{"role": "invalid_enum"})
+
+	share, err := e.shares.Create(e.ctx, owner, res, flow.ID, service.CreateShareInput{Email: "other@example.com"})
+	mustNoErr(t, err)
+	if share.Role != domain.ShareRoleView || share.UserID != other {
+		t.Fatalf("got share %+v, want VIEW for other", share)
+	}
+	_, err = e.shares.Create(e.ctx, owner, res, flow.ID, service.CreateShareInput{UserID: other})
+	wantErr(t, err, domain.ErrAlreadyExists)
+
+	// Upgrading the share to EDIT grants edit access; deleting it revokes all access.
+	_, err = e.flows.SubmitReview(e.ctx, other, flow.ID)
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.shares.Update(e.ctx, owner, res, flow.ID, share.ID, service.UpdateShareInput{Role: "EDIT"})
+	mustNoErr(t, err)
+	_, err = e.flows.SubmitReview(e.ctx, other, flow.ID)
+	mustNoErr(t, err)
+
+	shares, err := e.shares.List(e.ctx, owner, res, flow.ID)
+	mustNoErr(t, err)
+	if len(shares) != 1 || shares[0].UserEmail != "other@example.com" || shares[0].Role != domain.ShareRoleEdit {
+		t.Fatalf("got shares %+v", shares)
+	}
+
+	mustNoErr(t, e.shares.Delete(e.ctx, owner, res, flow.ID, share.ID))
+	_, err = e.flows.GetDetail(e.ctx, other, flow.ID)
+	wantErr(t, err, domain.ErrForbidden)
+}
+
+func TestGroupShareRules(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	lead := e.user(t, "lead@example.com")
+	member := e.user(t, "member@example.com")
+	flow := createFlow(t, e, owner, "F")
+	res := domain.ShareResourceFlow
+
+	group, err := e.groupSvc.Create(e.ctx, lead, service.GroupInput{Name: "Quality"})
+	mustNoErr(t, err)
+	_, err = e.groupSvc.AddMember(e.ctx, lead, false, group.ID, service.AddGroupMemberInput{UserID: member})
+	mustNoErr(t, err)
+
+	_, err = e.shares.CreateGroupShare(e.ctx, lead, res, flow.ID, service.CreateGroupShareInput{GroupID: group.ID})
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.shares.CreateGroupShare(e.ctx, owner, res, flow.ID, service.CreateGroupShareInput{})
+	wantFields(t, err, map[string]string{"group_id": "required"})
+	_, err = e.shares.CreateGroupShare(e.ctx, owner, res, flow.ID, service.CreateGroupShareInput{GroupID: owner})
+	wantFields(t, err, map[string]string{"group_id": "group_not_found"})
+
+	share, err := e.shares.CreateGroupShare(e.ctx, owner, res, flow.ID, service.CreateGroupShareInput{GroupID: group.ID})
+	mustNoErr(t, err)
+	if share.Role != domain.ShareRoleView || share.GroupName != "Quality" {
+		t.Fatalf("got group share %+v, want VIEW for Quality", share)
+	}
+	_, err = e.shares.CreateGroupShare(e.ctx, owner, res, flow.ID, service.CreateGroupShareInput{GroupID: group.ID, Role: "EDIT"})
+	wantErr(t, err, domain.ErrAlreadyExists)
+
+	// Every member reads the flow; EDIT on the group lets them edit it.
+	for _, user := range []uuid.UUID{lead, member} {
+		_, err = e.flows.GetDetail(e.ctx, user, flow.ID)
+		mustNoErr(t, err)
+	}
+	_, err = e.flows.SubmitReview(e.ctx, member, flow.ID)
+	wantErr(t, err, domain.ErrForbidden)
+	_, err = e.shares.UpdateGroupShare(e.ctx, owner, res, flow.ID, share.ID, service.UpdateShareInput{Role: "EDIT"})
+	mustNoErr(t, err)
+	_, err = e.flows.SubmitReview(e.ctx, member, flow.ID)
+	mustNoErr(t, err)
+
+	// A share of another resource is not found through this one.
+	other := createFlow(t, e, owner, "G")
+	_, err = e.shares.UpdateGroupShare(e.ctx, owner, res, other.ID, share.ID, service.UpdateShareInput{Role: "VIEW"})
+	wantErr(t, err, domain.ErrNotFound)
+
+	shares, err := e.shares.ListGroupShares(e.ctx, owner, res, flow.ID)
+	mustNoErr(t, err)
+	if len(shares) != 1 || shares[0].Role != domain.ShareRoleEdit || shares[0].GroupID != group.ID {
+		t.Fatalf("got group shares %+v", shares)
+	}
+
+	// Leaving the group ends the member's access.
+	mustNoErr(t, e.groupSvc.RemoveMember(e.ctx, member, false, group.ID, member))
+	_, err = e.flows.GetDetail(e.ctx, member, flow.ID)
+	wantErr(t, err, domain.ErrForbidden)
+
+	mustNoErr(t, e.shares.DeleteGroupShare(e.ctx, owner, res, flow.ID, share.ID))
+	_, err = e.flows.GetDetail(e.ctx, lead, flow.ID)
+	wantErr(t, err, domain.ErrForbidden)
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token signing algorithms.
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+	AlgHS256 = "HS256" // a shared secret; nothing to publish or rotate
+)
+
+// maxPublishLead caps how long before it starts signing a new key is
+// published. Verifiers that cache the key set have that long to pick it up.
+const maxPublishLead = 24 * time.Hour
+
+// keyReloadInterval limits how often a token signed with an unknown key
+// makes the signer reload keys another instance may have created.
+const keyReloadInterval = 10 * time.Second
+
+// TokenSignerOptions configures a TokenSigner.
+type TokenSignerOptions struct {
+	Algorithm string        // AlgEdDSA, AlgRS256 or AlgHS256
+	Secret    string        // the HS256 key; unused otherwise
+	Rotation  time.Duration // how long a key signs before the next one takes over; 0 keeps the first key
+	// MaxTokenAge is the longest lifetime of a signed token. A key keeps
+	// verifying this long after it stops signing.
+	MaxTokenAge time.Duration
+}
+
+// TokenSigner signs and verifies the JWTs AuthService issues.
+//
+// With EdDSA or RS256 the keys are stored in the database, so that every
+// instance signs with the same key and verifies with all of them. Each key
+// has a kid and is published in the key set from its creation, a quarter
+// rotation period (at most a day) before it starts signing, until the tokens
+// it signed have expired. Instances that run Rotate concurrently may both
+// create a key; both are published and the one that activates last signs.
+type TokenSigner struct {
+	repo repository.SigningKeyRepository
+	opts TokenSignerOptions
+	lead time.Duration
+
+	mu         sync.RWMutex
+	keys       []*signingKey // in the order they activate
+	reloadedAt time.Time
+}
+
+type signingKey struct {
+	id          string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatesAt time.Time
+}
+
+// NewTokenSigner returns a signer. Keys are loaded, and the first one
+// created, by Rotate.
+func NewTokenSigner(repo repository.SigningKeyRepository, opts TokenSignerOptions) *TokenSigner {
+	opts.MaxTokenAge = max(opts.MaxTokenAge, twoFactorChallengeTTL)
+	return &TokenSigner{
+		repo: repo,
+		opts: opts,
+		lead: min(opts.Rotation/4, maxPublishLead),
+	}
+}
+
+// Sign signs claims with the current key, naming it in the kid header.
+func (s *TokenSigner) Sign(claims jwt.MapClaims) (string, error) {
+	if s.opts.Algorithm == AlgHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.opts.Secret))
+	}
+	key := s.current(time.Now())
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// Parse checks a token's signature and expiry and returns its claims. Only
+// the configured algorithm, or the asymmetric ones of stored keys, are
+// accepted.
+func (s *TokenSigner) Parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	methods := []string{AlgEdDSA, AlgRS256}
+	if s.opts.Algorithm == AlgHS256 {
+		methods = []string{AlgHS256}
+	}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		if s.opts.Algorithm == AlgHS256 {
+			return []byte(s.opts.Secret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		key := s.key(ctx, kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if key.method.Alg() != t.Method.Alg() {
+			return nil, fmt.Errorf("signing key %q is not for %s", kid, t.Method.Alg())
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods(methods))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the key set served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys tokens may be verified with, including a key
+// about to start signing. It is empty with HS256.
+func (s *TokenSigner) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range s.keys {
+		jwk := publicJWK(k.public)
+		jwk.Kid, jwk.Use, jwk.Alg = k.id, "sig", k.method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Rotate reloads the keys and brings them up to date as of now: it creates
+// the first key, a key of a newly configured algorithm, or the next key when
+// the newest is due for rotation, and deletes keys no token signed with can
+// still be valid.
+func (s *TokenSigner) Rotate(ctx context.Context, now time.Time) error {
+	if s.opts.Algorithm == AlgHS256 {
+		return nil
+	}
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	keys := s.keys
+	s.mu.RUnlock()
+
+	var live []*signingKey
+	for i, k := range keys {
+		switch {
+		case k.activatesAt.After(now) && k.method.Alg() != s.opts.Algorithm:
+			// Published for an algorithm no longer configured; it would
+			// never sign.
+		case i+1 < len(keys) && now.Sub(keys[i+1].activatesAt) > s.opts.MaxTokenAge:
+			// Replaced long enough ago that its tokens have expired.
+		default:
+			live = append(live, k)
+			continue
+		}
+		if err := s.repo.Delete(ctx, k.id); err != nil {
+			return err
+		}
+		log.Printf("[auth] retired signing key %s", k.id)
+	}
+
+	var newest *signingKey
+	if len(live) > 0 {
+		newest = live[len(live)-1]
+	}
+	switch {
+	case newest == nil || newest.method.Alg() != s.opts.Algorithm:
+		if err := s.create(ctx, now); err != nil {
+			return err
+		}
+	case s.opts.Rotation > 0 && !now.Before(newest.activatesAt.Add(s.opts.Rotation-s.lead)):
+		at := newest.activatesAt.Add(s.opts.Rotation)
+		if earliest := now.Add(s.lead); at.Before(earliest) {
+			at = earliest
+		}
+		if err := s.create(ctx, at); err != nil {
+			return err
+		}
+	default:
+		if len(live) == len(keys) {
+			return nil
+		}
+	}
+	return s.reload(ctx)
+}
+
+// RunRotationJob runs Rotate once immediately and then every interval until
+// ctx is cancelled. Between rotations it also picks up keys created by other
+// instances.
+func (s *TokenSigner) RunRotationJob(ctx context.Context, interval time.Duration) {
+	if s.opts.Algorithm == AlgHS256 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Rotate(ctx, time.Now()); err != nil {
+			log.Printf("[auth] rotating signing keys failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ---------- Internal ----------
+
+// current returns the key that signs at now: the last one activated.
+func (s *TokenSigner) current(now time.Time) *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].activatesAt.After(now) {
+			return s.keys[i]
+		}
+	}
+	return nil
+}
+
+// key returns the key with the given kid, reloading the keys once in a
+// while when it is not known.
+func (s *TokenSigner) key(ctx context.Context, kid string) *signingKey {
+	if k := s.lookup(kid); k != nil {
+		return k
+	}
+	s.mu.RLock()
+	stale := time.Since(s.reloadedAt) > keyReloadInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	if err := s.reload(ctx); err != nil {
+		log.Printf("[auth] reloading signing keys failed: %v", err)
+		return nil
+	}
+	return s.lookup(kid)
+}
+
+func (s *TokenSigner) lookup(kid string) *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.id == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+func (s *TokenSigner) reload(ctx context.Context) error {
+	stored, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]*signingKey, 0, len(stored))
+	for _, sk := range stored {
+		k, err := decodeSigningKey(sk)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", sk.ID, err)
+		}
+		keys = append(keys, k)
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.reloadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// create generates and stores a key of the configured algorithm that signs
+// from activatesAt. Its kid is the key's RFC 7638 thumbprint.
+func (s *TokenSigner) create(ctx context.Context, activatesAt time.Time) error {
+	var private crypto.Signer
+	var err error
+	switch s.opts.Algorithm {
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", s.opts.Algorithm)
+	}
+	if err != nil {
+		return fmt.Errorf("generating signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("encoding signing key: %w", err)
+	}
+	key := &domain.SigningKey{
+		ID:          thumbprint(publicJWK(private.Public())),
+		Algorithm:   s.opts.Algorithm,
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		ActivatesAt: activatesAt,
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return err
+	}
+	log.Printf("[auth] created %s signing key %s, signing from %s", key.Algorithm, key.ID, activatesAt.Format(time.RFC3339))
+	return nil
+}
+
+func decodeSigningKey(sk domain.SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(sk.PrivateKey))
+	if block == nil {
+		return nil, errors.New("private key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k := &signingKey{id: sk.ID, activatesAt: sk.ActivatesAt}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.method, k.private = jwt.SigningMethodEdDSA, private
+	case *rsa.PrivateKey:
+		k.method, k.private = jwt.SigningMethodRS256, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if k.method.Alg() != sk.Algorithm {
+		return nil, fmt.Errorf("%s key stored for %s", k.method.Alg(), sk.Algorithm)
+	}
+	k.public = k.private.Public()
+	return k, nil
+}
+
+// publicJWK returns the key type and parameters of a public key.
+func publicJWK(public crypto.PublicKey) JWK {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch public := public.(type) {
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: enc(public)}
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: enc(public.N.Bytes()), E: enc(big.NewInt(int64(public.E)).Bytes())}
+	}
+	return JWK{}
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of a key: the hash of its
+// required members in lexicographic order.
+func thumbprint(k JWK) string {
+	var canonical string
+	switch k.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository/memory"
+	"docmv/internal/service"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+// signWith signs test claims and returns the token and its kid.
+func signWith(t *testing.T, s *service.TokenSigner) (string, string) {
+	t.Helper()
+	signed, err := s.Sign(testClaims())
+	mustNoErr(t, err)
+	token, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	mustNoErr(t, err)
+	kid, _ := token.Header["kid"].(string)
+	return signed, kid
+}
+
+func kids(set service.JWKSet) []string {
+	var out []string
+	for _, k := range set.Keys {
+		out = append(out, k.Kid)
+	}
+	return out
+}
+
+func TestTokenSignerRotation(t *testing.T) {
+	e := newTestEnv(t)
+	repo := memory.NewSigningKeyRepo(memory.NewStore())
+	opts := service.TokenSignerOptions{Algorithm: service.AlgEdDSA, Rotation: 4 * time.Millisecond, MaxTokenAge: 15 * time.Minute}
+	s := service.NewTokenSigner(repo, opts)
+	// Another instance sharing the database, which never rotates itself and
+	// has not loaded any key yet.
+	other := service.NewTokenSigner(repo, opts)
+
+	_, err := s.Sign(testClaims())
+	if err == nil {
+		t.Fatal("signed without a key")
+	}
+	mustNoErr(t, s.Rotate(e.ctx, time.Now()))
+	first, firstKid := signWith(t, s)
+	if set := s.JWKS(); len(set.Keys) != 1 || set.Keys[0].Kid != firstKid || set.Keys[0].Alg != "EdDSA" || set.Keys[0].Crv != "Ed25519" {
+		t.Fatalf("key set %+v, token kid %s", set, firstKid)
+	}
+
+	// The next key is published before it signs, and the previous one keeps
+	// verifying the tokens it signed.
+	time.Sleep(4 * time.Millisecond)
+	mustNoErr(t, s.Rotate(e.ctx, time.Now()))
+	if len(s.JWKS().Keys) != 2 {
+		t.Fatalf("key set after rotation %v", kids(s.JWKS()))
+	}
+	time.Sleep(2 * time.Millisecond)
+	second, secondKid := signWith(t, s)
+	if secondKid == firstKid || secondKid != s.JWKS().Keys[1].Kid {
+		t.Fatalf("signed with %s after rotating from %s; keys %v", secondKid, firstKid, kids(s.JWKS()))
+	}
+	for _, token := range []string{first, second} {
+		_, err := s.Parse(e.ctx, token)
+		mustNoErr(t, err)
+	}
+	// other loads the keys when it sees a token signed with one it does not
+	// know.
+	claims, err := other.Parse(e.ctx, second)
+	mustNoErr(t, err)
+	if claims["sub"] != "u1" {
+		t.Fatalf("claims %v", claims)
+	}
+	_, err = other.Parse(e.ctx, first)
+	mustNoErr(t, err)
+
+	// Once tokens of the first key have expired, it is retired.
+	mustNoErr(t, s.Rotate(e.ctx, time.Now().Add(16*time.Minute)))
+	if k := kids(s.JWKS()); k[0] != secondKid {
+		t.Fatalf("key set after retiring %v", k)
+	}
+	_, err = s.Parse(e.ctx, first)
+	if err == nil {
+		t.Fatal("token of a retired key accepted")
+	}
+	_, err = s.Parse(e.ctx, second)
+	mustNoErr(t, err)
+}
+
+func TestTokenSignerRS256AndAlgorithmChange(t *testing.T) {
+	e := newTestEnv(t)
+	repo := memory.NewSigningKeyRepo(memory.NewStore())
+	rs := service.NewTokenSigner(repo, service.TokenSignerOptions{Algorithm: service.AlgRS256, Rotation: time.Hour, MaxTokenAge: time.Minute})
+	mustNoErr(t, rs.Rotate(e.ctx, time.Now()))
+	// Nothing is due yet.
+	mustNoErr(t, rs.Rotate(e.ctx, time.Now()))
+	signed, kid := signWith(t, rs)
+
+	// The published key verifies the token on its own.
+	set := rs.JWKS()
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "RSA" || set.Keys[0].Alg != "RS256" || set.Keys[0].Use != "sig" {
+		t.Fatalf("key set %+v", set)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(set.Keys[0].N)
+	exp, _ := base64.RawURLEncoding.DecodeString(set.Keys[0].E)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(exp).Int64())}
+	_, err := jwt.Parse(signed, func(*jwt.Token) (any, error) { return public, nil }, jwt.WithValidMethods([]string{"RS256"}))
+	mustNoErr(t, err)
+
+	// Switching to EdDSA signs with a new key at once, while RS256 tokens
+	// stay valid until they expire.
+	ed := service.NewTokenSigner(repo, service.TokenSignerOptions{Algorithm: service.AlgEdDSA, Rotation: time.Hour, MaxTokenAge: time.Minute})
+	mustNoErr(t, ed.Rotate(e.ctx, time.Now()))
+	edSigned, edKid := signWith(t, ed)
+	token, _, err := jwt.NewParser().ParseUnverified(edSigned, jwt.MapClaims{})
+	mustNoErr(t, err)
+	if edKid == kid || token.Method.Alg() != "EdDSA" {
+		t.Fatalf("signed with %s %s after switching from %s", token.Method.Alg(), edKid, kid)
+	}
+	_, err = ed.Parse(e.ctx, signed)
+	mustNoErr(t, err)
+	mustNoErr(t, ed.Rotate(e.ctx, time.Now().Add(6*time.Minute)))
+	if k := kids(ed.JWKS()); len(k) != 1 || k[0] != edKid {
+		t.Fatalf("key set after retiring the RS256 key %v", k)
+	}
+}
+
+func TestTokenSignerRejects(t *testing.T) {
+	e := newTestEnv(t)
+	repo := memory.NewSigningKeyRepo(memory.NewStore())
+	s := service.NewTokenSigner(repo, service.TokenSignerOptions{Algorithm: service.AlgEdDSA})
+	mustNoErr(t, s.Rotate(e.ctx, time.Now()))
+	_, kid := signWith(t, s)
+	x, _ := base64.RawURLEncoding.DecodeString(s.JWKS().Keys[0].X)
+
+	sign := func(method jwt.SigningMethod, key any, kid string) string {
+		token := jwt.NewWithClaims(method, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		mustNoErr(t, err)
+		return signed
+	}
+	_, stranger, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name, token string
+	}{
+		// The public key used as an HMAC secret.
+		{"algorithm confusion", sign(jwt.SigningMethodHS256, x, kid)},
+		{"unsigned", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, kid)},
+		{"unknown kid", sign(jwt.SigningMethodEdDSA, stranger, "unknown")},
+		{"no kid", sign(jwt.SigningMethodEdDSA, stranger, "")},
+		{"wrong key", sign(jwt.SigningMethodEdDSA, stranger, kid)},
+		{"shared secret", sign(jwt.SigningMethodHS256, []byte(testJWTSecret), "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Parse(e.ctx, tt.token); err == nil {
+				t.Fatal("accepted")
+			}
+		})
+	}
+
+	// HS256 has nothing to publish and accepts no asymmetric tokens.
+	if set := e.signer.JWKS(); len(set.Keys) != 0 {
+		t.Fatalf("HS256 key set %+v", set)
+	}
+	_, err := e.auth.VerifyAccessToken(e.ctx, sign(jwt.SigningMethodEdDSA, stranger, kid))
+	wantErr(t, err, domain.ErrUnauthorized)
+}
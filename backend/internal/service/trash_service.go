@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// TrashService lists and restores soft-deleted documents, flows and workflow
+// nodes, and purges them once the retention period has passed.
+type TrashService struct {
+	txm          repository.TxManager
+	docRepo      repository.DocumentRepository
+	flowRepo     repository.FlowRepository
+	nodeRepo     repository.WorkflowNodeRepository
+	transferRepo repository.OwnershipTransferRepository
+}
+
+func NewTrashService(txm repository.TxManager, docRepo repository.DocumentRepository, flowRepo repository.FlowRepository,
+	nodeRepo repository.WorkflowNodeRepository, transferRepo repository.OwnershipTransferRepository) *TrashService {
+	return &TrashService{txm: txm, docRepo: docRepo, flowRepo: flowRepo, nodeRepo: nodeRepo, transferRepo: transferRepo}
+}
+
+// TrashListing is a user's trash: documents and flows they own, plus nodes
+// deleted from documents they can edit.
+type TrashListing struct {
+	Documents []domain.Document     `json:"documents"`
+	Flows     []domain.Flow         `json:"flows"`
+	Nodes     []domain.WorkflowNode `json:"nodes"`
+}
+
+type PurgeResult struct {
+	Documents int64 `json:"documents"`
+	Flows     int64 `json:"flows"`
+	Nodes     int64 `json:"nodes"`
+}
+
+func (s *TrashService) List(ctx context.Context, userID uuid.UUID) (*TrashListing, error) {
+	docs, err := s.docRepo.ListDeletedByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.flowRepo.ListDeletedByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.nodeRepo.ListDeletedEditable(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TrashListing{Documents: docs, Flows: flows, Nodes: nodes}, nil
+}
+
+// RestoreDocument takes one of the user's documents out of the trash.
+func (s *TrashService) RestoreDocument(ctx context.Context, userID, docID uuid.UUID) (*domain.Document, error) {
+	ok, err := s.docRepo.Restore(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return s.docRepo.GetByID(ctx, docID)
+}
+
+// RestoreFlow takes one of the user's flows out of the trash.
+func (s *TrashService) RestoreFlow(ctx context.Context, userID, flowID uuid.UUID) (*domain.Flow, error) {
+	ok, err := s.flowRepo.Restore(ctx, flowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return s.flowRepo.GetByID(ctx, flowID)
+}
+
+// RestoreNode takes a workflow node out of the trash. Requires edit access to
+// its document, which must itself not be in the trash.
+func (s *TrashService) RestoreNode(ctx context.Context, userID, nodeID uuid.UUID) (*domain.WorkflowNode, error) {
+	node, err := s.nodeRepo.GetDeletedByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.docRepo.HasEditAccess(ctx, node.DocumentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	if err := s.nodeRepo.Restore(ctx, nodeID); err != nil {
+		return nil, err
+	}
+	node.DeletedAt = nil
+	return node, nil
+}
+
+// Purge hard-deletes everything trashed more than retention ago. Child rows
+// (versions, shares, nodes) are removed by ON DELETE CASCADE.
+func (s *TrashService) Purge(ctx context.Context, retention time.Duration) (*PurgeResult, error) {
+	before := time.Now().Add(-retention)
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var result PurgeResult
+	if result.Nodes, err = s.nodeRepo.PurgeDeletedTx(ctx, tx, before); err != nil {
+		return nil, err
+	}
+	if result.Documents, err = s.docRepo.PurgeDeletedTx(ctx, tx, before); err != nil {
+		return nil, err
+	}
+	if result.Flows, err = s.flowRepo.PurgeDeletedTx(ctx, tx, before); err != nil {
+		return nil, err
+	}
+	if result.Documents > 0 || result.Flows > 0 {
+		if err := s.transferRepo.DeleteOrphansTx(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, tx.Commit()
+}
+
+// RunPurgeJob purges the trash once immediately and then every interval
+// until ctx is cancelled.
+func (s *TrashService) RunPurgeJob(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.Purge(ctx, retention)
+		if err != nil {
+			log.Printf("[trash] purge failed: %v", err)
+		} else if result.Documents+result.Flows+result.Nodes > 0 {
+			log.Printf("[trash] purged %d documents, %d flows, %d nodes", result.Documents, result.Flows, result.Nodes)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
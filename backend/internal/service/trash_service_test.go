@@ -0,0 +1,125 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+)
+
+func TestTrashRestore(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	editor := e.user(t, "editor@example.com")
+	viewer := e.user(t, "viewer@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Handbook"})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, doc.ID, editor, domain.ShareRoleEdit)
+	e.share(t, owner, domain.ShareResourceDocument, doc.ID, viewer, domain.ShareRoleView)
+	node := createNode(t, e, owner, doc.ID, "Kickoff")
+	flow := createFlow(t, e, owner, "Onboarding")
+
+	mustNoErr(t, e.nodes.DeleteNode(e.ctx, editor, node.ID))
+	mustNoErr(t, e.flows.Delete(e.ctx, owner, flow.ID))
+
+	listing, err := e.trash.List(e.ctx, owner)
+	mustNoErr(t, err)
+	if len(listing.Documents) != 0 || len(listing.Flows) != 1 || len(listing.Nodes) != 1 {
+		t.Fatalf("owner's trash has %d documents, %d flows, %d nodes", len(listing.Documents), len(listing.Flows), len(listing.Nodes))
+	}
+	listing, err = e.trash.List(e.ctx, editor)
+	mustNoErr(t, err)
+	if len(listing.Flows) != 0 || len(listing.Nodes) != 1 {
+		t.Fatalf("editor's trash has %d flows, %d nodes", len(listing.Flows), len(listing.Nodes))
+	}
+
+	_, err = e.trash.RestoreNode(e.ctx, viewer, node.ID)
+	wantErr(t, err, domain.ErrForbidden)
+	restored, err := e.trash.RestoreNode(e.ctx, editor, node.ID)
+	mustNoErr(t, err)
+	if restored.DeletedAt != nil {
+		t.Fatalf("restored node still has deleted_at %v", restored.DeletedAt)
+	}
+	if _, err := e.nodes.GetNode(e.ctx, viewer, node.ID); err != nil {
+		t.Fatalf("restored node not readable: %v", err)
+	}
+
+	// Only the owner can take a flow out of the trash.
+	_, err = e.trash.RestoreFlow(e.ctx, editor, flow.ID)
+	wantErr(t, err, domain.ErrNotFound)
+	_, err = e.trash.RestoreFlow(e.ctx, owner, flow.ID)
+	mustNoErr(t, err)
+	_, err = e.trash.RestoreFlow(e.ctx, owner, flow.ID)
+	wantErr(t, err, domain.ErrNotFound)
+
+	// Nodes of a trashed document stay out of reach until it is restored.
+	mustNoErr(t, e.nodes.DeleteNode(e.ctx, owner, node.ID))
+	mustNoErr(t, e.docs.Delete(e.ctx, owner, doc.ID))
+	listing, err = e.trash.List(e.ctx, owner)
+	mustNoErr(t, err)
+	if len(listing.Documents) != 1 || len(listing.Nodes) != 0 {
+		t.Fatalf("owner's trash has %d documents, %d nodes", len(listing.Documents), len(listing.Nodes))
+	}
+	_, err = e.trash.RestoreDocument(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	listing, err = e.trash.List(e.ctx, owner)
+	mustNoErr(t, err)
+	if len(listing.Documents) != 0 || len(listing.Nodes) != 1 {
+		t.Fatalf("owner's trash has %d documents, %d nodes", len(listing.Documents), len(listing.Nodes))
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	heir := e.user(t, "heir@example.com")
+	kept, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Kept"})
+	mustNoErr(t, err)
+	keptNode := createNode(t, e, owner, kept.ID, "Kept node")
+	trashedNode := createNode(t, e, owner, kept.ID, "Trashed node")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Trashed"})
+	mustNoErr(t, err)
+	createNode(t, e, owner, doc.ID, "Child")
+	flow := createFlow(t, e, owner, "Trashed flow")
+	_, err = e.ownership.Transfer(e.ctx, owner, false, domain.ShareResourceDocument, kept.ID, service.TransferInput{NewOwnerID: heir})
+	mustNoErr(t, err)
+	_, err = e.ownership.Transfer(e.ctx, owner, false, domain.ShareResourceDocument, doc.ID, service.TransferInput{NewOwnerID: heir})
+	mustNoErr(t, err)
+
+	mustNoErr(t, e.nodes.DeleteNode(e.ctx, heir, trashedNode.ID))
+	mustNoErr(t, e.docs.Delete(e.ctx, heir, doc.ID))
+	mustNoErr(t, e.flows.Delete(e.ctx, owner, flow.ID))
+
+	// Nothing has been in the trash for an hour yet.
+	result, err := e.trash.Purge(e.ctx, time.Hour)
+	mustNoErr(t, err)
+	if *result != (service.PurgeResult{}) {
+		t.Fatalf("got %+v, want nothing purged", result)
+	}
+
+	result, err = e.trash.Purge(e.ctx, 0)
+	mustNoErr(t, err)
+	if *result != (service.PurgeResult{Documents: 1, Flows: 1, Nodes: 1}) {
+		t.Fatalf("got %+v, want one of each purged", result)
+	}
+	_, err = e.trash.RestoreDocument(e.ctx, heir, doc.ID)
+	wantErr(t, err, domain.ErrNotFound)
+
+	// Live rows and their ownership history survive.
+	nodes, err := e.nodes.ListNodes(e.ctx, heir, kept.ID)
+	mustNoErr(t, err)
+	if len(nodes) != 1 || nodes[0].ID != keptNode.ID {
+		t.Fatalf("got nodes %v, want only the live one", nodeNames(nodes))
+	}
+	history, err := e.ownership.ListTransfers(e.ctx, heir, domain.ShareResourceDocument, kept.ID)
+	mustNoErr(t, err)
+	if len(history) != 1 {
+		t.Fatalf("got %d transfers for the kept document, want 1", len(history))
+	}
+	listing, err := e.trash.List(e.ctx, heir)
+	mustNoErr(t, err)
+	if len(listing.Documents)+len(listing.Flows)+len(listing.Nodes) != 0 {
+		t.Fatalf("trash not empty after purge: %+v", listing)
+	}
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	recoveryCodeCount = 10
+	// settingRequireAdmin2FA holds "true" when ADMIN users must enroll.
+	settingRequireAdmin2FA = "require_2fa_for_admins"
+)
+
+// TwoFactorService manages TOTP enrollment and recovery codes, and checks
+// the second factor for AuthService. The TOTP secret is stored as is, since
+// checking a code needs it; recovery codes are stored hashed.
+//
+// Failed codes count against the account like failed passwords, so the
+// LoginGuard also limits guessing codes from a stolen session.
+type TwoFactorService struct {
+	userRepo    repository.UserRepository
+	codeRepo    repository.RecoveryCodeRepository
+	settingRepo repository.SettingRepository
+	guard       *LoginGuard
+	issuer      string
+}
+
+func NewTwoFactorService(userRepo repository.UserRepository, codeRepo repository.RecoveryCodeRepository, settingRepo repository.SettingRepository, guard *LoginGuard, issuer string) *TwoFactorService {
+	return &TwoFactorService{userRepo: userRepo, codeRepo: codeRepo, settingRepo: settingRepo, guard: guard, issuer: issuer}
+}
+
+// TwoFactorStatus describes a user's enrollment.
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Pending                bool `json:"pending"`  // set up but not confirmed yet
+	Required               bool `json:"required"` // the user's role requires two-factor authentication
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorSetup is what an authenticator app needs: the secret to type in,
+// or the URI to scan as a QR code.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodes are shown once; each of them replaces one TOTP code once.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// SecuritySettings are the two-factor settings admins control.
+type SecuritySettings struct {
+	RequireForAdmins bool `json:"require_2fa_for_admins"`
+}
+
+// Status returns the caller's enrollment.
+func (s *TwoFactorService) Status(ctx context.Context, userID uuid.UUID) (*TwoFactorStatus, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.required(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{
+		Enabled:  user.TwoFactorEnabled,
+		Pending:  !user.TwoFactorEnabled && user.TOTPSecret != "",
+		Required: required,
+	}
+	if user.TwoFactorEnabled {
+		if status.RecoveryCodesRemaining, err = s.codeRepo.CountUnused(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Setup starts enrollment with a new secret. It takes effect once Confirm
+// receives a code generated from it; calling Setup again replaces it.
+func (s *TwoFactorService) Setup(ctx context.Context, userID uuid.UUID) (*TwoFactorSetup, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", domain.ErrInvalidState)
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTwoFactor(ctx, userID, secret, false); err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{Secret: secret, URI: totpURI(s.issuer, user.Email, secret)}, nil
+}
+
+// Confirm enables two-factor authentication once code shows that the
+// caller's authenticator app holds the secret from Setup, and returns the
+// first set of recovery codes.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID uuid.UUID, code string) (*RecoveryCodes, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", domain.ErrInvalidState)
+	}
+	if user.TOTPSecret == "" {
+		return nil, fmt.Errorf("%w: set up two-factor authentication first", domain.ErrInvalidState)
+	}
+	code = normalizeCode(code)
+	if code == "" {
+		return nil, domain.NewValidationError(map[string]string{"code": "required"})
+	}
+	if err := s.guard.Check(ctx, user.Email, ""); err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		s.guard.Fail(ctx, user.Email, "")
+		return nil, domain.NewValidationError(map[string]string{"code": "invalid"})
+	}
+
+	if err := s.userRepo.SetTwoFactor(ctx, userID, user.TOTPSecret, true); err != nil {
+		return nil, err
+	}
+	// The confirming code must not also work for the next login.
+	if _, err := s.userRepo.UseTOTPStep(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// Disable removes the caller's enrollment after checking a current code.
+// Users whose role requires two-factor authentication cannot disable it.
+func (s *TwoFactorService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.enrolledUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	required, err := s.required(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("%w: two-factor authentication is required for your role", domain.ErrForbidden)
+	}
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return err
+	}
+	return s.clear(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, used or not,
+// after checking a current code.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) (*RecoveryCodes, error) {
+	user, err := s.enrolledUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// Reset removes another user's enrollment (admin-only), for users who lost
+// their authenticator and their recovery codes. If their role requires
+// two-factor authentication, they must enroll again after their next login.
+func (s *TwoFactorService) Reset(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return fmt.Errorf("%w: admins cannot reset their own two-factor authentication", domain.ErrForbidden)
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	return s.clear(ctx, userID)
+}
+
+// Settings returns the two-factor settings (admin-only).
+func (s *TwoFactorService) Settings(ctx context.Context) (*SecuritySettings, error) {
+	required, err := s.required(ctx, domain.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return &SecuritySettings{RequireForAdmins: required}, nil
+}
+
+// UpdateSettings changes the two-factor settings (admin-only). Requiring
+// two-factor authentication for admins does not end their sessions: those
+// not enrolled yet are limited to enrolling from their next request on.
+func (s *TwoFactorService) UpdateSettings(ctx context.Context, in SecuritySettings) (*SecuritySettings, error) {
+	if err := s.settingRepo.Set(ctx, settingRequireAdmin2FA, strconv.FormatBool(in.RequireForAdmins)); err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// ---------- Internal ----------
+
+// required reports whether users of role must enroll.
+func (s *TwoFactorService) required(ctx context.Context, role domain.Role) (bool, error) {
+	if role != domain.RoleAdmin {
+		return false, nil
+	}
+	value, err := s.settingRepo.Get(ctx, settingRequireAdmin2FA)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// setupRequired reports whether user must enroll before doing anything else.
+func (s *TwoFactorService) setupRequired(ctx context.Context, user *domain.User) (bool, error) {
+	if user.TwoFactorEnabled {
+		return false, nil
+	}
+	return s.required(ctx, user.Role)
+}
+
+// verify checks a TOTP code or an unused recovery code of an enrolled user
+// and consumes it: a TOTP code works for one login only, even within its
+// time step.
+func (s *TwoFactorService) verify(ctx context.Context, user *domain.User, code string) (bool, error) {
+	code = normalizeCode(code)
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.userRepo.UseTOTPStep(ctx, user.ID, step)
+	}
+	if code == "" {
+		return false, nil
+	}
+	return s.codeRepo.Use(ctx, user.ID, hashToken(code), time.Now())
+}
+
+// checkCode verifies a code for a self-service change, counting failures
+// against the account.
+func (s *TwoFactorService) checkCode(ctx context.Context, user *domain.User, code string) error {
+	if strings.TrimSpace(code) == "" {
+		return domain.NewValidationError(map[string]string{"code": "required"})
+	}
+	if err := s.guard.Check(ctx, user.Email, ""); err != nil {
+		return err
+	}
+	ok, err := s.verify(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.guard.Fail(ctx, user.Email, "")
+		return domain.NewValidationError(map[string]string{"code": "invalid"})
+	}
+	return nil
+}
+
+func (s *TwoFactorService) enrolledUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, fmt.Errorf("%w: two-factor authentication is not enabled", domain.ErrInvalidState)
+	}
+	return user, nil
+}
+
+func (s *TwoFactorService) clear(ctx context.Context, userID uuid.UUID) error {
+	if err := s.userRepo.SetTwoFactor(ctx, userID, "", false); err != nil {
+		return err
+	}
+	return s.codeRepo.Replace(ctx, userID, nil)
+}
+
+// newRecoveryCodes replaces the user's recovery codes with fresh ones. Codes
+// are 64 random bits written as four groups of four hex digits.
+func (s *TwoFactorService) newRecoveryCodes(ctx context.Context, userID uuid.UUID) (*RecoveryCodes, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		h := hex.EncodeToString(raw)
+		codes[i] = h[0:4] + "-" + h[4:8] + "-" + h[8:12] + "-" + h[12:16]
+		hashes[i] = hashToken(h)
+	}
+	if err := s.codeRepo.Replace(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+// normalizeCode drops the spaces and dashes users type or paste along with
+// a code.
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,288 @@
+package service_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// totp computes a code independently of the service, offset steps from the
+// current one.
+func totp(t *testing.T, secret string, offset int) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decoding secret %q: %v", secret, err)
+	}
+	return hotp(key, time.Now().Unix()/30+int64(offset))
+}
+
+func hotp(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[19] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[off:])&0x7fffffff)%1_000_000)
+}
+
+// freshStep waits out the end of the current TOTP step, so that codes for
+// the previous, current and next step all stay valid during a test.
+func freshStep() {
+	if left := 30 - time.Now().Unix()%30; left < 3 {
+		time.Sleep(time.Duration(left) * time.Second)
+	}
+}
+
+func TestTOTPReferenceVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits.
+	key := []byte("12345678901234567890")
+	for _, c := range []struct {
+		unix int64
+		want string
+	}{{59, "287082"}, {1111111109, "081804"}, {2000000000, "279037"}} {
+		if got := hotp(key, c.unix/30); got != c.want {
+			t.Fatalf("code at %d = %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+// enroll sets up and confirms two-factor authentication for userID, using
+// the code of the previous step, and returns the secret and recovery codes.
+func (e *testEnv) enroll(t *testing.T, userID uuid.UUID) (string, []string) {
+	t.Helper()
+	setup, err := e.twoFactor.Setup(e.ctx, userID)
+	mustNoErr(t, err)
+	codes, err := e.twoFactor.Confirm(e.ctx, userID, totp(t, setup.Secret, -1))
+	mustNoErr(t, err)
+	return setup.Secret, codes.Codes
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	freshStep()
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	_, err = e.twoFactor.Confirm(e.ctx, user.ID, "123456")
+	wantErr(t, err, domain.ErrInvalidState)
+
+	setup, err := e.twoFactor.Setup(e.ctx, user.ID)
+	mustNoErr(t, err)
+	uri, err := url.Parse(setup.URI)
+	mustNoErr(t, err)
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/DocMV:u@example.com" ||
+		uri.Query().Get("secret") != setup.Secret || uri.Query().Get("issuer") != "DocMV" {
+		t.Fatalf("got provisioning uri %s", setup.URI)
+	}
+	status, err := e.twoFactor.Status(e.ctx, user.ID)
+	mustNoErr(t, err)
+	if status.Enabled || !status.Pending {
+		t.Fatalf("after setup: %+v", status)
+	}
+
+	_, err = e.twoFactor.Confirm(e.ctx, user.ID, "")
+	wantFields(t, err, map[string]string{"code": "required"})
+	_, err = e.twoFactor.Confirm(e.ctx, user.ID, totp(t, setup.Secret, 5))
+	wantFields(t, err, map[string]string{"code": "invalid"})
+
+	codes, err := e.twoFactor.Confirm(e.ctx, user.ID, totp(t, setup.Secret, 0))
+	mustNoErr(t, err)
+	if len(codes.Codes) != 10 || len(codes.Codes[0]) != 19 {
+		t.Fatalf("got recovery codes %v", codes.Codes)
+	}
+	status, err = e.twoFactor.Status(e.ctx, user.ID)
+	mustNoErr(t, err)
+	if !status.Enabled || status.Pending || status.RecoveryCodesRemaining != 10 {
+		t.Fatalf("after confirming: %+v", status)
+	}
+
+	_, err = e.twoFactor.Setup(e.ctx, user.ID)
+	wantErr(t, err, domain.ErrInvalidState)
+
+	// The code that confirmed enrollment cannot be replayed to log in.
+	res, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	_, err = e.auth.CompleteLogin(e.ctx, res.TwoFactor.ChallengeToken, totp(t, setup.Secret, 0), "")
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	freshStep()
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	secret, recovery := e.enroll(t, user.ID)
+
+	// The password alone yields a challenge, which is not an access token.
+	res, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	if res.AuthResult != nil || res.TwoFactor == nil || res.TwoFactor.ExpiresIn != 300 {
+		t.Fatalf("got login result %+v", res)
+	}
+	challenge := res.TwoFactor.ChallengeToken
+	_, err = e.auth.VerifyAccessToken(e.ctx, challenge)
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	_, err = e.auth.CompleteLogin(e.ctx, challenge, totp(t, secret, 5), "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	_, err = e.auth.CompleteLogin(e.ctx, "not-a-challenge", totp(t, secret, 0), "")
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	session, err := e.auth.CompleteLogin(e.ctx, challenge, totp(t, secret, 0), "")
+	mustNoErr(t, err)
+	principal, err := e.auth.VerifyAccessToken(e.ctx, session.Token)
+	mustNoErr(t, err)
+	if principal.UserID != user.ID || principal.TwoFactorSetupRequired {
+		t.Fatalf("got principal %+v", principal)
+	}
+	// Each code works once.
+	_, err = e.auth.CompleteLogin(e.ctx, challenge, totp(t, secret, 0), "")
+	wantErr(t, err, domain.ErrUnauthorized)
+
+	// Recovery codes are accepted once, however they are typed.
+	typed := strings.ToUpper(strings.ReplaceAll(recovery[0], "-", " "))
+	_, err = e.auth.CompleteLogin(e.ctx, challenge, typed, "")
+	mustNoErr(t, err)
+	_, err = e.auth.CompleteLogin(e.ctx, challenge, recovery[0], "")
+	wantErr(t, err, domain.ErrUnauthorized)
+	status, err := e.twoFactor.Status(e.ctx, user.ID)
+	mustNoErr(t, err)
+	if status.RecoveryCodesRemaining != 9 {
+		t.Fatalf("got %d recovery codes left, want 9", status.RecoveryCodesRemaining)
+	}
+
+	// Revoking the user's sessions also voids pending challenges.
+	mustNoErr(t, e.auth.ResetPassword(e.ctx, user.ID, "secret2"))
+	_, err = e.auth.CompleteLogin(e.ctx, challenge, recovery[1], "")
+	wantErr(t, err, domain.ErrUnauthorized)
+}
+
+func TestTwoFactorWrongCodesLockAccount(t *testing.T) {
+	freshStep()
+	e := newTestEnv(t)
+	auth := e.authWith(service.LockoutPolicy{MaxAccountFailures: 2, Lockout: time.Minute}, testPasswordPolicy())
+	user, err := auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	secret, _ := e.enroll(t, user.ID)
+
+	res, err := auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = auth.CompleteLogin(e.ctx, res.TwoFactor.ChallengeToken, "000000", "")
+		wantErr(t, err, domain.ErrUnauthorized)
+	}
+	_, err = auth.CompleteLogin(e.ctx, res.TwoFactor.ChallengeToken, totp(t, secret, 0), "")
+	wantRateLimited(t, err)
+	// A correct password does not lift the lockout either.
+	_, err = auth.Login(e.ctx, "u@example.com", "secret1", "")
+	wantRateLimited(t, err)
+}
+
+func TestTwoFactorDisableAndRegenerate(t *testing.T) {
+	freshStep()
+	e := newTestEnv(t)
+	user, err := e.auth.CreateUser(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	secret, recovery := e.enroll(t, user.ID)
+
+	_, err = e.twoFactor.RegenerateRecoveryCodes(e.ctx, user.ID, "000000")
+	wantFields(t, err, map[string]string{"code": "invalid"})
+	fresh, err := e.twoFactor.RegenerateRecoveryCodes(e.ctx, user.ID, totp(t, secret, 0))
+	mustNoErr(t, err)
+	wantFields(t, e.twoFactor.Disable(e.ctx, user.ID, recovery[0]), map[string]string{"code": "invalid"})
+
+	mustNoErr(t, e.twoFactor.Disable(e.ctx, user.ID, fresh.Codes[0]))
+	status, err := e.twoFactor.Status(e.ctx, user.ID)
+	mustNoErr(t, err)
+	if status.Enabled || status.Pending || status.RecoveryCodesRemaining != 0 {
+		t.Fatalf("after disabling: %+v", status)
+	}
+	wantErr(t, e.twoFactor.Disable(e.ctx, user.ID, totp(t, secret, 1)), domain.ErrInvalidState)
+
+	res, err := e.auth.Login(e.ctx, "u@example.com", "secret1", "")
+	mustNoErr(t, err)
+	if res.AuthResult == nil || res.TwoFactor != nil {
+		t.Fatalf("got login result %+v after disabling", res)
+	}
+}
+
+func TestTwoFactorRequiredForAdmins(t *testing.T) {
+	freshStep()
+	e := newTestEnv(t)
+	root, err := e.auth.CreateUser(e.ctx, "root@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+	admin, err := e.auth.CreateUser(e.ctx, "admin@example.com", "secret1", "ADMIN")
+	mustNoErr(t, err)
+	_, err = e.auth.CreateUser(e.ctx, "user@example.com", "secret1", "")
+	mustNoErr(t, err)
+
+	session, err := e.auth.Login(e.ctx, "admin@example.com", "secret1", "")
+	mustNoErr(t, err)
+	pat, err := e.apiTokens.Create(e.ctx, admin.ID, domain.RoleAdmin, service.CreateAPITokenInput{Name: "ci", Scopes: []domain.TokenScope{domain.ScopeRead}})
+	mustNoErr(t, err)
+	settings, err := e.twoFactor.UpdateSettings(e.ctx, service.SecuritySettings{RequireForAdmins: true})
+	mustNoErr(t, err)
+	if !settings.RequireForAdmins {
+		t.Fatalf("got settings %+v", settings)
+	}
+
+	// Sessions of admins without two-factor authentication are limited to
+	// enrolling, from their next request on.
+	principal, err := e.auth.VerifyAccessToken(e.ctx, session.Token)
+	mustNoErr(t, err)
+	if !principal.TwoFactorSetupRequired {
+		t.Fatalf("admin without 2FA not flagged")
+	}
+	principal, err = e.apiTokens.VerifyAccessToken(e.ctx, pat.Token)
+	mustNoErr(t, err)
+	if !principal.TwoFactorSetupRequired {
+		t.Fatalf("api token of admin without 2FA not flagged")
+	}
+	res, err := e.auth.Login(e.ctx, "admin@example.com", "secret1", "")
+	mustNoErr(t, err)
+	if !res.TwoFactorSetupRequired {
+		t.Fatalf("login result does not ask to enroll")
+	}
+	res, err = e.auth.Login(e.ctx, "user@example.com", "secret1", "")
+	mustNoErr(t, err)
+	if res.TwoFactorSetupRequired {
+		t.Fatalf("USER asked to enroll")
+	}
+
+	secret, _ := e.enroll(t, admin.ID)
+	principal, err = e.auth.VerifyAccessToken(e.ctx, session.Token)
+	mustNoErr(t, err)
+	if principal.TwoFactorSetupRequired {
+		t.Fatalf("enrolled admin still flagged")
+	}
+	principal, err = e.apiTokens.VerifyAccessToken(e.ctx, pat.Token)
+	mustNoErr(t, err)
+	if principal.TwoFactorSetupRequired {
+		t.Fatalf("api token of enrolled admin still flagged")
+	}
+	err = e.twoFactor.Disable(e.ctx, admin.ID, totp(t, secret, 0))
+	wantErr(t, err, domain.ErrForbidden)
+
+	// Another admin can reset a lost enrollment; the admin must enroll again.
+	wantErr(t, e.twoFactor.Reset(e.ctx, admin.ID, admin.ID), domain.ErrForbidden)
+	wantErr(t, e.twoFactor.Reset(e.ctx, root.ID, uuid.New()), domain.ErrNotFound)
+	mustNoErr(t, e.twoFactor.Reset(e.ctx, root.ID, admin.ID))
+	res, err = e.auth.Login(e.ctx, "admin@example.com", "secret1", "")
+	mustNoErr(t, err)
+	if res.AuthResult == nil || !res.TwoFactorSetupRequired {
+		t.Fatalf("got login result %+v after reset", res)
+	}
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"docmv/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// FieldChange is a single field whose value differs between two versions.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// NodeChange lists the field changes of a node present in both versions.
+// MatchedBy is "id" or "node_no".
+type NodeChange struct {
+	NodeID    uuid.UUID     `json:"node_id"`
+	NodeNo    string        `json:"node_no"`
+	Name      string        `json:"name"`
+	MatchedBy string        `json:"matched_by"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// DiagramElementChange lists the property changes of a diagram node or edge.
+type DiagramElementChange struct {
+	ID      string        `json:"id"`
+	Changes []FieldChange `json:"changes"`
+}
+
+type DiagramDiff struct {
+	NodesAdded    []json.RawMessage      `json:"nodes_added"`
+	NodesRemoved  []json.RawMessage      `json:"nodes_removed"`
+	NodesModified []DiagramElementChange `json:"nodes_modified"`
+	EdgesAdded    []json.RawMessage      `json:"edges_added"`
+	EdgesRemoved  []json.RawMessage      `json:"edges_removed"`
+	EdgesModified []DiagramElementChange `json:"edges_modified"`
+}
+
+// FlowVersionDiff describes what changed from one flow version to another.
+type FlowVersionDiff struct {
+	FromVersionID uuid.UUID         `json:"from_version_id"`
+	ToVersionID   uuid.UUID         `json:"to_version_id"`
+	Header        []FieldChange     `json:"header"`
+	NodesAdded    []domain.FlowNode `json:"nodes_added"`
+	NodesRemoved  []domain.FlowNode `json:"nodes_removed"`
+	NodesModified []NodeChange      `json:"nodes_modified"`
+	Diagram       DiagramDiff       `json:"diagram"`
+}
+
+// LineChange is one line of a line-based text diff. Op is "equal", "insert" or "delete".
+type LineChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DocumentVersionDiff describes what changed from one document version to another.
+type DocumentVersionDiff struct {
+	FromVersionID uuid.UUID    `json:"from_version_id"`
+	ToVersionID   uuid.UUID    `json:"to_version_id"`
+	LinesAdded    int          `json:"lines_added"`
+	LinesRemoved  int          `json:"lines_removed"`
+	Lines         []LineChange `json:"lines"`
+}
+
+// ---------- Flow diff ----------
+
+// diffFlowSnapshots compares two published snapshots.
+func diffFlowSnapshots(from, to *domain.FlowSnapshot) *FlowVersionDiff {
+	d := &FlowVersionDiff{
+		Header:        []FieldChange{},
+		NodesAdded:    []domain.FlowNode{},
+		NodesRemoved:  []domain.FlowNode{},
+		NodesModified: []NodeChange{},
+	}
+
+	a, b := from.Flow, to.Flow
+	d.Header = appendChange(d.Header, "title", a.Title, b.Title)
+	d.Header = appendChange(d.Header, "owner_id", a.OwnerID, b.OwnerID)
+	d.Header = appendChange(d.Header, "owner_dept_id", a.OwnerDeptID, b.OwnerDeptID)
+	d.Header = appendChange(d.Header, "overview", a.Overview, b.Overview)
+
+	diffFlowNodes(d, from.Nodes, to.Nodes)
+	d.Diagram = diffDiagrams(a.DiagramJSON, b.DiagramJSON)
+	return d
+}
+
+// diffFlowNodes matches nodes by ID first, then by node_no among the rest.
+func diffFlowNodes(d *FlowVersionDiff, before, after []domain.FlowNode) {
+	matched := make(map[int]bool) // indexes into before
+	pairs := make([][2]int, 0)
+	matchedBy := make([]string, 0)
+	unmatchedAfter := make([]int, 0)
+
+	byID := make(map[uuid.UUID]int, len(before))
+	for i, n := range before {
+		byID[n.ID] = i
+	}
+	for j, n := range after {
+		if i, ok := byID[n.ID]; ok {
+			matched[i] = true
+			pairs = append(pairs, [2]int{i, j})
+			matchedBy = append(matchedBy, "id")
+			continue
+		}
+		unmatchedAfter = append(unmatchedAfter, j)
+	}
+
+	byNo := make(map[string]int)
+	for i, n := range before {
+		if !matched[i] && n.NodeNo != "" {
+			if _, dup := byNo[n.NodeNo]; !dup {
+				byNo[n.NodeNo] = i
+			}
+		}
+	}
+	for _, j := range unmatchedAfter {
+		n := after[j]
+		if i, ok := byNo[n.NodeNo]; ok && n.NodeNo != "" {
+			delete(byNo, n.NodeNo)
+			matched[i] = true
+			pairs = append(pairs, [2]int{i, j})
+			matchedBy = append(matchedBy, "node_no")
+			continue
+		}
+		d.NodesAdded = append(d.NodesAdded, n)
+	}
+
+	for i, n := range before {
+		if !matched[i] {
+			d.NodesRemoved = append(d.NodesRemoved, n)
+		}
+	}
+
+	for k, p := range pairs {
+		changes := diffFlowNode(&before[p[0]], &after[p[1]])
+		if len(changes) == 0 {
+			continue
+		}
+		n := after[p[1]]
+		d.NodesModified = append(d.NodesModified, NodeChange{
+			NodeID:    n.ID,
+			NodeNo:    n.NodeNo,
+			Name:      n.Name,
+			MatchedBy: matchedBy[k],
+			Changes:   changes,
+		})
+	}
+}
+
+func diffFlowNode(a, b *domain.FlowNode) []FieldChange {
+	c := make([]FieldChange, 0)
+	c = appendChange(c, "node_no", a.NodeNo, b.NodeNo)
+	c = appendChange(c, "name", a.Name, b.Name)
+	c = appendChange(c, "intro", a.Intro, b.Intro)
+	c = appendChange(c, "exec_form", a.ExecForm, b.ExecForm)
+	c = appendChange(c, "duration_min", a.DurationMin, b.DurationMin)
+	c = appendChange(c, "duration_max", a.DurationMax, b.DurationMax)
+	c = appendChange(c, "duration_unit", a.DurationUnit, b.DurationUnit)
+	c = appendChange(c, "prereq_text", a.PrereqText, b.PrereqText)
+	c = appendChange(c, "outputs_text", a.OutputsText, b.OutputsText)
+	c = appendChange(c, "sort_order", a.SortOrder, b.SortOrder)
+
+	ra, rb := parseRACI(a.RaciJSON), parseRACI(b.RaciJSON)
+	c = appendChange(c, "raci.R", ra.R, rb.R)
+	c = appendChange(c, "raci.A", ra.A, rb.A)
+	c = appendChange(c, "raci.S", ra.S, rb.S)
+	c = appendChange(c, "raci.C", ra.C, rb.C)
+	c = appendChange(c, "raci.I", ra.I, rb.I)
+
+	c = appendChange(c, "subtasks", parseStringList(a.SubtasksJSON), parseStringList(b.SubtasksJSON))
+	return c
+}
+
+// appendChange appends a FieldChange if before and after differ. Nil pointers
+// are reported as null, non-nil pointers by value.
+func appendChange(changes []FieldChange, field string, before, after interface{}) []FieldChange {
+	before, after = deref(before), deref(after)
+	if reflect.DeepEqual(before, after) {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, Before: before, After: after})
+}
+
+func deref(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+func parseRACI(raw string) domain.RACI {
+	var r domain.RACI
+	_ = json.Unmarshal([]byte(raw), &r)
+	r.Normalize()
+	return r
+}
+
+func parseStringList(raw string) []string {
+	var list []string
+	_ = json.Unmarshal([]byte(raw), &list)
+	if list == nil {
+		list = []string{}
+	}
+	return list
+}
+
+// ---------- Diagram diff ----------
+
+type diagramElement struct {
+	key   string
+	raw   json.RawMessage
+	props map[string]interface{}
+}
+
+func diffDiagrams(before, after string) DiagramDiff {
+	a, b := parseDiagram(before), parseDiagram(after)
+	d := DiagramDiff{}
+	d.NodesAdded, d.NodesRemoved, d.NodesModified = diffElements(a.Nodes, b.Nodes, false)
+	d.EdgesAdded, d.EdgesRemoved, d.EdgesModified = diffElements(a.Edges, b.Edges, true)
+	return d
+}
+
+func parseDiagram(raw string) domain.DiagramJSON {
+	var d domain.DiagramJSON
+	_ = json.Unmarshal([]byte(raw), &d)
+	d.Normalize()
+	return d
+}
+
+// diffElements matches diagram elements by "id". Edges without an id fall
+// back to "source->target".
+func diffElements(before, after []json.RawMessage, isEdge bool) (added, removed []json.RawMessage, modified []DiagramElementChange) {
+	added, removed, modified = []json.RawMessage{}, []json.RawMessage{}, []DiagramElementChange{}
+
+	index := func(list []json.RawMessage) ([]diagramElement, map[string]int) {
+		elems := make([]diagramElement, 0, len(list))
+		pos := make(map[string]int, len(list))
+		for _, raw := range list {
+			var props map[string]interface{}
+			if err := json.Unmarshal(raw, &props); err != nil {
+				continue
+			}
+			key, _ := props["id"].(string)
+			if key == "" && isEdge {
+				src, _ := props["source"].(string)
+				dst, _ := props["target"].(string)
+				key = src + "->" + dst
+			}
+			pos[key] = len(elems)
+			elems = append(elems, diagramElement{key: key, raw: raw, props: props})
+		}
+		return elems, pos
+	}
+
+	ea, pa := index(before)
+	eb, pb := index(after)
+
+	for _, e := range eb {
+		i, ok := pa[e.key]
+		if !ok {
+			added = append(added, e.raw)
+			continue
+		}
+		if changes := diffProps(ea[i].props, e.props); len(changes) > 0 {
+			modified = append(modified, DiagramElementChange{ID: e.key, Changes: changes})
+		}
+	}
+	for _, e := range ea {
+		if _, ok := pb[e.key]; !ok {
+			removed = append(removed, e.raw)
+		}
+	}
+	return added, removed, modified
+}
+
+func diffProps(a, b map[string]interface{}) []FieldChange {
+	keys := make([]string, 0, len(a)+len(b))
+	seen := make(map[string]bool)
+	for k := range a {
+		keys = append(keys, k)
+		seen[k] = true
+	}
+	for k := range b {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]FieldChange, 0)
+	for _, k := range keys {
+		changes = appendChange(changes, k, a[k], b[k])
+	}
+	return changes
+}
+
+// ---------- Text diff ----------
+
+// maxLineDiffCells bounds the LCS table; larger inputs are reported as a full replacement.
+const maxLineDiffCells = 4_000_000
+
+// diffLines computes a line-based diff using the longest common subsequence.
+func diffLines(before, after string) *DocumentVersionDiff {
+	a, b := splitLines(before), splitLines(after)
+	d := &DocumentVersionDiff{Lines: make([]LineChange, 0, len(a)+len(b))}
+
+	if len(a)*len(b) > maxLineDiffCells {
+		for _, l := range a {
+			d.Lines = append(d.Lines, LineChange{Op: "delete", Text: l})
+		}
+		for _, l := range b {
+			d.Lines = append(d.Lines, LineChange{Op: "insert", Text: l})
+		}
+		d.LinesRemoved, d.LinesAdded = len(a), len(b)
+		return d
+	}
+
+	// lcs[i][j] = LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			d.Lines = append(d.Lines, LineChange{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			d.Lines = append(d.Lines, LineChange{Op: "insert", Text: b[j]})
+			d.LinesAdded++
+			j++
+		default:
+			d.Lines = append(d.Lines, LineChange{Op: "delete", Text: a[i]})
+			d.LinesRemoved++
+			i++
+		}
+	}
+	return d
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"docmv/internal/domain"
+	"docmv/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type WorkflowNodeService struct {
+	txm      repository.TxManager
+	nodeRepo repository.WorkflowNodeRepository
+	docRepo  repository.DocumentRepository
+}
+
+func NewWorkflowNodeService(txm repository.TxManager, nodeRepo repository.WorkflowNodeRepository, docRepo repository.DocumentRepository) *WorkflowNodeService {
+	return &WorkflowNodeService{txm: txm, nodeRepo: nodeRepo, docRepo: docRepo}
+}
+
+// NodeInput holds parameters for creating or updating a workflow node.
+type NodeInput struct {
+	Name          string              `json:"name"`
+	ExecForm      domain.ExecForm     `json:"exec_form"`
+	Description   string              `json:"description"`
+	Preconditions string              `json:"preconditions"`
+	Outputs       string              `json:"outputs"`
+	DurationMin   *float64            `json:"duration_min"`
+	DurationMax   *float64            `json:"duration_max"`
+	DurationUnit  domain.DurationUnit `json:"duration_unit"`
+	Raci          *domain.RACI        `json:"raci"`
+	Subtasks      []string            `json:"subtasks"`
+	DiagramJSON   *domain.DiagramJSON `json:"diagram_json"`
+	Revision      *int                `json:"revision"` // required on update; the handler fills it from If-Match when present
+}
+
+// validateNodeInput performs field-level validation and returns a ValidationError if any fields are invalid.
+func validateNodeInput(in *NodeInput) error {
+	fields := make(map[string]string)
+
+	if in.Name == "" {
+		fields["name"] = "required"
+	}
+	if in.ExecForm == "" {
+		fields["exec_form"] = "required"
+	} else if !in.ExecForm.Valid() {
+		fields["exec_form"] = "invalid_enum"
+	}
+
+	if in.DurationUnit != "" && !in.DurationUnit.Valid() {
+		fields["duration_unit"] = "invalid_enum"
+	}
+
+	if in.DurationMin != nil && in.DurationMax != nil {
+		if *in.DurationMin < 0 {
+			fields["duration_min"] = "must_be_non_negative"
+		}
+		if *in.DurationMax < 0 {
+			fields["duration_max"] = "must_be_non_negative"
+		}
+		if *in.DurationMin > *in.DurationMax {
+			fields["duration"] = "min_gt_max"
+		}
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+	return nil
+}
+
+// normalizeInput fills defaults for optional fields.
+func normalizeInput(in *NodeInput) {
+	if in.Raci == nil {
+		in.Raci = &domain.RACI{}
+	}
+	in.Raci.Normalize()
+
+	if in.Subtasks == nil {
+		in.Subtasks = []string{}
+	}
+
+	if in.DiagramJSON == nil {
+		in.DiagramJSON = &domain.DiagramJSON{}
+	}
+	in.DiagramJSON.Normalize()
+
+	if in.DurationUnit == "" {
+		in.DurationUnit = domain.DurationUnitDay
+	}
+}
+
+// toNode converts validated input to a WorkflowNode, serialising JSON fields.
+func toNode(in *NodeInput) *domain.WorkflowNode {
+	raciBytes, _ := json.Marshal(in.Raci)
+	subtasksBytes, _ := json.Marshal(in.Subtasks)
+	diagramBytes, _ := json.Marshal(in.DiagramJSON)
+
+	return &domain.WorkflowNode{
+		Name:          in.Name,
+		ExecForm:      in.ExecForm,
+		Description:   in.Description,
+		Preconditions: in.Preconditions,
+		Outputs:       in.Outputs,
+		DurationMin:   in.DurationMin,
+		DurationMax:   in.DurationMax,
+		DurationUnit:  in.DurationUnit,
+		RaciJSON:      string(raciBytes),
+		SubtasksJSON:  string(subtasksBytes),
+		DiagramRaw:    string(diagramBytes),
+		Raci:          *in.Raci,
+		Subtasks:      in.Subtasks,
+		DiagramJSON:   *in.DiagramJSON,
+	}
+}
+
+// CreateNode creates a new workflow node associated with a document.
+func (s *WorkflowNodeService) CreateNode(ctx context.Context, userID, docID uuid.UUID, in NodeInput) (*domain.WorkflowNode, error) {
+	// Verify document edit access
+	ok, err := s.docRepo.HasEditAccess(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	normalizeInput(&in)
+	if err := validateNodeInput(&in); err != nil {
+		return nil, err
+	}
+
+	tx, txErr := s.txm.Begin(ctx)
+	if txErr != nil {
+		return nil, txErr
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	node := toNode(&in)
+	node.DocumentID = docID
+
+	if err := s.nodeRepo.CreateTx(ctx, tx, node); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// UpdateNode updates an existing workflow node.
+func (s *WorkflowNodeService) UpdateNode(ctx context.Context, userID, nodeID uuid.UUID, in NodeInput) (*domain.WorkflowNode, error) {
+	existing, err := s.nodeRepo.GetByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify document edit access
+	ok, err := s.docRepo.HasEditAccess(ctx, existing.DocumentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	if err := checkRevision(in.Revision, existing.Revision); err != nil {
+		return nil, s.staleError(ctx, nodeID, err)
+	}
+
+	normalizeInput(&in)
+	if err := validateNodeInput(&in); err != nil {
+		return nil, err
+	}
+
+	tx, txErr := s.txm.Begin(ctx)
+	if txErr != nil {
+		return nil, txErr
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	node := toNode(&in)
+	node.ID = existing.ID
+	node.DocumentID = existing.DocumentID
+	node.SortOrder = existing.SortOrder
+	node.Revision = existing.Revision
+	node.CreatedAt = existing.CreatedAt
+
+	if err := s.nodeRepo.UpdateTx(ctx, tx, node); err != nil {
+		return nil, s.staleError(ctx, nodeID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// staleError upgrades ErrStaleRevision to a StaleRevisionError carrying the
+// current node. Other errors are returned unchanged.
+func (s *WorkflowNodeService) staleError(ctx context.Context, nodeID uuid.UUID, err error) error {
+	if !errors.Is(err, domain.ErrStaleRevision) {
+		return err
+	}
+	current, loadErr := s.nodeRepo.GetByID(ctx, nodeID)
+	if loadErr != nil {
+		return loadErr
+	}
+	return &domain.StaleRevisionError{Revision: current.Revision, Current: current}
+}
+
+// DeleteNode moves a workflow node to the trash. Requires document edit access.
+func (s *WorkflowNodeService) DeleteNode(ctx context.Context, userID, nodeID uuid.UUID) error {
+	node, err := s.nodeRepo.GetByID(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.docRepo.HasEditAccess(ctx, node.DocumentID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrForbidden
+	}
+
+	return s.nodeRepo.SoftDelete(ctx, nodeID)
+}
+
+// ReorderNodesInput lists every live node of a document in the desired order.
+type ReorderNodesInput struct {
+	NodeIDs []uuid.UUID `json:"node_ids"`
+}
+
+// ReorderNodes rewrites sort_order for all nodes of a document in one
+// transaction. The list must contain each live node exactly once.
+func (s *WorkflowNodeService) ReorderNodes(ctx context.Context, userID, docID uuid.UUID, in ReorderNodesInput) ([]domain.WorkflowNode, error) {
+	ok, err := s.docRepo.HasEditAccess(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	tx, err := s.txm.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	existing, err := s.nodeRepo.ListIDsByDocumentTx(ctx, tx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if !samePermutation(existing, in.NodeIDs) {
+		return nil, domain.NewValidationError(map[string]string{"node_ids": "must_list_every_node_once"})
+	}
+
+	if err := s.nodeRepo.UpdateSortOrderTx(ctx, tx, docID, in.NodeIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.nodeRepo.ListByDocument(ctx, docID)
+}
+
+// samePermutation reports whether ordered contains exactly the IDs in set,
+// each once.
+func samePermutation(set, ordered []uuid.UUID) bool {
+	if len(set) != len(ordered) {
+		return false
+	}
+	remaining := make(map[uuid.UUID]bool, len(set))
+	for _, id := range set {
+		remaining[id] = true
+	}
+	for _, id := range ordered {
+		if !remaining[id] {
+			return false
+		}
+		delete(remaining, id)
+	}
+	return true
+}
+
+// GetNode returns a single workflow node with access check.
+func (s *WorkflowNodeService) GetNode(ctx context.Context, userID, nodeID uuid.UUID) (*domain.WorkflowNode, error) {
+	node, err := s.nodeRepo.GetByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.docRepo.HasReadAccess(ctx, node.DocumentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	return node, nil
+}
+
+// ListNodes returns all workflow nodes for a document.
+func (s *WorkflowNodeService) ListNodes(ctx context.Context, userID, docID uuid.UUID) ([]domain.WorkflowNode, error) {
+	ok, err := s.docRepo.HasReadAccess(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrForbidden
+	}
+
+	return s.nodeRepo.ListByDocument(ctx, docID)
+}
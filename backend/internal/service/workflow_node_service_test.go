@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"docmv/internal/domain"
+	"docmv/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func createNode(t *testing.T, e *testEnv, userID, docID uuid.UUID, name string) *domain.WorkflowNode {
+	t.Helper()
+	node, err := e.nodes.CreateNode(e.ctx, userID, docID, service.NodeInput{Name: name, ExecForm: domain.ExecFormManual})
+	mustNoErr(t, err)
+	return node
+}
+
+func nodeNames(nodes []domain.WorkflowNode) []string {
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestNodeCreate(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	viewer := e.user(t, "viewer@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Handbook"})
+	mustNoErr(t, err)
+	e.share(t, owner, domain.ShareResourceDocument, doc.ID, viewer, domain.ShareRoleView)
+
+	_, err = e.nodes.CreateNode(e.ctx, owner, doc.ID, service.NodeInput{ExecForm: "TELEPATHY"})
+	wantFields(t, err, map[string]string{"name": "required", "exec_form": "invalid_enum"})
+	_, err = e.nodes.CreateNode(e.ctx, viewer, doc.ID, service.NodeInput{Name: "Kickoff", ExecForm: domain.ExecFormManual})
+	wantErr(t, err, domain.ErrForbidden)
+
+	first := createNode(t, e, owner, doc.ID, "Kickoff")
+	second := createNode(t, e, owner, doc.ID, "Review")
+	if first.Revision != 1 || first.SortOrder != 0 || second.SortOrder != 1 {
+		t.Fatalf("got revision %d, sort orders %d and %d", first.Revision, first.SortOrder, second.SortOrder)
+	}
+	if first.DurationUnit != domain.DurationUnitDay || first.Subtasks == nil {
+		t.Fatalf("defaults not applied: %+v", first)
+	}
+
+	got, err := e.nodes.GetNode(e.ctx, viewer, first.ID)
+	mustNoErr(t, err)
+	if got.Name != "Kickoff" || got.Raci.R == nil {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestNodeUpdateRevision(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Handbook"})
+	mustNoErr(t, err)
+	node := createNode(t, e, owner, doc.ID, "Kickoff")
+
+	in := service.NodeInput{Name: "Kickoff meeting", ExecForm: domain.ExecFormManual, Subtasks: []string{"book room"}}
+	_, err = e.nodes.UpdateNode(e.ctx, owner, node.ID, in)
+	wantErr(t, err, domain.ErrPreconditionRequired)
+
+	in.Revision = ptr(node.Revision)
+	updated, err := e.nodes.UpdateNode(e.ctx, owner, node.ID, in)
+	mustNoErr(t, err)
+	if updated.Revision != node.Revision+1 || updated.SortOrder != node.SortOrder {
+		t.Fatalf("got revision %d sort order %d", updated.Revision, updated.SortOrder)
+	}
+
+	// A second write based on the original revision sees the saved node.
+	in.Name = "Standup"
+	_, err = e.nodes.UpdateNode(e.ctx, owner, node.ID, in)
+	var stale *domain.StaleRevisionError
+	if !errors.As(err, &stale) {
+		t.Fatalf("got error %v, want a stale revision", err)
+	}
+	current := stale.Current.(*domain.WorkflowNode)
+	if stale.Revision != updated.Revision || current.Name != "Kickoff meeting" || len(current.Subtasks) != 1 {
+		t.Fatalf("got stale revision %d with %+v", stale.Revision, current)
+	}
+}
+
+func TestNodeReorder(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.user(t, "owner@example.com")
+	doc, err := e.docs.Create(e.ctx, owner, service.CreateDocInput{Title: "Handbook"})
+	mustNoErr(t, err)
+	a := createNode(t, e, owner, doc.ID, "A")
+	b := createNode(t, e, owner, doc.ID, "B")
+	c := createNode(t, e, owner, doc.ID, "C")
+	trashed := createNode(t, e, owner, doc.ID, "trashed")
+	mustNoErr(t, e.nodes.DeleteNode(e.ctx, owner, trashed.ID))
+
+	bad := [][]uuid.UUID{
+		{c.ID, a.ID},
+		{c.ID, a.ID, a.ID},
+		{c.ID, a.ID, b.ID, trashed.ID},
+	}
+	for _, ids := range bad {
+		_, err := e.nodes.ReorderNodes(e.ctx, owner, doc.ID, service.ReorderNodesInput{NodeIDs: ids})
+		wantFields(t, err, map[string]string{"node_ids": "must_list_every_node_once"})
+	}
+
+	nodes, err := e.nodes.ReorderNodes(e.ctx, owner, doc.ID, service.ReorderNodesInput{NodeIDs: []uuid.UUID{c.ID, a.ID, b.ID}})
+	mustNoErr(t, err)
+	if got := nodeNames(nodes); len(got) != 3 || got[0] != "C" || got[1] != "A" || got[2] != "B" {
+		t.Fatalf("got order %v, want [C A B]", got)
+	}
+
+	// New nodes still go to the end.
+	createNode(t, e, owner, doc.ID, "D")
+	nodes, err = e.nodes.ListNodes(e.ctx, owner, doc.ID)
+	mustNoErr(t, err)
+	if got := nodeNames(nodes); len(got) != 4 || got[3] != "D" {
+		t.Fatalf("got order %v, want D last", got)
+	}
+}
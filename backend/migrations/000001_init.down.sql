@@ -1,6 +0,0 @@
-DROP TABLE IF EXISTS document_shares;
-DROP TABLE IF EXISTS document_versions;
-DROP TABLE IF EXISTS documents;
-DROP TABLE IF EXISTS users;
-DROP TYPE IF EXISTS share_role;
-DROP TYPE IF EXISTS doc_visibility;
@@ -0,0 +1,13 @@
+// Package migrations embeds the versioned schema migrations.
+//
+// Each driver has its own directory of NNNNNN_name.up.sql and
+// NNNNNN_name.down.sql files. Files are applied in version order by
+// repository.Migrator and must not be edited once released: the migrator
+// stores a checksum of every applied up file and refuses to run when one no
+// longer matches.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql mysql/*.sql sqlite/*.sql
+var FS embed.FS
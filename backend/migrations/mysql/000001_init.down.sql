@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS flow_shares;
+DROP TABLE IF EXISTS flow_versions;
+DROP TABLE IF EXISTS flow_nodes;
+DROP TABLE IF EXISTS flows;
+DROP TABLE IF EXISTS workflow_nodes;
+DROP TABLE IF EXISTS document_shares;
+DROP TABLE IF EXISTS document_versions;
+DROP TABLE IF EXISTS documents;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,135 @@
+-- Initial schema (MySQL)
+-- UUIDs stored as CHAR(36), timestamps as DATETIME(6).
+
+CREATE TABLE IF NOT EXISTS users (
+    id            CHAR(36)     NOT NULL PRIMARY KEY,
+    email         VARCHAR(255) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    role          VARCHAR(20)  NOT NULL DEFAULT 'USER',
+    created_at    DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_users_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS documents (
+    id                CHAR(36)                            NOT NULL PRIMARY KEY,
+    owner_id          CHAR(36)                            NOT NULL,
+    title             VARCHAR(500)                        NOT NULL,
+    visibility        ENUM('PRIVATE','PUBLIC','SHARED')   NOT NULL DEFAULT 'PRIVATE',
+    latest_version_id CHAR(36)                            DEFAULT NULL,
+    created_at        DATETIME(6)                         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at        DATETIME(6)                         NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_documents_owner FOREIGN KEY (owner_id) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    id          CHAR(36)    NOT NULL PRIMARY KEY,
+    document_id CHAR(36)    NOT NULL,
+    content     LONGTEXT    NOT NULL,
+    created_by  CHAR(36)    NOT NULL,
+    created_at  DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_versions_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_versions_creator  FOREIGN KEY (created_by)  REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS document_shares (
+    id          CHAR(36)            NOT NULL PRIMARY KEY,
+    document_id CHAR(36)            NOT NULL,
+    user_id     CHAR(36)            NOT NULL,
+    role        ENUM('VIEW','EDIT') NOT NULL DEFAULT 'VIEW',
+    created_at  DATETIME(6)         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_shares_doc_user (document_id, user_id),
+    CONSTRAINT fk_shares_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_shares_user     FOREIGN KEY (user_id)     REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS workflow_nodes (
+    id             CHAR(36)       NOT NULL PRIMARY KEY,
+    document_id    CHAR(36)       NOT NULL,
+    name           VARCHAR(500)   NOT NULL,
+    exec_form      VARCHAR(50)    NOT NULL,
+    description    TEXT           NOT NULL,
+    preconditions  TEXT           NOT NULL,
+    outputs        TEXT           NOT NULL,
+    duration_min   DOUBLE,
+    duration_max   DOUBLE,
+    duration_unit  VARCHAR(20)    NOT NULL DEFAULT 'DAY',
+    raci_json      TEXT           NOT NULL,
+    subtasks_json  TEXT           NOT NULL,
+    diagram_json   TEXT           NOT NULL,
+    created_at     TIMESTAMP(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at     TIMESTAMP(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS flows (
+    id                CHAR(36)     NOT NULL PRIMARY KEY,
+    flow_no           VARCHAR(20)  NOT NULL,
+    title             VARCHAR(500) NOT NULL,
+    owner_id          CHAR(36)     NOT NULL,
+    owner_dept_id     VARCHAR(100) NOT NULL DEFAULT '',
+    overview          TEXT         DEFAULT NULL,
+    status            VARCHAR(20)  NOT NULL DEFAULT 'DRAFT',
+    diagram_json      LONGTEXT     DEFAULT NULL,
+    latest_version_id CHAR(36)     DEFAULT NULL,
+    created_at        DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at        DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_flows_flow_no (flow_no),
+    CONSTRAINT fk_flows_owner FOREIGN KEY (owner_id) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS flow_nodes (
+    id             CHAR(36)      NOT NULL PRIMARY KEY,
+    flow_id        CHAR(36)      NOT NULL,
+    node_no        VARCHAR(20)   NOT NULL DEFAULT '',
+    name           VARCHAR(200)  NOT NULL,
+    intro          TEXT          DEFAULT NULL,
+    raci_json      TEXT          DEFAULT NULL,
+    exec_form      VARCHAR(50)   DEFAULT NULL,
+    duration_min   DECIMAL(10,2) DEFAULT NULL,
+    duration_max   DECIMAL(10,2) DEFAULT NULL,
+    duration_unit  VARCHAR(10)   NOT NULL DEFAULT 'DAY',
+    prereq_text    TEXT          DEFAULT NULL,
+    outputs_text   TEXT          DEFAULT NULL,
+    subtasks_json  TEXT          DEFAULT NULL,
+    sort_order     INT           NOT NULL DEFAULT 0,
+    created_at     DATETIME(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at     DATETIME(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_flow_nodes_flow FOREIGN KEY (flow_id) REFERENCES flows(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS flow_versions (
+    id            CHAR(36)    NOT NULL PRIMARY KEY,
+    flow_id       CHAR(36)    NOT NULL,
+    snapshot_json LONGTEXT    NOT NULL,
+    created_by    CHAR(36)    NOT NULL,
+    created_at    DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_flow_versions_flow    FOREIGN KEY (flow_id)    REFERENCES flows(id) ON DELETE CASCADE,
+    CONSTRAINT fk_flow_versions_creator FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS flow_shares (
+    id          CHAR(36)            NOT NULL PRIMARY KEY,
+    flow_id     CHAR(36)            NOT NULL,
+    user_id     CHAR(36)            NOT NULL,
+    role        ENUM('VIEW','EDIT') NOT NULL DEFAULT 'VIEW',
+    created_at  DATETIME(6)         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_flow_shares (flow_id, user_id),
+    CONSTRAINT fk_flow_shares_flow FOREIGN KEY (flow_id) REFERENCES flows(id) ON DELETE CASCADE,
+    CONSTRAINT fk_flow_shares_user FOREIGN KEY (user_id) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_documents_owner          ON documents(owner_id);
+CREATE INDEX idx_documents_visibility     ON documents(visibility);
+CREATE INDEX idx_doc_versions_document    ON document_versions(document_id);
+CREATE INDEX idx_doc_versions_created_at  ON document_versions(document_id, created_at DESC);
+CREATE INDEX idx_doc_shares_document      ON document_shares(document_id);
+CREATE INDEX idx_doc_shares_user          ON document_shares(user_id);
+CREATE INDEX idx_workflow_nodes_document  ON workflow_nodes(document_id);
+CREATE INDEX idx_flows_owner              ON flows(owner_id);
+CREATE INDEX idx_flows_status             ON flows(status);
+CREATE INDEX idx_flow_nodes_flow          ON flow_nodes(flow_id);
+CREATE INDEX idx_flow_nodes_sort          ON flow_nodes(flow_id, sort_order);
+CREATE INDEX idx_flow_versions_flow       ON flow_versions(flow_id);
+CREATE INDEX idx_flow_versions_created    ON flow_versions(flow_id, created_at DESC);
+CREATE INDEX idx_flow_shares_flow         ON flow_shares(flow_id);
+CREATE INDEX idx_flow_shares_user         ON flow_shares(user_id);
@@ -0,0 +1,18 @@
+-- Ownership history for documents and flows.
+-- resource_type is 'document' or 'flow'; resource_id has no FK because it
+-- points at either table.
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+    id              CHAR(36)    NOT NULL PRIMARY KEY,
+    resource_type   VARCHAR(20) NOT NULL,
+    resource_id     CHAR(36)    NOT NULL,
+    from_user_id    CHAR(36)    NOT NULL,
+    to_user_id      CHAR(36)    NOT NULL,
+    transferred_by  CHAR(36)    NOT NULL,
+    kept_edit_share TINYINT(1)  NOT NULL DEFAULT 0,
+    created_at      DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_transfers_from FOREIGN KEY (from_user_id)   REFERENCES users(id),
+    CONSTRAINT fk_transfers_to   FOREIGN KEY (to_user_id)     REFERENCES users(id),
+    CONSTRAINT fk_transfers_by   FOREIGN KEY (transferred_by) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_ownership_transfers_res ON ownership_transfers(resource_type, resource_id);
@@ -0,0 +1,4 @@
+-- Indexes on deleted_at are dropped together with the columns.
+ALTER TABLE workflow_nodes DROP COLUMN deleted_at;
+ALTER TABLE flows          DROP COLUMN deleted_at;
+ALTER TABLE documents      DROP COLUMN deleted_at;
@@ -0,0 +1 @@
+ALTER TABLE workflow_nodes DROP COLUMN sort_order;
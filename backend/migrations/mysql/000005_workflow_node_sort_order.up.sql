@@ -0,0 +1,5 @@
+-- Explicit display order for workflow nodes.
+-- Existing rows keep sort_order 0 and fall back to created_at ordering.
+ALTER TABLE workflow_nodes ADD COLUMN sort_order INT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_workflow_nodes_sort ON workflow_nodes(document_id, sort_order);
@@ -0,0 +1,5 @@
+-- Revision counters for optimistic concurrency.
+-- Every successful update bumps revision; the API exposes it as an ETag.
+ALTER TABLE documents      ADD COLUMN revision INT NOT NULL DEFAULT 1;
+ALTER TABLE flows          ADD COLUMN revision INT NOT NULL DEFAULT 1;
+ALTER TABLE workflow_nodes ADD COLUMN revision INT NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+-- Tracks which document each converted flow came from, so that the
+-- docs-to-flows data migration can be re-run safely. No foreign keys: either
+-- side may be purged later without affecting the record.
+CREATE TABLE IF NOT EXISTS document_conversions (
+    document_id  CHAR(36)    NOT NULL PRIMARY KEY,
+    flow_id      CHAR(36)    NOT NULL,
+    version_id   CHAR(36)    NOT NULL,
+    converted_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
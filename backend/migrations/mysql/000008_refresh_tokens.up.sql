@@ -0,0 +1,23 @@
+-- Refresh tokens for short-lived access tokens.
+-- Only the SHA-256 of a token is stored. Every refresh marks the presented
+-- token used and issues a successor in the same family; presenting a used
+-- token again revokes the whole family.
+-- users.token_version is embedded in every issued token; bumping it (password
+-- reset, deactivation) invalidates access and refresh tokens issued earlier.
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id            CHAR(36)    NOT NULL PRIMARY KEY,
+    user_id       CHAR(36)    NOT NULL,
+    family_id     CHAR(36)    NOT NULL,
+    token_hash    CHAR(64)    NOT NULL,
+    token_version INT         NOT NULL,
+    expires_at    DATETIME(6) NOT NULL,
+    created_at    DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    used_at       DATETIME(6) DEFAULT NULL,
+    revoked_at    DATETIME(6) DEFAULT NULL,
+    UNIQUE KEY uk_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    KEY idx_refresh_tokens_expires (expires_at),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
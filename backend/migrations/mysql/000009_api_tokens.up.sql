@@ -0,0 +1,19 @@
+-- Personal access tokens for scripts and CI.
+-- Only the SHA-256 of a token is stored, plus its first characters so users
+-- can tell their tokens apart. scopes is a comma-separated list of
+-- read/write/admin.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           CHAR(36)     NOT NULL PRIMARY KEY,
+    user_id      CHAR(36)     NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   CHAR(64)     NOT NULL,
+    token_prefix VARCHAR(20)  NOT NULL,
+    scopes       VARCHAR(50)  NOT NULL,
+    expires_at   DATETIME(6)  NOT NULL,
+    created_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    last_used_at DATETIME(6)  DEFAULT NULL,
+    revoked_at   DATETIME(6)  DEFAULT NULL,
+    UNIQUE KEY uk_api_tokens_hash (token_hash),
+    KEY idx_api_tokens_user (user_id),
+    CONSTRAINT fk_api_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN is_active,
+    DROP COLUMN locale,
+    DROP COLUMN department,
+    DROP COLUMN display_name;
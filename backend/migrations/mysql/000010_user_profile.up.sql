@@ -0,0 +1,8 @@
+-- Self-service profile fields and account deactivation.
+-- A deactivated user (is_active false) cannot log in, and every request with
+-- a token issued to them is rejected; deactivating also bumps token_version.
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN department   VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN locale       VARCHAR(20)  NOT NULL DEFAULT 'zh-CN',
+    ADD COLUMN is_active    TINYINT(1)   NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS password_history;
+DROP TABLE IF EXISTS login_throttles;
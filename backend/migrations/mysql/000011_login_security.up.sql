@@ -0,0 +1,21 @@
+-- Login brute-force protection and password history.
+-- login_throttles counts recent failed logins per account ("account:<email>")
+-- and per client address ("ip:<addr>"); the service derives progressive
+-- delays and lockouts from the count and the time of the last failure.
+-- password_history keeps the hashes of a user's most recent passwords so
+-- they cannot be reused.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key    VARCHAR(300) NOT NULL PRIMARY KEY,
+    failures        INT          NOT NULL,
+    last_failure_at DATETIME(6)  NOT NULL,
+    KEY idx_login_throttles_last (last_failure_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id            CHAR(36)     NOT NULL PRIMARY KEY,
+    user_id       CHAR(36)     NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at    DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_password_history_user (user_id, created_at),
+    CONSTRAINT fk_password_history_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
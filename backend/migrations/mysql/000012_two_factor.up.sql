@@ -0,0 +1,26 @@
+-- TOTP two-factor authentication.
+-- totp_secret holds the base32 secret from enrollment until it is confirmed
+-- (two_factor_enabled) and afterwards; totp_last_step is the time step of the
+-- last accepted code, so a code cannot be used twice. recovery_codes keeps the
+-- SHA-256 of each one-time recovery code. app_settings stores settings admins
+-- change at runtime, such as requiring 2FA for admins.
+ALTER TABLE users
+    ADD COLUMN totp_secret        VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN two_factor_enabled TINYINT(1)  NOT NULL DEFAULT 0,
+    ADD COLUMN totp_last_step     BIGINT      NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         CHAR(36)    NOT NULL PRIMARY KEY,
+    user_id    CHAR(36)    NOT NULL,
+    code_hash  CHAR(64)    NOT NULL,
+    used_at    DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_recovery_codes_user (user_id),
+    CONSTRAINT fk_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS app_settings (
+    setting_key VARCHAR(100) NOT NULL PRIMARY KEY,
+    value       TEXT         NOT NULL,
+    updated_at  DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
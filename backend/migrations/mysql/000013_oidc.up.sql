@@ -0,0 +1,25 @@
+-- OpenID Connect single sign-on.
+-- user_identities links a user to their account at an external identity
+-- provider: provider is the OIDC issuer URL and subject the provider's
+-- stable id for the user. A user has at most one identity per provider.
+-- oidc_logins holds the state, nonce and PKCE verifier of logins between
+-- the redirect to the provider and its callback.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         CHAR(36)     NOT NULL PRIMARY KEY,
+    user_id    CHAR(36)     NOT NULL,
+    provider   VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_user_identities_subject (provider, subject),
+    UNIQUE KEY uk_user_identities_user (user_id, provider),
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state         VARCHAR(64)  NOT NULL PRIMARY KEY,
+    nonce         VARCHAR(64)  NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at    DATETIME(6)  NOT NULL,
+    created_at    DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_oidc_logins_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,21 @@
+-- User groups, provisioned through SCIM.
+-- external_id is the provisioning client's own id for the group, if it
+-- sent one. Members are removed along with their group or user.
+CREATE TABLE IF NOT EXISTS user_groups (
+    id          CHAR(36)     NOT NULL PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    external_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at  DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_user_groups_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_group_members (
+    group_id   CHAR(36)    NOT NULL,
+    user_id    CHAR(36)    NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (group_id, user_id),
+    KEY idx_user_group_members_user (user_id),
+    CONSTRAINT fk_user_group_members_group FOREIGN KEY (group_id) REFERENCES user_groups(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_group_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
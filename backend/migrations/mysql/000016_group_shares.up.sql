@@ -0,0 +1,31 @@
+-- Groups as share principals.
+-- role is the member's role within the group: OWNER and ADMIN members
+-- manage the group, MEMBER is plain membership. document_group_shares and
+-- flow_group_shares grant VIEW/EDIT to every member of a group; they go
+-- away with their resource or group.
+ALTER TABLE user_group_members
+    ADD COLUMN role ENUM('MEMBER','ADMIN','OWNER') NOT NULL DEFAULT 'MEMBER';
+
+CREATE TABLE IF NOT EXISTS document_group_shares (
+    id          CHAR(36)            NOT NULL PRIMARY KEY,
+    document_id CHAR(36)            NOT NULL,
+    group_id    CHAR(36)            NOT NULL,
+    role        ENUM('VIEW','EDIT') NOT NULL DEFAULT 'VIEW',
+    created_at  DATETIME(6)         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_doc_group_shares (document_id, group_id),
+    KEY idx_doc_group_shares_group (group_id),
+    CONSTRAINT fk_doc_group_shares_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_doc_group_shares_group FOREIGN KEY (group_id) REFERENCES user_groups(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS flow_group_shares (
+    id         CHAR(36)            NOT NULL PRIMARY KEY,
+    flow_id    CHAR(36)            NOT NULL,
+    group_id   CHAR(36)            NOT NULL,
+    role       ENUM('VIEW','EDIT') NOT NULL DEFAULT 'VIEW',
+    created_at DATETIME(6)         NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY uk_flow_group_shares (flow_id, group_id),
+    KEY idx_flow_group_shares_group (group_id),
+    CONSTRAINT fk_flow_group_shares_flow FOREIGN KEY (flow_id) REFERENCES flows(id) ON DELETE CASCADE,
+    CONSTRAINT fk_flow_group_shares_group FOREIGN KEY (group_id) REFERENCES user_groups(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
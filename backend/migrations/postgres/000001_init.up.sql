@@ -0,0 +1,122 @@
+-- Initial schema (PostgreSQL)
+-- Users, documents with versions/shares/workflow nodes, and flows with
+-- nodes/versions/shares.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email         VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    role          VARCHAR(20)  NOT NULL DEFAULT 'USER',
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS documents (
+    id                UUID           PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id          UUID           NOT NULL REFERENCES users(id),
+    title             VARCHAR(500)   NOT NULL,
+    visibility        VARCHAR(20)    NOT NULL DEFAULT 'PRIVATE',
+    latest_version_id UUID,
+    created_at        TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ    NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    id          UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID        NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    content     TEXT        NOT NULL DEFAULT '',
+    created_by  UUID        NOT NULL REFERENCES users(id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS document_shares (
+    id          UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID        NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    user_id     UUID        NOT NULL REFERENCES users(id),
+    role        VARCHAR(20) NOT NULL DEFAULT 'VIEW',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(document_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS workflow_nodes (
+    id             UUID             PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id    UUID             NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    name           VARCHAR(500)     NOT NULL,
+    exec_form      VARCHAR(50)      NOT NULL,
+    description    TEXT             NOT NULL DEFAULT '',
+    preconditions  TEXT             NOT NULL DEFAULT '',
+    outputs        TEXT             NOT NULL DEFAULT '',
+    duration_min   DOUBLE PRECISION,
+    duration_max   DOUBLE PRECISION,
+    duration_unit  VARCHAR(20)      NOT NULL DEFAULT 'DAY',
+    raci_json      TEXT             NOT NULL DEFAULT '{"R":[],"A":[],"S":[],"C":[],"I":[]}',
+    subtasks_json  TEXT             NOT NULL DEFAULT '[]',
+    diagram_json   TEXT             NOT NULL DEFAULT '{"nodes":[],"edges":[]}',
+    created_at     TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ      NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS flows (
+    id                UUID         PRIMARY KEY,
+    flow_no           VARCHAR(20)  NOT NULL UNIQUE,
+    title             VARCHAR(500) NOT NULL,
+    owner_id          UUID         NOT NULL REFERENCES users(id),
+    owner_dept_id     VARCHAR(100) NOT NULL DEFAULT '',
+    overview          TEXT,
+    status            VARCHAR(20)  NOT NULL DEFAULT 'DRAFT',
+    diagram_json      TEXT,
+    latest_version_id UUID,
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS flow_nodes (
+    id             UUID          PRIMARY KEY,
+    flow_id        UUID          NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    node_no        VARCHAR(20)   NOT NULL DEFAULT '',
+    name           VARCHAR(200)  NOT NULL,
+    intro          TEXT,
+    raci_json      TEXT,
+    exec_form      VARCHAR(50),
+    duration_min   DECIMAL(10,2),
+    duration_max   DECIMAL(10,2),
+    duration_unit  VARCHAR(10)   NOT NULL DEFAULT 'DAY',
+    prereq_text    TEXT,
+    outputs_text   TEXT,
+    subtasks_json  TEXT,
+    sort_order     INT           NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS flow_versions (
+    id            UUID        PRIMARY KEY,
+    flow_id       UUID        NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    snapshot_json TEXT        NOT NULL,
+    created_by    UUID        NOT NULL REFERENCES users(id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS flow_shares (
+    id          UUID        PRIMARY KEY,
+    flow_id     UUID        NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    user_id     UUID        NOT NULL REFERENCES users(id),
+    role        VARCHAR(10) NOT NULL DEFAULT 'VIEW',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(flow_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_owner          ON documents(owner_id);
+CREATE INDEX IF NOT EXISTS idx_documents_visibility     ON documents(visibility);
+CREATE INDEX IF NOT EXISTS idx_doc_versions_document    ON document_versions(document_id);
+CREATE INDEX IF NOT EXISTS idx_doc_versions_created_at  ON document_versions(document_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_doc_shares_document      ON document_shares(document_id);
+CREATE INDEX IF NOT EXISTS idx_doc_shares_user          ON document_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_nodes_document  ON workflow_nodes(document_id);
+CREATE INDEX IF NOT EXISTS idx_flows_owner              ON flows(owner_id);
+CREATE INDEX IF NOT EXISTS idx_flows_status             ON flows(status);
+CREATE INDEX IF NOT EXISTS idx_flow_nodes_flow          ON flow_nodes(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_nodes_sort          ON flow_nodes(flow_id, sort_order);
+CREATE INDEX IF NOT EXISTS idx_flow_versions_flow       ON flow_versions(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_versions_created    ON flow_versions(flow_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_flow_shares_flow         ON flow_shares(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_shares_user         ON flow_shares(user_id);
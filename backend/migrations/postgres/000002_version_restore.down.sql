@@ -0,0 +1,2 @@
+ALTER TABLE flow_versions     DROP COLUMN IF EXISTS restored_from_version_id;
+ALTER TABLE document_versions DROP COLUMN IF EXISTS restored_from_version_id;
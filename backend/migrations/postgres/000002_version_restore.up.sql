@@ -0,0 +1,4 @@
+-- Record which historical version a restored version was copied from.
+-- NULL for versions created by a normal edit or publish.
+ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS restored_from_version_id UUID;
+ALTER TABLE flow_versions     ADD COLUMN IF NOT EXISTS restored_from_version_id UUID;
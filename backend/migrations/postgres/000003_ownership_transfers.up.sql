@@ -0,0 +1,15 @@
+-- Ownership history for documents and flows.
+-- resource_type is 'document' or 'flow'; resource_id has no FK because it
+-- points at either table.
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+    id              UUID        PRIMARY KEY,
+    resource_type   VARCHAR(20) NOT NULL,
+    resource_id     UUID        NOT NULL,
+    from_user_id    UUID        NOT NULL REFERENCES users(id),
+    to_user_id      UUID        NOT NULL REFERENCES users(id),
+    transferred_by  UUID        NOT NULL REFERENCES users(id),
+    kept_edit_share BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ownership_transfers_res ON ownership_transfers(resource_type, resource_id);
@@ -0,0 +1,4 @@
+-- Indexes on deleted_at are dropped together with the columns.
+ALTER TABLE workflow_nodes DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE flows          DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE documents      DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1 @@
+ALTER TABLE workflow_nodes DROP COLUMN IF EXISTS sort_order;
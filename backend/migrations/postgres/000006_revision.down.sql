@@ -0,0 +1,3 @@
+ALTER TABLE workflow_nodes DROP COLUMN IF EXISTS revision;
+ALTER TABLE flows          DROP COLUMN IF EXISTS revision;
+ALTER TABLE documents      DROP COLUMN IF EXISTS revision;
@@ -0,0 +1,9 @@
+-- Tracks which document each converted flow came from, so that the
+-- docs-to-flows data migration can be re-run safely. No foreign keys: either
+-- side may be purged later without affecting the record.
+CREATE TABLE IF NOT EXISTS document_conversions (
+    document_id  UUID        PRIMARY KEY,
+    flow_id      UUID        NOT NULL,
+    version_id   UUID        NOT NULL,
+    converted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
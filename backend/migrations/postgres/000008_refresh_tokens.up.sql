@@ -0,0 +1,22 @@
+-- Refresh tokens for short-lived access tokens.
+-- Only the SHA-256 of a token is stored. Every refresh marks the presented
+-- token used and issues a successor in the same family; presenting a used
+-- token again revokes the whole family.
+-- users.token_version is embedded in every issued token; bumping it (password
+-- reset, deactivation) invalidates access and refresh tokens issued earlier.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id            UUID        PRIMARY KEY,
+    user_id       UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id     UUID        NOT NULL,
+    token_hash    CHAR(64)    NOT NULL UNIQUE,
+    token_version INT         NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at       TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family  ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires ON refresh_tokens(expires_at);
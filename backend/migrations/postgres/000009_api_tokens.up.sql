@@ -0,0 +1,18 @@
+-- Personal access tokens for scripts and CI.
+-- Only the SHA-256 of a token is stored, plus its first characters so users
+-- can tell their tokens apart. scopes is a comma-separated list of
+-- read/write/admin.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           UUID         PRIMARY KEY,
+    user_id      UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   CHAR(64)     NOT NULL UNIQUE,
+    token_prefix VARCHAR(20)  NOT NULL,
+    scopes       VARCHAR(50)  NOT NULL,
+    expires_at   TIMESTAMPTZ  NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
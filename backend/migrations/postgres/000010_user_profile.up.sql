@@ -0,0 +1,7 @@
+-- Self-service profile fields and account deactivation.
+-- A deactivated user (is_active false) cannot log in, and every request with
+-- a token issued to them is rejected; deactivating also bumps token_version.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS department   VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale       VARCHAR(20)  NOT NULL DEFAULT 'zh-CN';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active    BOOLEAN      NOT NULL DEFAULT TRUE;
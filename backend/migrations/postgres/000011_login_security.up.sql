@@ -0,0 +1,22 @@
+-- Login brute-force protection and password history.
+-- login_throttles counts recent failed logins per account ("account:<email>")
+-- and per client address ("ip:<addr>"); the service derives progressive
+-- delays and lockouts from the count and the time of the last failure.
+-- password_history keeps the hashes of a user's most recent passwords so
+-- they cannot be reused.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key    VARCHAR(300) PRIMARY KEY,
+    failures        INT          NOT NULL,
+    last_failure_at TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last ON login_throttles(last_failure_at);
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id            UUID         PRIMARY KEY,
+    user_id       UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, created_at);
@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication.
+-- totp_secret holds the base32 secret from enrollment until it is confirmed
+-- (two_factor_enabled) and afterwards; totp_last_step is the time step of the
+-- last accepted code, so a code cannot be used twice. recovery_codes keeps the
+-- SHA-256 of each one-time recovery code. app_settings stores settings admins
+-- change at runtime, such as requiring 2FA for admins.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret        VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_enabled BOOLEAN     NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step     BIGINT      NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         UUID        PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  CHAR(64)    NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+CREATE TABLE IF NOT EXISTS app_settings (
+    setting_key VARCHAR(100) PRIMARY KEY,
+    value       TEXT         NOT NULL,
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
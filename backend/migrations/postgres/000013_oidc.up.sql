@@ -0,0 +1,25 @@
+-- OpenID Connect single sign-on.
+-- user_identities links a user to their account at an external identity
+-- provider: provider is the OIDC issuer URL and subject the provider's
+-- stable id for the user. A user has at most one identity per provider.
+-- oidc_logins holds the state, nonce and PKCE verifier of logins between
+-- the redirect to the provider and its callback.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         UUID         PRIMARY KEY,
+    user_id    UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider   VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE(provider, subject),
+    UNIQUE(user_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state         VARCHAR(64)  PRIMARY KEY,
+    nonce         VARCHAR(64)  NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at    TIMESTAMPTZ  NOT NULL,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_logins_expires ON oidc_logins(expires_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_group_members;
+DROP TABLE IF EXISTS user_groups;
@@ -0,0 +1,27 @@
+-- Groups as share principals.
+-- role is the member's role within the group: OWNER and ADMIN members
+-- manage the group, MEMBER is plain membership. document_group_shares and
+-- flow_group_shares grant VIEW/EDIT to every member of a group; they go
+-- away with their resource or group.
+ALTER TABLE user_group_members ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'MEMBER';
+
+CREATE TABLE IF NOT EXISTS document_group_shares (
+    id          UUID        PRIMARY KEY,
+    document_id UUID        NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    group_id    UUID        NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    role        VARCHAR(20) NOT NULL DEFAULT 'VIEW',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (document_id, group_id)
+);
+
+CREATE TABLE IF NOT EXISTS flow_group_shares (
+    id         UUID        PRIMARY KEY,
+    flow_id    UUID        NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    group_id   UUID        NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    role       VARCHAR(20) NOT NULL DEFAULT 'VIEW',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (flow_id, group_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_doc_group_shares_group  ON document_group_shares(group_id);
+CREATE INDEX IF NOT EXISTS idx_flow_group_shares_group ON flow_group_shares(group_id);
@@ -0,0 +1,123 @@
+-- Initial schema (SQLite)
+-- UUIDs stored as TEXT, timestamps as DATETIME text, JSON as TEXT.
+-- Foreign keys are enforced only with PRAGMA foreign_keys=ON, which
+-- repository.NewDB sets on every connection.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            TEXT     NOT NULL PRIMARY KEY,
+    email         TEXT     NOT NULL UNIQUE,
+    password_hash TEXT     NOT NULL,
+    role          TEXT     NOT NULL DEFAULT 'USER',
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS documents (
+    id                TEXT     NOT NULL PRIMARY KEY,
+    owner_id          TEXT     NOT NULL REFERENCES users(id),
+    title             TEXT     NOT NULL,
+    visibility        TEXT     NOT NULL DEFAULT 'PRIVATE',
+    latest_version_id TEXT,
+    created_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    id          TEXT     NOT NULL PRIMARY KEY,
+    document_id TEXT     NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    content     TEXT     NOT NULL DEFAULT '',
+    created_by  TEXT     NOT NULL REFERENCES users(id),
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS document_shares (
+    id          TEXT     NOT NULL PRIMARY KEY,
+    document_id TEXT     NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    user_id     TEXT     NOT NULL REFERENCES users(id),
+    role        TEXT     NOT NULL DEFAULT 'VIEW',
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(document_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS workflow_nodes (
+    id             TEXT     NOT NULL PRIMARY KEY,
+    document_id    TEXT     NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    name           TEXT     NOT NULL,
+    exec_form      TEXT     NOT NULL,
+    description    TEXT     NOT NULL DEFAULT '',
+    preconditions  TEXT     NOT NULL DEFAULT '',
+    outputs        TEXT     NOT NULL DEFAULT '',
+    duration_min   REAL,
+    duration_max   REAL,
+    duration_unit  TEXT     NOT NULL DEFAULT 'DAY',
+    raci_json      TEXT     NOT NULL DEFAULT '{"R":[],"A":[],"S":[],"C":[],"I":[]}',
+    subtasks_json  TEXT     NOT NULL DEFAULT '[]',
+    diagram_json   TEXT     NOT NULL DEFAULT '{"nodes":[],"edges":[]}',
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS flows (
+    id                TEXT     NOT NULL PRIMARY KEY,
+    flow_no           TEXT     NOT NULL UNIQUE,
+    title             TEXT     NOT NULL,
+    owner_id          TEXT     NOT NULL REFERENCES users(id),
+    owner_dept_id     TEXT     NOT NULL DEFAULT '',
+    overview          TEXT,
+    status            TEXT     NOT NULL DEFAULT 'DRAFT',
+    diagram_json      TEXT,
+    latest_version_id TEXT,
+    created_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS flow_nodes (
+    id             TEXT     NOT NULL PRIMARY KEY,
+    flow_id        TEXT     NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    node_no        TEXT     NOT NULL DEFAULT '',
+    name           TEXT     NOT NULL,
+    intro          TEXT,
+    raci_json      TEXT,
+    exec_form      TEXT,
+    duration_min   REAL,
+    duration_max   REAL,
+    duration_unit  TEXT     NOT NULL DEFAULT 'DAY',
+    prereq_text    TEXT,
+    outputs_text   TEXT,
+    subtasks_json  TEXT,
+    sort_order     INTEGER  NOT NULL DEFAULT 0,
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS flow_versions (
+    id            TEXT     NOT NULL PRIMARY KEY,
+    flow_id       TEXT     NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    snapshot_json TEXT     NOT NULL,
+    created_by    TEXT     NOT NULL REFERENCES users(id),
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS flow_shares (
+    id          TEXT     NOT NULL PRIMARY KEY,
+    flow_id     TEXT     NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    user_id     TEXT     NOT NULL REFERENCES users(id),
+    role        TEXT     NOT NULL DEFAULT 'VIEW',
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(flow_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_owner          ON documents(owner_id);
+CREATE INDEX IF NOT EXISTS idx_documents_visibility     ON documents(visibility);
+CREATE INDEX IF NOT EXISTS idx_doc_versions_document    ON document_versions(document_id);
+CREATE INDEX IF NOT EXISTS idx_doc_versions_created_at  ON document_versions(document_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_doc_shares_document      ON document_shares(document_id);
+CREATE INDEX IF NOT EXISTS idx_doc_shares_user          ON document_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_nodes_document  ON workflow_nodes(document_id);
+CREATE INDEX IF NOT EXISTS idx_flows_owner              ON flows(owner_id);
+CREATE INDEX IF NOT EXISTS idx_flows_status             ON flows(status);
+CREATE INDEX IF NOT EXISTS idx_flow_nodes_flow          ON flow_nodes(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_nodes_sort          ON flow_nodes(flow_id, sort_order);
+CREATE INDEX IF NOT EXISTS idx_flow_versions_flow       ON flow_versions(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_versions_created    ON flow_versions(flow_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_flow_shares_flow         ON flow_shares(flow_id);
+CREATE INDEX IF NOT EXISTS idx_flow_shares_user         ON flow_shares(user_id);
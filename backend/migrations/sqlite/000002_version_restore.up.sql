@@ -0,0 +1,4 @@
+-- Record which historical version a restored version was copied from.
+-- NULL for versions created by a normal edit or publish.
+ALTER TABLE document_versions ADD COLUMN restored_from_version_id TEXT;
+ALTER TABLE flow_versions     ADD COLUMN restored_from_version_id TEXT;
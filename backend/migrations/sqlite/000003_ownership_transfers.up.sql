@@ -0,0 +1,15 @@
+-- Ownership history for documents and flows.
+-- resource_type is 'document' or 'flow'; resource_id has no FK because it
+-- points at either table.
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+    id              TEXT     NOT NULL PRIMARY KEY,
+    resource_type   TEXT     NOT NULL,
+    resource_id     TEXT     NOT NULL,
+    from_user_id    TEXT     NOT NULL REFERENCES users(id),
+    to_user_id      TEXT     NOT NULL REFERENCES users(id),
+    transferred_by  TEXT     NOT NULL REFERENCES users(id),
+    kept_edit_share INTEGER  NOT NULL DEFAULT 0,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ownership_transfers_res ON ownership_transfers(resource_type, resource_id);
@@ -0,0 +1,8 @@
+-- SQLite cannot drop an indexed column, so the indexes go first.
+DROP INDEX IF EXISTS idx_workflow_nodes_deleted;
+DROP INDEX IF EXISTS idx_flows_deleted_at;
+DROP INDEX IF EXISTS idx_documents_deleted_at;
+
+ALTER TABLE workflow_nodes DROP COLUMN deleted_at;
+ALTER TABLE flows          DROP COLUMN deleted_at;
+ALTER TABLE documents      DROP COLUMN deleted_at;
@@ -0,0 +1,9 @@
+-- Soft delete: trashed rows carry a deleted_at timestamp.
+-- The purge job hard-deletes rows older than TRASH_RETENTION_DAYS.
+ALTER TABLE documents      ADD COLUMN deleted_at DATETIME;
+ALTER TABLE flows          ADD COLUMN deleted_at DATETIME;
+ALTER TABLE workflow_nodes ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_documents_deleted_at   ON documents(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_flows_deleted_at       ON flows(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_workflow_nodes_deleted ON workflow_nodes(deleted_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_workflow_nodes_sort;
+ALTER TABLE workflow_nodes DROP COLUMN sort_order;
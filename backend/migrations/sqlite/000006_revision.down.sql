@@ -0,0 +1,3 @@
+ALTER TABLE workflow_nodes DROP COLUMN revision;
+ALTER TABLE flows          DROP COLUMN revision;
+ALTER TABLE documents      DROP COLUMN revision;
@@ -0,0 +1,9 @@
+-- Tracks which document each converted flow came from, so that the
+-- docs-to-flows data migration can be re-run safely. No foreign keys: either
+-- side may be purged later without affecting the record.
+CREATE TABLE IF NOT EXISTS document_conversions (
+    document_id  TEXT     NOT NULL PRIMARY KEY,
+    flow_id      TEXT     NOT NULL,
+    version_id   TEXT     NOT NULL,
+    converted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
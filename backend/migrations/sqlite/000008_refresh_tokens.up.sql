@@ -0,0 +1,22 @@
+-- Refresh tokens for short-lived access tokens.
+-- Only the SHA-256 of a token is stored. Every refresh marks the presented
+-- token used and issues a successor in the same family; presenting a used
+-- token again revokes the whole family.
+-- users.token_version is embedded in every issued token; bumping it (password
+-- reset, deactivation) invalidates access and refresh tokens issued earlier.
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id            TEXT     NOT NULL PRIMARY KEY,
+    user_id       TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id     TEXT     NOT NULL,
+    token_hash    TEXT     NOT NULL UNIQUE,
+    token_version INTEGER  NOT NULL,
+    expires_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at       DATETIME,
+    revoked_at    DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family  ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires ON refresh_tokens(expires_at);
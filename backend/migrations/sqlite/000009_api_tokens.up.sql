@@ -0,0 +1,18 @@
+-- Personal access tokens for scripts and CI.
+-- Only the SHA-256 of a token is stored, plus its first characters so users
+-- can tell their tokens apart. scopes is a comma-separated list of
+-- read/write/admin.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           TEXT     NOT NULL PRIMARY KEY,
+    user_id      TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT     NOT NULL,
+    token_hash   TEXT     NOT NULL UNIQUE,
+    token_prefix TEXT     NOT NULL,
+    scopes       TEXT     NOT NULL,
+    expires_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    revoked_at   DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
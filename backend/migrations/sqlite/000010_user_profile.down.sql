@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN is_active;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN department;
+ALTER TABLE users DROP COLUMN display_name;
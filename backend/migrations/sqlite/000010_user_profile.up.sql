@@ -0,0 +1,7 @@
+-- Self-service profile fields and account deactivation.
+-- A deactivated user (is_active false) cannot log in, and every request with
+-- a token issued to them is rejected; deactivating also bumps token_version.
+ALTER TABLE users ADD COLUMN display_name TEXT    NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN department   TEXT    NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN locale       TEXT    NOT NULL DEFAULT 'zh-CN';
+ALTER TABLE users ADD COLUMN is_active    INTEGER NOT NULL DEFAULT 1;
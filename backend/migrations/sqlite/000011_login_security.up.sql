@@ -0,0 +1,22 @@
+-- Login brute-force protection and password history.
+-- login_throttles counts recent failed logins per account ("account:<email>")
+-- and per client address ("ip:<addr>"); the service derives progressive
+-- delays and lockouts from the count and the time of the last failure.
+-- password_history keeps the hashes of a user's most recent passwords so
+-- they cannot be reused.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key    TEXT     NOT NULL PRIMARY KEY,
+    failures        INTEGER  NOT NULL,
+    last_failure_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last ON login_throttles(last_failure_at);
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id            TEXT     NOT NULL PRIMARY KEY,
+    user_id       TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT     NOT NULL,
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, created_at);
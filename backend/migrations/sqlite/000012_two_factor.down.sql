@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS app_settings;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN two_factor_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication.
+-- totp_secret holds the base32 secret from enrollment until it is confirmed
+-- (two_factor_enabled) and afterwards; totp_last_step is the time step of the
+-- last accepted code, so a code cannot be used twice. recovery_codes keeps the
+-- SHA-256 of each one-time recovery code. app_settings stores settings admins
+-- change at runtime, such as requiring 2FA for admins.
+ALTER TABLE users ADD COLUMN totp_secret        TEXT    NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN two_factor_enabled INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step     INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         TEXT     NOT NULL PRIMARY KEY,
+    user_id    TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  TEXT     NOT NULL,
+    used_at    DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+CREATE TABLE IF NOT EXISTS app_settings (
+    setting_key TEXT     NOT NULL PRIMARY KEY,
+    value       TEXT     NOT NULL,
+    updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
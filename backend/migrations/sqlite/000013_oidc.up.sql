@@ -0,0 +1,25 @@
+-- OpenID Connect single sign-on.
+-- user_identities links a user to their account at an external identity
+-- provider: provider is the OIDC issuer URL and subject the provider's
+-- stable id for the user. A user has at most one identity per provider.
+-- oidc_logins holds the state, nonce and PKCE verifier of logins between
+-- the redirect to the provider and its callback.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         TEXT     NOT NULL PRIMARY KEY,
+    user_id    TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider   TEXT     NOT NULL,
+    subject    TEXT     NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, subject),
+    UNIQUE(user_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state         TEXT     NOT NULL PRIMARY KEY,
+    nonce         TEXT     NOT NULL,
+    code_verifier TEXT     NOT NULL,
+    expires_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_logins_expires ON oidc_logins(expires_at);
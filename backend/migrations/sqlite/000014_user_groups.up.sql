@@ -0,0 +1,19 @@
+-- User groups, provisioned through SCIM.
+-- external_id is the provisioning client's own id for the group, if it
+-- sent one. Members are removed along with their group or user.
+CREATE TABLE IF NOT EXISTS user_groups (
+    id          TEXT     NOT NULL PRIMARY KEY,
+    name        TEXT     NOT NULL UNIQUE,
+    external_id TEXT     NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_group_members (
+    group_id   TEXT     NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    user_id    TEXT     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_group_members_user ON user_group_members(user_id);
@@ -0,0 +1,11 @@
+-- Keys access tokens are signed with. A key signs from activates_at until
+-- the next key activates and is published in /.well-known/jwks.json as long
+-- as tokens it signed may still be valid. id is the kid header of those
+-- tokens; private_key is the PKCS #8 key in PEM.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id           TEXT     NOT NULL PRIMARY KEY,
+    algorithm    TEXT     NOT NULL,
+    private_key  TEXT     NOT NULL,
+    activates_at DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS flow_group_shares;
+DROP TABLE IF EXISTS document_group_shares;
+ALTER TABLE user_group_members DROP COLUMN role;
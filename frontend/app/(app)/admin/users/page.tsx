@@ -3,13 +3,40 @@
 import { useEffect, useState, useCallback } from "react";
 import { useRouter } from "next/navigation";
 import {
+  APIError,
   listUsers,
   createUser,
   resetUserPassword,
+  updateUserRole,
+  setUserActive,
+  deleteUser,
+  listLockouts,
+  clearLockout,
+  resetUserTwoFactor,
+  getSecuritySettings,
+  updateSecuritySettings,
+  passwordErrorText,
+  getCurrentUserId,
   getCurrentUserRole,
+  type LoginLockout,
   type UserInfo,
 } from "@/lib/api";
 
+/** Error text for a failed create/reset, spelling out password rule codes. */
+function passwordFormError(err: unknown, fallback: string): string {
+  if (err instanceof APIError && err.fields?.password) {
+    return passwordErrorText(err.fields.password);
+  }
+  return err instanceof Error ? err.message : fallback;
+}
+
+/** "account:a@b.c" → 账号 a@b.c, "ip:1.2.3.4" → IP 1.2.3.4 */
+function lockoutSubject(key: string): string {
+  if (key.startsWith("account:")) return `账号 ${key.slice("account:".length)}`;
+  if (key.startsWith("ip:")) return `IP ${key.slice("ip:".length)}`;
+  return key;
+}
+
 /* ------------------------------------------------------------------ */
 /*  User Management Page (Admin only)                                  */
 /* ------------------------------------------------------------------ */
@@ -17,7 +44,10 @@ import {
 export default function AdminUsersPage() {
   const router = useRouter();
   const role = getCurrentUserRole();
+  const currentUserId = getCurrentUserId();
   const [users, setUsers] = useState<UserInfo[]>([]);
+  const [lockouts, setLockouts] = useState<LoginLockout[]>([]);
+  const [require2FA, setRequire2FA] = useState(false);
   const [loading, setLoading] = useState(true);
 
   // New-user form
@@ -46,6 +76,15 @@ export default function AdminUsersPage() {
     }
   }, []);
 
+  const fetchLockouts = useCallback(async () => {
+    try {
+      const data = await listLockouts();
+      setLockouts(Array.isArray(data) ? data : []);
+    } catch {
+      setLockouts([]);
+    }
+  }, []);
+
   useEffect(() => {
     // Client-side guard: non-admin redirected
     if (role !== "ADMIN") {
@@ -53,7 +92,11 @@ export default function AdminUsersPage() {
       return;
     }
     fetchUsers();
-  }, [role, router, fetchUsers]);
+    fetchLockouts();
+    getSecuritySettings()
+      .then((s) => setRequire2FA(s.require_2fa_for_admins))
+      .catch(() => setRequire2FA(false));
+  }, [role, router, fetchUsers, fetchLockouts]);
 
   // ---------- Create user ----------
   async function handleCreate(e: React.FormEvent) {
@@ -68,7 +111,7 @@ export default function AdminUsersPage() {
       setShowForm(false);
       await fetchUsers();
     } catch (err: unknown) {
-      setFormError(err instanceof Error ? err.message : "创建失败");
+      setFormError(passwordFormError(err, "创建失败"));
     } finally {
       setFormLoading(false);
     }
@@ -84,13 +127,84 @@ export default function AdminUsersPage() {
       await resetUserPassword(resetTarget.id, resetPwd);
       setResetTarget(null);
       setResetPwd("");
+      await fetchLockouts(); // a reset also lifts the account's lockout
     } catch (err: unknown) {
-      setResetError(err instanceof Error ? err.message : "重置失败");
+      setResetError(passwordFormError(err, "重置失败"));
     } finally {
       setResetLoading(false);
     }
   }
 
+  // ---------- Role / status / delete ----------
+  async function handleToggleRole(u: UserInfo) {
+    const next = u.role === "ADMIN" ? "USER" : "ADMIN";
+    const label = next === "ADMIN" ? "设为管理员" : "设为普通用户";
+    if (!confirm(`确定将 ${u.email} ${label}？`)) return;
+    try {
+      await updateUserRole(u.id, next);
+      await fetchUsers();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    }
+  }
+
+  async function handleToggleActive(u: UserInfo) {
+    if (u.active && !confirm(`确定停用 ${u.email}？该用户将无法登录，已登录的会话立即失效。`)) return;
+    try {
+      await setUserActive(u.id, !u.active);
+      await fetchUsers();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    }
+  }
+
+  async function handleDelete(u: UserInfo) {
+    if (!confirm(`确定删除 ${u.email}？此操作不可撤销。`)) return;
+    try {
+      await deleteUser(u.id);
+      await fetchUsers();
+    } catch (err: unknown) {
+      if (err instanceof APIError && err.code === "INVALID_STATE") {
+        alert("该用户仍拥有文档或流程，或出现在版本/转移记录中。请先转移其所有权，或改为停用账号。");
+      } else {
+        alert(err instanceof Error ? err.message : "删除失败");
+      }
+    }
+  }
+
+  // ---------- Two-factor authentication ----------
+  async function handleReset2FA(u: UserInfo) {
+    if (!confirm(`确定重置 ${u.email} 的两步验证？其身份验证器和恢复码将失效，需要重新开启。`)) return;
+    try {
+      await resetUserTwoFactor(u.id);
+      await fetchUsers();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    }
+  }
+
+  async function handleToggleRequire2FA() {
+    const next = !require2FA;
+    if (next && !confirm("确定要求所有管理员开启两步验证？尚未开启的管理员在开启前只能访问设置页。")) return;
+    try {
+      const s = await updateSecuritySettings({ require_2fa_for_admins: next });
+      setRequire2FA(s.require_2fa_for_admins);
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    }
+  }
+
+  // ---------- Login lockouts ----------
+  async function handleClearLockout(l: LoginLockout) {
+    if (!confirm(`确定解除 ${lockoutSubject(l.key)} 的登录限制？`)) return;
+    try {
+      await clearLockout(l.key);
+      await fetchLockouts();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    }
+  }
+
   // ---------- Render ----------
 
   if (loading) {
@@ -150,11 +264,9 @@ export default function AdminUsersPage() {
               <input
                 type="password"
                 className="input"
-                placeholder="至少 6 个字符"
                 value={formPassword}
                 onChange={(e) => setFormPassword(e.target.value)}
                 required
-                minLength={6}
               />
             </div>
             <div>
@@ -191,6 +303,8 @@ export default function AdminUsersPage() {
             <tr className="border-b border-stone-100 bg-stone-50/60 text-left text-xs font-medium uppercase tracking-wider text-stone-500">
               <th className="px-5 py-3">邮箱</th>
               <th className="px-5 py-3">角色</th>
+              <th className="px-5 py-3">状态</th>
+              <th className="px-5 py-3">两步验证</th>
               <th className="px-5 py-3">创建时间</th>
               <th className="px-5 py-3 text-right">操作</th>
             </tr>
@@ -198,14 +312,21 @@ export default function AdminUsersPage() {
           <tbody className="divide-y divide-stone-100">
             {users.length === 0 ? (
               <tr>
-                <td colSpan={4} className="px-5 py-10 text-center text-stone-400">
+                <td colSpan={6} className="px-5 py-10 text-center text-stone-400">
                   暂无用户
                 </td>
               </tr>
             ) : (
               users.map((u) => (
                 <tr key={u.id} className="hover:bg-stone-50/40 transition-colors">
-                  <td className="px-5 py-3 font-medium text-stone-800">{u.email}</td>
+                  <td className="px-5 py-3">
+                    <div className="font-medium text-stone-800">{u.email}</div>
+                    {(u.display_name || u.department) && (
+                      <div className="text-xs text-stone-500">
+                        {[u.display_name, u.department].filter(Boolean).join(" · ")}
+                      </div>
+                    )}
+                  </td>
                   <td className="px-5 py-3">
                     <span
                       className={`inline-flex items-center rounded-full px-2 py-0.5 text-xs font-medium ${
@@ -217,16 +338,135 @@ export default function AdminUsersPage() {
                       {u.role === "ADMIN" ? "管理员" : "普通用户"}
                     </span>
                   </td>
+                  <td className="px-5 py-3">
+                    {u.active ? (
+                      <span className="text-xs text-emerald-600">正常</span>
+                    ) : (
+                      <span className="inline-flex items-center rounded-full bg-red-50 px-2 py-0.5 text-xs font-medium text-red-600 border border-red-100">
+                        已停用
+                      </span>
+                    )}
+                  </td>
+                  <td className="px-5 py-3">
+                    {u.two_factor_enabled ? (
+                      <span className="text-xs text-emerald-600">已开启</span>
+                    ) : (
+                      <span className="text-xs text-stone-400">未开启</span>
+                    )}
+                  </td>
                   <td className="px-5 py-3 text-stone-500">
                     {new Date(u.created_at).toLocaleDateString("zh-CN")}
                   </td>
-                  <td className="px-5 py-3 text-right">
+                  <td className="px-5 py-3 text-right space-x-3 whitespace-nowrap">
                     <button
                       onClick={() => { setResetTarget(u); setResetPwd(""); setResetError(""); }}
                       className="text-xs font-medium text-brand-600 hover:text-brand-700 transition-colors"
                     >
                       重置密码
                     </button>
+                    {u.id !== currentUserId && (
+                      <>
+                        <button
+                          onClick={() => handleToggleRole(u)}
+                          className="text-xs font-medium text-brand-600 hover:text-brand-700 transition-colors"
+                        >
+                          {u.role === "ADMIN" ? "设为普通用户" : "设为管理员"}
+                        </button>
+                        {u.two_factor_enabled && (
+                          <button
+                            onClick={() => handleReset2FA(u)}
+                            className="text-xs font-medium text-amber-600 hover:text-amber-700 transition-colors"
+                          >
+                            重置两步验证
+                          </button>
+                        )}
+                        <button
+                          onClick={() => handleToggleActive(u)}
+                          className="text-xs font-medium text-amber-600 hover:text-amber-700 transition-colors"
+                        >
+                          {u.active ? "停用" : "启用"}
+                        </button>
+                        <button
+                          onClick={() => handleDelete(u)}
+                          className="text-xs font-medium text-red-600 hover:text-red-700 transition-colors"
+                        >
+                          删除
+                        </button>
+                      </>
+                    )}
+                  </td>
+                </tr>
+              ))
+            )}
+          </tbody>
+        </table>
+      </div>
+
+      {/* Two-factor policy */}
+      <div className="card flex items-center justify-between p-5">
+        <div>
+          <h2 className="text-sm font-semibold text-stone-800">管理员两步验证</h2>
+          <p className="mt-1 text-xs text-stone-500">
+            开启后，所有管理员必须先在设置页开启两步验证才能使用系统。
+          </p>
+        </div>
+        <label className="flex items-center gap-2 text-sm text-stone-700">
+          <input type="checkbox" checked={require2FA} onChange={handleToggleRequire2FA} />
+          强制要求
+        </label>
+      </div>
+
+      {/* Login lockouts */}
+      <div className="card overflow-hidden">
+        <div className="px-5 py-4 border-b border-stone-100">
+          <h2 className="text-sm font-semibold text-stone-800">登录失败记录</h2>
+          <p className="mt-1 text-xs text-stone-500">
+            连续登录失败的账号和 IP 会被延迟或临时锁定，记录到期后自动清除。
+          </p>
+        </div>
+        <table className="w-full text-sm">
+          <thead>
+            <tr className="border-b border-stone-100 bg-stone-50/60 text-left text-xs font-medium uppercase tracking-wider text-stone-500">
+              <th className="px-5 py-3">对象</th>
+              <th className="px-5 py-3">失败次数</th>
+              <th className="px-5 py-3">最近失败</th>
+              <th className="px-5 py-3">状态</th>
+              <th className="px-5 py-3 text-right">操作</th>
+            </tr>
+          </thead>
+          <tbody className="divide-y divide-stone-100">
+            {lockouts.length === 0 ? (
+              <tr>
+                <td colSpan={5} className="px-5 py-8 text-center text-stone-400">
+                  暂无记录
+                </td>
+              </tr>
+            ) : (
+              lockouts.map((l) => (
+                <tr key={l.key} className="hover:bg-stone-50/40 transition-colors">
+                  <td className="px-5 py-3 font-medium text-stone-800">{lockoutSubject(l.key)}</td>
+                  <td className="px-5 py-3 text-stone-600">{l.failures}</td>
+                  <td className="px-5 py-3 text-stone-500">
+                    {new Date(l.last_failure_at).toLocaleString("zh-CN")}
+                  </td>
+                  <td className="px-5 py-3">
+                    {l.locked && l.blocked_until ? (
+                      <span className="inline-flex items-center rounded-full bg-red-50 px-2 py-0.5 text-xs font-medium text-red-600 border border-red-100">
+                        锁定至 {new Date(l.blocked_until).toLocaleTimeString("zh-CN")}
+                      </span>
+                    ) : l.blocked_until ? (
+                      <span className="text-xs text-amber-600">延迟中</span>
+                    ) : (
+                      <span className="text-xs text-stone-500">未限制</span>
+                    )}
+                  </td>
+                  <td className="px-5 py-3 text-right">
+                    <button
+                      onClick={() => handleClearLockout(l)}
+                      className="text-xs font-medium text-brand-600 hover:text-brand-700 transition-colors"
+                    >
+                      解除
+                    </button>
                   </td>
                 </tr>
               ))
@@ -252,11 +492,9 @@ export default function AdminUsersPage() {
               <input
                 type="password"
                 className="input"
-                placeholder="至少 6 个字符"
                 value={resetPwd}
                 onChange={(e) => setResetPwd(e.target.value)}
                 required
-                minLength={6}
                 autoFocus
               />
             </div>
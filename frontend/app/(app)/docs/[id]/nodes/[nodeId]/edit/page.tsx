@@ -148,6 +148,7 @@ export default function EditNodePage() {
     R: "", A: "", S: "", C: "", I: "",
   });
   const [subtasksText, setSubtasksText] = useState("");
+  const [revision, setRevision] = useState<number | undefined>(undefined);
 
   // UI state
   const [loading, setLoading] = useState(true);
@@ -171,6 +172,7 @@ export default function EditNodePage() {
         setDurationUnit(node.duration_unit || "DAY");
         setRaci(normalizeRaci(node.raci));
         setSubtasksText((node.subtasks || []).join("\n"));
+        setRevision(node.revision);
       })
       .catch((err) => setError(err.message))
       .finally(() => setLoading(false));
@@ -225,6 +227,7 @@ export default function EditNodePage() {
       raci: normalizedRaci,
       subtasks,
       diagram_json: emptyDiagram(),
+      revision,
     };
 
     if (process.env.NODE_ENV === "development") {
@@ -86,6 +86,7 @@ export default function EditFlowPage() {
         overview,
         diagram_json: diagramJSON,
         nodes,
+        revision: detail?.flow.revision,
       });
       router.push(`/flows/${flowId}`);
     } catch (err: unknown) {
@@ -11,6 +11,8 @@ import {
   listFlowVersions,
   submitFlowReview,
   publishFlow,
+  rejectFlow,
+  createFlowDraft,
   getCurrentUserId,
 } from "@/lib/api";
 import FlowOverviewCard from "@/components/flow-overview-card";
@@ -115,6 +117,30 @@ export default function FlowDetailPage() {
     }
   }
 
+  async function handleReject() {
+    setActionLoading(true);
+    try {
+      await rejectFlow(flowId);
+      await fetchData();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    } finally {
+      setActionLoading(false);
+    }
+  }
+
+  async function handleNewDraft() {
+    setActionLoading(true);
+    try {
+      await createFlowDraft(flowId);
+      await fetchData();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "操作失败");
+    } finally {
+      setActionLoading(false);
+    }
+  }
+
   return (
     <div className="max-w-7xl mx-auto space-y-5">
       {/* Overview card */}
@@ -149,6 +175,24 @@ export default function FlowDetailPage() {
             {actionLoading ? "处理中…" : "发布生效"}
           </button>
         )}
+        {isOwner && flow.status === "IN_REVIEW" && (
+          <button
+            onClick={handleReject}
+            disabled={actionLoading}
+            className="btn-secondary text-sm"
+          >
+            {actionLoading ? "处理中…" : "退回草稿"}
+          </button>
+        )}
+        {isOwner && flow.status === "EFFECTIVE" && (
+          <button
+            onClick={handleNewDraft}
+            disabled={actionLoading}
+            className="btn-secondary text-sm"
+          >
+            {actionLoading ? "处理中…" : "创建新草稿"}
+          </button>
+        )}
         <button
           onClick={() => setShowVersions(!showVersions)}
           className="btn-secondary text-sm"
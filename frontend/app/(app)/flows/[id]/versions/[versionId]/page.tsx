@@ -5,6 +5,7 @@ import { useParams, useRouter } from "next/navigation";
 import Link from "next/link";
 import dynamic from "next/dynamic";
 import {
+  FlowSnapshot,
   FlowVersion,
   getFlowVersionDetail,
   listFlowVersions,
@@ -22,39 +23,6 @@ const FlowDiagram = dynamic(() => import("@/components/flow-diagram"), {
   ),
 });
 
-interface SnapshotData {
-  flow: {
-    id: string;
-    flow_no: string;
-    title: string;
-    owner_id: string;
-    owner_dept_id: string;
-    overview: string;
-    status: string;
-    diagram_json: string;
-    created_at: string;
-    updated_at: string;
-  };
-  nodes: Array<{
-    id: string;
-    flow_id: string;
-    node_no: string;
-    name: string;
-    intro: string;
-    raci_json: string;
-    exec_form: string;
-    duration_min: number | null;
-    duration_max: number | null;
-    duration_unit: string;
-    prereq_text: string;
-    outputs_text: string;
-    subtasks_json: string;
-    sort_order: number;
-  }>;
-  total_duration_min_days: number;
-  total_duration_max_days: number;
-}
-
 export default function FlowVersionDetailPage() {
   const params = useParams();
   const router = useRouter();
@@ -85,14 +53,7 @@ export default function FlowVersionDetailPage() {
     load();
   }, [flowId, versionId]);
 
-  const snapshot = useMemo<SnapshotData | null>(() => {
-    if (!version?.snapshot_json) return null;
-    try {
-      return JSON.parse(version.snapshot_json) as SnapshotData;
-    } catch {
-      return null;
-    }
-  }, [version]);
+  const snapshot = useMemo<FlowSnapshot | null>(() => version?.snapshot ?? null, [version]);
 
   const versionIndex = allVersions.findIndex((v) => v.id === versionId);
   const versionNumber = versionIndex >= 0 ? allVersions.length - versionIndex : 0;
@@ -143,7 +104,7 @@ export default function FlowVersionDetailPage() {
       {/* Overview (from snapshot) */}
       {/* eslint-disable-next-line @typescript-eslint/no-explicit-any */}
       <FlowOverviewCard
-        flow={snapshot.flow as any}
+        flow={snapshot.flow}
         totalDurationMinDays={snapshot.total_duration_min_days}
         totalDurationMaxDays={snapshot.total_duration_max_days}
       />
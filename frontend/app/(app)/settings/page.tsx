@@ -1,33 +1,668 @@
 "use client";
 
+import { useEffect, useState, useCallback } from "react";
+import {
+  getMe,
+  updateMe,
+  changePassword,
+  listAPITokens,
+  createAPIToken,
+  revokeAPIToken,
+  getCurrentUserRole,
+  getPasswordPolicy,
+  describePasswordPolicy,
+  passwordErrorText,
+  getTwoFactorStatus,
+  setupTwoFactor,
+  confirmTwoFactor,
+  regenerateRecoveryCodes,
+  disableTwoFactor,
+  APIError,
+  type TwoFactorStatus,
+  type APIToken,
+  type TokenScope,
+} from "@/lib/api";
+
+/* ------------------------------------------------------------------ */
+/*  Settings Page — profile, password, 2FA, personal access tokens     */
+/* ------------------------------------------------------------------ */
+
+const SCOPE_LABELS: Record<TokenScope, string> = {
+  read: "读取",
+  write: "写入",
+  admin: "管理",
+};
+
+const EXPIRY_OPTIONS = [30, 90, 180, 365];
+
+const LOCALE_OPTIONS = [
+  { value: "zh-CN", label: "简体中文" },
+  { value: "en-US", label: "English" },
+];
+
 export default function SettingsPage() {
   return (
-    <div>
-      <h1 className="text-xl font-bold text-stone-900 mb-2">设置</h1>
-      <p className="text-sm text-stone-500 mb-8">系统偏好设置（即将推出）</p>
-
-      <div className="card flex items-center justify-center py-16 text-stone-400">
-        <div className="text-center">
-          <svg
-            className="mx-auto mb-4 h-12 w-12"
-            fill="none"
-            viewBox="0 0 24 24"
-            stroke="currentColor"
-            strokeWidth={1.5}
+    <div className="space-y-8">
+      <div>
+        <h1 className="text-xl font-bold tracking-tight text-stone-900">设置</h1>
+        <p className="mt-1 text-sm text-stone-500">个人资料、登录密码、两步验证与个人访问令牌</p>
+      </div>
+      <ProfileSection />
+      <PasswordSection />
+      <TwoFactorSection />
+      <TokensSection />
+    </div>
+  );
+}
+
+/* ---------- Profile ---------- */
+
+function ProfileSection() {
+  const [email, setEmail] = useState("");
+  const [displayName, setDisplayName] = useState("");
+  const [department, setDepartment] = useState("");
+  const [locale, setLocale] = useState("zh-CN");
+  const [error, setError] = useState("");
+  const [saved, setSaved] = useState(false);
+  const [saving, setSaving] = useState(false);
+
+  useEffect(() => {
+    getMe()
+      .then((me) => {
+        setEmail(me.email);
+        setDisplayName(me.display_name);
+        setDepartment(me.department);
+        setLocale(me.locale || "zh-CN");
+      })
+      .catch(() => {});
+  }, []);
+
+  async function handleSave(e: React.FormEvent) {
+    e.preventDefault();
+    setError("");
+    setSaved(false);
+    setSaving(true);
+    try {
+      await updateMe({ display_name: displayName, department, locale });
+      setSaved(true);
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : "保存失败");
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  return (
+    <form onSubmit={handleSave} className="card p-5 space-y-4">
+      <h2 className="text-sm font-semibold text-stone-800">个人资料</h2>
+      {error && (
+        <div className="rounded-lg bg-red-50 px-4 py-2.5 text-sm text-red-700 border border-red-100">
+          {error}
+        </div>
+      )}
+      <div className="grid grid-cols-1 gap-4 sm:grid-cols-2">
+        <div>
+          <label className="label">邮箱</label>
+          <input className="input bg-stone-50 text-stone-500" value={email} readOnly />
+        </div>
+        <div>
+          <label className="label">显示名称</label>
+          <input
+            className="input"
+            value={displayName}
+            onChange={(e) => setDisplayName(e.target.value)}
+            maxLength={100}
+          />
+        </div>
+        <div>
+          <label className="label">部门</label>
+          <input
+            className="input"
+            value={department}
+            onChange={(e) => setDepartment(e.target.value)}
+            maxLength={100}
+          />
+        </div>
+        <div>
+          <label className="label">语言</label>
+          <select className="input" value={locale} onChange={(e) => setLocale(e.target.value)}>
+            {LOCALE_OPTIONS.map((o) => (
+              <option key={o.value} value={o.value}>
+                {o.label}
+              </option>
+            ))}
+          </select>
+        </div>
+      </div>
+      <div className="flex items-center gap-3">
+        <button type="submit" disabled={saving} className="btn-primary text-sm">
+          {saving ? "保存中…" : "保存"}
+        </button>
+        {saved && <span className="text-sm text-emerald-600">已保存</span>}
+      </div>
+    </form>
+  );
+}
+
+/* ---------- Password ---------- */
+
+function PasswordSection() {
+  const [current, setCurrent] = useState("");
+  const [next, setNext] = useState("");
+  const [confirmNext, setConfirmNext] = useState("");
+  const [error, setError] = useState("");
+  const [done, setDone] = useState(false);
+  const [saving, setSaving] = useState(false);
+  const [policyText, setPolicyText] = useState("");
+
+  useEffect(() => {
+    getPasswordPolicy()
+      .then((p) => setPolicyText(describePasswordPolicy(p)))
+      .catch(() => setPolicyText(""));
+  }, []);
+
+  async function handleChange(e: React.FormEvent) {
+    e.preventDefault();
+    setError("");
+    setDone(false);
+    if (next !== confirmNext) {
+      setError("两次输入的新密码不一致");
+      return;
+    }
+    setSaving(true);
+    try {
+      await changePassword(current, next);
+      setCurrent("");
+      setNext("");
+      setConfirmNext("");
+      setDone(true);
+    } catch (err: unknown) {
+      if (err instanceof APIError && err.fields?.current_password) {
+        setError("当前密码不正确");
+      } else if (err instanceof APIError && err.fields?.new_password) {
+        setError(passwordErrorText(err.fields.new_password));
+      } else {
+        setError(err instanceof Error ? err.message : "修改失败");
+      }
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  return (
+    <form onSubmit={handleChange} className="card p-5 space-y-4">
+      <div>
+        <h2 className="text-sm font-semibold text-stone-800">修改密码</h2>
+        <p className="mt-1 text-xs text-stone-500">修改后，其他设备上的登录将全部失效。</p>
+        {policyText && <p className="mt-1 text-xs text-stone-500">密码要求：{policyText}。</p>}
+      </div>
+      {error && (
+        <div className="rounded-lg bg-red-50 px-4 py-2.5 text-sm text-red-700 border border-red-100">
+          {error}
+        </div>
+      )}
+      <div className="grid grid-cols-1 gap-4 sm:grid-cols-3">
+        <div>
+          <label className="label">当前密码</label>
+          <input
+            type="password"
+            className="input"
+            value={current}
+            onChange={(e) => setCurrent(e.target.value)}
+            required
+          />
+        </div>
+        <div>
+          <label className="label">新密码</label>
+          <input
+            type="password"
+            className="input"
+            value={next}
+            onChange={(e) => setNext(e.target.value)}
+            required
+          />
+        </div>
+        <div>
+          <label className="label">确认新密码</label>
+          <input
+            type="password"
+            className="input"
+            value={confirmNext}
+            onChange={(e) => setConfirmNext(e.target.value)}
+            required
+          />
+        </div>
+      </div>
+      <div className="flex items-center gap-3">
+        <button type="submit" disabled={saving} className="btn-primary text-sm">
+          {saving ? "修改中…" : "修改密码"}
+        </button>
+        {done && <span className="text-sm text-emerald-600">密码已修改</span>}
+      </div>
+    </form>
+  );
+}
+
+/* ---------- Two-factor authentication ---------- */
+
+function codeErrorText(err: unknown): string {
+  if (err instanceof APIError && err.fields?.code) return "验证码不正确";
+  if (err instanceof APIError && err.code === "TOO_MANY_REQUESTS") return "验证失败次数过多，请稍后再试";
+  return err instanceof Error ? err.message : "操作失败";
+}
+
+function TwoFactorSection() {
+  const [status, setStatus] = useState<TwoFactorStatus | null>(null);
+  const [setup, setSetup] = useState<{ secret: string; uri: string } | null>(null);
+  const [code, setCode] = useState("");
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [error, setError] = useState("");
+  const [busy, setBusy] = useState(false);
+
+  const fetchStatus = useCallback(async () => {
+    try {
+      setStatus(await getTwoFactorStatus());
+    } catch {
+      setStatus(null);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchStatus();
+  }, [fetchStatus]);
+
+  async function run(action: () => Promise<void>) {
+    setError("");
+    setBusy(true);
+    try {
+      await action();
+      setCode("");
+      await fetchStatus();
+    } catch (err: unknown) {
+      setError(codeErrorText(err));
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  const handleSetup = () => run(async () => setSetup(await setupTwoFactor()));
+  const handleConfirm = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      setRecoveryCodes((await confirmTwoFactor(code)).recovery_codes);
+      setSetup(null);
+    });
+  };
+  const handleRegenerate = () =>
+    run(async () => setRecoveryCodes((await regenerateRecoveryCodes(code)).recovery_codes));
+  const handleDisable = () => {
+    if (!confirm("确定关闭两步验证？")) return;
+    run(async () => {
+      await disableTwoFactor(code);
+    });
+  };
+
+  if (!status) return null;
+
+  return (
+    <div className="card p-5 space-y-4">
+      <div>
+        <h2 className="text-sm font-semibold text-stone-800">两步验证</h2>
+        <p className="mt-1 text-xs text-stone-500">
+          登录时除密码外，还需输入身份验证器（如 Google Authenticator、Microsoft Authenticator）生成的 6 位验证码。
+        </p>
+      </div>
+      {status.required && !status.enabled && (
+        <div className="rounded-lg bg-amber-50 px-4 py-2.5 text-sm text-amber-800 border border-amber-100">
+          管理员要求你的账号开启两步验证，开启前无法使用其他功能。
+        </div>
+      )}
+      {error && (
+        <div className="rounded-lg bg-red-50 px-4 py-2.5 text-sm text-red-700 border border-red-100">
+          {error}
+        </div>
+      )}
+
+      {/* Recovery codes (shown once) */}
+      {recoveryCodes && (
+        <div className="rounded-lg border border-emerald-200 bg-emerald-50/60 p-4 space-y-3">
+          <p className="text-sm font-medium text-emerald-800">
+            请妥善保存以下恢复码。手机丢失时，每个恢复码可代替验证码使用一次；关闭后将无法再次查看。
+          </p>
+          <div className="grid grid-cols-2 gap-x-6 gap-y-1 font-mono text-sm text-stone-800 sm:grid-cols-5">
+            {recoveryCodes.map((c) => (
+              <span key={c}>{c}</span>
+            ))}
+          </div>
+          <button
+            onClick={() => setRecoveryCodes(null)}
+            className="text-xs font-medium text-emerald-700 hover:text-emerald-800 transition-colors"
           >
-            <path
-              strokeLinecap="round"
-              strokeLinejoin="round"
-              d="M9.594 3.94c.09-.542.56-.94 1.11-.94h2.593c.55 0 1.02.398 1.11.94l.213 1.281c.063.374.313.686.645.87.074.04.147.083.22.127.325.196.72.257 1.075.124l1.217-.456a1.125 1.125 0 0 1 1.37.49l1.296 2.247a1.125 1.125 0 0 1-.26 1.431l-1.003.827c-.293.24-.438.613-.431.992a7.723 7.723 0 0 1 0 .255c-.007.378.138.75.43.99l1.005.828c.424.35.534.954.26 1.43l-1.298 2.247a1.125 1.125 0 0 1-1.369.491l-1.217-.456c-.355-.133-.75-.072-1.076.124a6.47 6.47 0 0 1-.22.128c-.331.183-.581.495-.644.869l-.213 1.28c-.09.543-.56.941-1.11.941h-2.594c-.55 0-1.02-.398-1.11-.94l-.213-1.281c-.062-.374-.312-.686-.644-.87a6.52 6.52 0 0 1-.22-.127c-.325-.196-.72-.257-1.076-.124l-1.217.456a1.125 1.125 0 0 1-1.369-.49l-1.297-2.247a1.125 1.125 0 0 1 .26-1.431l1.004-.827c.292-.24.437-.613.43-.992a7.723 7.723 0 0 1 0-.255c.007-.378-.138-.75-.43-.99l-1.004-.828a1.125 1.125 0 0 1-.26-1.43l1.297-2.247a1.125 1.125 0 0 1 1.37-.491l1.216.456c.356.133.751.072 1.076-.124.072-.044.146-.087.22-.128.332-.183.582-.495.644-.869l.214-1.281Z"
+            我已保存，关闭
+          </button>
+        </div>
+      )}
+
+      {status.enabled ? (
+        <div className="space-y-3">
+          <p className="text-sm text-stone-700">
+            <span className="font-medium text-emerald-700">已开启</span>，剩余恢复码 {status.recovery_codes_remaining} 个。
+          </p>
+          <div className="flex flex-wrap items-center gap-3">
+            <input
+              className="input w-56 font-mono"
+              placeholder="当前验证码或恢复码"
+              autoComplete="one-time-code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
             />
-            <path
-              strokeLinecap="round"
-              strokeLinejoin="round"
-              d="M15 12a3 3 0 1 1-6 0 3 3 0 0 1 6 0Z"
+            <button onClick={handleRegenerate} disabled={busy || !code} className="btn-primary text-sm">
+              重新生成恢复码
+            </button>
+            {!status.required && (
+              <button
+                onClick={handleDisable}
+                disabled={busy || !code}
+                className="rounded-lg border border-red-200 px-4 py-2 text-sm text-red-600 hover:bg-red-50 transition-colors"
+              >
+                关闭两步验证
+              </button>
+            )}
+          </div>
+        </div>
+      ) : setup ? (
+        <form onSubmit={handleConfirm} className="space-y-3">
+          <p className="text-sm text-stone-700">
+            在身份验证器中添加账户并输入下方密钥（基于时间），或在手机上打开链接，然后填写生成的验证码。
+          </p>
+          <input readOnly className="input font-mono text-xs" value={setup.secret} onFocus={(e) => e.target.select()} />
+          <a href={setup.uri} className="block break-all text-xs text-brand-600 hover:underline">
+            {setup.uri}
+          </a>
+          <div className="flex items-center gap-3">
+            <input
+              className="input w-40 font-mono"
+              placeholder="6 位验证码"
+              autoComplete="one-time-code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              required
             />
+            <button type="submit" disabled={busy} className="btn-primary text-sm">
+              {busy ? "验证中…" : "确认开启"}
+            </button>
+          </div>
+        </form>
+      ) : (
+        <button onClick={handleSetup} disabled={busy} className="btn-primary text-sm">
+          开启两步验证
+        </button>
+      )}
+    </div>
+  );
+}
+
+/* ---------- Personal access tokens ---------- */
+
+function TokensSection() {
+  const isAdmin = getCurrentUserRole() === "ADMIN";
+  const [tokens, setTokens] = useState<APIToken[]>([]);
+  const [loading, setLoading] = useState(true);
+
+  // New-token form
+  const [showForm, setShowForm] = useState(false);
+  const [formName, setFormName] = useState("");
+  const [formScopes, setFormScopes] = useState<TokenScope[]>(["read"]);
+  const [formDays, setFormDays] = useState(90);
+  const [formError, setFormError] = useState("");
+  const [formLoading, setFormLoading] = useState(false);
+
+  // A freshly created token, shown until dismissed
+  const [newToken, setNewToken] = useState<string | null>(null);
+  const [copied, setCopied] = useState(false);
+
+  const fetchTokens = useCallback(async () => {
+    try {
+      const data = await listAPITokens();
+      setTokens(Array.isArray(data) ? data : []);
+    } catch {
+      setTokens([]);
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchTokens();
+  }, [fetchTokens]);
+
+  function toggleScope(scope: TokenScope) {
+    setFormScopes((prev) =>
+      prev.includes(scope) ? prev.filter((s) => s !== scope) : [...prev, scope]
+    );
+  }
+
+  // ---------- Create token ----------
+  async function handleCreate(e: React.FormEvent) {
+    e.preventDefault();
+    setFormError("");
+    setFormLoading(true);
+    try {
+      const created = await createAPIToken({
+        name: formName,
+        scopes: formScopes,
+        expires_in_days: formDays,
+      });
+      setNewToken(created.token);
+      setCopied(false);
+      setFormName("");
+      setFormScopes(["read"]);
+      setFormDays(90);
+      setShowForm(false);
+      await fetchTokens();
+    } catch (err: unknown) {
+      setFormError(err instanceof Error ? err.message : "创建失败");
+    } finally {
+      setFormLoading(false);
+    }
+  }
+
+  // ---------- Revoke token ----------
+  async function handleRevoke(token: APIToken) {
+    if (!confirm(`确定吊销令牌「${token.name}」？使用它的脚本将立即失效。`)) return;
+    try {
+      await revokeAPIToken(token.id);
+      await fetchTokens();
+    } catch (err: unknown) {
+      alert(err instanceof Error ? err.message : "吊销失败");
+    }
+  }
+
+  async function handleCopy() {
+    if (!newToken) return;
+    try {
+      await navigator.clipboard.writeText(newToken);
+      setCopied(true);
+    } catch {
+      // Clipboard unavailable (e.g. plain http); the token stays selectable.
+    }
+  }
+
+  // ---------- Render ----------
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center py-20">
+        <div className="h-8 w-8 animate-spin rounded-full border-4 border-stone-200 border-t-brand-600" />
+      </div>
+    );
+  }
+
+  return (
+    <div className="space-y-4">
+      {/* Header */}
+      <div className="flex items-center justify-between">
+        <div>
+          <h2 className="text-sm font-semibold text-stone-800">个人访问令牌</h2>
+          <p className="mt-1 text-xs text-stone-500">
+            供脚本与 CI 调用 API，请求头为 Authorization: Bearer &lt;令牌&gt;
+          </p>
+        </div>
+        <button
+          onClick={() => setShowForm(!showForm)}
+          className="btn-primary gap-1.5 text-sm"
+        >
+          <svg className="h-4 w-4" fill="none" viewBox="0 0 24 24" stroke="currentColor" strokeWidth={2}>
+            <path strokeLinecap="round" strokeLinejoin="round" d="M12 4.5v15m7.5-7.5h-15" />
           </svg>
-          <p className="text-sm">功能开发中，敬请期待</p>
+          新建令牌
+        </button>
+      </div>
+
+      {/* Newly created token (shown once) */}
+      {newToken && (
+        <div className="card border-emerald-200 bg-emerald-50/60 p-5 space-y-3">
+          <p className="text-sm font-medium text-emerald-800">
+            令牌已创建。请立即复制保存，关闭后将无法再次查看。
+          </p>
+          <div className="flex gap-2">
+            <input readOnly className="input font-mono text-xs" value={newToken} onFocus={(e) => e.target.select()} />
+            <button onClick={handleCopy} className="btn-primary text-sm whitespace-nowrap">
+              {copied ? "已复制" : "复制"}
+            </button>
+          </div>
+          <button
+            onClick={() => setNewToken(null)}
+            className="text-xs font-medium text-emerald-700 hover:text-emerald-800 transition-colors"
+          >
+            我已保存，关闭
+          </button>
         </div>
+      )}
+
+      {/* Create token form (slide-down) */}
+      {showForm && (
+        <form onSubmit={handleCreate} className="card p-5 space-y-4">
+          <h3 className="text-sm font-semibold text-stone-800">新建令牌</h3>
+          {formError && (
+            <div className="rounded-lg bg-red-50 px-4 py-2.5 text-sm text-red-700 border border-red-100">
+              {formError}
+            </div>
+          )}
+          <div className="grid grid-cols-1 gap-4 sm:grid-cols-3">
+            <div>
+              <label className="label">名称</label>
+              <input
+                className="input"
+                placeholder="例如：CI 导出"
+                value={formName}
+                onChange={(e) => setFormName(e.target.value)}
+                required
+                maxLength={100}
+              />
+            </div>
+            <div>
+              <label className="label">权限范围</label>
+              <div className="flex gap-4 pt-2">
+                {(["read", "write", "admin"] as TokenScope[])
+                  .filter((scope) => scope !== "admin" || isAdmin)
+                  .map((scope) => (
+                    <label key={scope} className="flex items-center gap-1.5 text-sm text-stone-700">
+                      <input
+                        type="checkbox"
+                        checked={formScopes.includes(scope)}
+                        onChange={() => toggleScope(scope)}
+                      />
+                      {SCOPE_LABELS[scope]}
+                    </label>
+                  ))}
+              </div>
+            </div>
+            <div>
+              <label className="label">有效期</label>
+              <select
+                className="input"
+                value={formDays}
+                onChange={(e) => setFormDays(Number(e.target.value))}
+              >
+                {EXPIRY_OPTIONS.map((d) => (
+                  <option key={d} value={d}>
+                    {d} 天
+                  </option>
+                ))}
+              </select>
+            </div>
+          </div>
+          <div className="flex gap-3">
+            <button
+              type="submit"
+              disabled={formLoading || formScopes.length === 0}
+              className="btn-primary text-sm"
+            >
+              {formLoading ? "创建中…" : "创建"}
+            </button>
+            <button
+              type="button"
+              onClick={() => { setShowForm(false); setFormError(""); }}
+              className="rounded-lg border border-stone-200 px-4 py-2 text-sm text-stone-600 hover:bg-stone-50 transition-colors"
+            >
+              取消
+            </button>
+          </div>
+        </form>
+      )}
+
+      {/* Tokens table */}
+      <div className="card overflow-hidden">
+        <table className="w-full text-sm">
+          <thead>
+            <tr className="border-b border-stone-100 bg-stone-50/60 text-left text-xs font-medium uppercase tracking-wider text-stone-500">
+              <th className="px-5 py-3">名称</th>
+              <th className="px-5 py-3">令牌</th>
+              <th className="px-5 py-3">权限范围</th>
+              <th className="px-5 py-3">过期时间</th>
+              <th className="px-5 py-3">最近使用</th>
+              <th className="px-5 py-3 text-right">操作</th>
+            </tr>
+          </thead>
+          <tbody className="divide-y divide-stone-100">
+            {tokens.length === 0 ? (
+              <tr>
+                <td colSpan={6} className="px-5 py-10 text-center text-stone-400">
+                  暂无令牌
+                </td>
+              </tr>
+            ) : (
+              tokens.map((t) => {
+                const expired = new Date(t.expires_at) <= new Date();
+                return (
+                  <tr key={t.id} className="hover:bg-stone-50/40 transition-colors">
+                    <td className="px-5 py-3 font-medium text-stone-800">{t.name}</td>
+                    <td className="px-5 py-3 font-mono text-xs text-stone-500">{t.token_prefix}…</td>
+                    <td className="px-5 py-3 text-stone-600">
+                      {t.scopes.map((s) => SCOPE_LABELS[s] ?? s).join("、")}
+                    </td>
+                    <td className={`px-5 py-3 ${expired ? "text-red-600" : "text-stone-500"}`}>
+                      {new Date(t.expires_at).toLocaleDateString("zh-CN")}
+                      {expired && "（已过期）"}
+                    </td>
+                    <td className="px-5 py-3 text-stone-500">
+                      {t.last_used_at ? new Date(t.last_used_at).toLocaleString("zh-CN") : "从未使用"}
+                    </td>
+                    <td className="px-5 py-3 text-right">
+                      <button
+                        onClick={() => handleRevoke(t)}
+                        className="text-xs font-medium text-red-600 hover:text-red-700 transition-colors"
+                      >
+                        吊销
+                      </button>
+                    </td>
+                  </tr>
+                );
+              })
+            )}
+          </tbody>
+        </table>
       </div>
     </div>
   );
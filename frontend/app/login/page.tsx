@@ -2,7 +2,17 @@
 
 import { useEffect, useState } from "react";
 import { useRouter } from "next/navigation";
-import { login as apiLogin, isLoggedIn } from "@/lib/api";
+import {
+  login as apiLogin,
+  completeLogin,
+  getOIDCInfo,
+  isLoggedIn,
+  APIError,
+  SSO_CHALLENGE_KEY,
+  SSO_LOGIN_URL,
+  type AuthResult,
+  type OIDCInfo,
+} from "@/lib/api";
 import { useAuth } from "@/lib/auth";
 
 export default function LoginPage() {
@@ -12,6 +22,10 @@ export default function LoginPage() {
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
   const [loading, setLoading] = useState(false);
+  // Second step for accounts with two-factor authentication
+  const [challenge, setChallenge] = useState<string | null>(null);
+  const [code, setCode] = useState("");
+  const [sso, setSSO] = useState<OIDCInfo | null>(null);
 
   // Already logged in → redirect to dashboard
   useEffect(() => {
@@ -20,22 +34,54 @@ export default function LoginPage() {
     }
   }, [authLoading, loggedIn, router]);
 
+  useEffect(() => {
+    getOIDCInfo().then(setSSO).catch(() => setSSO(null));
+    // Single sign-on for an account with two-factor authentication ends
+    // here, at the code step.
+    const pending = sessionStorage.getItem(SSO_CHALLENGE_KEY);
+    if (pending) {
+      sessionStorage.removeItem(SSO_CHALLENGE_KEY);
+      setChallenge(pending);
+    }
+  }, []);
+
   async function handleSubmit(e: React.FormEvent) {
     e.preventDefault();
     setError("");
     setLoading(true);
 
     try {
-      await apiLogin(email, password);
-      signIn(); // update auth context so AppShell sees loggedIn=true
-      router.replace("/dashboard");
+      if (challenge) {
+        finish(await completeLogin(challenge, code));
+        return;
+      }
+      const result = await apiLogin(email, password);
+      if (result.two_factor) {
+        setChallenge(result.two_factor.challenge_token);
+        return;
+      }
+      finish(result as AuthResult);
     } catch (err: unknown) {
-      setError(err instanceof Error ? err.message : "登录失败，请重试");
+      if (challenge && err instanceof APIError && err.code === "UNAUTHORIZED") {
+        setError("验证码不正确或已过期；验证超过 5 分钟需刷新页面重新登录");
+      } else if (err instanceof APIError && err.code === "FORBIDDEN") {
+        setError("账号已停用，请联系管理员");
+      } else if (err instanceof APIError && err.code === "TOO_MANY_REQUESTS") {
+        setError("登录失败次数过多，请稍后再试");
+      } else {
+        setError(err instanceof Error ? err.message : "登录失败，请重试");
+      }
     } finally {
       setLoading(false);
     }
   }
 
+  function finish(result: AuthResult) {
+    signIn(); // update auth context so AppShell sees loggedIn=true
+    // Admins who must enroll in two-factor authentication start there.
+    router.replace(result.two_factor_setup_required ? "/settings" : "/dashboard");
+  }
+
   return (
     <div className="flex min-h-[80vh] items-center justify-center">
       <div className="w-full max-w-sm">
@@ -57,36 +103,61 @@ export default function LoginPage() {
             </div>
           )}
 
-          <div>
-            <label htmlFor="email" className="label">
-              邮箱
-            </label>
-            <input
-              id="email"
-              type="email"
-              className="input"
-              placeholder="you@example.com"
-              value={email}
-              onChange={(e) => setEmail(e.target.value)}
-              required
-              autoFocus
-            />
-          </div>
+          {challenge ? (
+            <div>
+              <label htmlFor="code" className="label">
+                验证码
+              </label>
+              <input
+                id="code"
+                className="input font-mono"
+                placeholder="身份验证器中的 6 位数字或恢复码"
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                required
+                autoFocus
+              />
+              <p className="mt-1.5 text-xs text-stone-500">
+                此账号已开启两步验证。
+              </p>
+            </div>
+          ) : (
+            <>
+              <div>
+                <label htmlFor="email" className="label">
+                  邮箱
+                </label>
+                <input
+                  id="email"
+                  type="text"
+                  inputMode="email"
+                  autoComplete="username"
+                  className="input"
+                  placeholder="you@example.com"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                  autoFocus
+                />
+              </div>
 
-          <div>
-            <label htmlFor="password" className="label">
-              密码
-            </label>
-            <input
-              id="password"
-              type="password"
-              className="input"
-              placeholder="输入密码"
-              value={password}
-              onChange={(e) => setPassword(e.target.value)}
-              required
-            />
-          </div>
+              <div>
+                <label htmlFor="password" className="label">
+                  密码
+                </label>
+                <input
+                  id="password"
+                  type="password"
+                  className="input"
+                  placeholder="输入密码"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                />
+              </div>
+            </>
+          )}
 
           <button type="submit" disabled={loading} className="btn-primary w-full">
             {loading ? (
@@ -94,12 +165,20 @@ export default function LoginPage() {
                 <span className="h-4 w-4 animate-spin rounded-full border-2 border-white/30 border-t-white" />
                 处理中…
               </span>
+            ) : challenge ? (
+              "验证"
             ) : (
               "登录"
             )}
           </button>
         </form>
 
+        {sso?.enabled && !challenge && (
+          <a href={SSO_LOGIN_URL} className="btn-secondary mt-4 w-full">
+            使用 {sso.display_name || "SSO"} 登录
+          </a>
+        )}
+
         <p className="mt-5 text-center text-xs text-stone-400">
           账号由管理员创建，如需帮助请联系管理员
         </p>
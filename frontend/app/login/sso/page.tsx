@@ -0,0 +1,70 @@
+"use client";
+
+import { useEffect, useRef, useState } from "react";
+import Link from "next/link";
+import { useRouter, useSearchParams } from "next/navigation";
+import { completeSSOLogin, APIError, SSO_CHALLENGE_KEY, type AuthResult } from "@/lib/api";
+import { useAuth } from "@/lib/auth";
+
+// The identity provider redirects here after single sign-on.
+export default function SSOCallbackPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { signIn } = useAuth();
+  const [error, setError] = useState("");
+  // A code works once; React may run effects twice in development.
+  const started = useRef(false);
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    const code = searchParams.get("code");
+    const state = searchParams.get("state");
+    if (searchParams.get("error") || !code || !state) {
+      setError(searchParams.get("error_description") || "身份提供方未完成登录");
+      return;
+    }
+
+    completeSSOLogin(state, code)
+      .then((result) => {
+        if (result.two_factor) {
+          // The login page asks for the code.
+          sessionStorage.setItem(SSO_CHALLENGE_KEY, result.two_factor.challenge_token);
+          router.replace("/login");
+          return;
+        }
+        signIn();
+        router.replace((result as AuthResult).two_factor_setup_required ? "/settings" : "/dashboard");
+      })
+      .catch((err: unknown) => {
+        if (err instanceof APIError && err.code === "FORBIDDEN") {
+          setError(err.message.replace(/^forbidden: /, ""));
+        } else {
+          setError("单点登录失败，请重新登录");
+        }
+      });
+  }, [searchParams, router, signIn]);
+
+  return (
+    <div className="flex min-h-[80vh] items-center justify-center">
+      <div className="w-full max-w-sm text-center">
+        {error ? (
+          <div className="card p-6 space-y-4">
+            <div className="rounded-lg bg-red-50 px-4 py-3 text-sm text-red-700 border border-red-100">
+              {error}
+            </div>
+            <Link href="/login" className="btn-primary">
+              返回登录
+            </Link>
+          </div>
+        ) : (
+          <p className="inline-flex items-center gap-2 text-sm text-stone-500">
+            <span className="h-4 w-4 animate-spin rounded-full border-2 border-stone-300 border-t-stone-600" />
+            正在登录…
+          </p>
+        )}
+      </div>
+    </div>
+  );
+}
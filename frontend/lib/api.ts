@@ -15,9 +15,23 @@ export interface APIResponse<T> {
 
 export interface AuthResult {
   token: string;
+  refresh_token: string;
+  /** Access token lifetime in seconds. */
+  expires_in: number;
   user: { id: string; email: string; role: string; created_at: string };
+  /** The user must enroll in two-factor authentication before anything else. */
+  two_factor_setup_required?: boolean;
 }
 
+/** Returned by the first login step for users with two-factor authentication. */
+export interface TwoFactorChallenge {
+  challenge_token: string;
+  expires_in: number; // seconds
+}
+
+/** Either a token pair or, with two-factor authentication, a challenge. */
+export type LoginResult = Partial<AuthResult> & { two_factor?: TwoFactorChallenge };
+
 // ---------- Flow types ----------
 
 export type FlowStatus = "DRAFT" | "IN_REVIEW" | "EFFECTIVE";
@@ -32,8 +46,10 @@ export interface Flow {
   status: FlowStatus;
   diagram_json: string;
   latest_version_id?: string;
+  revision: number;
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface FlowNode {
@@ -60,18 +76,33 @@ export interface FlowDetail {
   total_duration_max_days: number;
 }
 
+export interface FlowSnapshot {
+  schema_version: number;
+  flow: Flow;
+  nodes: FlowNode[];
+  total_duration_min_days: number;
+  total_duration_max_days: number;
+}
+
+/** Version metadata; `snapshot` is only present on the detail endpoint. */
 export interface FlowVersion {
   id: string;
   flow_id: string;
-  snapshot_json: string;
   created_by: string;
   created_at: string;
+  restored_from_version_id?: string;
+  snapshot?: FlowSnapshot;
 }
 
 export interface UserInfo {
   id: string;
   email: string;
   role: string;
+  display_name: string;
+  department: string;
+  locale: string;
+  active: boolean;
+  two_factor_enabled: boolean;
   created_at: string;
 }
 
@@ -96,9 +127,57 @@ function getToken(): string | null {
   return localStorage.getItem("token");
 }
 
+function storeSession(result: AuthResult) {
+  localStorage.setItem("token", result.token);
+  localStorage.setItem("refresh_token", result.refresh_token);
+}
+
+function clearSession() {
+  localStorage.removeItem("token");
+  localStorage.removeItem("refresh_token");
+}
+
+let refreshing: Promise<boolean> | null = null;
+
+/**
+ * Exchange the stored refresh token for a new token pair. Refresh tokens are
+ * single-use and the backend ends the session when one is presented twice, so
+ * concurrent callers share one attempt, and a token already replaced by
+ * another tab is not refreshed again.
+ */
+function refreshSession(staleToken: string | null): Promise<boolean> {
+  if (getToken() !== staleToken) return Promise.resolve(true);
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem("refresh_token");
+      if (!refreshToken) return false;
+      try {
+        const res = await fetch(`${BASE}/auth/refresh`, {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        const body: APIResponse<AuthResult> = await res.json();
+        if (!res.ok || !body.data) {
+          clearSession();
+          return false;
+        }
+        storeSession(body.data);
+        return true;
+      } catch {
+        return false;
+      }
+    })().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
 async function request<T>(
   path: string,
-  options: RequestInit = {}
+  options: RequestInit = {},
+  retried = false
 ): Promise<T> {
   const token = getToken();
   const headers: Record<string, string> = {
@@ -111,6 +190,13 @@ async function request<T>(
 
   const res = await fetch(`${BASE}${path}`, { ...options, headers });
 
+  // Access tokens are short-lived: refresh once and replay the request.
+  if (res.status === 401 && token && !retried && !path.startsWith("/auth/")) {
+    if (await refreshSession(token)) {
+      return request<T>(path, options, true);
+    }
+  }
+
   // Use text() + JSON.parse() instead of json() for better diagnostics
   const raw = await res.text();
 
@@ -134,6 +220,11 @@ async function request<T>(
   }
 
   if (body.error) {
+    // Admins who must enroll in two-factor authentication can only do that.
+    if (body.error.code === "TWO_FACTOR_REQUIRED" && typeof window !== "undefined" &&
+        window.location.pathname !== "/settings") {
+      window.location.assign("/settings");
+    }
     if (process.env.NODE_ENV === "development") {
       console.warn("[API Error]", { request_id: body.request_id, fields: body.error.fields });
     }
@@ -145,17 +236,108 @@ async function request<T>(
 
 // ---------- Auth ----------
 
+/**
+ * First login step. For users with two-factor authentication the result
+ * carries a challenge for completeLogin instead of a session.
+ */
 export async function login(email: string, password: string) {
-  const result = await request<AuthResult>("/auth/login", {
+  const result = await request<LoginResult>("/auth/login", {
     method: "POST",
     body: JSON.stringify({ email, password }),
   });
-  localStorage.setItem("token", result.token);
+  if (!result.two_factor) storeSession(result as AuthResult);
   return result;
 }
 
-export function logout() {
-  localStorage.removeItem("token");
+/** Second login step: a TOTP code or a recovery code. */
+export async function completeLogin(challengeToken: string, code: string) {
+  const result = await request<AuthResult>("/auth/login/2fa", {
+    method: "POST",
+    body: JSON.stringify({ challenge_token: challengeToken, code }),
+  });
+  storeSession(result);
+  return result;
+}
+
+// ---------- Single sign-on ----------
+
+export interface OIDCInfo {
+  enabled: boolean;
+  display_name?: string;
+}
+
+/** The browser navigates here to log in at the identity provider. */
+export const SSO_LOGIN_URL = `${BASE}/auth/oidc/login`;
+
+/** Where a two-factor challenge from single sign-on waits for the login page. */
+export const SSO_CHALLENGE_KEY = "sso_challenge";
+
+export async function getOIDCInfo() {
+  return request<OIDCInfo>("/auth/oidc");
+}
+
+/**
+ * Completes single sign-on with the code and state the identity provider
+ * redirected back with. Like login, the result may be a challenge.
+ */
+export async function completeSSOLogin(state: string, code: string) {
+  const result = await request<LoginResult>("/auth/oidc/callback", {
+    method: "POST",
+    body: JSON.stringify({ state, code }),
+  });
+  if (!result.two_factor) storeSession(result as AuthResult);
+  return result;
+}
+
+export interface PasswordPolicy {
+  min_length: number;
+  min_classes: number; // of lower case, upper case, digits and symbols
+  history: number; // recent passwords that may not be reused
+}
+
+export async function getPasswordPolicy() {
+  return request<PasswordPolicy>("/auth/password_policy");
+}
+
+export function describePasswordPolicy(p: PasswordPolicy): string {
+  let text = `至少 ${p.min_length} 个字符，包含小写字母、大写字母、数字、符号中的至少 ${p.min_classes} 类`;
+  if (p.history > 0) text += `，且不能与最近 ${p.history} 次使用的密码相同`;
+  return text;
+}
+
+/** Text for a password validation code returned in APIError.fields. */
+export function passwordErrorText(code: string): string {
+  switch (code) {
+    case "too_short":
+      return "密码太短";
+    case "too_long":
+      return "密码过长（最多 72 字节）";
+    case "too_few_classes":
+      return "密码包含的字符种类不足（小写字母、大写字母、数字、符号）";
+    case "breached":
+      return "该密码出现在已泄露密码列表中，请换一个";
+    case "reused":
+      return "不能使用最近用过的密码";
+    case "same_as_current":
+      return "新密码不能与当前密码相同";
+    default:
+      return "密码不符合要求";
+  }
+}
+
+/** End the session on the server (best effort) and forget the tokens. */
+export async function logout() {
+  const refreshToken = localStorage.getItem("refresh_token");
+  clearSession();
+  if (!refreshToken) return;
+  try {
+    await request("/auth/logout", {
+      method: "POST",
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+  } catch {
+    // The tokens are gone locally; an unreachable server changes nothing.
+  }
 }
 
 export function isLoggedIn(): boolean {
@@ -215,6 +397,7 @@ export async function updateFlow(
     overview: string;
     diagram_json: string;
     nodes: FlowNode[];
+    revision?: number;
   }
 ) {
   return request<Flow>(`/flows/${id}`, {
@@ -223,6 +406,10 @@ export async function updateFlow(
   });
 }
 
+export async function deleteFlow(id: string) {
+  return request<{ status: string }>(`/flows/${id}`, { method: "DELETE" });
+}
+
 export async function submitFlowReview(id: string) {
   return request<Flow>(`/flows/${id}/submit_review`, {
     method: "POST",
@@ -235,6 +422,18 @@ export async function publishFlow(id: string) {
   });
 }
 
+export async function rejectFlow(id: string) {
+  return request<Flow>(`/flows/${id}/reject`, {
+    method: "POST",
+  });
+}
+
+export async function createFlowDraft(id: string) {
+  return request<Flow>(`/flows/${id}/new_draft`, {
+    method: "POST",
+  });
+}
+
 export async function listFlowVersions(flowId: string) {
   return request<FlowVersion[]>(`/flows/${flowId}/versions`);
 }
@@ -246,6 +445,255 @@ export async function getFlowVersionDetail(
   return request<FlowVersion>(`/flows/${flowId}/versions/${versionId}`);
 }
 
+export interface FieldChange {
+  field: string;
+  before: unknown;
+  after: unknown;
+}
+
+export interface DiagramElementChange {
+  id: string;
+  changes: FieldChange[];
+}
+
+export interface FlowVersionDiff {
+  from_version_id: string;
+  to_version_id: string;
+  header: FieldChange[];
+  nodes_added: FlowNode[];
+  nodes_removed: FlowNode[];
+  nodes_modified: Array<{
+    node_id: string;
+    node_no: string;
+    name: string;
+    matched_by: "id" | "node_no";
+    changes: FieldChange[];
+  }>;
+  diagram: {
+    nodes_added: unknown[];
+    nodes_removed: unknown[];
+    nodes_modified: DiagramElementChange[];
+    edges_added: unknown[];
+    edges_removed: unknown[];
+    edges_modified: DiagramElementChange[];
+  };
+}
+
+export async function diffFlowVersions(
+  flowId: string,
+  fromVersionId: string,
+  toVersionId: string
+) {
+  return request<FlowVersionDiff>(
+    `/flows/${flowId}/versions/${fromVersionId}/diff/${toVersionId}`
+  );
+}
+
+export async function restoreFlowVersion(flowId: string, versionId: string) {
+  return request<Flow>(`/flows/${flowId}/versions/${versionId}/restore`, {
+    method: "POST",
+  });
+}
+
+// ---------- Shares ----------
+
+export type ShareRole = "VIEW" | "EDIT";
+export type ShareResource = "docs" | "flows";
+
+export interface Share {
+  id: string;
+  resource: "document" | "flow";
+  resource_id: string;
+  user_id: string;
+  user_email: string;
+  role: ShareRole;
+  created_at: string;
+}
+
+export async function listShares(resource: ShareResource, id: string) {
+  return request<Share[]>(`/${resource}/${id}/shares`);
+}
+
+export async function createShare(
+  resource: ShareResource,
+  id: string,
+  data: { user_id?: string; email?: string; role?: ShareRole }
+) {
+  return request<Share>(`/${resource}/${id}/shares`, {
+    method: "POST",
+    body: JSON.stringify(data),
+  });
+}
+
+export async function updateShare(
+  resource: ShareResource,
+  id: string,
+  shareId: string,
+  role: ShareRole
+) {
+  return request<Share>(`/${resource}/${id}/shares/${shareId}`, {
+    method: "PUT",
+    body: JSON.stringify({ role }),
+  });
+}
+
+export async function deleteShare(resource: ShareResource, id: string, shareId: string) {
+  return request<{ status: string }>(`/${resource}/${id}/shares/${shareId}`, {
+    method: "DELETE",
+  });
+}
+
+// ---------- Ownership ----------
+
+export interface OwnershipTransfer {
+  id: string;
+  resource_type: "document" | "flow";
+  resource_id: string;
+  from_user_id: string;
+  to_user_id: string;
+  transferred_by: string;
+  kept_edit_share: boolean;
+  created_at: string;
+}
+
+export async function transferOwnership(
+  resource: ShareResource,
+  id: string,
+  data: { new_owner_id?: string; email?: string; keep_edit_share?: boolean }
+) {
+  return request<OwnershipTransfer>(`/${resource}/${id}/transfer`, {
+    method: "POST",
+    body: JSON.stringify(data),
+  });
+}
+
+export async function listOwnershipTransfers(resource: ShareResource, id: string) {
+  return request<OwnershipTransfer[]>(`/${resource}/${id}/transfers`);
+}
+
+// ---------- Trash ----------
+
+export interface TrashListing {
+  documents: { id: string; title: string; deleted_at: string }[];
+  flows: Flow[];
+  nodes: { id: string; document_id: string; name: string; deleted_at: string }[];
+}
+
+export async function listTrash() {
+  return request<TrashListing>("/trash");
+}
+
+export async function restoreFromTrash(
+  kind: "documents" | "flows" | "nodes",
+  id: string
+) {
+  return request<unknown>(`/trash/${kind}/${id}/restore`, { method: "POST" });
+}
+
+// ---------- Account ----------
+
+export async function getMe() {
+  return request<UserInfo>("/me");
+}
+
+export async function updateMe(data: {
+  display_name: string;
+  department: string;
+  locale: string;
+}) {
+  return request<UserInfo>("/me", {
+    method: "PUT",
+    body: JSON.stringify(data),
+  });
+}
+
+/**
+ * Change the caller's password. The server ends every session of the user
+ * and returns a new token pair for this one.
+ */
+export async function changePassword(currentPassword: string, newPassword: string) {
+  const result = await request<AuthResult>("/me/password", {
+    method: "POST",
+    body: JSON.stringify({ current_password: currentPassword, new_password: newPassword }),
+  });
+  storeSession(result);
+  return result;
+}
+
+// ---------- Two-factor authentication ----------
+
+export interface TwoFactorStatus {
+  enabled: boolean;
+  pending: boolean; // set up but not confirmed yet
+  required: boolean; // the user's role requires it
+  recovery_codes_remaining: number;
+}
+
+export async function getTwoFactorStatus() {
+  return request<TwoFactorStatus>("/me/2fa");
+}
+
+/** Start enrollment; `uri` is the otpauth:// link authenticator apps scan. */
+export async function setupTwoFactor() {
+  return request<{ secret: string; uri: string }>("/me/2fa/setup", { method: "POST" });
+}
+
+// The returned recovery codes are shown once.
+export async function confirmTwoFactor(code: string) {
+  return request<{ recovery_codes: string[] }>("/me/2fa/confirm", {
+    method: "POST",
+    body: JSON.stringify({ code }),
+  });
+}
+
+export async function regenerateRecoveryCodes(code: string) {
+  return request<{ recovery_codes: string[] }>("/me/2fa/recovery_codes", {
+    method: "POST",
+    body: JSON.stringify({ code }),
+  });
+}
+
+export async function disableTwoFactor(code: string) {
+  return request<{ status: string }>("/me/2fa/disable", {
+    method: "POST",
+    body: JSON.stringify({ code }),
+  });
+}
+
+// ---------- Personal Access Tokens ----------
+
+export type TokenScope = "read" | "write" | "admin";
+
+export interface APIToken {
+  id: string;
+  name: string;
+  token_prefix: string;
+  scopes: TokenScope[];
+  expires_at: string;
+  created_at: string;
+  last_used_at: string | null;
+}
+
+export async function listAPITokens() {
+  return request<APIToken[]>("/tokens");
+}
+
+// The returned token is shown once; only its prefix can be listed later.
+export async function createAPIToken(data: {
+  name: string;
+  scopes: TokenScope[];
+  expires_in_days?: number;
+}) {
+  return request<APIToken & { token: string }>("/tokens", {
+    method: "POST",
+    body: JSON.stringify(data),
+  });
+}
+
+export async function revokeAPIToken(id: string) {
+  return request<{ status: string }>(`/tokens/${id}`, { method: "DELETE" });
+}
+
 // ---------- Admin: User Management ----------
 
 export async function listUsers() {
@@ -270,6 +718,76 @@ export async function resetUserPassword(userId: string, password: string) {
   });
 }
 
+export async function updateUserRole(userId: string, role: string) {
+  return request<UserInfo>(`/admin/users/${userId}/role`, {
+    method: "PUT",
+    body: JSON.stringify({ role }),
+  });
+}
+
+export async function setUserActive(userId: string, active: boolean) {
+  return request<UserInfo>(
+    `/admin/users/${userId}/${active ? "reactivate" : "deactivate"}`,
+    { method: "POST" }
+  );
+}
+
+export async function deleteUser(userId: string) {
+  return request<{ status: string }>(`/admin/users/${userId}`, { method: "DELETE" });
+}
+
+export async function resetUserTwoFactor(userId: string) {
+  return request<{ status: string }>(`/admin/users/${userId}/reset_2fa`, { method: "POST" });
+}
+
+export interface SecuritySettings {
+  require_2fa_for_admins: boolean;
+}
+
+export async function getSecuritySettings() {
+  return request<SecuritySettings>("/admin/security");
+}
+
+export async function updateSecuritySettings(settings: SecuritySettings) {
+  return request<SecuritySettings>("/admin/security", {
+    method: "PUT",
+    body: JSON.stringify(settings),
+  });
+}
+
+export interface LoginLockout {
+  key: string; // "account:<email>" or "ip:<address>"
+  failures: number;
+  last_failure_at: string;
+  locked: boolean; // the failure limit was reached
+  blocked_until: string | null;
+}
+
+export async function listLockouts() {
+  return request<LoginLockout[]>("/admin/lockouts");
+}
+
+export async function clearLockout(key: string) {
+  return request<{ status: string }>("/admin/lockouts/clear", {
+    method: "POST",
+    body: JSON.stringify({ key }),
+  });
+}
+
+export async function transferUserOwnership(
+  userId: string,
+  toUserId: string,
+  keepEditShare = false
+) {
+  return request<{ documents: number; flows: number }>(
+    `/admin/users/${userId}/transfer_ownership`,
+    {
+      method: "POST",
+      body: JSON.stringify({ to_user_id: toUserId, keep_edit_share: keepEditShare }),
+    }
+  );
+}
+
 // ---------- Workflow Nodes ----------
 
 export interface RACI {
@@ -299,6 +817,8 @@ export interface WorkflowNode {
   raci: RACI;
   subtasks: string[];
   diagram_json: DiagramJSON;
+  sort_order: number;
+  revision: number;
   created_at: string;
   updated_at: string;
 }
@@ -315,6 +835,7 @@ export interface NodeInput {
   raci: RACI;
   subtasks: string[];
   diagram_json: DiagramJSON;
+  revision?: number;
 }
 
 export async function listNodes(docId: string) {
@@ -338,3 +859,15 @@ export async function updateNode(nodeId: string, data: NodeInput) {
     body: JSON.stringify(data),
   });
 }
+
+export async function deleteNode(nodeId: string) {
+  return request<{ status: string }>(`/nodes/${nodeId}`, { method: "DELETE" });
+}
+
+/** Persists a new node order; `nodeIds` must list every node of the document once. */
+export async function reorderNodes(docId: string, nodeIds: string[]) {
+  return request<WorkflowNode[]>(`/docs/${docId}/nodes/order`, {
+    method: "PUT",
+    body: JSON.stringify({ node_ids: nodeIds }),
+  });
+}
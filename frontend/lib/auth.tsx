@@ -9,6 +9,7 @@ import {
   type ReactNode,
 } from "react";
 import { useRouter } from "next/navigation";
+import { logout } from "@/lib/api";
 
 interface AuthState {
   loggedIn: boolean;
@@ -40,7 +41,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
   }, []);
 
   const signOut = useCallback(() => {
-    localStorage.removeItem("token");
+    void logout();
     setLoggedIn(false);
     router.push("/login");
   }, [router]);
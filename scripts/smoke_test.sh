@@ -76,7 +76,11 @@ read_env() {
 
 BASE_URL="${BASE_URL:-http://localhost:$(read_env SERVER_PORT 8080)}"
 EMAIL="${ADMIN_EMAIL:-$(read_env ADMIN_EMAIL admin@docmv.local)}"
-PASSWORD="${ADMIN_PASSWORD:-$(read_env ADMIN_PASSWORD admin123)}"
+PASSWORD="${ADMIN_PASSWORD:-$(read_env ADMIN_PASSWORD "")}"
+if [[ -z "$PASSWORD" ]]; then
+  fail "未设置 ADMIN_PASSWORD — 请在环境变量或 backend/.env 中提供管理员密码（未配置时，首次启动生成的密码见后端日志）"
+  exit 1
+fi
 
 echo ""
 echo "=========================================="